@@ -0,0 +1,255 @@
+// Command analyze matches a range of CT log entries against a keyword list
+// and reports per-keyword hit counts, without a database:
+//
+//	analyze -keywords-file keywords.txt [-log-url URL] [-count 100]
+//	        [-start-index N] [-format text|json|csv] [-concurrency N]
+//	        [-save-entries FILE]
+//
+// The keywords file holds one keyword per line (or comma-separated, as
+// SEED_KEYWORDS_FILE), or is a CSV export with a "value" column when its
+// name ends in .csv; "-" reads stdin. -save-entries also writes the fetched
+// entries in the get-entries response format, for cmd/benchmatch. It exits
+// 1 when the log cannot be read and 2 on bad flags, so it can be scripted.
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/analyze"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/ctlog"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/seed"
+)
+
+const (
+	exitOK      = 0
+	exitFailure = 1 // the log could not be read
+	exitUsage   = 2 // bad flags or keywords file
+)
+
+// defaultLogURL matches the server's CT_LOG_URL default.
+const defaultLogURL = "https://oak.ct.letsencrypt.org/2026h2"
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+type options struct {
+	logURL       string
+	count        int
+	startIndex   int64
+	keywordsFile string
+	format       string
+	concurrency  int
+	saveEntries  string
+}
+
+func parseFlags(args []string, stderr io.Writer) (options, error) {
+	fs := flag.NewFlagSet("analyze", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	logURL := defaultLogURL
+	if v := os.Getenv("CT_LOG_URL"); v != "" {
+		logURL = v
+	}
+
+	var o options
+	fs.StringVar(&o.logURL, "log-url", logURL, "CT log base URL (default $CT_LOG_URL)")
+	fs.IntVar(&o.count, "count", 100, "number of entries to analyze")
+	fs.Int64Var(&o.startIndex, "start-index", -1, "first entry to analyze (default: the newest -count entries)")
+	fs.StringVar(&o.keywordsFile, "keywords-file", "", `keyword list: one per line, or a CSV with a "value" column if named *.csv ("-" for stdin)`)
+	fs.StringVar(&o.format, "format", "text", "output format: text, json or csv")
+	fs.IntVar(&o.concurrency, "concurrency", runtime.NumCPU(), "goroutines parsing certificates")
+	fs.StringVar(&o.saveEntries, "save-entries", "", "also write the fetched entries to this file as a get-entries response")
+	if err := fs.Parse(args); err != nil {
+		return o, err
+	}
+
+	switch {
+	case fs.NArg() > 0:
+		return o, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	case o.keywordsFile == "":
+		return o, errors.New("-keywords-file is required")
+	case o.count < 1:
+		return o, errors.New("-count must be positive")
+	case o.concurrency < 1:
+		return o, errors.New("-concurrency must be positive")
+	case o.format != "text" && o.format != "json" && o.format != "csv":
+		return o, errors.New("-format must be text, json or csv")
+	}
+	normalized, _, err := ctlog.NormalizeURL(o.logURL)
+	if err != nil {
+		return o, err
+	}
+	o.logURL = normalized
+	return o, nil
+}
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	o, err := parseFlags(args, stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "analyze: %v\n", err)
+		return exitUsage
+	}
+
+	keywords, err := readKeywords(o.keywordsFile, stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "analyze: read keywords: %v\n", err)
+		return exitUsage
+	}
+	if len(keywords) == 0 {
+		fmt.Fprintf(stderr, "analyze: %s has no keywords\n", o.keywordsFile)
+		return exitUsage
+	}
+
+	opts := []analyze.Option{analyze.WithConcurrency(o.concurrency)}
+	if o.startIndex >= 0 {
+		opts = append(opts, analyze.WithStart(o.startIndex))
+	}
+	client := &recordingClient{Client: ctlog.NewClient(o.logURL)}
+	res, err := analyze.Run(ctx, client, keywords, o.count, opts...)
+	if err != nil {
+		fmt.Fprintf(stderr, "analyze: %s: %v\n", o.logURL, err)
+		return exitFailure
+	}
+	if o.saveEntries != "" {
+		if err := saveEntries(o.saveEntries, client.entries); err != nil {
+			fmt.Fprintf(stderr, "analyze: save entries: %v\n", err)
+			return exitFailure
+		}
+	}
+
+	switch o.format {
+	case "json":
+		err = writeJSON(stdout, res)
+	case "csv":
+		err = writeCSV(stdout, res)
+	default:
+		err = writeText(stdout, o.logURL, res)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "analyze: write results: %v\n", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// recordingClient keeps every entry it fetches, for -save-entries.
+type recordingClient struct {
+	*ctlog.Client
+	entries []ctlog.RawEntry
+}
+
+func (c *recordingClient) GetEntries(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
+	entries, err := c.Client.GetEntries(ctx, start, end)
+	c.entries = append(c.entries, entries...)
+	return entries, err
+}
+
+// saveEntries writes entries to path in the get-entries response format.
+func saveEntries(path string, entries []ctlog.RawEntry) error {
+	data, err := json.Marshal(struct {
+		Entries []ctlog.RawEntry `json:"entries"`
+	}{entries})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// readKeywords loads path ("-" = stdin) as a CSV export when it ends in
+// .csv and as a seed list otherwise.
+func readKeywords(path string, stdin io.Reader) ([]model.Keyword, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var values []string
+	if strings.HasSuffix(strings.ToLower(path), ".csv") {
+		if values, err = seed.ParseCSV(strings.NewReader(string(data))); err != nil {
+			return nil, err
+		}
+	} else {
+		values = seed.Parse(string(data))
+	}
+
+	keywords := make([]model.Keyword, 0, len(values))
+	for i, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			keywords = append(keywords, model.Keyword{ID: i + 1, Value: v, MatchMode: model.MatchModeSubstring})
+		}
+	}
+	return keywords, nil
+}
+
+func writeJSON(w io.Writer, res *analyze.Result) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(res)
+}
+
+// writeCSV writes one row per keyword; sample_domains is a JSON array, as
+// the sans column of the certificate export.
+func writeCSV(w io.Writer, res *analyze.Result) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"keyword", "matches", "sample_domains"})
+	for _, kr := range res.Keywords {
+		samples, err := json.Marshal(kr.SampleDomains)
+		if err != nil {
+			return err
+		}
+		cw.Write([]string{kr.Keyword, strconv.Itoa(kr.Matches), string(samples)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeText(w io.Writer, logURL string, res *analyze.Result) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Log:          %s (tree size %d)\n", logURL, res.TreeSize)
+	fmt.Fprintf(&b, "Entries:      %d-%d (%d fetched, %d parse errors)\n\n", res.Start, res.End, res.Entries, res.ParseErrors)
+
+	var misses []string
+	for _, kr := range res.Keywords {
+		if kr.Matches == 0 {
+			misses = append(misses, kr.Keyword)
+			continue
+		}
+		fmt.Fprintf(&b, "%s: %d matches\n", kr.Keyword, kr.Matches)
+		for _, d := range kr.SampleDomains {
+			fmt.Fprintf(&b, "  - %s\n", d)
+		}
+		if more := kr.Matches - len(kr.SampleDomains); more > 0 {
+			fmt.Fprintf(&b, "  ... and %d more\n", more)
+		}
+	}
+	if len(misses) > 0 {
+		fmt.Fprintf(&b, "\nNo matches: %s\n", strings.Join(misses, ", "))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/fakectlog"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/analyze"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/ctlog"
+)
+
+// newLog serves 30 entries, every tenth a paypal domain.
+func newLog(t *testing.T) (*fakectlog.Log, string) {
+	t.Helper()
+	log := fakectlog.New(fakectlog.WithTreeSize(30), fakectlog.WithPageSize(8),
+		fakectlog.WithKeywords("paypal"), fakectlog.WithMatchEvery(10))
+	srv := httptest.NewServer(log)
+	t.Cleanup(srv.Close)
+	return log, srv.URL
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+func runAnalyze(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun_JSON(t *testing.T) {
+	_, url := newLog(t)
+	kwFile := writeFile(t, "keywords.txt", "# brands\npaypal\namazon\n")
+
+	code, out, stderr := runAnalyze(t, "", "-log-url", url, "-keywords-file", kwFile,
+		"-start-index", "0", "-count", "30", "-format", "json", "-concurrency", "3")
+	if code != exitOK {
+		t.Fatalf("exit %d, stderr: %s", code, stderr)
+	}
+	var res analyze.Result
+	if err := json.Unmarshal([]byte(out), &res); err != nil {
+		t.Fatalf("decode output: %v\n%s", err, out)
+	}
+	if res.Start != 0 || res.End != 29 || res.Entries != 30 {
+		t.Errorf("start/end/entries = %d/%d/%d, want 0/29/30", res.Start, res.End, res.Entries)
+	}
+	if len(res.Keywords) != 2 || res.Keywords[0].Matches != 3 || res.Keywords[1].Matches != 0 {
+		t.Errorf("keywords = %+v, want paypal 3 and amazon 0", res.Keywords)
+	}
+}
+
+func TestRun_CSVKeywordsAndOutput(t *testing.T) {
+	_, url := newLog(t)
+	kwFile := writeFile(t, "export.csv", "value,created_at\npaypal,2025-01-01T00:00:00Z\n")
+
+	code, out, stderr := runAnalyze(t, "", "-log-url", url, "-keywords-file", kwFile, "-count", "10", "-format", "csv")
+	if code != exitOK {
+		t.Fatalf("exit %d, stderr: %s", code, stderr)
+	}
+	want := "keyword,matches,sample_domains\npaypal,1,\"[\"\"paypal-login-20.example.com\"\"]\"\n"
+	if out != want {
+		t.Errorf("output = %q, want %q (newest 10 entries)", out, want)
+	}
+}
+
+func TestRun_TextFromStdin(t *testing.T) {
+	_, url := newLog(t)
+
+	code, out, stderr := runAnalyze(t, "paypal, amazon", "-log-url", url, "-keywords-file", "-")
+	if code != exitOK {
+		t.Fatalf("exit %d, stderr: %s", code, stderr)
+	}
+	for _, want := range []string{"tree size 30", "paypal: 3 matches", "  - paypal-login-0.example.com", "No matches: amazon"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output is missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRun_SaveEntries(t *testing.T) {
+	log, url := newLog(t)
+	path := filepath.Join(t.TempDir(), "batch.json")
+
+	code, _, stderr := runAnalyze(t, "paypal", "-log-url", url, "-keywords-file", "-", "-count", "20", "-save-entries", path)
+	if code != exitOK {
+		t.Fatalf("exit %d, stderr: %s", code, stderr)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read saved entries: %v", err)
+	}
+	var batch struct {
+		Entries []ctlog.RawEntry `json:"entries"`
+	}
+	if err := json.Unmarshal(data, &batch); err != nil {
+		t.Fatalf("decode saved entries: %v", err)
+	}
+	if len(batch.Entries) != 20 {
+		t.Fatalf("saved %d entries, want 20", len(batch.Entries))
+	}
+	cert, err := ctlog.ParseLeafInput(batch.Entries[0].LeafInput, batch.Entries[0].ExtraData)
+	if err != nil || cert.CommonName != log.Domain(10) {
+		t.Errorf("first saved entry = %+v, %v; want entry 10's certificate", cert, err)
+	}
+}
+
+func TestRun_FetchFailure(t *testing.T) {
+	log, url := newLog(t)
+	log.FailNext(1, http.StatusServiceUnavailable)
+
+	code, out, stderr := runAnalyze(t, "paypal", "-log-url", url, "-keywords-file", "-")
+	if code != exitFailure {
+		t.Errorf("exit %d, want %d", code, exitFailure)
+	}
+	if out != "" || !strings.Contains(stderr, "503") {
+		t.Errorf("stdout %q, stderr %q; want no output and the status on stderr", out, stderr)
+	}
+}
+
+func TestRun_Usage(t *testing.T) {
+	_, url := newLog(t)
+	for _, args := range [][]string{
+		{"-log-url", url},
+		{"-log-url", url, "-keywords-file", "-", "-format", "xml"},
+		{"-log-url", url, "-keywords-file", "-", "-count", "0"},
+		{"-log-url", url, "-keywords-file", "-", "-concurrency", "0"},
+		{"-log-url", "ftp://ct.example.com", "-keywords-file", "-"},
+		{"-log-url", url, "-keywords-file", "-", "extra"},
+		{"-log-url", url, "-keywords-file", filepath.Join(t.TempDir(), "missing.txt")},
+	} {
+		if code, _, _ := runAnalyze(t, "paypal", args...); code != exitUsage {
+			t.Errorf("%q: exit %d, want %d", args, code, exitUsage)
+		}
+	}
+	if code, _, _ := runAnalyze(t, "# nothing\n", "-log-url", url, "-keywords-file", "-"); code != exitUsage {
+		t.Errorf("empty keyword list: exit %d, want %d", code, exitUsage)
+	}
+}
@@ -0,0 +1,221 @@
+// Command benchmatch measures certificate parsing and keyword matching over
+// a batch of CT log entries, for checking changes to those hot paths:
+//
+//	benchmatch [-entries FILE | -synthetic N] [-keywords LIST]
+//	           [-benchtime 1s] [-count 1] [-cpuprofile FILE] [-memprofile FILE]
+//
+// FILE is a get-entries response, such as one saved with analyze
+// -save-entries; without it a deterministic corpus of N entries from
+// internal/benchcorpus is used. Every keyword is matched in both match
+// modes. Results are printed in the go test -bench format, one op per
+// certificate, so runs can be compared with benchstat.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"testing"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/benchcorpus"
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/ctlog"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/matcher"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/seed"
+)
+
+const (
+	exitOK      = 0
+	exitFailure = 1 // the entries could not be loaded or profiled
+	exitUsage   = 2 // bad flags
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+type options struct {
+	entriesFile string
+	synthetic   int
+	keywords    string
+	benchtime   string
+	count       int
+	cpuProfile  string
+	memProfile  string
+}
+
+func parseFlags(args []string, stderr io.Writer) (options, error) {
+	fs := flag.NewFlagSet("benchmatch", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	var o options
+	fs.StringVar(&o.entriesFile, "entries", "", "get-entries response to benchmark (default: a synthetic corpus)")
+	fs.IntVar(&o.synthetic, "synthetic", 1000, "entries in the synthetic corpus")
+	fs.StringVar(&o.keywords, "keywords", "", "comma-separated keywords (default: 10 synthetic brands)")
+	fs.StringVar(&o.benchtime, "benchtime", "1s", "run each benchmark for this long, or Nx times")
+	fs.IntVar(&o.count, "count", 1, "run each benchmark this many times")
+	fs.StringVar(&o.cpuProfile, "cpuprofile", "", "write a CPU profile of all runs to this file")
+	fs.StringVar(&o.memProfile, "memprofile", "", "write an allocation profile to this file when done")
+	if err := fs.Parse(args); err != nil {
+		return o, err
+	}
+
+	switch {
+	case fs.NArg() > 0:
+		return o, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	case o.synthetic < 1:
+		return o, errors.New("-synthetic must be positive")
+	case o.count < 1:
+		return o, errors.New("-count must be positive")
+	}
+	return o, nil
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	o, err := parseFlags(args, stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "benchmatch: %v\n", err)
+		return exitUsage
+	}
+	// testing.Benchmark reads its duration from the test flags.
+	testing.Init()
+	if err := flag.Set("test.benchtime", o.benchtime); err != nil {
+		fmt.Fprintf(stderr, "benchmatch: -benchtime: %v\n", err)
+		return exitUsage
+	}
+
+	keywords := benchcorpus.Keywords(10)
+	if o.keywords != "" {
+		keywords = keywordsOf(seed.Parse(o.keywords))
+	}
+	entries, source, err := loadEntries(o, keywords)
+	if err != nil {
+		fmt.Fprintf(stderr, "benchmatch: %v\n", err)
+		return exitFailure
+	}
+	certs := parseAll(entries)
+	if len(certs) == 0 {
+		fmt.Fprintf(stderr, "benchmatch: none of the %d entries parse\n", len(entries))
+		return exitFailure
+	}
+
+	if o.cpuProfile != "" {
+		f, err := os.Create(o.cpuProfile)
+		if err != nil {
+			fmt.Fprintf(stderr, "benchmatch: %v\n", err)
+			return exitFailure
+		}
+		defer f.Close()
+		if err := pprof.StartCPUProfile(f); err != nil {
+			fmt.Fprintf(stderr, "benchmatch: start CPU profile: %v\n", err)
+			return exitFailure
+		}
+		defer pprof.StopCPUProfile()
+	}
+
+	fmt.Fprintf(stdout, "goos: %s\ngoarch: %s\npkg: benchmatch\n", runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(stdout, "# %s: %d entries, %d parsed, %d keywords\n", source, len(entries), len(certs), len(keywords))
+	suffix := fmt.Sprintf("/entries=%d/keywords=%d-%d", len(entries), len(keywords), runtime.GOMAXPROCS(0))
+	for range o.count {
+		report(stdout, "BenchmarkParse"+suffix, perCert(len(entries), func(i int) {
+			ctlog.ParseLeafInput(entries[i].LeafInput, entries[i].ExtraData)
+		}))
+		report(stdout, "BenchmarkMatch"+suffix, perCert(len(certs), func(i int) {
+			matcher.Match(certs[i], keywords)
+		}))
+		report(stdout, "BenchmarkParseAndMatch"+suffix, perCert(len(entries), func(i int) {
+			if cert, err := ctlog.ParseLeafInput(entries[i].LeafInput, entries[i].ExtraData); err == nil {
+				matcher.Match(cert, keywords)
+			}
+		}))
+	}
+
+	if o.memProfile != "" {
+		f, err := os.Create(o.memProfile)
+		if err != nil {
+			fmt.Fprintf(stderr, "benchmatch: %v\n", err)
+			return exitFailure
+		}
+		defer f.Close()
+		if err := pprof.Lookup("allocs").WriteTo(f, 0); err != nil {
+			fmt.Fprintf(stderr, "benchmatch: write allocation profile: %v\n", err)
+			return exitFailure
+		}
+	}
+	return exitOK
+}
+
+// keywordsOf returns each value as a substring and a boundary keyword.
+func keywordsOf(values []string) []model.Keyword {
+	var kws []model.Keyword
+	for _, mode := range []string{model.MatchModeSubstring, model.MatchModeBoundary} {
+		for _, v := range values {
+			kws = append(kws, model.Keyword{ID: len(kws) + 1, Value: v, MatchMode: mode})
+		}
+	}
+	return kws
+}
+
+// loadEntries reads o.entriesFile, or generates the synthetic corpus, and
+// names the source for the report.
+func loadEntries(o options, keywords []model.Keyword) ([]ctlog.RawEntry, string, error) {
+	if o.entriesFile == "" {
+		leaves := benchcorpus.Leaves(o.synthetic, keywords)
+		entries := make([]ctlog.RawEntry, len(leaves))
+		for i, leaf := range leaves {
+			entries[i] = ctlog.RawEntry{LeafInput: leaf}
+		}
+		return entries, "synthetic corpus", nil
+	}
+
+	data, err := os.ReadFile(o.entriesFile)
+	if err != nil {
+		return nil, "", err
+	}
+	var batch struct {
+		Entries []ctlog.RawEntry `json:"entries"`
+	}
+	if err := json.Unmarshal(data, &batch); err != nil {
+		return nil, "", fmt.Errorf("%s: %w", o.entriesFile, err)
+	}
+	if len(batch.Entries) == 0 {
+		return nil, "", fmt.Errorf("%s has no entries", o.entriesFile)
+	}
+	return batch.Entries, o.entriesFile, nil
+}
+
+// parseAll returns the certificates of the entries that parse.
+func parseAll(entries []ctlog.RawEntry) []*ctlog.ParsedCertificate {
+	var certs []*ctlog.ParsedCertificate
+	for _, e := range entries {
+		if cert, err := ctlog.ParseLeafInput(e.LeafInput, e.ExtraData); err == nil {
+			certs = append(certs, cert)
+		}
+	}
+	return certs
+}
+
+// perCert benchmarks fn over indices 0..n-1, one index per op.
+func perCert(n int, fn func(i int)) testing.BenchmarkResult {
+	return testing.Benchmark(func(b *testing.B) {
+		b.ReportAllocs()
+		i := 0
+		for b.Loop() {
+			fn(i % n)
+			i++
+		}
+		b.ReportMetric(float64(i)/b.Elapsed().Seconds(), "certs/s")
+	})
+}
+
+func report(w io.Writer, name string, r testing.BenchmarkResult) {
+	fmt.Fprintf(w, "%s\t%s\t%s\n", name, r.String(), r.MemString())
+}
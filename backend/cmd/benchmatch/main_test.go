@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/benchcorpus"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/ctlog"
+)
+
+func runBench(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun_Synthetic(t *testing.T) {
+	code, out, stderr := runBench(t, "-synthetic", "50", "-benchtime", "20x", "-count", "2")
+	if code != exitOK {
+		t.Fatalf("exit %d, stderr: %s", code, stderr)
+	}
+	if !strings.Contains(out, "# synthetic corpus: 50 entries, 50 parsed, 20 keywords") {
+		t.Errorf("output is missing the corpus line:\n%s", out)
+	}
+	for _, name := range []string{"BenchmarkParse/", "BenchmarkMatch/", "BenchmarkParseAndMatch/"} {
+		if n := strings.Count(out, "\n"+name+"entries=50/keywords=20-"); n != 2 {
+			t.Errorf("%s reported %d times, want 2 (-count):\n%s", name, n, out)
+		}
+	}
+	if !strings.Contains(out, "certs/s") || !strings.Contains(out, "allocs/op") {
+		t.Errorf("output is missing certs/s or allocs/op:\n%s", out)
+	}
+}
+
+func TestRun_EntriesFileAndProfiles(t *testing.T) {
+	dir := t.TempDir()
+	leaves := benchcorpus.Leaves(10, benchcorpus.Keywords(2))
+	batch := struct {
+		Entries []ctlog.RawEntry `json:"entries"`
+	}{}
+	for _, leaf := range leaves {
+		batch.Entries = append(batch.Entries, ctlog.RawEntry{LeafInput: leaf})
+	}
+	batch.Entries = append(batch.Entries, ctlog.RawEntry{LeafInput: []byte("malformed")})
+	data, err := json.Marshal(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := filepath.Join(dir, "batch.json")
+	if err := os.WriteFile(entries, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cpu, mem := filepath.Join(dir, "cpu.pprof"), filepath.Join(dir, "mem.pprof")
+
+	code, out, stderr := runBench(t, "-entries", entries, "-keywords", "paypal,amazon", "-benchtime", "5x",
+		"-cpuprofile", cpu, "-memprofile", mem)
+	if code != exitOK {
+		t.Fatalf("exit %d, stderr: %s", code, stderr)
+	}
+	if !strings.Contains(out, "11 entries, 10 parsed, 4 keywords") {
+		t.Errorf("output is missing the batch line:\n%s", out)
+	}
+	for _, path := range []string{cpu, mem} {
+		if fi, err := os.Stat(path); err != nil || fi.Size() == 0 {
+			t.Errorf("profile %s: %v, want a non-empty file", path, err)
+		}
+	}
+}
+
+func TestRun_Errors(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want int
+	}{
+		{[]string{"-synthetic", "0"}, exitUsage},
+		{[]string{"-count", "0"}, exitUsage},
+		{[]string{"-benchtime", "soon"}, exitUsage},
+		{[]string{"extra"}, exitUsage},
+		{[]string{"-entries", filepath.Join(t.TempDir(), "missing.json")}, exitFailure},
+	} {
+		if code, _, _ := runBench(t, tc.args...); code != tc.want {
+			t.Errorf("%q: exit %d, want %d", tc.args, code, tc.want)
+		}
+	}
+}
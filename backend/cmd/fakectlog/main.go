@@ -0,0 +1,93 @@
+// Command fakectlog serves a synthetic CT log for local development. Point
+// the monitor at it with CT_LOG_URL=http://localhost:8081:
+//
+//	go run ./cmd/fakectlog -keywords paypal,amazon -match-every 50
+//
+// The tree grows by -grow entries every -interval. Failures are injected
+// at runtime through the control endpoints:
+//
+//	curl -X POST 'localhost:8081/fake/grow?n=1000'
+//	curl -X POST 'localhost:8081/fake/fail?status=429&count=3'
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/fakectlog"
+)
+
+func main() {
+	addr := flag.String("addr", ":8081", "listen address")
+	size := flag.Int64("size", 1000, "initial tree size")
+	grow := flag.Int64("grow", 100, "entries appended every -interval (0 disables growth)")
+	interval := flag.Duration("interval", 5*time.Second, "growth interval")
+	keywords := flag.String("keywords", "paypal", "comma-separated words embedded in matching domains")
+	matchEvery := flag.Int64("match-every", 50, "every nth entry carries a keyword (0 disables)")
+	malformedEvery := flag.Int64("malformed-every", 0, "every nth entry is unparsable (0 disables)")
+	pageSize := flag.Int64("page-size", 256, "maximum entries per get-entries response")
+	flag.Parse()
+
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+
+	if *interval <= 0 || *pageSize <= 0 || *size < 0 || *grow < 0 || *matchEvery < 0 || *malformedEvery < 0 {
+		slog.Error("invalid flags: -interval and -page-size must be positive, the others not negative")
+		os.Exit(2)
+	}
+
+	var words []string
+	for w := range strings.SplitSeq(*keywords, ",") {
+		if w = strings.TrimSpace(w); w != "" {
+			words = append(words, w)
+		}
+	}
+	log := fakectlog.New(
+		fakectlog.WithTreeSize(*size),
+		fakectlog.WithKeywords(words...),
+		fakectlog.WithMatchEvery(*matchEvery),
+		fakectlog.WithMalformedEvery(*malformedEvery),
+		fakectlog.WithPageSize(*pageSize),
+	)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *grow > 0 {
+		go func() {
+			ticker := time.NewTicker(*interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					log.Grow(*grow)
+					slog.Debug("tree grown", "tree_size", log.TreeSize())
+				}
+			}
+		}()
+	}
+
+	srv := &http.Server{Addr: *addr, Handler: log, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	slog.Info("fake CT log listening", "addr", *addr, "tree_size", *size, "keywords", words,
+		"match_every", *matchEvery, "malformed_every", *malformedEvery)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("server failed", "error", err)
+		os.Exit(1)
+	}
+}
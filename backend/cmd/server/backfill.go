@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/config"
+	"github.com/andres10976/SISAP-PoC/backend/internal/repository"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/ctlog"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/monitor"
+)
+
+// backfillRange is the inclusive range of log indices to rescan.
+type backfillRange struct {
+	start, end int64
+}
+
+func parseBackfill(args []string, stderr io.Writer) (func(*config.Config) int, error) {
+	r, err := parseBackfillRange(args, stderr)
+	if err != nil {
+		return nil, err
+	}
+	return func(cfg *config.Config) int { return backfill(cfg, r) }, nil
+}
+
+func parseBackfillRange(args []string, stderr io.Writer) (backfillRange, error) {
+	fs := newFlagSet("backfill", stderr)
+	rangeOf := rangeFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return backfillRange{}, err
+	}
+	if err := noArgs(fs); err != nil {
+		return backfillRange{}, err
+	}
+	return rangeOf()
+}
+
+// rangeFlags defines -start and -end on fs. The returned func validates
+// them once fs has been parsed.
+func rangeFlags(fs *flag.FlagSet) func() (backfillRange, error) {
+	start := fs.Int64("start", -1, "first CT log index to scan")
+	end := fs.Int64("end", -1, "last CT log index to scan (inclusive)")
+	return func() (backfillRange, error) {
+		switch {
+		case *start < 0 || *end < 0:
+			return backfillRange{}, errors.New("-start and -end are required and must not be negative")
+		case *end < *start:
+			return backfillRange{}, errors.New("-end must not be before -start")
+		}
+		return backfillRange{start: *start, end: *end}, nil
+	}
+}
+
+// backfill matches entries r.start..r.end of CT_LOG_URL against the stored
+// keywords. It does not move the monitor's position, so it is safe to run
+// next to a serving instance. Interrupting it exits with exitFailure;
+// matches stored so far are kept and rerunning skips them.
+func backfill(cfg *config.Config, r backfillRange) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	pool, err := openDatabase(cfg)
+	if err != nil {
+		slog.Error("database setup failed", "error", err)
+		return exitFailure
+	}
+	defer pool.Close()
+
+	certRepo, err := certificateRepository(pool, cfg)
+	if err != nil {
+		slog.Error("invalid configuration", "error", err)
+		return exitFailure
+	}
+	client := ctlog.NewClient(cfg.CTLogURL,
+		ctlog.WithMaxResponseBytes(cfg.CTLogMaxResponseBytes),
+		ctlog.WithSTHCacheTTL(cfg.CTLogSTHCacheTTL))
+	opts := append([]monitor.Option{
+		monitor.WithMaxMatchesPerCert(cfg.MonitorMaxMatchesPerCert),
+		monitor.WithServerAuthOnly(cfg.MonitorServerAuthOnly),
+		monitor.WithIgnoreCN(cfg.MatchIgnoreCN),
+		monitor.WithMinNotBefore(cfg.MonitorMinNotBefore),
+		monitor.WithLogMatches(cfg.LogMatches),
+		monitor.WithLogs(cfg.CTLogURL, cfg.CTLogURL, nil),
+	}, chainVerifier(ctx, cfg, client)...)
+	mon := monitor.New(client, repository.NewKeywordRepository(pool), certRepo,
+		repository.NewMonitorRepository(pool), cfg.MonitorBatchSize, cfg.MonitorInterval, false, opts...)
+
+	slog.Info("backfill starting", "start", r.start, "end", r.end, "ct_log_url", cfg.CTLogURL)
+	stats, err := mon.Backfill(ctx, r.start, r.end)
+	attrs := []any{"entries", stats.Entries, "matches", stats.Matches,
+		"parse_errors", stats.ParseErrors, "dropped_matches", stats.DroppedMatches,
+		"skipped_not_before", stats.SkippedNotBefore, "cn_less_matches", stats.CNLessMatches, "duration", stats.Duration}
+	if err != nil {
+		slog.Error("backfill failed", append(attrs, "error", err)...)
+		return exitFailure
+	}
+	slog.Info("backfill complete", attrs...)
+	return exitOK
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestParseBackfillRange(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    backfillRange
+		wantErr string
+	}{
+		{[]string{"-start", "100", "-end", "199"}, backfillRange{start: 100, end: 199}, ""},
+		{[]string{"-start=5", "-end=5"}, backfillRange{start: 5, end: 5}, ""},
+		{[]string{"-start", "100"}, backfillRange{}, "required"},
+		{[]string{"-end", "100"}, backfillRange{}, "required"},
+		{[]string{"-start", "200", "-end", "100"}, backfillRange{}, "before -start"},
+		{[]string{"-start", "-3", "-end", "100"}, backfillRange{}, "must not be negative"},
+		{[]string{"-start", "x", "-end", "100"}, backfillRange{}, "invalid value"},
+		{[]string{"-start", "1", "-end", "2", "extra"}, backfillRange{}, "unexpected argument"},
+	}
+	for _, tt := range tests {
+		got, err := parseBackfillRange(tt.args, io.Discard)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseBackfillRange(%q) error = %v, want %q", tt.args, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseBackfillRange(%q) = %+v, %v; want %+v", tt.args, got, err, tt.want)
+		}
+	}
+}
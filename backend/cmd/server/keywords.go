@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/config"
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/repository"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/audit"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/seed"
+)
+
+// cliActor attributes audit entries written by CLI subcommands.
+const cliActor = "cli"
+
+// keywordsCommand is a parsed "keywords import|export -file PATH".
+type keywordsCommand struct {
+	action string
+	file   string
+}
+
+func parseKeywords(args []string, stderr io.Writer) (func(*config.Config) int, error) {
+	kc, err := parseKeywordsCommand(args, stderr)
+	if err != nil {
+		return nil, err
+	}
+	if kc.action == "import" {
+		return func(cfg *config.Config) int { return importKeywords(cfg, kc.file) }, nil
+	}
+	return func(cfg *config.Config) int { return exportKeywords(cfg, kc.file) }, nil
+}
+
+func parseKeywordsCommand(args []string, stderr io.Writer) (keywordsCommand, error) {
+	if len(args) == 0 {
+		return keywordsCommand{}, errors.New("missing action: import or export")
+	}
+	action := args[0]
+	if action != "import" && action != "export" {
+		return keywordsCommand{}, fmt.Errorf("unknown action %q: want import or export", action)
+	}
+	fs := newFlagSet("keywords "+action, stderr)
+	file := fs.String("file", "", `keyword list, one per line or comma-separated ("-" for stdin/stdout)`)
+	if err := fs.Parse(args[1:]); err != nil {
+		return keywordsCommand{}, err
+	}
+	if err := noArgs(fs); err != nil {
+		return keywordsCommand{}, err
+	}
+	if *file == "" {
+		return keywordsCommand{}, errors.New("-file is required")
+	}
+	return keywordsCommand{action: action, file: *file}, nil
+}
+
+// importKeywords adds the keywords listed in path, in the SEED_KEYWORDS_FILE
+// format. Existing keywords are skipped, so importing twice is a no-op.
+func importKeywords(cfg *config.Config, path string) int {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		slog.Error("failed to read keywords", "path", path, "error", err)
+		return exitFailure
+	}
+	values := seed.Parse(string(data))
+
+	pool, err := openDatabase(cfg)
+	if err != nil {
+		slog.Error("database setup failed", "error", err)
+		return exitFailure
+	}
+	defer pool.Close()
+
+	ctx := audit.WithActor(context.Background(), cliActor)
+	imported, err := seed.Keywords(ctx, repository.NewKeywordRepository(pool), values)
+	if err != nil {
+		slog.Error("failed to import keywords", "error", err)
+		return exitFailure
+	}
+	if imported > 0 {
+		audit.NewRecorder(repository.NewAuditRepository(pool)).Record(ctx,
+			model.AuditActionImport, model.AuditEntityKeyword, "",
+			map[string]model.AuditChange{"count": {New: strconv.Itoa(imported)}})
+	}
+	slog.Info("keywords imported", "imported", imported, "skipped", len(values)-imported)
+	return exitOK
+}
+
+// exportKeywords writes every keyword to path, one per line, in a form
+// importKeywords and SEED_KEYWORDS_FILE accept.
+func exportKeywords(cfg *config.Config, path string) int {
+	pool, err := openDatabase(cfg)
+	if err != nil {
+		slog.Error("database setup failed", "error", err)
+		return exitFailure
+	}
+	defer pool.Close()
+
+	keywords, err := repository.NewKeywordRepository(pool).List(context.Background())
+	if err != nil {
+		slog.Error("failed to list keywords", "error", err)
+		return exitFailure
+	}
+
+	out := os.Stdout
+	if path != "-" {
+		if out, err = os.Create(path); err != nil {
+			slog.Error("failed to create export file", "path", path, "error", err)
+			return exitFailure
+		}
+	}
+	w := bufio.NewWriter(out)
+	for _, kw := range keywords {
+		fmt.Fprintln(w, kw.Value)
+	}
+	err = w.Flush()
+	if path != "-" {
+		err = errors.Join(err, out.Close())
+	}
+	if err != nil {
+		slog.Error("failed to write keywords", "path", path, "error", err)
+		return exitFailure
+	}
+	slog.Info("keywords exported", "count", len(keywords), "path", path)
+	return exitOK
+}
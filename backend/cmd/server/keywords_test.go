@@ -0,0 +1,35 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestParseKeywordsCommand(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    keywordsCommand
+		wantErr string
+	}{
+		{[]string{"import", "-file", "kw.txt"}, keywordsCommand{action: "import", file: "kw.txt"}, ""},
+		{[]string{"export", "-file", "-"}, keywordsCommand{action: "export", file: "-"}, ""},
+		{nil, keywordsCommand{}, "missing action"},
+		{[]string{"delete", "-file", "kw.txt"}, keywordsCommand{}, `unknown action "delete"`},
+		{[]string{"import"}, keywordsCommand{}, "-file is required"},
+		{[]string{"export", "-file", "kw.txt", "more"}, keywordsCommand{}, "unexpected argument"},
+		{[]string{"import", "-path", "kw.txt"}, keywordsCommand{}, "flag provided but not defined"},
+	}
+	for _, tt := range tests {
+		got, err := parseKeywordsCommand(tt.args, io.Discard)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseKeywordsCommand(%q) error = %v, want %q", tt.args, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseKeywordsCommand(%q) = %+v, %v; want %+v", tt.args, got, err, tt.want)
+		}
+	}
+}
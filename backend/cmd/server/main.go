@@ -1,159 +1,270 @@
+// Command server runs the CT monitor API (the default "serve" subcommand)
+// and its operational tasks:
+//
+//	server [serve]                       run the API server and monitor
+//	server migrate                       apply database migrations and exit
+//	server backfill -start N -end M      match CT log entries N..M and exit
+//	server verify -start N -end M [-fix] diff a rescan of N..M with stored matches
+//	server keywords import -file PATH    add keywords from a file ("-" = stdin)
+//	server keywords export -file PATH    write keywords to a file ("-" = stdout)
+//
+// Every subcommand reads the same environment configuration.
 package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"os"
-	"os/signal"
 	"strconv"
-	"syscall"
+	"strings"
 	"time"
 
-	"github.com/go-chi/chi/v5"
-	chiMiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/andres10976/SISAP-PoC/backend/internal/config"
 	"github.com/andres10976/SISAP-PoC/backend/internal/database"
-	"github.com/andres10976/SISAP-PoC/backend/internal/handler"
-	"github.com/andres10976/SISAP-PoC/backend/internal/middleware"
+	"github.com/andres10976/SISAP-PoC/backend/internal/logging"
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
 	"github.com/andres10976/SISAP-PoC/backend/internal/repository"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/audit"
 	"github.com/andres10976/SISAP-PoC/backend/internal/service/ctlog"
 	"github.com/andres10976/SISAP-PoC/backend/internal/service/monitor"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/risk"
 )
 
-func getEnv(key, fallback string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
-	}
-	return fallback
+// Exit codes shared by all subcommands.
+const (
+	exitOK      = 0
+	exitFailure = 1 // the command ran and failed
+	exitUsage   = 2 // bad subcommand, flags or arguments
+)
+
+const usage = `usage: server [command] [flags]
+
+commands:
+  serve                           run the API server and monitor (default)
+  migrate                         apply database migrations and exit
+  backfill -start N -end M        match CT log entries N..M (inclusive) and exit
+  verify -start N -end M [-fix]   rescan N..M and report missing, extra and mismatched matches
+  keywords import -file PATH      add keywords from PATH ("-" reads stdin)
+  keywords export -file PATH      write keywords to PATH ("-" writes stdout)
+
+Configuration is read from the environment; see CLAUDE.md.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
 }
 
-func getInt(key string, fallback int) int {
-	v := os.Getenv(key)
-	if v == "" {
-		return fallback
+// logRing holds the server's most recent log lines.
+var logRing = logging.NewRing(logging.DefaultRingLines)
+
+// run dispatches to a subcommand and returns the process exit code. With no
+// arguments it serves, so existing deployments keep working. The server
+// logs JSON to stdout; the other subcommands log to stderr so stdout stays
+// free for their output (e.g. keywords export -file -).
+func run(args []string, stdout, stderr io.Writer) int {
+	name, rest := "serve", args
+	if len(args) > 0 {
+		name, rest = args[0], args[1:]
 	}
-	n, err := strconv.Atoi(v)
-	if err != nil {
-		return fallback
+	logOut := stderr
+	if name == "serve" {
+		// The server also keeps its latest lines for GET /monitor/logs.
+		logOut = io.MultiWriter(stdout, logRing)
 	}
-	return n
-}
+	slog.SetDefault(slog.New(logging.NewHandler(slog.NewJSONHandler(logOut, nil))))
 
-func getDuration(key string, fallback time.Duration) time.Duration {
-	v := os.Getenv(key)
-	if v == "" {
-		return fallback
+	var parse func([]string, io.Writer) (func(*config.Config) int, error)
+	switch name {
+	case "serve":
+		parse = parseServe
+	case "migrate":
+		parse = parseMigrate
+	case "backfill":
+		parse = parseBackfill
+	case "verify":
+		parse = parseVerify
+	case "keywords":
+		parse = parseKeywords
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stderr, usage)
+		return exitOK
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", name, usage)
+		return exitUsage
+	}
+
+	cmd, err := parse(rest, stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
 	}
-	d, err := time.ParseDuration(v)
 	if err != nil {
-		return fallback
+		fmt.Fprintf(stderr, "%s: %v\n", name, err)
+		return exitUsage
 	}
-	return d
+
+	cfg := config.Load()
+	if err := cfg.Validate(); err != nil {
+		slog.Error("invalid configuration", "error", err)
+		return exitFailure
+	}
+	slog.LogAttrs(context.Background(), slog.LevelInfo, "configuration loaded", cfg.LogAttrs()...)
+	return cmd(cfg)
+}
+
+// newFlagSet returns a flag set that reports errors instead of exiting.
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	return fs
 }
 
-func getBool(key string, fallback bool) bool {
-	v := os.Getenv(key)
-	if v == "" {
-		return fallback
+// noArgs rejects positional arguments left over after flag parsing.
+func noArgs(fs *flag.FlagSet) error {
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected argument %q", fs.Arg(0))
 	}
-	b, err := strconv.ParseBool(v)
-	if err != nil {
-		return fallback
+	return nil
+}
+
+func parseServe(args []string, stderr io.Writer) (func(*config.Config) int, error) {
+	fs := newFlagSet("serve", stderr)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
 	}
-	return b
+	if err := noArgs(fs); err != nil {
+		return nil, err
+	}
+	return serve, nil
 }
 
-func main() {
-	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, nil)))
-
-	// Config
-	databaseURL := os.Getenv("DATABASE_URL")
-	if databaseURL == "" {
-		slog.Error("DATABASE_URL environment variable is required")
-		os.Exit(1)
-	}
-	serverPort := getEnv("SERVER_PORT", "8080")
-	ctLogURL := getEnv("CT_LOG_URL", "https://oak.ct.letsencrypt.org/2026h2")
-	corsOrigin := getEnv("CORS_ALLOW_ORIGIN", "http://localhost:3000")
-	monitorInterval := getDuration("MONITOR_INTERVAL", 60*time.Second)
-	monitorBatchSize := getInt("MONITOR_BATCH_SIZE", 100)
-	monitorReprocessOnIdle := getBool("MONITOR_REPROCESS_ON_IDLE", false)
-
-	// Database
-	pool, err := database.Connect(databaseURL)
+// openDatabase connects to DATABASE_URL and applies the migrations, which
+// are idempotent, so every subcommand sees the current schema.
+func openDatabase(cfg *config.Config) (*pgxpool.Pool, error) {
+	pool, err := database.Connect(cfg.DatabaseURL, cfg.DBQueryTimeout)
 	if err != nil {
-		slog.Error("database connection failed", "error", err)
-		os.Exit(1)
+		return nil, err
 	}
-	defer pool.Close()
-
 	if err := database.Migrate(pool); err != nil {
-		slog.Error("migration failed", "error", err)
-		os.Exit(1)
+		pool.Close()
+		return nil, err
 	}
+	return pool, nil
+}
 
-	// Repositories
-	keywordRepo := repository.NewKeywordRepository(pool)
-	certRepo := repository.NewCertificateRepository(pool)
-	monitorRepo := repository.NewMonitorRepository(pool)
-
-	// Reset stale monitor state from previous process crash
-	if err := monitorRepo.SetRunning(context.Background(), false); err != nil {
-		slog.Error("failed to reset monitor state", "error", err)
-		os.Exit(1)
+// certificateRepository returns the certificate repository with the
+// configured conflict strategy, scoring matches with the default risk
+// weights overridden by RISK_WEIGHTS.
+func certificateRepository(pool *pgxpool.Pool, cfg *config.Config) (*repository.CertificateRepository, error) {
+	scorer, err := risk.New(cfg.RiskWeights)
+	if err != nil {
+		return nil, fmt.Errorf("RISK_WEIGHTS: %w", err)
 	}
+	return repository.NewCertificateRepository(pool,
+		repository.WithConflictStrategy(repository.ConflictStrategy(cfg.CertConflictStrategy)),
+		repository.WithScorer(scorer.Score),
+		repository.WithConsolidation(cfg.CertConsolidate),
+	), nil
+}
 
-	// Services
-	ctClient := ctlog.NewClient(ctLogURL)
-	mon := monitor.New(ctClient, keywordRepo, certRepo, monitorRepo, monitorBatchSize, monitorInterval, monitorReprocessOnIdle)
-
-	// Handlers
-	kwHandler := handler.NewKeywordHandler(keywordRepo)
-	certHandler := handler.NewCertificateHandler(certRepo)
-	monHandler := handler.NewMonitorHandler(mon, monitorRepo)
-
-	// Router
-	r := chi.NewRouter()
-	r.Use(middleware.CORS(corsOrigin))
-	r.Use(chiMiddleware.Logger)
-	r.Use(middleware.Recovery)
+// chainVerifier returns monitor options that verify matched certificates
+// against the system roots plus the log's own, or none when
+// MONITOR_VERIFY_CHAINS is off. If get-roots fails, only the system roots
+// are trusted.
+func chainVerifier(ctx context.Context, cfg *config.Config, client *ctlog.Client) []monitor.Option {
+	if !cfg.MonitorVerifyChains {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	roots, err := client.GetRoots(ctx)
+	if err != nil {
+		slog.Warn("failed to fetch the CT log's roots, verifying chains against system roots only", "error", err)
+	}
+	return []monitor.Option{monitor.WithChainVerifier(ctlog.NewVerifier(ctlog.RootPool(roots)))}
+}
 
-	r.Route("/api/v1", func(r chi.Router) {
-		kwHandler.RegisterRoutes(r)
-		certHandler.RegisterRoutes(r)
-		monHandler.RegisterRoutes(r)
-	})
+// auditedDisabler records the keywords the monitor disables in the audit
+// trail, as the system actor.
+type auditedDisabler struct {
+	keywords monitor.KeywordDisabler
+	audit    auditRecorder
+}
 
-	// Server with graceful shutdown
-	srv := &http.Server{
-		Addr:         fmt.Sprintf(":%s", serverPort),
-		Handler:      r,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 30 * time.Second,
-		IdleTimeout:  60 * time.Second,
+func (d auditedDisabler) Disable(ctx context.Context, id int, reason string) (*model.Keyword, error) {
+	kw, err := d.keywords.Disable(ctx, id, reason)
+	if err != nil {
+		return nil, err
 	}
+	d.audit.Record(audit.WithActor(ctx, audit.System), model.AuditActionDisable, model.AuditEntityKeyword,
+		strconv.Itoa(id), map[string]model.AuditChange{"disabled_reason": {New: reason}})
+	return kw, nil
+}
 
-	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-	defer stop()
-
-	go func() {
-		slog.Info("server starting", "port", serverPort)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			slog.Error("server error", "error", err)
-			os.Exit(1)
+// logDialer returns how the monitor opens another CT log when it switches:
+// only logs on CT_LOG_URL's or CT_LOG_SUCCESSOR_URL's host or on a host in
+// ALLOWED_CT_LOG_URLS, with the same client settings as CT_LOG_URL.
+// Redirects are only followed to those hosts.
+func logDialer(cfg *config.Config, allowedHosts []string) func(string) (monitor.LogClient, error) {
+	allowed := make(map[string]bool, len(allowedHosts)+2)
+	for _, host := range allowedHosts {
+		allowed[host] = true
+	}
+	for _, u := range []string{cfg.CTLogURL, cfg.CTLogSuccessorURL} {
+		if normalized, _, err := ctlog.NormalizeURL(u); err == nil {
+			allowed[ctlog.Host(normalized)] = true
+		}
+	}
+	return func(raw string) (monitor.LogClient, error) {
+		normalized, _, err := ctlog.NormalizeURL(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", monitor.ErrInvalidLogURL, err)
 		}
-	}()
+		if host := ctlog.Host(normalized); !allowed[host] {
+			return nil, fmt.Errorf("%w: log host %s is not in ALLOWED_CT_LOG_URLS", monitor.ErrLogNotAllowed, host)
+		}
+		return ctlog.NewClient(normalized,
+			ctlog.WithMaxResponseBytes(cfg.CTLogMaxResponseBytes),
+			ctlog.WithSTHCacheTTL(cfg.CTLogSTHCacheTTL),
+			ctlog.WithAllowedRedirects(func(host string) bool { return allowed[host] })), nil
+	}
+}
 
-	<-ctx.Done()
-	slog.Info("shutting down")
+// auditedLogSwitch records the monitor's automatic switches to a successor
+// log in the audit trail, as the system actor.
+type auditedLogSwitch struct {
+	audit auditRecorder
+}
 
-	// Stop the monitor if running
-	mon.Stop(context.Background())
+func (a auditedLogSwitch) RecordLogSwitch(ctx context.Context, sw monitor.LogSwitch) {
+	a.audit.Record(audit.WithActor(ctx, audit.System), model.AuditActionSwitchLog, model.AuditEntityMonitor, "ct_log",
+		map[string]model.AuditChange{
+			"ct_log_url":  {Old: sw.From, New: sw.To},
+			"start_index": {New: strconv.FormatInt(sw.StartIndex, 10)},
+			"reason":      {New: sw.Reason},
+		})
+}
 
-	// Give in-flight requests time to complete
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	srv.Shutdown(shutdownCtx)
+// withBasePath serves h under BASE_PATH with the prefix stripped, so the
+// routes and the middleware that matches on paths (timeouts, body limits,
+// log and trace filters) see the same paths as without one; basePath
+// itself serves the router's root. Requests outside the prefix get a 404.
+func withBasePath(basePath string, h http.Handler) http.Handler {
+	if basePath == "" {
+		return h
+	}
+	stripped := http.StripPrefix(basePath, h)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != basePath && !strings.HasPrefix(r.URL.Path, basePath+"/") {
+			http.NotFound(w, r)
+			return
+		}
+		stripped.ServeHTTP(w, r)
+	})
 }
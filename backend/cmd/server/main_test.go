@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/config"
+	"github.com/andres10976/SISAP-PoC/backend/internal/middleware"
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/monitor"
+)
+
+func TestRun_UsageErrors(t *testing.T) {
+	prev := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+		msg  string
+	}{
+		{"unknown command", []string{"frobnicate"}, exitUsage, `unknown command "frobnicate"`},
+		{"help", []string{"help"}, exitOK, "usage: server"},
+		{"flag help", []string{"migrate", "-h"}, exitOK, "Usage of migrate"},
+		{"serve extra argument", []string{"serve", "now"}, exitUsage, `unexpected argument "now"`},
+		{"migrate unknown flag", []string{"migrate", "-force"}, exitUsage, "flag provided but not defined"},
+		{"backfill without range", []string{"backfill"}, exitUsage, "-start and -end are required"},
+		{"verify reversed range", []string{"verify", "-start", "9", "-end", "1"}, exitUsage, "-end must not be before -start"},
+		{"keywords without action", []string{"keywords"}, exitUsage, "missing action"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stderr bytes.Buffer
+			if got := run(tt.args, io.Discard, &stderr); got != tt.want {
+				t.Errorf("run(%q) = %d, want %d", tt.args, got, tt.want)
+			}
+			if !strings.Contains(stderr.String(), tt.msg) {
+				t.Errorf("stderr = %q, want it to mention %q", stderr.String(), tt.msg)
+			}
+		})
+	}
+}
+
+func TestRun_InvalidConfigFails(t *testing.T) {
+	prev := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	t.Setenv("DATABASE_URL", "")
+	t.Setenv("DATABASE_URL_FILE", "")
+
+	var stderr bytes.Buffer
+	if got := run([]string{"migrate"}, io.Discard, &stderr); got != exitFailure {
+		t.Errorf("run(migrate) = %d, want %d", got, exitFailure)
+	}
+	// Subcommands other than serve log to stderr.
+	if !strings.Contains(stderr.String(), "invalid configuration") {
+		t.Errorf("stderr = %q, want the configuration error", stderr.String())
+	}
+}
+
+type fakeDisabler struct {
+	err error
+}
+
+func (d fakeDisabler) Disable(ctx context.Context, id int, reason string) (*model.Keyword, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	return &model.Keyword{ID: id, DisabledReason: reason}, nil
+}
+
+func TestAuditedDisabler(t *testing.T) {
+	log := &shutdownLog{}
+	d := auditedDisabler{keywords: fakeDisabler{}, audit: &fakeRecorder{log: log}}
+	if _, err := d.Disable(context.Background(), 3, "too many matches"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"audit.disable.keyword.system"}; !slices.Equal(log.steps, want) {
+		t.Errorf("audit = %v, want %v", log.steps, want)
+	}
+
+	log.steps = nil
+	d.keywords = fakeDisabler{err: errors.New("db down")}
+	if _, err := d.Disable(context.Background(), 3, "too many matches"); err == nil {
+		t.Error("Disable() = nil, want the store's error")
+	}
+	if len(log.steps) != 0 {
+		t.Errorf("audit = %v, want nothing recorded for a failed disable", log.steps)
+	}
+}
+
+func TestLogDialer(t *testing.T) {
+	dial := logDialer(&config.Config{
+		CTLogURL:          "https://oak.ct.letsencrypt.org/2026h1",
+		CTLogSuccessorURL: "https://willow.ct.letsencrypt.org/2026h2",
+	}, []string{"ct.example.com:8443"})
+
+	for _, url := range []string{
+		"https://oak.ct.letsencrypt.org/2026h2/",
+		"willow.ct.letsencrypt.org/2026h2/ct/v1/get-sth",
+		"https://ct.example.com:8443/log",
+	} {
+		if client, err := dial(url); err != nil || client == nil {
+			t.Errorf("dial(%q) = %v, %v; want a client", url, client, err)
+		}
+	}
+	if _, err := dial("https://ct.example.com/log"); !errors.Is(err, monitor.ErrLogNotAllowed) {
+		t.Errorf("dial(other port) error = %v, want ErrLogNotAllowed", err)
+	}
+	if _, err := dial("ftp://oak.ct.letsencrypt.org"); !errors.Is(err, monitor.ErrInvalidLogURL) {
+		t.Errorf("dial(ftp) error = %v, want ErrInvalidLogURL", err)
+	}
+}
+
+func TestAuditedLogSwitch(t *testing.T) {
+	log := &shutdownLog{}
+	auditedLogSwitch{audit: &fakeRecorder{log: log}}.RecordLogSwitch(context.Background(),
+		monitor.LogSwitch{From: "https://a", To: "https://b", StartIndex: 9, Reason: monitor.LogSwitchFrozen})
+	if want := []string{"audit.switch_log.monitor.system"}; !slices.Equal(log.steps, want) {
+		t.Errorf("audit = %v, want %v", log.steps, want)
+	}
+}
+
+func TestWithBasePath(t *testing.T) {
+	r := chi.NewRouter()
+	r.Get("/healthz", func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "healthz") })
+	r.Get("/metrics", func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "metrics") })
+	r.Route("/api/v1", func(r chi.Router) {
+		r.Use(middleware.Timeout(50*time.Millisecond, "/api/v1/certificates/stream"))
+		r.Get("/keywords", func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "keywords") })
+		r.Get("/certificates/stream", func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(100 * time.Millisecond)
+			io.WriteString(w, "stream")
+		})
+	})
+	r.Get("/*", func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "frontend") })
+
+	get := func(h http.Handler, target string) (int, string) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec.Code, rec.Body.String()
+	}
+
+	if code, body := get(withBasePath("", r), "/api/v1/keywords"); code != http.StatusOK || body != "keywords" {
+		t.Errorf("no base path: GET /api/v1/keywords = %d %q, want the route", code, body)
+	}
+
+	h := withBasePath("/sisap", r)
+	for target, want := range map[string]string{
+		"/sisap/healthz":                    "healthz",
+		"/sisap/metrics":                    "metrics",
+		"/sisap/api/v1/keywords":            "keywords",
+		"/sisap/api/v1/certificates/stream": "stream",
+		"/sisap":                            "frontend",
+		"/sisap/keywords/7":                 "frontend",
+	} {
+		if code, body := get(h, target); code != http.StatusOK || body != want {
+			t.Errorf("GET %s = %d %q, want 200 %q", target, code, body, want)
+		}
+	}
+	for _, target := range []string{"/healthz", "/api/v1/keywords", "/sisapx/healthz", "/"} {
+		if code, _ := get(h, target); code != http.StatusNotFound {
+			t.Errorf("GET %s = %d, want 404 outside the base path", target, code)
+		}
+	}
+}
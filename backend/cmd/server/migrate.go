@@ -0,0 +1,32 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/config"
+)
+
+func parseMigrate(args []string, stderr io.Writer) (func(*config.Config) int, error) {
+	fs := newFlagSet("migrate", stderr)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if err := noArgs(fs); err != nil {
+		return nil, err
+	}
+	return migrate, nil
+}
+
+// migrate applies the migrations and exits, e.g. from a deploy job that
+// runs before the new servers start.
+func migrate(cfg *config.Config) int {
+	pool, err := openDatabase(cfg)
+	if err != nil {
+		slog.Error("migration failed", "error", err)
+		return exitFailure
+	}
+	pool.Close()
+	slog.Info("migrations applied")
+	return exitOK
+}
@@ -0,0 +1,504 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/config"
+	"github.com/andres10976/SISAP-PoC/backend/internal/database"
+	"github.com/andres10976/SISAP-PoC/backend/internal/handler"
+	"github.com/andres10976/SISAP-PoC/backend/internal/metrics"
+	"github.com/andres10976/SISAP-PoC/backend/internal/middleware"
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/repository"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/audit"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/broadcast"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/ctlog"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/domain"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/enrich"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/history"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/monitor"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/notify"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/report"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/seed"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/webhook"
+	"github.com/andres10976/SISAP-PoC/backend/internal/tlsserver"
+	"github.com/andres10976/SISAP-PoC/backend/internal/tracing"
+	"github.com/andres10976/SISAP-PoC/backend/internal/version"
+)
+
+// serve runs the API server and the monitor until SIGINT/SIGTERM (or the
+// monitor's cycle limit), then shuts down gracefully.
+func serve(cfg *config.Config) int {
+	trustedProxies, err := middleware.ParseCIDRs(cfg.TrustedProxies)
+	if err != nil {
+		slog.Error("invalid TRUSTED_PROXIES", "error", err)
+		return exitFailure
+	}
+	adminAllowCIDRs, err := middleware.ParseCIDRs(cfg.AdminAllowCIDRs)
+	if err != nil {
+		slog.Error("invalid ADMIN_ALLOW_CIDRS", "error", err)
+		return exitFailure
+	}
+	allowedLogHosts, err := ctlog.ParseAllowedHosts(cfg.AllowedCTLogURLs)
+	if err != nil {
+		slog.Error("invalid ALLOWED_CT_LOG_URLS", "error", err)
+		return exitFailure
+	}
+
+	// Load the certificate before anything else so a bad or expired one
+	// fails the start immediately.
+	var certs *tlsserver.Reloader
+	if cfg.TLSEnabled() {
+		certs, err = tlsserver.NewReloader(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			slog.Error("failed to load TLS certificate", "error", err)
+			return exitFailure
+		}
+		slog.Info("TLS enabled", "cert_file", cfg.TLSCertFile, "not_after", certs.NotAfter())
+	}
+
+	// Tracing, before anything that creates spans
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.OTLPEndpoint, version.Version)
+	if err != nil {
+		slog.Error("failed to set up tracing", "error", err)
+		return exitFailure
+	}
+	if cfg.OTLPEndpoint != "" {
+		slog.Info("tracing enabled", "endpoint", cfg.OTLPEndpoint)
+	}
+
+	// Database
+	pool, err := openDatabase(cfg)
+	if err != nil {
+		slog.Error("database setup failed", "error", err)
+		return exitFailure
+	}
+	defer pool.Close()
+
+	// Repositories
+	keywordRepo := repository.NewKeywordRepository(pool)
+	certRepo, err := certificateRepository(pool, cfg)
+	if err != nil {
+		slog.Error("invalid configuration", "error", err)
+		return exitFailure
+	}
+	monitorRepo := repository.NewMonitorRepository(pool)
+	auditRepo := repository.NewAuditRepository(pool)
+	outboxRepo := repository.NewOutboxRepository(pool)
+	webhookRepo := repository.NewWebhookRepository(pool)
+	auditRecorder := audit.NewRecorder(auditRepo)
+	reportRepo := repository.NewReportRepository(pool)
+
+	// Seed keywords from SEED_KEYWORDS and SEED_KEYWORDS_FILE; existing
+	// keywords are skipped so this is safe on every start.
+	if cfg.SeedKeywordsFile != "" {
+		data, err := os.ReadFile(cfg.SeedKeywordsFile)
+		if err != nil {
+			slog.Error("failed to read seed keywords file", "path", cfg.SeedKeywordsFile, "error", err)
+			return exitFailure
+		}
+		cfg.SeedKeywords += "\n" + string(data)
+	}
+	if values := seed.Parse(cfg.SeedKeywords); len(values) > 0 {
+		n, err := seed.Keywords(context.Background(), keywordRepo, values)
+		if err != nil {
+			slog.Error("failed to seed keywords", "error", err)
+			return exitFailure
+		}
+		slog.Info("seeded keywords", "added", n, "configured", len(values))
+	}
+
+	// Reset stale monitor state from previous process crash
+	if err := monitorRepo.SetRunning(context.Background(), false); err != nil {
+		slog.Error("failed to reset monitor state", "error", err)
+		return exitFailure
+	}
+
+	// Metrics
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	appMetrics := metrics.New(reg)
+	metrics.RegisterPool(reg, pool.Stat)
+
+	// Resume on the log the monitor last switched to, unless CT_LOG_URL has
+	// been changed since: then the configured log wins.
+	state, err := monitorRepo.Get(context.Background())
+	if err != nil {
+		slog.Error("failed to read monitor state", "error", err)
+		return exitFailure
+	}
+	logURL := cfg.CTLogURL
+	if state.CTLogURL != "" && state.CTLogConfiguredURL == cfg.CTLogURL {
+		logURL = state.CTLogURL
+		slog.Info("resuming on the CT log the monitor switched to", "ct_log_url", logURL, "configured", cfg.CTLogURL)
+	}
+
+	// Services
+	matchStream := broadcast.NewBroadcaster(cfg.StreamSubscriberBuffer)
+	ctClient := ctlog.NewClient(logURL,
+		ctlog.WithMaxResponseBytes(cfg.CTLogMaxResponseBytes),
+		ctlog.WithSTHCacheTTL(cfg.CTLogSTHCacheTTL))
+	monitorOpts := []monitor.Option{
+		monitor.WithStartJitter(cfg.MonitorStartJitter),
+		monitor.WithMetrics(appMetrics),
+		monitor.WithPublisher(matchStream),
+		monitor.WithMaxMatchesPerCert(cfg.MonitorMaxMatchesPerCert),
+		monitor.WithMaxCycles(cfg.MonitorMaxCycles),
+		monitor.WithServerAuthOnly(cfg.MonitorServerAuthOnly),
+		monitor.WithIgnoreCN(cfg.MatchIgnoreCN),
+		monitor.WithMinNotBefore(cfg.MonitorMinNotBefore),
+		monitor.WithHeadLag(int64(cfg.MonitorHeadLag)),
+		monitor.WithLogMatches(cfg.LogMatches),
+		monitor.WithLogs(cfg.CTLogURL, logURL, logDialer(cfg, allowedLogHosts)),
+		monitor.WithRollover(cfg.CTLogIntervalEnd, cfg.CTLogFrozenAfter, cfg.CTLogSuccessorURL,
+			auditedLogSwitch{audit: auditRecorder}),
+	}
+	monitorOpts = append(monitorOpts, chainVerifier(context.Background(), cfg, ctClient)...)
+	if cfg.KeywordMaxMatchPercent > 0 {
+		monitorOpts = append(monitorOpts, monitor.WithRunawayKeywords(
+			auditedDisabler{keywords: keywordRepo, audit: auditRecorder},
+			cfg.KeywordMaxMatchPercent, cfg.KeywordMaxMatchCycles))
+	}
+
+	// Enrichment only runs when enabled, so by default the server makes no
+	// calls beyond the CT log. Each source has its own queue, so a slow or
+	// rate-limited one does not hold up the other.
+	var enrichers []func(context.Context)
+	if cfg.CrtShEnabled {
+		e := enrich.NewHistory(enrich.NewCrtSh(cfg.CrtShURL, cfg.CrtShTimeout), certRepo,
+			enrich.WithMinInterval(cfg.CrtShMinInterval),
+			enrich.WithCacheTTL(cfg.CrtShCacheTTL),
+		)
+		monitorOpts = append(monitorOpts, monitor.WithPublisher(e))
+		metrics.RegisterEnrichment(reg, e.Name(), e.Dropped)
+		enrichers = append(enrichers, e.Run)
+	}
+	if cfg.RDAPEnabled {
+		e := enrich.NewDomainAge(enrich.NewRDAP(cfg.RDAPURL, cfg.RDAPTimeout), certRepo,
+			enrich.WithMinInterval(cfg.RDAPMinInterval),
+			enrich.WithCacheTTL(cfg.RDAPCacheTTL),
+		)
+		monitorOpts = append(monitorOpts, monitor.WithPublisher(e))
+		metrics.RegisterEnrichment(reg, e.Name(), e.Dropped)
+		enrichers = append(enrichers, e.Run)
+	}
+	mon := monitor.New(ctClient, keywordRepo, certRepo, monitorRepo, cfg.MonitorBatchSize, cfg.MonitorInterval, cfg.MonitorReprocessOnIdle,
+		monitorOpts...)
+
+	// Matches always fan out to the webhooks configured through the API;
+	// NOTIFY_WEBHOOK_URL adds the unsigned legacy webhook.
+	notifiers := []notify.Notifier{webhook.NewFanout(webhookRepo)}
+	if cfg.NotifyWebhookURL != "" {
+		notifiers = append(notifiers, notify.NewWebhookNotifier(cfg.NotifyWebhookURL))
+	}
+	dispatcher := notify.NewDispatcher(outboxRepo, notifiers, cfg.NotifyPollInterval,
+		notify.WithMaxAttempts(cfg.NotifyMaxAttempts),
+		notify.WithRetention(cfg.NotifyOutboxRetention),
+		notify.WithWorkers(cfg.NotifyWorkers),
+		notify.WithBatchSize(cfg.NotifyBatchSize),
+		notify.WithQueue(cfg.NotifyQueueSize, cfg.NotifyQueuePolicy),
+	)
+	metrics.RegisterNotifyQueue(reg, dispatcher.QueueDepth, dispatcher.Dropped, dispatcher.Digested)
+	webhookWorker := webhook.NewWorker(webhookRepo, cfg.NotifyPollInterval,
+		webhook.WithWorkers(cfg.NotifyWorkers),
+		webhook.WithBatchSize(cfg.NotifyBatchSize),
+		webhook.WithQueue(cfg.NotifyQueueSize, cfg.NotifyQueuePolicy),
+		webhook.WithRetention(cfg.NotifyOutboxRetention),
+	)
+	reportOpts := []report.Option{
+		report.WithHour(cfg.ReportHour),
+		report.WithTopN(cfg.ReportTopN),
+		report.WithMinRisk(cfg.ReportMinRisk),
+	}
+	if cfg.ReportSlackWebhookURL != "" {
+		reportOpts = append(reportOpts, report.WithSender(report.NewSlackSender(cfg.ReportSlackWebhookURL)))
+	}
+	reports := report.New(certRepo, monitorRepo, reportRepo, cfg.ReportLocation, reportOpts...)
+
+	// Like enrichment, the crt.sh history import makes no external call
+	// unless enabled; its routes answer 503 otherwise.
+	var importer *history.Importer
+	if cfg.CrtShImportEnabled {
+		importer = history.New(enrich.NewCrtSh(cfg.CrtShURL, cfg.CrtShImportTimeout), keywordRepo, certRepo,
+			history.WithMaxRows(cfg.CrtShImportMaxRows),
+			history.WithMinInterval(cfg.CrtShMinInterval),
+			history.WithIgnoreCN(cfg.MatchIgnoreCN),
+		)
+	}
+
+	// Handlers
+	kwHandler := handler.NewKeywordHandler(keywordRepo, auditRecorder)
+	groupHandler := handler.NewKeywordGroupHandler(repository.NewKeywordGroupRepository(pool), auditRecorder)
+	certHandler := handler.NewCertificateHandler(certRepo, auditRecorder)
+	monHandler := handler.NewMonitorHandler(mon, monitorRepo, auditRecorder).WithNotifyQueue(dispatcher.Stats)
+	auditHandler := handler.NewAuditHandler(auditRepo)
+	webhookHandler := handler.NewWebhookHandler(webhookRepo, auditRecorder)
+	statsHandler := handler.NewStatsHandler(certRepo, model.StatsLimits{TopN: cfg.StatsTopN, Days: cfg.StatsDays})
+	streamHandler := handler.NewStreamHandler(matchStream)
+	wsHandler := handler.NewWSHandler(matchStream, cfg.WSMaxConnections)
+	analyzeHandler := handler.NewAnalyzeHandler(ctClient, keywordRepo, cfg.AnalyzeMaxCount)
+	ctlogHandler := handler.NewCTLogHandler(cfg.CTLogURL, allowedLogHosts, 5*time.Second)
+	logsHandler := handler.NewLogsHandler(logRing)
+	configHandler := handler.NewConfigHandler(cfg)
+	poolHandler := handler.NewPoolHandler(func() model.PoolStats { return database.PoolStats(pool) })
+	versionHandler := handler.NewVersionHandler(version.Info)
+	var cycled func() bool
+	if cfg.ReadyzRequireCycle {
+		cycled = mon.HasCompletedCycle
+	}
+	readyHandler := handler.NewReadyHandler(pool.Ping, cycled)
+	reportHandler := handler.NewReportHandler(reportRepo, reports, auditRecorder)
+	historyHandler := handler.NewHistoryHandler(nil, auditRecorder)
+	if importer != nil {
+		historyHandler = handler.NewHistoryHandler(importer, auditRecorder)
+	}
+
+	var frontend *handler.FrontendHandler
+	if cfg.FrontendDir != "" {
+		frontendFS := os.DirFS(cfg.FrontendDir)
+		if _, err := fs.Stat(frontendFS, "index.html"); err != nil {
+			slog.Error("FRONTEND_DIR has no index.html", "path", cfg.FrontendDir, "error", err)
+			return exitFailure
+		}
+		frontend = handler.NewFrontendHandler(frontendFS)
+	}
+
+	// Router
+	var inFlight middleware.InFlight
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(middleware.ClientIP(trustedProxies))
+	r.Use(middleware.Tracing)
+	r.Use(inFlight.Handler)
+	r.Use(appMetrics.Middleware)
+	// Not needed when the UI is served from this origin (FRONTEND_DIR).
+	if cfg.CORSAllowOrigin != "" {
+		r.Use(middleware.CORS(cfg.CORSAllowOrigin, cfg.CORSAllowCredentials))
+	}
+	r.Use(middleware.SlogLogger(slog.Default(), cfg.HTTPLogSuccessLevel))
+	r.Use(middleware.RecoveryWithHook(appMetrics.IncPanics))
+
+	// Outside /api/v1 so API-only middleware (auth, rate limits) never applies.
+	r.Handle("/metrics", metrics.Handler(reg))
+	versionHandler.RegisterHealthRoutes(r)
+	readyHandler.RegisterRoutes(r)
+
+	// pprof and runtime stats, outside /api/v1 so profiles are not cut off
+	// by REQUEST_TIMEOUT, and only from ADMIN_ALLOW_CIDRS.
+	if cfg.DebugEndpoints {
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.AllowCIDRs(adminAllowCIDRs))
+			handler.NewDebugHandler().RegisterRoutes(r)
+		})
+		slog.Warn("debug endpoints enabled", "path", cfg.BasePath+"/debug/")
+	}
+
+	r.Route("/api/v1", func(r chi.Router) {
+		// Streaming endpoints run until the client or the server stops them.
+		r.Use(middleware.Timeout(cfg.RequestTimeout,
+			"/api/v1/certificates/export",
+			"/api/v1/certificates/stream",
+			"/api/v1/ws",
+		))
+		r.Use(middleware.BodyLimit(cfg.MaxBodyBytes, map[string]int64{
+			"/api/v1/keywords/import": cfg.ImportMaxBodyBytes,
+		}))
+		r.Use(middleware.ContentType([]string{"application/json"}, map[string][]string{
+			"/api/v1/keywords/import": {"application/json", "text/csv", "multipart/form-data"},
+		}))
+		kwHandler.RegisterRoutes(r)
+		groupHandler.RegisterRoutes(r)
+		certHandler.RegisterRoutes(r)
+		monHandler.RegisterRoutes(r)
+		auditHandler.RegisterRoutes(r)
+		statsHandler.RegisterRoutes(r)
+		streamHandler.RegisterRoutes(r)
+		wsHandler.RegisterRoutes(r)
+		analyzeHandler.RegisterRoutes(r)
+		poolHandler.RegisterRoutes(r)
+		versionHandler.RegisterRoutes(r)
+		reportHandler.RegisterRoutes(r)
+
+		// Destructive endpoints are only reachable from ADMIN_ALLOW_CIDRS.
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.AllowCIDRs(adminAllowCIDRs))
+			kwHandler.RegisterAdminRoutes(r)
+			groupHandler.RegisterAdminRoutes(r)
+			certHandler.RegisterAdminRoutes(r)
+			monHandler.RegisterAdminRoutes(r)
+			logsHandler.RegisterAdminRoutes(r)
+			configHandler.RegisterAdminRoutes(r)
+			ctlogHandler.RegisterAdminRoutes(r)
+			webhookHandler.RegisterAdminRoutes(r)
+			reportHandler.RegisterAdminRoutes(r)
+			historyHandler.RegisterAdminRoutes(r)
+		})
+	})
+
+	if frontend != nil {
+		frontend.RegisterRoutes(r)
+	}
+
+	// Server with graceful shutdown
+	srv := &http.Server{
+		Addr:        fmt.Sprintf(":%s", cfg.ServerPort),
+		Handler:     withBasePath(cfg.BasePath, r),
+		ReadTimeout: 15 * time.Second,
+		// No WriteTimeout: it would cut off streaming responses. API
+		// handlers are bounded by middleware.Timeout instead.
+		IdleTimeout: 60 * time.Second,
+	}
+	// Shutdown waits for active requests; end the open event streams and
+	// WebSockets, which it does not track once hijacked.
+	srv.RegisterOnShutdown(matchStream.Close)
+
+	var redirectSrv *http.Server
+	if certs != nil {
+		srv.TLSConfig = certs.TLSConfig()
+		if cfg.TLSRedirectPort != "" {
+			redirectSrv = &http.Server{
+				Addr:        fmt.Sprintf(":%s", cfg.TLSRedirectPort),
+				Handler:     tlsserver.RedirectHandler(cfg.ServerPort),
+				ReadTimeout: 15 * time.Second,
+				IdleTimeout: 60 * time.Second,
+			}
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Fill in registrable domains for rows stored before the column existed
+	go func() {
+		if err := domain.Backfill(ctx, certRepo); err != nil && ctx.Err() == nil {
+			slog.Error("registrable domain backfill failed", "error", err)
+		}
+	}()
+
+	// The dispatcher, webhook worker, enrichers and report schedule get their
+	// own context so they can be stopped after the server has drained, and
+	// waited on so claimed deliveries finish.
+	dispatchCtx, stopDispatch := context.WithCancel(context.Background())
+	defer stopDispatch()
+	var dispatchers sync.WaitGroup
+	dispatchers.Go(func() { dispatcher.Run(dispatchCtx) })
+	dispatchers.Go(func() { webhookWorker.Run(dispatchCtx) })
+	for _, run := range enrichers {
+		dispatchers.Go(func() { run(dispatchCtx) })
+	}
+	if cfg.ReportEnabled {
+		dispatchers.Go(func() { reports.Run(dispatchCtx) })
+	}
+	dispatchDone := make(chan struct{})
+	go func() {
+		dispatchers.Wait()
+		close(dispatchDone)
+	}()
+
+	if certs != nil {
+		// Pick up renewals written over the files, or on SIGHUP.
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go certs.Watch(ctx, cfg.TLSReloadInterval, hup)
+	}
+
+	go func() {
+		slog.Info("server starting", "port", cfg.ServerPort, "base_path", cfg.BasePath, "tls", certs != nil,
+			"version", version.Version, "commit", version.Commit, "build_date", version.BuildDate)
+		var err error
+		if certs != nil {
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			slog.Error("server error", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	if redirectSrv != nil {
+		go func() {
+			slog.Info("HTTPS redirect listener starting", "port", cfg.TLSRedirectPort)
+			if err := redirectSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				slog.Error("redirect server error", "error", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
+	// With a cycle limit nobody is expected to call /monitor/start: run the
+	// batches straight away and exit once they are done.
+	if cfg.MonitorMaxCycles > 0 {
+		if err := mon.Start(ctx); err != nil {
+			slog.Error("failed to start monitor", "error", err)
+			return exitFailure
+		}
+		slog.Info("monitor started", "max_cycles", cfg.MonitorMaxCycles)
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-mon.Done():
+		slog.Info("monitor finished its cycles", "max_cycles", cfg.MonitorMaxCycles)
+	}
+	slog.Info("shutting down", "in_flight", inFlight.Count(), "timeout", cfg.ShutdownTimeout)
+
+	// Exit non-zero on an unclean shutdown so deploy tooling can tell.
+	exitCode := exitOK
+
+	// Let the monitor's current batch finish, then give in-flight requests
+	// time to complete, all within ShutdownTimeout.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	servers := []server{srv}
+	if redirectSrv != nil {
+		servers = append(servers, redirectSrv)
+	}
+	if err := shutdown(shutdownCtx, mon, auditRecorder, servers...); err != nil {
+		slog.Error("shutdown incomplete", "error", err, "in_flight", inFlight.Count())
+		exitCode = exitFailure
+	}
+
+	// Stop running imports; the matches they stored so far are kept.
+	if importer != nil {
+		importer.Close()
+	}
+
+	// Let in-flight notification deliveries finish; undelivered rows stay
+	// in the outbox and webhook_deliveries for the next start.
+	stopDispatch()
+	select {
+	case <-dispatchDone:
+	case <-shutdownCtx.Done():
+		slog.Error("notification dispatchers did not stop in time")
+		exitCode = exitFailure
+	}
+
+	pool.Close()
+	// Flush spans buffered by the exporter, including the shutdown's own.
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("trace exporter shutdown incomplete", "error", err)
+		exitCode = exitFailure
+	}
+	if exitCode == exitOK {
+		slog.Info("shutdown complete")
+	}
+	return exitCode
+}
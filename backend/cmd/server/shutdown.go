@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/audit"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/monitor"
+)
+
+type monitorStopper interface {
+	IsRunning() bool
+	Stop(ctx context.Context) error
+}
+
+type auditRecorder interface {
+	Record(ctx context.Context, action, entityType, entityID string, changes map[string]model.AuditChange)
+}
+
+type server interface {
+	Shutdown(ctx context.Context) error
+}
+
+// shutdown stops the monitor if it is running, letting its current batch
+// finish and persist its progress, records the stop in the audit trail, and
+// then drains the HTTP servers. Every step shares ctx's deadline. All
+// failures are returned; each step runs regardless of earlier ones.
+func shutdown(ctx context.Context, mon monitorStopper, rec auditRecorder, servers ...server) error {
+	var errs []error
+	if mon.IsRunning() {
+		if err := mon.Stop(ctx); err != nil && !errors.Is(err, monitor.ErrNotRunning) {
+			errs = append(errs, fmt.Errorf("stop monitor: %w", err))
+		}
+		rec.Record(audit.WithActor(ctx, audit.System), model.AuditActionStop, model.AuditEntityMonitor, "", nil)
+	}
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("shut down server: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
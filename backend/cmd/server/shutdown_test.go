@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/audit"
+)
+
+// shutdownLog collects the steps the fakes see, in order.
+type shutdownLog struct {
+	steps []string
+}
+
+type fakeMonitor struct {
+	log     *shutdownLog
+	running bool
+	stopErr error
+	stopCtx context.Context
+}
+
+func (m *fakeMonitor) IsRunning() bool { return m.running }
+
+func (m *fakeMonitor) Stop(ctx context.Context) error {
+	m.log.steps = append(m.log.steps, "monitor.stop")
+	m.stopCtx = ctx
+	m.running = false
+	return m.stopErr
+}
+
+type fakeRecorder struct {
+	log *shutdownLog
+}
+
+func (r *fakeRecorder) Record(ctx context.Context, action, entityType, _ string, _ map[string]model.AuditChange) {
+	r.log.steps = append(r.log.steps, "audit."+action+"."+entityType+"."+audit.ActorFromContext(ctx))
+}
+
+type fakeServer struct {
+	log  *shutdownLog
+	name string
+	err  error
+}
+
+func (s *fakeServer) Shutdown(context.Context) error {
+	s.log.steps = append(s.log.steps, s.name+".shutdown")
+	return s.err
+}
+
+func TestShutdown_StopsMonitorBeforeServers(t *testing.T) {
+	log := &shutdownLog{}
+	mon := &fakeMonitor{log: log, running: true}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	err := shutdown(ctx, mon, &fakeRecorder{log: log},
+		&fakeServer{log: log, name: "https"}, &fakeServer{log: log, name: "redirect"})
+	if err != nil {
+		t.Fatalf("shutdown() = %v, want nil", err)
+	}
+	want := []string{"monitor.stop", "audit.stop.monitor.system", "https.shutdown", "redirect.shutdown"}
+	if !slices.Equal(log.steps, want) {
+		t.Errorf("steps = %v, want %v", log.steps, want)
+	}
+	if _, ok := mon.stopCtx.Deadline(); !ok {
+		t.Error("monitor Stop got no deadline, want the shutdown deadline")
+	}
+}
+
+func TestShutdown_SkipsIdleMonitor(t *testing.T) {
+	log := &shutdownLog{}
+
+	err := shutdown(context.Background(), &fakeMonitor{log: log}, &fakeRecorder{log: log},
+		&fakeServer{log: log, name: "https"})
+	if err != nil {
+		t.Fatalf("shutdown() = %v, want nil", err)
+	}
+	if want := []string{"https.shutdown"}; !slices.Equal(log.steps, want) {
+		t.Errorf("steps = %v, want %v", log.steps, want)
+	}
+}
+
+func TestShutdown_ReportsFailuresAndKeepsGoing(t *testing.T) {
+	log := &shutdownLog{}
+	mon := &fakeMonitor{log: log, running: true, stopErr: context.DeadlineExceeded}
+
+	err := shutdown(context.Background(), mon, &fakeRecorder{log: log},
+		&fakeServer{log: log, name: "https", err: errors.New("connections still open")},
+		&fakeServer{log: log, name: "redirect"})
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "connections still open") {
+		t.Errorf("shutdown() = %v, want both failures", err)
+	}
+	want := []string{"monitor.stop", "audit.stop.monitor.system", "https.shutdown", "redirect.shutdown"}
+	if !slices.Equal(log.steps, want) {
+		t.Errorf("steps = %v, want %v", log.steps, want)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/config"
+	"github.com/andres10976/SISAP-PoC/backend/internal/repository"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/ctlog"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/monitor"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/verify"
+)
+
+type verifyOptions struct {
+	backfillRange
+	fix bool
+}
+
+func parseVerify(args []string, stderr io.Writer) (func(*config.Config) int, error) {
+	o, err := parseVerifyOptions(args, stderr)
+	if err != nil {
+		return nil, err
+	}
+	return func(cfg *config.Config) int { return runVerify(cfg, o, os.Stdout, stderr) }, nil
+}
+
+func parseVerifyOptions(args []string, stderr io.Writer) (verifyOptions, error) {
+	fs := newFlagSet("verify", stderr)
+	rangeOf := rangeFlags(fs)
+	fix := fs.Bool("fix", false, "insert the missing matches")
+	if err := fs.Parse(args); err != nil {
+		return verifyOptions{}, err
+	}
+	if err := noArgs(fs); err != nil {
+		return verifyOptions{}, err
+	}
+	r, err := rangeOf()
+	if err != nil {
+		return verifyOptions{}, err
+	}
+	return verifyOptions{backfillRange: r, fix: *fix}, nil
+}
+
+// runVerify rescans entries o.start..o.end of CT_LOG_URL with the current
+// keywords, through the same pipeline as backfill but without storing, and
+// diffs the matches against matched_certificates. The JSON report goes to
+// stdout and a summary to stderr. With -fix the missing matches are
+// inserted (and notified) as backfill would. It exits with exitFailure
+// when the range could not be verified or differences remain.
+func runVerify(cfg *config.Config, o verifyOptions, stdout, stderr io.Writer) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	pool, err := openDatabase(cfg)
+	if err != nil {
+		slog.Error("database setup failed", "error", err)
+		return exitFailure
+	}
+	defer pool.Close()
+
+	collector := &verify.Collector{}
+	client := ctlog.NewClient(cfg.CTLogURL,
+		ctlog.WithMaxResponseBytes(cfg.CTLogMaxResponseBytes),
+		ctlog.WithSTHCacheTTL(cfg.CTLogSTHCacheTTL))
+	mon := monitor.New(client, repository.NewKeywordRepository(pool), collector,
+		repository.NewMonitorRepository(pool), cfg.MonitorBatchSize, cfg.MonitorInterval, false,
+		monitor.WithLogs(cfg.CTLogURL, cfg.CTLogURL, nil),
+		monitor.WithMaxMatchesPerCert(cfg.MonitorMaxMatchesPerCert),
+		monitor.WithServerAuthOnly(cfg.MonitorServerAuthOnly),
+		monitor.WithIgnoreCN(cfg.MatchIgnoreCN),
+		monitor.WithMinNotBefore(cfg.MonitorMinNotBefore),
+	)
+
+	slog.Info("verify starting", "start", o.start, "end", o.end, "ct_log_url", cfg.CTLogURL)
+	if _, err := mon.Backfill(ctx, o.start, o.end); err != nil {
+		slog.Error("verify rescan failed", "error", err)
+		return exitFailure
+	}
+	expected := collector.Matches()
+
+	certRepo, err := certificateRepository(pool, cfg)
+	if err != nil {
+		slog.Error("invalid configuration", "error", err)
+		return exitFailure
+	}
+	stored, err := certRepo.ListForRange(ctx, cfg.CTLogURL, o.start, o.end, verify.Serials(expected))
+	if err != nil {
+		slog.Error("failed to load stored matches", "error", err)
+		return exitFailure
+	}
+	report := verify.Compare(cfg.CTLogURL, o.start, o.end, expected, stored)
+
+	if o.fix {
+		for _, c := range report.Missing {
+			if err := certRepo.Create(ctx, &c); err != nil {
+				slog.Error("failed to insert missing match", "error", err,
+					"serial", c.SerialNumber, "keyword_id", c.KeywordID)
+				continue
+			}
+			report.Fixed++
+		}
+	}
+
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		slog.Error("failed to write report", "error", err)
+		return exitFailure
+	}
+	writeVerifySummary(stderr, report)
+
+	if len(report.Extra) > 0 || len(report.Mismatched) > 0 || report.Fixed < len(report.Missing) {
+		return exitFailure
+	}
+	return exitOK
+}
+
+func writeVerifySummary(w io.Writer, r *verify.Report) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Verified entries %d-%d: %d expected matches, %d stored rows compared\n",
+		r.Start, r.End, r.Expected, r.Stored)
+	if r.Clean() {
+		b.WriteString("No differences.\n")
+	}
+	if len(r.Missing) > 0 {
+		fmt.Fprintf(&b, "Missing (%d", len(r.Missing))
+		if r.Fixed > 0 {
+			fmt.Fprintf(&b, ", %d inserted", r.Fixed)
+		}
+		b.WriteString("):\n")
+		for _, c := range r.Missing {
+			fmt.Fprintf(&b, "  #%d %s  keyword %q  serial %s\n", c.CTLogIndex, c.MatchedDomain, c.KeywordValue, c.SerialNumber)
+		}
+	}
+	if len(r.Extra) > 0 {
+		fmt.Fprintf(&b, "Extra (%d):\n", len(r.Extra))
+		for _, c := range r.Extra {
+			fmt.Fprintf(&b, "  id %d  #%d %s  keyword %q\n", c.ID, c.CTLogIndex, c.MatchedDomain, c.KeywordValue)
+		}
+	}
+	if len(r.Mismatched) > 0 {
+		fmt.Fprintf(&b, "Mismatched (%d):\n", len(r.Mismatched))
+		for _, m := range r.Mismatched {
+			fmt.Fprintf(&b, "  id %d  keyword %q  serial %s\n", m.ID, m.KeywordValue, m.SerialNumber)
+			for _, f := range m.Fields {
+				fmt.Fprintf(&b, "    %s: stored %q, expected %q\n", f.Field, f.Stored, f.Expected)
+			}
+		}
+	}
+	io.WriteString(w, b.String())
+}
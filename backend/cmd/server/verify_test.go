@@ -0,0 +1,49 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/verify"
+)
+
+func TestParseVerifyOptions(t *testing.T) {
+	got, err := parseVerifyOptions([]string{"-start", "10", "-end", "19", "-fix"}, io.Discard)
+	if err != nil || got != (verifyOptions{backfillRange{start: 10, end: 19}, true}) {
+		t.Errorf("parseVerifyOptions() = %+v, %v; want 10-19 with -fix", got, err)
+	}
+	for _, args := range [][]string{
+		{"-start", "10"},
+		{"-start", "10", "-end", "19", "extra"},
+		{"-start", "10", "-end", "19", "-fix=maybe"},
+	} {
+		if _, err := parseVerifyOptions(args, io.Discard); err == nil {
+			t.Errorf("parseVerifyOptions(%q) error = nil, want an error", args)
+		}
+	}
+}
+
+func TestWriteVerifySummary(t *testing.T) {
+	r := &verify.Report{
+		Start: 10, End: 19, Expected: 2, Stored: 2,
+		Missing: []model.MatchedCertificate{{SerialNumber: "0a", KeywordValue: "paypal", MatchedDomain: "paypal.example.com", CTLogIndex: 12}},
+		Extra:   []model.MatchedCertificate{{ID: 8, KeywordValue: "paypal", MatchedDomain: "old.example.com", CTLogIndex: 15}},
+		Mismatched: []verify.Mismatch{{ID: 7, SerialNumber: "0b", KeywordValue: "paypal",
+			Fields: []verify.FieldDiff{{Field: "matched_field", Stored: "cn", Expected: "san"}}}},
+		Fixed: 1,
+	}
+	var b strings.Builder
+	writeVerifySummary(&b, r)
+	for _, want := range []string{
+		"Verified entries 10-19: 2 expected matches, 2 stored rows compared",
+		"Missing (1, 1 inserted):\n  #12 paypal.example.com",
+		"Extra (1):\n  id 8  #15 old.example.com",
+		`    matched_field: stored "cn", expected "san"`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("summary is missing %q:\n%s", want, b.String())
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// Package benchcorpus generates the synthetic keywords, certificate names
+// and CT log leaves the matcher and parser benchmarks run over. Output
+// depends only on the arguments: names come from a fixed-seed PCG and
+// certificates are signed with a fixed Ed25519 key (whose signatures are
+// deterministic), so results stay comparable between runs and machines.
+package benchcorpus
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"math/rand/v2"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+// MatchEvery is how often a certificate carries a keyword: one in
+// MatchEvery, in a random one of its names.
+const MatchEvery = 10
+
+// MaxNames bounds the DNS names per certificate (1..MaxNames).
+const MaxNames = 8
+
+// epoch is the NotBefore and log timestamp of certificate 0.
+var epoch = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+var brands = []string{
+	"paypal", "amazon", "apple", "google", "microsoft", "netflix", "chase",
+	"wellsfargo", "coinbase", "binance", "dropbox", "github", "outlook",
+	"instagram", "facebook", "linkedin",
+}
+
+var words = []string{
+	"cloud", "cdn", "mail", "shop", "api", "static", "app", "login", "secure",
+	"portal", "news", "blog", "store", "media", "data", "edge", "auth", "web",
+}
+
+var tlds = []string{"com", "net", "org", "io", "co.uk", "de", "xyz", "app"}
+
+// Keywords returns k keywords of each match mode: IDs 1..k are substring
+// keywords and k+1..2k boundary keywords over the same values.
+func Keywords(k int) []model.Keyword {
+	kws := make([]model.Keyword, 0, 2*k)
+	for _, mode := range []string{model.MatchModeSubstring, model.MatchModeBoundary} {
+		for i := range k {
+			value := brands[i%len(brands)]
+			if i >= len(brands) {
+				value = fmt.Sprintf("%s%d", value, i/len(brands))
+			}
+			kws = append(kws, model.Keyword{ID: len(kws) + 1, Value: value, MatchMode: mode})
+		}
+	}
+	return kws
+}
+
+// Names returns the DNS names of n certificates; the first name of each is
+// also its common name. Every MatchEvery-th certificate embeds one of
+// keywords' values, at a boundary or inside a label.
+func Names(n int, keywords []model.Keyword) [][]string {
+	rng := rand.New(rand.NewPCG(1953, 2))
+	certs := make([][]string, n)
+	for i := range certs {
+		names := make([]string, 1+rng.IntN(MaxNames))
+		for j := range names {
+			names[j] = fmt.Sprintf("%s%d.%s.%s", words[rng.IntN(len(words))], rng.IntN(1000),
+				words[rng.IntN(len(words))], tlds[rng.IntN(len(tlds))])
+		}
+		if i%MatchEvery == 0 && len(keywords) > 0 {
+			kw := keywords[rng.IntN(len(keywords))].Value
+			j := rng.IntN(len(names))
+			if rng.IntN(2) == 0 {
+				names[j] = kw + "-" + names[j]
+			} else {
+				names[j] = "x" + kw + names[j]
+			}
+		}
+		certs[i] = names
+	}
+	return certs
+}
+
+// Leaves returns one x509_entry MerkleTreeLeaf per certificate of Names,
+// as get-entries serves them in leaf_input.
+func Leaves(n int, keywords []model.Keyword) [][]byte {
+	key := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	leaves := make([][]byte, n)
+	for i, names := range Names(n, keywords) {
+		logged := epoch.Add(time.Duration(i) * time.Second)
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(int64(i) + 1),
+			Subject:      pkix.Name{CommonName: names[0]},
+			Issuer:       pkix.Name{CommonName: "Bench CA", Organization: []string{"benchcorpus"}},
+			DNSNames:     names,
+			NotBefore:    logged,
+			NotAfter:     logged.Add(90 * 24 * time.Hour),
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		}
+		der, err := x509.CreateCertificate(nil, tmpl, tmpl, key.Public(), key)
+		if err != nil {
+			panic(fmt.Sprintf("benchcorpus: create certificate %d: %v", i, err))
+		}
+
+		leaf := []byte{0, 0} // version v1, leaf type timestamped_entry
+		leaf = binary.BigEndian.AppendUint64(leaf, uint64(logged.UnixMilli()))
+		leaf = append(leaf, 0, 0) // x509_entry
+		leaf = append(leaf, byte(len(der)>>16), byte(len(der)>>8), byte(len(der)))
+		leaves[i] = append(leaf, der...)
+	}
+	return leaves
+}
@@ -0,0 +1,65 @@
+package benchcorpus
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/ctlog"
+)
+
+func TestKeywords(t *testing.T) {
+	kws := Keywords(20)
+	if len(kws) != 40 {
+		t.Fatalf("len = %d, want 40", len(kws))
+	}
+	if kws[0].MatchMode != model.MatchModeSubstring || kws[20].MatchMode != model.MatchModeBoundary {
+		t.Errorf("modes = %q, %q; want substring then boundary", kws[0].MatchMode, kws[20].MatchMode)
+	}
+	if kws[0].Value != kws[20].Value || kws[16].Value != "paypal1" || kws[39].ID != 40 {
+		t.Errorf("keywords = %+v", kws)
+	}
+}
+
+func TestNames_Deterministic(t *testing.T) {
+	kws := Keywords(4)
+	a, b := Names(200, kws), Names(200, kws)
+	if !slices.EqualFunc(a, b, slices.Equal) {
+		t.Fatal("Names differs between calls")
+	}
+
+	matching := 0
+	for i, names := range a {
+		if len(names) < 1 || len(names) > MaxNames {
+			t.Fatalf("certificate %d has %d names", i, len(names))
+		}
+		if slices.ContainsFunc(names, func(n string) bool {
+			return slices.ContainsFunc(kws, func(kw model.Keyword) bool { return strings.Contains(n, kw.Value) })
+		}) {
+			matching++
+		}
+	}
+	if matching != 200/MatchEvery {
+		t.Errorf("%d certificates carry a keyword, want %d", matching, 200/MatchEvery)
+	}
+}
+
+func TestLeaves_Deterministic(t *testing.T) {
+	a, b := Leaves(5, Keywords(2)), Leaves(5, Keywords(2))
+	if !slices.EqualFunc(a, b, bytes.Equal) {
+		t.Fatal("Leaves differs between calls")
+	}
+
+	names := Names(5, Keywords(2))
+	for i, leaf := range a {
+		cert, err := ctlog.ParseLeafInput(leaf, nil)
+		if err != nil {
+			t.Fatalf("leaf %d: %v", i, err)
+		}
+		if cert.CommonName != names[i][0] || !slices.Equal(cert.SANs, names[i]) {
+			t.Errorf("leaf %d: CN %q, SANs %v; want the names %v", i, cert.CommonName, cert.SANs, names[i])
+		}
+	}
+}
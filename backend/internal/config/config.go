@@ -0,0 +1,666 @@
+// Package config reads the server's settings from the environment.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	// REPORT_TIMEZONE must resolve in the runtime image, which ships no
+	// zoneinfo.
+	_ "time/tzdata"
+)
+
+// Config holds every setting the server reads from the environment. Load
+// fills it in and Validate reports missing or malformed values.
+type Config struct {
+	DatabaseURL string
+	// DBQueryTimeout is the statement_timeout for every pool connection;
+	// zero leaves queries unbounded.
+	DBQueryTimeout time.Duration
+	ServerPort     string
+	// BasePath, when set, serves every route, /metrics and /healthz
+	// included, under this prefix, e.g. "/sisap" behind a reverse proxy
+	// sharing its host. It starts with "/" and has no trailing "/".
+	BasePath string
+
+	// With TLSCertFile and TLSKeyFile set the server speaks HTTPS on
+	// ServerPort and, if TLSRedirectPort is set, redirects plain HTTP there.
+	TLSCertFile       string
+	TLSKeyFile        string
+	TLSRedirectPort   string
+	TLSReloadInterval time.Duration
+
+	CTLogURL                 string
+	CTLogMaxResponseBytes    int64
+	CTLogSTHCacheTTL         time.Duration
+	MonitorInterval          time.Duration
+	MonitorBatchSize         int
+	MonitorReprocessOnIdle   bool
+	MonitorStartJitter       time.Duration
+	MonitorMaxMatchesPerCert int
+	MonitorMaxCycles         int
+	MonitorServerAuthOnly    bool
+	MonitorVerifyChains      bool
+	MonitorHeadLag           int
+	// MonitorMinNotBefore skips certificates issued before it; zero means
+	// no cutoff.
+	MonitorMinNotBefore time.Time
+
+	// CTLogIntervalEnd is the end of CT_LOG_URL's temporal interval; once
+	// it has passed and the tree size has not changed for CTLogFrozenAfter
+	// the log is reported frozen, and the monitor moves on to
+	// CTLogSuccessorURL if set. Zero disables the check.
+	CTLogIntervalEnd  time.Time
+	CTLogFrozenAfter  time.Duration
+	CTLogSuccessorURL string
+	// MatchIgnoreCN matches keywords against SANs only.
+	MatchIgnoreCN bool
+	// KeywordMaxMatchPercent, when positive, disables a keyword that
+	// matched more than that percentage of a cycle's entries for
+	// KeywordMaxMatchCycles cycles in a row.
+	KeywordMaxMatchPercent int
+	KeywordMaxMatchCycles  int
+	// LogMatches emits an Info line per newly stored match for log-based
+	// alerting.
+	LogMatches bool
+	// CertConflictStrategy is "ignore" or "update"; see
+	// repository.ConflictStrategy.
+	CertConflictStrategy string
+	// CertConsolidate sends one notification per certificate however many
+	// keywords match it; see repository.WithConsolidation.
+	CertConsolidate bool
+
+	StreamSubscriberBuffer int
+	WSMaxConnections       int
+	AnalyzeMaxCount        int
+	StatsTopN              int
+	StatsDays              int
+
+	MaxBodyBytes       int64
+	ImportMaxBodyBytes int64
+	RequestTimeout     time.Duration
+	ShutdownTimeout    time.Duration
+
+	SeedKeywords     string
+	SeedKeywordsFile string
+
+	NotifyWebhookURL      string
+	NotifyPollInterval    time.Duration
+	NotifyMaxAttempts     int
+	NotifyOutboxRetention time.Duration
+	NotifyWorkers         int
+	NotifyBatchSize       int
+	NotifyQueueSize       int
+	NotifyQueuePolicy     string
+
+	// Report* configure the daily report: when ReportEnabled is set the
+	// previous day's report, in ReportLocation, is generated at ReportHour
+	// and posted to ReportSlackWebhookURL if set.
+	ReportEnabled         bool
+	ReportHour            int
+	ReportLocation        *time.Location
+	ReportTopN            int
+	ReportMinRisk         int
+	ReportSlackWebhookURL string
+
+	// CrtSh* configure the optional crt.sh enrichment of new matches; no
+	// external call is made unless CrtShEnabled is set.
+	CrtShEnabled     bool
+	CrtShURL         string
+	CrtShMinInterval time.Duration
+	CrtShTimeout     time.Duration
+	CrtShCacheTTL    time.Duration
+
+	// CrtShImport* configure the admin import of a keyword's historical
+	// matches from crt.sh, which shares CrtShURL and CrtShMinInterval; no
+	// import runs unless CrtShImportEnabled is set.
+	CrtShImportEnabled bool
+	CrtShImportMaxRows int
+	CrtShImportTimeout time.Duration
+	// RDAP* configure the optional lookup of each match's domain
+	// registration date; off unless RDAPEnabled is set.
+	RDAPEnabled     bool
+	RDAPURL         string
+	RDAPMinInterval time.Duration
+	RDAPTimeout     time.Duration
+	RDAPCacheTTL    time.Duration
+
+	// RiskWeights overrides the points of risk score factors by name (see
+	// service/risk); factor names and ranges are checked by risk.New.
+	RiskWeights map[string]int
+
+	// HTTPLogSuccessLevel is the level for requests answered below 400.
+	HTTPLogSuccessLevel slog.Level
+	// TrustedProxies and AdminAllowCIDRs are comma-separated CIDR lists,
+	// parsed with middleware.ParseCIDRs.
+	TrustedProxies  string
+	AdminAllowCIDRs string
+	// AllowedCTLogURLs is a comma-separated list of logs, besides CTLogURL,
+	// that /ctlog/check may fetch, parsed with ctlog.ParseAllowedHosts.
+	AllowedCTLogURLs string
+	// DebugEndpoints mounts pprof and runtime stats under /debug/, behind
+	// AdminAllowCIDRs.
+	DebugEndpoints bool
+	// ReadyzRequireCycle keeps /readyz at 503 until the monitor has
+	// completed a batch, not just until the database answers.
+	ReadyzRequireCycle bool
+	// OTLPEndpoint enables trace export. It is read from the standard
+	// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT or OTEL_EXPORTER_OTLP_ENDPOINT
+	// variables, which the exporter itself also honours.
+	OTLPEndpoint string
+	// FrontendDir, when set, is the built frontend served at /. The UI is
+	// then same-origin, so CORSAllowOrigin defaults to empty (CORS off).
+	FrontendDir          string
+	CORSAllowOrigin      string
+	CORSAllowCredentials bool
+
+	// errs collects values that could not be parsed; Validate returns them.
+	errs []error
+}
+
+// Load reads the configuration from the environment, using the default for
+// every unset variable. A malformed value leaves the default in place and
+// is reported by Validate.
+func Load() *Config {
+	c := &Config{}
+	c.DatabaseURL = c.getSecret("DATABASE_URL")
+	c.DBQueryTimeout = c.getDuration("DB_QUERY_TIMEOUT", 30*time.Second)
+	c.ServerPort = c.getEnv("SERVER_PORT", "8080")
+	c.BasePath = c.getBasePath("BASE_PATH")
+
+	c.TLSCertFile = c.getEnv("TLS_CERT_FILE", "")
+	c.TLSKeyFile = c.getEnv("TLS_KEY_FILE", "")
+	c.TLSRedirectPort = c.getEnv("TLS_REDIRECT_PORT", "")
+	c.TLSReloadInterval = c.getDuration("TLS_RELOAD_INTERVAL", time.Minute)
+
+	c.CTLogURL = c.getEnv("CT_LOG_URL", "https://oak.ct.letsencrypt.org/2026h2")
+	c.CTLogMaxResponseBytes = int64(c.getInt("CT_LOG_MAX_RESPONSE_BYTES", 64<<20))
+	c.CTLogSTHCacheTTL = c.getDuration("CT_LOG_STH_CACHE_TTL", 0)
+	c.CTLogIntervalEnd = c.getDate("CT_LOG_INTERVAL_END")
+	c.CTLogFrozenAfter = c.getDuration("CT_LOG_FROZEN_AFTER", time.Hour)
+	c.CTLogSuccessorURL = c.getEnv("CT_LOG_SUCCESSOR_URL", "")
+	c.MonitorInterval = c.getDuration("MONITOR_INTERVAL", 60*time.Second)
+	c.MonitorBatchSize = c.getInt("MONITOR_BATCH_SIZE", 100)
+	c.MonitorReprocessOnIdle = c.getBool("MONITOR_REPROCESS_ON_IDLE", false)
+	c.MonitorStartJitter = c.getDuration("MONITOR_START_JITTER", 0)
+	c.MonitorMaxMatchesPerCert = c.getInt("MONITOR_MAX_MATCHES_PER_CERT", 0)
+	c.MonitorMaxCycles = c.getInt("MONITOR_MAX_CYCLES", 0)
+	c.MonitorServerAuthOnly = c.getBool("MONITOR_SERVER_AUTH_ONLY", false)
+	c.MonitorVerifyChains = c.getBool("MONITOR_VERIFY_CHAINS", true)
+	c.MatchIgnoreCN = c.getBool("MATCH_IGNORE_CN", false)
+	c.KeywordMaxMatchPercent = c.getInt("KEYWORD_MAX_MATCH_PERCENT", 0)
+	c.KeywordMaxMatchCycles = c.getInt("KEYWORD_MAX_MATCH_CYCLES", 3)
+	c.MonitorHeadLag = c.getInt("MONITOR_HEAD_LAG", 0)
+	c.MonitorMinNotBefore = c.getDate("MONITOR_MIN_NOT_BEFORE")
+	c.LogMatches = c.getBool("LOG_MATCHES", false)
+	switch strategy := strings.ToLower(c.getEnv("CERT_CONFLICT_STRATEGY", "ignore")); strategy {
+	case "ignore", "update":
+		c.CertConflictStrategy = strategy
+	default:
+		c.CertConflictStrategy = "ignore"
+		c.errs = append(c.errs, fmt.Errorf("CERT_CONFLICT_STRATEGY: %q is not ignore or update", strategy))
+	}
+	c.CertConsolidate = c.getBool("CERT_CONSOLIDATE", false)
+
+	c.StreamSubscriberBuffer = c.getInt("STREAM_SUBSCRIBER_BUFFER", 64)
+	c.WSMaxConnections = c.getInt("WS_MAX_CONNECTIONS", 100)
+	c.AnalyzeMaxCount = c.getInt("ANALYZE_MAX_COUNT", 1000)
+	c.StatsTopN = c.getInt("STATS_TOP_N", 10)
+	c.StatsDays = c.getInt("STATS_DAYS", 30)
+
+	c.MaxBodyBytes = int64(c.getInt("MAX_BODY_BYTES", 1<<20))
+	c.ImportMaxBodyBytes = int64(c.getInt("IMPORT_MAX_BODY_BYTES", 10<<20))
+	c.RequestTimeout = c.getDuration("REQUEST_TIMEOUT", 30*time.Second)
+	c.ShutdownTimeout = c.getDuration("SHUTDOWN_TIMEOUT", 10*time.Second)
+
+	c.SeedKeywords = c.getEnv("SEED_KEYWORDS", "")
+	c.SeedKeywordsFile = c.getEnv("SEED_KEYWORDS_FILE", "")
+
+	c.NotifyWebhookURL = c.getSecret("NOTIFY_WEBHOOK_URL")
+	c.NotifyPollInterval = c.getDuration("NOTIFY_POLL_INTERVAL", 5*time.Second)
+	c.NotifyMaxAttempts = c.getInt("NOTIFY_MAX_ATTEMPTS", 5)
+	c.NotifyOutboxRetention = c.getDuration("NOTIFY_OUTBOX_RETENTION", 7*24*time.Hour)
+	c.NotifyWorkers = c.getInt("NOTIFY_WORKERS", 4)
+	c.NotifyBatchSize = c.getInt("NOTIFY_BATCH_SIZE", 200)
+	c.NotifyQueueSize = c.getInt("NOTIFY_QUEUE_SIZE", 100)
+	switch policy := strings.ToLower(c.getEnv("NOTIFY_QUEUE_POLICY", "block")); policy {
+	case "block", "drop", "drop_oldest", "digest":
+		c.NotifyQueuePolicy = policy
+	default:
+		c.NotifyQueuePolicy = "block"
+		c.errs = append(c.errs, fmt.Errorf("NOTIFY_QUEUE_POLICY: %q is not block, drop, drop_oldest or digest", policy))
+	}
+
+	c.ReportEnabled = c.getBool("REPORT_ENABLED", false)
+	c.ReportHour = c.getInt("REPORT_HOUR", 8)
+	c.ReportLocation = time.UTC
+	if name := c.getEnv("REPORT_TIMEZONE", "UTC"); name != "UTC" {
+		if loc, err := time.LoadLocation(name); err != nil {
+			c.errs = append(c.errs, fmt.Errorf("REPORT_TIMEZONE: %w", err))
+		} else {
+			c.ReportLocation = loc
+		}
+	}
+	c.ReportTopN = c.getInt("REPORT_TOP_N", 10)
+	c.ReportMinRisk = c.getInt("REPORT_MIN_RISK", 50)
+	c.ReportSlackWebhookURL = c.getSecret("REPORT_SLACK_WEBHOOK_URL")
+
+	c.CrtShEnabled = c.getBool("CRTSH_ENABLED", false)
+	c.CrtShURL = c.getEnv("CRTSH_URL", "https://crt.sh")
+	c.CrtShMinInterval = c.getDuration("CRTSH_MIN_INTERVAL", 5*time.Second)
+	c.CrtShTimeout = c.getDuration("CRTSH_TIMEOUT", 30*time.Second)
+	c.CrtShCacheTTL = c.getDuration("CRTSH_CACHE_TTL", 24*time.Hour)
+	c.CrtShImportEnabled = c.getBool("CRTSH_IMPORT_ENABLED", false)
+	c.CrtShImportMaxRows = c.getInt("CRTSH_IMPORT_MAX_ROWS", 1000)
+	c.CrtShImportTimeout = c.getDuration("CRTSH_IMPORT_TIMEOUT", 2*time.Minute)
+	c.RDAPEnabled = c.getBool("RDAP_ENABLED", false)
+	c.RDAPURL = c.getEnv("RDAP_URL", "https://rdap.org")
+	c.RDAPMinInterval = c.getDuration("RDAP_MIN_INTERVAL", 2*time.Second)
+	c.RDAPTimeout = c.getDuration("RDAP_TIMEOUT", 15*time.Second)
+	c.RDAPCacheTTL = c.getDuration("RDAP_CACHE_TTL", 7*24*time.Hour)
+
+	c.RiskWeights = c.getWeights("RISK_WEIGHTS")
+
+	switch level := strings.ToLower(c.getEnv("HTTP_LOG_SUCCESS_LEVEL", "info")); level {
+	case "info":
+		c.HTTPLogSuccessLevel = slog.LevelInfo
+	case "debug":
+		c.HTTPLogSuccessLevel = slog.LevelDebug
+	default:
+		c.HTTPLogSuccessLevel = slog.LevelInfo
+		c.errs = append(c.errs, fmt.Errorf("HTTP_LOG_SUCCESS_LEVEL: %q is not info or debug", level))
+	}
+	c.TrustedProxies = c.getEnv("TRUSTED_PROXIES", "")
+	c.AdminAllowCIDRs = c.getEnv("ADMIN_ALLOW_CIDRS", "")
+	c.AllowedCTLogURLs = c.getEnv("ALLOWED_CT_LOG_URLS", "")
+	c.DebugEndpoints = c.getBool("DEBUG_ENDPOINTS", false)
+	c.ReadyzRequireCycle = c.getBool("READYZ_REQUIRE_CYCLE", false)
+	c.OTLPEndpoint = c.getEnv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", c.getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""))
+	c.FrontendDir = c.getEnv("FRONTEND_DIR", "")
+	corsDefault := "http://localhost:3000"
+	if c.FrontendDir != "" {
+		corsDefault = ""
+	}
+	c.CORSAllowOrigin = c.getEnv("CORS_ALLOW_ORIGIN", corsDefault)
+	c.CORSAllowCredentials = c.getBool("CORS_ALLOW_CREDENTIALS", false)
+	return c
+}
+
+// TLSEnabled reports whether the server terminates TLS itself, i.e. both
+// TLS_CERT_FILE and TLS_KEY_FILE are set; otherwise it serves plain HTTP.
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+// Validate reports every missing, malformed or out-of-range value at once.
+func (c *Config) Validate() error {
+	errs := append([]error(nil), c.errs...)
+	if c.DatabaseURL == "" {
+		errs = append(errs, errors.New("DATABASE_URL is required"))
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		errs = append(errs, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together"))
+	}
+	if c.TLSEnabled() {
+		// Whether the pair loads is checked when the server starts
+		// (tlsserver.NewReloader); catch missing paths here with the rest.
+		for _, f := range []struct{ name, path string }{
+			{"TLS_CERT_FILE", c.TLSCertFile},
+			{"TLS_KEY_FILE", c.TLSKeyFile},
+		} {
+			if info, err := os.Stat(f.path); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", f.name, err))
+			} else if info.IsDir() {
+				errs = append(errs, fmt.Errorf("%s: %s is a directory", f.name, f.path))
+			}
+		}
+	}
+	if c.TLSRedirectPort != "" && !c.TLSEnabled() {
+		errs = append(errs, errors.New("TLS_REDIRECT_PORT requires TLS_CERT_FILE and TLS_KEY_FILE"))
+	}
+	if c.CTLogSuccessorURL != "" && c.CTLogIntervalEnd.IsZero() {
+		errs = append(errs, errors.New("CT_LOG_SUCCESSOR_URL requires CT_LOG_INTERVAL_END"))
+	}
+	if c.KeywordMaxMatchPercent < 0 || c.KeywordMaxMatchPercent > 100 {
+		errs = append(errs, fmt.Errorf("KEYWORD_MAX_MATCH_PERCENT must be between 0 and 100, got %d", c.KeywordMaxMatchPercent))
+	}
+	if c.ReportHour < 0 || c.ReportHour > 23 {
+		errs = append(errs, fmt.Errorf("REPORT_HOUR must be between 0 and 23, got %d", c.ReportHour))
+	}
+	if c.ReportMinRisk < 0 || c.ReportMinRisk > 100 {
+		errs = append(errs, fmt.Errorf("REPORT_MIN_RISK must be between 0 and 100, got %d", c.ReportMinRisk))
+	}
+	// A claim that always fits in the queue never overflows it.
+	if c.NotifyQueuePolicy != "block" && c.NotifyQueueSize > 0 && c.NotifyBatchSize <= c.NotifyQueueSize {
+		errs = append(errs, fmt.Errorf("NOTIFY_BATCH_SIZE (%d) must exceed NOTIFY_QUEUE_SIZE (%d) for NOTIFY_QUEUE_POLICY %s to apply",
+			c.NotifyBatchSize, c.NotifyQueueSize, c.NotifyQueuePolicy))
+	}
+	positive := []struct {
+		name string
+		ok   bool
+	}{
+		{"TLS_RELOAD_INTERVAL", c.TLSReloadInterval > 0},
+		{"CT_LOG_MAX_RESPONSE_BYTES", c.CTLogMaxResponseBytes > 0},
+		{"CT_LOG_FROZEN_AFTER", c.CTLogFrozenAfter > 0},
+		{"MONITOR_INTERVAL", c.MonitorInterval > 0},
+		{"MONITOR_BATCH_SIZE", c.MonitorBatchSize > 0},
+		{"STREAM_SUBSCRIBER_BUFFER", c.StreamSubscriberBuffer > 0},
+		{"WS_MAX_CONNECTIONS", c.WSMaxConnections > 0},
+		{"ANALYZE_MAX_COUNT", c.AnalyzeMaxCount > 0},
+		{"STATS_TOP_N", c.StatsTopN > 0},
+		{"STATS_DAYS", c.StatsDays > 0},
+		{"MAX_BODY_BYTES", c.MaxBodyBytes > 0},
+		{"IMPORT_MAX_BODY_BYTES", c.ImportMaxBodyBytes > 0},
+		{"SHUTDOWN_TIMEOUT", c.ShutdownTimeout > 0},
+		{"NOTIFY_POLL_INTERVAL", c.NotifyPollInterval > 0},
+		{"NOTIFY_MAX_ATTEMPTS", c.NotifyMaxAttempts > 0},
+		{"NOTIFY_WORKERS", c.NotifyWorkers > 0},
+		{"NOTIFY_BATCH_SIZE", c.NotifyBatchSize > 0},
+		{"NOTIFY_QUEUE_SIZE", c.NotifyQueueSize > 0},
+		{"REPORT_TOP_N", c.ReportTopN > 0},
+		{"KEYWORD_MAX_MATCH_CYCLES", c.KeywordMaxMatchCycles > 0},
+		{"CRTSH_MIN_INTERVAL", c.CrtShMinInterval > 0},
+		{"CRTSH_TIMEOUT", c.CrtShTimeout > 0},
+		{"CRTSH_IMPORT_MAX_ROWS", c.CrtShImportMaxRows > 0},
+		{"CRTSH_IMPORT_TIMEOUT", c.CrtShImportTimeout > 0},
+		{"RDAP_MIN_INTERVAL", c.RDAPMinInterval > 0},
+		{"RDAP_TIMEOUT", c.RDAPTimeout > 0},
+	}
+	for _, p := range positive {
+		if !p.ok {
+			errs = append(errs, fmt.Errorf("%s must be positive", p.name))
+		}
+	}
+	nonNegative := []struct {
+		name string
+		ok   bool
+	}{
+		{"CT_LOG_STH_CACHE_TTL", c.CTLogSTHCacheTTL >= 0},
+		{"MONITOR_START_JITTER", c.MonitorStartJitter >= 0},
+		{"MONITOR_MAX_MATCHES_PER_CERT", c.MonitorMaxMatchesPerCert >= 0},
+		{"MONITOR_MAX_CYCLES", c.MonitorMaxCycles >= 0},
+		{"MONITOR_HEAD_LAG", c.MonitorHeadLag >= 0},
+		{"REQUEST_TIMEOUT", c.RequestTimeout >= 0},
+		{"DB_QUERY_TIMEOUT", c.DBQueryTimeout >= 0},
+		{"NOTIFY_OUTBOX_RETENTION", c.NotifyOutboxRetention >= 0},
+		{"CRTSH_CACHE_TTL", c.CrtShCacheTTL >= 0},
+		{"RDAP_CACHE_TTL", c.RDAPCacheTTL >= 0},
+	}
+	for _, n := range nonNegative {
+		if !n.ok {
+			errs = append(errs, fmt.Errorf("%s must not be negative", n.name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// LogAttrs describes every setting for the startup log. Credentials and
+// the paths of URLs that may embed tokens are masked.
+func (c *Config) LogAttrs() []slog.Attr {
+	return []slog.Attr{
+		slog.String("database_url", redactURL(c.DatabaseURL)),
+		slog.Duration("db_query_timeout", c.DBQueryTimeout),
+		slog.String("server_port", c.ServerPort),
+		slog.String("base_path", c.BasePath),
+		slog.String("tls_cert_file", c.TLSCertFile),
+		slog.String("tls_key_file", c.TLSKeyFile),
+		slog.String("tls_redirect_port", c.TLSRedirectPort),
+		slog.Duration("tls_reload_interval", c.TLSReloadInterval),
+		slog.String("ct_log_url", c.CTLogURL),
+		slog.Int64("ct_log_max_response_bytes", c.CTLogMaxResponseBytes),
+		slog.Duration("ct_log_sth_cache_ttl", c.CTLogSTHCacheTTL),
+		slog.Time("ct_log_interval_end", c.CTLogIntervalEnd),
+		slog.Duration("ct_log_frozen_after", c.CTLogFrozenAfter),
+		slog.String("ct_log_successor_url", c.CTLogSuccessorURL),
+		slog.Duration("monitor_interval", c.MonitorInterval),
+		slog.Int("monitor_batch_size", c.MonitorBatchSize),
+		slog.Bool("monitor_reprocess_on_idle", c.MonitorReprocessOnIdle),
+		slog.Duration("monitor_start_jitter", c.MonitorStartJitter),
+		slog.Int("monitor_max_matches_per_cert", c.MonitorMaxMatchesPerCert),
+		slog.Int("monitor_max_cycles", c.MonitorMaxCycles),
+		slog.Bool("monitor_server_auth_only", c.MonitorServerAuthOnly),
+		slog.Bool("monitor_verify_chains", c.MonitorVerifyChains),
+		slog.Bool("match_ignore_cn", c.MatchIgnoreCN),
+		slog.Int("keyword_max_match_percent", c.KeywordMaxMatchPercent),
+		slog.Int("keyword_max_match_cycles", c.KeywordMaxMatchCycles),
+		slog.Bool("log_matches", c.LogMatches),
+		slog.Int("monitor_head_lag", c.MonitorHeadLag),
+		slog.Time("monitor_min_not_before", c.MonitorMinNotBefore),
+		slog.String("cert_conflict_strategy", c.CertConflictStrategy),
+		slog.Bool("cert_consolidate", c.CertConsolidate),
+		slog.Int("stream_subscriber_buffer", c.StreamSubscriberBuffer),
+		slog.Int("ws_max_connections", c.WSMaxConnections),
+		slog.Int("analyze_max_count", c.AnalyzeMaxCount),
+		slog.Int("stats_top_n", c.StatsTopN),
+		slog.Int("stats_days", c.StatsDays),
+		slog.Int64("max_body_bytes", c.MaxBodyBytes),
+		slog.Int64("import_max_body_bytes", c.ImportMaxBodyBytes),
+		slog.Duration("request_timeout", c.RequestTimeout),
+		slog.Duration("shutdown_timeout", c.ShutdownTimeout),
+		slog.Int("seed_keywords", len(strings.FieldsFunc(c.SeedKeywords, func(r rune) bool { return r == ',' || r == '\n' }))),
+		slog.String("seed_keywords_file", c.SeedKeywordsFile),
+		slog.String("notify_webhook_url", redactURL(c.NotifyWebhookURL)),
+		slog.Duration("notify_poll_interval", c.NotifyPollInterval),
+		slog.Int("notify_max_attempts", c.NotifyMaxAttempts),
+		slog.Duration("notify_outbox_retention", c.NotifyOutboxRetention),
+		slog.Int("notify_workers", c.NotifyWorkers),
+		slog.Int("notify_batch_size", c.NotifyBatchSize),
+		slog.Int("notify_queue_size", c.NotifyQueueSize),
+		slog.String("notify_queue_policy", c.NotifyQueuePolicy),
+		slog.Bool("report_enabled", c.ReportEnabled),
+		slog.Int("report_hour", c.ReportHour),
+		slog.String("report_timezone", c.ReportLocation.String()),
+		slog.Int("report_top_n", c.ReportTopN),
+		slog.Int("report_min_risk", c.ReportMinRisk),
+		slog.String("report_slack_webhook_url", redactURL(c.ReportSlackWebhookURL)),
+		slog.Bool("crtsh_enabled", c.CrtShEnabled),
+		slog.String("crtsh_url", c.CrtShURL),
+		slog.Duration("crtsh_min_interval", c.CrtShMinInterval),
+		slog.Duration("crtsh_timeout", c.CrtShTimeout),
+		slog.Duration("crtsh_cache_ttl", c.CrtShCacheTTL),
+		slog.Bool("crtsh_import_enabled", c.CrtShImportEnabled),
+		slog.Int("crtsh_import_max_rows", c.CrtShImportMaxRows),
+		slog.Duration("crtsh_import_timeout", c.CrtShImportTimeout),
+		slog.Bool("rdap_enabled", c.RDAPEnabled),
+		slog.String("rdap_url", c.RDAPURL),
+		slog.Duration("rdap_min_interval", c.RDAPMinInterval),
+		slog.Duration("rdap_timeout", c.RDAPTimeout),
+		slog.Duration("rdap_cache_ttl", c.RDAPCacheTTL),
+		slog.String("risk_weights", formatWeights(c.RiskWeights)),
+		slog.String("http_log_success_level", c.HTTPLogSuccessLevel.String()),
+		slog.String("trusted_proxies", c.TrustedProxies),
+		slog.String("admin_allow_cidrs", c.AdminAllowCIDRs),
+		slog.String("allowed_ct_log_urls", c.AllowedCTLogURLs),
+		slog.Bool("debug_endpoints", c.DebugEndpoints),
+		slog.Bool("readyz_require_cycle", c.ReadyzRequireCycle),
+		slog.String("otel_exporter_otlp_endpoint", redactURL(c.OTLPEndpoint)),
+		slog.String("frontend_dir", c.FrontendDir),
+		slog.String("cors_allow_origin", c.CORSAllowOrigin),
+		slog.Bool("cors_allow_credentials", c.CORSAllowCredentials),
+	}
+}
+
+// Redacted returns the settings LogAttrs describes, keyed by the same
+// names, for GET /config. Every value is rendered as a string: durations
+// as Go duration strings, dates as RFC 3339 and unset dates as "".
+func (c *Config) Redacted() map[string]string {
+	attrs := c.LogAttrs()
+	settings := make(map[string]string, len(attrs))
+	for _, a := range attrs {
+		switch a.Value.Kind() {
+		case slog.KindDuration:
+			settings[a.Key] = a.Value.Duration().String()
+		case slog.KindTime:
+			if t := a.Value.Time(); t.IsZero() {
+				settings[a.Key] = ""
+			} else {
+				settings[a.Key] = t.Format(time.RFC3339)
+			}
+		default:
+			settings[a.Key] = a.Value.String()
+		}
+	}
+	return settings
+}
+
+// formatWeights renders weights as RISK_WEIGHTS takes them, sorted by
+// name.
+func formatWeights(weights map[string]int) string {
+	pairs := make([]string, 0, len(weights))
+	for name, points := range weights {
+		pairs = append(pairs, name+"="+strconv.Itoa(points))
+	}
+	slices.Sort(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// redactURL keeps a URL's scheme, user name and host, masking the password
+// and replacing any path or query (webhook URLs often carry a token there).
+func redactURL(s string) string {
+	if s == "" {
+		return ""
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return "xxxxx"
+	}
+	redacted := &url.URL{Scheme: u.Scheme, Host: u.Host}
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			redacted.User = url.UserPassword(u.User.Username(), "xxxxx")
+		} else {
+			redacted.User = url.User(u.User.Username())
+		}
+	}
+	out := redacted.String()
+	if (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+		out += "/xxxxx"
+	}
+	return out
+}
+
+// getSecret reads a credential from key or, for secret mounts, from the file
+// named by key_FILE (trimmed). Setting both is an error, so a stale value
+// cannot silently win.
+func (c *Config) getSecret(key string) string {
+	v := os.Getenv(key)
+	path := os.Getenv(key + "_FILE")
+	if path == "" {
+		return v
+	}
+	if v != "" {
+		c.errs = append(c.errs, fmt.Errorf("%s and %s_FILE are both set", key, key))
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		c.errs = append(c.errs, fmt.Errorf("%s_FILE: %w", key, err))
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func (c *Config) getEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func (c *Config) getInt(key string, fallback int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		c.errs = append(c.errs, fmt.Errorf("%s: %q is not an integer", key, v))
+		return fallback
+	}
+	return n
+}
+
+func (c *Config) getDuration(key string, fallback time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		c.errs = append(c.errs, fmt.Errorf("%s: %q is not a duration", key, v))
+		return fallback
+	}
+	return d
+}
+
+func (c *Config) getBool(key string, fallback bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		c.errs = append(c.errs, fmt.Errorf("%s: %q is not a boolean", key, v))
+		return fallback
+	}
+	return b
+}
+
+// getWeights parses key as comma-separated name=points pairs, e.g.
+// "wildcard=0,domain_age_week=50". Unset returns nil.
+func (c *Config) getWeights(key string) map[string]int {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil
+	}
+	weights := make(map[string]int)
+	for _, pair := range strings.Split(v, ",") {
+		name, points, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		n, err := strconv.Atoi(strings.TrimSpace(points))
+		if !ok || err != nil || strings.TrimSpace(name) == "" {
+			c.errs = append(c.errs, fmt.Errorf("%s: %q is not name=points", key, pair))
+			return nil
+		}
+		weights[strings.TrimSpace(name)] = n
+	}
+	return weights
+}
+
+// getDate parses key as a date (2006-01-02, UTC midnight) or an RFC 3339
+// timestamp. Unset returns the zero time.
+func (c *Config) getDate(key string) time.Time {
+	v := os.Getenv(key)
+	if v == "" {
+		return time.Time{}
+	}
+	if t, err := time.Parse(time.DateOnly, v); err == nil {
+		return t
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		c.errs = append(c.errs, fmt.Errorf("%s: %q is not a date (YYYY-MM-DD) or RFC 3339 timestamp", key, v))
+		return time.Time{}
+	}
+	return t
+}
+
+// getBasePath parses key as a URL path prefix such as "/sisap". A trailing
+// slash is dropped, so unset and "/" both return "" (no prefix).
+func (c *Config) getBasePath(key string) string {
+	v := strings.TrimRight(os.Getenv(key), "/")
+	if v == "" {
+		return ""
+	}
+	if !strings.HasPrefix(v, "/") || path.Clean(v) != v || strings.ContainsAny(v, "?#% ") {
+		c.errs = append(c.errs, fmt.Errorf("%s: %q is not a path like /sisap", key, os.Getenv(key)))
+		return ""
+	}
+	return v
+}
@@ -0,0 +1,448 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad_Defaults(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://localhost/ct")
+
+	c := Load()
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Validate() = %v, want nil", err)
+	}
+	if c.ServerPort != "8080" {
+		t.Errorf("ServerPort = %q, want 8080", c.ServerPort)
+	}
+	if c.MonitorInterval != 60*time.Second || c.MonitorBatchSize != 100 {
+		t.Errorf("MonitorInterval/BatchSize = %v/%d, want 60s/100", c.MonitorInterval, c.MonitorBatchSize)
+	}
+	if c.MonitorReprocessOnIdle {
+		t.Error("MonitorReprocessOnIdle = true, want false")
+	}
+	if c.MaxBodyBytes != 1<<20 || c.ImportMaxBodyBytes != 10<<20 {
+		t.Errorf("MaxBodyBytes/ImportMaxBodyBytes = %d/%d", c.MaxBodyBytes, c.ImportMaxBodyBytes)
+	}
+	if c.CTLogMaxResponseBytes != 64<<20 {
+		t.Errorf("CTLogMaxResponseBytes = %d, want %d", c.CTLogMaxResponseBytes, 64<<20)
+	}
+	if c.CTLogSTHCacheTTL != 0 {
+		t.Errorf("CTLogSTHCacheTTL = %v, want 0", c.CTLogSTHCacheTTL)
+	}
+	if c.HTTPLogSuccessLevel != slog.LevelInfo {
+		t.Errorf("HTTPLogSuccessLevel = %v, want INFO", c.HTTPLogSuccessLevel)
+	}
+	if c.DebugEndpoints {
+		t.Error("DebugEndpoints = true, want false")
+	}
+	if c.CertConflictStrategy != "ignore" {
+		t.Errorf("CertConflictStrategy = %q, want ignore", c.CertConflictStrategy)
+	}
+	if c.DBQueryTimeout != 30*time.Second {
+		t.Errorf("DBQueryTimeout = %v, want 30s", c.DBQueryTimeout)
+	}
+	if c.NotifyOutboxRetention != 7*24*time.Hour {
+		t.Errorf("NotifyOutboxRetention = %v, want 168h", c.NotifyOutboxRetention)
+	}
+	if c.CrtShEnabled || c.CrtShURL != "https://crt.sh" || c.CrtShMinInterval != 5*time.Second {
+		t.Errorf("crt.sh = %v/%q/%v, want disabled, https://crt.sh and 5s", c.CrtShEnabled, c.CrtShURL, c.CrtShMinInterval)
+	}
+	if c.CrtShImportEnabled || c.CrtShImportMaxRows != 1000 || c.CrtShImportTimeout != 2*time.Minute {
+		t.Errorf("crt.sh import = %v/%d/%v, want disabled, 1000 and 2m", c.CrtShImportEnabled, c.CrtShImportMaxRows, c.CrtShImportTimeout)
+	}
+	if c.RDAPEnabled || c.RDAPURL != "https://rdap.org" || c.RDAPCacheTTL != 7*24*time.Hour {
+		t.Errorf("RDAP = %v/%q/%v, want disabled, https://rdap.org and 168h", c.RDAPEnabled, c.RDAPURL, c.RDAPCacheTTL)
+	}
+	if c.MatchIgnoreCN {
+		t.Error("MatchIgnoreCN = true, want the CN matched by default")
+	}
+	if c.KeywordMaxMatchPercent != 0 || c.KeywordMaxMatchCycles != 3 {
+		t.Errorf("keyword match limit = %d%%/%d, want off and 3", c.KeywordMaxMatchPercent, c.KeywordMaxMatchCycles)
+	}
+	if !c.MonitorVerifyChains {
+		t.Error("MonitorVerifyChains = false, want chain verification on by default")
+	}
+	if !c.MonitorMinNotBefore.IsZero() {
+		t.Errorf("MonitorMinNotBefore = %v, want zero", c.MonitorMinNotBefore)
+	}
+	if c.NotifyWorkers != 4 || c.NotifyBatchSize != 200 || c.NotifyQueueSize != 100 || c.NotifyQueuePolicy != "block" {
+		t.Errorf("notify pool = %d/%d/%d/%q, want 4/200/100/block",
+			c.NotifyWorkers, c.NotifyBatchSize, c.NotifyQueueSize, c.NotifyQueuePolicy)
+	}
+	if c.ReportEnabled || c.ReportHour != 8 || c.ReportLocation != time.UTC || c.ReportTopN != 10 || c.ReportMinRisk != 50 {
+		t.Errorf("report = %v/%d/%v/%d/%d, want disabled, 8, UTC, 10 and 50",
+			c.ReportEnabled, c.ReportHour, c.ReportLocation, c.ReportTopN, c.ReportMinRisk)
+	}
+}
+
+func TestLoad_Overrides(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://localhost/ct")
+	t.Setenv("MONITOR_INTERVAL", "5s")
+	t.Setenv("MONITOR_REPROCESS_ON_IDLE", "true")
+	t.Setenv("MONITOR_MAX_CYCLES", "3")
+	t.Setenv("HTTP_LOG_SUCCESS_LEVEL", "DEBUG")
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "1")
+	t.Setenv("CERT_CONFLICT_STRATEGY", "Update")
+	t.Setenv("CERT_CONSOLIDATE", "true")
+	t.Setenv("READYZ_REQUIRE_CYCLE", "true")
+	t.Setenv("CT_LOG_INTERVAL_END", "2027-01-01")
+	t.Setenv("CT_LOG_FROZEN_AFTER", "30m")
+	t.Setenv("CT_LOG_SUCCESSOR_URL", "https://oak.ct.letsencrypt.org/2027h1")
+	t.Setenv("NOTIFY_QUEUE_POLICY", "Drop_Oldest")
+	t.Setenv("MONITOR_MIN_NOT_BEFORE", "2025-06-01")
+	t.Setenv("RISK_WEIGHTS", "wildcard=0, domain_age_week=50")
+	t.Setenv("REPORT_TIMEZONE", "America/New_York")
+	t.Setenv("REPORT_HOUR", "0")
+
+	c := Load()
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Validate() = %v, want nil", err)
+	}
+	if c.MonitorInterval != 5*time.Second || !c.MonitorReprocessOnIdle || c.MonitorMaxCycles != 3 {
+		t.Errorf("monitor = %v/%v/%d, want 5s/true/3", c.MonitorInterval, c.MonitorReprocessOnIdle, c.MonitorMaxCycles)
+	}
+	if c.HTTPLogSuccessLevel != slog.LevelDebug {
+		t.Errorf("HTTPLogSuccessLevel = %v, want DEBUG", c.HTTPLogSuccessLevel)
+	}
+	if !c.CORSAllowCredentials {
+		t.Error("CORSAllowCredentials = false, want true")
+	}
+	if c.CertConflictStrategy != "update" {
+		t.Errorf("CertConflictStrategy = %q, want update", c.CertConflictStrategy)
+	}
+	if !c.CertConsolidate {
+		t.Error("CertConsolidate = false, want true")
+	}
+	if !c.ReadyzRequireCycle {
+		t.Error("ReadyzRequireCycle = false, want true")
+	}
+	if want := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC); !c.CTLogIntervalEnd.Equal(want) ||
+		c.CTLogFrozenAfter != 30*time.Minute || c.CTLogSuccessorURL == "" {
+		t.Errorf("rollover = %v/%v/%q, want 2027-01-01/30m/the successor", c.CTLogIntervalEnd, c.CTLogFrozenAfter, c.CTLogSuccessorURL)
+	}
+	if c.NotifyQueuePolicy != "drop_oldest" {
+		t.Errorf("NotifyQueuePolicy = %q, want drop_oldest", c.NotifyQueuePolicy)
+	}
+	if want := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC); !c.MonitorMinNotBefore.Equal(want) {
+		t.Errorf("MonitorMinNotBefore = %v, want %v", c.MonitorMinNotBefore, want)
+	}
+	if len(c.RiskWeights) != 2 || c.RiskWeights["wildcard"] != 0 || c.RiskWeights["domain_age_week"] != 50 {
+		t.Errorf("RiskWeights = %v, want wildcard=0 domain_age_week=50", c.RiskWeights)
+	}
+	if c.ReportLocation.String() != "America/New_York" || c.ReportHour != 0 {
+		t.Errorf("report = %v/%d, want America/New_York/0", c.ReportLocation, c.ReportHour)
+	}
+}
+
+func TestValidate_ReportsEveryProblem(t *testing.T) {
+	t.Setenv("DATABASE_URL", "")
+	t.Setenv("MONITOR_BATCH_SIZE", "lots")
+	t.Setenv("MONITOR_INTERVAL", "0s")
+	t.Setenv("REQUEST_TIMEOUT", "-1s")
+	t.Setenv("DB_QUERY_TIMEOUT", "-5s")
+	t.Setenv("HTTP_LOG_SUCCESS_LEVEL", "trace")
+	t.Setenv("CERT_CONFLICT_STRATEGY", "replace")
+	t.Setenv("TLS_CERT_FILE", "/etc/tls/tls.crt")
+	t.Setenv("NOTIFY_WORKERS", "0")
+	t.Setenv("NOTIFY_QUEUE_POLICY", "spill")
+	t.Setenv("CT_LOG_MAX_RESPONSE_BYTES", "0")
+	t.Setenv("CT_LOG_STH_CACHE_TTL", "-5s")
+	t.Setenv("CT_LOG_FROZEN_AFTER", "0s")
+	t.Setenv("MONITOR_MIN_NOT_BEFORE", "June 2025")
+	t.Setenv("CRTSH_MIN_INTERVAL", "0s")
+	t.Setenv("CRTSH_IMPORT_MAX_ROWS", "-1")
+	t.Setenv("RDAP_CACHE_TTL", "-1h")
+	t.Setenv("RISK_WEIGHTS", "wildcard:5")
+	t.Setenv("REPORT_HOUR", "24")
+	t.Setenv("REPORT_TIMEZONE", "Mars/Olympus_Mons")
+	t.Setenv("REPORT_TOP_N", "0")
+	t.Setenv("REPORT_MIN_RISK", "101")
+	t.Setenv("KEYWORD_MAX_MATCH_PERCENT", "150")
+	t.Setenv("KEYWORD_MAX_MATCH_CYCLES", "0")
+
+	err := Load().Validate()
+	if err == nil {
+		t.Fatal("Validate() = nil, want errors")
+	}
+	for _, want := range []string{
+		"DATABASE_URL is required",
+		"MONITOR_BATCH_SIZE",
+		"MONITOR_INTERVAL must be positive",
+		"REQUEST_TIMEOUT must not be negative",
+		"DB_QUERY_TIMEOUT must not be negative",
+		"HTTP_LOG_SUCCESS_LEVEL",
+		"CERT_CONFLICT_STRATEGY",
+		"TLS_CERT_FILE and TLS_KEY_FILE must be set together",
+		"NOTIFY_WORKERS must be positive",
+		"NOTIFY_QUEUE_POLICY",
+		"CT_LOG_MAX_RESPONSE_BYTES must be positive",
+		"CT_LOG_STH_CACHE_TTL must not be negative",
+		"CT_LOG_FROZEN_AFTER must be positive",
+		"MONITOR_MIN_NOT_BEFORE",
+		"CRTSH_MIN_INTERVAL must be positive",
+		"CRTSH_IMPORT_MAX_ROWS must be positive",
+		"RDAP_CACHE_TTL must not be negative",
+		"RISK_WEIGHTS",
+		"REPORT_HOUR must be between 0 and 23",
+		"REPORT_TIMEZONE",
+		"REPORT_TOP_N must be positive",
+		"REPORT_MIN_RISK must be between 0 and 100",
+		"KEYWORD_MAX_MATCH_PERCENT must be between 0 and 100",
+		"KEYWORD_MAX_MATCH_CYCLES must be positive",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestLogAttrs_RedactsSecrets(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://ctmonitor:s3cret@db:5432/ct_monitor?sslmode=disable")
+	t.Setenv("NOTIFY_WEBHOOK_URL", "https://hooks.example.com/services/T000/B000/tok3n")
+	t.Setenv("REPORT_SLACK_WEBHOOK_URL", "https://hooks.slack.com/services/T000/B000/r3port")
+
+	attrs := Load().LogAttrs()
+	values := make(map[string]string, len(attrs))
+	for _, a := range attrs {
+		values[a.Key] = a.Value.String()
+	}
+
+	if got := values["database_url"]; strings.Contains(got, "s3cret") || !strings.Contains(got, "ctmonitor:xxxxx@db:5432") {
+		t.Errorf("database_url = %q, want password masked", got)
+	}
+	if got := values["notify_webhook_url"]; strings.Contains(got, "tok3n") || !strings.HasPrefix(got, "https://hooks.example.com") {
+		t.Errorf("notify_webhook_url = %q, want path masked", got)
+	}
+	if got := values["report_slack_webhook_url"]; strings.Contains(got, "r3port") || !strings.HasPrefix(got, "https://hooks.slack.com") {
+		t.Errorf("report_slack_webhook_url = %q, want path masked", got)
+	}
+	if _, ok := values["monitor_batch_size"]; !ok {
+		t.Error("summary is missing monitor_batch_size")
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://ctmonitor:s3cret@db:5432/ct_monitor")
+	t.Setenv("MONITOR_REPROCESS_ON_IDLE", "true")
+	t.Setenv("RISK_WEIGHTS", "wildcard=0,domain_age_week=50")
+
+	settings := Load().Redacted()
+	if len(settings) != len(Load().LogAttrs()) {
+		t.Errorf("got %d settings, want one per LogAttrs entry", len(settings))
+	}
+	if got := settings["database_url"]; got != "postgres://ctmonitor:xxxxx@db:5432/xxxxx" {
+		t.Errorf("database_url = %q, want password and path masked", got)
+	}
+	if got := settings["monitor_interval"]; got != "1m0s" {
+		t.Errorf("monitor_interval = %q, want 1m0s", got)
+	}
+	if got := settings["monitor_min_not_before"]; got != "" {
+		t.Errorf("monitor_min_not_before = %q, want empty when unset", got)
+	}
+	if got := settings["monitor_reprocess_on_idle"]; got != "true" {
+		t.Errorf("monitor_reprocess_on_idle = %q, want true", got)
+	}
+	if got := settings["monitor_batch_size"]; got != "100" {
+		t.Errorf("monitor_batch_size = %q, want 100", got)
+	}
+	if got := settings["risk_weights"]; got != "domain_age_week=50,wildcard=0" {
+		t.Errorf("risk_weights = %q, want the pairs sorted by name", got)
+	}
+}
+
+func TestRedactURL(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"https://example.com", "https://example.com"},
+		{"https://example.com/hook?token=x", "https://example.com/xxxxx"},
+		{"postgres://user@db/ct", "postgres://user@db/xxxxx"},
+		{"not a url", "xxxxx"},
+	}
+	for _, tt := range tests {
+		if got := redactURL(tt.in); got != tt.want {
+			t.Errorf("redactURL(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLoad_BasePath(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://localhost/ct")
+	tests := []struct {
+		in, want string
+		valid    bool
+	}{
+		{"", "", true},
+		{"/", "", true},
+		{"/sisap", "/sisap", true},
+		{"/tools/sisap/", "/tools/sisap", true},
+		{"sisap", "", false},
+		{"/sisap/../admin", "", false},
+		{"//sisap", "", false},
+		{"/sisap?x=1", "", false},
+	}
+	for _, tt := range tests {
+		t.Setenv("BASE_PATH", tt.in)
+		c := Load()
+		err := c.Validate()
+		if c.BasePath != tt.want || (err == nil) != tt.valid {
+			t.Errorf("BASE_PATH=%q: BasePath = %q, Validate() = %v; want %q, valid %v", tt.in, c.BasePath, err, tt.want, tt.valid)
+		}
+	}
+}
+
+func writeSecret(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("write secret: %v", err)
+	}
+	return path
+}
+
+func TestLoad_SecretFiles(t *testing.T) {
+	t.Setenv("DATABASE_URL", "")
+	t.Setenv("DATABASE_URL_FILE", writeSecret(t, "postgres://u:p@db/ct\n"))
+	t.Setenv("NOTIFY_WEBHOOK_URL_FILE", writeSecret(t, "  https://hooks.example.com/x  "))
+
+	c := Load()
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Validate() = %v, want nil", err)
+	}
+	if c.DatabaseURL != "postgres://u:p@db/ct" {
+		t.Errorf("DatabaseURL = %q, want the trimmed file contents", c.DatabaseURL)
+	}
+	if c.NotifyWebhookURL != "https://hooks.example.com/x" {
+		t.Errorf("NotifyWebhookURL = %q, want the trimmed file contents", c.NotifyWebhookURL)
+	}
+}
+
+func TestLoad_SecretFileErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		plain string
+		file  func(t *testing.T) string
+		want  string
+	}{
+		{"both set", "postgres://db/ct", func(t *testing.T) string { return writeSecret(t, "postgres://db/other") },
+			"DATABASE_URL and DATABASE_URL_FILE are both set"},
+		{"unreadable file", "", func(t *testing.T) string { return filepath.Join(t.TempDir(), "missing") },
+			"DATABASE_URL_FILE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DATABASE_URL", tt.plain)
+			t.Setenv("DATABASE_URL_FILE", tt.file(t))
+
+			err := Load().Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate() = %v, want an error mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate_TLSRedirectNeedsCertificate(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://localhost/ct")
+	t.Setenv("TLS_REDIRECT_PORT", "80")
+
+	err := Load().Validate()
+	if err == nil || !strings.Contains(err.Error(), "TLS_REDIRECT_PORT requires") {
+		t.Errorf("Validate() = %v, want TLS_REDIRECT_PORT error", err)
+	}
+}
+
+func TestValidate_SuccessorNeedsIntervalEnd(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://localhost/ct")
+	t.Setenv("CT_LOG_SUCCESSOR_URL", "https://oak.ct.letsencrypt.org/2027h1")
+
+	err := Load().Validate()
+	if err == nil || !strings.Contains(err.Error(), "CT_LOG_SUCCESSOR_URL requires") {
+		t.Errorf("Validate() = %v, want CT_LOG_SUCCESSOR_URL error", err)
+	}
+}
+
+func TestValidate_QueuePolicyNeedsLargerBatch(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://localhost/ct")
+	t.Setenv("NOTIFY_QUEUE_POLICY", "drop")
+	t.Setenv("NOTIFY_BATCH_SIZE", "100")
+
+	err := Load().Validate()
+	if err == nil || !strings.Contains(err.Error(), "NOTIFY_BATCH_SIZE (100) must exceed NOTIFY_QUEUE_SIZE (100)") {
+		t.Errorf("Validate() = %v, want NOTIFY_BATCH_SIZE error", err)
+	}
+
+	// Blocking never overflows, so any batch size will do.
+	t.Setenv("NOTIFY_QUEUE_POLICY", "block")
+	if err := Load().Validate(); err != nil {
+		t.Errorf("Validate() with block = %v, want nil", err)
+	}
+}
+
+func TestValidate_TLSFiles(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	for _, f := range []string{certFile, keyFile} {
+		if err := os.WriteFile(f, []byte("pem"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		cert, key  string
+		wantTLS    bool
+		wantErrors []string
+	}{
+		{name: "plain HTTP"},
+		{name: "both present", cert: certFile, key: keyFile, wantTLS: true},
+		{name: "missing key file", cert: certFile, key: filepath.Join(dir, "missing.key"), wantTLS: true,
+			wantErrors: []string{"TLS_KEY_FILE"}},
+		{name: "directory", cert: dir, key: keyFile, wantTLS: true,
+			wantErrors: []string{"TLS_CERT_FILE: " + dir + " is a directory"}},
+		{name: "key unset", cert: certFile,
+			wantErrors: []string{"must be set together"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DATABASE_URL", "postgres://localhost/ct")
+			t.Setenv("TLS_CERT_FILE", tt.cert)
+			t.Setenv("TLS_KEY_FILE", tt.key)
+
+			c := Load()
+			if got := c.TLSEnabled(); got != tt.wantTLS {
+				t.Errorf("TLSEnabled() = %v, want %v", got, tt.wantTLS)
+			}
+			err := c.Validate()
+			if len(tt.wantErrors) == 0 && err != nil {
+				t.Errorf("Validate() = %v, want nil", err)
+			}
+			for _, want := range tt.wantErrors {
+				if err == nil || !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() = %v, want an error mentioning %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoad_FrontendDirDisablesCORSByDefault(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://localhost/ct")
+	t.Setenv("FRONTEND_DIR", "/srv/frontend")
+
+	if c := Load(); c.CORSAllowOrigin != "" {
+		t.Errorf("CORSAllowOrigin = %q, want empty when serving the frontend", c.CORSAllowOrigin)
+	}
+
+	t.Setenv("CORS_ALLOW_ORIGIN", "https://other.example")
+	if c := Load(); c.CORSAllowOrigin != "https://other.example" {
+		t.Errorf("CORSAllowOrigin = %q, want the explicit value", c.CORSAllowOrigin)
+	}
+}
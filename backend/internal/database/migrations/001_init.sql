@@ -42,3 +42,309 @@ INSERT INTO monitor_state (id) VALUES (1) ON CONFLICT (id) DO NOTHING;
 ALTER TABLE monitor_state ADD COLUMN IF NOT EXISTS parse_errors_in_last_cycle INTEGER NOT NULL DEFAULT 0;
 
 ALTER TABLE monitor_state ADD COLUMN IF NOT EXISTS last_error TEXT NOT NULL DEFAULT '';
+ALTER TABLE monitor_state ADD COLUMN IF NOT EXISTS last_error_first_seen TIMESTAMPTZ;
+ALTER TABLE monitor_state ADD COLUMN IF NOT EXISTS last_error_count INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS audit_log (
+    id          BIGSERIAL   PRIMARY KEY,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    actor       TEXT        NOT NULL,
+    action      TEXT        NOT NULL,
+    entity_type TEXT        NOT NULL,
+    entity_id   TEXT        NOT NULL DEFAULT '',
+    changes     JSONB       NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_created
+    ON audit_log(created_at DESC);
+
+ALTER TABLE matched_certificates ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'new';
+ALTER TABLE matched_certificates ADD COLUMN IF NOT EXISTS acknowledged_by TEXT NOT NULL DEFAULT '';
+ALTER TABLE matched_certificates ADD COLUMN IF NOT EXISTS acknowledged_at TIMESTAMPTZ;
+ALTER TABLE matched_certificates ADD COLUMN IF NOT EXISTS status_note TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_matched_certs_status
+    ON matched_certificates(status);
+
+ALTER TABLE matched_certificates ADD COLUMN IF NOT EXISTS matched_field TEXT NOT NULL DEFAULT '';
+ALTER TABLE matched_certificates ADD COLUMN IF NOT EXISTS is_precert BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- registrable_domain stays NULL until computed; rows stored before the
+-- column existed are filled in by a background backfill at startup.
+ALTER TABLE matched_certificates ADD COLUMN IF NOT EXISTS registrable_domain TEXT;
+ALTER TABLE matched_certificates ADD COLUMN IF NOT EXISTS registrable_domain_raw BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_matched_certs_registrable_domain
+    ON matched_certificates(registrable_domain);
+
+CREATE TABLE IF NOT EXISTS notification_outbox (
+    id              BIGSERIAL   PRIMARY KEY,
+    certificate_id  INTEGER     NOT NULL REFERENCES matched_certificates(id) ON DELETE CASCADE,
+    payload         JSONB       NOT NULL,
+    status          TEXT        NOT NULL DEFAULT 'pending',
+    attempts        INTEGER     NOT NULL DEFAULT 0,
+    last_error      TEXT        NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    sent_at         TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_outbox_pending
+    ON notification_outbox(next_attempt_at) WHERE status = 'pending';
+
+-- Extended key usages of the matched certificate; is_server_auth is false
+-- for client-auth, code-signing and similar certificates.
+ALTER TABLE matched_certificates ADD COLUMN IF NOT EXISTS ext_key_usages TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE matched_certificates ADD COLUMN IF NOT EXISTS is_server_auth BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- How the keyword is matched: anywhere in the domain, or only at a
+-- '.'/'-' boundary or the start/end of the domain.
+ALTER TABLE keywords ADD COLUMN IF NOT EXISTS match_mode TEXT NOT NULL DEFAULT 'substring';
+
+-- Trigram index for similarity search over matched domains
+-- (GET /certificates/similar), which surfaces typosquat clusters.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_matched_certs_domain_trgm
+    ON matched_certificates USING GIN (matched_domain gin_trgm_ops);
+
+-- When a (serial, keyword) match was first and last observed. With
+-- CERT_CONFLICT_STRATEGY=update a re-observation bumps last_seen_*;
+-- first_seen_* never change. Rows stored before these columns existed read
+-- them as ct_log_index/discovered_at.
+ALTER TABLE matched_certificates ADD COLUMN IF NOT EXISTS first_seen_index BIGINT;
+ALTER TABLE matched_certificates ADD COLUMN IF NOT EXISTS first_seen_at TIMESTAMPTZ;
+ALTER TABLE matched_certificates ADD COLUMN IF NOT EXISTS last_seen_index BIGINT;
+ALTER TABLE matched_certificates ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMPTZ;
+
+-- Signed webhooks (see internal/service/webhook). Each match is fanned out
+-- into one delivery per active webhook; event_id is the idempotency key
+-- receivers dedupe on, so the (webhook_id, event_id) pair is unique and a
+-- replayed fan-out adds nothing. Deliveries that exhaust the webhook's
+-- max_attempts stay in the dead state until pruned.
+CREATE TABLE IF NOT EXISTS webhooks (
+    id           SERIAL      PRIMARY KEY,
+    url          TEXT        NOT NULL,
+    secret       TEXT        NOT NULL,
+    max_attempts INTEGER     NOT NULL DEFAULT 5,
+    active       BOOLEAN     NOT NULL DEFAULT TRUE,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id              BIGSERIAL   PRIMARY KEY,
+    webhook_id      INTEGER     NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event_id        TEXT        NOT NULL,
+    event           TEXT        NOT NULL,
+    payload         JSONB       NOT NULL,
+    status          TEXT        NOT NULL DEFAULT 'pending',
+    attempts        INTEGER     NOT NULL DEFAULT 0,
+    last_error      TEXT        NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    sent_at         TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (webhook_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_pending
+    ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+
+-- Where an audited change came from: the X-Request-Id of the request (to
+-- correlate with request logs) and the client IP as resolved through
+-- TRUSTED_PROXIES. Both are empty for actions the server takes itself.
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS request_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS client_ip TEXT NOT NULL DEFAULT '';
+
+-- crt.sh history of the match's registrable domain, filled in after the
+-- insert by the optional enricher (CRTSH_ENABLED). NULL until looked up;
+-- historical_first_seen stays NULL when crt.sh knows no certificates.
+ALTER TABLE matched_certificates ADD COLUMN IF NOT EXISTS historical_cert_count INTEGER;
+ALTER TABLE matched_certificates ADD COLUMN IF NOT EXISTS historical_first_seen TIMESTAMPTZ;
+
+-- RDAP registration date of the match's registrable domain, filled in by
+-- the optional enricher (RDAP_ENABLED). domain_age_days is the domain's age
+-- when the match was first seen, kept as a column so listings can filter on
+-- it. domain_age_status is '' until looked up, then 'known', or 'unknown'
+-- when RDAP had no answer (no server for the TLD, no registration event,
+-- rate limited).
+ALTER TABLE matched_certificates ADD COLUMN IF NOT EXISTS domain_registered_at TIMESTAMPTZ;
+ALTER TABLE matched_certificates ADD COLUMN IF NOT EXISTS domain_age_days INTEGER;
+ALTER TABLE matched_certificates ADD COLUMN IF NOT EXISTS domain_age_status TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_matched_certificates_domain_age
+    ON matched_certificates(domain_age_days) WHERE domain_age_days IS NOT NULL;
+
+-- Whether the matched certificate chains to a trusted root (system pool plus
+-- the log's get-roots) through the chain logged with it: valid,
+-- unknown_issuer, expired_chain, or not_checked (verification off, rows
+-- stored before it existed).
+ALTER TABLE matched_certificates ADD COLUMN IF NOT EXISTS chain_status TEXT NOT NULL DEFAULT 'not_checked';
+
+-- Composite risk score (0-100) and the factors behind it (a JSON array of
+-- {factor, points, detail}), computed on insert and again when enrichment
+-- lands. Rows stored before scoring existed keep 0 until enriched. The
+-- index backs the certificate list's default order.
+ALTER TABLE matched_certificates ADD COLUMN IF NOT EXISTS risk_score INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE matched_certificates ADD COLUMN IF NOT EXISTS risk_breakdown JSONB NOT NULL DEFAULT '[]';
+
+CREATE INDEX IF NOT EXISTS idx_matched_certificates_risk
+    ON matched_certificates(risk_score DESC, discovered_at DESC);
+
+-- What kind of CA issued the match (service/issuer): free_automated, paid,
+-- enterprise or unknown. Set on insert from the issuer name; rows stored
+-- before classification existed stay unknown.
+ALTER TABLE matched_certificates ADD COLUMN IF NOT EXISTS issuer_class TEXT NOT NULL DEFAULT 'unknown';
+
+-- Keyword mutes (POST /keywords/{id}/mute). Until muted_until passes, a
+-- 'notifications' mute stores the keyword's matches without enqueueing
+-- notifications and a 'matching' mute keeps the monitor from matching it
+-- at all. Expired mutes are ignored rather than cleared.
+ALTER TABLE keywords ADD COLUMN IF NOT EXISTS muted_until TIMESTAMPTZ;
+ALTER TABLE keywords ADD COLUMN IF NOT EXISTS mute_scope TEXT NOT NULL DEFAULT '';
+
+-- Daily reports (service/report), one per calendar day in REPORT_TIMEZONE,
+-- kept so GET /reports/daily can serve them again. Regenerating a day
+-- replaces its row. The expression index backs the report's first-seen
+-- window.
+CREATE TABLE IF NOT EXISTS daily_reports (
+    report_date  DATE        PRIMARY KEY,
+    timezone     TEXT        NOT NULL,
+    report       JSONB       NOT NULL,
+    generated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_matched_certificates_first_seen
+    ON matched_certificates((COALESCE(first_seen_at, discovered_at)));
+
+-- Matches imported from crt.sh (service/history) have no entry in the
+-- monitored log: ct_log_index and the *_seen_index columns stay NULL and
+-- source tells them apart. first_seen_at is when crt.sh saw them logged.
+ALTER TABLE matched_certificates ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT 'ctlog';
+ALTER TABLE matched_certificates ALTER COLUMN ct_log_index DROP NOT NULL;
+
+-- Disabled keywords are never matched. The monitor disables a keyword on
+-- its own when it matches too large a share of the log for several cycles
+-- in a row (KEYWORD_MAX_MATCH_PERCENT), recording why; POST
+-- /keywords/{id}/enable clears both columns.
+ALTER TABLE keywords ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMPTZ;
+ALTER TABLE keywords ADD COLUMN IF NOT EXISTS disabled_reason TEXT NOT NULL DEFAULT '';
+
+-- SAN filters on the certificate list (?san=, ?min_sans=, ?max_sans=).
+-- lower_sans lowercases a SAN array so the GIN index can answer an exact,
+-- case-insensitive SAN lookup through @>; the expression index serves the
+-- SAN count ranges.
+CREATE OR REPLACE FUNCTION lower_sans(sans TEXT[]) RETURNS TEXT[]
+    LANGUAGE sql IMMUTABLE PARALLEL SAFE
+    AS $$ SELECT array_agg(lower(s)) FROM unnest(sans) AS s $$;
+
+CREATE INDEX IF NOT EXISTS idx_matched_certificates_sans_lower
+    ON matched_certificates USING GIN (lower_sans(sans));
+CREATE INDEX IF NOT EXISTS idx_matched_certificates_san_count
+    ON matched_certificates((cardinality(sans)));
+
+-- Keyword groups bundle variants of one brand under shared settings. A
+-- group's match_mode, mute and disable are defaults for its keywords: a
+-- keyword's own setting wins, and a keyword created in a group without a
+-- match mode stores '' to take the group's. Deleting a group ungroups its
+-- keywords unless the request deletes them too.
+CREATE TABLE IF NOT EXISTS keyword_groups (
+    id              SERIAL PRIMARY KEY,
+    name            TEXT        NOT NULL UNIQUE,
+    match_mode      TEXT        NOT NULL DEFAULT '',
+    muted_until     TIMESTAMPTZ,
+    mute_scope      TEXT        NOT NULL DEFAULT '',
+    disabled_at     TIMESTAMPTZ,
+    disabled_reason TEXT        NOT NULL DEFAULT '',
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE keywords ADD COLUMN IF NOT EXISTS group_id INTEGER
+    REFERENCES keyword_groups(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_keywords_group ON keywords(group_id) WHERE group_id IS NOT NULL;
+
+-- Keyword alternatives: further values (paypal, pp, paypa1) matched as
+-- the same keyword, so a certificate hitting several still yields one
+-- match. matched_value records which value the stored match hit; rows
+-- stored before alternatives existed keep ''.
+ALTER TABLE keywords ADD COLUMN IF NOT EXISTS alternatives TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE matched_certificates ADD COLUMN IF NOT EXISTS matched_value TEXT NOT NULL DEFAULT '';
+
+-- Consolidated certificates: one row per certificate, keyed by the SHA-256
+-- of issuer, a zero byte and serial (stix.Fingerprint), with the keywords
+-- that matched it in certificate_matches. matched_certificates stays the
+-- per-keyword store every other query reads; each new match is linked
+-- here as it is stored, and the inserts below backfill those stored
+-- before (only unlinked matches, so later boots stay cheap).
+-- reason says which field contained which keyword value.
+CREATE TABLE IF NOT EXISTS certificates (
+    id            SERIAL PRIMARY KEY,
+    fingerprint   TEXT        NOT NULL UNIQUE,
+    serial_number TEXT        NOT NULL,
+    common_name   TEXT        NOT NULL,
+    sans          TEXT[]      NOT NULL DEFAULT '{}',
+    issuer        TEXT        NOT NULL,
+    not_before    TIMESTAMPTZ,
+    not_after     TIMESTAMPTZ,
+    first_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS certificate_matches (
+    certificate_id INTEGER NOT NULL REFERENCES certificates(id) ON DELETE CASCADE,
+    keyword_id     INTEGER NOT NULL REFERENCES keywords(id) ON DELETE CASCADE,
+    match_id       INTEGER NOT NULL UNIQUE REFERENCES matched_certificates(id) ON DELETE CASCADE,
+    matched_domain TEXT    NOT NULL,
+    reason         TEXT    NOT NULL,
+    PRIMARY KEY (certificate_id, keyword_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_certificate_matches_keyword ON certificate_matches(keyword_id);
+
+INSERT INTO certificates (fingerprint, serial_number, common_name, sans, issuer, not_before, not_after, first_seen_at)
+SELECT DISTINCT ON (fp) fp, serial_number, common_name, sans, issuer, not_before, not_after,
+       COALESCE(first_seen_at, discovered_at)
+FROM (
+    SELECT encode(sha256(convert_to(issuer, 'UTF8') || '\x00'::bytea || convert_to(serial_number, 'UTF8')), 'hex') AS fp, *
+    FROM matched_certificates m
+    WHERE NOT EXISTS (SELECT 1 FROM certificate_matches cm WHERE cm.match_id = m.id)
+) mc
+ORDER BY fp, id
+ON CONFLICT (fingerprint) DO NOTHING;
+
+INSERT INTO certificate_matches (certificate_id, keyword_id, match_id, matched_domain, reason)
+SELECT c.id, mc.keyword_id, mc.id, mc.matched_domain,
+       mc.matched_field || CASE WHEN mc.matched_value <> '' THEN ' contains ' || mc.matched_value ELSE '' END
+FROM matched_certificates mc
+JOIN certificates c ON c.fingerprint =
+    encode(sha256(convert_to(mc.issuer, 'UTF8') || '\x00'::bytea || convert_to(mc.serial_number, 'UTF8')), 'hex')
+WHERE NOT EXISTS (SELECT 1 FROM certificate_matches cm WHERE cm.match_id = mc.id)
+ON CONFLICT DO NOTHING;
+
+-- CT log rollover: the log the cursor follows after the monitor switched
+-- away from CT_LOG_URL (honoured on start while CT_LOG_URL still equals
+-- ct_log_configured_url), and since when the current log looks frozen.
+ALTER TABLE monitor_state ADD COLUMN IF NOT EXISTS ct_log_url TEXT NOT NULL DEFAULT '';
+ALTER TABLE monitor_state ADD COLUMN IF NOT EXISTS ct_log_configured_url TEXT NOT NULL DEFAULT '';
+ALTER TABLE monitor_state ADD COLUMN IF NOT EXISTS log_frozen_since TIMESTAMPTZ;
+
+-- Keyword active windows: a keyword is matched from active_from until
+-- active_until; NULL leaves that side open. Outside the window it is kept,
+-- with its matches, but skipped by the monitor.
+ALTER TABLE keywords ADD COLUMN IF NOT EXISTS active_from TIMESTAMPTZ;
+ALTER TABLE keywords ADD COLUMN IF NOT EXISTS active_until TIMESTAMPTZ;
+
+-- When a match last changed, so list ETags notice in-place edits (triage
+-- notes, enrichment, a ConflictUpdate refresh) that leave every count
+-- alone. Each write path sets it to clock_timestamp().
+ALTER TABLE matched_certificates ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+-- The CT log a match's indices point into: ct_log_index and last_seen_index
+-- into ct_log_url, first_seen_index into first_seen_log_url. Every log
+-- numbers its entries from 0, so index ranges are only meaningful within
+-- one; rows stored before the log was recorded keep '' and count as
+-- belonging to any log.
+ALTER TABLE matched_certificates ADD COLUMN IF NOT EXISTS ct_log_url TEXT NOT NULL DEFAULT '';
+ALTER TABLE matched_certificates ADD COLUMN IF NOT EXISTS first_seen_log_url TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_matched_certificates_log_index
+    ON matched_certificates(ct_log_url, ct_log_index);
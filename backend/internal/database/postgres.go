@@ -2,13 +2,24 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/exaring/otelpgx"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
 )
 
-func Connect(databaseURL string) (*pgxpool.Pool, error) {
+// Connect opens a pool and checks the database is reachable. A positive
+// queryTimeout becomes the statement_timeout of every connection, so the
+// server cancels any query running longer (SQLSTATE 57014, see
+// IsQueryTimeout) instead of letting it hold a connection indefinitely.
+func Connect(databaseURL string, queryTimeout time.Duration) (*pgxpool.Pool, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -16,6 +27,12 @@ func Connect(databaseURL string) (*pgxpool.Pool, error) {
 	if err != nil {
 		return nil, fmt.Errorf("parse database URL: %w", err)
 	}
+	// Queries become child spans of the request or monitor cycle that
+	// issued them; a no-op unless tracing is enabled.
+	config.ConnConfig.Tracer = otelpgx.NewTracer()
+	if queryTimeout > 0 {
+		config.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(queryTimeout.Milliseconds(), 10)
+	}
 
 	pool, err := pgxpool.NewWithConfig(ctx, config)
 	if err != nil {
@@ -29,3 +46,26 @@ func Connect(databaseURL string) (*pgxpool.Pool, error) {
 
 	return pool, nil
 }
+
+// IsQueryTimeout reports whether err is a query canceled by the
+// statement_timeout set in Connect.
+func IsQueryTimeout(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "57014" && strings.Contains(pgErr.Message, "statement timeout")
+}
+
+// PoolStats snapshots pool's connection statistics. An empty-acquire count
+// that keeps growing means requests are waiting for a free connection.
+func PoolStats(pool *pgxpool.Pool) model.PoolStats {
+	s := pool.Stat()
+	return model.PoolStats{
+		AcquiredConns:        s.AcquiredConns(),
+		IdleConns:            s.IdleConns(),
+		TotalConns:           s.TotalConns(),
+		MaxConns:             s.MaxConns(),
+		AcquireCount:         s.AcquireCount(),
+		EmptyAcquireCount:    s.EmptyAcquireCount(),
+		CanceledAcquireCount: s.CanceledAcquireCount(),
+		AcquireDurationMS:    s.AcquireDuration().Milliseconds(),
+	}
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestConnect_QueryTimeoutCancelsSlowQueries(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set; skipping database integration test")
+	}
+
+	pool, err := Connect(url, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	ctx := context.Background()
+	if _, err := pool.Exec(ctx, `SELECT 1`); err != nil {
+		t.Fatalf("fast query: %v", err)
+	}
+	_, err = pool.Exec(ctx, `SELECT pg_sleep(2)`)
+	if !IsQueryTimeout(err) {
+		t.Fatalf("slow query error = %v, want a statement timeout", err)
+	}
+}
+
+func TestIsQueryTimeout(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"statement timeout", &pgconn.PgError{Code: "57014", Message: "canceling statement due to statement timeout"}, true},
+		{"user cancel", &pgconn.PgError{Code: "57014", Message: "canceling statement due to user request"}, false},
+		{"other error", &pgconn.PgError{Code: "23505", Message: "duplicate key"}, false},
+		{"not postgres", errors.New("boom"), false},
+		{"nil", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsQueryTimeout(tt.err); got != tt.want {
+				t.Errorf("IsQueryTimeout(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,5 @@
+// Package e2e holds end-to-end tests: the real handlers and monitor behind a
+// chi router, reading a fakectlog server. They run against in-memory stores
+// by default; with TEST_DATABASE_URL set the same flow also runs against
+// the PostgreSQL repositories.
+package e2e
@@ -0,0 +1,224 @@
+package e2e
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/database"
+	"github.com/andres10976/SISAP-PoC/backend/internal/fakectlog"
+	"github.com/andres10976/SISAP-PoC/backend/internal/logging"
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/repository"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/audit"
+)
+
+// newLog returns a log of one batch whose every fifth entry carries a
+// keyword, alternating paypal and amazon: paypal at 0, 10, 20, ...
+func newLog(opts ...fakectlog.Option) *fakectlog.Log {
+	return fakectlog.New(append([]fakectlog.Option{
+		fakectlog.WithTreeSize(batchSize),
+		fakectlog.WithKeywords("paypal", "amazon"),
+		fakectlog.WithMatchEvery(5),
+	}, opts...)...)
+}
+
+// storedIndices returns the CT log indices of the stored matches, ascending.
+func storedIndices(certs []model.MatchedCertificate) []int64 {
+	var out []int64
+	for _, c := range certs {
+		out = append(out, c.CTLogIndex)
+	}
+	slices.Sort(out)
+	return out
+}
+
+// runFlow creates a keyword, lets the monitor work through the log as it
+// grows, and checks the matches through the list and export endpoints.
+func runFlow(t *testing.T, h *harness) {
+	var kw model.Keyword
+	h.mustDo(t, http.MethodPost, "/keywords", `{"value":"paypal"}`, http.StatusCreated, &kw)
+	h.mustDo(t, http.MethodPost, "/keywords", `{"value":"paypal"}`, http.StatusConflict, nil)
+	h.mustDo(t, http.MethodPost, "/monitor/start", "", http.StatusOK, nil)
+
+	eventually(t, "the first batch's matches", func() bool { return len(h.certificates(t)) == 2 })
+	h.log.Grow(batchSize)
+	eventually(t, "the grown tree's matches", func() bool { return len(h.certificates(t)) == 4 })
+
+	certs := h.certificates(t)
+	if got := storedIndices(certs); !slices.Equal(got, []int64{0, 10, 20, 30}) {
+		t.Errorf("stored indices = %v, want [0 10 20 30]", got)
+	}
+	for _, c := range certs {
+		if c.KeywordID != kw.ID || c.MatchedDomain != h.log.Domain(c.CTLogIndex) {
+			t.Errorf("match %d = keyword %d, domain %q; want %d, %q",
+				c.CTLogIndex, c.KeywordID, c.MatchedDomain, kw.ID, h.log.Domain(c.CTLogIndex))
+		}
+	}
+
+	status, export := h.do(t, http.MethodGet, "/certificates/export", "")
+	if status != http.StatusOK {
+		t.Fatalf("export status = %d, want 200", status)
+	}
+	for _, i := range []int64{0, 10, 20, 30} {
+		if !strings.Contains(string(export), h.log.Domain(i)) {
+			t.Errorf("export is missing %s", h.log.Domain(i))
+		}
+	}
+	if strings.Contains(string(export), "amazon") {
+		t.Error("export contains amazon domains, which match no keyword")
+	}
+
+	h.mustDo(t, http.MethodPost, "/monitor/stop", "", http.StatusOK, nil)
+	state := h.status(t)
+	if state.IsRunning || state.LastProcessedIndex != 2*batchSize {
+		t.Errorf("status running/last index = %v/%d, want false/%d", state.IsRunning, state.LastProcessedIndex, 2*batchSize)
+	}
+}
+
+func TestFlow(t *testing.T) {
+	runFlow(t, newHarness(t, memStores(newMemStore()), newLog()))
+}
+
+func TestFlow_Postgres(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set; skipping PostgreSQL end-to-end test")
+	}
+	pool, err := database.Connect(url, 0)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(pool.Close)
+	if err := database.Migrate(pool); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	ctx := context.Background()
+	for _, stmt := range []string{
+		`TRUNCATE keywords, keyword_groups, matched_certificates, certificates, audit_log, notification_outbox RESTART IDENTITY CASCADE`,
+		`DELETE FROM monitor_state`,
+		`INSERT INTO monitor_state (id) VALUES (1)`,
+	} {
+		if _, err := pool.Exec(ctx, stmt); err != nil {
+			t.Fatalf("reset database: %v", err)
+		}
+	}
+
+	runFlow(t, newHarness(t, stores{
+		keywords: repository.NewKeywordRepository(pool),
+		certs:    repository.NewCertificateRepository(pool),
+		state:    repository.NewMonitorRepository(pool),
+		audit:    audit.NewRecorder(repository.NewAuditRepository(pool)),
+	}, newLog()))
+}
+
+func TestLogRateLimited(t *testing.T) {
+	store := newMemStore()
+	h := newHarness(t, memStores(store), newLog())
+	h.log.FailNext(3, http.StatusTooManyRequests)
+
+	h.mustDo(t, http.MethodPost, "/keywords", `{"value":"paypal"}`, http.StatusCreated, nil)
+	h.mustDo(t, http.MethodPost, "/monitor/start", "", http.StatusOK, nil)
+	eventually(t, "matches after the log recovers", func() bool { return len(h.certificates(t)) == 2 })
+	eventually(t, "the error to clear", func() bool { return h.status(t).LastError == "" })
+
+	errs := store.errorLog()
+	if len(errs) != 3 {
+		t.Fatalf("recorded errors = %q, want 3", errs)
+	}
+	for _, e := range errs {
+		if !strings.Contains(e, "status 429") {
+			t.Errorf("recorded error %q, want a 429", e)
+		}
+	}
+}
+
+func TestDuplicateMatchesStoredOnce(t *testing.T) {
+	h := newHarness(t, memStores(newMemStore()), newLog())
+
+	h.mustDo(t, http.MethodPost, "/keywords", `{"value":"paypal"}`, http.StatusCreated, nil)
+	h.mustDo(t, http.MethodPost, "/monitor/start", "", http.StatusOK, nil)
+	eventually(t, "the first batch's matches", func() bool { return len(h.certificates(t)) == 2 })
+	h.mustDo(t, http.MethodPost, "/monitor/stop", "", http.StatusOK, nil)
+
+	// Rescanning the same entries hits the (serial, keyword) conflict.
+	stats, err := h.mon.Backfill(context.Background(), 0, batchSize-1)
+	if err != nil {
+		t.Fatalf("Backfill() error = %v", err)
+	}
+	if stats.Matches != 2 {
+		t.Errorf("backfill matches = %d, want 2", stats.Matches)
+	}
+	if got := storedIndices(h.certificates(t)); !slices.Equal(got, []int64{0, 10}) {
+		t.Errorf("stored indices after rescan = %v, want [0 10]", got)
+	}
+}
+
+func TestStoreFailureSkipsMatch(t *testing.T) {
+	store := newMemStore()
+	store.createErr = func(cert *model.MatchedCertificate) error {
+		if cert.CTLogIndex == 0 {
+			return errors.New("connection reset by peer")
+		}
+		return nil
+	}
+	h := newHarness(t, memStores(store), newLog(fakectlog.WithMalformedEvery(7)))
+
+	h.mustDo(t, http.MethodPost, "/keywords", `{"value":"paypal"}`, http.StatusCreated, nil)
+	h.mustDo(t, http.MethodPost, "/monitor/start", "", http.StatusOK, nil)
+	eventually(t, "the batch to finish", func() bool { return h.status(t).LastProcessedIndex == batchSize })
+
+	// The failed insert is logged and dropped; the rest of the batch,
+	// including entries after unparsable ones, is still stored.
+	if got := storedIndices(h.certificates(t)); !slices.Equal(got, []int64{10}) {
+		t.Errorf("stored indices = %v, want [10]", got)
+	}
+	if state := h.status(t); state.ParseErrorsInLastCycle != 2 {
+		t.Errorf("parse errors = %d, want 2 (entries 6 and 13)", state.ParseErrorsInLastCycle)
+	}
+}
+
+func TestKeywordDeleteIsAudited(t *testing.T) {
+	store := newMemStore()
+	st := memStores(store)
+	st.audit = audit.NewRecorder(auditStore{store})
+	h := newHarness(t, st, newLog())
+
+	var kw model.Keyword
+	h.mustDo(t, http.MethodPost, "/keywords", `{"value":"paypal"}`, http.StatusCreated, &kw)
+	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/api/v1/keywords/%d", h.api.URL, kw.ID), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(logging.RequestIDHeader, "e2e-delete-1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete status = %d, want 204", resp.StatusCode)
+	}
+
+	entries := store.auditLog()
+	if len(entries) != 2 {
+		t.Fatalf("audit entries = %+v, want a create and a delete", entries)
+	}
+	del := entries[1]
+	if del.Action != model.AuditActionDelete || del.EntityType != model.AuditEntityKeyword || del.EntityID != strconv.Itoa(kw.ID) {
+		t.Errorf("entry = %+v, want the delete of keyword %d", del, kw.ID)
+	}
+	if del.RequestID != "e2e-delete-1" || del.ClientIP != "127.0.0.1" || del.Actor != audit.Anonymous {
+		t.Errorf("request_id/client_ip/actor = %q/%q/%q, want e2e-delete-1/127.0.0.1/%s",
+			del.RequestID, del.ClientIP, del.Actor, audit.Anonymous)
+	}
+	if entries[0].RequestID == "" || entries[0].RequestID == del.RequestID {
+		t.Errorf("create request_id = %q, want a generated ID of its own", entries[0].RequestID)
+	}
+}
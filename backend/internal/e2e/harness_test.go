@@ -0,0 +1,186 @@
+package e2e
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/fakectlog"
+	"github.com/andres10976/SISAP-PoC/backend/internal/handler"
+	"github.com/andres10976/SISAP-PoC/backend/internal/middleware"
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/repository"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/ctlog"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/monitor"
+)
+
+// Harness settings: the monitor reads batchSize entries every
+// monitorInterval, and eventually gives up after waitTimeout.
+const (
+	batchSize       = 20
+	monitorInterval = 10 * time.Millisecond
+	waitTimeout     = 5 * time.Second
+)
+
+type keywordRepo interface {
+	List(ctx context.Context) ([]model.Keyword, error)
+	Create(ctx context.Context, value, mode string, groupID *int, alternatives []string, activeFrom, activeUntil *time.Time) (*model.Keyword, error)
+	SetGroup(ctx context.Context, id int, groupID *int) (*model.Keyword, error)
+	SetAlternatives(ctx context.Context, id int, alternatives []string) (*model.Keyword, error)
+	Delete(ctx context.Context, id int) (*model.Keyword, error)
+	Mute(ctx context.Context, id int, until time.Time, scope string) (*model.Keyword, error)
+	Unmute(ctx context.Context, id int) (*model.Keyword, error)
+	Enable(ctx context.Context, id int) (*model.Keyword, error)
+	CreateMany(ctx context.Context, values []string) (int, error)
+}
+
+type certRepo interface {
+	Create(ctx context.Context, cert *model.MatchedCertificate) error
+	ListPaginated(ctx context.Context, page, perPage int, filter repository.CertificateFilter) ([]model.MatchedCertificate, int, error)
+	ListSince(ctx context.Context, limit int, filter repository.CertificateFilter) ([]model.MatchedCertificate, int, error)
+	ExportEach(ctx context.Context, filter repository.CertificateFilter, fn func(model.MatchedCertificate) error) error
+	Version(ctx context.Context, filter repository.CertificateFilter) (string, error)
+	DistinctIssuers(ctx context.Context, limit int) ([]string, error)
+	SimilarDomains(ctx context.Context, domain string, threshold float64) ([]model.SimilarDomain, error)
+	GetByID(ctx context.Context, id int) (*model.MatchedCertificate, error)
+	DeleteWhere(ctx context.Context, filter repository.CertificateFilter) (int64, error)
+	ListConsolidated(ctx context.Context, page, perPage int, filter repository.ConsolidatedFilter) ([]model.Certificate, int, error)
+}
+
+type stateRepo interface {
+	Get(ctx context.Context) (*model.MonitorState, error)
+	Update(ctx context.Context, state *model.MonitorState) error
+	SetRunning(ctx context.Context, running bool) error
+	SetError(ctx context.Context, errMsg string) error
+	ResetCycleStats(ctx context.Context) error
+	SetLogFrozen(ctx context.Context, since *time.Time) error
+	SwitchLog(ctx context.Context, url, configuredURL string, index, treeSize int64) error
+}
+
+type auditRecorder interface {
+	Record(ctx context.Context, action, entityType, entityID string, changes map[string]model.AuditChange)
+}
+
+// stores is what the harness wires the handlers and the monitor to.
+type stores struct {
+	keywords keywordRepo
+	certs    certRepo
+	state    stateRepo
+	audit    auditRecorder
+}
+
+func memStores(s *memStore) stores {
+	return stores{keywordStore{s}, certStore{s}, stateStore{s}, discardAudit{}}
+}
+
+type discardAudit struct{}
+
+func (discardAudit) Record(context.Context, string, string, string, map[string]model.AuditChange) {}
+
+// harness is the API, as cmd/server routes it, over a fake CT log.
+type harness struct {
+	log *fakectlog.Log
+	mon *monitor.Monitor
+	api *httptest.Server
+}
+
+func newHarness(t *testing.T, st stores, log *fakectlog.Log) *harness {
+	t.Helper()
+	logSrv := httptest.NewServer(log)
+	t.Cleanup(logSrv.Close)
+
+	mon := monitor.New(ctlog.NewClient(logSrv.URL), st.keywords, st.certs, st.state,
+		batchSize, monitorInterval, false)
+	t.Cleanup(func() { mon.Stop(context.Background()) })
+
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(middleware.ClientIP(nil))
+	r.Use(middleware.Recovery)
+	r.Route("/api/v1", func(r chi.Router) {
+		kw := handler.NewKeywordHandler(st.keywords, st.audit)
+		mh := handler.NewMonitorHandler(mon, st.state, st.audit)
+		kw.RegisterRoutes(r)
+		kw.RegisterAdminRoutes(r)
+		handler.NewCertificateHandler(st.certs, st.audit).RegisterRoutes(r)
+		mh.RegisterRoutes(r)
+		mh.RegisterAdminRoutes(r)
+	})
+	api := httptest.NewServer(r)
+	t.Cleanup(api.Close)
+
+	return &harness{log: log, mon: mon, api: api}
+}
+
+// do sends a request to the API and returns the status and body.
+func (h *harness) do(t *testing.T, method, path, body string) (int, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(method, h.api.URL+"/api/v1"+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("%s %s: read body: %v", method, path, err)
+	}
+	return resp.StatusCode, data
+}
+
+// mustDo is do, failing the test unless the API answers want.
+func (h *harness) mustDo(t *testing.T, method, path, body string, want int, out any) {
+	t.Helper()
+	status, data := h.do(t, method, path, body)
+	if status != want {
+		t.Fatalf("%s %s: status %d, want %d: %s", method, path, status, want, data)
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			t.Fatalf("%s %s: decode %s: %v", method, path, data, err)
+		}
+	}
+}
+
+// certificates lists the stored matches, newest first.
+func (h *harness) certificates(t *testing.T) []model.MatchedCertificate {
+	t.Helper()
+	var page struct {
+		Certificates []model.MatchedCertificate `json:"certificates"`
+	}
+	h.mustDo(t, http.MethodGet, "/certificates?per_page=100", "", http.StatusOK, &page)
+	return page.Certificates
+}
+
+// status returns the monitor state from GET /monitor/status.
+func (h *harness) status(t *testing.T) model.MonitorState {
+	t.Helper()
+	var state model.MonitorState
+	h.mustDo(t, http.MethodGet, "/monitor/status", "", http.StatusOK, &state)
+	return state
+}
+
+// eventually polls cond until it holds, failing the test after waitTimeout.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(waitTimeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(monitorInterval)
+	}
+}
@@ -0,0 +1,394 @@
+package e2e
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/repository"
+)
+
+// memStore stands in for the keyword, certificate and monitor state
+// repositories. It mirrors the behavior the handlers and the monitor rely
+// on (duplicate keywords fail with a unique violation, a match already
+// stored for its keyword leaves the ID zero) and applies the KeywordID,
+// Status, SinceID and Issuer filters; the others are ignored.
+type memStore struct {
+	mu       sync.Mutex
+	keywords []model.Keyword
+	lastKwID int
+	certs    []model.MatchedCertificate
+	state    model.MonitorState
+	// errors lists every non-empty SetError message, in order.
+	errors []string
+	// createErr, when set, fails Create for the certificates it returns
+	// an error for.
+	createErr func(cert *model.MatchedCertificate) error
+	audit     []model.AuditEntry
+}
+
+func newMemStore() *memStore {
+	return &memStore{}
+}
+
+// The repositories share method names (Create, List, Get, ...), so each
+// is a view of the one memStore.
+
+// keywordStore exposes the keyword methods.
+type keywordStore struct{ *memStore }
+
+func (k keywordStore) List(ctx context.Context) ([]model.Keyword, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return slices.Clone(k.keywords), nil
+}
+
+func (k keywordStore) Create(ctx context.Context, value, mode string, groupID *int, alternatives []string, activeFrom, activeUntil *time.Time) (*model.Keyword, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if groupID != nil {
+		return nil, repository.ErrGroupNotFound // the memory store has no groups
+	}
+	kw, err := k.createKeyword(value, mode)
+	if err != nil {
+		return nil, err
+	}
+	stored := &k.keywords[len(k.keywords)-1]
+	stored.ActiveFrom, stored.ActiveUntil = activeFrom, activeUntil
+	return k.setAlternatives(kw.ID, alternatives)
+}
+
+func (k keywordStore) SetGroup(ctx context.Context, id int, groupID *int) (*model.Keyword, error) {
+	return nil, repository.ErrGroupNotFound
+}
+
+func (k keywordStore) SetAlternatives(ctx context.Context, id int, alternatives []string) (*model.Keyword, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.setAlternatives(id, alternatives)
+}
+
+// setAlternatives mirrors the repository: an alternative equal to the
+// keyword's value is dropped.
+func (s *memStore) setAlternatives(id int, alternatives []string) (*model.Keyword, error) {
+	i := slices.IndexFunc(s.keywords, func(kw model.Keyword) bool { return kw.ID == id })
+	if i < 0 {
+		return nil, repository.ErrNotFound
+	}
+	s.keywords[i].Alternatives = slices.DeleteFunc(slices.Clone(alternatives), func(a string) bool {
+		return strings.EqualFold(a, s.keywords[i].Value)
+	})
+	kw := s.keywords[i]
+	return &kw, nil
+}
+
+func (s *memStore) createKeyword(value, mode string) (*model.Keyword, error) {
+	for _, kw := range s.keywords {
+		if strings.EqualFold(kw.Value, value) {
+			return nil, &pgconn.PgError{Code: "23505", Message: "duplicate key value violates unique constraint"}
+		}
+	}
+	s.lastKwID++
+	kw := model.Keyword{ID: s.lastKwID, Value: value, MatchMode: mode, CreatedAt: time.Now()}
+	s.keywords = append(s.keywords, kw)
+	return &kw, nil
+}
+
+func (k keywordStore) Delete(ctx context.Context, id int) (*model.Keyword, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	i := slices.IndexFunc(k.keywords, func(kw model.Keyword) bool { return kw.ID == id })
+	if i < 0 {
+		return nil, repository.ErrNotFound
+	}
+	kw := k.keywords[i]
+	k.keywords = slices.Delete(k.keywords, i, i+1)
+	return &kw, nil
+}
+
+func (k keywordStore) Mute(ctx context.Context, id int, until time.Time, scope string) (*model.Keyword, error) {
+	return k.setMute(id, &until, scope)
+}
+
+func (k keywordStore) Unmute(ctx context.Context, id int) (*model.Keyword, error) {
+	return k.setMute(id, nil, "")
+}
+
+func (k keywordStore) setMute(id int, until *time.Time, scope string) (*model.Keyword, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	i := slices.IndexFunc(k.keywords, func(kw model.Keyword) bool { return kw.ID == id })
+	if i < 0 {
+		return nil, repository.ErrNotFound
+	}
+	k.keywords[i].MutedUntil, k.keywords[i].MuteScope = until, scope
+	kw := k.keywords[i]
+	return &kw, nil
+}
+
+func (k keywordStore) Enable(ctx context.Context, id int) (*model.Keyword, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	i := slices.IndexFunc(k.keywords, func(kw model.Keyword) bool { return kw.ID == id })
+	if i < 0 {
+		return nil, repository.ErrNotFound
+	}
+	k.keywords[i].DisabledAt, k.keywords[i].DisabledReason = nil, ""
+	kw := k.keywords[i]
+	return &kw, nil
+}
+
+func (k keywordStore) CreateMany(ctx context.Context, values []string) (int, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	created := 0
+	for _, v := range values {
+		if _, err := k.createKeyword(v, model.MatchModeSubstring); err == nil {
+			created++
+		}
+	}
+	return created, nil
+}
+
+// certStore exposes the certificate methods.
+type certStore struct{ *memStore }
+
+func (c certStore) Create(ctx context.Context, cert *model.MatchedCertificate) error {
+	s := c.memStore
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.createErr != nil {
+		if err := s.createErr(cert); err != nil {
+			return err
+		}
+	}
+	for _, existing := range s.certs {
+		if existing.SerialNumber == cert.SerialNumber && existing.KeywordID == cert.KeywordID {
+			return nil
+		}
+	}
+	cert.ID = len(s.certs) + 1
+	cert.DiscoveredAt = time.Now()
+	cert.FirstSeenIndex, cert.FirstSeenAt = cert.CTLogIndex, cert.DiscoveredAt
+	cert.LastSeenIndex, cert.LastSeenAt = cert.CTLogIndex, cert.DiscoveredAt
+	cert.Status = model.CertStatusNew
+	cert.SANCount = len(cert.SANs)
+	s.certs = append(s.certs, *cert)
+	return nil
+}
+
+func (c certStore) filtered(f repository.CertificateFilter) []model.MatchedCertificate {
+	var out []model.MatchedCertificate
+	for _, cert := range c.certs {
+		if (f.KeywordID > 0 && cert.KeywordID != f.KeywordID) ||
+			(f.Status != "" && cert.Status != f.Status) ||
+			cert.ID <= f.SinceID ||
+			(f.Issuer != "" && cert.Issuer != f.Issuer) {
+			continue
+		}
+		out = append(out, cert)
+	}
+	return out
+}
+
+func (c certStore) ListPaginated(ctx context.Context, page, perPage int, f repository.CertificateFilter) ([]model.MatchedCertificate, int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	certs := c.filtered(f)
+	slices.Reverse(certs) // newest first
+	from := min((page-1)*perPage, len(certs))
+	to := min(from+perPage, len(certs))
+	return certs[from:to], len(certs), nil
+}
+
+func (c certStore) ListSince(ctx context.Context, limit int, f repository.CertificateFilter) ([]model.MatchedCertificate, int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	certs := c.filtered(f)
+	return certs[:min(limit, len(certs))], len(certs), nil
+}
+
+func (c certStore) ExportEach(ctx context.Context, f repository.CertificateFilter, fn func(model.MatchedCertificate) error) error {
+	c.mu.Lock()
+	certs := c.filtered(f)
+	c.mu.Unlock()
+	for _, cert := range certs {
+		if err := fn(cert); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c certStore) Version(ctx context.Context, f repository.CertificateFilter) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return fmt.Sprintf("%d", len(c.filtered(f))), nil
+}
+
+func (c certStore) DistinctIssuers(ctx context.Context, limit int) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := make(map[string]int)
+	for _, cert := range c.certs {
+		if cert.Issuer != "" {
+			counts[cert.Issuer]++
+		}
+	}
+	issuers := make([]string, 0, len(counts))
+	for issuer := range counts {
+		issuers = append(issuers, issuer)
+	}
+	slices.SortFunc(issuers, func(a, b string) int {
+		return cmp.Or(cmp.Compare(counts[b], counts[a]), cmp.Compare(a, b))
+	})
+	return issuers[:min(limit, len(issuers))], nil
+}
+
+func (c certStore) GetByID(ctx context.Context, id int) (*model.MatchedCertificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if id < 1 || id > len(c.certs) {
+		return nil, repository.ErrNotFound
+	}
+	cert := c.certs[id-1]
+	return &cert, nil
+}
+
+// SimilarDomains needs pg_trgm; the flows here do not search.
+func (c certStore) SimilarDomains(ctx context.Context, domain string, threshold float64) ([]model.SimilarDomain, error) {
+	return nil, errors.New("similar domain search needs PostgreSQL")
+}
+
+// DeleteWhere would break GetByID's id-as-index lookup; the flows here do
+// not delete.
+func (c certStore) DeleteWhere(ctx context.Context, f repository.CertificateFilter) (int64, error) {
+	return 0, errors.New("bulk delete is not supported by the in-memory store")
+}
+
+func (c certStore) ListConsolidated(ctx context.Context, page, perPage int, f repository.ConsolidatedFilter) ([]model.Certificate, int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var certs []model.Certificate
+	index := make(map[string]int)
+	for _, cert := range c.certs {
+		fp := cert.Fingerprint()
+		i, ok := index[fp]
+		if !ok {
+			i = len(certs)
+			index[fp] = i
+			certs = append(certs, model.Certificate{
+				ID: i + 1, Fingerprint: fp, SerialNumber: cert.SerialNumber, CommonName: cert.CommonName,
+				SANs: cert.SANs, Issuer: cert.Issuer, NotBefore: cert.NotBefore, NotAfter: cert.NotAfter,
+				FirstSeenAt: cert.FirstSeenAt,
+			})
+		}
+		certs[i].Keywords = append(certs[i].Keywords, model.CertificateKeyword{
+			MatchID: cert.ID, KeywordID: cert.KeywordID, Keyword: cert.KeywordValue,
+			MatchedDomain: cert.MatchedDomain, Reason: model.MatchReason(cert.MatchedField, cert.MatchedValue),
+		})
+	}
+	certs = slices.DeleteFunc(certs, func(cert model.Certificate) bool {
+		return len(cert.Keywords) < f.MinKeywords || (f.KeywordID > 0 && !slices.ContainsFunc(cert.Keywords,
+			func(k model.CertificateKeyword) bool { return k.KeywordID == f.KeywordID }))
+	})
+	slices.Reverse(certs) // newest first
+	from := min((page-1)*perPage, len(certs))
+	to := min(from+perPage, len(certs))
+	return certs[from:to], len(certs), nil
+}
+
+// stateStore exposes the monitor state methods.
+type stateStore struct{ *memStore }
+
+func (st stateStore) Get(ctx context.Context) (*model.MonitorState, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	state := st.state
+	return &state, nil
+}
+
+func (st stateStore) Update(ctx context.Context, state *model.MonitorState) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	now := time.Now()
+	logURL, configuredURL, frozen := st.state.CTLogURL, st.state.CTLogConfiguredURL, st.state.LogFrozenSince
+	st.state = *state
+	st.state.CTLogURL, st.state.CTLogConfiguredURL, st.state.LogFrozenSince = logURL, configuredURL, frozen
+	st.state.LastRunAt = &now
+	st.state.UpdatedAt = now
+	return nil
+}
+
+func (st stateStore) SetRunning(ctx context.Context, running bool) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.state.IsRunning = running
+	return nil
+}
+
+func (st stateStore) ResetCycleStats(ctx context.Context) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.state.CertsInLastCycle, st.state.MatchesInLastCycle, st.state.ParseErrorsInLastCycle = 0, 0, 0
+	return nil
+}
+
+func (st stateStore) SetError(ctx context.Context, errMsg string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.state.LastError = errMsg
+	if errMsg != "" {
+		st.errors = append(st.errors, errMsg)
+	}
+	return nil
+}
+
+func (st stateStore) SetLogFrozen(ctx context.Context, since *time.Time) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.state.LogFrozenSince = since
+	return nil
+}
+
+func (st stateStore) SwitchLog(ctx context.Context, url, configuredURL string, index, treeSize int64) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.state.CTLogURL, st.state.CTLogConfiguredURL = url, configuredURL
+	st.state.LastProcessedIndex, st.state.LastTreeSize = index, treeSize
+	st.state.LogFrozenSince = nil
+	return nil
+}
+
+// errorLog returns the SetError messages recorded so far.
+func (s *memStore) errorLog() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.errors)
+}
+
+// auditStore is the audit entry store of an audit.Recorder.
+type auditStore struct{ *memStore }
+
+func (s auditStore) Create(ctx context.Context, entry *model.AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry.ID = int64(len(s.audit) + 1)
+	entry.CreatedAt = time.Now()
+	s.audit = append(s.audit, *entry)
+	return nil
+}
+
+func (s *memStore) auditLog() []model.AuditEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.audit)
+}
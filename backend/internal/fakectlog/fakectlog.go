@@ -0,0 +1,316 @@
+// Package fakectlog serves a synthetic Certificate Transparency log over the
+// two RFC 6962 endpoints the monitor uses (get-sth and get-entries). Its
+// tree grows on demand, a configurable share of its certificates carry
+// keyword-bearing domains, and failures can be injected, so local
+// development and integration tests run without a real CT log:
+//
+//	log := fakectlog.New(fakectlog.WithKeywords("paypal"), fakectlog.WithMatchEvery(10))
+//	srv := httptest.NewServer(log)
+//	client := ctlog.NewClient(srv.URL)
+//
+// Entry i's domain depends only on i and the options; the certificate is
+// generated on first fetch and cached, so every fetch returns the same bytes.
+package fakectlog
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// epoch is the timestamp of entry 0; entry i is logged i milliseconds later.
+var epoch = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// malformedLeaf is served in place of entries WithMalformedEvery selects.
+var malformedLeaf = []byte("malformed leaf")
+
+// Log is a fake CT log. It implements http.Handler; the zero value is not
+// usable, build one with New.
+type Log struct {
+	keywords       []string
+	matchEvery     int64
+	malformedEvery int64
+	pageSize       int64
+
+	key *ecdsa.PrivateKey
+
+	mu       sync.Mutex
+	size     int64
+	leaves   map[int64][]byte
+	failures []int // statuses to answer the next requests with, in order
+}
+
+// Option configures a Log.
+type Option func(*Log)
+
+// WithTreeSize sets the initial number of entries (default 0).
+func WithTreeSize(n int64) Option {
+	return func(l *Log) { l.size = n }
+}
+
+// WithKeywords sets the words embedded in matching domains. Matching entries
+// cycle through them in order.
+func WithKeywords(keywords ...string) Option {
+	return func(l *Log) { l.keywords = keywords }
+}
+
+// WithMatchEvery makes every nth entry (0, n, 2n, ...) a keyword-bearing
+// domain. 0 disables matches; it has no effect without WithKeywords.
+func WithMatchEvery(n int64) Option {
+	return func(l *Log) { l.matchEvery = n }
+}
+
+// WithMalformedEvery makes every nth entry (n-1, 2n-1, ...) an unparsable
+// leaf. 0 (the default) disables malformed entries.
+func WithMalformedEvery(n int64) Option {
+	return func(l *Log) { l.malformedEvery = n }
+}
+
+// WithPageSize caps the entries one get-entries response returns, as real
+// logs do (default 256).
+func WithPageSize(n int64) Option {
+	return func(l *Log) { l.pageSize = n }
+}
+
+// New builds a Log.
+func New(opts ...Option) *Log {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(fmt.Sprintf("fakectlog: generate key: %v", err))
+	}
+	l := &Log{pageSize: 256, key: key, leaves: make(map[int64][]byte)}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// TreeSize returns the current number of entries.
+func (l *Log) TreeSize() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.size
+}
+
+// Grow appends n entries to the tree.
+func (l *Log) Grow(n int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.size += n
+}
+
+// FailNext makes the next n requests, to either endpoint, answer status
+// instead. A 429 carries a Retry-After of one second.
+func (l *Log) FailNext(n int, status int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for range n {
+		l.failures = append(l.failures, status)
+	}
+}
+
+// Domain returns the common name of entry i, or "" when the entry is
+// malformed.
+func (l *Log) Domain(i int64) string {
+	if l.malformed(i) {
+		return ""
+	}
+	if l.matchEvery > 0 && len(l.keywords) > 0 && i%l.matchEvery == 0 {
+		kw := l.keywords[(i/l.matchEvery)%int64(len(l.keywords))]
+		return fmt.Sprintf("%s-login-%d.example.com", kw, i)
+	}
+	return fmt.Sprintf("host-%d.fake-ct.test", i)
+}
+
+// Matching reports whether entry i carries one of the keywords.
+func (l *Log) Matching(i int64) bool {
+	return !l.malformed(i) && l.matchEvery > 0 && len(l.keywords) > 0 && i%l.matchEvery == 0
+}
+
+func (l *Log) malformed(i int64) bool {
+	return l.malformedEvery > 0 && i%l.malformedEvery == l.malformedEvery-1
+}
+
+// ServeHTTP serves /ct/v1/get-sth and /ct/v1/get-entries, plus the control
+// endpoints POST /fake/grow?n=N and POST /fake/fail?status=S&count=N used
+// by cmd/fakectlog.
+func (l *Log) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/fake/grow":
+		l.serveGrow(w, r)
+		return
+	case "/fake/fail":
+		l.serveFail(w, r)
+		return
+	}
+
+	if status, ok := l.nextFailure(); ok {
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "1")
+		}
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/ct/v1/get-sth":
+		l.serveSTH(w)
+	case "/ct/v1/get-entries":
+		l.serveEntries(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (l *Log) nextFailure() (int, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.failures) == 0 {
+		return 0, false
+	}
+	status := l.failures[0]
+	l.failures = l.failures[1:]
+	return status, true
+}
+
+func (l *Log) serveSTH(w http.ResponseWriter) {
+	size := l.TreeSize()
+	root := sha256.Sum256(binary.BigEndian.AppendUint64(nil, uint64(size)))
+	writeJSON(w, sthResponse{TreeSize: size, Timestamp: time.Now().UnixMilli(), SHA256RootHash: root[:]})
+}
+
+// sthResponse is the body of get-sth.
+type sthResponse struct {
+	TreeSize       int64  `json:"tree_size"`
+	Timestamp      int64  `json:"timestamp"`
+	SHA256RootHash []byte `json:"sha256_root_hash"`
+}
+
+type rawEntry struct {
+	LeafInput []byte `json:"leaf_input"`
+	ExtraData []byte `json:"extra_data"`
+}
+
+// entriesResponse is the body of get-entries.
+type entriesResponse struct {
+	Entries []rawEntry `json:"entries"`
+}
+
+// growResponse is the body of POST /fake/grow.
+type growResponse struct {
+	TreeSize int64 `json:"tree_size"`
+}
+
+// response is every body the log writes as JSON.
+type response interface {
+	sthResponse | entriesResponse | growResponse
+}
+
+func (l *Log) serveEntries(w http.ResponseWriter, r *http.Request) {
+	start, err1 := strconv.ParseInt(r.URL.Query().Get("start"), 10, 64)
+	end, err2 := strconv.ParseInt(r.URL.Query().Get("end"), 10, 64)
+	size := l.TreeSize()
+	if err1 != nil || err2 != nil || start < 0 || end < start || start >= size {
+		http.Error(w, "invalid start/end", http.StatusBadRequest)
+		return
+	}
+	end = min(end, size-1, start+l.pageSize-1)
+
+	entries := make([]rawEntry, 0, end-start+1)
+	for i := start; i <= end; i++ {
+		entries = append(entries, rawEntry{LeafInput: l.leaf(i), ExtraData: []byte{}})
+	}
+	writeJSON(w, entriesResponse{Entries: entries})
+}
+
+func (l *Log) serveGrow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	n, err := strconv.ParseInt(r.URL.Query().Get("n"), 10, 64)
+	if err != nil || n < 0 {
+		http.Error(w, "n must be a non-negative integer", http.StatusBadRequest)
+		return
+	}
+	l.Grow(n)
+	writeJSON(w, growResponse{TreeSize: l.TreeSize()})
+}
+
+func (l *Log) serveFail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	status, err := strconv.Atoi(q.Get("status"))
+	if err != nil || status < 400 || status > 599 {
+		http.Error(w, "status must be a 4xx or 5xx code", http.StatusBadRequest)
+		return
+	}
+	count := 1
+	if v := q.Get("count"); v != "" {
+		if count, err = strconv.Atoi(v); err != nil || count < 1 {
+			http.Error(w, "count must be a positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+	l.FailNext(count, status)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// leaf returns the MerkleTreeLeaf of entry i, generating it on first use.
+func (l *Log) leaf(i int64) []byte {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if b, ok := l.leaves[i]; ok {
+		return b
+	}
+	b := malformedLeaf
+	if !l.malformed(i) {
+		b = l.buildLeaf(i)
+	}
+	l.leaves[i] = b
+	return b
+}
+
+// buildLeaf encodes a self-signed certificate for Domain(i) as an
+// x509_entry MerkleTreeLeaf.
+func (l *Log) buildLeaf(i int64) []byte {
+	domain := l.Domain(i)
+	logged := epoch.Add(time.Duration(i) * time.Millisecond)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(i + 1),
+		Subject:      pkix.Name{CommonName: domain},
+		DNSNames:     []string{domain, "www." + domain},
+		NotBefore:    logged,
+		NotAfter:     logged.Add(90 * 24 * time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &l.key.PublicKey, l.key)
+	if err != nil {
+		panic(fmt.Sprintf("fakectlog: create certificate %d: %v", i, err))
+	}
+
+	leaf := []byte{0, 0} // version v1, leaf type timestamped_entry
+	leaf = binary.BigEndian.AppendUint64(leaf, uint64(logged.UnixMilli()))
+	leaf = append(leaf, 0, 0) // x509_entry
+	leaf = append(leaf, byte(len(der)>>16), byte(len(der)>>8), byte(len(der)))
+	return append(leaf, der...)
+}
+
+func writeJSON[T response](w http.ResponseWriter, v T) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
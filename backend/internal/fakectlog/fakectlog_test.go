@@ -0,0 +1,181 @@
+package fakectlog
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/ctlog"
+)
+
+func newServer(t *testing.T, opts ...Option) (*Log, *ctlog.Client, *httptest.Server) {
+	t.Helper()
+	log := New(opts...)
+	srv := httptest.NewServer(log)
+	t.Cleanup(srv.Close)
+	return log, ctlog.NewClient(srv.URL), srv
+}
+
+func TestGetSTH_ReportsTreeSize(t *testing.T) {
+	log, client, _ := newServer(t, WithTreeSize(10))
+
+	sth, err := client.GetSTH(context.Background())
+	if err != nil {
+		t.Fatalf("GetSTH: %v", err)
+	}
+	if sth.TreeSize != 10 {
+		t.Errorf("TreeSize = %d, want 10", sth.TreeSize)
+	}
+
+	log.Grow(5)
+	if sth, _ = client.GetSTH(context.Background()); sth.TreeSize != 15 {
+		t.Errorf("TreeSize after Grow = %d, want 15", sth.TreeSize)
+	}
+}
+
+func TestGetEntries_ParsableCertificates(t *testing.T) {
+	log, client, _ := newServer(t, WithTreeSize(20), WithKeywords("paypal", "amazon"), WithMatchEvery(5))
+
+	entries, err := client.GetEntries(context.Background(), 0, 19)
+	if err != nil {
+		t.Fatalf("GetEntries: %v", err)
+	}
+	if len(entries) != 20 {
+		t.Fatalf("entries = %d, want 20", len(entries))
+	}
+	var matches []string
+	for i, e := range entries {
+		cert, err := ctlog.ParseLeafInput(e.LeafInput, e.ExtraData)
+		if err != nil {
+			t.Fatalf("entry %d: %v", i, err)
+		}
+		if cert.CommonName != log.Domain(int64(i)) {
+			t.Errorf("entry %d CN = %q, want %q", i, cert.CommonName, log.Domain(int64(i)))
+		}
+		if log.Matching(int64(i)) {
+			matches = append(matches, cert.CommonName)
+		}
+	}
+	want := []string{"paypal-login-0.example.com", "amazon-login-5.example.com",
+		"paypal-login-10.example.com", "amazon-login-15.example.com"}
+	if strings.Join(matches, ",") != strings.Join(want, ",") {
+		t.Errorf("matching domains = %v, want %v", matches, want)
+	}
+}
+
+func TestGetEntries_SameBytesOnRefetch(t *testing.T) {
+	_, client, _ := newServer(t, WithTreeSize(3))
+
+	first, _ := client.GetEntries(context.Background(), 0, 2)
+	second, _ := client.GetEntries(context.Background(), 0, 2)
+	for i := range first {
+		if !bytes.Equal(first[i].LeafInput, second[i].LeafInput) {
+			t.Errorf("entry %d changed between fetches", i)
+		}
+	}
+}
+
+func TestGetEntries_CapsPageAndTree(t *testing.T) {
+	_, client, _ := newServer(t, WithTreeSize(10), WithPageSize(4))
+
+	entries, err := client.GetEntries(context.Background(), 2, 9)
+	if err != nil {
+		t.Fatalf("GetEntries: %v", err)
+	}
+	if len(entries) != 4 {
+		t.Errorf("page = %d entries, want 4", len(entries))
+	}
+
+	entries, err = client.GetEntries(context.Background(), 8, 100)
+	if err != nil {
+		t.Fatalf("GetEntries past the tree: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("tail = %d entries, want 2", len(entries))
+	}
+
+	if _, err := client.GetEntries(context.Background(), 10, 12); err == nil {
+		t.Error("GetEntries beyond the tree succeeded, want an error")
+	}
+}
+
+func TestGetEntries_MalformedEvery(t *testing.T) {
+	log, client, _ := newServer(t, WithTreeSize(6), WithMalformedEvery(3))
+
+	entries, err := client.GetEntries(context.Background(), 0, 5)
+	if err != nil {
+		t.Fatalf("GetEntries: %v", err)
+	}
+	for i, e := range entries {
+		_, err := ctlog.ParseLeafInput(e.LeafInput, e.ExtraData)
+		wantErr := i == 2 || i == 5
+		if (err != nil) != wantErr {
+			t.Errorf("entry %d parse error = %v, want error %v", i, err, wantErr)
+		}
+		if wantErr && log.Domain(int64(i)) != "" {
+			t.Errorf("Domain(%d) = %q, want empty for a malformed entry", i, log.Domain(int64(i)))
+		}
+	}
+}
+
+func TestFailNext(t *testing.T) {
+	log, client, srv := newServer(t, WithTreeSize(1))
+	log.FailNext(1, http.StatusTooManyRequests)
+
+	resp, err := http.Get(srv.URL + "/ct/v1/get-sth")
+	if err != nil {
+		t.Fatalf("get-sth: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "1" {
+		t.Errorf("status = %d, Retry-After = %q, want 429 and 1", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+
+	if _, err := client.GetSTH(context.Background()); err != nil {
+		t.Errorf("request after the injected failure: %v", err)
+	}
+}
+
+func TestControlEndpoints(t *testing.T) {
+	log, client, srv := newServer(t)
+
+	resp, err := http.Post(srv.URL+"/fake/grow?n=7", "", nil)
+	if err != nil {
+		t.Fatalf("grow: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || log.TreeSize() != 7 {
+		t.Errorf("grow: status %d, tree size %d, want 200 and 7", resp.StatusCode, log.TreeSize())
+	}
+
+	resp, err = http.Post(srv.URL+"/fake/fail?status=503&count=2", "", nil)
+	if err != nil {
+		t.Fatalf("fail: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("fail: status %d, want 204", resp.StatusCode)
+	}
+	for i := range 2 {
+		if _, err := client.GetSTH(context.Background()); err == nil {
+			t.Errorf("request %d succeeded, want the injected 503", i)
+		}
+	}
+	if _, err := client.GetSTH(context.Background()); err != nil {
+		t.Errorf("request after the injected failures: %v", err)
+	}
+
+	for _, path := range []string{"/fake/grow?n=-1", "/fake/fail?status=200", "/fake/fail?status=500&count=0"} {
+		resp, err := http.Post(srv.URL+path, "", nil)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", path, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/analyze"
+)
+
+type keywordLister interface {
+	List(ctx context.Context) ([]model.Keyword, error)
+}
+
+// defaultAnalyzeCount is used when a request omits count.
+const defaultAnalyzeCount = 100
+
+type AnalyzeHandler struct {
+	client   analyze.Client
+	keywords keywordLister
+	maxCount int
+}
+
+// NewAnalyzeHandler serves dry-run analyses of the newest entries of the
+// log behind client; requests may ask for at most maxCount entries.
+func NewAnalyzeHandler(client analyze.Client, keywords keywordLister, maxCount int) *AnalyzeHandler {
+	return &AnalyzeHandler{client: client, keywords: keywords, maxCount: maxCount}
+}
+
+func (h *AnalyzeHandler) RegisterRoutes(r chi.Router) {
+	r.Post("/analyze", h.Analyze)
+}
+
+// Analyze matches the newest count log entries against the keywords in the
+// request, or the stored keywords when none are given, and reports per
+// keyword hit counts. Nothing is stored.
+func (h *AnalyzeHandler) Analyze(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Count    int      `json:"count"`
+		Keywords []string `json:"keywords"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if req.Count == 0 {
+		req.Count = defaultAnalyzeCount
+	}
+	if req.Count < 1 || req.Count > h.maxCount {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("count must be between 1 and %d", h.maxCount))
+		return
+	}
+
+	var keywords []model.Keyword
+	if len(req.Keywords) == 0 {
+		stored, err := h.keywords.List(r.Context())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to list keywords")
+			return
+		}
+		keywords = stored
+	} else {
+		for i, v := range req.Keywords {
+			v = strings.TrimSpace(v)
+			if len(v) < 3 {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("keyword %q must be at least 3 characters", v))
+				return
+			}
+			keywords = append(keywords, model.Keyword{ID: i + 1, Value: v})
+		}
+	}
+	if len(keywords) == 0 {
+		writeError(w, http.StatusBadRequest, "no keywords given and none stored")
+		return
+	}
+
+	result, err := analyze.Run(r.Context(), h.client, keywords, req.Count)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "analyze failed", "error", err)
+		writeError(w, http.StatusBadGateway, "failed to read the CT log")
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
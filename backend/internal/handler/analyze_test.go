@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/analyze"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/ctlog"
+)
+
+type mockLogClient struct {
+	getSTHFn     func(ctx context.Context) (*ctlog.STH, error)
+	getEntriesFn func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error)
+}
+
+func (m *mockLogClient) GetSTH(ctx context.Context) (*ctlog.STH, error) {
+	return m.getSTHFn(ctx)
+}
+func (m *mockLogClient) GetEntries(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
+	return m.getEntriesFn(ctx, start, end)
+}
+
+type mockKeywordLister struct {
+	listFn func(ctx context.Context) ([]model.Keyword, error)
+}
+
+func (m *mockKeywordLister) List(ctx context.Context) ([]model.Keyword, error) {
+	return m.listFn(ctx)
+}
+
+// unparseableLog serves treeSize entries that all fail to parse.
+func unparseableLog(treeSize int64, gotRange *[2]int64) *mockLogClient {
+	return &mockLogClient{
+		getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+			return &ctlog.STH{TreeSize: treeSize}, nil
+		},
+		getEntriesFn: func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
+			if gotRange != nil {
+				*gotRange = [2]int64{start, end}
+			}
+			return make([]ctlog.RawEntry, end-start+1), nil
+		},
+	}
+}
+
+func TestAnalyze_SuppliedKeywords(t *testing.T) {
+	var gotRange [2]int64
+	h := NewAnalyzeHandler(unparseableLog(1000, &gotRange), &mockKeywordLister{}, 500)
+
+	req := httptest.NewRequest(http.MethodPost, "/analyze", strings.NewReader(`{"count":50,"keywords":["paypal","amazon"]}`))
+	rec := httptest.NewRecorder()
+	h.Analyze(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if gotRange != [2]int64{950, 999} {
+		t.Errorf("fetched %v, want the newest 50 entries [950 999]", gotRange)
+	}
+	var res analyze.Result
+	if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if res.Entries != 50 || res.ParseErrors != 50 {
+		t.Errorf("entries/parse_errors = %d/%d, want 50/50", res.Entries, res.ParseErrors)
+	}
+	if len(res.Keywords) != 2 || res.Keywords[0].Keyword != "paypal" || res.Keywords[1].Keyword != "amazon" {
+		t.Errorf("keywords = %+v, want paypal and amazon", res.Keywords)
+	}
+}
+
+func TestAnalyze_FallsBackToStoredKeywords(t *testing.T) {
+	h := NewAnalyzeHandler(unparseableLog(10, nil), &mockKeywordLister{
+		listFn: func(ctx context.Context) ([]model.Keyword, error) {
+			return []model.Keyword{{ID: 7, Value: "stored"}}, nil
+		},
+	}, 500)
+
+	req := httptest.NewRequest(http.MethodPost, "/analyze", strings.NewReader(`{}`))
+	rec := httptest.NewRecorder()
+	h.Analyze(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var res analyze.Result
+	if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(res.Keywords) != 1 || res.Keywords[0].Keyword != "stored" {
+		t.Errorf("keywords = %+v, want the stored keyword", res.Keywords)
+	}
+	if res.Entries != 10 {
+		t.Errorf("entries = %d, want 10 (default count capped by tree size)", res.Entries)
+	}
+}
+
+func TestAnalyze_BadRequests(t *testing.T) {
+	h := NewAnalyzeHandler(unparseableLog(10, nil), &mockKeywordLister{
+		listFn: func(ctx context.Context) ([]model.Keyword, error) { return nil, nil },
+	}, 500)
+
+	for _, body := range []string{
+		`{"count":501,"keywords":["paypal"]}`,
+		`{"count":-1,"keywords":["paypal"]}`,
+		`{"keywords":["ab"]}`,
+		`{}`, // no keywords stored either
+		`not json`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/analyze", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		h.Analyze(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", body, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestAnalyze_LogError(t *testing.T) {
+	h := NewAnalyzeHandler(&mockLogClient{
+		getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+			return nil, errors.New("unreachable")
+		},
+	}, &mockKeywordLister{}, 500)
+
+	req := httptest.NewRequest(http.MethodPost, "/analyze", strings.NewReader(`{"keywords":["paypal"]}`))
+	rec := httptest.NewRecorder()
+	h.Analyze(rec, req)
+
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadGateway)
+	}
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+// auditRecorder is the best-effort audit sink used by mutating handlers.
+type auditRecorder interface {
+	Record(ctx context.Context, action, entityType, entityID string, changes map[string]model.AuditChange)
+}
+
+type auditStore interface {
+	List(ctx context.Context, page, perPage int, filter model.AuditFilter) ([]model.AuditEntry, int, error)
+}
+
+type AuditHandler struct {
+	repo auditStore
+}
+
+func NewAuditHandler(repo auditStore) *AuditHandler {
+	return &AuditHandler{repo: repo}
+}
+
+func (h *AuditHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/audit", h.List)
+}
+
+func (h *AuditHandler) List(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	page := 1
+	perPage := 50
+
+	if v := q.Get("page"); v != "" {
+		if p, err := strconv.Atoi(v); err == nil && p > 0 {
+			page = p
+		}
+	}
+	// limit is accepted as an alias for per_page.
+	for _, name := range []string{"limit", "per_page"} {
+		if v := q.Get(name); v != "" {
+			if pp, err := strconv.Atoi(v); err == nil && pp > 0 && pp <= 200 {
+				perPage = pp
+			}
+		}
+	}
+
+	filter := model.AuditFilter{
+		Actor:      q.Get("actor"),
+		Action:     q.Get("action"),
+		EntityType: q.Get("entity_type"),
+		RequestID:  q.Get("request_id"),
+	}
+	for _, p := range []struct {
+		name string
+		dst  **time.Time
+	}{{"since", &filter.Since}, {"until", &filter.Until}} {
+		v := q.Get(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid "+p.name+" timestamp (want RFC 3339)")
+			return
+		}
+		*p.dst = &t
+	}
+
+	entries, total, err := h.repo.List(r.Context(), page, perPage, filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list audit log")
+		return
+	}
+	if entries == nil {
+		entries = []model.AuditEntry{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"entries":  entries,
+		"total":    total,
+		"page":     page,
+		"per_page": perPage,
+	})
+}
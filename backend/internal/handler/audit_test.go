@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+type auditCall struct {
+	action     string
+	entityType string
+	entityID   string
+	changes    map[string]model.AuditChange
+}
+
+// mockAuditRecorder captures Record calls for assertions.
+type mockAuditRecorder struct {
+	calls []auditCall
+}
+
+func (m *mockAuditRecorder) Record(ctx context.Context, action, entityType, entityID string, changes map[string]model.AuditChange) {
+	m.calls = append(m.calls, auditCall{action, entityType, entityID, changes})
+}
+
+type mockAuditStore struct {
+	listFn func(ctx context.Context, page, perPage int, filter model.AuditFilter) ([]model.AuditEntry, int, error)
+}
+
+func (m *mockAuditStore) List(ctx context.Context, page, perPage int, filter model.AuditFilter) ([]model.AuditEntry, int, error) {
+	return m.listFn(ctx, page, perPage, filter)
+}
+
+func TestAuditList_Filters(t *testing.T) {
+	h := NewAuditHandler(&mockAuditStore{
+		listFn: func(ctx context.Context, page, perPage int, filter model.AuditFilter) ([]model.AuditEntry, int, error) {
+			if page != 2 || perPage != 10 {
+				t.Errorf("page/perPage = %d/%d, want 2/10", page, perPage)
+			}
+			if filter.Action != "delete" || filter.EntityType != "keyword" || filter.Actor != "ops" {
+				t.Errorf("filter = %+v", filter)
+			}
+			if filter.Since == nil || !filter.Since.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)) {
+				t.Errorf("Since = %v, want 2025-01-01", filter.Since)
+			}
+			return []model.AuditEntry{{ID: 1, Action: "delete", Changes: json.RawMessage(`{}`)}}, 11, nil
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodGet,
+		"/audit?page=2&per_page=10&action=delete&entity_type=keyword&actor=ops&since=2025-01-01T00:00:00Z", nil)
+	rec := httptest.NewRecorder()
+	h.List(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var body struct {
+		Entries []model.AuditEntry `json:"entries"`
+		Total   int                `json:"total"`
+	}
+	json.NewDecoder(rec.Body).Decode(&body)
+	if len(body.Entries) != 1 || body.Total != 11 {
+		t.Errorf("entries = %d, total = %d; want 1, 11", len(body.Entries), body.Total)
+	}
+}
+
+func TestAuditList_LimitAndRequestID(t *testing.T) {
+	var gotPerPage int
+	var gotFilter model.AuditFilter
+	h := NewAuditHandler(&mockAuditStore{
+		listFn: func(ctx context.Context, page, perPage int, filter model.AuditFilter) ([]model.AuditEntry, int, error) {
+			gotPerPage, gotFilter = perPage, filter
+			return []model.AuditEntry{{ID: 1, RequestID: "abc123", ClientIP: "203.0.113.7"}}, 1, nil
+		},
+	})
+
+	rec := httptest.NewRecorder()
+	h.List(rec, httptest.NewRequest(http.MethodGet, "/audit?limit=5&request_id=abc123", nil))
+
+	if gotPerPage != 5 || gotFilter.RequestID != "abc123" {
+		t.Errorf("perPage/request_id = %d/%q, want 5/abc123", gotPerPage, gotFilter.RequestID)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, `"request_id":"abc123"`) || !strings.Contains(body, `"client_ip":"203.0.113.7"`) {
+		t.Errorf("body = %s, want request_id and client_ip", body)
+	}
+}
+
+func TestAuditList_InvalidSince(t *testing.T) {
+	h := NewAuditHandler(&mockAuditStore{})
+
+	req := httptest.NewRequest(http.MethodGet, "/audit?since=yesterday", nil)
+	rec := httptest.NewRecorder()
+	h.List(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestAuditList_Error(t *testing.T) {
+	h := NewAuditHandler(&mockAuditStore{
+		listFn: func(ctx context.Context, page, perPage int, filter model.AuditFilter) ([]model.AuditEntry, int, error) {
+			return nil, 0, errors.New("db error")
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/audit", nil)
+	rec := httptest.NewRecorder()
+	h.List(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+}
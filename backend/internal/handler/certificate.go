@@ -3,6 +3,9 @@ package handler
 import (
 	"context"
 	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"strconv"
@@ -12,30 +15,73 @@ import (
 	"github.com/go-chi/chi/v5"
 
 	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/repository"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/domain"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/stix"
 )
 
 type certificateStore interface {
-	ListPaginated(ctx context.Context, page, perPage, keywordID int) ([]model.MatchedCertificate, int, error)
-	ExportAll(ctx context.Context) ([]model.MatchedCertificate, error)
+	ListPaginated(ctx context.Context, page, perPage int, filter repository.CertificateFilter) ([]model.MatchedCertificate, int, error)
+	ListSince(ctx context.Context, limit int, filter repository.CertificateFilter) ([]model.MatchedCertificate, int, error)
+	ExportEach(ctx context.Context, filter repository.CertificateFilter, fn func(model.MatchedCertificate) error) error
+	Version(ctx context.Context, filter repository.CertificateFilter) (string, error)
+	DistinctIssuers(ctx context.Context, limit int) ([]string, error)
+	SimilarDomains(ctx context.Context, domain string, threshold float64) ([]model.SimilarDomain, error)
+	GetByID(ctx context.Context, id int) (*model.MatchedCertificate, error)
+	DeleteWhere(ctx context.Context, filter repository.CertificateFilter) (int64, error)
+	ListConsolidated(ctx context.Context, page, perPage int, filter repository.ConsolidatedFilter) ([]model.Certificate, int, error)
 }
 
 type CertificateHandler struct {
-	repo certificateStore
+	repo  certificateStore
+	audit auditRecorder
 }
 
-func NewCertificateHandler(repo certificateStore) *CertificateHandler {
-	return &CertificateHandler{repo: repo}
+func NewCertificateHandler(repo certificateStore, audit auditRecorder) *CertificateHandler {
+	return &CertificateHandler{repo: repo, audit: audit}
 }
 
 func (h *CertificateHandler) RegisterRoutes(r chi.Router) {
 	r.Get("/certificates", h.List)
 	r.Get("/certificates/export", h.Export)
+	r.Get("/certificates/issuers", h.Issuers)
+	r.Get("/certificates/similar", h.Similar)
+	r.Get("/certificates/consolidated", h.Consolidated)
+	r.Get("/certificates/{id}", h.Get)
 }
 
+// RegisterAdminRoutes registers the bulk delete, which removes matches
+// (and their pending notifications) for good.
+func (h *CertificateHandler) RegisterAdminRoutes(r chi.Router) {
+	r.Delete("/certificates", h.Delete)
+}
+
+// Get returns one match, including when it was first and last seen.
+func (h *CertificateHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid certificate id")
+		return
+	}
+
+	cert, err := h.repo.GetByID(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "certificate not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to get certificate")
+		return
+	}
+	writeJSON(w, http.StatusOK, cert)
+}
+
+// List returns a page of matches, highest risk score first unless
+// ?sort=newest, as JSON or, when the Accept header prefers text/csv, as CSV
+// with the total in X-Total-Count.
 func (h *CertificateHandler) List(w http.ResponseWriter, r *http.Request) {
 	page := 1
 	perPage := 20
-	keywordID := 0
 
 	if v := r.URL.Query().Get("page"); v != "" {
 		if p, err := strconv.Atoi(v); err == nil && p > 0 {
@@ -47,18 +93,49 @@ func (h *CertificateHandler) List(w http.ResponseWriter, r *http.Request) {
 			perPage = pp
 		}
 	}
-	if v := r.URL.Query().Get("keyword"); v != "" {
-		if kid, err := strconv.Atoi(v); err == nil {
-			keywordID = kid
-		}
+	filter, msg := certificateFilter(r)
+	if msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	switch v := r.URL.Query().Get("sort"); v {
+	case "", repository.SortRisk, repository.SortNewest:
+		filter.Sort = v
+	default:
+		writeError(w, http.StatusBadRequest, "invalid sort (want risk or newest)")
+		return
+	}
+
+	asCSV := acceptsCSV(r)
+	representation := "json"
+	if asCSV {
+		representation = "csv"
+	}
+	w.Header().Add("Vary", "Accept")
+
+	// A failed version lookup only costs the client its cache hit; the list
+	// itself is still served.
+	if version, err := h.repo.Version(r.Context(), filter); err != nil {
+		slog.WarnContext(r.Context(), "certificate list version failed", "error", err)
+	} else if notModified(w, r, makeETag(version, r.URL.Query().Encode(), representation)) {
+		return
 	}
 
-	certs, total, err := h.repo.ListPaginated(r.Context(), page, perPage, keywordID)
+	if r.URL.Query().Get("since_id") != "" {
+		h.listSince(w, r, filter.SinceID, perPage, filter, asCSV)
+		return
+	}
+
+	certs, total, err := h.repo.ListPaginated(r.Context(), page, perPage, filter)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to list certificates")
+		writeQueryError(w, err, "failed to list certificates")
 		return
 	}
 
+	if asCSV {
+		writeCertificatesCSV(r.Context(), w, certs, total)
+		return
+	}
 	if certs == nil {
 		certs = []model.MatchedCertificate{}
 	}
@@ -71,43 +148,467 @@ func (h *CertificateHandler) List(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func (h *CertificateHandler) Export(w http.ResponseWriter, r *http.Request) {
-	certs, err := h.repo.ExportAll(r.Context())
+// Consolidated returns a page of certificates, newest first, each listed
+// once with every keyword that matched it. ?keyword keeps certificates that
+// keyword matched and ?min_keywords those matched by at least that many.
+func (h *CertificateHandler) Consolidated(w http.ResponseWriter, r *http.Request) {
+	page := 1
+	perPage := 20
+
+	if v := r.URL.Query().Get("page"); v != "" {
+		if p, err := strconv.Atoi(v); err == nil && p > 0 {
+			page = p
+		}
+	}
+	if v := r.URL.Query().Get("per_page"); v != "" {
+		if pp, err := strconv.Atoi(v); err == nil && pp > 0 && pp <= 100 {
+			perPage = pp
+		}
+	}
+	var filter repository.ConsolidatedFilter
+	if v := r.URL.Query().Get("keyword"); v != "" {
+		kid, err := strconv.Atoi(v)
+		if err != nil || kid < 1 {
+			writeError(w, http.StatusBadRequest, "invalid keyword filter")
+			return
+		}
+		filter.KeywordID = kid
+	}
+	if v := r.URL.Query().Get("min_keywords"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "invalid min_keywords filter")
+			return
+		}
+		filter.MinKeywords = n
+	}
+
+	certs, total, err := h.repo.ListConsolidated(r.Context(), page, perPage, filter)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to export certificates")
+		writeQueryError(w, err, "failed to list certificates")
 		return
 	}
+	if certs == nil {
+		certs = []model.Certificate{}
+	}
 
-	w.Header().Set("Content-Type", "text/csv")
-	w.Header().Set("Content-Disposition", `attachment; filename="matched_certificates.csv"`)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"certificates": certs,
+		"total":        total,
+		"page":         page,
+		"per_page":     perPage,
+	})
+}
 
-	writer := csv.NewWriter(w)
-	defer func() {
-		writer.Flush()
-		if err := writer.Error(); err != nil {
-			slog.Error("csv export write error", "error", err)
+// Delete removes every match the List filters keep and reports how many
+// were deleted. A request without filters is refused unless ?all=true
+// confirms that every match should go.
+func (h *CertificateHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	filter, msg := certificateFilter(r)
+	if msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	if filter.IsZero() && r.URL.Query().Get("all") != "true" {
+		writeError(w, http.StatusBadRequest, "refusing to delete every certificate without a filter; pass all=true to confirm")
+		return
+	}
+
+	deleted, err := h.repo.DeleteWhere(r.Context(), filter)
+	if err != nil {
+		writeQueryError(w, err, "failed to delete certificates")
+		return
+	}
+
+	h.audit.Record(r.Context(), model.AuditActionDelete, model.AuditEntityCertificate, "",
+		map[string]model.AuditChange{
+			"filter":  {Old: r.URL.Query().Encode()},
+			"deleted": {Old: strconv.FormatInt(deleted, 10)},
+		})
+
+	writeJSON(w, http.StatusOK, map[string]any{"deleted": deleted})
+}
+
+// certificateFilter parses the filter query parameters shared by List and
+// Delete; a non-empty message describes the first invalid one.
+func certificateFilter(r *http.Request) (repository.CertificateFilter, string) {
+	var filter repository.CertificateFilter
+	if v := r.URL.Query().Get("keyword"); v != "" {
+		kid, err := strconv.Atoi(v)
+		if err != nil || kid < 1 {
+			return filter, "invalid keyword filter"
+		}
+		filter.KeywordID = kid
+	}
+	if v := r.URL.Query().Get("group"); v != "" {
+		gid, err := strconv.Atoi(v)
+		if err != nil || gid < 1 {
+			return filter, "invalid group filter"
+		}
+		filter.GroupID = gid
+	}
+	if v := r.URL.Query().Get("status"); v != "" {
+		if !model.ValidCertStatus(v) {
+			return filter, "invalid status filter"
+		}
+		filter.Status = v
+	}
+	if v := r.URL.Query().Get("cn_not_in_sans"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, "invalid cn_not_in_sans filter"
+		}
+		filter.CNNotInSANs = b
+	}
+	if v := r.URL.Query().Get("server_auth"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, "invalid server_auth filter"
+		}
+		filter.ServerAuth = &b
+	}
+	if v := r.URL.Query().Get("min_sans"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return filter, "invalid min_sans filter"
+		}
+		filter.MinSANs = n
+	}
+	if v := r.URL.Query().Get("max_sans"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return filter, "invalid max_sans filter"
+		}
+		if n < filter.MinSANs {
+			return filter, "max_sans must not be less than min_sans"
+		}
+		filter.MaxSANs = n
+	}
+	if v := strings.TrimSpace(r.URL.Query().Get("san")); v != "" {
+		if len(v) > 253 {
+			return filter, "invalid san filter"
+		}
+		filter.SAN = v
+	}
+	if v := r.URL.Query().Get("chain_status"); v != "" {
+		if !model.ValidChainStatus(v) {
+			return filter, "invalid chain_status filter"
+		}
+		filter.ChainStatus = v
+	}
+	if v := r.URL.Query().Get("issuer_class"); v != "" {
+		if !model.ValidIssuerClass(v) {
+			return filter, "invalid issuer_class filter"
+		}
+		filter.IssuerClass = v
+	}
+	if v := r.URL.Query().Get("base_domain"); v != "" {
+		// Reduce the host the way the monitor does before storing, so any
+		// name under a domain (wildcards included) selects all its matches.
+		d, _ := domain.Registrable(v)
+		if d == "" {
+			return filter, "invalid base_domain filter"
 		}
-	}()
+		filter.RegistrableDomain = d
+	}
+	if v := r.URL.Query().Get("max_domain_age_days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return filter, "invalid max_domain_age_days filter"
+		}
+		filter.MaxDomainAgeDays = &n
+	}
+	if v := r.URL.Query().Get("discovered_before"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return filter, "invalid discovered_before filter"
+		}
+		filter.DiscoveredBefore = t
+	}
+	filter.Issuer = r.URL.Query().Get("issuer")
+	if v := r.URL.Query().Get("since_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id < 0 {
+			return filter, "invalid since_id"
+		}
+		filter.SinceID = id
+	}
+	return filter, ""
+}
+
+// listSince answers a since_id poll: up to perPage newer matches in
+// ascending id order, how many newer matches exist in total, and the id to
+// pass as since_id next time.
+func (h *CertificateHandler) listSince(w http.ResponseWriter, r *http.Request, sinceID, perPage int, filter repository.CertificateFilter, asCSV bool) {
+	certs, count, err := h.repo.ListSince(r.Context(), perPage, filter)
+	if err != nil {
+		writeQueryError(w, err, "failed to list certificates")
+		return
+	}
+	if asCSV {
+		writeCertificatesCSV(r.Context(), w, certs, count)
+		return
+	}
+
+	lastID := sinceID
+	if len(certs) > 0 {
+		lastID = certs[len(certs)-1].ID
+	} else {
+		certs = []model.MatchedCertificate{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"certificates": certs,
+		"count":        count,
+		"last_id":      lastID,
+	})
+}
+
+// Issuers lists the issuers present in the matches, most frequent first,
+// for the issuer filter; ?limit= caps the list (default 100, max 500).
+func (h *CertificateHandler) Issuers(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if l, err := strconv.Atoi(v); err == nil && l > 0 && l <= 500 {
+			limit = l
+		}
+	}
+
+	issuers, err := h.repo.DistinctIssuers(r.Context(), limit)
+	if err != nil {
+		writeQueryError(w, err, "failed to list issuers")
+		return
+	}
+	if issuers == nil {
+		issuers = []string{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuers": issuers,
+		"limit":   limit,
+	})
+}
+
+// defaultSimilarityThreshold is pg_trgm's own default for the % operator.
+const defaultSimilarityThreshold = 0.3
 
-	writer.Write([]string{
-		"id", "serial_number", "common_name", "sans", "issuer",
-		"not_before", "not_after", "keyword", "matched_domain",
-		"ct_log_index", "discovered_at",
+// Similar lists stored matched domains whose trigram similarity to ?domain=
+// exceeds ?threshold= (default 0.3, exclusive range 0..1), closest first.
+func (h *CertificateHandler) Similar(w http.ResponseWriter, r *http.Request) {
+	domain := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("domain")))
+	if domain == "" || len(domain) > 253 {
+		writeError(w, http.StatusBadRequest, "domain is required and at most 253 characters")
+		return
+	}
+	threshold := defaultSimilarityThreshold
+	if v := r.URL.Query().Get("threshold"); v != "" {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil || t <= 0 || t >= 1 {
+			writeError(w, http.StatusBadRequest, "threshold must be between 0 and 1")
+			return
+		}
+		threshold = t
+	}
+
+	similar, err := h.repo.SimilarDomains(r.Context(), domain, threshold)
+	if err != nil {
+		writeQueryError(w, err, "failed to search similar domains")
+		return
+	}
+	if similar == nil {
+		similar = []model.SimilarDomain{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"domain":    domain,
+		"threshold": threshold,
+		"similar":   similar,
 	})
+}
+
+// Export streams up to 10000 recent matches as CSV (the default) or, with
+// format=stix, as a STIX 2.1 bundle. It takes the same filters as List.
+func (h *CertificateHandler) Export(w http.ResponseWriter, r *http.Request) {
+	filter, msg := certificateFilter(r)
+	if msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "csv":
+		h.exportCSV(w, r, filter)
+	case "stix":
+		h.exportSTIX(w, r, filter)
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown format %q (want csv or stix)", format))
+	}
+}
+
+// certCSVHeader names the columns of certCSVRecord, shared by the export
+// and the CSV representation of the list.
+var certCSVHeader = []string{
+	"id", "serial_number", "common_name", "sans", "issuer",
+	"not_before", "not_after", "keyword", "matched_domain",
+	"ct_log_index", "discovered_at", "registrable_domain",
+	"domain_age_days", "domain_age_status", "risk_score", "issuer_class",
+}
 
+func certCSVRecord(c model.MatchedCertificate) ([]string, error) {
+	// SANs are a JSON array so values containing the delimiter,
+	// quotes or semicolons round-trip unambiguously.
+	sans, err := json.Marshal(c.SANs)
+	if err != nil {
+		return nil, fmt.Errorf("encode sans of %d: %w", c.ID, err)
+	}
+	if c.SANs == nil {
+		sans = []byte("[]")
+	}
+	age := ""
+	if c.DomainAgeDays != nil {
+		age = strconv.Itoa(*c.DomainAgeDays)
+	}
+	return []string{
+		strconv.Itoa(c.ID),
+		c.SerialNumber,
+		c.CommonName,
+		string(sans),
+		c.Issuer,
+		c.NotBefore.Format(time.RFC3339),
+		c.NotAfter.Format(time.RFC3339),
+		c.KeywordValue,
+		c.MatchedDomain,
+		strconv.FormatInt(c.CTLogIndex, 10),
+		c.DiscoveredAt.Format(time.RFC3339),
+		c.RegistrableDomain,
+		age,
+		c.DomainAgeStatus,
+		strconv.Itoa(c.RiskScore),
+		c.IssuerClass,
+	}, nil
+}
+
+// acceptsCSV reports whether the Accept header ranks text/csv above JSON,
+// the default. Equal q-values go to whichever is listed first; wildcards
+// count as JSON.
+func acceptsCSV(r *http.Request) bool {
+	csvQ, jsonQ := 0.0, 0.0
+	csvFirst := false
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		for _, p := range strings.Split(params, ";") {
+			if k, v, ok := strings.Cut(strings.TrimSpace(p), "="); ok && k == "q" {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(mediaType)) {
+		case "text/csv":
+			if csvQ == 0 && jsonQ == 0 {
+				csvFirst = true
+			}
+			csvQ = max(csvQ, q)
+		case "application/json", "application/*", "*/*":
+			jsonQ = max(jsonQ, q)
+		}
+	}
+	return csvQ > jsonQ || (csvQ > 0 && csvQ == jsonQ && csvFirst)
+}
+
+// writeCertificatesCSV renders one page of a list as CSV.
+func writeCertificatesCSV(ctx context.Context, w http.ResponseWriter, certs []model.MatchedCertificate, total int) {
+	records := make([][]string, 0, len(certs)+1)
+	records = append(records, certCSVHeader)
 	for _, c := range certs {
-		writer.Write([]string{
-			strconv.Itoa(c.ID),
-			c.SerialNumber,
-			c.CommonName,
-			strings.Join(c.SANs, ";"),
-			c.Issuer,
-			c.NotBefore.Format(time.RFC3339),
-			c.NotAfter.Format(time.RFC3339),
-			c.KeywordValue,
-			c.MatchedDomain,
-			strconv.FormatInt(c.CTLogIndex, 10),
-			c.DiscoveredAt.Format(time.RFC3339),
-		})
+		rec, err := certCSVRecord(c)
+		if err != nil {
+			slog.ErrorContext(ctx, "certificate list csv failed", "error", err)
+			writeError(w, http.StatusInternalServerError, "failed to list certificates")
+			return
+		}
+		records = append(records, rec)
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	w.WriteHeader(http.StatusOK)
+	if err := csv.NewWriter(w).WriteAll(records); err != nil {
+		slog.WarnContext(ctx, "certificate list csv write failed", "error", err)
+	}
+}
+
+func (h *CertificateHandler) exportCSV(w http.ResponseWriter, r *http.Request, filter repository.CertificateFilter) {
+	sw := newStreamWriter(w)
+	writer := csv.NewWriter(sw)
+
+	h.stream(sw, r, "csv", filter, func() {
+		sw.Header().Set("Content-Type", "text/csv")
+		sw.Header().Set("Content-Disposition", `attachment; filename="matched_certificates.csv"`)
+		sw.WriteHeader(http.StatusOK)
+		writer.Write(certCSVHeader)
+	}, func(c model.MatchedCertificate) error {
+		rec, err := certCSVRecord(c)
+		if err != nil {
+			return err
+		}
+		return writer.Write(rec)
+	}, func() error {
+		writer.Flush()
+		return writer.Error()
+	})
+}
+
+// exportSTIX writes each match as an indicator, an x509-certificate
+// observable and a relationship. Object IDs are derived from the
+// certificate, so importing a later export updates the same objects.
+func (h *CertificateHandler) exportSTIX(w http.ResponseWriter, r *http.Request, filter repository.CertificateFilter) {
+	sw := newStreamWriter(w)
+	bundle := stix.NewWriter(sw)
+
+	h.stream(sw, r, "stix", filter, func() {
+		sw.Header().Set("Content-Type", stix.MediaType)
+		sw.Header().Set("Content-Disposition", `attachment; filename="matched_certificates.stix.json"`)
+		sw.WriteHeader(http.StatusOK)
+	}, bundle.Add, bundle.Close)
+}
+
+// stream feeds every exported match that filter keeps to row. Headers are sent with the first
+// row (via start), so a query that fails before producing one is still
+// reported as a JSON 500; a failure after that aborts the response.
+func (h *CertificateHandler) stream(sw *streamWriter, r *http.Request, format string,
+	filter repository.CertificateFilter, start func(), row func(model.MatchedCertificate) error, finish func() error,
+) {
+	begin := func() {
+		if !sw.committed {
+			start()
+		}
+	}
+
+	rows := 0
+	err := h.repo.ExportEach(r.Context(), filter, func(c model.MatchedCertificate) error {
+		// Stop as soon as the client goes away instead of formatting the
+		// rest of the result set for nobody.
+		if err := r.Context().Err(); err != nil {
+			return err
+		}
+		rows++
+		begin()
+		return row(c)
+	})
+	if err == nil {
+		begin()
+		err = finish()
+	}
+	if err != nil && r.Context().Err() != nil {
+		slog.InfoContext(r.Context(), format+" export aborted by client", "rows_written", rows, "error", err)
+		return
+	}
+	if err != nil {
+		if !sw.committed {
+			slog.ErrorContext(r.Context(), format+" export failed", "error", err)
+			writeQueryError(sw, err, "failed to export certificates")
+			return
+		}
+		abortStream(r.Context(), format+" export failed mid-stream", err)
 	}
 }
@@ -7,57 +7,106 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
+
 	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/repository"
 )
 
 type mockCertificateStore struct {
-	listPaginatedFn func(ctx context.Context, page, perPage, keywordID int) ([]model.MatchedCertificate, int, error)
-	exportAllFn     func(ctx context.Context) ([]model.MatchedCertificate, error)
+	listPaginatedFn func(ctx context.Context, page, perPage int, filter repository.CertificateFilter) ([]model.MatchedCertificate, int, error)
+	listSinceFn     func(ctx context.Context, limit int, filter repository.CertificateFilter) ([]model.MatchedCertificate, int, error)
+	exportEachFn    func(ctx context.Context, filter repository.CertificateFilter, fn func(model.MatchedCertificate) error) error
+	versionFn       func(ctx context.Context, filter repository.CertificateFilter) (string, error)
+	issuersFn       func(ctx context.Context, limit int) ([]string, error)
+	similarFn       func(ctx context.Context, domain string, threshold float64) ([]model.SimilarDomain, error)
+	getByIDFn       func(ctx context.Context, id int) (*model.MatchedCertificate, error)
+	deleteWhereFn   func(ctx context.Context, filter repository.CertificateFilter) (int64, error)
+
+	listConsolidatedFn func(ctx context.Context, page, perPage int, filter repository.ConsolidatedFilter) ([]model.Certificate, int, error)
 }
 
-func (m *mockCertificateStore) ListPaginated(ctx context.Context, page, perPage, keywordID int) ([]model.MatchedCertificate, int, error) {
-	return m.listPaginatedFn(ctx, page, perPage, keywordID)
+func (m *mockCertificateStore) ListPaginated(ctx context.Context, page, perPage int, filter repository.CertificateFilter) ([]model.MatchedCertificate, int, error) {
+	return m.listPaginatedFn(ctx, page, perPage, filter)
+}
+func (m *mockCertificateStore) ListSince(ctx context.Context, limit int, filter repository.CertificateFilter) ([]model.MatchedCertificate, int, error) {
+	return m.listSinceFn(ctx, limit, filter)
+}
+func (m *mockCertificateStore) ExportEach(ctx context.Context, filter repository.CertificateFilter, fn func(model.MatchedCertificate) error) error {
+	return m.exportEachFn(ctx, filter, fn)
+}
+func (m *mockCertificateStore) Version(ctx context.Context, filter repository.CertificateFilter) (string, error) {
+	if m.versionFn == nil {
+		return "", nil
+	}
+	return m.versionFn(ctx, filter)
+}
+func (m *mockCertificateStore) DistinctIssuers(ctx context.Context, limit int) ([]string, error) {
+	return m.issuersFn(ctx, limit)
+}
+func (m *mockCertificateStore) SimilarDomains(ctx context.Context, domain string, threshold float64) ([]model.SimilarDomain, error) {
+	return m.similarFn(ctx, domain, threshold)
 }
-func (m *mockCertificateStore) ExportAll(ctx context.Context) ([]model.MatchedCertificate, error) {
-	return m.exportAllFn(ctx)
+func (m *mockCertificateStore) GetByID(ctx context.Context, id int) (*model.MatchedCertificate, error) {
+	return m.getByIDFn(ctx, id)
+}
+func (m *mockCertificateStore) DeleteWhere(ctx context.Context, filter repository.CertificateFilter) (int64, error) {
+	return m.deleteWhereFn(ctx, filter)
+}
+func (m *mockCertificateStore) ListConsolidated(ctx context.Context, page, perPage int, filter repository.ConsolidatedFilter) ([]model.Certificate, int, error) {
+	return m.listConsolidatedFn(ctx, page, perPage, filter)
+}
+
+// exportRows returns an exportEachFn that yields certs.
+func exportRows(certs ...model.MatchedCertificate) func(context.Context, repository.CertificateFilter, func(model.MatchedCertificate) error) error {
+	return func(ctx context.Context, _ repository.CertificateFilter, fn func(model.MatchedCertificate) error) error {
+		for _, c := range certs {
+			if err := fn(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
 }
 
 func sampleCert() model.MatchedCertificate {
 	return model.MatchedCertificate{
-		ID:            1,
-		SerialNumber:  "abc123",
-		CommonName:    "example.com",
-		SANs:          []string{"www.example.com"},
-		Issuer:        "Let's Encrypt",
-		NotBefore:     time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
-		NotAfter:      time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
-		KeywordID:     1,
-		KeywordValue:  "example",
-		MatchedDomain: "example.com",
-		CTLogIndex:    999,
-		DiscoveredAt:  time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+		ID:                1,
+		SerialNumber:      "abc123",
+		CommonName:        "example.com",
+		SANs:              []string{"www.example.com"},
+		Issuer:            "Let's Encrypt",
+		NotBefore:         time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:          time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		KeywordID:         1,
+		KeywordValue:      "example",
+		MatchedDomain:     "example.com",
+		CTLogIndex:        999,
+		DiscoveredAt:      time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+		RegistrableDomain: "example.com",
 	}
 }
 
 func TestCertificateList_Defaults(t *testing.T) {
 	h := NewCertificateHandler(&mockCertificateStore{
-		listPaginatedFn: func(ctx context.Context, page, perPage, keywordID int) ([]model.MatchedCertificate, int, error) {
+		listPaginatedFn: func(ctx context.Context, page, perPage int, filter repository.CertificateFilter) ([]model.MatchedCertificate, int, error) {
 			if page != 1 {
 				t.Errorf("page = %d, want 1", page)
 			}
 			if perPage != 20 {
 				t.Errorf("perPage = %d, want 20", perPage)
 			}
-			if keywordID != 0 {
-				t.Errorf("keywordID = %d, want 0", keywordID)
+			if filter.KeywordID != 0 {
+				t.Errorf("KeywordID = %d, want 0", filter.KeywordID)
 			}
 			return []model.MatchedCertificate{sampleCert()}, 1, nil
 		},
-	})
+	}, &mockAuditRecorder{})
 
 	req := httptest.NewRequest(http.MethodGet, "/certificates", nil)
 	rec := httptest.NewRecorder()
@@ -78,7 +127,7 @@ func TestCertificateList_Defaults(t *testing.T) {
 
 func TestCertificateList_CustomPagination(t *testing.T) {
 	h := NewCertificateHandler(&mockCertificateStore{
-		listPaginatedFn: func(ctx context.Context, page, perPage, keywordID int) ([]model.MatchedCertificate, int, error) {
+		listPaginatedFn: func(ctx context.Context, page, perPage int, filter repository.CertificateFilter) ([]model.MatchedCertificate, int, error) {
 			if page != 3 {
 				t.Errorf("page = %d, want 3", page)
 			}
@@ -87,7 +136,7 @@ func TestCertificateList_CustomPagination(t *testing.T) {
 			}
 			return nil, 0, nil
 		},
-	})
+	}, &mockAuditRecorder{})
 
 	req := httptest.NewRequest(http.MethodGet, "/certificates?page=3&per_page=50", nil)
 	rec := httptest.NewRecorder()
@@ -100,13 +149,13 @@ func TestCertificateList_CustomPagination(t *testing.T) {
 
 func TestCertificateList_KeywordFilter(t *testing.T) {
 	h := NewCertificateHandler(&mockCertificateStore{
-		listPaginatedFn: func(ctx context.Context, page, perPage, keywordID int) ([]model.MatchedCertificate, int, error) {
-			if keywordID != 5 {
-				t.Errorf("keywordID = %d, want 5", keywordID)
+		listPaginatedFn: func(ctx context.Context, page, perPage int, filter repository.CertificateFilter) ([]model.MatchedCertificate, int, error) {
+			if filter.KeywordID != 5 {
+				t.Errorf("KeywordID = %d, want 5", filter.KeywordID)
 			}
 			return nil, 0, nil
 		},
-	})
+	}, &mockAuditRecorder{})
 
 	req := httptest.NewRequest(http.MethodGet, "/certificates?keyword=5", nil)
 	rec := httptest.NewRecorder()
@@ -117,15 +166,496 @@ func TestCertificateList_KeywordFilter(t *testing.T) {
 	}
 }
 
+func TestCertificateList_StatusFilter(t *testing.T) {
+	h := NewCertificateHandler(&mockCertificateStore{
+		listPaginatedFn: func(ctx context.Context, page, perPage int, filter repository.CertificateFilter) ([]model.MatchedCertificate, int, error) {
+			if filter.Status != model.CertStatusAcknowledged {
+				t.Errorf("Status = %q, want %q", filter.Status, model.CertStatusAcknowledged)
+			}
+			return nil, 0, nil
+		},
+	}, &mockAuditRecorder{})
+
+	req := httptest.NewRequest(http.MethodGet, "/certificates?status=acknowledged", nil)
+	rec := httptest.NewRecorder()
+	h.List(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestCertificateList_InvalidStatus(t *testing.T) {
+	h := NewCertificateHandler(&mockCertificateStore{}, &mockAuditRecorder{})
+
+	req := httptest.NewRequest(http.MethodGet, "/certificates?status=bogus", nil)
+	rec := httptest.NewRecorder()
+	h.List(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestCertificateList_CNNotInSANsFilter(t *testing.T) {
+	h := NewCertificateHandler(&mockCertificateStore{
+		listPaginatedFn: func(ctx context.Context, page, perPage int, filter repository.CertificateFilter) ([]model.MatchedCertificate, int, error) {
+			if !filter.CNNotInSANs {
+				t.Error("CNNotInSANs = false, want true")
+			}
+			return nil, 0, nil
+		},
+	}, &mockAuditRecorder{})
+
+	req := httptest.NewRequest(http.MethodGet, "/certificates?cn_not_in_sans=true", nil)
+	rec := httptest.NewRecorder()
+	h.List(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestCertificateList_MinSANsFilter(t *testing.T) {
+	h := NewCertificateHandler(&mockCertificateStore{
+		listPaginatedFn: func(ctx context.Context, page, perPage int, filter repository.CertificateFilter) ([]model.MatchedCertificate, int, error) {
+			if filter.MinSANs != 50 {
+				t.Errorf("MinSANs = %d, want 50", filter.MinSANs)
+			}
+			return nil, 0, nil
+		},
+	}, &mockAuditRecorder{})
+
+	req := httptest.NewRequest(http.MethodGet, "/certificates?min_sans=50", nil)
+	rec := httptest.NewRecorder()
+	h.List(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestCertificateList_SANFiltersCombine(t *testing.T) {
+	var got repository.CertificateFilter
+	h := NewCertificateHandler(&mockCertificateStore{
+		listPaginatedFn: func(ctx context.Context, page, perPage int, filter repository.CertificateFilter) ([]model.MatchedCertificate, int, error) {
+			got = filter
+			return nil, 0, nil
+		},
+	}, &mockAuditRecorder{})
+
+	req := httptest.NewRequest(http.MethodGet, "/certificates?keyword=3&status=new&san=+Login.PayPal.com+&min_sans=1&max_sans=1", nil)
+	rec := httptest.NewRecorder()
+	h.List(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	want := repository.CertificateFilter{KeywordID: 3, Status: "new", SAN: "Login.PayPal.com", MinSANs: 1, MaxSANs: 1}
+	if got.KeywordID != want.KeywordID || got.Status != want.Status || got.SAN != want.SAN ||
+		got.MinSANs != want.MinSANs || got.MaxSANs != want.MaxSANs {
+		t.Errorf("filter = %+v, want %+v", got, want)
+	}
+}
+
+func TestCertificateList_GroupFilter(t *testing.T) {
+	var got repository.CertificateFilter
+	h := NewCertificateHandler(&mockCertificateStore{
+		listPaginatedFn: func(ctx context.Context, page, perPage int, filter repository.CertificateFilter) ([]model.MatchedCertificate, int, error) {
+			got = filter
+			return nil, 0, nil
+		},
+	}, &mockAuditRecorder{})
+
+	rec := httptest.NewRecorder()
+	h.List(rec, httptest.NewRequest(http.MethodGet, "/certificates?group=2&status=new", nil))
+	if rec.Code != http.StatusOK || got.GroupID != 2 || got.Status != "new" {
+		t.Errorf("status = %d, filter = %+v; want 200 with group 2 and status new", rec.Code, got)
+	}
+
+	for _, v := range []string{"brand", "0", "-1"} {
+		rec = httptest.NewRecorder()
+		h.List(rec, httptest.NewRequest(http.MethodGet, "/certificates?group="+v, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("group=%s: status = %d, want %d", v, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestCertificateList_ServerAuthFilter(t *testing.T) {
+	h := NewCertificateHandler(&mockCertificateStore{
+		listPaginatedFn: func(ctx context.Context, page, perPage int, filter repository.CertificateFilter) ([]model.MatchedCertificate, int, error) {
+			if filter.ServerAuth == nil || *filter.ServerAuth {
+				t.Errorf("ServerAuth = %v, want false", filter.ServerAuth)
+			}
+			return nil, 0, nil
+		},
+	}, &mockAuditRecorder{})
+
+	req := httptest.NewRequest(http.MethodGet, "/certificates?server_auth=false", nil)
+	rec := httptest.NewRecorder()
+	h.List(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/certificates?server_auth=maybe", nil)
+	rec = httptest.NewRecorder()
+	h.List(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("server_auth=maybe: status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestCertificateList_MaxDomainAgeFilter(t *testing.T) {
+	h := NewCertificateHandler(&mockCertificateStore{
+		listPaginatedFn: func(ctx context.Context, page, perPage int, filter repository.CertificateFilter) ([]model.MatchedCertificate, int, error) {
+			if filter.MaxDomainAgeDays == nil || *filter.MaxDomainAgeDays != 7 {
+				t.Errorf("MaxDomainAgeDays = %v, want 7", filter.MaxDomainAgeDays)
+			}
+			return nil, 0, nil
+		},
+	}, &mockAuditRecorder{})
+
+	req := httptest.NewRequest(http.MethodGet, "/certificates?max_domain_age_days=7", nil)
+	rec := httptest.NewRecorder()
+	h.List(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	for _, v := range []string{"new", "-1"} {
+		req = httptest.NewRequest(http.MethodGet, "/certificates?max_domain_age_days="+v, nil)
+		rec = httptest.NewRecorder()
+		h.List(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("max_domain_age_days=%s: status = %d, want %d", v, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestCertificateList_ChainStatusFilter(t *testing.T) {
+	h := NewCertificateHandler(&mockCertificateStore{
+		listPaginatedFn: func(ctx context.Context, page, perPage int, filter repository.CertificateFilter) ([]model.MatchedCertificate, int, error) {
+			if filter.ChainStatus != model.ChainUnknownIssuer {
+				t.Errorf("ChainStatus = %q, want unknown_issuer", filter.ChainStatus)
+			}
+			return nil, 0, nil
+		},
+	}, &mockAuditRecorder{})
+
+	req := httptest.NewRequest(http.MethodGet, "/certificates?chain_status=unknown_issuer", nil)
+	rec := httptest.NewRecorder()
+	h.List(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/certificates?chain_status=trusted", nil)
+	rec = httptest.NewRecorder()
+	h.List(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("chain_status=trusted: status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestCertificateDelete_ByFilter(t *testing.T) {
+	audit := &mockAuditRecorder{}
+	h := NewCertificateHandler(&mockCertificateStore{
+		deleteWhereFn: func(ctx context.Context, filter repository.CertificateFilter) (int64, error) {
+			want := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+			if filter.KeywordID != 5 || !filter.DiscoveredBefore.Equal(want) {
+				t.Errorf("filter = %+v, want keyword 5 discovered before %v", filter, want)
+			}
+			return 3, nil
+		},
+	}, audit)
+
+	req := httptest.NewRequest(http.MethodDelete, "/certificates?keyword=5&discovered_before=2025-01-01T00:00:00Z", nil)
+	rec := httptest.NewRecorder()
+	h.Delete(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var body struct {
+		Deleted int64 `json:"deleted"`
+	}
+	json.NewDecoder(rec.Body).Decode(&body)
+	if body.Deleted != 3 {
+		t.Errorf("deleted = %d, want 3", body.Deleted)
+	}
+	if len(audit.calls) != 1 || audit.calls[0].entityType != model.AuditEntityCertificate ||
+		audit.calls[0].changes["deleted"].Old != "3" {
+		t.Errorf("audit calls = %+v, want one certificate delete of 3", audit.calls)
+	}
+}
+
+func TestCertificateDelete_RefusesUnfiltered(t *testing.T) {
+	var calls int
+	h := NewCertificateHandler(&mockCertificateStore{
+		deleteWhereFn: func(ctx context.Context, filter repository.CertificateFilter) (int64, error) {
+			calls++
+			if !filter.IsZero() {
+				t.Errorf("filter = %+v, want none", filter)
+			}
+			return 7, nil
+		},
+	}, &mockAuditRecorder{})
+
+	// An invalid keyword is rejected rather than dropped, so it can never
+	// widen a delete it was meant to narrow.
+	for _, target := range []string{
+		"/certificates",
+		"/certificates?keyword=abc",
+		"/certificates?keyword=abc&status=resolved",
+		"/certificates?keyword=0&status=resolved",
+		"/certificates?all=1",
+	} {
+		rec := httptest.NewRecorder()
+		h.Delete(rec, httptest.NewRequest(http.MethodDelete, target, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", target, rec.Code, http.StatusBadRequest)
+		}
+	}
+	if calls != 0 {
+		t.Fatalf("DeleteWhere called %d times for unfiltered requests", calls)
+	}
+
+	rec := httptest.NewRecorder()
+	h.Delete(rec, httptest.NewRequest(http.MethodDelete, "/certificates?all=true", nil))
+	if rec.Code != http.StatusOK || calls != 1 {
+		t.Errorf("all=true: status = %d, calls = %d; want %d and 1", rec.Code, calls, http.StatusOK)
+	}
+}
+
+func TestCertificateDelete_InvalidFilter(t *testing.T) {
+	h := NewCertificateHandler(&mockCertificateStore{}, &mockAuditRecorder{})
+
+	for _, q := range []string{"discovered_before=yesterday", "status=bogus&all=true"} {
+		rec := httptest.NewRecorder()
+		h.Delete(rec, httptest.NewRequest(http.MethodDelete, "/certificates?"+q, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", q, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestCertificateList_IssuerClassFilter(t *testing.T) {
+	h := NewCertificateHandler(&mockCertificateStore{
+		listPaginatedFn: func(ctx context.Context, page, perPage int, filter repository.CertificateFilter) ([]model.MatchedCertificate, int, error) {
+			if filter.IssuerClass != model.IssuerFreeAutomated {
+				t.Errorf("IssuerClass = %q, want free_automated", filter.IssuerClass)
+			}
+			return nil, 0, nil
+		},
+	}, &mockAuditRecorder{})
+
+	rec := httptest.NewRecorder()
+	h.List(rec, httptest.NewRequest(http.MethodGet, "/certificates?issuer_class=free_automated", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	rec = httptest.NewRecorder()
+	h.List(rec, httptest.NewRequest(http.MethodGet, "/certificates?issuer_class=cheap", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("issuer_class=cheap: status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestCertificateList_BaseDomainFilter(t *testing.T) {
+	var got string
+	h := NewCertificateHandler(&mockCertificateStore{
+		listPaginatedFn: func(ctx context.Context, page, perPage int, filter repository.CertificateFilter) ([]model.MatchedCertificate, int, error) {
+			got = filter.RegistrableDomain
+			return nil, 0, nil
+		},
+	}, &mockAuditRecorder{})
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"example.com", "example.com"},
+		{"login.secure.example.com", "example.com"},
+		{"%2A.example.co.uk", "example.co.uk"},
+		{"shop.example.co.uk", "example.co.uk"},
+		{"WWW.Example.COM.", "example.com"},
+		{"user.github.io", "user.github.io"},
+		// No registrable domain: matched as stored, the normalized host.
+		{"%2A.co.uk", "co.uk"},
+		{"localhost", "localhost"},
+	}
+	for _, tt := range tests {
+		got = ""
+		rec := httptest.NewRecorder()
+		h.List(rec, httptest.NewRequest(http.MethodGet, "/certificates?base_domain="+tt.query, nil))
+		if rec.Code != http.StatusOK || got != tt.want {
+			t.Errorf("base_domain=%s: status %d, filter %q; want 200, %q", tt.query, rec.Code, got, tt.want)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.List(rec, httptest.NewRequest(http.MethodGet, "/certificates?base_domain=.", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("base_domain=.: status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestCertificateList_Sort(t *testing.T) {
+	var got []string
+	h := NewCertificateHandler(&mockCertificateStore{
+		listPaginatedFn: func(ctx context.Context, page, perPage int, filter repository.CertificateFilter) ([]model.MatchedCertificate, int, error) {
+			got = append(got, filter.Sort)
+			return nil, 0, nil
+		},
+	}, &mockAuditRecorder{})
+
+	for _, q := range []string{"", "?sort=risk", "?sort=newest"} {
+		rec := httptest.NewRecorder()
+		h.List(rec, httptest.NewRequest(http.MethodGet, "/certificates"+q, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("%q: status = %d, want %d", q, rec.Code, http.StatusOK)
+		}
+	}
+	if want := []string{"", repository.SortRisk, repository.SortNewest}; !slices.Equal(got, want) {
+		t.Errorf("sorts = %q, want %q", got, want)
+	}
+
+	rec := httptest.NewRecorder()
+	h.List(rec, httptest.NewRequest(http.MethodGet, "/certificates?sort=oldest", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("sort=oldest: status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestCertificateList_InvalidMinSANs(t *testing.T) {
+	h := NewCertificateHandler(&mockCertificateStore{}, &mockAuditRecorder{})
+
+	for _, v := range []string{"many", "0", "-3"} {
+		req := httptest.NewRequest(http.MethodGet, "/certificates?min_sans="+v, nil)
+		rec := httptest.NewRecorder()
+		h.List(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("min_sans=%s: status = %d, want %d", v, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestCertificateList_InvalidSANFilters(t *testing.T) {
+	h := NewCertificateHandler(&mockCertificateStore{}, &mockAuditRecorder{})
+
+	for _, q := range []string{
+		"keyword=abc",
+		"keyword=0",
+		"max_sans=few",
+		"max_sans=0",
+		"min_sans=10&max_sans=5",
+		"san=" + strings.Repeat("a", 254),
+	} {
+		rec := httptest.NewRecorder()
+		h.List(rec, httptest.NewRequest(http.MethodGet, "/certificates?"+q, nil))
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", q, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestCertificateList_InvalidCNNotInSANs(t *testing.T) {
+	h := NewCertificateHandler(&mockCertificateStore{}, &mockAuditRecorder{})
+
+	req := httptest.NewRequest(http.MethodGet, "/certificates?cn_not_in_sans=maybe", nil)
+	rec := httptest.NewRecorder()
+	h.List(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestCertificateList_SinceID(t *testing.T) {
+	newer := sampleCert()
+	newer.ID = 8
+	h := NewCertificateHandler(&mockCertificateStore{
+		listSinceFn: func(ctx context.Context, limit int, filter repository.CertificateFilter) ([]model.MatchedCertificate, int, error) {
+			if filter.SinceID != 7 || filter.KeywordID != 3 {
+				t.Errorf("filter = %+v, want SinceID 7 and KeywordID 3", filter)
+			}
+			if limit != 20 {
+				t.Errorf("limit = %d, want 20", limit)
+			}
+			return []model.MatchedCertificate{newer}, 3, nil
+		},
+	}, &mockAuditRecorder{})
+
+	req := httptest.NewRequest(http.MethodGet, "/certificates?since_id=7&keyword=3", nil)
+	rec := httptest.NewRecorder()
+	h.List(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var body struct {
+		Certificates []model.MatchedCertificate `json:"certificates"`
+		Count        int                        `json:"count"`
+		LastID       int                        `json:"last_id"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(body.Certificates) != 1 || body.Count != 3 || body.LastID != 8 {
+		t.Errorf("body = %+v, want one certificate, count 3, last_id 8", body)
+	}
+}
+
+func TestCertificateList_SinceIDNoNewRows(t *testing.T) {
+	h := NewCertificateHandler(&mockCertificateStore{
+		listSinceFn: func(ctx context.Context, limit int, filter repository.CertificateFilter) ([]model.MatchedCertificate, int, error) {
+			return nil, 0, nil
+		},
+	}, &mockAuditRecorder{})
+
+	req := httptest.NewRequest(http.MethodGet, "/certificates?since_id=42", nil)
+	rec := httptest.NewRecorder()
+	h.List(rec, req)
+
+	if got := strings.TrimSpace(rec.Body.String()); got != `{"certificates":[],"count":0,"last_id":42}` {
+		t.Errorf("body = %s", got)
+	}
+}
+
+func TestCertificateList_InvalidSinceID(t *testing.T) {
+	h := NewCertificateHandler(&mockCertificateStore{}, &mockAuditRecorder{})
+
+	for _, v := range []string{"abc", "-1"} {
+		req := httptest.NewRequest(http.MethodGet, "/certificates?since_id="+v, nil)
+		rec := httptest.NewRecorder()
+		h.List(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("since_id=%s: status = %d, want %d", v, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
 func TestCertificateList_InvalidPage(t *testing.T) {
 	h := NewCertificateHandler(&mockCertificateStore{
-		listPaginatedFn: func(ctx context.Context, page, perPage, keywordID int) ([]model.MatchedCertificate, int, error) {
+		listPaginatedFn: func(ctx context.Context, page, perPage int, filter repository.CertificateFilter) ([]model.MatchedCertificate, int, error) {
 			if page != 1 {
 				t.Errorf("page = %d, want default 1 for invalid input", page)
 			}
 			return nil, 0, nil
 		},
-	})
+	}, &mockAuditRecorder{})
 
 	req := httptest.NewRequest(http.MethodGet, "/certificates?page=-1", nil)
 	rec := httptest.NewRecorder()
@@ -138,13 +668,13 @@ func TestCertificateList_InvalidPage(t *testing.T) {
 
 func TestCertificateList_PerPageClamp(t *testing.T) {
 	h := NewCertificateHandler(&mockCertificateStore{
-		listPaginatedFn: func(ctx context.Context, page, perPage, keywordID int) ([]model.MatchedCertificate, int, error) {
+		listPaginatedFn: func(ctx context.Context, page, perPage int, filter repository.CertificateFilter) ([]model.MatchedCertificate, int, error) {
 			if perPage != 20 {
 				t.Errorf("perPage = %d, want default 20 for per_page>100", perPage)
 			}
 			return nil, 0, nil
 		},
-	})
+	}, &mockAuditRecorder{})
 
 	req := httptest.NewRequest(http.MethodGet, "/certificates?per_page=200", nil)
 	rec := httptest.NewRecorder()
@@ -157,10 +687,10 @@ func TestCertificateList_PerPageClamp(t *testing.T) {
 
 func TestCertificateList_NilCerts(t *testing.T) {
 	h := NewCertificateHandler(&mockCertificateStore{
-		listPaginatedFn: func(ctx context.Context, page, perPage, keywordID int) ([]model.MatchedCertificate, int, error) {
+		listPaginatedFn: func(ctx context.Context, page, perPage int, filter repository.CertificateFilter) ([]model.MatchedCertificate, int, error) {
 			return nil, 0, nil
 		},
-	})
+	}, &mockAuditRecorder{})
 
 	req := httptest.NewRequest(http.MethodGet, "/certificates", nil)
 	rec := httptest.NewRecorder()
@@ -181,10 +711,10 @@ func TestCertificateList_NilCerts(t *testing.T) {
 
 func TestCertificateList_Error(t *testing.T) {
 	h := NewCertificateHandler(&mockCertificateStore{
-		listPaginatedFn: func(ctx context.Context, page, perPage, keywordID int) ([]model.MatchedCertificate, int, error) {
+		listPaginatedFn: func(ctx context.Context, page, perPage int, filter repository.CertificateFilter) ([]model.MatchedCertificate, int, error) {
 			return nil, 0, errors.New("db error")
 		},
-	})
+	}, &mockAuditRecorder{})
 
 	req := httptest.NewRequest(http.MethodGet, "/certificates", nil)
 	rec := httptest.NewRecorder()
@@ -195,12 +725,206 @@ func TestCertificateList_Error(t *testing.T) {
 	}
 }
 
-func TestCertificateExport_Success(t *testing.T) {
+func TestCertificateList_QueryTimeout(t *testing.T) {
+	h := NewCertificateHandler(&mockCertificateStore{
+		listPaginatedFn: func(ctx context.Context, page, perPage int, filter repository.CertificateFilter) ([]model.MatchedCertificate, int, error) {
+			return nil, 0, &pgconn.PgError{Code: "57014", Message: "canceling statement due to statement timeout"}
+		},
+	}, &mockAuditRecorder{})
+
+	req := httptest.NewRequest(http.MethodGet, "/certificates?search=x", nil)
+	rec := httptest.NewRecorder()
+	h.List(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "query timed out") {
+		t.Errorf("status = %d, body = %s; want 503 query timed out", rec.Code, rec.Body)
+	}
+}
+
+func TestCertificateList_ETag(t *testing.T) {
+	version := "5-5-0"
+	listed := 0
+	h := NewCertificateHandler(&mockCertificateStore{
+		listPaginatedFn: func(ctx context.Context, page, perPage int, filter repository.CertificateFilter) ([]model.MatchedCertificate, int, error) {
+			listed++
+			return []model.MatchedCertificate{sampleCert()}, 1, nil
+		},
+		versionFn: func(ctx context.Context, filter repository.CertificateFilter) (string, error) {
+			if filter.Status != model.CertStatusNew {
+				t.Errorf("Status = %q, want %q", filter.Status, model.CertStatusNew)
+			}
+			return version, nil
+		},
+	}, &mockAuditRecorder{})
+
+	get := func(etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/certificates?status=new", nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rec := httptest.NewRecorder()
+		h.List(rec, req)
+		return rec
+	}
+
+	first := get("")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("first: status = %d, ETag = %q; want 200 with an ETag", first.Code, etag)
+	}
+
+	unchanged := get(etag)
+	if unchanged.Code != http.StatusNotModified {
+		t.Fatalf("unchanged: status = %d, want %d", unchanged.Code, http.StatusNotModified)
+	}
+	if unchanged.Body.Len() != 0 {
+		t.Errorf("unchanged: body = %q, want empty", unchanged.Body.String())
+	}
+	if listed != 1 {
+		t.Errorf("ListPaginated called %d times, want 1", listed)
+	}
+
+	version = "6-6-0"
+	changed := get(etag)
+	if changed.Code != http.StatusOK {
+		t.Fatalf("changed: status = %d, want %d", changed.Code, http.StatusOK)
+	}
+	if got := changed.Header().Get("ETag"); got == "" || got == etag {
+		t.Errorf("changed: ETag = %q, want a new non-empty value (old %q)", got, etag)
+	}
+}
+
+func TestCertificateList_ETagVariesWithQuery(t *testing.T) {
+	h := NewCertificateHandler(&mockCertificateStore{
+		listPaginatedFn: func(ctx context.Context, page, perPage int, filter repository.CertificateFilter) ([]model.MatchedCertificate, int, error) {
+			return nil, 0, nil
+		},
+		versionFn: func(ctx context.Context, filter repository.CertificateFilter) (string, error) {
+			return "5-5-0", nil
+		},
+	}, &mockAuditRecorder{})
+
+	etags := make(map[string]bool)
+	for _, target := range []string{"/certificates", "/certificates?page=2"} {
+		rec := httptest.NewRecorder()
+		h.List(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		etags[rec.Header().Get("ETag")] = true
+	}
+	if len(etags) != 2 {
+		t.Errorf("got ETags %v, want a distinct one per page", etags)
+	}
+}
+
+func TestCertificateList_AcceptCSV(t *testing.T) {
+	var gotPage, gotPerPage int
+	var gotFilter repository.CertificateFilter
+	h := NewCertificateHandler(&mockCertificateStore{
+		listPaginatedFn: func(ctx context.Context, page, perPage int, filter repository.CertificateFilter) ([]model.MatchedCertificate, int, error) {
+			gotPage, gotPerPage, gotFilter = page, perPage, filter
+			second := sampleCert()
+			second.ID, second.SANs = 2, nil
+			return []model.MatchedCertificate{sampleCert(), second}, 42, nil
+		},
+		versionFn: func(ctx context.Context, filter repository.CertificateFilter) (string, error) {
+			return "5-5-0", nil
+		},
+	}, &mockAuditRecorder{})
+
+	req := httptest.NewRequest(http.MethodGet, "/certificates?page=3&per_page=2&keyword=1&status=new", nil)
+	req.Header.Set("Accept", "text/csv")
+	rec := httptest.NewRecorder()
+	h.List(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if gotPage != 3 || gotPerPage != 2 || gotFilter.KeywordID != 1 || gotFilter.Status != model.CertStatusNew {
+		t.Errorf("listed page %d/%d with %+v, want page 3 of 2 filtered by keyword and status", gotPage, gotPerPage, gotFilter)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/csv" {
+		t.Errorf("Content-Type = %q, want text/csv", ct)
+	}
+	if got := rec.Header().Get("X-Total-Count"); got != "42" {
+		t.Errorf("X-Total-Count = %q, want 42", got)
+	}
+	if got := rec.Header().Get("Vary"); got != "Accept" {
+		t.Errorf("Vary = %q, want Accept", got)
+	}
+
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("read CSV: %v", err)
+	}
+	if len(records) != 3 || records[0][0] != "id" || records[1][0] != "1" || records[2][0] != "2" {
+		t.Fatalf("records = %q, want the header and both rows of the page", records)
+	}
+	if records[2][3] != "[]" {
+		t.Errorf("sans = %q, want []", records[2][3])
+	}
+
+	// The CSV and JSON representations of one page must not share an ETag.
+	jsonRec := httptest.NewRecorder()
+	h.List(jsonRec, httptest.NewRequest(http.MethodGet, "/certificates?page=3&per_page=2&keyword=1&status=new", nil))
+	if jsonRec.Header().Get("ETag") == rec.Header().Get("ETag") {
+		t.Error("CSV and JSON responses share an ETag")
+	}
+}
+
+func TestAcceptsCSV(t *testing.T) {
+	for _, tc := range []struct {
+		accept string
+		want   bool
+	}{
+		{"", false},
+		{"application/json", false},
+		{"*/*", false},
+		{"text/csv", true},
+		{"text/csv, */*", true},
+		{"application/json, text/csv", false},
+		{"application/json;q=0.5, text/csv", true},
+		{"text/csv;q=0.2, */*;q=0.8", false},
+		{"text/csv;q=0", false},
+		{"TEXT/CSV; charset=utf-8", true},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/certificates", nil)
+		req.Header.Set("Accept", tc.accept)
+		if got := acceptsCSV(req); got != tc.want {
+			t.Errorf("acceptsCSV(%q) = %v, want %v", tc.accept, got, tc.want)
+		}
+	}
+}
+
+func TestCertificateList_VersionErrorStillServes(t *testing.T) {
 	h := NewCertificateHandler(&mockCertificateStore{
-		exportAllFn: func(ctx context.Context) ([]model.MatchedCertificate, error) {
-			return []model.MatchedCertificate{sampleCert()}, nil
+		listPaginatedFn: func(ctx context.Context, page, perPage int, filter repository.CertificateFilter) ([]model.MatchedCertificate, int, error) {
+			return []model.MatchedCertificate{sampleCert()}, 1, nil
 		},
-	})
+		versionFn: func(ctx context.Context, filter repository.CertificateFilter) (string, error) {
+			return "", errors.New("db error")
+		},
+	}, &mockAuditRecorder{})
+
+	req := httptest.NewRequest(http.MethodGet, "/certificates", nil)
+	req.Header.Set("If-None-Match", "*")
+	rec := httptest.NewRecorder()
+	h.List(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if got := rec.Header().Get("ETag"); got != "" {
+		t.Errorf("ETag = %q, want none", got)
+	}
+}
+
+func TestCertificateExport_Success(t *testing.T) {
+	aged := sampleCert()
+	age := 4
+	aged.DomainAgeDays, aged.DomainAgeStatus = &age, model.DomainAgeKnown
+	aged.RiskScore, aged.IssuerClass = 45, model.IssuerFreeAutomated
+	h := NewCertificateHandler(&mockCertificateStore{
+		exportEachFn: exportRows(aged),
+	}, &mockAuditRecorder{})
 
 	req := httptest.NewRequest(http.MethodGet, "/certificates/export", nil)
 	rec := httptest.NewRecorder()
@@ -220,16 +944,131 @@ func TestCertificateExport_Success(t *testing.T) {
 	}
 	// Header + 1 data row
 	if len(records) != 2 {
-		t.Errorf("got %d CSV rows, want 2 (header + 1 data)", len(records))
+		t.Fatalf("got %d CSV rows, want 2 (header + 1 data)", len(records))
+	}
+	tail := len(records[0]) - 5
+	if got := strings.Join(records[0][tail:], ","); got != "registrable_domain,domain_age_days,domain_age_status,risk_score,issuer_class" {
+		t.Errorf("trailing columns = %s", got)
+	}
+	if got := strings.Join(records[1][tail:], ","); got != "example.com,4,known,45,free_automated" {
+		t.Errorf("trailing values = %s, want example.com,4,known,45,free_automated", got)
+	}
+}
+
+func TestCertificateExport_STIX(t *testing.T) {
+	h := NewCertificateHandler(&mockCertificateStore{
+		exportEachFn: exportRows(sampleCert()),
+	}, &mockAuditRecorder{})
+
+	req := httptest.NewRequest(http.MethodGet, "/certificates/export?format=stix", nil)
+	rec := httptest.NewRecorder()
+	h.Export(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/stix+json;version=2.1" {
+		t.Errorf("Content-Type = %q, want the STIX media type", ct)
+	}
+	var bundle struct {
+		Type    string `json:"type"`
+		Objects []struct {
+			Type string `json:"type"`
+		} `json:"objects"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&bundle); err != nil {
+		t.Fatalf("decode bundle: %v", err)
+	}
+	if bundle.Type != "bundle" || len(bundle.Objects) != 3 {
+		t.Errorf("bundle = %+v, want 3 objects", bundle)
+	}
+}
+
+func TestCertificateExport_UnknownFormat(t *testing.T) {
+	h := NewCertificateHandler(&mockCertificateStore{}, &mockAuditRecorder{})
+
+	rec := httptest.NewRecorder()
+	h.Export(rec, httptest.NewRequest(http.MethodGet, "/certificates/export?format=xml", nil))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestCertificateExport_PassesFilter(t *testing.T) {
+	for _, format := range []string{"csv", "stix"} {
+		var got repository.CertificateFilter
+		h := NewCertificateHandler(&mockCertificateStore{
+			exportEachFn: func(_ context.Context, filter repository.CertificateFilter, fn func(model.MatchedCertificate) error) error {
+				got = filter
+				return nil
+			},
+		}, &mockAuditRecorder{})
+
+		rec := httptest.NewRecorder()
+		h.Export(rec, httptest.NewRequest(http.MethodGet,
+			"/certificates/export?format="+format+"&keyword=3&status=resolved&san=shop.example.com&min_sans=2&max_sans=5", nil))
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, want %d", format, rec.Code, http.StatusOK)
+		}
+		if got.KeywordID != 3 || got.Status != "resolved" || got.SAN != "shop.example.com" ||
+			got.MinSANs != 2 || got.MaxSANs != 5 {
+			t.Errorf("%s: filter = %+v, want the query's filters", format, got)
+		}
+	}
+}
+
+func TestCertificateExport_InvalidFilter(t *testing.T) {
+	h := NewCertificateHandler(&mockCertificateStore{}, &mockAuditRecorder{})
+
+	rec := httptest.NewRecorder()
+	h.Export(rec, httptest.NewRequest(http.MethodGet, "/certificates/export?max_sans=few", nil))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestCertificateExport_SANsRoundTrip(t *testing.T) {
+	cert := sampleCert()
+	cert.SANs = []string{"a;b.example.com", `quo"te.example.com`, "comma,example.com"}
+	cert.Issuer = "CN=Test, O=\"Acme, Inc.\"\nLine2"
+	h := NewCertificateHandler(&mockCertificateStore{
+		exportEachFn: exportRows(cert),
+	}, &mockAuditRecorder{})
+
+	req := httptest.NewRequest(http.MethodGet, "/certificates/export", nil)
+	rec := httptest.NewRecorder()
+	h.Export(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("read CSV: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d CSV rows, want 2", len(records))
+	}
+
+	var sans []string
+	if err := json.Unmarshal([]byte(records[1][3]), &sans); err != nil {
+		t.Fatalf("sans column %q is not a JSON array: %v", records[1][3], err)
+	}
+	if len(sans) != 3 || sans[0] != cert.SANs[0] || sans[1] != cert.SANs[1] || sans[2] != cert.SANs[2] {
+		t.Errorf("sans = %q, want %q", sans, cert.SANs)
+	}
+	if records[1][4] != cert.Issuer {
+		t.Errorf("issuer = %q, want %q", records[1][4], cert.Issuer)
 	}
 }
 
 func TestCertificateExport_Empty(t *testing.T) {
 	h := NewCertificateHandler(&mockCertificateStore{
-		exportAllFn: func(ctx context.Context) ([]model.MatchedCertificate, error) {
-			return nil, nil
-		},
-	})
+		exportEachFn: exportRows(),
+	}, &mockAuditRecorder{})
 
 	req := httptest.NewRequest(http.MethodGet, "/certificates/export", nil)
 	rec := httptest.NewRecorder()
@@ -248,16 +1087,321 @@ func TestCertificateExport_Empty(t *testing.T) {
 
 func TestCertificateExport_Error(t *testing.T) {
 	h := NewCertificateHandler(&mockCertificateStore{
-		exportAllFn: func(ctx context.Context) ([]model.MatchedCertificate, error) {
-			return nil, errors.New("db error")
+		exportEachFn: func(ctx context.Context, _ repository.CertificateFilter, fn func(model.MatchedCertificate) error) error {
+			return errors.New("db error")
 		},
-	})
+	}, &mockAuditRecorder{})
+
+	// A query that fails before the first row is a clean JSON 500 in
+	// every format, with none of the download headers.
+	for _, format := range []string{"csv", "stix"} {
+		req := httptest.NewRequest(http.MethodGet, "/certificates/export?format="+format, nil)
+		rec := httptest.NewRecorder()
+		h.Export(rec, req)
+
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("%s: status = %d, want %d", format, rec.Code, http.StatusInternalServerError)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s: Content-Type = %q, want application/json", format, ct)
+		}
+		if cd := rec.Header().Get("Content-Disposition"); cd != "" {
+			t.Errorf("%s: Content-Disposition = %q, want none on error", format, cd)
+		}
+		var body map[string]string
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body["error"] == "" {
+			t.Errorf("%s: body = %v (%v), want a JSON error", format, body, err)
+		}
+	}
+}
+
+func TestCertificateExport_MidStreamError(t *testing.T) {
+	h := NewCertificateHandler(&mockCertificateStore{
+		exportEachFn: func(ctx context.Context, _ repository.CertificateFilter, fn func(model.MatchedCertificate) error) error {
+			if err := fn(sampleCert()); err != nil {
+				return err
+			}
+			return errors.New("connection reset")
+		},
+	}, &mockAuditRecorder{})
 
 	req := httptest.NewRequest(http.MethodGet, "/certificates/export", nil)
 	rec := httptest.NewRecorder()
+	func() {
+		defer func() {
+			if p := recover(); p != http.ErrAbortHandler {
+				t.Errorf("panic = %v, want http.ErrAbortHandler", p)
+			}
+		}()
+		h.Export(rec, req)
+	}()
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d (already sent)", rec.Code, http.StatusOK)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/csv" {
+		t.Errorf("Content-Type = %q, want text/csv", ct)
+	}
+	if strings.Contains(rec.Body.String(), `"error"`) {
+		t.Errorf("body = %q, must not contain a JSON error after streaming began", rec.Body.String())
+	}
+}
+
+func TestCertificateExport_StopsWhenClientCancels(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	offered := 0
+	var exportErr error
+	h := NewCertificateHandler(&mockCertificateStore{
+		exportEachFn: func(_ context.Context, _ repository.CertificateFilter, fn func(model.MatchedCertificate) error) error {
+			for offered < 10000 {
+				offered++
+				if offered == 3 {
+					cancel()
+				}
+				if err := fn(sampleCert()); err != nil {
+					exportErr = err
+					return err
+				}
+			}
+			return nil
+		},
+	}, &mockAuditRecorder{})
+
+	req := httptest.NewRequest(http.MethodGet, "/certificates/export", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
 	h.Export(rec, req)
 
-	if rec.Code != http.StatusInternalServerError {
-		t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	if offered != 3 {
+		t.Errorf("rows offered = %d, want the export to stop at the cancel (3)", offered)
+	}
+	if !errors.Is(exportErr, context.Canceled) {
+		t.Errorf("row callback error = %v, want context.Canceled", exportErr)
+	}
+	if strings.Contains(rec.Body.String(), `"error"`) {
+		t.Errorf("body = %q, must not contain a JSON error", rec.Body.String())
+	}
+}
+
+func TestCertificateIssuers(t *testing.T) {
+	tests := []struct {
+		query     string
+		wantLimit int
+	}{
+		{"", 100},
+		{"?limit=5", 5},
+		{"?limit=5000", 100},
+		{"?limit=zero", 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			var gotLimit int
+			h := NewCertificateHandler(&mockCertificateStore{
+				issuersFn: func(ctx context.Context, limit int) ([]string, error) {
+					gotLimit = limit
+					return []string{"CN=R3, O=Let's Encrypt", "CN=Other CA"}, nil
+				},
+			}, &mockAuditRecorder{})
+
+			rec := httptest.NewRecorder()
+			h.Issuers(rec, httptest.NewRequest(http.MethodGet, "/certificates/issuers"+tt.query, nil))
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", rec.Code)
+			}
+			if gotLimit != tt.wantLimit {
+				t.Errorf("limit = %d, want %d", gotLimit, tt.wantLimit)
+			}
+			var body struct {
+				Issuers []string `json:"issuers"`
+				Limit   int      `json:"limit"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if len(body.Issuers) != 2 || body.Issuers[0] != "CN=R3, O=Let's Encrypt" || body.Limit != tt.wantLimit {
+				t.Errorf("body = %+v", body)
+			}
+		})
+	}
+}
+
+func TestCertificateIssuers_EmptyIsArray(t *testing.T) {
+	h := NewCertificateHandler(&mockCertificateStore{
+		issuersFn: func(ctx context.Context, limit int) ([]string, error) { return nil, nil },
+	}, &mockAuditRecorder{})
+
+	rec := httptest.NewRecorder()
+	h.Issuers(rec, httptest.NewRequest(http.MethodGet, "/certificates/issuers", nil))
+
+	if !strings.Contains(rec.Body.String(), `"issuers":[]`) {
+		t.Errorf("body = %s, want an empty issuers array", rec.Body)
+	}
+}
+
+func TestCertificateSimilar(t *testing.T) {
+	var gotDomain string
+	var gotThreshold float64
+	h := NewCertificateHandler(&mockCertificateStore{
+		similarFn: func(ctx context.Context, domain string, threshold float64) ([]model.SimilarDomain, error) {
+			gotDomain, gotThreshold = domain, threshold
+			return []model.SimilarDomain{{Domain: "paypa1-login.com", Similarity: 0.79, Count: 3}}, nil
+		},
+	}, &mockAuditRecorder{})
+
+	rec := httptest.NewRecorder()
+	h.Similar(rec, httptest.NewRequest(http.MethodGet, "/certificates/similar?domain=+PayPal-Login.com+&threshold=0.5", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if gotDomain != "paypal-login.com" || gotThreshold != 0.5 {
+		t.Errorf("searched %q at %v, want paypal-login.com at 0.5", gotDomain, gotThreshold)
+	}
+	var body struct {
+		Similar []model.SimilarDomain `json:"similar"`
+	}
+	json.NewDecoder(rec.Body).Decode(&body)
+	if len(body.Similar) != 1 || body.Similar[0].Count != 3 {
+		t.Errorf("similar = %+v, want the one stored domain", body.Similar)
+	}
+}
+
+func TestCertificateSimilar_Validation(t *testing.T) {
+	h := NewCertificateHandler(&mockCertificateStore{
+		similarFn: func(ctx context.Context, domain string, threshold float64) ([]model.SimilarDomain, error) {
+			if threshold != defaultSimilarityThreshold {
+				t.Errorf("threshold = %v, want the default", threshold)
+			}
+			return nil, nil
+		},
+	}, &mockAuditRecorder{})
+
+	for query, want := range map[string]int{
+		"?domain=a.com":               http.StatusOK,
+		"":                            http.StatusBadRequest,
+		"?domain=+":                   http.StatusBadRequest,
+		"?domain=a.com&threshold=0":   http.StatusBadRequest,
+		"?domain=a.com&threshold=1":   http.StatusBadRequest,
+		"?domain=a.com&threshold=abc": http.StatusBadRequest,
+	} {
+		rec := httptest.NewRecorder()
+		h.Similar(rec, httptest.NewRequest(http.MethodGet, "/certificates/similar"+query, nil))
+		if rec.Code != want {
+			t.Errorf("%q: status = %d, want %d", query, rec.Code, want)
+		}
+		if want == http.StatusOK && !strings.Contains(rec.Body.String(), `"similar":[]`) {
+			t.Errorf("%q: body = %s, want an empty similar array", query, rec.Body)
+		}
+	}
+}
+
+func TestCertificateGet(t *testing.T) {
+	first := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	h := NewCertificateHandler(&mockCertificateStore{
+		getByIDFn: func(ctx context.Context, id int) (*model.MatchedCertificate, error) {
+			if id != 7 {
+				return nil, repository.ErrNotFound
+			}
+			return &model.MatchedCertificate{ID: 7, CTLogIndex: 90,
+				FirstSeenIndex: 40, FirstSeenAt: first, LastSeenIndex: 90, LastSeenAt: first.Add(time.Hour)}, nil
+		},
+	}, &mockAuditRecorder{})
+
+	rec := httptest.NewRecorder()
+	h.Get(rec, chiRequest(http.MethodGet, "/certificates/7", map[string]string{"id": "7"}))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	for _, want := range []string{`"first_seen_index":40`, `"first_seen_at":"2025-03-01T12:00:00Z"`,
+		`"last_seen_index":90`, `"last_seen_at":"2025-03-01T13:00:00Z"`} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("body = %s, want %s", rec.Body, want)
+		}
+	}
+
+	for id, want := range map[string]int{"8": http.StatusNotFound, "abc": http.StatusBadRequest} {
+		rec := httptest.NewRecorder()
+		h.Get(rec, chiRequest(http.MethodGet, "/certificates/"+id, map[string]string{"id": id}))
+		if rec.Code != want {
+			t.Errorf("id %q: status = %d, want %d", id, rec.Code, want)
+		}
+	}
+}
+
+func TestCertificateList_IssuerFilter(t *testing.T) {
+	var got repository.CertificateFilter
+	h := NewCertificateHandler(&mockCertificateStore{
+		listPaginatedFn: func(ctx context.Context, page, perPage int, filter repository.CertificateFilter) ([]model.MatchedCertificate, int, error) {
+			got = filter
+			return nil, 0, nil
+		},
+	}, &mockAuditRecorder{})
+
+	req := httptest.NewRequest(http.MethodGet, "/certificates?issuer=CN%3DR3%2C+O%3DLet%27s+Encrypt", nil)
+	h.List(httptest.NewRecorder(), req)
+
+	if got.Issuer != "CN=R3, O=Let's Encrypt" {
+		t.Errorf("Issuer filter = %q", got.Issuer)
+	}
+}
+
+func TestCertificateConsolidated(t *testing.T) {
+	var got repository.ConsolidatedFilter
+	h := NewCertificateHandler(&mockCertificateStore{
+		listConsolidatedFn: func(ctx context.Context, page, perPage int, filter repository.ConsolidatedFilter) ([]model.Certificate, int, error) {
+			got = filter
+			if page != 2 || perPage != 5 {
+				t.Errorf("page %d/%d, want 2/5", page, perPage)
+			}
+			return []model.Certificate{{
+				ID: 1, SerialNumber: "abc",
+				Keywords: []model.CertificateKeyword{{KeywordID: 3, Keyword: "paypal"}, {KeywordID: 4, Keyword: "login"}},
+			}}, 6, nil
+		},
+	}, &mockAuditRecorder{})
+
+	rec := httptest.NewRecorder()
+	h.Consolidated(rec, httptest.NewRequest(http.MethodGet, "/certificates/consolidated?page=2&per_page=5&keyword=3&min_keywords=2", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if got.KeywordID != 3 || got.MinKeywords != 2 {
+		t.Errorf("filter = %+v, want keyword 3, min_keywords 2", got)
+	}
+	var body struct {
+		Certificates []model.Certificate `json:"certificates"`
+		Total        int                 `json:"total"`
+	}
+	json.NewDecoder(rec.Body).Decode(&body)
+	if body.Total != 6 || len(body.Certificates) != 1 || len(body.Certificates[0].Keywords) != 2 {
+		t.Errorf("body = %+v, want one certificate with two keywords of 6", body)
+	}
+}
+
+func TestCertificateConsolidated_Validation(t *testing.T) {
+	h := NewCertificateHandler(&mockCertificateStore{
+		listConsolidatedFn: func(ctx context.Context, page, perPage int, filter repository.ConsolidatedFilter) ([]model.Certificate, int, error) {
+			return nil, 0, nil
+		},
+	}, &mockAuditRecorder{})
+
+	for query, want := range map[string]int{
+		"":                 http.StatusOK,
+		"?keyword=abc":     http.StatusBadRequest,
+		"?keyword=0":       http.StatusBadRequest,
+		"?min_keywords=0":  http.StatusBadRequest,
+		"?min_keywords=xy": http.StatusBadRequest,
+	} {
+		rec := httptest.NewRecorder()
+		h.Consolidated(rec, httptest.NewRequest(http.MethodGet, "/certificates/consolidated"+query, nil))
+		if rec.Code != want {
+			t.Errorf("%q: status = %d, want %d", query, rec.Code, want)
+		}
+		if want == http.StatusOK && !strings.Contains(rec.Body.String(), `"certificates":[]`) {
+			t.Errorf("%q: body = %s, want an empty certificates array", query, rec.Body)
+		}
 	}
 }
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// settingsSource is the part of config.Config the handler reads.
+type settingsSource interface {
+	Redacted() map[string]string
+}
+
+type ConfigHandler struct {
+	cfg settingsSource
+}
+
+// NewConfigHandler serves the configuration the process loaded, with
+// credentials masked as in the startup log.
+func NewConfigHandler(cfg settingsSource) *ConfigHandler {
+	return &ConfigHandler{cfg: cfg}
+}
+
+// RegisterAdminRoutes registers the configuration view; even redacted, it
+// names hosts, paths and network ranges, so mount it behind the admin
+// allowlist.
+func (h *ConfigHandler) RegisterAdminRoutes(r chi.Router) {
+	r.Get("/config", h.Get)
+}
+
+// Get returns every setting as the startup log reports it, keyed by its
+// log name (database_url, ct_log_url, monitor_interval, ...).
+func (h *ConfigHandler) Get(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.cfg.Redacted())
+}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/config"
+)
+
+func TestConfigGet_RedactsSecrets(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://ctmonitor:s3cret@db:5432/ct_monitor?sslmode=disable")
+	t.Setenv("NOTIFY_WEBHOOK_URL", "https://hooks.example.com/services/T000/B000/tok3n")
+	t.Setenv("CT_LOG_URL", "https://ct.example.com/2026h2")
+	t.Setenv("MONITOR_INTERVAL", "45s")
+	t.Setenv("CORS_ALLOW_ORIGIN", "https://app.example.com")
+
+	rec := httptest.NewRecorder()
+	NewConfigHandler(config.Load()).Get(rec, httptest.NewRequest(http.MethodGet, "/config", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if body := rec.Body.String(); strings.Contains(body, "s3cret") || strings.Contains(body, "tok3n") {
+		t.Fatalf("body leaks a secret: %s", body)
+	}
+	var got map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := map[string]string{
+		"database_url":       "postgres://ctmonitor:xxxxx@db:5432/xxxxx",
+		"notify_webhook_url": "https://hooks.example.com/xxxxx",
+		"ct_log_url":         "https://ct.example.com/2026h2",
+		"monitor_interval":   "45s",
+		"monitor_batch_size": "100",
+		"cors_allow_origin":  "https://app.example.com",
+	}
+	for key, v := range want {
+		if got[key] != v {
+			t.Errorf("%s = %v, want %v", key, got[key], v)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/ctlog"
+)
+
+// ctlogCheckTimeout bounds the get-sth call of one check.
+const ctlogCheckTimeout = 10 * time.Second
+
+type sthGetter interface {
+	GetSTH(ctx context.Context) (*ctlog.STH, error)
+}
+
+// CTLogHandler checks whether a CT log answers get-sth, without involving
+// the monitor.
+type CTLogHandler struct {
+	configuredURL string
+	allowedHosts  map[string]bool
+	newClient     func(baseURL string) sthGetter
+	minInterval   time.Duration
+
+	mu        sync.Mutex
+	lastCheck time.Time
+}
+
+// NewCTLogHandler checks configuredURL (CT_LOG_URL) unless a request names
+// another log. Only logs on configuredURL's host or on allowedHosts
+// (ALLOWED_CT_LOG_URLS, see ctlog.ParseAllowedHosts) are fetched, and
+// redirects are only followed to those hosts, so the check cannot be
+// pointed at internal services. Checks are spaced at least
+// minInterval apart across all clients, since each one makes an outbound
+// request.
+func NewCTLogHandler(configuredURL string, allowedHosts []string, minInterval time.Duration) *CTLogHandler {
+	allowed := make(map[string]bool, len(allowedHosts)+1)
+	for _, host := range allowedHosts {
+		allowed[host] = true
+	}
+	if normalized, _, err := ctlog.NormalizeURL(configuredURL); err == nil {
+		allowed[ctlog.Host(normalized)] = true
+	}
+	return &CTLogHandler{
+		configuredURL: configuredURL,
+		allowedHosts:  allowed,
+		newClient: func(baseURL string) sthGetter {
+			return ctlog.NewClient(baseURL, ctlog.WithAllowedRedirects(func(host string) bool { return allowed[host] }))
+		},
+		minInterval: minInterval,
+	}
+}
+
+// RegisterAdminRoutes registers the check. It fetches caller-chosen URLs,
+// so it belongs behind ADMIN_ALLOW_CIDRS.
+func (h *CTLogHandler) RegisterAdminRoutes(r chi.Router) {
+	r.Get("/ctlog/check", h.Check)
+}
+
+type ctlogCheckResult struct {
+	URL        string `json:"url"`
+	Normalized bool   `json:"normalized"`
+	Reachable  bool   `json:"reachable"`
+	Error      string `json:"error,omitempty"`
+	LatencyMS  int64  `json:"latency_ms"`
+	TreeSize   *int64 `json:"tree_size,omitempty"`
+	// STHTimestamp and STHAgeSeconds tell a live log (an STH at most a
+	// day old, per its maximum merge delay) from a frozen or retired one.
+	STHTimestamp  *time.Time `json:"sth_timestamp,omitempty"`
+	STHAgeSeconds *float64   `json:"sth_age_seconds,omitempty"`
+}
+
+// Check calls get-sth on the log in the url query parameter, or on
+// CT_LOG_URL, and reports the outcome. An unreachable log is still a 200:
+// the check itself succeeded. Invalid URLs are a 400, hosts outside the
+// allowlist a 403 and checks closer together than minInterval a 429.
+func (h *CTLogHandler) Check(w http.ResponseWriter, r *http.Request) {
+	raw := r.URL.Query().Get("url")
+	if raw == "" {
+		raw = h.configuredURL
+	}
+	baseURL, normalized, err := ctlog.NormalizeURL(raw)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !h.allowedHosts[ctlog.Host(baseURL)] {
+		writeError(w, http.StatusForbidden, fmt.Sprintf("log host %s is not in ALLOWED_CT_LOG_URLS", ctlog.Host(baseURL)))
+		return
+	}
+
+	if wait := h.reserve(); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		writeError(w, http.StatusTooManyRequests, fmt.Sprintf("checks are limited to one every %s", h.minInterval))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), ctlogCheckTimeout)
+	defer cancel()
+	started := time.Now()
+	sth, err := h.newClient(baseURL).GetSTH(ctx)
+
+	result := ctlogCheckResult{
+		URL:        baseURL,
+		Normalized: normalized,
+		LatencyMS:  time.Since(started).Milliseconds(),
+	}
+	if err != nil {
+		result.Error = err.Error()
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+	ts := time.UnixMilli(sth.Timestamp).UTC()
+	age := time.Since(ts).Seconds()
+	result.Reachable = true
+	result.TreeSize = &sth.TreeSize
+	result.STHTimestamp = &ts
+	result.STHAgeSeconds = &age
+	writeJSON(w, http.StatusOK, result)
+}
+
+// reserve claims the next check slot, or returns how long until one frees.
+func (h *CTLogHandler) reserve() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	if wait := h.lastCheck.Add(h.minInterval).Sub(now); wait > 0 {
+		return wait
+	}
+	h.lastCheck = now
+	return 0
+}
@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/fakectlog"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/ctlog"
+)
+
+func doCTLogCheck(t *testing.T, h *CTLogHandler, query string) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/ctlog/check"+query, nil)
+	rec := httptest.NewRecorder()
+	h.Check(rec, req)
+	var body map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	return rec, body
+}
+
+func TestCTLogCheck_ReachableLog(t *testing.T) {
+	srv := httptest.NewServer(fakectlog.New(fakectlog.WithTreeSize(42)))
+	defer srv.Close()
+	h := NewCTLogHandler("https://unused.example.com", []string{ctlog.Host(srv.URL)}, 0)
+
+	rec, body := doCTLogCheck(t, h, "?url="+srv.URL+"/ct/v1/")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if body["reachable"] != true || body["tree_size"] != float64(42) {
+		t.Errorf("reachable/tree_size = %v/%v, want true/42", body["reachable"], body["tree_size"])
+	}
+	if body["url"] != srv.URL || body["normalized"] != true {
+		t.Errorf("url/normalized = %v/%v, want %s/true", body["url"], body["normalized"], srv.URL)
+	}
+	if age, ok := body["sth_age_seconds"].(float64); !ok || age < 0 || age > 60 {
+		t.Errorf("sth_age_seconds = %v, want a fresh STH", body["sth_age_seconds"])
+	}
+}
+
+func TestCTLogCheck_DefaultsToConfiguredURL(t *testing.T) {
+	var checked string
+	h := NewCTLogHandler("https://oak.ct.letsencrypt.org/2026h2", nil, 0)
+	h.newClient = func(baseURL string) sthGetter {
+		checked = baseURL
+		return &mockLogClient{getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+			return &ctlog.STH{TreeSize: 7, Timestamp: time.Now().Add(-time.Hour).UnixMilli()}, nil
+		}}
+	}
+
+	_, body := doCTLogCheck(t, h, "")
+	if checked != "https://oak.ct.letsencrypt.org/2026h2" {
+		t.Errorf("checked %q, want the configured URL", checked)
+	}
+	if body["normalized"] != false {
+		t.Errorf("normalized = %v, want false", body["normalized"])
+	}
+	if age := body["sth_age_seconds"].(float64); age < 3599 || age > 3660 {
+		t.Errorf("sth_age_seconds = %v, want about 3600", age)
+	}
+}
+
+func TestCTLogCheck_UnreachableLog(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close() // nothing listens there any more
+	h := NewCTLogHandler(url, nil, 0)
+
+	rec, body := doCTLogCheck(t, h, "?url="+url)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if body["reachable"] != false || body["error"] == nil {
+		t.Errorf("reachable/error = %v/%v, want false and an error", body["reachable"], body["error"])
+	}
+	if _, ok := body["tree_size"]; ok {
+		t.Error("tree_size present for an unreachable log")
+	}
+}
+
+func TestCTLogCheck_LogError(t *testing.T) {
+	h := NewCTLogHandler("https://ct.example.com", nil, 0)
+	h.newClient = func(string) sthGetter {
+		return &mockLogClient{getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+			return nil, errors.New("STH returned status 404")
+		}}
+	}
+
+	_, body := doCTLogCheck(t, h, "")
+	if body["reachable"] != false || body["error"] != "STH returned status 404" {
+		t.Errorf("reachable/error = %v/%v, want false and the client error", body["reachable"], body["error"])
+	}
+}
+
+func TestCTLogCheck_InvalidURL(t *testing.T) {
+	h := NewCTLogHandler("", nil, 0)
+	h.newClient = func(string) sthGetter {
+		t.Error("invalid URL was fetched")
+		return nil
+	}
+
+	for _, query := range []string{"", "?url=ftp://ct.example.com", "?url=https://user:pw@ct.example.com"} {
+		if rec, _ := doCTLogCheck(t, h, query); rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: status = %d, want 400", query, rec.Code)
+		}
+	}
+}
+
+func TestCTLogCheck_RateLimited(t *testing.T) {
+	h := NewCTLogHandler("https://ct.example.com", nil, time.Minute)
+	h.newClient = func(string) sthGetter {
+		return &mockLogClient{getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+			return &ctlog.STH{TreeSize: 1, Timestamp: time.Now().UnixMilli()}, nil
+		}}
+	}
+
+	if rec, _ := doCTLogCheck(t, h, ""); rec.Code != http.StatusOK {
+		t.Fatalf("first check status = %d, want 200", rec.Code)
+	}
+	rec, _ := doCTLogCheck(t, h, "")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second check status = %d, want 429", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("Retry-After = %q, want 60", got)
+	}
+}
+
+func TestCTLogCheck_Allowlist(t *testing.T) {
+	var checked []string
+	h := NewCTLogHandler("https://oak.ct.letsencrypt.org/2026h2",
+		[]string{"ct.example.com", "ct.googleapis.com:8443"}, 0)
+	h.newClient = func(baseURL string) sthGetter {
+		checked = append(checked, baseURL)
+		return &mockLogClient{getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+			return &ctlog.STH{TreeSize: 1, Timestamp: time.Now().UnixMilli()}, nil
+		}}
+	}
+
+	for _, tc := range []struct {
+		url  string
+		want int
+	}{
+		{"https://oak.ct.letsencrypt.org/2027h1", http.StatusOK}, // the configured log's host
+		{"https://CT.example.com/logs/argon", http.StatusOK},
+		{"ct.googleapis.com:8443/pilot", http.StatusOK},
+		{"https://ct.googleapis.com/pilot", http.StatusForbidden}, // port differs
+		{"http://169.254.169.254/latest", http.StatusForbidden},
+		{"http://localhost:5432", http.StatusForbidden},
+		{"https://ct.example.com.evil.test", http.StatusForbidden},
+	} {
+		rec, body := doCTLogCheck(t, h, "?url="+tc.url)
+		if rec.Code != tc.want {
+			t.Errorf("%s: status = %d, want %d (%v)", tc.url, rec.Code, tc.want, body)
+		}
+	}
+	if len(checked) != 3 {
+		t.Errorf("fetched %q, want only the 3 allowed logs", checked)
+	}
+}
+
+func TestCTLogCheck_EmptyAllowlistMeansConfiguredLogOnly(t *testing.T) {
+	h := NewCTLogHandler("https://ct.example.com/log", nil, 0)
+	h.newClient = func(string) sthGetter {
+		return &mockLogClient{getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+			return &ctlog.STH{TreeSize: 1, Timestamp: time.Now().UnixMilli()}, nil
+		}}
+	}
+
+	if rec, _ := doCTLogCheck(t, h, ""); rec.Code != http.StatusOK {
+		t.Errorf("configured log: status = %d, want 200", rec.Code)
+	}
+	rec, body := doCTLogCheck(t, h, "?url=https://other.example.com")
+	if rec.Code != http.StatusForbidden || body["error"] != "log host other.example.com is not in ALLOWED_CT_LOG_URLS" {
+		t.Errorf("other log: %d %v, want 403", rec.Code, body)
+	}
+}
+
+func TestCTLogCheck_RejectsRedirectOffAllowlist(t *testing.T) {
+	internal := httptest.NewServer(fakectlog.New(fakectlog.WithTreeSize(42)))
+	defer internal.Close()
+	log := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, internal.URL+r.URL.Path, http.StatusFound)
+	}))
+	defer log.Close()
+	h := NewCTLogHandler(log.URL, nil, 0)
+
+	_, body := doCTLogCheck(t, h, "")
+	if body["reachable"] != false {
+		t.Fatalf("reachable = %v, want false for a redirect off the allowlist", body["reachable"])
+	}
+	if msg, _ := body["error"].(string); !strings.Contains(msg, ctlog.ErrRedirectNotAllowed.Error()) {
+		t.Errorf("error = %q, want %q", msg, ctlog.ErrRedirectNotAllowed)
+	}
+
+	// The same redirect is followed once the target's host is allowed.
+	h = NewCTLogHandler(log.URL, []string{ctlog.Host(internal.URL)}, 0)
+	if _, body := doCTLogCheck(t, h, ""); body["reachable"] != true || body["tree_size"] != float64(42) {
+		t.Errorf("reachable/tree_size = %v/%v, want true/42", body["reachable"], body["tree_size"])
+	}
+}
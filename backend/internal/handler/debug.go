@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	runtimepprof "runtime/pprof"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// DebugHandler exposes net/http/pprof and runtime summaries for diagnosing
+// leaks in a running server. It is only mounted with DEBUG_ENDPOINTS=true,
+// on the root router (profiles outlast the API request timeout) and behind
+// the admin allowlist.
+type DebugHandler struct{}
+
+func NewDebugHandler() *DebugHandler {
+	return &DebugHandler{}
+}
+
+func (h *DebugHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/debug/pprof/", pprof.Index)
+	r.Get("/debug/pprof/cmdline", pprof.Cmdline)
+	r.Get("/debug/pprof/profile", pprof.Profile)
+	r.Get("/debug/pprof/symbol", pprof.Symbol)
+	r.Post("/debug/pprof/symbol", pprof.Symbol)
+	r.Get("/debug/pprof/trace", pprof.Trace)
+	// Named profiles: heap, goroutine, allocs, block, mutex, threadcreate.
+	r.Get("/debug/pprof/{profile}", pprof.Index)
+	r.Get("/debug/goroutines", h.Goroutines)
+	r.Get("/debug/stats", h.Stats)
+}
+
+// Goroutines dumps every goroutine's stack as plain text.
+func (h *DebugHandler) Goroutines(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	runtimepprof.Lookup("goroutine").WriteTo(w, 2)
+}
+
+// runtimeStats is the subset of runtime.MemStats worth watching for leaks.
+type runtimeStats struct {
+	Goroutines   int    `json:"goroutines"`
+	HeapAlloc    uint64 `json:"heap_alloc_bytes"`
+	HeapInuse    uint64 `json:"heap_inuse_bytes"`
+	HeapObjects  uint64 `json:"heap_objects"`
+	Sys          uint64 `json:"sys_bytes"`
+	TotalAlloc   uint64 `json:"total_alloc_bytes"`
+	NumGC        uint32 `json:"num_gc"`
+	PauseTotalNs uint64 `json:"gc_pause_total_ns"`
+	NextGC       uint64 `json:"next_gc_bytes"`
+}
+
+// Stats reports goroutine and memory counters.
+func (h *DebugHandler) Stats(w http.ResponseWriter, r *http.Request) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	writeJSON(w, http.StatusOK, runtimeStats{
+		Goroutines:   runtime.NumGoroutine(),
+		HeapAlloc:    ms.HeapAlloc,
+		HeapInuse:    ms.HeapInuse,
+		HeapObjects:  ms.HeapObjects,
+		Sys:          ms.Sys,
+		TotalAlloc:   ms.TotalAlloc,
+		NumGC:        ms.NumGC,
+		PauseTotalNs: ms.PauseTotalNs,
+		NextGC:       ms.NextGC,
+	})
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func debugRouter() chi.Router {
+	r := chi.NewRouter()
+	NewDebugHandler().RegisterRoutes(r)
+	return r
+}
+
+func TestDebugStats(t *testing.T) {
+	rec := httptest.NewRecorder()
+	debugRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/stats", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var body map[string]float64
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	for _, field := range []string{"goroutines", "heap_alloc_bytes", "heap_objects", "sys_bytes", "num_gc", "next_gc_bytes"} {
+		if _, ok := body[field]; !ok {
+			t.Errorf("missing field %q", field)
+		}
+	}
+	if body["goroutines"] < 1 {
+		t.Errorf("goroutines = %v, want at least 1", body["goroutines"])
+	}
+}
+
+func TestDebugGoroutines(t *testing.T) {
+	rec := httptest.NewRecorder()
+	debugRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/goroutines", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if !strings.Contains(rec.Body.String(), "TestDebugGoroutines") {
+		t.Error("goroutine dump does not include the running test's stack")
+	}
+}
+
+func TestDebugPprof(t *testing.T) {
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/heap?debug=1", "/debug/pprof/cmdline"} {
+		rec := httptest.NewRecorder()
+		debugRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK || rec.Body.Len() == 0 {
+			t.Errorf("%s: status = %d, %d bytes; want 200 with a body", path, rec.Code, rec.Body.Len())
+		}
+	}
+}
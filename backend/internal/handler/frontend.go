@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// FrontendHandler serves the built single-page app (the frontend's dist
+// directory) so one process can host both the UI and the API. It mirrors
+// frontend/nginx.conf: hashed files under assets/ are cached forever,
+// everything else is revalidated, and unknown paths get index.html so
+// client-side routes survive a reload.
+type FrontendHandler struct {
+	fsys fs.FS
+}
+
+// NewFrontendHandler serves files from fsys, which must contain index.html.
+func NewFrontendHandler(fsys fs.FS) *FrontendHandler {
+	return &FrontendHandler{fsys: fsys}
+}
+
+// RegisterRoutes registers a catch-all on the root router. chi prefers the
+// more specific /api/v1 and /metrics routes, so they are never shadowed.
+func (h *FrontendHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/*", h.Serve)
+	r.Head("/*", h.Serve)
+}
+
+func (h *FrontendHandler) Serve(w http.ResponseWriter, r *http.Request) {
+	// Unknown API paths stay JSON 404s rather than becoming the app shell.
+	if r.URL.Path == "/api" || strings.HasPrefix(r.URL.Path, "/api/") {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Referrer-Policy", "strict-origin-when-cross-origin")
+
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name != "" && name != "index.html" {
+		info, err := fs.Stat(h.fsys, name)
+		switch {
+		case err == nil && !info.IsDir():
+			if strings.HasPrefix(name, "assets/") {
+				w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+			} else {
+				w.Header().Set("Cache-Control", "no-cache")
+			}
+			http.ServeFileFS(w, r, h.fsys, name)
+			return
+		case strings.HasPrefix(name, "assets/"):
+			// A missing bundle must not be answered with HTML.
+			http.NotFound(w, r)
+			return
+		case err != nil && !errors.Is(err, fs.ErrNotExist):
+			writeError(w, http.StatusInternalServerError, "failed to read frontend")
+			return
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+	index, err := fs.ReadFile(h.fsys, "index.html")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to read frontend")
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write(index)
+	}
+}
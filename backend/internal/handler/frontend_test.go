@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const testIndexHTML = `<!doctype html><div id="root"></div>`
+
+func frontendRouter() chi.Router {
+	r := chi.NewRouter()
+	r.Handle("/metrics", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("metrics"))
+	}))
+	r.Route("/api/v1", func(r chi.Router) {
+		r.Get("/keywords", func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, http.StatusOK, map[string]any{"keywords": []string{}})
+		})
+	})
+	NewFrontendHandler(fstest.MapFS{
+		"index.html":             {Data: []byte(testIndexHTML)},
+		"favicon.svg":            {Data: []byte("<svg/>")},
+		"assets/index-a1b2c3.js": {Data: []byte("console.log(1)")},
+	}).RegisterRoutes(r)
+	return r
+}
+
+func TestFrontend_ServesFiles(t *testing.T) {
+	for _, tc := range []struct {
+		path, body, cacheControl string
+	}{
+		{"/", testIndexHTML, "no-cache"},
+		{"/assets/index-a1b2c3.js", "console.log(1)", "public, max-age=31536000, immutable"},
+		{"/favicon.svg", "<svg/>", "no-cache"},
+	} {
+		rec := httptest.NewRecorder()
+		frontendRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: status = %d, want %d", tc.path, rec.Code, http.StatusOK)
+		}
+		if rec.Body.String() != tc.body {
+			t.Errorf("%s: body = %q, want %q", tc.path, rec.Body.String(), tc.body)
+		}
+		if got := rec.Header().Get("Cache-Control"); got != tc.cacheControl {
+			t.Errorf("%s: Cache-Control = %q, want %q", tc.path, got, tc.cacheControl)
+		}
+	}
+}
+
+func TestFrontend_SPAFallback(t *testing.T) {
+	for _, path := range []string{"/certificates", "/keywords/12", "/assets"} {
+		rec := httptest.NewRecorder()
+		frontendRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+		if rec.Code != http.StatusOK || rec.Body.String() != testIndexHTML {
+			t.Errorf("%s: status = %d body = %q, want index.html", path, rec.Code, rec.Body.String())
+		}
+		if got := rec.Header().Get("Cache-Control"); got != "no-cache" {
+			t.Errorf("%s: Cache-Control = %q, want no-cache", path, got)
+		}
+		if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+			t.Errorf("%s: Content-Type = %q, want text/html", path, ct)
+		}
+	}
+}
+
+func TestFrontend_MissingAssetIs404(t *testing.T) {
+	rec := httptest.NewRecorder()
+	frontendRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/assets/index-old.js", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	if strings.Contains(rec.Body.String(), testIndexHTML) {
+		t.Error("missing asset was answered with index.html")
+	}
+}
+
+func TestFrontend_DoesNotShadowAPI(t *testing.T) {
+	for _, tc := range []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/api/v1/keywords", http.StatusOK, `"keywords"`},
+		{"/metrics", http.StatusOK, "metrics"},
+		{"/api/v1/nope", http.StatusNotFound, ""},
+		{"/api/v2/keywords", http.StatusNotFound, `"error"`},
+	} {
+		rec := httptest.NewRecorder()
+		frontendRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+		if rec.Code != tc.code {
+			t.Errorf("%s: status = %d, want %d", tc.path, rec.Code, tc.code)
+		}
+		if !strings.Contains(rec.Body.String(), tc.body) {
+			t.Errorf("%s: body = %q, want it to contain %q", tc.path, rec.Body.String(), tc.body)
+		}
+		if strings.Contains(rec.Body.String(), testIndexHTML) {
+			t.Errorf("%s: answered with index.html", tc.path)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/repository"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/history"
+)
+
+// defaultHistoryWindow is how far back an import reaches when the request
+// gives no since.
+const defaultHistoryWindow = 90 * 24 * time.Hour
+
+type historyImporter interface {
+	Start(ctx context.Context, keywordID int, since time.Time, maxRows int) (model.ImportJob, error)
+	Get(id int) (model.ImportJob, error)
+	List() []model.ImportJob
+	Cancel(id int) (model.ImportJob, error)
+}
+
+// HistoryHandler serves the crt.sh history import. A nil importer means
+// the import is disabled, and every route answers 503.
+type HistoryHandler struct {
+	importer historyImporter
+	audit    auditRecorder
+	now      func() time.Time
+}
+
+func NewHistoryHandler(importer historyImporter, audit auditRecorder) *HistoryHandler {
+	return &HistoryHandler{importer: importer, audit: audit, now: time.Now}
+}
+
+// RegisterAdminRoutes registers the routes that start, inspect and cancel
+// imports; mount them behind the admin allowlist.
+func (h *HistoryHandler) RegisterAdminRoutes(r chi.Router) {
+	r.Post("/keywords/{id}/import-history", h.Start)
+	r.Get("/keywords/import-history", h.List)
+	r.Get("/keywords/import-history/{job}", h.Get)
+	r.Delete("/keywords/import-history/{job}", h.Cancel)
+}
+
+// Start launches an import of keyword {id}'s matches from crt.sh. The
+// optional body sets since (YYYY-MM-DD or RFC 3339, default 90 days ago)
+// and max_rows (default and cap CRTSH_IMPORT_MAX_ROWS). It answers 202
+// with the job to poll.
+func (h *HistoryHandler) Start(w http.ResponseWriter, r *http.Request) {
+	if !h.enabled(w) {
+		return
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid keyword id")
+		return
+	}
+	var req struct {
+		Since   string `json:"since"`
+		MaxRows int    `json:"max_rows"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeBodyError(w, err)
+		return
+	}
+	since := h.now().Add(-defaultHistoryWindow)
+	if req.Since != "" {
+		since, err = parseSince(req.Since)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid since (want YYYY-MM-DD or RFC 3339)")
+			return
+		}
+		if since.After(h.now()) {
+			writeError(w, http.StatusBadRequest, "since must not be in the future")
+			return
+		}
+	}
+	if req.MaxRows < 0 {
+		writeError(w, http.StatusBadRequest, "max_rows must not be negative")
+		return
+	}
+
+	job, err := h.importer.Start(r.Context(), id, since, req.MaxRows)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		writeError(w, http.StatusNotFound, "keyword not found")
+		return
+	case errors.Is(err, history.ErrJobRunning):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case errors.Is(err, history.ErrClosed):
+		writeError(w, http.StatusServiceUnavailable, "server is shutting down")
+		return
+	case err != nil:
+		writeQueryError(w, err, "failed to start import")
+		return
+	}
+
+	h.audit.Record(r.Context(), model.AuditActionImport, model.AuditEntityKeyword, strconv.Itoa(id),
+		map[string]model.AuditChange{
+			"source":   {New: model.SourceCrtSh},
+			"since":    {New: job.Since.UTC().Format(time.RFC3339)},
+			"max_rows": {New: strconv.Itoa(job.MaxRows)},
+		})
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// List returns the import jobs since startup, newest first.
+func (h *HistoryHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !h.enabled(w) {
+		return
+	}
+	writeJSON(w, http.StatusOK, h.importer.List())
+}
+
+// Get returns import job {job} with its progress.
+func (h *HistoryHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if !h.enabled(w) {
+		return
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "job"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid job id")
+		return
+	}
+	job, err := h.importer.Get(id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "import job not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// Cancel stops running import job {job}; matches it already stored are
+// kept. A finished job answers 409.
+func (h *HistoryHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	if !h.enabled(w) {
+		return
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "job"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid job id")
+		return
+	}
+	job, err := h.importer.Cancel(id)
+	switch {
+	case errors.Is(err, history.ErrJobNotFound):
+		writeError(w, http.StatusNotFound, "import job not found")
+		return
+	case errors.Is(err, history.ErrJobFinished):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "failed to cancel import")
+		return
+	}
+
+	h.audit.Record(r.Context(), model.AuditActionStop, model.AuditEntityKeyword, strconv.Itoa(job.KeywordID),
+		map[string]model.AuditChange{"import_job": {New: strconv.Itoa(job.ID)}})
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+func (h *HistoryHandler) enabled(w http.ResponseWriter) bool {
+	if h.importer == nil {
+		writeError(w, http.StatusServiceUnavailable, "crt.sh import is disabled (CRTSH_IMPORT_ENABLED)")
+		return false
+	}
+	return true
+}
+
+func parseSince(s string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/repository"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/history"
+)
+
+type mockHistoryImporter struct {
+	startFn  func(ctx context.Context, keywordID int, since time.Time, maxRows int) (model.ImportJob, error)
+	getFn    func(id int) (model.ImportJob, error)
+	listFn   func() []model.ImportJob
+	cancelFn func(id int) (model.ImportJob, error)
+}
+
+func (m *mockHistoryImporter) Start(ctx context.Context, keywordID int, since time.Time, maxRows int) (model.ImportJob, error) {
+	return m.startFn(ctx, keywordID, since, maxRows)
+}
+func (m *mockHistoryImporter) Get(id int) (model.ImportJob, error) { return m.getFn(id) }
+func (m *mockHistoryImporter) List() []model.ImportJob             { return m.listFn() }
+func (m *mockHistoryImporter) Cancel(id int) (model.ImportJob, error) {
+	return m.cancelFn(id)
+}
+
+var historyNow = time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+func newHistoryHandler(imp historyImporter, audit auditRecorder) *HistoryHandler {
+	h := NewHistoryHandler(imp, audit)
+	h.now = func() time.Time { return historyNow }
+	return h
+}
+
+// historyStartRequest builds a POST to keyword id's import-history route.
+func historyStartRequest(id, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/keywords/"+id+"/import-history", strings.NewReader(body))
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", id)
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestHistoryStart(t *testing.T) {
+	var gotSince time.Time
+	var gotID, gotMax int
+	audit := &mockAuditRecorder{}
+	h := newHistoryHandler(&mockHistoryImporter{
+		startFn: func(ctx context.Context, keywordID int, since time.Time, maxRows int) (model.ImportJob, error) {
+			gotID, gotSince, gotMax = keywordID, since, maxRows
+			return model.ImportJob{ID: 1, KeywordID: keywordID, Since: since, MaxRows: 500, Status: model.ImportRunning}, nil
+		},
+	}, audit)
+
+	rec := httptest.NewRecorder()
+	h.Start(rec, historyStartRequest("3", `{"since":"2025-06-01","max_rows":500}`))
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202: %s", rec.Code, rec.Body)
+	}
+	if gotID != 3 || gotMax != 500 || !gotSince.Equal(time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Start(%d, %v, %d), want 3, 2025-06-01, 500", gotID, gotSince, gotMax)
+	}
+	var job model.ImportJob
+	if err := json.NewDecoder(rec.Body).Decode(&job); err != nil {
+		t.Fatal(err)
+	}
+	if job.ID != 1 || job.Status != model.ImportRunning {
+		t.Errorf("job = %+v", job)
+	}
+	if len(audit.calls) != 1 {
+		t.Fatalf("audit calls = %d, want 1", len(audit.calls))
+	}
+	if c := audit.calls[0]; c.action != model.AuditActionImport || c.entityType != model.AuditEntityKeyword || c.entityID != "3" ||
+		c.changes["source"].New != model.SourceCrtSh || c.changes["max_rows"].New != "500" {
+		t.Errorf("audit = %+v", c)
+	}
+}
+
+func TestHistoryStart_Defaults(t *testing.T) {
+	var gotSince time.Time
+	gotMax := -1
+	h := newHistoryHandler(&mockHistoryImporter{
+		startFn: func(ctx context.Context, keywordID int, since time.Time, maxRows int) (model.ImportJob, error) {
+			gotSince, gotMax = since, maxRows
+			return model.ImportJob{ID: 1}, nil
+		},
+	}, &mockAuditRecorder{})
+
+	rec := httptest.NewRecorder()
+	h.Start(rec, historyStartRequest("3", ""))
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202: %s", rec.Code, rec.Body)
+	}
+	if want := historyNow.Add(-defaultHistoryWindow); !gotSince.Equal(want) || gotMax != 0 {
+		t.Errorf("since/max = %v/%d, want %v/0", gotSince, gotMax, want)
+	}
+}
+
+func TestHistoryStart_BadRequest(t *testing.T) {
+	h := newHistoryHandler(&mockHistoryImporter{}, &mockAuditRecorder{})
+
+	for _, tt := range []struct{ id, body string }{
+		{"abc", ""},
+		{"3", `{"since":"yesterday"}`},
+		{"3", `{"since":"2026-04-01"}`},
+		{"3", `{"max_rows":-1}`},
+		{"3", `{"max_rows":`},
+	} {
+		rec := httptest.NewRecorder()
+		h.Start(rec, historyStartRequest(tt.id, tt.body))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("id %s body %s: status = %d, want 400", tt.id, tt.body, rec.Code)
+		}
+	}
+}
+
+func TestHistoryStart_Errors(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{repository.ErrNotFound, http.StatusNotFound},
+		{history.ErrJobRunning, http.StatusConflict},
+		{history.ErrClosed, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		audit := &mockAuditRecorder{}
+		h := newHistoryHandler(&mockHistoryImporter{
+			startFn: func(ctx context.Context, keywordID int, since time.Time, maxRows int) (model.ImportJob, error) {
+				return model.ImportJob{}, tt.err
+			},
+		}, audit)
+		rec := httptest.NewRecorder()
+		h.Start(rec, historyStartRequest("3", ""))
+		if rec.Code != tt.want {
+			t.Errorf("%v: status = %d, want %d", tt.err, rec.Code, tt.want)
+		}
+		if len(audit.calls) != 0 {
+			t.Errorf("%v: audit calls = %d, want 0", tt.err, len(audit.calls))
+		}
+	}
+}
+
+func TestHistory_Disabled(t *testing.T) {
+	h := newHistoryHandler(nil, &mockAuditRecorder{})
+
+	for name, fn := range map[string]http.HandlerFunc{"start": h.Start, "list": h.List, "get": h.Get, "cancel": h.Cancel} {
+		rec := httptest.NewRecorder()
+		fn(rec, chiRequest(http.MethodGet, "/api/v1/keywords/import-history/1", map[string]string{"id": "1", "job": "1"}))
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s: status = %d, want 503", name, rec.Code)
+		}
+	}
+}
+
+func TestHistoryListAndGet(t *testing.T) {
+	h := newHistoryHandler(&mockHistoryImporter{
+		listFn: func() []model.ImportJob {
+			return []model.ImportJob{{ID: 2}, {ID: 1}}
+		},
+		getFn: func(id int) (model.ImportJob, error) {
+			if id != 2 {
+				return model.ImportJob{}, history.ErrJobNotFound
+			}
+			return model.ImportJob{ID: 2, Status: model.ImportCompleted, Imported: 7}, nil
+		},
+	}, &mockAuditRecorder{})
+
+	rec := httptest.NewRecorder()
+	h.List(rec, httptest.NewRequest(http.MethodGet, "/api/v1/keywords/import-history", nil))
+	var jobs []model.ImportJob
+	if err := json.NewDecoder(rec.Body).Decode(&jobs); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || len(jobs) != 2 || jobs[0].ID != 2 {
+		t.Errorf("list: status = %d, jobs = %+v", rec.Code, jobs)
+	}
+
+	rec = httptest.NewRecorder()
+	h.Get(rec, chiRequest(http.MethodGet, "/api/v1/keywords/import-history/2", map[string]string{"job": "2"}))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"imported":7`) {
+		t.Errorf("get: status = %d, body = %s", rec.Code, rec.Body)
+	}
+
+	for job, want := range map[string]int{"9": http.StatusNotFound, "x": http.StatusBadRequest} {
+		rec = httptest.NewRecorder()
+		h.Get(rec, chiRequest(http.MethodGet, "/api/v1/keywords/import-history/"+job, map[string]string{"job": job}))
+		if rec.Code != want {
+			t.Errorf("get %s: status = %d, want %d", job, rec.Code, want)
+		}
+	}
+}
+
+func TestHistoryCancel(t *testing.T) {
+	audit := &mockAuditRecorder{}
+	h := newHistoryHandler(&mockHistoryImporter{
+		cancelFn: func(id int) (model.ImportJob, error) {
+			switch id {
+			case 1:
+				return model.ImportJob{ID: 1, KeywordID: 3, Status: model.ImportRunning}, nil
+			case 2:
+				return model.ImportJob{ID: 2, Status: model.ImportCompleted}, history.ErrJobFinished
+			}
+			return model.ImportJob{}, history.ErrJobNotFound
+		},
+	}, audit)
+
+	for job, want := range map[string]int{"1": http.StatusAccepted, "2": http.StatusConflict, "9": http.StatusNotFound} {
+		rec := httptest.NewRecorder()
+		h.Cancel(rec, chiRequest(http.MethodDelete, "/api/v1/keywords/import-history/"+job, map[string]string{"job": job}))
+		if rec.Code != want {
+			t.Errorf("cancel %s: status = %d, want %d", job, rec.Code, want)
+		}
+	}
+	if len(audit.calls) != 1 {
+		t.Fatalf("audit calls = %d, want 1", len(audit.calls))
+	}
+	if c := audit.calls[0]; c.action != model.AuditActionStop || c.entityID != "3" || c.changes["import_job"].New != "1" {
+		t.Errorf("audit = %+v", c)
+	}
+}
@@ -1,59 +1,114 @@
 package handler
 
 import (
+	"bytes"
 	"context"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
 	"github.com/andres10976/SISAP-PoC/backend/internal/model"
 	"github.com/andres10976/SISAP-PoC/backend/internal/repository"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/seed"
 )
 
 type keywordStore interface {
 	List(ctx context.Context) ([]model.Keyword, error)
-	Create(ctx context.Context, value string) (*model.Keyword, error)
-	Delete(ctx context.Context, id int) error
+	Create(ctx context.Context, value, mode string, groupID *int, alternatives []string, activeFrom, activeUntil *time.Time) (*model.Keyword, error)
+	SetGroup(ctx context.Context, id int, groupID *int) (*model.Keyword, error)
+	SetAlternatives(ctx context.Context, id int, alternatives []string) (*model.Keyword, error)
+	Delete(ctx context.Context, id int) (*model.Keyword, error)
+	Mute(ctx context.Context, id int, until time.Time, scope string) (*model.Keyword, error)
+	Unmute(ctx context.Context, id int) (*model.Keyword, error)
+	Enable(ctx context.Context, id int) (*model.Keyword, error)
+	CreateMany(ctx context.Context, values []string) (int, error)
 }
 
+// maxKeywordAlternatives caps the alternative values one keyword matches on.
+const maxKeywordAlternatives = 20
+
 type KeywordHandler struct {
-	repo keywordStore
+	repo  keywordStore
+	audit auditRecorder
 }
 
-func NewKeywordHandler(repo keywordStore) *KeywordHandler {
-	return &KeywordHandler{repo: repo}
+func NewKeywordHandler(repo keywordStore, audit auditRecorder) *KeywordHandler {
+	return &KeywordHandler{repo: repo, audit: audit}
 }
 
 func (h *KeywordHandler) RegisterRoutes(r chi.Router) {
 	r.Get("/keywords", h.List)
 	r.Post("/keywords", h.Create)
+	r.Get("/keywords/export", h.Export)
+}
+
+// RegisterAdminRoutes registers the routes that remove, bulk-load, mute,
+// re-enable, regroup or re-value keywords; mount them behind the admin
+// allowlist.
+func (h *KeywordHandler) RegisterAdminRoutes(r chi.Router) {
 	r.Delete("/keywords/{id}", h.Delete)
+	r.Put("/keywords/{id}/group", h.SetGroup)
+	r.Put("/keywords/{id}/alternatives", h.SetAlternatives)
+	r.Post("/keywords/import", h.Import)
+	r.Post("/keywords/{id}/mute", h.Mute)
+	r.Delete("/keywords/{id}/mute", h.Unmute)
+	r.Post("/keywords/{id}/enable", h.Enable)
 }
 
+// List returns every keyword, or with ?group= only those in that group.
 func (h *KeywordHandler) List(w http.ResponseWriter, r *http.Request) {
+	groupID := 0
+	if v := r.URL.Query().Get("group"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id < 1 {
+			writeError(w, http.StatusBadRequest, "invalid group filter")
+			return
+		}
+		groupID = id
+	}
+
 	keywords, err := h.repo.List(r.Context())
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to list keywords")
 		return
 	}
+	if groupID > 0 {
+		keywords = slices.DeleteFunc(keywords, func(kw model.Keyword) bool {
+			return kw.GroupID == nil || *kw.GroupID != groupID
+		})
+	}
 	if keywords == nil {
 		keywords = []model.Keyword{}
 	}
 	writeJSON(w, http.StatusOK, map[string]any{"keywords": keywords})
 }
 
+// Create adds a keyword, optionally in group group_id. Without a
+// match_mode a grouped keyword takes its group's and any other is
+// substring. Alternatives are further values the keyword matches on, as
+// one keyword (matcher.Match). active_from and active_until (RFC 3339)
+// limit when it is matched; active_until must be in the future.
 func (h *KeywordHandler) Create(w http.ResponseWriter, r *http.Request) {
-	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1 MB
-
 	var req struct {
-		Value string `json:"value"`
+		Value        string     `json:"value"`
+		MatchMode    string     `json:"match_mode"`
+		GroupID      *int       `json:"group_id"`
+		Alternatives []string   `json:"alternatives"`
+		ActiveFrom   *time.Time `json:"active_from"`
+		ActiveUntil  *time.Time `json:"active_until"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request body")
+		writeBodyError(w, err)
 		return
 	}
 
@@ -66,9 +121,35 @@ func (h *KeywordHandler) Create(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "keyword must be at least 3 characters")
 		return
 	}
+	mode := req.MatchMode
+	if mode == "" && req.GroupID == nil {
+		mode = model.MatchModeSubstring
+	}
+	if mode != "" && !model.ValidMatchMode(mode) {
+		writeError(w, http.StatusBadRequest, "match_mode must be substring or boundary")
+		return
+	}
+	alternatives, ok := cleanAlternatives(w, req.Alternatives)
+	if !ok {
+		return
+	}
+	if req.ActiveUntil != nil {
+		if !req.ActiveUntil.After(time.Now()) {
+			writeError(w, http.StatusBadRequest, "active_until must be in the future")
+			return
+		}
+		if req.ActiveFrom != nil && !req.ActiveUntil.After(*req.ActiveFrom) {
+			writeError(w, http.StatusBadRequest, "active_until must be after active_from")
+			return
+		}
+	}
 
-	kw, err := h.repo.Create(r.Context(), value)
+	kw, err := h.repo.Create(r.Context(), value, mode, req.GroupID, alternatives, req.ActiveFrom, req.ActiveUntil)
 	if err != nil {
+		if errors.Is(err, repository.ErrGroupNotFound) {
+			writeError(w, http.StatusBadRequest, "keyword group not found")
+			return
+		}
 		if isDuplicateKeyError(err) {
 			writeError(w, http.StatusConflict, "keyword already exists")
 			return
@@ -77,9 +158,41 @@ func (h *KeywordHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	changes := make(map[string]model.AuditChange)
+	for field, v := range keywordFields(kw) {
+		changes[field] = model.AuditChange{New: v}
+	}
+	h.audit.Record(r.Context(), model.AuditActionCreate, model.AuditEntityKeyword, strconv.Itoa(kw.ID), changes)
+
 	writeJSON(w, http.StatusCreated, kw)
 }
 
+// keywordFields renders the settings of kw that are set, keyed by their
+// JSON names, for the audit log.
+func keywordFields(kw *model.Keyword) map[string]string {
+	fields := map[string]string{"value": kw.Value, "match_mode": kw.MatchMode}
+	if kw.GroupID != nil {
+		fields["group_id"] = strconv.Itoa(*kw.GroupID)
+	}
+	if len(kw.Alternatives) > 0 {
+		fields["alternatives"] = strings.Join(kw.Alternatives, ",")
+	}
+	if kw.ActiveFrom != nil {
+		fields["active_from"] = kw.ActiveFrom.UTC().Format(time.RFC3339)
+	}
+	if kw.ActiveUntil != nil {
+		fields["active_until"] = kw.ActiveUntil.UTC().Format(time.RFC3339)
+	}
+	if kw.MutedUntil != nil {
+		fields["muted_until"] = kw.MutedUntil.UTC().Format(time.RFC3339)
+		fields["mute_scope"] = kw.MuteScope
+	}
+	if kw.DisabledAt != nil {
+		fields["disabled_reason"] = kw.DisabledReason
+	}
+	return fields
+}
+
 func (h *KeywordHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
@@ -87,7 +200,8 @@ func (h *KeywordHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.repo.Delete(r.Context(), id); err != nil {
+	kw, err := h.repo.Delete(r.Context(), id)
+	if err != nil {
 		if errors.Is(err, repository.ErrNotFound) {
 			writeError(w, http.StatusNotFound, "keyword not found")
 			return
@@ -96,5 +210,362 @@ func (h *KeywordHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	changes := make(map[string]model.AuditChange)
+	for field, v := range keywordFields(kw) {
+		changes[field] = model.AuditChange{Old: v}
+	}
+	h.audit.Record(r.Context(), model.AuditActionDelete, model.AuditEntityKeyword, strconv.Itoa(id), changes)
+
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// Mute silences a keyword until the given time. Scope notifications (the
+// default) keeps matching and storing its certificates without notifying;
+// matching stops matching it. The mute lapses on its own at until.
+func (h *KeywordHandler) Mute(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid keyword id")
+		return
+	}
+	until, scope, ok := decodeMute(w, r)
+	if !ok {
+		return
+	}
+
+	kw, err := h.repo.Mute(r.Context(), id, until, scope)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "keyword not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to mute keyword")
+		return
+	}
+
+	h.audit.Record(r.Context(), model.AuditActionMute, model.AuditEntityKeyword, strconv.Itoa(id),
+		map[string]model.AuditChange{
+			"muted_until": {New: until.UTC().Format(time.RFC3339)},
+			"mute_scope":  {New: scope},
+		})
+
+	writeJSON(w, http.StatusOK, kw)
+}
+
+// decodeMute reads a mute request body, {until, scope}, shared by keywords
+// and keyword groups. On a bad body it writes the 400 and returns false.
+func decodeMute(w http.ResponseWriter, r *http.Request) (time.Time, string, bool) {
+	var req struct {
+		Until time.Time `json:"until"`
+		Scope string    `json:"scope"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return time.Time{}, "", false
+	}
+	if req.Until.IsZero() {
+		writeError(w, http.StatusBadRequest, "until is required")
+		return time.Time{}, "", false
+	}
+	if !req.Until.After(time.Now()) {
+		writeError(w, http.StatusBadRequest, "until must be in the future")
+		return time.Time{}, "", false
+	}
+	scope := req.Scope
+	if scope == "" {
+		scope = model.MuteScopeNotifications
+	}
+	if !model.ValidMuteScope(scope) {
+		writeError(w, http.StatusBadRequest, "scope must be notifications or matching")
+		return time.Time{}, "", false
+	}
+	return req.Until, scope, true
+}
+
+// Unmute lifts a keyword's mute before it lapses.
+func (h *KeywordHandler) Unmute(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid keyword id")
+		return
+	}
+
+	kw, err := h.repo.Unmute(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "keyword not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to unmute keyword")
+		return
+	}
+
+	h.audit.Record(r.Context(), model.AuditActionUnmute, model.AuditEntityKeyword, strconv.Itoa(id), nil)
+
+	writeJSON(w, http.StatusOK, kw)
+}
+
+// Enable lets a keyword the monitor disabled for matching too much of the
+// log be matched again. Enabling an enabled keyword is a no-op.
+func (h *KeywordHandler) Enable(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid keyword id")
+		return
+	}
+
+	kw, err := h.repo.Enable(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "keyword not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to enable keyword")
+		return
+	}
+
+	h.audit.Record(r.Context(), model.AuditActionEnable, model.AuditEntityKeyword, strconv.Itoa(id), nil)
+
+	writeJSON(w, http.StatusOK, kw)
+}
+
+// SetGroup moves a keyword into the group_id of the body, or out of its
+// group when group_id is null; settings it does not hold itself then come
+// from the new group.
+func (h *KeywordHandler) SetGroup(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid keyword id")
+		return
+	}
+	var req struct {
+		GroupID *int `json:"group_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	kw, err := h.repo.SetGroup(r.Context(), id, req.GroupID)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		writeError(w, http.StatusNotFound, "keyword not found")
+		return
+	case errors.Is(err, repository.ErrGroupNotFound):
+		writeError(w, http.StatusBadRequest, "keyword group not found")
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "failed to set keyword group")
+		return
+	}
+
+	group := ""
+	if req.GroupID != nil {
+		group = strconv.Itoa(*req.GroupID)
+	}
+	h.audit.Record(r.Context(), model.AuditActionUpdate, model.AuditEntityKeyword, strconv.Itoa(id),
+		map[string]model.AuditChange{"group_id": {New: group}})
+
+	writeJSON(w, http.StatusOK, kw)
+}
+
+// SetAlternatives replaces a keyword's alternative values with the
+// alternatives of the body; an empty list leaves it matching on its value
+// alone.
+func (h *KeywordHandler) SetAlternatives(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid keyword id")
+		return
+	}
+	var req struct {
+		Alternatives []string `json:"alternatives"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	alternatives, ok := cleanAlternatives(w, req.Alternatives)
+	if !ok {
+		return
+	}
+
+	kw, err := h.repo.SetAlternatives(r.Context(), id, alternatives)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "keyword not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to set keyword alternatives")
+		return
+	}
+
+	h.audit.Record(r.Context(), model.AuditActionUpdate, model.AuditEntityKeyword, strconv.Itoa(id),
+		map[string]model.AuditChange{"alternatives": {New: strings.Join(kw.Alternatives, ",")}})
+
+	writeJSON(w, http.StatusOK, kw)
+}
+
+// cleanAlternatives trims alternative keyword values and drops
+// case-insensitive duplicates. Each must, like a keyword, be at least 3
+// characters; on a bad list it writes the 400 and returns false.
+func cleanAlternatives(w http.ResponseWriter, alternatives []string) ([]string, bool) {
+	if len(alternatives) > maxKeywordAlternatives {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("at most %d alternatives", maxKeywordAlternatives))
+		return nil, false
+	}
+	seen := make(map[string]bool, len(alternatives))
+	var cleaned []string
+	for _, a := range alternatives {
+		a = strings.TrimSpace(a)
+		if len(a) < 3 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("alternative %q must be at least 3 characters", a))
+			return nil, false
+		}
+		if !seen[strings.ToLower(a)] {
+			seen[strings.ToLower(a)] = true
+			cleaned = append(cleaned, a)
+		}
+	}
+	return cleaned, true
+}
+
+// keywordExport is one row of a keyword export; Import accepts the same
+// shape (created_at is informational and ignored).
+type keywordExport struct {
+	Value     string    `json:"value"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Export downloads all keywords as JSON (default) or CSV (?format=csv) in a
+// form Import accepts.
+func (h *KeywordHandler) Export(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		writeError(w, http.StatusBadRequest, "format must be json or csv")
+		return
+	}
+
+	keywords, err := h.repo.List(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to export keywords")
+		return
+	}
+	rows := make([]keywordExport, len(keywords))
+	for i, kw := range keywords {
+		rows[i] = keywordExport{Value: kw.Value, CreatedAt: kw.CreatedAt}
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=keywords.%s", format))
+	if format == "json" {
+		writeJSON(w, http.StatusOK, rows)
+		return
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write([]string{"value", "created_at"})
+	for _, row := range rows {
+		writer.Write([]string{row.Value, row.CreatedAt.Format(time.RFC3339)})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		w.Header().Del("Content-Disposition")
+		writeError(w, http.StatusInternalServerError, "failed to export keywords")
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// Import adds the keywords from an Export body: JSON, CSV when the
+// Content-Type is text/csv, or either as the "file" part of a
+// multipart/form-data upload. Keywords that already exist, compared
+// case-insensitively, are skipped, so importing the same file twice is a
+// no-op.
+func (h *KeywordHandler) Import(w http.ResponseWriter, r *http.Request) {
+	values, err := readKeywordImport(r)
+	if err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	seen := make(map[string]bool, len(values))
+	unique := values[:0]
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if len(v) < 3 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("keyword %q must be at least 3 characters", v))
+			return
+		}
+		if !seen[strings.ToLower(v)] {
+			seen[strings.ToLower(v)] = true
+			unique = append(unique, v)
+		}
+	}
+	values = unique
+
+	imported, err := seed.Keywords(r.Context(), h.repo, values)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to import keywords")
+		return
+	}
+
+	if imported > 0 {
+		h.audit.Record(r.Context(), model.AuditActionImport, model.AuditEntityKeyword, "",
+			map[string]model.AuditChange{"count": {New: strconv.Itoa(imported)}})
+	}
+
+	writeJSON(w, http.StatusOK, map[string]int{
+		"imported": imported,
+		"skipped":  len(values) - imported,
+	})
+}
+
+func readKeywordImport(r *http.Request) ([]string, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		return seed.ParseCSV(r.Body)
+	case "multipart/form-data":
+		mr, err := r.MultipartReader()
+		if err != nil {
+			return nil, err
+		}
+		for {
+			part, err := mr.NextPart()
+			if err != nil {
+				if err == io.EOF {
+					return nil, errors.New("multipart body has no file part")
+				}
+				return nil, err
+			}
+			if part.FormName() != "file" {
+				continue
+			}
+			if strings.HasSuffix(strings.ToLower(part.FileName()), ".csv") ||
+				strings.HasPrefix(part.Header.Get("Content-Type"), "text/csv") {
+				return seed.ParseCSV(part)
+			}
+			return parseKeywordJSON(part)
+		}
+	default:
+		return parseKeywordJSON(r.Body)
+	}
+}
+
+func parseKeywordJSON(r io.Reader) ([]string, error) {
+	var rows []keywordExport
+	if err := json.NewDecoder(r).Decode(&rows); err != nil {
+		return nil, err
+	}
+	values := make([]string, len(rows))
+	for i, row := range rows {
+		values[i] = row.Value
+	}
+	return values, nil
+}
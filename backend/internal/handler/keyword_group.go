@@ -0,0 +1,288 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/repository"
+)
+
+// maxGroupNameLength caps a keyword group's name.
+const maxGroupNameLength = 100
+
+type keywordGroupStore interface {
+	List(ctx context.Context) ([]model.KeywordGroup, error)
+	Get(ctx context.Context, id int) (*model.KeywordGroup, error)
+	Create(ctx context.Context, name, mode string) (*model.KeywordGroup, error)
+	Update(ctx context.Context, id int, name, mode string) (*model.KeywordGroup, error)
+	Mute(ctx context.Context, id int, until time.Time, scope string) (*model.KeywordGroup, error)
+	Unmute(ctx context.Context, id int) (*model.KeywordGroup, error)
+	Disable(ctx context.Context, id int, reason string) (*model.KeywordGroup, error)
+	Enable(ctx context.Context, id int) (*model.KeywordGroup, error)
+	Delete(ctx context.Context, id int, withKeywords bool) (int64, error)
+}
+
+// KeywordGroupHandler serves keyword groups, whose match mode, mute and
+// disable are defaults for the keywords in them (model.Keyword.Inherit).
+type KeywordGroupHandler struct {
+	repo  keywordGroupStore
+	audit auditRecorder
+}
+
+func NewKeywordGroupHandler(repo keywordGroupStore, audit auditRecorder) *KeywordGroupHandler {
+	return &KeywordGroupHandler{repo: repo, audit: audit}
+}
+
+func (h *KeywordGroupHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/keyword-groups", h.List)
+	r.Post("/keyword-groups", h.Create)
+	r.Get("/keyword-groups/{id}", h.Get)
+}
+
+// RegisterAdminRoutes registers the routes that change, mute, disable or
+// delete groups, each of which reaches every keyword in the group; mount
+// them behind the admin allowlist.
+func (h *KeywordGroupHandler) RegisterAdminRoutes(r chi.Router) {
+	r.Put("/keyword-groups/{id}", h.Update)
+	r.Delete("/keyword-groups/{id}", h.Delete)
+	r.Post("/keyword-groups/{id}/mute", h.Mute)
+	r.Delete("/keyword-groups/{id}/mute", h.Unmute)
+	r.Post("/keyword-groups/{id}/disable", h.Disable)
+	r.Post("/keyword-groups/{id}/enable", h.Enable)
+}
+
+func (h *KeywordGroupHandler) List(w http.ResponseWriter, r *http.Request) {
+	groups, err := h.repo.List(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list keyword groups")
+		return
+	}
+	if groups == nil {
+		groups = []model.KeywordGroup{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"groups": groups})
+}
+
+func (h *KeywordGroupHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, ok := groupID(w, r)
+	if !ok {
+		return
+	}
+	g, err := h.repo.Get(r.Context(), id)
+	if !h.ok(w, err, "failed to get keyword group") {
+		return
+	}
+	writeJSON(w, http.StatusOK, g)
+}
+
+// Create adds a group from {name, match_mode}; an empty match_mode leaves
+// its keywords' modes to themselves.
+func (h *KeywordGroupHandler) Create(w http.ResponseWriter, r *http.Request) {
+	name, mode, ok := decodeGroup(w, r)
+	if !ok {
+		return
+	}
+
+	g, err := h.repo.Create(r.Context(), name, mode)
+	if err != nil {
+		if isDuplicateKeyError(err) {
+			writeError(w, http.StatusConflict, "keyword group already exists")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to create keyword group")
+		return
+	}
+
+	h.audit.Record(r.Context(), model.AuditActionCreate, model.AuditEntityKeywordGroup, strconv.Itoa(g.ID),
+		map[string]model.AuditChange{"name": {New: g.Name}, "match_mode": {New: g.MatchMode}})
+	writeJSON(w, http.StatusCreated, g)
+}
+
+// Update replaces a group's name and default match mode.
+func (h *KeywordGroupHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, ok := groupID(w, r)
+	if !ok {
+		return
+	}
+	name, mode, ok := decodeGroup(w, r)
+	if !ok {
+		return
+	}
+
+	g, err := h.repo.Update(r.Context(), id, name, mode)
+	if err != nil && isDuplicateKeyError(err) {
+		writeError(w, http.StatusConflict, "keyword group already exists")
+		return
+	}
+	if !h.ok(w, err, "failed to update keyword group") {
+		return
+	}
+
+	h.audit.Record(r.Context(), model.AuditActionUpdate, model.AuditEntityKeywordGroup, strconv.Itoa(id),
+		map[string]model.AuditChange{"name": {New: g.Name}, "match_mode": {New: g.MatchMode}})
+	writeJSON(w, http.StatusOK, g)
+}
+
+// Delete removes a group. Its keywords are ungrouped unless
+// ?delete_keywords=true confirms that they, and their matches, should be
+// deleted too.
+func (h *KeywordGroupHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := groupID(w, r)
+	if !ok {
+		return
+	}
+	withKeywords := false
+	if v := r.URL.Query().Get("delete_keywords"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid delete_keywords")
+			return
+		}
+		withKeywords = b
+	}
+
+	deleted, err := h.repo.Delete(r.Context(), id, withKeywords)
+	if !h.ok(w, err, "failed to delete keyword group") {
+		return
+	}
+
+	h.audit.Record(r.Context(), model.AuditActionDelete, model.AuditEntityKeywordGroup, strconv.Itoa(id),
+		map[string]model.AuditChange{"deleted_keywords": {Old: strconv.FormatInt(deleted, 10)}})
+	writeJSON(w, http.StatusOK, map[string]any{"deleted_keywords": deleted})
+}
+
+// Mute silences, until the given time, every keyword in the group that has
+// no mute of its own; the body is as for a keyword mute.
+func (h *KeywordGroupHandler) Mute(w http.ResponseWriter, r *http.Request) {
+	id, ok := groupID(w, r)
+	if !ok {
+		return
+	}
+	until, scope, ok := decodeMute(w, r)
+	if !ok {
+		return
+	}
+
+	g, err := h.repo.Mute(r.Context(), id, until, scope)
+	if !h.ok(w, err, "failed to mute keyword group") {
+		return
+	}
+
+	h.audit.Record(r.Context(), model.AuditActionMute, model.AuditEntityKeywordGroup, strconv.Itoa(id),
+		map[string]model.AuditChange{
+			"muted_until": {New: until.UTC().Format(time.RFC3339)},
+			"mute_scope":  {New: scope},
+		})
+	writeJSON(w, http.StatusOK, g)
+}
+
+// Unmute lifts a group's mute before it lapses.
+func (h *KeywordGroupHandler) Unmute(w http.ResponseWriter, r *http.Request) {
+	id, ok := groupID(w, r)
+	if !ok {
+		return
+	}
+	g, err := h.repo.Unmute(r.Context(), id)
+	if !h.ok(w, err, "failed to unmute keyword group") {
+		return
+	}
+	h.audit.Record(r.Context(), model.AuditActionUnmute, model.AuditEntityKeywordGroup, strconv.Itoa(id), nil)
+	writeJSON(w, http.StatusOK, g)
+}
+
+// Disable stops every keyword in the group from being matched, with an
+// optional {reason}.
+func (h *KeywordGroupHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	id, ok := groupID(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeBodyError(w, err)
+		return
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		reason = "disabled by an operator"
+	}
+
+	g, err := h.repo.Disable(r.Context(), id, reason)
+	if !h.ok(w, err, "failed to disable keyword group") {
+		return
+	}
+	h.audit.Record(r.Context(), model.AuditActionDisable, model.AuditEntityKeywordGroup, strconv.Itoa(id),
+		map[string]model.AuditChange{"disabled_reason": {New: reason}})
+	writeJSON(w, http.StatusOK, g)
+}
+
+// Enable lifts a group's disable; keywords disabled on their own stay so.
+func (h *KeywordGroupHandler) Enable(w http.ResponseWriter, r *http.Request) {
+	id, ok := groupID(w, r)
+	if !ok {
+		return
+	}
+	g, err := h.repo.Enable(r.Context(), id)
+	if !h.ok(w, err, "failed to enable keyword group") {
+		return
+	}
+	h.audit.Record(r.Context(), model.AuditActionEnable, model.AuditEntityKeywordGroup, strconv.Itoa(id), nil)
+	writeJSON(w, http.StatusOK, g)
+}
+
+// ok writes the response for a failed store call, 404 for an unknown
+// group and 500 with msg otherwise, and reports whether err was nil.
+func (h *KeywordGroupHandler) ok(w http.ResponseWriter, err error, msg string) bool {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		writeError(w, http.StatusNotFound, "keyword group not found")
+		return false
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, msg)
+		return false
+	}
+	return true
+}
+
+func groupID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid keyword group id")
+		return 0, false
+	}
+	return id, true
+}
+
+// decodeGroup reads and validates a {name, match_mode} body, writing the
+// 400 when it is bad.
+func decodeGroup(w http.ResponseWriter, r *http.Request) (name, mode string, ok bool) {
+	var req struct {
+		Name      string `json:"name"`
+		MatchMode string `json:"match_mode"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return "", "", false
+	}
+	name = strings.TrimSpace(req.Name)
+	if name == "" || len(name) > maxGroupNameLength {
+		writeError(w, http.StatusBadRequest, "name is required and at most 100 characters")
+		return "", "", false
+	}
+	if req.MatchMode != "" && !model.ValidMatchMode(req.MatchMode) {
+		writeError(w, http.StatusBadRequest, "match_mode must be substring or boundary")
+		return "", "", false
+	}
+	return name, req.MatchMode, true
+}
@@ -0,0 +1,296 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/repository"
+)
+
+type mockKeywordGroupStore struct {
+	listFn    func(ctx context.Context) ([]model.KeywordGroup, error)
+	getFn     func(ctx context.Context, id int) (*model.KeywordGroup, error)
+	createFn  func(ctx context.Context, name, mode string) (*model.KeywordGroup, error)
+	updateFn  func(ctx context.Context, id int, name, mode string) (*model.KeywordGroup, error)
+	muteFn    func(ctx context.Context, id int, until time.Time, scope string) (*model.KeywordGroup, error)
+	unmuteFn  func(ctx context.Context, id int) (*model.KeywordGroup, error)
+	disableFn func(ctx context.Context, id int, reason string) (*model.KeywordGroup, error)
+	enableFn  func(ctx context.Context, id int) (*model.KeywordGroup, error)
+	deleteFn  func(ctx context.Context, id int, withKeywords bool) (int64, error)
+}
+
+func (m *mockKeywordGroupStore) List(ctx context.Context) ([]model.KeywordGroup, error) {
+	return m.listFn(ctx)
+}
+func (m *mockKeywordGroupStore) Get(ctx context.Context, id int) (*model.KeywordGroup, error) {
+	return m.getFn(ctx, id)
+}
+func (m *mockKeywordGroupStore) Create(ctx context.Context, name, mode string) (*model.KeywordGroup, error) {
+	return m.createFn(ctx, name, mode)
+}
+func (m *mockKeywordGroupStore) Update(ctx context.Context, id int, name, mode string) (*model.KeywordGroup, error) {
+	return m.updateFn(ctx, id, name, mode)
+}
+func (m *mockKeywordGroupStore) Mute(ctx context.Context, id int, until time.Time, scope string) (*model.KeywordGroup, error) {
+	return m.muteFn(ctx, id, until, scope)
+}
+func (m *mockKeywordGroupStore) Unmute(ctx context.Context, id int) (*model.KeywordGroup, error) {
+	return m.unmuteFn(ctx, id)
+}
+func (m *mockKeywordGroupStore) Disable(ctx context.Context, id int, reason string) (*model.KeywordGroup, error) {
+	return m.disableFn(ctx, id, reason)
+}
+func (m *mockKeywordGroupStore) Enable(ctx context.Context, id int) (*model.KeywordGroup, error) {
+	return m.enableFn(ctx, id)
+}
+func (m *mockKeywordGroupStore) Delete(ctx context.Context, id int, withKeywords bool) (int64, error) {
+	return m.deleteFn(ctx, id, withKeywords)
+}
+
+// groupRequest builds a request for keyword group id with body.
+func groupRequest(method, target, id, body string) *http.Request {
+	req := chiRequest(method, target, map[string]string{"id": id})
+	req.Body = io.NopCloser(strings.NewReader(body))
+	return req
+}
+
+func TestKeywordGroupCreate(t *testing.T) {
+	var gotName, gotMode string
+	audit := &mockAuditRecorder{}
+	h := NewKeywordGroupHandler(&mockKeywordGroupStore{
+		createFn: func(ctx context.Context, name, mode string) (*model.KeywordGroup, error) {
+			gotName, gotMode = name, mode
+			if name == "Taken" {
+				return nil, &pgconn.PgError{Code: "23505"}
+			}
+			return &model.KeywordGroup{ID: 1, Name: name, MatchMode: mode}, nil
+		},
+	}, audit)
+
+	rec := httptest.NewRecorder()
+	h.Create(rec, httptest.NewRequest(http.MethodPost, "/keyword-groups", strings.NewReader(`{"name":" Brand X ","match_mode":"boundary"}`)))
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	if gotName != "Brand X" || gotMode != model.MatchModeBoundary {
+		t.Errorf("Create(%q, %q), want Brand X, boundary", gotName, gotMode)
+	}
+	if len(audit.calls) != 1 || audit.calls[0].entityType != model.AuditEntityKeywordGroup || audit.calls[0].changes["name"].New != "Brand X" {
+		t.Errorf("audit calls = %+v, want one create of Brand X", audit.calls)
+	}
+
+	for _, tt := range []struct {
+		body string
+		want int
+	}{
+		{`{"name":"Taken"}`, http.StatusConflict},
+		{`{"name":"  "}`, http.StatusBadRequest},
+		{`{"name":"` + strings.Repeat("x", 101) + `"}`, http.StatusBadRequest},
+		{`{"name":"Y","match_mode":"regex"}`, http.StatusBadRequest},
+		{`{"name":`, http.StatusBadRequest},
+	} {
+		rec := httptest.NewRecorder()
+		h.Create(rec, httptest.NewRequest(http.MethodPost, "/keyword-groups", strings.NewReader(tt.body)))
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.body, rec.Code, tt.want)
+		}
+	}
+	if len(audit.calls) != 1 {
+		t.Errorf("audit calls = %d, want 1", len(audit.calls))
+	}
+}
+
+func TestKeywordGroupListAndGet(t *testing.T) {
+	h := NewKeywordGroupHandler(&mockKeywordGroupStore{
+		listFn: func(ctx context.Context) ([]model.KeywordGroup, error) {
+			return nil, nil
+		},
+		getFn: func(ctx context.Context, id int) (*model.KeywordGroup, error) {
+			if id != 1 {
+				return nil, repository.ErrNotFound
+			}
+			return &model.KeywordGroup{ID: 1, Name: "Brand X", KeywordCount: 15}, nil
+		},
+	}, &mockAuditRecorder{})
+
+	rec := httptest.NewRecorder()
+	h.List(rec, httptest.NewRequest(http.MethodGet, "/keyword-groups", nil))
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `{"groups":[]}` {
+		t.Errorf("list: status = %d, body = %s", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	h.Get(rec, chiRequest(http.MethodGet, "/keyword-groups/1", map[string]string{"id": "1"}))
+	var g model.KeywordGroup
+	if err := json.NewDecoder(rec.Body).Decode(&g); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || g.KeywordCount != 15 {
+		t.Errorf("get: status = %d, group = %+v", rec.Code, g)
+	}
+
+	for id, want := range map[string]int{"2": http.StatusNotFound, "x": http.StatusBadRequest} {
+		rec = httptest.NewRecorder()
+		h.Get(rec, chiRequest(http.MethodGet, "/keyword-groups/"+id, map[string]string{"id": id}))
+		if rec.Code != want {
+			t.Errorf("get %s: status = %d, want %d", id, rec.Code, want)
+		}
+	}
+}
+
+func TestKeywordGroupUpdate(t *testing.T) {
+	audit := &mockAuditRecorder{}
+	h := NewKeywordGroupHandler(&mockKeywordGroupStore{
+		updateFn: func(ctx context.Context, id int, name, mode string) (*model.KeywordGroup, error) {
+			if id == 9 {
+				return nil, repository.ErrNotFound
+			}
+			return &model.KeywordGroup{ID: id, Name: name, MatchMode: mode}, nil
+		},
+	}, audit)
+
+	rec := httptest.NewRecorder()
+	h.Update(rec, groupRequest(http.MethodPut, "/keyword-groups/1", "1", `{"name":"Brand Y","match_mode":""}`))
+	if rec.Code != http.StatusOK || len(audit.calls) != 1 || audit.calls[0].action != model.AuditActionUpdate {
+		t.Errorf("status = %d, audit = %+v; want 200 and one update", rec.Code, audit.calls)
+	}
+
+	rec = httptest.NewRecorder()
+	h.Update(rec, groupRequest(http.MethodPut, "/keyword-groups/9", "9", `{"name":"Brand Y"}`))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown group: status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestKeywordGroupDelete(t *testing.T) {
+	var gotWith []bool
+	audit := &mockAuditRecorder{}
+	h := NewKeywordGroupHandler(&mockKeywordGroupStore{
+		deleteFn: func(ctx context.Context, id int, withKeywords bool) (int64, error) {
+			if id == 9 {
+				return 0, repository.ErrNotFound
+			}
+			gotWith = append(gotWith, withKeywords)
+			if withKeywords {
+				return 15, nil
+			}
+			return 0, nil
+		},
+	}, audit)
+
+	rec := httptest.NewRecorder()
+	h.Delete(rec, chiRequest(http.MethodDelete, "/keyword-groups/1", map[string]string{"id": "1"}))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"deleted_keywords":0`) {
+		t.Errorf("ungroup: status = %d, body = %s", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	h.Delete(rec, chiRequest(http.MethodDelete, "/keyword-groups/1?delete_keywords=true", map[string]string{"id": "1"}))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"deleted_keywords":15`) {
+		t.Errorf("with keywords: status = %d, body = %s", rec.Code, rec.Body)
+	}
+	if len(gotWith) != 2 || gotWith[0] || !gotWith[1] {
+		t.Errorf("withKeywords = %v, want [false true]", gotWith)
+	}
+	if len(audit.calls) != 2 || audit.calls[1].changes["deleted_keywords"].Old != "15" {
+		t.Errorf("audit calls = %+v, want two deletes, the second of 15 keywords", audit.calls)
+	}
+
+	for target, want := range map[string]int{
+		"/keyword-groups/1?delete_keywords=maybe": http.StatusBadRequest,
+		"/keyword-groups/9":                       http.StatusNotFound,
+	} {
+		id := strings.TrimPrefix(strings.SplitN(target, "?", 2)[0], "/keyword-groups/")
+		rec = httptest.NewRecorder()
+		h.Delete(rec, chiRequest(http.MethodDelete, target, map[string]string{"id": id}))
+		if rec.Code != want {
+			t.Errorf("%s: status = %d, want %d", target, rec.Code, want)
+		}
+	}
+}
+
+func TestKeywordGroupMuteAndUnmute(t *testing.T) {
+	var gotScope string
+	audit := &mockAuditRecorder{}
+	h := NewKeywordGroupHandler(&mockKeywordGroupStore{
+		muteFn: func(ctx context.Context, id int, until time.Time, scope string) (*model.KeywordGroup, error) {
+			gotScope = scope
+			return &model.KeywordGroup{ID: id, MutedUntil: &until, MuteScope: scope}, nil
+		},
+		unmuteFn: func(ctx context.Context, id int) (*model.KeywordGroup, error) {
+			return nil, repository.ErrNotFound
+		},
+	}, audit)
+
+	until := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	rec := httptest.NewRecorder()
+	h.Mute(rec, groupRequest(http.MethodPost, "/keyword-groups/1/mute", "1", `{"until":"`+until+`"}`))
+	if rec.Code != http.StatusOK || gotScope != model.MuteScopeNotifications {
+		t.Errorf("status = %d, scope = %q; want 200 and notifications", rec.Code, gotScope)
+	}
+	if len(audit.calls) != 1 || audit.calls[0].action != model.AuditActionMute || audit.calls[0].changes["muted_until"].New != until {
+		t.Errorf("audit calls = %+v, want one mute until %s", audit.calls, until)
+	}
+
+	rec = httptest.NewRecorder()
+	h.Mute(rec, groupRequest(http.MethodPost, "/keyword-groups/1/mute", "1", `{"until":"2020-01-01T00:00:00Z"}`))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("past until: status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	rec = httptest.NewRecorder()
+	h.Unmute(rec, chiRequest(http.MethodDelete, "/keyword-groups/9/mute", map[string]string{"id": "9"}))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unmute unknown group: status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestKeywordGroupDisableAndEnable(t *testing.T) {
+	var gotReason string
+	audit := &mockAuditRecorder{}
+	h := NewKeywordGroupHandler(&mockKeywordGroupStore{
+		disableFn: func(ctx context.Context, id int, reason string) (*model.KeywordGroup, error) {
+			gotReason = reason
+			now := time.Now()
+			return &model.KeywordGroup{ID: id, DisabledAt: &now, DisabledReason: reason}, nil
+		},
+		enableFn: func(ctx context.Context, id int) (*model.KeywordGroup, error) {
+			return nil, errors.New("db down")
+		},
+	}, audit)
+
+	rec := httptest.NewRecorder()
+	h.Disable(rec, chiRequest(http.MethodPost, "/keyword-groups/1/disable", map[string]string{"id": "1"}))
+	if rec.Code != http.StatusOK || gotReason != "disabled by an operator" {
+		t.Errorf("no body: status = %d, reason = %q", rec.Code, gotReason)
+	}
+
+	rec = httptest.NewRecorder()
+	h.Disable(rec, groupRequest(http.MethodPost, "/keyword-groups/1/disable", "1", `{"reason":"brand retired"}`))
+	if rec.Code != http.StatusOK || gotReason != "brand retired" {
+		t.Errorf("with reason: status = %d, reason = %q", rec.Code, gotReason)
+	}
+	if len(audit.calls) != 2 || audit.calls[1].action != model.AuditActionDisable || audit.calls[1].changes["disabled_reason"].New != "brand retired" {
+		t.Errorf("audit calls = %+v, want two disables", audit.calls)
+	}
+
+	rec = httptest.NewRecorder()
+	h.Enable(rec, chiRequest(http.MethodPost, "/keyword-groups/1/enable", map[string]string{"id": "1"}))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("enable failure: status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	if len(audit.calls) != 2 {
+		t.Errorf("audit calls = %d, want 2", len(audit.calls))
+	}
+}
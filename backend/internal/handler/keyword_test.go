@@ -1,11 +1,17 @@
 package handler
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -18,20 +24,60 @@ import (
 
 // mockKeywordStore implements keywordStore for testing.
 type mockKeywordStore struct {
-	listFn   func(ctx context.Context) ([]model.Keyword, error)
-	createFn func(ctx context.Context, value string) (*model.Keyword, error)
-	deleteFn func(ctx context.Context, id int) error
+	listFn       func(ctx context.Context) ([]model.Keyword, error)
+	createFn     func(ctx context.Context, value, mode string, groupID *int, alternatives []string, activeFrom, activeUntil *time.Time) (*model.Keyword, error)
+	setGroupFn   func(ctx context.Context, id int, groupID *int) (*model.Keyword, error)
+	setAltsFn    func(ctx context.Context, id int, alternatives []string) (*model.Keyword, error)
+	deleteFn     func(ctx context.Context, id int) (*model.Keyword, error)
+	muteFn       func(ctx context.Context, id int, until time.Time, scope string) (*model.Keyword, error)
+	unmuteFn     func(ctx context.Context, id int) (*model.Keyword, error)
+	enableFn     func(ctx context.Context, id int) (*model.Keyword, error)
+	createManyFn func(ctx context.Context, values []string) (int, error)
 }
 
 func (m *mockKeywordStore) List(ctx context.Context) ([]model.Keyword, error) {
 	return m.listFn(ctx)
 }
-func (m *mockKeywordStore) Create(ctx context.Context, value string) (*model.Keyword, error) {
-	return m.createFn(ctx, value)
+func (m *mockKeywordStore) Create(ctx context.Context, value, mode string, groupID *int, alternatives []string, activeFrom, activeUntil *time.Time) (*model.Keyword, error) {
+	return m.createFn(ctx, value, mode, groupID, alternatives, activeFrom, activeUntil)
 }
-func (m *mockKeywordStore) Delete(ctx context.Context, id int) error {
+func (m *mockKeywordStore) SetGroup(ctx context.Context, id int, groupID *int) (*model.Keyword, error) {
+	return m.setGroupFn(ctx, id, groupID)
+}
+func (m *mockKeywordStore) SetAlternatives(ctx context.Context, id int, alternatives []string) (*model.Keyword, error) {
+	return m.setAltsFn(ctx, id, alternatives)
+}
+func (m *mockKeywordStore) Delete(ctx context.Context, id int) (*model.Keyword, error) {
 	return m.deleteFn(ctx, id)
 }
+func (m *mockKeywordStore) Mute(ctx context.Context, id int, until time.Time, scope string) (*model.Keyword, error) {
+	return m.muteFn(ctx, id, until, scope)
+}
+func (m *mockKeywordStore) Unmute(ctx context.Context, id int) (*model.Keyword, error) {
+	return m.unmuteFn(ctx, id)
+}
+func (m *mockKeywordStore) Enable(ctx context.Context, id int) (*model.Keyword, error) {
+	return m.enableFn(ctx, id)
+}
+func (m *mockKeywordStore) CreateMany(ctx context.Context, values []string) (int, error) {
+	return m.createManyFn(ctx, values)
+}
+
+// memoryKeywordStore is a mockKeywordStore backed by a slice, for
+// round-trip tests.
+func memoryKeywordStore(keywords *[]model.Keyword) *mockKeywordStore {
+	return &mockKeywordStore{
+		listFn: func(ctx context.Context) ([]model.Keyword, error) {
+			return *keywords, nil
+		},
+		createManyFn: func(ctx context.Context, values []string) (int, error) {
+			for _, v := range values {
+				*keywords = append(*keywords, model.Keyword{ID: len(*keywords) + 1, Value: v, CreatedAt: time.Now()})
+			}
+			return len(values), nil
+		},
+	}
+}
 
 func TestKeywordList_Success(t *testing.T) {
 	h := NewKeywordHandler(&mockKeywordStore{
@@ -40,7 +86,7 @@ func TestKeywordList_Success(t *testing.T) {
 				{ID: 1, Value: "example", CreatedAt: time.Now()},
 			}, nil
 		},
-	})
+	}, &mockAuditRecorder{})
 
 	req := httptest.NewRequest(http.MethodGet, "/keywords", nil)
 	rec := httptest.NewRecorder()
@@ -59,12 +105,47 @@ func TestKeywordList_Success(t *testing.T) {
 	}
 }
 
+func TestKeywordList_GroupFilter(t *testing.T) {
+	one, two := 1, 2
+	h := NewKeywordHandler(&mockKeywordStore{
+		listFn: func(ctx context.Context) ([]model.Keyword, error) {
+			return []model.Keyword{
+				{ID: 1, Value: "paypal", GroupID: &one},
+				{ID: 2, Value: "paypa1", GroupID: &one},
+				{ID: 3, Value: "amazon", GroupID: &two},
+				{ID: 4, Value: "google"},
+			}, nil
+		},
+	}, &mockAuditRecorder{})
+
+	rec := httptest.NewRecorder()
+	h.List(rec, httptest.NewRequest(http.MethodGet, "/keywords?group=1", nil))
+
+	var body struct {
+		Keywords []model.Keyword `json:"keywords"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || len(body.Keywords) != 2 || body.Keywords[0].ID != 1 || body.Keywords[1].ID != 2 {
+		t.Errorf("status = %d, keywords = %+v; want keywords 1 and 2", rec.Code, body.Keywords)
+	}
+
+	for _, v := range []string{"brand", "0"} {
+		rec = httptest.NewRecorder()
+		h.List(rec, httptest.NewRequest(http.MethodGet, "/keywords?group="+v, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("group=%s: status = %d, want %d", v, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
 func TestKeywordList_Empty(t *testing.T) {
 	h := NewKeywordHandler(&mockKeywordStore{
 		listFn: func(ctx context.Context) ([]model.Keyword, error) {
 			return nil, nil
 		},
-	})
+	}, &mockAuditRecorder{})
 
 	req := httptest.NewRequest(http.MethodGet, "/keywords", nil)
 	rec := httptest.NewRecorder()
@@ -88,7 +169,7 @@ func TestKeywordList_Error(t *testing.T) {
 		listFn: func(ctx context.Context) ([]model.Keyword, error) {
 			return nil, errors.New("db error")
 		},
-	})
+	}, &mockAuditRecorder{})
 
 	req := httptest.NewRequest(http.MethodGet, "/keywords", nil)
 	rec := httptest.NewRecorder()
@@ -101,10 +182,13 @@ func TestKeywordList_Error(t *testing.T) {
 
 func TestKeywordCreate_Success(t *testing.T) {
 	h := NewKeywordHandler(&mockKeywordStore{
-		createFn: func(ctx context.Context, value string) (*model.Keyword, error) {
-			return &model.Keyword{ID: 1, Value: value, CreatedAt: time.Now()}, nil
+		createFn: func(ctx context.Context, value, mode string, groupID *int, alternatives []string, activeFrom, activeUntil *time.Time) (*model.Keyword, error) {
+			if mode != model.MatchModeSubstring {
+				t.Errorf("mode = %q, want %q", mode, model.MatchModeSubstring)
+			}
+			return &model.Keyword{ID: 1, Value: value, MatchMode: mode, CreatedAt: time.Now()}, nil
 		},
-	})
+	}, &mockAuditRecorder{})
 
 	body := strings.NewReader(`{"value":"example"}`)
 	req := httptest.NewRequest(http.MethodPost, "/keywords", body)
@@ -122,8 +206,142 @@ func TestKeywordCreate_Success(t *testing.T) {
 	}
 }
 
+func TestKeywordCreate_BoundaryMode(t *testing.T) {
+	h := NewKeywordHandler(&mockKeywordStore{
+		createFn: func(ctx context.Context, value, mode string, groupID *int, alternatives []string, activeFrom, activeUntil *time.Time) (*model.Keyword, error) {
+			return &model.Keyword{ID: 1, Value: value, MatchMode: mode, CreatedAt: time.Now()}, nil
+		},
+	}, &mockAuditRecorder{})
+
+	body := strings.NewReader(`{"value":"paypal","match_mode":"boundary"}`)
+	req := httptest.NewRequest(http.MethodPost, "/keywords", body)
+	rec := httptest.NewRecorder()
+	h.Create(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusCreated)
+	}
+	var kw model.Keyword
+	json.NewDecoder(rec.Body).Decode(&kw)
+	if kw.MatchMode != model.MatchModeBoundary {
+		t.Errorf("MatchMode = %q, want %q", kw.MatchMode, model.MatchModeBoundary)
+	}
+}
+
+func TestKeywordCreate_InvalidMatchMode(t *testing.T) {
+	h := NewKeywordHandler(&mockKeywordStore{}, &mockAuditRecorder{})
+
+	body := strings.NewReader(`{"value":"paypal","match_mode":"regex"}`)
+	req := httptest.NewRequest(http.MethodPost, "/keywords", body)
+	rec := httptest.NewRecorder()
+	h.Create(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestKeywordCreate_ActiveWindow(t *testing.T) {
+	var gotFrom, gotUntil *time.Time
+	audit := &mockAuditRecorder{}
+	h := NewKeywordHandler(&mockKeywordStore{
+		createFn: func(ctx context.Context, value, mode string, groupID *int, alternatives []string, activeFrom, activeUntil *time.Time) (*model.Keyword, error) {
+			gotFrom, gotUntil = activeFrom, activeUntil
+			return &model.Keyword{ID: 1, Value: value, ActiveFrom: activeFrom, ActiveUntil: activeUntil}, nil
+		},
+	}, audit)
+
+	from := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	until := from.Add(14 * 24 * time.Hour)
+	body := fmt.Sprintf(`{"value":"campaign","active_from":%q,"active_until":%q}`,
+		from.Format(time.RFC3339), until.Format(time.RFC3339))
+	rec := httptest.NewRecorder()
+	h.Create(rec, httptest.NewRequest(http.MethodPost, "/keywords", strings.NewReader(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	if gotFrom == nil || !gotFrom.Equal(from) || gotUntil == nil || !gotUntil.Equal(until) {
+		t.Errorf("Create window = %v..%v, want %v..%v", gotFrom, gotUntil, from, until)
+	}
+	if audit.calls[0].changes["active_until"].New != until.Format(time.RFC3339) {
+		t.Errorf("audit changes = %+v, want active_until", audit.calls[0].changes)
+	}
+
+	for _, body := range []string{
+		`{"value":"campaign","active_until":"2020-01-01T00:00:00Z"}`,
+		fmt.Sprintf(`{"value":"campaign","active_from":%q,"active_until":%q}`,
+			until.Format(time.RFC3339), until.Format(time.RFC3339)),
+		`{"value":"campaign","active_from":"next week"}`,
+	} {
+		rec := httptest.NewRecorder()
+		h.Create(rec, httptest.NewRequest(http.MethodPost, "/keywords", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", body, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestKeywordCreate_RecordsAudit(t *testing.T) {
+	audit := &mockAuditRecorder{}
+	h := NewKeywordHandler(&mockKeywordStore{
+		createFn: func(ctx context.Context, value, mode string, groupID *int, alternatives []string, activeFrom, activeUntil *time.Time) (*model.Keyword, error) {
+			return &model.Keyword{ID: 7, Value: value, CreatedAt: time.Now()}, nil
+		},
+	}, audit)
+
+	req := httptest.NewRequest(http.MethodPost, "/keywords", strings.NewReader(`{"value":"example"}`))
+	h.Create(httptest.NewRecorder(), req)
+
+	if len(audit.calls) != 1 {
+		t.Fatalf("got %d audit calls, want 1", len(audit.calls))
+	}
+	call := audit.calls[0]
+	if call.action != model.AuditActionCreate || call.entityType != model.AuditEntityKeyword || call.entityID != "7" {
+		t.Errorf("audit call = %+v", call)
+	}
+	if call.changes["value"].New != "example" {
+		t.Errorf("changes[value].New = %q, want %q", call.changes["value"].New, "example")
+	}
+}
+
+func TestKeywordCreate_InGroup(t *testing.T) {
+	var gotMode string
+	var gotGroup *int
+	audit := &mockAuditRecorder{}
+	h := NewKeywordHandler(&mockKeywordStore{
+		createFn: func(ctx context.Context, value, mode string, groupID *int, alternatives []string, activeFrom, activeUntil *time.Time) (*model.Keyword, error) {
+			gotMode, gotGroup = mode, groupID
+			if *groupID == 9 {
+				return nil, repository.ErrGroupNotFound
+			}
+			return &model.Keyword{ID: 4, Value: value, MatchMode: model.MatchModeBoundary, GroupID: groupID,
+				Inherited: []string{"match_mode"}}, nil
+		},
+	}, audit)
+
+	rec := httptest.NewRecorder()
+	h.Create(rec, httptest.NewRequest(http.MethodPost, "/keywords", strings.NewReader(`{"value":"paypal-x","group_id":2}`)))
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	// Without a match_mode a grouped keyword stores none, taking the group's.
+	if gotMode != "" || gotGroup == nil || *gotGroup != 2 {
+		t.Errorf("Create(mode %q, group %v), want \"\" and 2", gotMode, gotGroup)
+	}
+	if len(audit.calls) != 1 || audit.calls[0].changes["group_id"].New != "2" {
+		t.Errorf("audit calls = %+v, want one create with group_id 2", audit.calls)
+	}
+
+	rec = httptest.NewRecorder()
+	h.Create(rec, httptest.NewRequest(http.MethodPost, "/keywords", strings.NewReader(`{"value":"paypal-y","group_id":9}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown group: status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
 func TestKeywordCreate_EmptyValue(t *testing.T) {
-	h := NewKeywordHandler(&mockKeywordStore{})
+	h := NewKeywordHandler(&mockKeywordStore{}, &mockAuditRecorder{})
 
 	body := strings.NewReader(`{"value":"   "}`)
 	req := httptest.NewRequest(http.MethodPost, "/keywords", body)
@@ -136,7 +354,7 @@ func TestKeywordCreate_EmptyValue(t *testing.T) {
 }
 
 func TestKeywordCreate_TooShort(t *testing.T) {
-	h := NewKeywordHandler(&mockKeywordStore{})
+	h := NewKeywordHandler(&mockKeywordStore{}, &mockAuditRecorder{})
 
 	body := strings.NewReader(`{"value":"ab"}`)
 	req := httptest.NewRequest(http.MethodPost, "/keywords", body)
@@ -149,7 +367,7 @@ func TestKeywordCreate_TooShort(t *testing.T) {
 }
 
 func TestKeywordCreate_InvalidJSON(t *testing.T) {
-	h := NewKeywordHandler(&mockKeywordStore{})
+	h := NewKeywordHandler(&mockKeywordStore{}, &mockAuditRecorder{})
 
 	body := strings.NewReader(`not json`)
 	req := httptest.NewRequest(http.MethodPost, "/keywords", body)
@@ -163,10 +381,10 @@ func TestKeywordCreate_InvalidJSON(t *testing.T) {
 
 func TestKeywordCreate_Duplicate(t *testing.T) {
 	h := NewKeywordHandler(&mockKeywordStore{
-		createFn: func(ctx context.Context, value string) (*model.Keyword, error) {
+		createFn: func(ctx context.Context, value, mode string, groupID *int, alternatives []string, activeFrom, activeUntil *time.Time) (*model.Keyword, error) {
 			return nil, errors.New("duplicate key value violates unique constraint")
 		},
-	})
+	}, &mockAuditRecorder{})
 
 	body := strings.NewReader(`{"value":"example"}`)
 	req := httptest.NewRequest(http.MethodPost, "/keywords", body)
@@ -180,10 +398,10 @@ func TestKeywordCreate_Duplicate(t *testing.T) {
 
 func TestKeywordCreate_Error(t *testing.T) {
 	h := NewKeywordHandler(&mockKeywordStore{
-		createFn: func(ctx context.Context, value string) (*model.Keyword, error) {
+		createFn: func(ctx context.Context, value, mode string, groupID *int, alternatives []string, activeFrom, activeUntil *time.Time) (*model.Keyword, error) {
 			return nil, errors.New("db error")
 		},
-	})
+	}, &mockAuditRecorder{})
 
 	body := strings.NewReader(`{"value":"example"}`)
 	req := httptest.NewRequest(http.MethodPost, "/keywords", body)
@@ -207,13 +425,13 @@ func chiRequest(method, target string, params map[string]string) *http.Request {
 
 func TestKeywordDelete_Success(t *testing.T) {
 	h := NewKeywordHandler(&mockKeywordStore{
-		deleteFn: func(ctx context.Context, id int) error {
+		deleteFn: func(ctx context.Context, id int) (*model.Keyword, error) {
 			if id != 42 {
 				t.Errorf("id = %d, want 42", id)
 			}
-			return nil
+			return &model.Keyword{ID: id, Value: "example"}, nil
 		},
-	})
+	}, &mockAuditRecorder{})
 
 	req := chiRequest(http.MethodDelete, "/keywords/42", map[string]string{"id": "42"})
 	rec := httptest.NewRecorder()
@@ -224,8 +442,49 @@ func TestKeywordDelete_Success(t *testing.T) {
 	}
 }
 
+func TestKeywordDelete_RecordsAudit(t *testing.T) {
+	audit := &mockAuditRecorder{}
+	h := NewKeywordHandler(&mockKeywordStore{
+		deleteFn: func(ctx context.Context, id int) (*model.Keyword, error) {
+			group := 3
+			return &model.Keyword{ID: id, Value: "example", MatchMode: "contains", GroupID: &group,
+				Alternatives: []string{"exmpl", "examp1e"}}, nil
+		},
+	}, audit)
+
+	req := chiRequest(http.MethodDelete, "/keywords/42", map[string]string{"id": "42"})
+	h.Delete(httptest.NewRecorder(), req)
+
+	if len(audit.calls) != 1 || audit.calls[0].action != model.AuditActionDelete || audit.calls[0].entityID != "42" {
+		t.Fatalf("audit calls = %+v, want one delete of keyword 42", audit.calls)
+	}
+	want := map[string]model.AuditChange{
+		"value":        {Old: "example"},
+		"match_mode":   {Old: "contains"},
+		"group_id":     {Old: "3"},
+		"alternatives": {Old: "exmpl,examp1e"},
+	}
+	if got := audit.calls[0].changes; !maps.Equal(got, want) {
+		t.Errorf("changes = %v, want the deleted keyword's fields as old values %v", got, want)
+	}
+}
+
+func TestKeywordDelete_NotFound_NoAudit(t *testing.T) {
+	audit := &mockAuditRecorder{}
+	h := NewKeywordHandler(&mockKeywordStore{
+		deleteFn: func(ctx context.Context, id int) (*model.Keyword, error) { return nil, repository.ErrNotFound },
+	}, audit)
+
+	req := chiRequest(http.MethodDelete, "/keywords/1", map[string]string{"id": "1"})
+	h.Delete(httptest.NewRecorder(), req)
+
+	if len(audit.calls) != 0 {
+		t.Errorf("got %d audit calls for failed delete, want 0", len(audit.calls))
+	}
+}
+
 func TestKeywordDelete_InvalidID(t *testing.T) {
-	h := NewKeywordHandler(&mockKeywordStore{})
+	h := NewKeywordHandler(&mockKeywordStore{}, &mockAuditRecorder{})
 
 	req := chiRequest(http.MethodDelete, "/keywords/abc", map[string]string{"id": "abc"})
 	rec := httptest.NewRecorder()
@@ -238,10 +497,10 @@ func TestKeywordDelete_InvalidID(t *testing.T) {
 
 func TestKeywordDelete_NotFound(t *testing.T) {
 	h := NewKeywordHandler(&mockKeywordStore{
-		deleteFn: func(ctx context.Context, id int) error {
-			return repository.ErrNotFound
+		deleteFn: func(ctx context.Context, id int) (*model.Keyword, error) {
+			return nil, repository.ErrNotFound
 		},
-	})
+	}, &mockAuditRecorder{})
 
 	req := chiRequest(http.MethodDelete, "/keywords/1", map[string]string{"id": "1"})
 	rec := httptest.NewRecorder()
@@ -254,10 +513,10 @@ func TestKeywordDelete_NotFound(t *testing.T) {
 
 func TestKeywordDelete_Error(t *testing.T) {
 	h := NewKeywordHandler(&mockKeywordStore{
-		deleteFn: func(ctx context.Context, id int) error {
-			return errors.New("db error")
+		deleteFn: func(ctx context.Context, id int) (*model.Keyword, error) {
+			return nil, errors.New("db error")
 		},
-	})
+	}, &mockAuditRecorder{})
 
 	req := chiRequest(http.MethodDelete, "/keywords/1", map[string]string{"id": "1"})
 	rec := httptest.NewRecorder()
@@ -267,3 +526,459 @@ func TestKeywordDelete_Error(t *testing.T) {
 		t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
 	}
 }
+
+func muteRequest(id, body string) *http.Request {
+	req := chiRequest(http.MethodPost, "/keywords/"+id+"/mute", map[string]string{"id": id})
+	req.Body = io.NopCloser(strings.NewReader(body))
+	return req
+}
+
+func TestKeywordMute_Success(t *testing.T) {
+	until := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	audit := &mockAuditRecorder{}
+	h := NewKeywordHandler(&mockKeywordStore{
+		muteFn: func(ctx context.Context, id int, u time.Time, scope string) (*model.Keyword, error) {
+			if id != 7 || !u.Equal(until) || scope != model.MuteScopeNotifications {
+				t.Errorf("Mute(%d, %v, %q), want 7, %v, notifications", id, u, scope, until)
+			}
+			return &model.Keyword{ID: id, Value: "example", MutedUntil: &u, MuteScope: scope}, nil
+		},
+	}, audit)
+
+	rec := httptest.NewRecorder()
+	h.Mute(rec, muteRequest("7", `{"until":"`+until.Format(time.RFC3339)+`"}`))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var kw model.Keyword
+	json.NewDecoder(rec.Body).Decode(&kw)
+	if kw.MutedUntil == nil || !kw.MutedUntil.Equal(until) || kw.MuteScope != model.MuteScopeNotifications {
+		t.Errorf("keyword = %+v, want muted until %v", kw, until)
+	}
+	if len(audit.calls) != 1 || audit.calls[0].action != model.AuditActionMute ||
+		audit.calls[0].changes["mute_scope"].New != model.MuteScopeNotifications {
+		t.Errorf("audit calls = %+v, want one mute", audit.calls)
+	}
+}
+
+func TestKeywordMute_MatchingScope(t *testing.T) {
+	var got string
+	h := NewKeywordHandler(&mockKeywordStore{
+		muteFn: func(ctx context.Context, id int, u time.Time, scope string) (*model.Keyword, error) {
+			got = scope
+			return &model.Keyword{ID: id}, nil
+		},
+	}, &mockAuditRecorder{})
+
+	until := time.Now().Add(time.Hour).Format(time.RFC3339)
+	h.Mute(httptest.NewRecorder(), muteRequest("1", `{"until":"`+until+`","scope":"matching"}`))
+	if got != model.MuteScopeMatching {
+		t.Errorf("scope = %q, want matching", got)
+	}
+}
+
+func TestKeywordMute_Invalid(t *testing.T) {
+	future := time.Now().Add(time.Hour).Format(time.RFC3339)
+	tests := []struct {
+		name, id, body, want string
+	}{
+		{"bad id", "abc", `{"until":"` + future + `"}`, "invalid keyword id"},
+		{"missing until", "1", `{}`, "until is required"},
+		{"past until", "1", `{"until":"2020-01-01T00:00:00Z"}`, "until must be in the future"},
+		{"bad scope", "1", `{"until":"` + future + `","scope":"everything"}`, "scope must be"},
+		{"bad json", "1", `{"until":"tomorrow"}`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audit := &mockAuditRecorder{}
+			h := NewKeywordHandler(&mockKeywordStore{}, audit)
+			rec := httptest.NewRecorder()
+			h.Mute(rec, muteRequest(tt.id, tt.body))
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+			if !strings.Contains(rec.Body.String(), tt.want) {
+				t.Errorf("body = %s, want it to mention %q", rec.Body, tt.want)
+			}
+			if len(audit.calls) != 0 {
+				t.Errorf("got %d audit calls, want 0", len(audit.calls))
+			}
+		})
+	}
+}
+
+func TestKeywordMute_NotFound(t *testing.T) {
+	h := NewKeywordHandler(&mockKeywordStore{
+		muteFn: func(ctx context.Context, id int, u time.Time, scope string) (*model.Keyword, error) {
+			return nil, repository.ErrNotFound
+		},
+	}, &mockAuditRecorder{})
+
+	rec := httptest.NewRecorder()
+	h.Mute(rec, muteRequest("9", `{"until":"`+time.Now().Add(time.Hour).Format(time.RFC3339)+`"}`))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestKeywordUnmute(t *testing.T) {
+	audit := &mockAuditRecorder{}
+	h := NewKeywordHandler(&mockKeywordStore{
+		unmuteFn: func(ctx context.Context, id int) (*model.Keyword, error) {
+			if id == 9 {
+				return nil, repository.ErrNotFound
+			}
+			return &model.Keyword{ID: id, Value: "example"}, nil
+		},
+	}, audit)
+
+	rec := httptest.NewRecorder()
+	h.Unmute(rec, chiRequest(http.MethodDelete, "/keywords/3/mute", map[string]string{"id": "3"}))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"muted_until":null`) {
+		t.Errorf("status = %d, body = %s; want 200 with muted_until null", rec.Code, rec.Body)
+	}
+	if len(audit.calls) != 1 || audit.calls[0].action != model.AuditActionUnmute {
+		t.Errorf("audit calls = %+v, want one unmute", audit.calls)
+	}
+
+	rec = httptest.NewRecorder()
+	h.Unmute(rec, chiRequest(http.MethodDelete, "/keywords/9/mute", map[string]string{"id": "9"}))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestKeywordEnable(t *testing.T) {
+	audit := &mockAuditRecorder{}
+	h := NewKeywordHandler(&mockKeywordStore{
+		enableFn: func(ctx context.Context, id int) (*model.Keyword, error) {
+			switch id {
+			case 9:
+				return nil, repository.ErrNotFound
+			case 10:
+				return nil, errors.New("db down")
+			}
+			return &model.Keyword{ID: id, Value: "com"}, nil
+		},
+	}, audit)
+
+	rec := httptest.NewRecorder()
+	h.Enable(rec, chiRequest(http.MethodPost, "/keywords/3/enable", map[string]string{"id": "3"}))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"disabled_at":null`) {
+		t.Errorf("status = %d, body = %s; want 200 with disabled_at null", rec.Code, rec.Body)
+	}
+	if len(audit.calls) != 1 || audit.calls[0].action != model.AuditActionEnable || audit.calls[0].entityID != "3" {
+		t.Errorf("audit calls = %+v, want one enable of keyword 3", audit.calls)
+	}
+
+	for id, want := range map[string]int{"9": http.StatusNotFound, "10": http.StatusInternalServerError, "x": http.StatusBadRequest} {
+		rec = httptest.NewRecorder()
+		h.Enable(rec, chiRequest(http.MethodPost, "/keywords/"+id+"/enable", map[string]string{"id": id}))
+		if rec.Code != want {
+			t.Errorf("id %s: status = %d, want %d", id, rec.Code, want)
+		}
+	}
+	if len(audit.calls) != 1 {
+		t.Errorf("audit calls = %d, want 1", len(audit.calls))
+	}
+}
+
+func TestKeywordSetGroup(t *testing.T) {
+	var gotGroup *int
+	audit := &mockAuditRecorder{}
+	h := NewKeywordHandler(&mockKeywordStore{
+		setGroupFn: func(ctx context.Context, id int, groupID *int) (*model.Keyword, error) {
+			gotGroup = groupID
+			switch {
+			case id == 9:
+				return nil, repository.ErrNotFound
+			case groupID != nil && *groupID == 9:
+				return nil, repository.ErrGroupNotFound
+			}
+			return &model.Keyword{ID: id, Value: "paypal", GroupID: groupID}, nil
+		},
+	}, audit)
+
+	setGroup := func(id, body string) *httptest.ResponseRecorder {
+		req := chiRequest(http.MethodPut, "/keywords/"+id+"/group", map[string]string{"id": id})
+		req.Body = io.NopCloser(strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		h.SetGroup(rec, req)
+		return rec
+	}
+
+	if rec := setGroup("3", `{"group_id":2}`); rec.Code != http.StatusOK || gotGroup == nil || *gotGroup != 2 {
+		t.Errorf("move: status = %d, group = %v; want 200 and 2", rec.Code, gotGroup)
+	}
+	if rec := setGroup("3", `{"group_id":null}`); rec.Code != http.StatusOK || gotGroup != nil {
+		t.Errorf("ungroup: status = %d, group = %v; want 200 and nil", rec.Code, gotGroup)
+	}
+	if len(audit.calls) != 2 || audit.calls[0].changes["group_id"].New != "2" || audit.calls[1].changes["group_id"].New != "" {
+		t.Errorf("audit calls = %+v, want a move to 2 then an ungroup", audit.calls)
+	}
+
+	for _, tt := range []struct {
+		id, body string
+		want     int
+	}{
+		{"9", `{"group_id":2}`, http.StatusNotFound},
+		{"3", `{"group_id":9}`, http.StatusBadRequest},
+		{"x", `{"group_id":2}`, http.StatusBadRequest},
+		{"3", `{"group_id":`, http.StatusBadRequest},
+	} {
+		if rec := setGroup(tt.id, tt.body); rec.Code != tt.want {
+			t.Errorf("id %s body %s: status = %d, want %d", tt.id, tt.body, rec.Code, tt.want)
+		}
+	}
+	if len(audit.calls) != 2 {
+		t.Errorf("audit calls = %d, want 2", len(audit.calls))
+	}
+}
+
+func TestKeywordCreate_Alternatives(t *testing.T) {
+	var gotAlts []string
+	audit := &mockAuditRecorder{}
+	h := NewKeywordHandler(&mockKeywordStore{
+		createFn: func(ctx context.Context, value, mode string, groupID *int, alternatives []string, activeFrom, activeUntil *time.Time) (*model.Keyword, error) {
+			gotAlts = alternatives
+			return &model.Keyword{ID: 1, Value: value, MatchMode: mode, Alternatives: alternatives}, nil
+		},
+	}, audit)
+
+	create := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/keywords", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		h.Create(rec, req)
+		return rec
+	}
+
+	rec := create(`{"value":"paypal","alternatives":[" paypa1 ","pypl","PYPL"]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusCreated)
+	}
+	if want := []string{"paypa1", "pypl"}; !slices.Equal(gotAlts, want) {
+		t.Errorf("stored alternatives = %q, want %q", gotAlts, want)
+	}
+	if len(audit.calls) != 1 || audit.calls[0].changes["alternatives"].New != "paypa1,pypl" {
+		t.Errorf("audit calls = %+v, want alternatives paypa1,pypl", audit.calls)
+	}
+
+	tooMany := `{"value":"paypal","alternatives":[` + strings.Repeat(`"abc",`, maxKeywordAlternatives) + `"abc"]}`
+	for _, body := range []string{
+		`{"value":"paypal","alternatives":["pp"]}`,
+		`{"value":"paypal","alternatives":["  "]}`,
+		tooMany,
+	} {
+		if rec := create(body); rec.Code != http.StatusBadRequest {
+			t.Errorf("body %.60s: status = %d, want %d", body, rec.Code, http.StatusBadRequest)
+		}
+	}
+	if len(audit.calls) != 1 {
+		t.Errorf("audit calls = %d, want 1", len(audit.calls))
+	}
+}
+
+func TestKeywordSetAlternatives(t *testing.T) {
+	var gotAlts []string
+	audit := &mockAuditRecorder{}
+	h := NewKeywordHandler(&mockKeywordStore{
+		setAltsFn: func(ctx context.Context, id int, alternatives []string) (*model.Keyword, error) {
+			if id == 9 {
+				return nil, repository.ErrNotFound
+			}
+			gotAlts = alternatives
+			return &model.Keyword{ID: id, Value: "paypal", Alternatives: alternatives}, nil
+		},
+	}, audit)
+
+	setAlts := func(id, body string) *httptest.ResponseRecorder {
+		req := chiRequest(http.MethodPut, "/keywords/"+id+"/alternatives", map[string]string{"id": id})
+		req.Body = io.NopCloser(strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		h.SetAlternatives(rec, req)
+		return rec
+	}
+
+	rec := setAlts("3", `{"alternatives":["pypl","paypa1"]}`)
+	if rec.Code != http.StatusOK || !slices.Equal(gotAlts, []string{"pypl", "paypa1"}) {
+		t.Errorf("set: status = %d, alternatives = %q; want 200 and [pypl paypa1]", rec.Code, gotAlts)
+	}
+	var kw model.Keyword
+	json.NewDecoder(rec.Body).Decode(&kw)
+	if !slices.Equal(kw.Values(), []string{"paypal", "pypl", "paypa1"}) {
+		t.Errorf("response Values() = %q, want [paypal pypl paypa1]", kw.Values())
+	}
+	if rec := setAlts("3", `{"alternatives":[]}`); rec.Code != http.StatusOK || len(gotAlts) != 0 {
+		t.Errorf("clear: status = %d, alternatives = %q; want 200 and none", rec.Code, gotAlts)
+	}
+	if len(audit.calls) != 2 || audit.calls[0].changes["alternatives"].New != "pypl,paypa1" || audit.calls[1].changes["alternatives"].New != "" {
+		t.Errorf("audit calls = %+v, want a set then a clear", audit.calls)
+	}
+
+	for _, tt := range []struct {
+		id, body string
+		want     int
+	}{
+		{"9", `{"alternatives":["pypl"]}`, http.StatusNotFound},
+		{"x", `{"alternatives":["pypl"]}`, http.StatusBadRequest},
+		{"3", `{"alternatives":["pp"]}`, http.StatusBadRequest},
+		{"3", `{"alternatives":`, http.StatusBadRequest},
+	} {
+		if rec := setAlts(tt.id, tt.body); rec.Code != tt.want {
+			t.Errorf("id %s body %s: status = %d, want %d", tt.id, tt.body, rec.Code, tt.want)
+		}
+	}
+	if len(audit.calls) != 2 {
+		t.Errorf("audit calls = %d, want 2", len(audit.calls))
+	}
+}
+
+func TestKeywordExportImport_RoundTrip(t *testing.T) {
+	for _, format := range []string{"json", "csv"} {
+		t.Run(format, func(t *testing.T) {
+			source := []model.Keyword{
+				{ID: 1, Value: "paypal", CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)},
+				{ID: 2, Value: "Amazon", CreatedAt: time.Date(2026, 2, 3, 4, 5, 6, 0, time.UTC)},
+				{ID: 3, Value: "bank,of,x", CreatedAt: time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)},
+			}
+			exporter := NewKeywordHandler(memoryKeywordStore(&source), &mockAuditRecorder{})
+
+			rec := httptest.NewRecorder()
+			exporter.Export(rec, httptest.NewRequest(http.MethodGet, "/keywords/export?format="+format, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("export status = %d, want %d", rec.Code, http.StatusOK)
+			}
+			if cd := rec.Header().Get("Content-Disposition"); !strings.Contains(cd, "keywords."+format) {
+				t.Errorf("Content-Disposition = %q", cd)
+			}
+			if format == "csv" && !strings.Contains(rec.Body.String(), "2026-01-02T03:04:05Z") {
+				t.Errorf("csv export missing created_at: %s", rec.Body.String())
+			}
+
+			var target []model.Keyword
+			audit := &mockAuditRecorder{}
+			importer := NewKeywordHandler(memoryKeywordStore(&target), audit)
+
+			req := httptest.NewRequest(http.MethodPost, "/keywords/import", strings.NewReader(rec.Body.String()))
+			if format == "csv" {
+				req.Header.Set("Content-Type", "text/csv")
+			}
+			rec = httptest.NewRecorder()
+			importer.Import(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("import status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+			}
+
+			got := map[string]bool{}
+			for _, kw := range target {
+				got[kw.Value] = true
+			}
+			for _, kw := range source {
+				if !got[kw.Value] {
+					t.Errorf("keyword %q missing after round trip", kw.Value)
+				}
+			}
+			if len(target) != len(source) {
+				t.Errorf("imported %d keywords, want %d", len(target), len(source))
+			}
+			if len(audit.calls) != 1 || audit.calls[0].action != model.AuditActionImport {
+				t.Errorf("audit calls = %+v, want one import", audit.calls)
+			}
+
+			// Importing the same export again adds nothing.
+			rec = httptest.NewRecorder()
+			exporter.Export(rec, httptest.NewRequest(http.MethodGet, "/keywords/export?format="+format, nil))
+			req = httptest.NewRequest(http.MethodPost, "/keywords/import", strings.NewReader(rec.Body.String()))
+			if format == "csv" {
+				req.Header.Set("Content-Type", "text/csv")
+			}
+			rec = httptest.NewRecorder()
+			importer.Import(rec, req)
+			if got := strings.TrimSpace(rec.Body.String()); got != `{"imported":0,"skipped":3}` {
+				t.Errorf("second import body = %s", got)
+			}
+		})
+	}
+}
+
+func TestKeywordExport_InvalidFormat(t *testing.T) {
+	h := NewKeywordHandler(&mockKeywordStore{}, &mockAuditRecorder{})
+
+	rec := httptest.NewRecorder()
+	h.Export(rec, httptest.NewRequest(http.MethodGet, "/keywords/export?format=xml", nil))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestKeywordImport_Invalid(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{"malformed json", "application/json", `{"value":`},
+		{"short value", "application/json", `[{"value":"ab"}]`},
+		{"csv without value column", "text/csv", "name\napple\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var store []model.Keyword
+			h := NewKeywordHandler(memoryKeywordStore(&store), &mockAuditRecorder{})
+
+			req := httptest.NewRequest(http.MethodPost, "/keywords/import", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			rec := httptest.NewRecorder()
+			h.Import(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+			if len(store) != 0 {
+				t.Errorf("stored %d keywords, want 0", len(store))
+			}
+		})
+	}
+}
+
+func TestKeywordImport_Multipart(t *testing.T) {
+	var store []model.Keyword
+	h := NewKeywordHandler(memoryKeywordStore(&store), &mockAuditRecorder{})
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("file", "keywords.csv")
+	if err != nil {
+		t.Fatalf("CreateFormFile: %v", err)
+	}
+	part.Write([]byte("value,created_at\npaypal,2026-01-02T03:04:05Z\namazon,2026-01-02T03:04:05Z\n"))
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/keywords/import", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rec := httptest.NewRecorder()
+	h.Import(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if len(store) != 2 {
+		t.Errorf("stored %d keywords, want 2", len(store))
+	}
+}
+
+func TestKeywordCreate_BodyTooLarge(t *testing.T) {
+	h := NewKeywordHandler(&mockKeywordStore{}, &mockAuditRecorder{})
+
+	req := httptest.NewRequest(http.MethodPost, "/keywords", strings.NewReader(`{"value":"`+strings.Repeat("a", 64)+`"}`))
+	rec := httptest.NewRecorder()
+	req.Body = http.MaxBytesReader(rec, req.Body, 16)
+	h.Create(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+}
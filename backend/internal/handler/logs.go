@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// logSource is the part of logging.Ring the handler reads.
+type logSource interface {
+	Last(n int) []json.RawMessage
+	Size() int
+}
+
+type LogsHandler struct {
+	logs logSource
+}
+
+// NewLogsHandler serves the recent log lines kept by logs (the server's
+// logging.Ring).
+func NewLogsHandler(logs logSource) *LogsHandler {
+	return &LogsHandler{logs: logs}
+}
+
+// RegisterAdminRoutes registers the log view; log lines carry client IPs
+// and URLs, so mount it behind the admin allowlist.
+func (h *LogsHandler) RegisterAdminRoutes(r chi.Router) {
+	r.Get("/monitor/logs", h.List)
+}
+
+// List returns the last limit log records (default 100, at most the
+// buffer size), oldest first, as the JSON objects the server logged.
+func (h *LogsHandler) List(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if l, err := strconv.Atoi(v); err == nil && l > 0 {
+			limit = l
+		}
+	}
+	limit = min(limit, h.logs.Size())
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"lines": h.logs.Last(limit),
+		"limit": limit,
+	})
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/logging"
+)
+
+func TestLogsList(t *testing.T) {
+	ring := logging.NewRing(5)
+	for i := range 8 {
+		fmt.Fprintf(ring, `{"msg":"line %d"}`+"\n", i)
+	}
+	h := NewLogsHandler(ring)
+
+	tests := []struct {
+		query     string
+		wantLimit int
+		wantFirst string
+	}{
+		{"", 5, "line 3"},
+		{"?limit=2", 2, "line 6"},
+		{"?limit=500", 5, "line 3"},
+		{"?limit=oops", 5, "line 3"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/monitor/logs"+tt.query, nil)
+		rec := httptest.NewRecorder()
+		h.List(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, want %d", tt.query, rec.Code, http.StatusOK)
+		}
+		var body struct {
+			Lines []struct{ Msg string }
+			Limit int
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("%s: decode: %v", tt.query, err)
+		}
+		if body.Limit != tt.wantLimit || len(body.Lines) != tt.wantLimit {
+			t.Errorf("%s: limit = %d with %d lines, want %d", tt.query, body.Limit, len(body.Lines), tt.wantLimit)
+		}
+		if len(body.Lines) > 0 && (body.Lines[0].Msg != tt.wantFirst || body.Lines[len(body.Lines)-1].Msg != "line 7") {
+			t.Errorf("%s: lines = %+v, want %s through line 7", tt.query, body.Lines, tt.wantFirst)
+		}
+	}
+}
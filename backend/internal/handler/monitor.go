@@ -2,8 +2,12 @@ package handler
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
 
 	"github.com/go-chi/chi/v5"
 
@@ -15,25 +19,55 @@ type monitorService interface {
 	Start(ctx context.Context) error
 	Stop(ctx context.Context) error
 	IsRunning() bool
+	Pause() error
+	Resume() error
+	IsPaused() bool
+	Reprocess(ctx context.Context, start, end int64) (monitor.CycleStats, error)
+	SwitchLog(ctx context.Context, url string, start *int64) (monitor.LogSwitch, error)
 }
 
+// maxReprocessEntries caps the range one reprocess request may cover; it
+// runs within the request, so larger ranges belong to the backfill
+// command.
+const maxReprocessEntries = 1000
+
 type monitorStateStore interface {
 	Get(ctx context.Context) (*model.MonitorState, error)
+	ResetCycleStats(ctx context.Context) error
 }
 
 type MonitorHandler struct {
-	monitor monitorService
-	repo    monitorStateStore
+	monitor     monitorService
+	repo        monitorStateStore
+	audit       auditRecorder
+	notifyQueue func() model.NotifyQueueStats
+}
+
+func NewMonitorHandler(mon monitorService, repo monitorStateStore, audit auditRecorder) *MonitorHandler {
+	return &MonitorHandler{monitor: mon, repo: repo, audit: audit}
 }
 
-func NewMonitorHandler(mon monitorService, repo monitorStateStore) *MonitorHandler {
-	return &MonitorHandler{monitor: mon, repo: repo}
+// WithNotifyQueue adds the notification queue, as reported by stats, to
+// the status response.
+func (h *MonitorHandler) WithNotifyQueue(stats func() model.NotifyQueueStats) *MonitorHandler {
+	h.notifyQueue = stats
+	return h
 }
 
 func (h *MonitorHandler) RegisterRoutes(r chi.Router) {
 	r.Get("/monitor/status", h.Status)
+}
+
+// RegisterAdminRoutes registers the routes that change the monitor's
+// lifecycle; mount them behind the admin allowlist.
+func (h *MonitorHandler) RegisterAdminRoutes(r chi.Router) {
 	r.Post("/monitor/start", h.Start)
 	r.Post("/monitor/stop", h.Stop)
+	r.Post("/monitor/pause", h.Pause)
+	r.Post("/monitor/resume", h.Resume)
+	r.Post("/monitor/reset-cycle-stats", h.ResetCycleStats)
+	r.Post("/monitor/reprocess", h.Reprocess)
+	r.Post("/monitor/log", h.SwitchLog)
 }
 
 func (h *MonitorHandler) Status(w http.ResponseWriter, r *http.Request) {
@@ -42,6 +76,11 @@ func (h *MonitorHandler) Status(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "failed to get monitor status")
 		return
 	}
+	state.Paused = h.monitor.IsPaused()
+	if h.notifyQueue != nil {
+		stats := h.notifyQueue()
+		state.NotifyQueue = &stats
+	}
 	writeJSON(w, http.StatusOK, state)
 }
 
@@ -54,6 +93,7 @@ func (h *MonitorHandler) Start(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "failed to start monitor")
 		return
 	}
+	h.audit.Record(r.Context(), model.AuditActionStart, model.AuditEntityMonitor, "", nil)
 	writeJSON(w, http.StatusOK, map[string]string{"message": "Monitor started"})
 }
 
@@ -66,5 +106,148 @@ func (h *MonitorHandler) Stop(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "failed to stop monitor")
 		return
 	}
+	h.audit.Record(r.Context(), model.AuditActionStop, model.AuditEntityMonitor, "", nil)
 	writeJSON(w, http.StatusOK, map[string]string{"message": "Monitor stopped"})
 }
+
+func (h *MonitorHandler) Pause(w http.ResponseWriter, r *http.Request) {
+	if err := h.monitor.Pause(); err != nil {
+		if errors.Is(err, monitor.ErrNotRunning) {
+			writeError(w, http.StatusConflict, "monitor is not running")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to pause monitor")
+		return
+	}
+	h.audit.Record(r.Context(), model.AuditActionPause, model.AuditEntityMonitor, "", nil)
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Monitor paused"})
+}
+
+func (h *MonitorHandler) Resume(w http.ResponseWriter, r *http.Request) {
+	if err := h.monitor.Resume(); err != nil {
+		if errors.Is(err, monitor.ErrNotRunning) {
+			writeError(w, http.StatusConflict, "monitor is not running")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to resume monitor")
+		return
+	}
+	h.audit.Record(r.Context(), model.AuditActionResume, model.AuditEntityMonitor, "", nil)
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Monitor resumed"})
+}
+
+// ResetCycleStats zeroes the last-cycle counters shown by Status without
+// moving the monitor's position or totals.
+func (h *MonitorHandler) ResetCycleStats(w http.ResponseWriter, r *http.Request) {
+	if err := h.repo.ResetCycleStats(r.Context()); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to reset cycle stats")
+		return
+	}
+	h.audit.Record(r.Context(), model.AuditActionReset, model.AuditEntityMonitor, "cycle_stats", nil)
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Cycle stats reset"})
+}
+
+// Reprocess re-fetches log entries start..end (inclusive) from the body,
+// matches them and stores new matches, leaving the monitor's position
+// where it is. The range must lie within the current tree and span at most
+// maxReprocessEntries entries.
+func (h *MonitorHandler) Reprocess(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Start *int64 `json:"start"`
+		End   *int64 `json:"end"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	switch {
+	case req.Start == nil || req.End == nil:
+		writeError(w, http.StatusBadRequest, "start and end are required")
+		return
+	case *req.Start < 0 || *req.End < *req.Start:
+		writeError(w, http.StatusBadRequest, "start must not be negative and end must not be before start")
+		return
+	case *req.End-*req.Start+1 > maxReprocessEntries:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("range must span at most %d entries", maxReprocessEntries))
+		return
+	}
+	start, end := *req.Start, *req.End
+
+	stats, err := h.monitor.Reprocess(r.Context(), start, end)
+	switch {
+	case errors.Is(err, monitor.ErrBeyondTree):
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, monitor.ErrReprocessRunning):
+		writeError(w, http.StatusConflict, "a reprocess is already running")
+		return
+	case errors.Is(err, monitor.ErrNoKeywords):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		slog.ErrorContext(r.Context(), "reprocess failed", "error", err, "start", start, "end", end)
+		writeError(w, http.StatusBadGateway, "failed to read the CT log")
+		return
+	}
+
+	rangeID := fmt.Sprintf("%d-%d", start, end)
+	h.audit.Record(r.Context(), model.AuditActionReprocess, model.AuditEntityMonitor, rangeID, nil)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"start":              start,
+		"end":                end,
+		"entries":            stats.Entries,
+		"matches":            stats.Matches,
+		"parse_errors":       stats.ParseErrors,
+		"dropped_matches":    stats.DroppedMatches,
+		"skipped_not_before": stats.SkippedNotBefore,
+		"duration_ms":        stats.Duration.Milliseconds(),
+	})
+}
+
+// SwitchLog moves the monitor's cursor to the CT log at url from the body,
+// at start_index or, without one, at the log's current tree size. It
+// overrides the automatic switch to CT_LOG_SUCCESSOR_URL and, like it, is
+// kept across restarts until CT_LOG_URL changes.
+func (h *MonitorHandler) SwitchLog(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		URL        string `json:"url"`
+		StartIndex *int64 `json:"start_index"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if req.URL == "" {
+		writeError(w, http.StatusBadRequest, "url is required")
+		return
+	}
+
+	sw, err := h.monitor.SwitchLog(r.Context(), req.URL, req.StartIndex)
+	switch {
+	case errors.Is(err, monitor.ErrInvalidLogURL), errors.Is(err, monitor.ErrBeyondTree):
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, monitor.ErrLogNotAllowed):
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	case errors.Is(err, monitor.ErrLogSwitchDisabled):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		slog.ErrorContext(r.Context(), "CT log switch failed", "error", err, "url", req.URL)
+		writeError(w, http.StatusBadGateway, "failed to switch CT log")
+		return
+	}
+
+	h.audit.Record(r.Context(), model.AuditActionSwitchLog, model.AuditEntityMonitor, "ct_log",
+		map[string]model.AuditChange{
+			"ct_log_url":  {Old: sw.From, New: sw.To},
+			"start_index": {New: strconv.FormatInt(sw.StartIndex, 10)},
+			"reason":      {New: sw.Reason},
+		})
+	writeJSON(w, http.StatusOK, map[string]any{
+		"from":        sw.From,
+		"to":          sw.To,
+		"start_index": sw.StartIndex,
+	})
+}
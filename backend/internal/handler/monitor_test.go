@@ -2,13 +2,19 @@ package handler
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/andres10976/SISAP-PoC/backend/internal/fakectlog"
 	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/ctlog"
 	"github.com/andres10976/SISAP-PoC/backend/internal/service/monitor"
 )
 
@@ -16,19 +22,37 @@ type mockMonitorService struct {
 	startFn     func(ctx context.Context) error
 	stopFn      func(ctx context.Context) error
 	isRunningFn func() bool
+	pauseFn     func() error
+	resumeFn    func() error
+	reprocessFn func(ctx context.Context, start, end int64) (monitor.CycleStats, error)
+	switchLogFn func(ctx context.Context, url string, start *int64) (monitor.LogSwitch, error)
+	paused      bool
 }
 
 func (m *mockMonitorService) Start(ctx context.Context) error { return m.startFn(ctx) }
 func (m *mockMonitorService) Stop(ctx context.Context) error  { return m.stopFn(ctx) }
 func (m *mockMonitorService) IsRunning() bool                 { return m.isRunningFn() }
+func (m *mockMonitorService) Pause() error                    { return m.pauseFn() }
+func (m *mockMonitorService) Resume() error                   { return m.resumeFn() }
+func (m *mockMonitorService) IsPaused() bool                  { return m.paused }
+func (m *mockMonitorService) Reprocess(ctx context.Context, start, end int64) (monitor.CycleStats, error) {
+	return m.reprocessFn(ctx, start, end)
+}
+func (m *mockMonitorService) SwitchLog(ctx context.Context, url string, start *int64) (monitor.LogSwitch, error) {
+	return m.switchLogFn(ctx, url, start)
+}
 
 type mockMonitorStateStore struct {
-	getFn func(ctx context.Context) (*model.MonitorState, error)
+	getFn   func(ctx context.Context) (*model.MonitorState, error)
+	resetFn func(ctx context.Context) error
 }
 
 func (m *mockMonitorStateStore) Get(ctx context.Context) (*model.MonitorState, error) {
 	return m.getFn(ctx)
 }
+func (m *mockMonitorStateStore) ResetCycleStats(ctx context.Context) error {
+	return m.resetFn(ctx)
+}
 
 func TestMonitorStatus_Success(t *testing.T) {
 	now := time.Now()
@@ -44,6 +68,7 @@ func TestMonitorStatus_Success(t *testing.T) {
 				}, nil
 			},
 		},
+		&mockAuditRecorder{},
 	)
 
 	req := httptest.NewRequest(http.MethodGet, "/monitor/status", nil)
@@ -55,6 +80,70 @@ func TestMonitorStatus_Success(t *testing.T) {
 	}
 }
 
+func TestMonitorStatus_NotifyQueue(t *testing.T) {
+	store := &mockMonitorStateStore{
+		getFn: func(ctx context.Context) (*model.MonitorState, error) { return &model.MonitorState{}, nil },
+	}
+	status := func(h *MonitorHandler) map[string]json.RawMessage {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		h.Status(rec, httptest.NewRequest(http.MethodGet, "/monitor/status", nil))
+		var body map[string]json.RawMessage
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		return body
+	}
+
+	if body := status(NewMonitorHandler(&mockMonitorService{}, store, &mockAuditRecorder{})); body["notify_queue"] != nil {
+		t.Errorf("notify_queue = %s without a dispatcher, want it omitted", body["notify_queue"])
+	}
+
+	h := NewMonitorHandler(&mockMonitorService{}, store, &mockAuditRecorder{}).
+		WithNotifyQueue(func() model.NotifyQueueStats {
+			return model.NotifyQueueStats{Size: 100, Policy: "digest", Depth: 3, Dropped: 0, Digested: 40}
+		})
+	var queue model.NotifyQueueStats
+	if err := json.Unmarshal(status(h)["notify_queue"], &queue); err != nil {
+		t.Fatal(err)
+	}
+	if queue.Policy != "digest" || queue.Depth != 3 || queue.Digested != 40 {
+		t.Errorf("notify_queue = %+v", queue)
+	}
+}
+
+func TestMonitorStatus_IncludesErrorOccurrences(t *testing.T) {
+	firstSeen := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := NewMonitorHandler(
+		&mockMonitorService{},
+		&mockMonitorStateStore{
+			getFn: func(ctx context.Context) (*model.MonitorState, error) {
+				return &model.MonitorState{
+					LastError:          "failed to get STH: timeout",
+					LastErrorFirstSeen: &firstSeen,
+					LastErrorCount:     400,
+				}, nil
+			},
+		},
+		&mockAuditRecorder{},
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/monitor/status", nil)
+	rec := httptest.NewRecorder()
+	h.Status(rec, req)
+
+	var body model.MonitorState
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if body.LastErrorCount != 400 {
+		t.Errorf("last_error_count = %d, want 400", body.LastErrorCount)
+	}
+	if body.LastErrorFirstSeen == nil || !body.LastErrorFirstSeen.Equal(firstSeen) {
+		t.Errorf("last_error_first_seen = %v, want %v", body.LastErrorFirstSeen, firstSeen)
+	}
+}
+
 func TestMonitorStatus_Error(t *testing.T) {
 	h := NewMonitorHandler(
 		&mockMonitorService{},
@@ -63,6 +152,7 @@ func TestMonitorStatus_Error(t *testing.T) {
 				return nil, errors.New("db error")
 			},
 		},
+		&mockAuditRecorder{},
 	)
 
 	req := httptest.NewRequest(http.MethodGet, "/monitor/status", nil)
@@ -80,6 +170,7 @@ func TestMonitorStart_Success(t *testing.T) {
 			startFn: func(ctx context.Context) error { return nil },
 		},
 		&mockMonitorStateStore{},
+		&mockAuditRecorder{},
 	)
 
 	req := httptest.NewRequest(http.MethodPost, "/monitor/start", nil)
@@ -97,6 +188,7 @@ func TestMonitorStart_AlreadyRunning(t *testing.T) {
 			startFn: func(ctx context.Context) error { return monitor.ErrAlreadyRunning },
 		},
 		&mockMonitorStateStore{},
+		&mockAuditRecorder{},
 	)
 
 	req := httptest.NewRequest(http.MethodPost, "/monitor/start", nil)
@@ -114,6 +206,7 @@ func TestMonitorStart_Error(t *testing.T) {
 			startFn: func(ctx context.Context) error { return errors.New("start failed") },
 		},
 		&mockMonitorStateStore{},
+		&mockAuditRecorder{},
 	)
 
 	req := httptest.NewRequest(http.MethodPost, "/monitor/start", nil)
@@ -131,6 +224,7 @@ func TestMonitorStop_Success(t *testing.T) {
 			stopFn: func(ctx context.Context) error { return nil },
 		},
 		&mockMonitorStateStore{},
+		&mockAuditRecorder{},
 	)
 
 	req := httptest.NewRequest(http.MethodPost, "/monitor/stop", nil)
@@ -148,6 +242,7 @@ func TestMonitorStop_NotRunning(t *testing.T) {
 			stopFn: func(ctx context.Context) error { return monitor.ErrNotRunning },
 		},
 		&mockMonitorStateStore{},
+		&mockAuditRecorder{},
 	)
 
 	req := httptest.NewRequest(http.MethodPost, "/monitor/stop", nil)
@@ -165,6 +260,7 @@ func TestMonitorStop_Error(t *testing.T) {
 			stopFn: func(ctx context.Context) error { return errors.New("stop failed") },
 		},
 		&mockMonitorStateStore{},
+		&mockAuditRecorder{},
 	)
 
 	req := httptest.NewRequest(http.MethodPost, "/monitor/stop", nil)
@@ -175,3 +271,355 @@ func TestMonitorStop_Error(t *testing.T) {
 		t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
 	}
 }
+
+func TestMonitorStatus_IncludesPaused(t *testing.T) {
+	h := NewMonitorHandler(
+		&mockMonitorService{paused: true},
+		&mockMonitorStateStore{
+			getFn: func(ctx context.Context) (*model.MonitorState, error) {
+				return &model.MonitorState{IsRunning: true}, nil
+			},
+		},
+		&mockAuditRecorder{},
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/monitor/status", nil)
+	rec := httptest.NewRecorder()
+	h.Status(rec, req)
+
+	var body model.MonitorState
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if !body.Paused || !body.IsRunning {
+		t.Errorf("paused=%v is_running=%v, want both true", body.Paused, body.IsRunning)
+	}
+}
+
+func TestMonitorPause_Success(t *testing.T) {
+	audit := &mockAuditRecorder{}
+	h := NewMonitorHandler(
+		&mockMonitorService{pauseFn: func() error { return nil }},
+		&mockMonitorStateStore{},
+		audit,
+	)
+
+	req := httptest.NewRequest(http.MethodPost, "/monitor/pause", nil)
+	rec := httptest.NewRecorder()
+	h.Pause(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if len(audit.calls) != 1 || audit.calls[0].action != model.AuditActionPause {
+		t.Errorf("audit calls = %+v, want one pause", audit.calls)
+	}
+}
+
+func TestMonitorPause_NotRunning(t *testing.T) {
+	h := NewMonitorHandler(
+		&mockMonitorService{pauseFn: func() error { return monitor.ErrNotRunning }},
+		&mockMonitorStateStore{},
+		&mockAuditRecorder{},
+	)
+
+	req := httptest.NewRequest(http.MethodPost, "/monitor/pause", nil)
+	rec := httptest.NewRecorder()
+	h.Pause(rec, req)
+
+	if rec.Code != http.StatusConflict {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+}
+
+func TestMonitorResume_Success(t *testing.T) {
+	h := NewMonitorHandler(
+		&mockMonitorService{resumeFn: func() error { return nil }},
+		&mockMonitorStateStore{},
+		&mockAuditRecorder{},
+	)
+
+	req := httptest.NewRequest(http.MethodPost, "/monitor/resume", nil)
+	rec := httptest.NewRecorder()
+	h.Resume(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestMonitorResume_NotRunning(t *testing.T) {
+	h := NewMonitorHandler(
+		&mockMonitorService{resumeFn: func() error { return monitor.ErrNotRunning }},
+		&mockMonitorStateStore{},
+		&mockAuditRecorder{},
+	)
+
+	req := httptest.NewRequest(http.MethodPost, "/monitor/resume", nil)
+	rec := httptest.NewRecorder()
+	h.Resume(rec, req)
+
+	if rec.Code != http.StatusConflict {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+}
+
+func TestMonitorResetCycleStats(t *testing.T) {
+	resets := 0
+	audit := &mockAuditRecorder{}
+	h := NewMonitorHandler(&mockMonitorService{}, &mockMonitorStateStore{
+		resetFn: func(ctx context.Context) error {
+			resets++
+			return nil
+		},
+	}, audit)
+
+	rec := httptest.NewRecorder()
+	h.ResetCycleStats(rec, httptest.NewRequest(http.MethodPost, "/monitor/reset-cycle-stats", nil))
+
+	if rec.Code != http.StatusOK || resets != 1 {
+		t.Fatalf("status = %d after %d resets, want %d after 1", rec.Code, resets, http.StatusOK)
+	}
+	if len(audit.calls) != 1 || audit.calls[0].action != model.AuditActionReset || audit.calls[0].entityID != "cycle_stats" {
+		t.Errorf("audit = %+v, want one reset of cycle_stats", audit.calls)
+	}
+}
+
+func TestMonitorResetCycleStats_Error(t *testing.T) {
+	audit := &mockAuditRecorder{}
+	h := NewMonitorHandler(&mockMonitorService{}, &mockMonitorStateStore{
+		resetFn: func(ctx context.Context) error { return errors.New("db down") },
+	}, audit)
+
+	rec := httptest.NewRecorder()
+	h.ResetCycleStats(rec, httptest.NewRequest(http.MethodPost, "/monitor/reset-cycle-stats", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	if len(audit.calls) != 0 {
+		t.Errorf("audit = %+v, want nothing recorded for a failed reset", audit.calls)
+	}
+}
+
+// recordingCTClient passes calls through to a ctlog client and records the
+// ranges fetched.
+type recordingCTClient struct {
+	*ctlog.Client
+	fetched [][2]int64
+}
+
+func (c *recordingCTClient) GetEntries(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
+	c.fetched = append(c.fetched, [2]int64{start, end})
+	return c.Client.GetEntries(ctx, start, end)
+}
+
+type reprocessKeywords struct{}
+
+func (reprocessKeywords) List(ctx context.Context) ([]model.Keyword, error) {
+	return []model.Keyword{{ID: 1, Value: "paypal"}}, nil
+}
+
+type reprocessCerts struct{ stored []int64 }
+
+func (c *reprocessCerts) Create(ctx context.Context, cert *model.MatchedCertificate) error {
+	c.stored = append(c.stored, cert.CTLogIndex)
+	return nil
+}
+
+// reprocessState fails the test on any write: a reprocess must leave the
+// monitor's position alone.
+type reprocessState struct{ t *testing.T }
+
+func (s reprocessState) Get(ctx context.Context) (*model.MonitorState, error) {
+	return &model.MonitorState{LastProcessedIndex: 5}, nil
+}
+func (s reprocessState) Update(ctx context.Context, state *model.MonitorState) error {
+	s.t.Errorf("Update(%+v) called, want the state left alone", state)
+	return nil
+}
+func (s reprocessState) SetRunning(ctx context.Context, running bool) error {
+	s.t.Errorf("SetRunning(%v) called", running)
+	return nil
+}
+func (s reprocessState) SetError(ctx context.Context, errMsg string) error { return nil }
+func (s reprocessState) SetLogFrozen(ctx context.Context, since *time.Time) error {
+	s.t.Errorf("SetLogFrozen(%v) called", since)
+	return nil
+}
+func (s reprocessState) SwitchLog(ctx context.Context, url, configuredURL string, index, treeSize int64) error {
+	s.t.Errorf("SwitchLog(%s) called", url)
+	return nil
+}
+
+func reprocessRequest(body string) *http.Request {
+	return httptest.NewRequest(http.MethodPost, "/monitor/reprocess", strings.NewReader(body))
+}
+
+func TestMonitorReprocess_FetchesRangeWithoutAdvancing(t *testing.T) {
+	log := fakectlog.New(fakectlog.WithTreeSize(100), fakectlog.WithKeywords("paypal"), fakectlog.WithMatchEvery(7))
+	srv := httptest.NewServer(log)
+	defer srv.Close()
+
+	ct := &recordingCTClient{Client: ctlog.NewClient(srv.URL)}
+	certs := &reprocessCerts{}
+	m := monitor.New(ct, reprocessKeywords{}, certs, reprocessState{t}, 100, time.Hour, false)
+	audit := &mockAuditRecorder{}
+	h := NewMonitorHandler(m, &mockMonitorStateStore{}, audit)
+
+	rec := httptest.NewRecorder()
+	h.Reprocess(rec, reprocessRequest(`{"start":40,"end":59}`))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if len(ct.fetched) != 1 || ct.fetched[0] != [2]int64{40, 59} {
+		t.Errorf("fetched = %v, want [[40 59]]", ct.fetched)
+	}
+	var want []int64
+	for i := int64(40); i <= 59; i++ {
+		if log.Matching(i) {
+			want = append(want, i)
+		}
+	}
+	if !slices.Equal(certs.stored, want) {
+		t.Errorf("stored = %v, want %v", certs.stored, want)
+	}
+	var body struct {
+		Entries int `json:"entries"`
+		Matches int `json:"matches"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Entries != 20 || body.Matches != len(want) {
+		t.Errorf("entries/matches = %d/%d, want 20/%d", body.Entries, body.Matches, len(want))
+	}
+	if len(audit.calls) != 1 || audit.calls[0].action != model.AuditActionReprocess || audit.calls[0].entityID != "40-59" {
+		t.Errorf("audit = %+v, want one reprocess of 40-59", audit.calls)
+	}
+}
+
+func TestMonitorReprocess_BadRequest(t *testing.T) {
+	h := NewMonitorHandler(&mockMonitorService{}, &mockMonitorStateStore{}, &mockAuditRecorder{})
+
+	for _, body := range []string{
+		`{"start":10}`,
+		`{"start":-1,"end":5}`,
+		`{"start":10,"end":9}`,
+		`{"start":0,"end":1000}`,
+		`{"start":`,
+	} {
+		rec := httptest.NewRecorder()
+		h.Reprocess(rec, reprocessRequest(body))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", body, rec.Code)
+		}
+	}
+}
+
+func TestMonitorReprocess_Errors(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("%w: last index is 99", monitor.ErrBeyondTree), http.StatusBadRequest},
+		{monitor.ErrReprocessRunning, http.StatusConflict},
+		{monitor.ErrNoKeywords, http.StatusConflict},
+		{errors.New("get-entries: 503"), http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		audit := &mockAuditRecorder{}
+		h := NewMonitorHandler(&mockMonitorService{
+			reprocessFn: func(ctx context.Context, start, end int64) (monitor.CycleStats, error) {
+				return monitor.CycleStats{}, tt.err
+			},
+		}, &mockMonitorStateStore{}, audit)
+
+		rec := httptest.NewRecorder()
+		h.Reprocess(rec, reprocessRequest(`{"start":100,"end":109}`))
+		if rec.Code != tt.want {
+			t.Errorf("%v: status = %d, want %d", tt.err, rec.Code, tt.want)
+		}
+		if len(audit.calls) != 0 {
+			t.Errorf("%v: audit calls = %d, want 0", tt.err, len(audit.calls))
+		}
+	}
+}
+
+func TestMonitorSwitchLog(t *testing.T) {
+	var gotURL string
+	var gotStart *int64
+	audit := &mockAuditRecorder{}
+	h := NewMonitorHandler(&mockMonitorService{
+		switchLogFn: func(ctx context.Context, url string, start *int64) (monitor.LogSwitch, error) {
+			gotURL, gotStart = url, start
+			return monitor.LogSwitch{From: "https://old.example", To: url, StartIndex: 42, Reason: monitor.LogSwitchManual}, nil
+		},
+	}, &mockMonitorStateStore{}, audit)
+
+	rec := httptest.NewRecorder()
+	h.SwitchLog(rec, httptest.NewRequest(http.MethodPost, "/monitor/log",
+		strings.NewReader(`{"url":"https://new.example"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if gotURL != "https://new.example" || gotStart != nil {
+		t.Errorf("SwitchLog(%q, %v), want the new URL at its tree size", gotURL, gotStart)
+	}
+	var body struct {
+		From       string `json:"from"`
+		To         string `json:"to"`
+		StartIndex int64  `json:"start_index"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.From != "https://old.example" || body.To != "https://new.example" || body.StartIndex != 42 {
+		t.Errorf("body = %+v", body)
+	}
+	if len(audit.calls) != 1 || audit.calls[0].action != model.AuditActionSwitchLog ||
+		audit.calls[0].changes["ct_log_url"].New != "https://new.example" {
+		t.Errorf("audit = %+v, want the switch recorded", audit.calls)
+	}
+
+	rec = httptest.NewRecorder()
+	h.SwitchLog(rec, httptest.NewRequest(http.MethodPost, "/monitor/log",
+		strings.NewReader(`{"url":"https://new.example","start_index":7}`)))
+	if rec.Code != http.StatusOK || gotStart == nil || *gotStart != 7 {
+		t.Errorf("with start_index: status = %d, start = %v; want 200 at 7", rec.Code, gotStart)
+	}
+}
+
+func TestMonitorSwitchLog_Errors(t *testing.T) {
+	tests := []struct {
+		body string
+		err  error
+		want int
+	}{
+		{`{}`, nil, http.StatusBadRequest},
+		{`{"url":`, nil, http.StatusBadRequest},
+		{`{"url":"ftp://x"}`, fmt.Errorf("%w: scheme", monitor.ErrInvalidLogURL), http.StatusBadRequest},
+		{`{"url":"https://x","start_index":9}`, fmt.Errorf("%w: start must be between 0 and 5", monitor.ErrBeyondTree), http.StatusBadRequest},
+		{`{"url":"https://evil.example"}`, monitor.ErrLogNotAllowed, http.StatusForbidden},
+		{`{"url":"https://x"}`, monitor.ErrLogSwitchDisabled, http.StatusConflict},
+		{`{"url":"https://x"}`, errors.New("get-sth: 503"), http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		audit := &mockAuditRecorder{}
+		h := NewMonitorHandler(&mockMonitorService{
+			switchLogFn: func(ctx context.Context, url string, start *int64) (monitor.LogSwitch, error) {
+				return monitor.LogSwitch{}, tt.err
+			},
+		}, &mockMonitorStateStore{}, audit)
+
+		rec := httptest.NewRecorder()
+		h.SwitchLog(rec, httptest.NewRequest(http.MethodPost, "/monitor/log", strings.NewReader(tt.body)))
+		if rec.Code != tt.want {
+			t.Errorf("%s (%v): status = %d, want %d", tt.body, tt.err, rec.Code, tt.want)
+		}
+		if len(audit.calls) != 0 {
+			t.Errorf("%s: audit calls = %d, want 0", tt.body, len(audit.calls))
+		}
+	}
+}
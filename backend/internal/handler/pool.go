@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+type PoolHandler struct {
+	stats func() model.PoolStats
+}
+
+// NewPoolHandler serves the snapshot returned by stats (database.PoolStats
+// in production).
+func NewPoolHandler(stats func() model.PoolStats) *PoolHandler {
+	return &PoolHandler{stats: stats}
+}
+
+func (h *PoolHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/debug/pool", h.Get)
+}
+
+func (h *PoolHandler) Get(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.stats())
+}
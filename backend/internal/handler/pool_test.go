@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+func TestPoolGet(t *testing.T) {
+	h := NewPoolHandler(func() model.PoolStats {
+		return model.PoolStats{
+			AcquiredConns:     3,
+			IdleConns:         1,
+			TotalConns:        4,
+			MaxConns:          4,
+			AcquireCount:      120,
+			EmptyAcquireCount: 7,
+			AcquireDurationMS: 250,
+		}
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/debug/pool", nil)
+	rec := httptest.NewRecorder()
+	h.Get(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var body map[string]int64
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := map[string]int64{
+		"acquired_conns":         3,
+		"idle_conns":             1,
+		"total_conns":            4,
+		"max_conns":              4,
+		"acquire_count":          120,
+		"empty_acquire_count":    7,
+		"canceled_acquire_count": 0,
+		"acquire_duration_ms":    250,
+	}
+	for field, v := range want {
+		got, ok := body[field]
+		if !ok {
+			t.Errorf("missing field %q", field)
+			continue
+		}
+		if got != v {
+			t.Errorf("%s = %d, want %d", field, got, v)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// readyPingTimeout bounds the database ping of one readiness probe.
+const readyPingTimeout = 2 * time.Second
+
+type ReadyHandler struct {
+	ping   func(ctx context.Context) error
+	cycled func() bool
+}
+
+// NewReadyHandler checks the database with ping (pool.Ping in production)
+// and, when cycled is not nil, also waits for it to report true
+// (Monitor.HasCompletedCycle under READYZ_REQUIRE_CYCLE).
+func NewReadyHandler(ping func(ctx context.Context) error, cycled func() bool) *ReadyHandler {
+	return &ReadyHandler{ping: ping, cycled: cycled}
+}
+
+// RegisterRoutes registers the readiness probe. Mount it on the root
+// router, next to /healthz.
+func (h *ReadyHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/readyz", h.Ready)
+}
+
+// Ready answers 200 once the service can take traffic and 503 with the
+// reason until then.
+func (h *ReadyHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyPingTimeout)
+	defer cancel()
+	if err := h.ping(ctx); err != nil {
+		slog.WarnContext(r.Context(), "readiness database ping failed", "error", err)
+		writeError(w, http.StatusServiceUnavailable, "database unreachable")
+		return
+	}
+	if h.cycled != nil && !h.cycled() {
+		writeError(w, http.StatusServiceUnavailable, "monitor has not completed a cycle yet")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+}
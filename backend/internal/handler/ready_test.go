@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReady(t *testing.T) {
+	var pingErr error
+	cycled := false
+	ping := func(ctx context.Context) error { return pingErr }
+
+	tests := []struct {
+		name     string
+		h        *ReadyHandler
+		pingErr  error
+		cycled   bool
+		wantCode int
+		wantBody string
+	}{
+		{"db up", NewReadyHandler(ping, nil), nil, false, http.StatusOK, `"status":"ready"`},
+		{"db down", NewReadyHandler(ping, nil), errors.New("refused"), false, http.StatusServiceUnavailable, "database unreachable"},
+		{"before first cycle", NewReadyHandler(ping, func() bool { return cycled }), nil, false, http.StatusServiceUnavailable, "not completed a cycle"},
+		{"after first cycle", NewReadyHandler(ping, func() bool { return cycled }), nil, true, http.StatusOK, `"status":"ready"`},
+		{"after first cycle, db down", NewReadyHandler(ping, func() bool { return cycled }), errors.New("refused"), true, http.StatusServiceUnavailable, "database unreachable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pingErr, cycled = tt.pingErr, tt.cycled
+			rec := httptest.NewRecorder()
+			tt.h.Ready(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want %s", rec.Body, tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/repository"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/report"
+)
+
+type reportStore interface {
+	Get(ctx context.Context, date string) (*model.DailyReport, error)
+	Latest(ctx context.Context) (*model.DailyReport, error)
+}
+
+type reportGenerator interface {
+	Generate(ctx context.Context, date string) (*model.DailyReport, error)
+	PreviousDay() string
+}
+
+type ReportHandler struct {
+	repo  reportStore
+	gen   reportGenerator
+	audit auditRecorder
+}
+
+func NewReportHandler(repo reportStore, gen reportGenerator, audit auditRecorder) *ReportHandler {
+	return &ReportHandler{repo: repo, gen: gen, audit: audit}
+}
+
+func (h *ReportHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/reports/daily", h.Get)
+}
+
+// RegisterAdminRoutes registers the route that (re)generates a report;
+// mount it behind the admin allowlist.
+func (h *ReportHandler) RegisterAdminRoutes(r chi.Router) {
+	r.Post("/reports/daily", h.Generate)
+}
+
+// Get serves the stored report for ?date=YYYY-MM-DD, or the latest one,
+// as JSON or, with ?format=, as HTML, plain text or a Slack message.
+func (h *ReportHandler) Get(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "", "json", "html", "text", "slack":
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown format %q (want json, html, text or slack)", format))
+		return
+	}
+
+	var (
+		rep *model.DailyReport
+		err error
+	)
+	if date := r.URL.Query().Get("date"); date != "" {
+		if _, perr := time.Parse(report.DateLayout, date); perr != nil {
+			writeError(w, http.StatusBadRequest, "invalid date (want YYYY-MM-DD)")
+			return
+		}
+		rep, err = h.repo.Get(r.Context(), date)
+	} else {
+		rep, err = h.repo.Latest(r.Context())
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "report not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get report")
+		return
+	}
+
+	switch format {
+	case "html":
+		body, err := report.HTML(rep)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to render report")
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(body)
+	case "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(report.Text(rep)))
+	case "slack":
+		body, err := report.Slack(rep)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to render report")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	default:
+		writeJSON(w, http.StatusOK, rep)
+	}
+}
+
+// Generate builds and stores the report for ?date=YYYY-MM-DD, by default
+// the previous day, replacing any stored one. It does not send the report.
+func (h *ReportHandler) Generate(w http.ResponseWriter, r *http.Request) {
+	date := r.URL.Query().Get("date")
+	if date == "" {
+		date = h.gen.PreviousDay()
+	} else if _, err := time.Parse(report.DateLayout, date); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid date (want YYYY-MM-DD)")
+		return
+	}
+
+	rep, err := h.gen.Generate(r.Context(), date)
+	if err != nil {
+		writeQueryError(w, err, "failed to generate report")
+		return
+	}
+	h.audit.Record(r.Context(), model.AuditActionCreate, model.AuditEntityReport, date, nil)
+	writeJSON(w, http.StatusOK, rep)
+}
@@ -0,0 +1,247 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/repository"
+)
+
+type mockReportStore struct {
+	getFn    func(ctx context.Context, date string) (*model.DailyReport, error)
+	latestFn func(ctx context.Context) (*model.DailyReport, error)
+}
+
+func (m *mockReportStore) Get(ctx context.Context, date string) (*model.DailyReport, error) {
+	return m.getFn(ctx, date)
+}
+func (m *mockReportStore) Latest(ctx context.Context) (*model.DailyReport, error) {
+	return m.latestFn(ctx)
+}
+
+type mockReportGenerator struct {
+	generateFn  func(ctx context.Context, date string) (*model.DailyReport, error)
+	previousDay string
+}
+
+func (m *mockReportGenerator) Generate(ctx context.Context, date string) (*model.DailyReport, error) {
+	return m.generateFn(ctx, date)
+}
+func (m *mockReportGenerator) PreviousDay() string { return m.previousDay }
+
+func testReport(date string) *model.DailyReport {
+	from, _ := time.Parse(time.DateOnly, date)
+	return &model.DailyReport{
+		Date:     date,
+		Timezone: "UTC",
+		From:     from,
+		To:       from.AddDate(0, 0, 1),
+		MatchSummary: model.MatchSummary{
+			NewMatches: 2,
+			ByKeyword:  []model.StatsBucket{{Key: "paypal", Count: 2}},
+			TopDomains: []model.DomainCount{{Domain: "paypal-login.com", Count: 2}},
+			HighRisk:   []model.ReportMatch{},
+		},
+	}
+}
+
+func TestReportGet_ByDate(t *testing.T) {
+	var gotDate string
+	h := NewReportHandler(&mockReportStore{
+		getFn: func(ctx context.Context, date string) (*model.DailyReport, error) {
+			gotDate = date
+			return testReport(date), nil
+		},
+	}, &mockReportGenerator{}, &mockAuditRecorder{})
+
+	rec := httptest.NewRecorder()
+	h.Get(rec, httptest.NewRequest(http.MethodGet, "/api/v1/reports/daily?date=2026-03-09", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if gotDate != "2026-03-09" {
+		t.Errorf("date = %q, want 2026-03-09", gotDate)
+	}
+	var got model.DailyReport
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Date != "2026-03-09" || got.NewMatches != 2 {
+		t.Errorf("report = %+v", got)
+	}
+}
+
+func TestReportGet_Latest(t *testing.T) {
+	h := NewReportHandler(&mockReportStore{
+		latestFn: func(ctx context.Context) (*model.DailyReport, error) {
+			return testReport("2026-03-10"), nil
+		},
+	}, &mockReportGenerator{}, &mockAuditRecorder{})
+
+	rec := httptest.NewRecorder()
+	h.Get(rec, httptest.NewRequest(http.MethodGet, "/api/v1/reports/daily", nil))
+
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"date":"2026-03-10"`) {
+		t.Errorf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+}
+
+func TestReportGet_Formats(t *testing.T) {
+	h := NewReportHandler(&mockReportStore{
+		getFn: func(ctx context.Context, date string) (*model.DailyReport, error) {
+			return testReport(date), nil
+		},
+	}, &mockReportGenerator{}, &mockAuditRecorder{})
+
+	tests := []struct {
+		format      string
+		contentType string
+		want        string
+	}{
+		{"html", "text/html; charset=utf-8", "<h1>CT monitor daily report: 2026-03-09</h1>"},
+		{"text", "text/plain; charset=utf-8", "  paypal: 2\n"},
+		{"slack", "application/json", `"blocks":[`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.Get(rec, httptest.NewRequest(http.MethodGet, "/api/v1/reports/daily?date=2026-03-09&format="+tt.format, nil))
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", ct, tt.contentType)
+			}
+			if !strings.Contains(rec.Body.String(), tt.want) {
+				t.Errorf("body missing %q:\n%s", tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestReportGet_BadRequest(t *testing.T) {
+	h := NewReportHandler(&mockReportStore{}, &mockReportGenerator{}, &mockAuditRecorder{})
+
+	for _, target := range []string{
+		"/api/v1/reports/daily?date=yesterday",
+		"/api/v1/reports/daily?date=2026-02-30",
+		"/api/v1/reports/daily?format=pdf",
+	} {
+		rec := httptest.NewRecorder()
+		h.Get(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", target, rec.Code)
+		}
+	}
+}
+
+func TestReportGet_NotFound(t *testing.T) {
+	h := NewReportHandler(&mockReportStore{
+		getFn: func(ctx context.Context, date string) (*model.DailyReport, error) {
+			return nil, repository.ErrNotFound
+		},
+		latestFn: func(ctx context.Context) (*model.DailyReport, error) {
+			return nil, repository.ErrNotFound
+		},
+	}, &mockReportGenerator{}, &mockAuditRecorder{})
+
+	for _, target := range []string{"/api/v1/reports/daily?date=2026-03-09", "/api/v1/reports/daily"} {
+		rec := httptest.NewRecorder()
+		h.Get(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: status = %d, want 404", target, rec.Code)
+		}
+	}
+}
+
+func TestReportGet_StoreError(t *testing.T) {
+	h := NewReportHandler(&mockReportStore{
+		latestFn: func(ctx context.Context) (*model.DailyReport, error) {
+			return nil, errors.New("db down")
+		},
+	}, &mockReportGenerator{}, &mockAuditRecorder{})
+
+	rec := httptest.NewRecorder()
+	h.Get(rec, httptest.NewRequest(http.MethodGet, "/api/v1/reports/daily", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+}
+
+func TestReportGenerate(t *testing.T) {
+	var gotDate string
+	audit := &mockAuditRecorder{}
+	h := NewReportHandler(&mockReportStore{}, &mockReportGenerator{
+		generateFn: func(ctx context.Context, date string) (*model.DailyReport, error) {
+			gotDate = date
+			return testReport(date), nil
+		},
+	}, audit)
+
+	rec := httptest.NewRecorder()
+	h.Generate(rec, httptest.NewRequest(http.MethodPost, "/api/v1/reports/daily?date=2026-03-01", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if gotDate != "2026-03-01" {
+		t.Errorf("date = %q, want 2026-03-01", gotDate)
+	}
+	if len(audit.calls) != 1 {
+		t.Fatalf("audit calls = %d, want 1", len(audit.calls))
+	}
+	if c := audit.calls[0]; c.action != model.AuditActionCreate || c.entityType != model.AuditEntityReport || c.entityID != "2026-03-01" {
+		t.Errorf("audit = %+v", c)
+	}
+}
+
+func TestReportGenerate_DefaultsToPreviousDay(t *testing.T) {
+	var gotDate string
+	h := NewReportHandler(&mockReportStore{}, &mockReportGenerator{
+		previousDay: "2026-03-09",
+		generateFn: func(ctx context.Context, date string) (*model.DailyReport, error) {
+			gotDate = date
+			return testReport(date), nil
+		},
+	}, &mockAuditRecorder{})
+
+	rec := httptest.NewRecorder()
+	h.Generate(rec, httptest.NewRequest(http.MethodPost, "/api/v1/reports/daily", nil))
+
+	if rec.Code != http.StatusOK || gotDate != "2026-03-09" {
+		t.Errorf("status = %d, date = %q, want 200 and 2026-03-09", rec.Code, gotDate)
+	}
+}
+
+func TestReportGenerate_Errors(t *testing.T) {
+	audit := &mockAuditRecorder{}
+	h := NewReportHandler(&mockReportStore{}, &mockReportGenerator{
+		generateFn: func(ctx context.Context, date string) (*model.DailyReport, error) {
+			return nil, errors.New("db down")
+		},
+	}, audit)
+
+	rec := httptest.NewRecorder()
+	h.Generate(rec, httptest.NewRequest(http.MethodPost, "/api/v1/reports/daily?date=03/09/2026", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid date: status = %d, want 400", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.Generate(rec, httptest.NewRequest(http.MethodPost, "/api/v1/reports/daily?date=2026-03-09", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("generate failure: status = %d, want 500", rec.Code)
+	}
+	if len(audit.calls) != 0 {
+		t.Errorf("audit calls = %d, want 0", len(audit.calls))
+	}
+}
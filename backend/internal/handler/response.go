@@ -1,22 +1,118 @@
 package handler
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"log/slog"
 	"net/http"
 	"strings"
 
 	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/database"
+	"github.com/andres10976/SISAP-PoC/backend/internal/logging"
 )
 
+// writeJSON encodes data before sending anything, so an encoding failure
+// becomes a logged 500 rather than a truncated body. On a streamWriter whose
+// status was already sent it only logs: a second status line cannot be sent
+// and a JSON body would corrupt the stream.
 func writeJSON(w http.ResponseWriter, status int, data any) {
+	if sw, ok := w.(*streamWriter); ok && sw.committed {
+		slog.Error("response already started, dropping JSON body",
+			"status", status, "request_id", w.Header().Get(logging.RequestIDHeader))
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(data); err != nil {
+		slog.Error("encode JSON response", "error", err,
+			"status", status, "request_id", w.Header().Get(logging.RequestIDHeader))
+		status = http.StatusInternalServerError
+		buf.Reset()
+		buf.WriteString(`{"error":"internal server error"}` + "\n")
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(data)
+	w.Write(buf.Bytes())
 }
 
+// writeError sends {"error": message}. When the RequestID middleware has set
+// the response's request ID header, the ID is included as "request_id" so
+// users can quote it when reporting a failure.
 func writeError(w http.ResponseWriter, status int, message string) {
-	writeJSON(w, status, map[string]string{"error": message})
+	body := map[string]string{"error": message}
+	if id := w.Header().Get(logging.RequestIDHeader); id != "" {
+		body["request_id"] = id
+	}
+	writeJSON(w, status, body)
+}
+
+// writeQueryError reports a failed repository call: 503 when the database
+// canceled the query at DB_QUERY_TIMEOUT, so clients can tell an overloaded
+// search from a bug, and a 500 with message otherwise.
+func writeQueryError(w http.ResponseWriter, err error, message string) {
+	if database.IsQueryTimeout(err) {
+		slog.Warn("query exceeded DB_QUERY_TIMEOUT", "error", err,
+			"request_id", w.Header().Get(logging.RequestIDHeader))
+		writeError(w, http.StatusServiceUnavailable, "query timed out")
+		return
+	}
+	writeError(w, http.StatusInternalServerError, message)
+}
+
+// writeBodyError reports a failure to read or decode the request body: 413
+// when the body exceeded the limit set by middleware.BodyLimit, 400
+// otherwise.
+func writeBodyError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, http.StatusRequestEntityTooLarge, "request body too large")
+		return
+	}
+	writeError(w, http.StatusBadRequest, "invalid request body")
+}
+
+// streamWriter records whether the response status has been sent, for
+// handlers that write their body incrementally. writeJSON and writeError
+// become no-ops on it once streaming has begun; use abortStream instead.
+type streamWriter struct {
+	http.ResponseWriter
+	committed bool
+}
+
+func newStreamWriter(w http.ResponseWriter) *streamWriter {
+	return &streamWriter{ResponseWriter: w}
+}
+
+func (w *streamWriter) WriteHeader(status int) {
+	if w.committed {
+		return
+	}
+	w.committed = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *streamWriter) Write(b []byte) (int, error) {
+	w.committed = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *streamWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// abortStream gives up on a response whose status has already been sent. It
+// logs err and closes the connection without finishing the body, so the
+// client sees a truncated transfer instead of a complete-looking file. It
+// does not return.
+func abortStream(ctx context.Context, msg string, err error) {
+	slog.ErrorContext(ctx, msg, "error", err)
+	panic(http.ErrAbortHandler)
 }
 
 func isDuplicateKeyError(err error) bool {
@@ -6,8 +6,13 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/logging"
+	"github.com/andres10976/SISAP-PoC/backend/internal/middleware"
 )
 
 func TestWriteJSON(t *testing.T) {
@@ -47,6 +52,42 @@ func TestWriteError(t *testing.T) {
 	}
 }
 
+func TestWriteError_IncludesRequestID(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rec.Header().Set(logging.RequestIDHeader, "req-42")
+	writeError(rec, http.StatusNotFound, "not found")
+
+	var body map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if body["request_id"] != "req-42" {
+		t.Errorf("body[request_id] = %q, want %q", body["request_id"], "req-42")
+	}
+}
+
+func TestWriteError_RequestIDThroughRouter(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Route("/api/v1", func(r chi.Router) {
+		r.Use(middleware.Timeout(time.Second))
+		r.Get("/bad", func(w http.ResponseWriter, r *http.Request) {
+			writeError(w, http.StatusBadRequest, "bad")
+		})
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/bad", nil))
+
+	var body map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if id := rec.Header().Get(logging.RequestIDHeader); id == "" || body["request_id"] != id {
+		t.Errorf("body[request_id] = %q, header = %q; want equal and non-empty", body["request_id"], id)
+	}
+}
+
 func TestIsDuplicateKeyError_StringMatch(t *testing.T) {
 	err := errors.New("duplicate key value violates unique constraint")
 	if !isDuplicateKeyError(err) {
@@ -74,3 +115,35 @@ func TestIsDuplicateKeyError_PgErrorOtherCode(t *testing.T) {
 		t.Error("expected false for PgError code 23503")
 	}
 }
+
+func TestWriteJSON_EncodeFailure(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeJSON(rec, http.StatusOK, map[string]any{"bad": make(chan int)})
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	var body map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if body["error"] == "" {
+		t.Errorf("body = %v, want an error message", body)
+	}
+}
+
+func TestWriteError_AfterStreamStarted(t *testing.T) {
+	rec := httptest.NewRecorder()
+	sw := newStreamWriter(rec)
+	sw.WriteHeader(http.StatusOK)
+	sw.Write([]byte("id,name\n"))
+
+	writeError(sw, http.StatusInternalServerError, "too late")
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if got := rec.Body.String(); got != "id,name\n" {
+		t.Errorf("body = %q, want only the streamed data", got)
+	}
+}
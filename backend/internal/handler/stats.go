@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/repository"
+)
+
+type statsStore interface {
+	Stats(ctx context.Context, limits model.StatsLimits) (*model.CertificateStats, error)
+	TopRegistrableDomains(ctx context.Context, limit int) ([]model.DomainCount, error)
+	Version(ctx context.Context, filter repository.CertificateFilter) (string, error)
+}
+
+type StatsHandler struct {
+	repo   statsStore
+	limits model.StatsLimits
+}
+
+// NewStatsHandler serves stats whose issuer and per-day groupings are capped
+// by limits; the caps are echoed in every /stats response.
+func NewStatsHandler(repo statsStore, limits model.StatsLimits) *StatsHandler {
+	return &StatsHandler{repo: repo, limits: limits}
+}
+
+func (h *StatsHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/stats", h.Get)
+	r.Get("/stats/domains", h.Domains)
+}
+
+func (h *StatsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	// The per-day window moves at UTC midnight, so the date is part of the
+	// ETag even when no rows changed.
+	if version, err := h.repo.Version(r.Context(), repository.CertificateFilter{}); err != nil {
+		slog.WarnContext(r.Context(), "stats version failed", "error", err)
+	} else if notModified(w, r, makeETag(version, time.Now().UTC().Format(time.DateOnly),
+		strconv.Itoa(h.limits.TopN), strconv.Itoa(h.limits.Days))) {
+		return
+	}
+
+	stats, err := h.repo.Stats(r.Context(), h.limits)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to compute stats")
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// Domains groups matches by registrable domain, most frequent first.
+func (h *StatsHandler) Domains(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if l, err := strconv.Atoi(v); err == nil && l > 0 && l <= 500 {
+			limit = l
+		}
+	}
+
+	domains, err := h.repo.TopRegistrableDomains(r.Context(), limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to group domains")
+		return
+	}
+	if domains == nil {
+		domains = []model.DomainCount{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"domains": domains,
+		"limit":   limit,
+	})
+}
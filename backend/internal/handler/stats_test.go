@@ -0,0 +1,195 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/repository"
+)
+
+type mockStatsStore struct {
+	statsFn   func(ctx context.Context, limits model.StatsLimits) (*model.CertificateStats, error)
+	domainsFn func(ctx context.Context, limit int) ([]model.DomainCount, error)
+	versionFn func(ctx context.Context, filter repository.CertificateFilter) (string, error)
+}
+
+func (m *mockStatsStore) Stats(ctx context.Context, limits model.StatsLimits) (*model.CertificateStats, error) {
+	return m.statsFn(ctx, limits)
+}
+func (m *mockStatsStore) TopRegistrableDomains(ctx context.Context, limit int) ([]model.DomainCount, error) {
+	return m.domainsFn(ctx, limit)
+}
+func (m *mockStatsStore) Version(ctx context.Context, filter repository.CertificateFilter) (string, error) {
+	if m.versionFn == nil {
+		return "", nil
+	}
+	return m.versionFn(ctx, filter)
+}
+
+var testStatsLimits = model.StatsLimits{TopN: 10, Days: 30}
+
+func TestStats_Success(t *testing.T) {
+	h := NewStatsHandler(&mockStatsStore{
+		statsFn: func(ctx context.Context, limits model.StatsLimits) (*model.CertificateStats, error) {
+			if limits != testStatsLimits {
+				t.Errorf("limits = %+v, want %+v", limits, testStatsLimits)
+			}
+			return &model.CertificateStats{
+				Total:          10,
+				ByMatchedField: []model.StatsBucket{{Key: "san", Count: 8}, {Key: "cn", Count: 2}},
+				ByPrecert:      []model.StatsBucket{{Key: "precert", Count: 6}, {Key: "final", Count: 4}},
+				ByIssuerClass:  []model.StatsBucket{{Key: model.IssuerFreeAutomated, Count: 10}},
+				TopIssuers:     []model.StatsBucket{{Key: "R3", Count: 10}},
+				StatsLimits:    limits,
+			}, nil
+		},
+	}, testStatsLimits)
+
+	req := httptest.NewRequest(http.MethodGet, "/stats", nil)
+	rec := httptest.NewRecorder()
+	h.Get(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var body model.CertificateStats
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if body.Total != 10 || len(body.ByMatchedField) != 2 || len(body.ByPrecert) != 2 ||
+		len(body.ByIssuerClass) != 1 || len(body.TopIssuers) != 1 {
+		t.Errorf("body = %+v", body)
+	}
+	if body.TopN != 10 || body.Days != 30 {
+		t.Errorf("caps = %+v, want top_n 10, days 30", body.StatsLimits)
+	}
+}
+
+func TestStats_Error(t *testing.T) {
+	h := NewStatsHandler(&mockStatsStore{
+		statsFn: func(ctx context.Context, limits model.StatsLimits) (*model.CertificateStats, error) {
+			return nil, errors.New("db error")
+		},
+	}, testStatsLimits)
+
+	req := httptest.NewRequest(http.MethodGet, "/stats", nil)
+	rec := httptest.NewRecorder()
+	h.Get(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+}
+
+func TestStats_ETag(t *testing.T) {
+	version := "5-5-0"
+	h := NewStatsHandler(&mockStatsStore{
+		statsFn: func(ctx context.Context, limits model.StatsLimits) (*model.CertificateStats, error) {
+			return &model.CertificateStats{Total: 5, StatsLimits: limits}, nil
+		},
+		versionFn: func(ctx context.Context, filter repository.CertificateFilter) (string, error) {
+			return version, nil
+		},
+	}, testStatsLimits)
+
+	get := func(etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/stats", nil)
+		req.Header.Set("If-None-Match", etag)
+		rec := httptest.NewRecorder()
+		h.Get(rec, req)
+		return rec
+	}
+
+	etag := get("").Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag on first response")
+	}
+	if rec := get(etag); rec.Code != http.StatusNotModified {
+		t.Errorf("unchanged: status = %d, want %d", rec.Code, http.StatusNotModified)
+	}
+
+	version = "6-6-0"
+	rec := get(etag)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("changed: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if got := rec.Header().Get("ETag"); got == etag {
+		t.Errorf("changed: ETag = %q, want a new value", got)
+	}
+}
+
+func TestStatsDomains_Success(t *testing.T) {
+	var gotLimit int
+	h := NewStatsHandler(&mockStatsStore{
+		domainsFn: func(ctx context.Context, limit int) ([]model.DomainCount, error) {
+			gotLimit = limit
+			return []model.DomainCount{
+				{Domain: "example.com", Count: 5},
+				{Domain: "10.0.0.1", Count: 1, Raw: true},
+			}, nil
+		},
+	}, testStatsLimits)
+
+	req := httptest.NewRequest(http.MethodGet, "/stats/domains?limit=2", nil)
+	rec := httptest.NewRecorder()
+	h.Domains(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if gotLimit != 2 {
+		t.Errorf("limit = %d, want 2", gotLimit)
+	}
+	var body struct {
+		Domains []model.DomainCount `json:"domains"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if len(body.Domains) != 2 || body.Domains[0].Domain != "example.com" || !body.Domains[1].Raw {
+		t.Errorf("domains = %+v", body.Domains)
+	}
+}
+
+func TestStatsDomains_InvalidLimitUsesDefault(t *testing.T) {
+	var gotLimit int
+	h := NewStatsHandler(&mockStatsStore{
+		domainsFn: func(ctx context.Context, limit int) ([]model.DomainCount, error) {
+			gotLimit = limit
+			return nil, nil
+		},
+	}, testStatsLimits)
+
+	req := httptest.NewRequest(http.MethodGet, "/stats/domains?limit=100000", nil)
+	rec := httptest.NewRecorder()
+	h.Domains(rec, req)
+
+	if gotLimit != 50 {
+		t.Errorf("limit = %d, want default 50", gotLimit)
+	}
+	if !strings.Contains(rec.Body.String(), `"domains":[]`) {
+		t.Errorf("body = %s, want empty domains array", rec.Body.String())
+	}
+}
+
+func TestStatsDomains_Error(t *testing.T) {
+	h := NewStatsHandler(&mockStatsStore{
+		domainsFn: func(ctx context.Context, limit int) ([]model.DomainCount, error) {
+			return nil, errors.New("db error")
+		},
+	}, testStatsLimits)
+
+	req := httptest.NewRequest(http.MethodGet, "/stats/domains", nil)
+	rec := httptest.NewRecorder()
+	h.Domains(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+}
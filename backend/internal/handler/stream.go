@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/broadcast"
+)
+
+type matchSubscriber interface {
+	Subscribe() *broadcast.Subscription
+	Unsubscribe(s *broadcast.Subscription)
+}
+
+// StreamHandler pushes new matches to clients as Server-Sent Events.
+type StreamHandler struct {
+	matches   matchSubscriber
+	heartbeat time.Duration
+}
+
+func NewStreamHandler(matches matchSubscriber) *StreamHandler {
+	return &StreamHandler{matches: matches, heartbeat: 15 * time.Second}
+}
+
+func (h *StreamHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/certificates/stream", h.Stream)
+}
+
+// Stream sends each new match as a "match" event until the client goes away
+// or the subscription is dropped for falling behind, in which case a final
+// "dropped" event tells the client to reconnect and catch up via since_id.
+func (h *StreamHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+
+	sub := h.matches.Subscribe()
+	defer h.matches.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case cert, ok := <-sub.C:
+			if !ok {
+				if sub.Dropped() {
+					fmt.Fprint(w, "event: dropped\ndata: {}\n\n")
+					flusher.Flush()
+				}
+				return
+			}
+			data, err := json.Marshal(cert)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "stream encode match", "error", err, "id", cert.ID)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: match\ndata: %s\n\n", cert.ID, data); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
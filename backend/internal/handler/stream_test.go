@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/broadcast"
+)
+
+func TestStream_SendsMatches(t *testing.T) {
+	b := broadcast.NewBroadcaster(4)
+	srv := httptest.NewServer(http.HandlerFunc(NewStreamHandler(b).Stream))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	waitForSubscribers(t, b, 1)
+	b.Publish(model.MatchedCertificate{ID: 9, CommonName: "example.com"})
+
+	reader := bufio.NewReader(resp.Body)
+	var event, data string
+	for event == "" || data == "" {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event: "))
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimSpace(strings.TrimPrefix(line, "data: "))
+		}
+	}
+
+	if event != "match" {
+		t.Errorf("event = %q, want match", event)
+	}
+	var cert model.MatchedCertificate
+	if err := json.Unmarshal([]byte(data), &cert); err != nil {
+		t.Fatalf("decode data: %v", err)
+	}
+	if cert.ID != 9 || cert.CommonName != "example.com" {
+		t.Errorf("cert = %+v, want ID 9 example.com", cert)
+	}
+}
+
+func TestStream_UnsubscribesOnDisconnect(t *testing.T) {
+	b := broadcast.NewBroadcaster(4)
+	h := NewStreamHandler(b)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/certificates/stream", nil).WithContext(ctx)
+	done := make(chan struct{})
+	go func() {
+		h.Stream(httptest.NewRecorder(), req)
+		close(done)
+	}()
+
+	waitForSubscribers(t, b, 1)
+	cancel()
+	<-done
+
+	if n := b.Subscribers(); n != 0 {
+		t.Errorf("Subscribers() = %d after disconnect, want 0", n)
+	}
+}
+
+func waitForSubscribers(t *testing.T, b *broadcast.Broadcaster, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for b.Subscribers() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Subscribers() = %d, want %d", b.Subscribers(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
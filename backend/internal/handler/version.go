@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+type VersionHandler struct {
+	info func() model.BuildInfo
+}
+
+// NewVersionHandler serves the build described by info (version.Info in
+// production).
+func NewVersionHandler(info func() model.BuildInfo) *VersionHandler {
+	return &VersionHandler{info: info}
+}
+
+func (h *VersionHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/version", h.Get)
+}
+
+// RegisterHealthRoutes registers the liveness probe. Mount it on the root
+// router so API-only middleware never applies.
+func (h *VersionHandler) RegisterHealthRoutes(r chi.Router) {
+	r.Get("/healthz", h.Health)
+}
+
+func (h *VersionHandler) Get(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.info())
+}
+
+// Health reports that the process is up, and which build it is.
+func (h *VersionHandler) Health(w http.ResponseWriter, r *http.Request) {
+	info := h.info()
+	writeJSON(w, http.StatusOK, map[string]string{
+		"status":  "ok",
+		"version": info.Version,
+		"commit":  info.Commit,
+	})
+}
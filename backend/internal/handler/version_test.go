@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+func testBuildInfo() model.BuildInfo {
+	return model.BuildInfo{
+		Version:       "v0.0.0-test",
+		Commit:        "abc1234",
+		BuildDate:     "2026-01-02T03:04:05Z",
+		GoVersion:     "go1.25.0",
+		UptimeSeconds: 42,
+	}
+}
+
+func TestVersionGet(t *testing.T) {
+	h := NewVersionHandler(testBuildInfo)
+
+	req := httptest.NewRequest(http.MethodGet, "/version", nil)
+	rec := httptest.NewRecorder()
+	h.Get(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var body map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := map[string]any{
+		"version":        "v0.0.0-test",
+		"commit":         "abc1234",
+		"build_date":     "2026-01-02T03:04:05Z",
+		"go_version":     "go1.25.0",
+		"uptime_seconds": float64(42),
+	}
+	for field, v := range want {
+		if got, ok := body[field]; !ok || got != v {
+			t.Errorf("%s = %v (present %v), want %v", field, got, ok, v)
+		}
+	}
+	if len(body) != len(want) {
+		t.Errorf("got fields %v, want exactly %d", body, len(want))
+	}
+}
+
+func TestHealth(t *testing.T) {
+	h := NewVersionHandler(testBuildInfo)
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	rec := httptest.NewRecorder()
+	h.Health(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var body map[string]string
+	json.NewDecoder(rec.Body).Decode(&body)
+	if body["status"] != "ok" || body["version"] != "v0.0.0-test" || body["commit"] != "abc1234" {
+		t.Errorf("body = %v", body)
+	}
+}
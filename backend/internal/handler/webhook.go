@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/repository"
+)
+
+const (
+	defaultWebhookMaxAttempts = 5
+	maxWebhookMaxAttempts     = 20
+)
+
+type webhookStore interface {
+	List(ctx context.Context) ([]model.Webhook, error)
+	Create(ctx context.Context, url, secret string, maxAttempts int) (*model.Webhook, error)
+	Delete(ctx context.Context, id int) error
+	ListDeliveries(ctx context.Context, webhookID int, status string, limit int) ([]model.WebhookDelivery, error)
+}
+
+type WebhookHandler struct {
+	repo  webhookStore
+	audit auditRecorder
+}
+
+func NewWebhookHandler(repo webhookStore, audit auditRecorder) *WebhookHandler {
+	return &WebhookHandler{repo: repo, audit: audit}
+}
+
+// RegisterAdminRoutes registers every webhook route: webhook URLs often
+// carry a token, and a new webhook receives every match, so mount them
+// behind the admin allowlist.
+func (h *WebhookHandler) RegisterAdminRoutes(r chi.Router) {
+	r.Get("/webhooks", h.List)
+	r.Post("/webhooks", h.Create)
+	r.Delete("/webhooks/{id}", h.Delete)
+	r.Get("/webhooks/{id}/deliveries", h.Deliveries)
+}
+
+func (h *WebhookHandler) List(w http.ResponseWriter, r *http.Request) {
+	hooks, err := h.repo.List(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list webhooks")
+		return
+	}
+	if hooks == nil {
+		hooks = []model.Webhook{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"webhooks": hooks})
+}
+
+// Create adds a webhook. Without a secret one is generated; either way the
+// response is the only place it is returned.
+func (h *WebhookHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		URL         string `json:"url"`
+		Secret      string `json:"secret"`
+		MaxAttempts int    `json:"max_attempts"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	u, err := url.Parse(strings.TrimSpace(req.URL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		writeError(w, http.StatusBadRequest, "url must be an absolute http or https URL")
+		return
+	}
+	if req.Secret != "" && len(req.Secret) < 16 {
+		writeError(w, http.StatusBadRequest, "secret must be at least 16 characters")
+		return
+	}
+	if req.Secret == "" {
+		req.Secret = newWebhookSecret()
+	}
+	if req.MaxAttempts == 0 {
+		req.MaxAttempts = defaultWebhookMaxAttempts
+	}
+	if req.MaxAttempts < 1 || req.MaxAttempts > maxWebhookMaxAttempts {
+		writeError(w, http.StatusBadRequest, "max_attempts must be between 1 and 20")
+		return
+	}
+
+	hook, err := h.repo.Create(r.Context(), u.String(), req.Secret, req.MaxAttempts)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create webhook")
+		return
+	}
+
+	h.audit.Record(r.Context(), model.AuditActionCreate, model.AuditEntityWebhook, strconv.Itoa(hook.ID),
+		map[string]model.AuditChange{"url": {New: redactWebhookURL(hook.URL)}})
+
+	writeJSON(w, http.StatusCreated, struct {
+		*model.Webhook
+		Secret string `json:"secret"`
+	}{hook, hook.Secret})
+}
+
+func (h *WebhookHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid webhook id")
+		return
+	}
+
+	if err := h.repo.Delete(r.Context(), id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "webhook not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to delete webhook")
+		return
+	}
+
+	h.audit.Record(r.Context(), model.AuditActionDelete, model.AuditEntityWebhook, strconv.Itoa(id), nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Deliveries lists a webhook's most recent deliveries; ?status=dead shows
+// the dead-letter queue.
+func (h *WebhookHandler) Deliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid webhook id")
+		return
+	}
+	q := r.URL.Query()
+	status := q.Get("status")
+	if status != "" && !model.ValidWebhookDeliveryStatus(status) {
+		writeError(w, http.StatusBadRequest, "status must be pending, sent or dead")
+		return
+	}
+	limit := 50
+	if v := q.Get("limit"); v != "" {
+		if l, err := strconv.Atoi(v); err == nil && l > 0 && l <= 200 {
+			limit = l
+		}
+	}
+
+	deliveries, err := h.repo.ListDeliveries(r.Context(), id, status, limit)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "webhook not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to list webhook deliveries")
+		return
+	}
+	if deliveries == nil {
+		deliveries = []model.WebhookDelivery{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"deliveries": deliveries})
+}
+
+// newWebhookSecret returns 32 random bytes, hex-encoded.
+func newWebhookSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// redactWebhookURL keeps the scheme and host of a webhook URL for the audit
+// log, dropping any token in its path or query.
+func redactWebhookURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/repository"
+)
+
+// mockWebhookStore implements webhookStore for testing.
+type mockWebhookStore struct {
+	listFn           func(ctx context.Context) ([]model.Webhook, error)
+	createFn         func(ctx context.Context, url, secret string, maxAttempts int) (*model.Webhook, error)
+	deleteFn         func(ctx context.Context, id int) error
+	listDeliveriesFn func(ctx context.Context, webhookID int, status string, limit int) ([]model.WebhookDelivery, error)
+}
+
+func (m *mockWebhookStore) List(ctx context.Context) ([]model.Webhook, error) {
+	return m.listFn(ctx)
+}
+func (m *mockWebhookStore) Create(ctx context.Context, url, secret string, maxAttempts int) (*model.Webhook, error) {
+	return m.createFn(ctx, url, secret, maxAttempts)
+}
+func (m *mockWebhookStore) Delete(ctx context.Context, id int) error {
+	return m.deleteFn(ctx, id)
+}
+func (m *mockWebhookStore) ListDeliveries(ctx context.Context, webhookID int, status string, limit int) ([]model.WebhookDelivery, error) {
+	return m.listDeliveriesFn(ctx, webhookID, status, limit)
+}
+
+func TestWebhookList_OmitsSecret(t *testing.T) {
+	h := NewWebhookHandler(&mockWebhookStore{
+		listFn: func(ctx context.Context) ([]model.Webhook, error) {
+			return []model.Webhook{{ID: 1, URL: "https://hooks.example.com/in", Secret: "s3cret", Dead: 2}}, nil
+		},
+	}, &mockAuditRecorder{})
+
+	rec := httptest.NewRecorder()
+	h.List(rec, httptest.NewRequest(http.MethodGet, "/webhooks", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	body := rec.Body.String()
+	if strings.Contains(body, "s3cret") || !strings.Contains(body, `"dead":2`) {
+		t.Errorf("body = %s, want dead counts and no secret", body)
+	}
+}
+
+func TestWebhookCreate(t *testing.T) {
+	var gotSecret string
+	var gotAttempts int
+	audit := &mockAuditRecorder{}
+	h := NewWebhookHandler(&mockWebhookStore{
+		createFn: func(ctx context.Context, url, secret string, maxAttempts int) (*model.Webhook, error) {
+			gotSecret, gotAttempts = secret, maxAttempts
+			return &model.Webhook{ID: 3, URL: url, Secret: secret, MaxAttempts: maxAttempts, Active: true}, nil
+		},
+	}, audit)
+
+	req := httptest.NewRequest(http.MethodPost, "/webhooks",
+		strings.NewReader(`{"url":"https://hooks.example.com/in?token=abc"}`))
+	rec := httptest.NewRecorder()
+	h.Create(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	if len(gotSecret) != 64 || gotAttempts != defaultWebhookMaxAttempts {
+		t.Errorf("stored secret %q with max_attempts %d, want a generated secret and %d", gotSecret, gotAttempts, defaultWebhookMaxAttempts)
+	}
+	var resp struct {
+		ID     int    `json:"id"`
+		Secret string `json:"secret"`
+	}
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if resp.ID != 3 || resp.Secret != gotSecret {
+		t.Errorf("response = %+v, want id 3 and the generated secret", resp)
+	}
+
+	if len(audit.calls) != 1 || audit.calls[0].entityType != model.AuditEntityWebhook ||
+		audit.calls[0].changes["url"].New != "https://hooks.example.com" {
+		t.Errorf("audit = %+v, want a create with the redacted URL", audit.calls)
+	}
+}
+
+func TestWebhookCreate_Validation(t *testing.T) {
+	h := NewWebhookHandler(&mockWebhookStore{}, &mockAuditRecorder{})
+	for _, body := range []string{
+		`{"url":""}`,
+		`{"url":"ftp://hooks.example.com"}`,
+		`{"url":"/relative"}`,
+		`{"url":"https://hooks.example.com","secret":"short"}`,
+		`{"url":"https://hooks.example.com","max_attempts":21}`,
+		`{"url":"https://hooks.example.com","max_attempts":-1}`,
+		`not json`,
+	} {
+		rec := httptest.NewRecorder()
+		h.Create(rec, httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", body, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestWebhookDelete(t *testing.T) {
+	h := NewWebhookHandler(&mockWebhookStore{
+		deleteFn: func(ctx context.Context, id int) error {
+			if id != 5 {
+				return repository.ErrNotFound
+			}
+			return nil
+		},
+	}, &mockAuditRecorder{})
+
+	for _, tc := range []struct {
+		id   string
+		want int
+	}{{"5", http.StatusNoContent}, {"6", http.StatusNotFound}, {"abc", http.StatusBadRequest}} {
+		rec := httptest.NewRecorder()
+		h.Delete(rec, chiRequest(http.MethodDelete, "/webhooks/"+tc.id, map[string]string{"id": tc.id}))
+		if rec.Code != tc.want {
+			t.Errorf("DELETE %s: status = %d, want %d", tc.id, rec.Code, tc.want)
+		}
+	}
+}
+
+func TestWebhookDeliveries_DeadLetters(t *testing.T) {
+	var gotStatus string
+	var gotLimit int
+	h := NewWebhookHandler(&mockWebhookStore{
+		listDeliveriesFn: func(ctx context.Context, webhookID int, status string, limit int) ([]model.WebhookDelivery, error) {
+			if webhookID != 1 {
+				return nil, repository.ErrNotFound
+			}
+			gotStatus, gotLimit = status, limit
+			return []model.WebhookDelivery{{ID: 9, EventID: "match-4", Status: model.WebhookDeliveryDead, Attempts: 5,
+				LastError: "webhook returned status 503", Secret: "s3cret"}}, nil
+		},
+	}, &mockAuditRecorder{})
+
+	rec := httptest.NewRecorder()
+	h.Deliveries(rec, chiRequest(http.MethodGet, "/webhooks/1/deliveries?status=dead&limit=10", map[string]string{"id": "1"}))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if gotStatus != model.WebhookDeliveryDead || gotLimit != 10 {
+		t.Errorf("listed status %q limit %d, want dead and 10", gotStatus, gotLimit)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, `"last_error":"webhook returned status 503"`) || strings.Contains(body, "s3cret") {
+		t.Errorf("body = %s", body)
+	}
+
+	for _, tc := range []struct {
+		target string
+		id     string
+		want   int
+	}{
+		{"/webhooks/1/deliveries?status=failed", "1", http.StatusBadRequest},
+		{"/webhooks/x/deliveries", "x", http.StatusBadRequest},
+		{"/webhooks/2/deliveries", "2", http.StatusNotFound},
+	} {
+		rec := httptest.NewRecorder()
+		h.Deliveries(rec, chiRequest(http.MethodGet, tc.target, map[string]string{"id": tc.id}))
+		if rec.Code != tc.want {
+			t.Errorf("%s: status = %d, want %d", tc.target, rec.Code, tc.want)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/net/websocket"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+// maxWSMessageBytes caps a message from a WebSocket client; a larger one
+// closes the connection.
+const maxWSMessageBytes = 4 << 10
+
+// wsFilter is a WebSocket client's subscription. Zero values mean "any".
+type wsFilter struct {
+	KeywordIDs   []int `json:"keyword_ids,omitempty"`
+	MinRiskScore int   `json:"min_risk_score,omitempty"`
+}
+
+func (f wsFilter) matches(cert model.MatchedCertificate) bool {
+	if len(f.KeywordIDs) > 0 && !slices.Contains(f.KeywordIDs, cert.KeywordID) {
+		return false
+	}
+	return cert.RiskScore >= f.MinRiskScore
+}
+
+// wsMessage is every frame of the feed, in both directions; Type says
+// which fields are set.
+type wsMessage struct {
+	Type   string                    `json:"type"`
+	Filter *wsFilter                 `json:"filter,omitempty"`
+	Match  *model.MatchedCertificate `json:"match,omitempty"`
+	Error  string                    `json:"error,omitempty"`
+}
+
+// WSHandler pushes new matches to WebSocket clients, each receiving only
+// those its latest subscription message asks for. It shares the broadcast
+// hub with StreamHandler: the hub buffers each connection's matches and
+// drops a client that falls behind.
+type WSHandler struct {
+	matches  matchSubscriber
+	maxConns int64
+	conns    atomic.Int64
+
+	// pingInterval is how often the server pings; a client that sends
+	// nothing, not even the pong, for idleTimeout is disconnected, as is
+	// one that cannot take a frame within writeTimeout.
+	pingInterval time.Duration
+	idleTimeout  time.Duration
+	writeTimeout time.Duration
+}
+
+// NewWSHandler returns a WSHandler serving at most maxConns connections at
+// once.
+func NewWSHandler(matches matchSubscriber, maxConns int) *WSHandler {
+	return &WSHandler{
+		matches:      matches,
+		maxConns:     int64(maxConns),
+		pingInterval: 30 * time.Second,
+		idleTimeout:  75 * time.Second,
+		writeTimeout: 10 * time.Second,
+	}
+}
+
+func (h *WSHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/ws", h.Serve)
+}
+
+// Serve upgrades the request to a WebSocket, or answers 503 when
+// maxConns clients are already connected. Clients send
+// {"type":"subscribe","filter":{...}} to start, or replace, their filter
+// and receive {"type":"match","match":{...}} for each new match that
+// passes it; a client the hub drops gets {"type":"dropped"} before the
+// connection closes, and should resume from since_id.
+func (h *WSHandler) Serve(w http.ResponseWriter, r *http.Request) {
+	if h.conns.Add(1) > h.maxConns {
+		h.conns.Add(-1)
+		writeError(w, http.StatusServiceUnavailable, "too many websocket connections")
+		return
+	}
+	defer h.conns.Add(-1)
+
+	// Any origin may connect, as with the event stream.
+	srv := websocket.Server{Handler: func(conn *websocket.Conn) { h.serveConn(r.Context(), conn) }}
+	srv.ServeHTTP(&wsHijacker{ResponseWriter: w, idle: h.idleTimeout}, r)
+}
+
+func (h *WSHandler) serveConn(ctx context.Context, conn *websocket.Conn) {
+	defer conn.Close()
+	conn.MaxPayloadBytes = maxWSMessageBytes
+
+	sub := h.matches.Subscribe()
+	defer h.matches.Unsubscribe(sub)
+
+	// The reader passes on subscriptions; every write happens below.
+	filters := make(chan wsMessage)
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		for {
+			var data []byte
+			if err := websocket.Message.Receive(conn, &data); err != nil {
+				return
+			}
+			var msg wsMessage
+			if err := json.Unmarshal(data, &msg); err != nil {
+				msg = wsMessage{}
+			}
+			select {
+			case filters <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	send := func(msg wsMessage) bool {
+		conn.SetWriteDeadline(time.Now().Add(h.writeTimeout))
+		return websocket.JSON.Send(conn, msg) == nil
+	}
+
+	ping := time.NewTicker(h.pingInterval)
+	defer ping.Stop()
+
+	var (
+		filter     wsFilter
+		subscribed bool
+	)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-readDone:
+			return
+		case <-ping.C:
+			conn.SetWriteDeadline(time.Now().Add(h.writeTimeout))
+			conn.PayloadType = websocket.PingFrame
+			if _, err := conn.Write(nil); err != nil {
+				return
+			}
+		case msg := <-filters:
+			reply := wsMessage{Type: "subscribed"}
+			switch {
+			case msg.Type != "subscribe":
+				reply = wsMessage{Type: "error", Error: `expected {"type":"subscribe","filter":{...}}`}
+			case msg.Filter != nil && (msg.Filter.MinRiskScore < 0 || msg.Filter.MinRiskScore > 100):
+				reply = wsMessage{Type: "error", Error: "min_risk_score must be between 0 and 100"}
+			default:
+				filter, subscribed = wsFilter{}, true
+				if msg.Filter != nil {
+					filter = *msg.Filter
+				}
+				reply.Filter = &filter
+			}
+			if !send(reply) {
+				return
+			}
+		case cert, ok := <-sub.C:
+			if !ok {
+				if sub.Dropped() {
+					send(wsMessage{Type: "dropped"})
+				}
+				return
+			}
+			if !subscribed || !filter.matches(cert) {
+				continue
+			}
+			if !send(wsMessage{Type: "match", Match: &cert}) {
+				slog.InfoContext(ctx, "websocket client too slow, disconnecting")
+				return
+			}
+		}
+	}
+}
+
+// wsHijacker hands websocket.Server a connection whose reads time out
+// after idle without data. The server pings more often than that and a
+// client answers with a pong, so only a dead client hits the deadline.
+type wsHijacker struct {
+	http.ResponseWriter
+	idle time.Duration
+}
+
+func (w *wsHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	c := &idleConn{Conn: conn, idle: w.idle}
+	var r io.Reader = c
+	if n := brw.Reader.Buffered(); n > 0 {
+		r = io.MultiReader(io.LimitReader(brw.Reader, int64(n)), c)
+	}
+	return c, bufio.NewReadWriter(bufio.NewReader(r), brw.Writer), nil
+}
+
+// idleConn pushes its read deadline idle into the future before each read.
+type idleConn struct {
+	net.Conn
+	idle time.Duration
+}
+
+func (c *idleConn) Read(p []byte) (int, error) {
+	c.Conn.SetReadDeadline(time.Now().Add(c.idle))
+	return c.Conn.Read(p)
+}
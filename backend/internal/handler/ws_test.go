@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/broadcast"
+)
+
+func dialWS(t *testing.T, srv *httptest.Server) *websocket.Conn {
+	t.Helper()
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), "", srv.URL)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	return ws
+}
+
+func sendWS(t *testing.T, ws *websocket.Conn, msg string) {
+	t.Helper()
+	if err := websocket.Message.Send(ws, msg); err != nil {
+		t.Fatalf("send %s: %v", msg, err)
+	}
+}
+
+func receiveWS(t *testing.T, ws *websocket.Conn) wsMessage {
+	t.Helper()
+	ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg wsMessage
+	if err := websocket.JSON.Receive(ws, &msg); err != nil {
+		t.Fatalf("receive: %v", err)
+	}
+	return msg
+}
+
+func TestWS_FiltersMatches(t *testing.T) {
+	b := broadcast.NewBroadcaster(8)
+	srv := httptest.NewServer(http.HandlerFunc(NewWSHandler(b, 4).Serve))
+	defer srv.Close()
+	ws := dialWS(t, srv)
+
+	sendWS(t, ws, `{"type":"subscribe","filter":{"keyword_ids":[7],"min_risk_score":50}}`)
+	if msg := receiveWS(t, ws); msg.Type != "subscribed" || msg.Filter == nil || msg.Filter.MinRiskScore != 50 {
+		t.Fatalf("reply = %+v, want subscribed with min_risk_score 50", msg)
+	}
+
+	b.Publish(model.MatchedCertificate{ID: 1, KeywordID: 8, RiskScore: 90})
+	b.Publish(model.MatchedCertificate{ID: 2, KeywordID: 7, RiskScore: 20})
+	b.Publish(model.MatchedCertificate{ID: 3, KeywordID: 7, RiskScore: 80, CommonName: "paypal-login.com"})
+	msg := receiveWS(t, ws)
+	if msg.Type != "match" || msg.Match == nil || msg.Match.ID != 3 || msg.Match.CommonName != "paypal-login.com" {
+		t.Fatalf("first event = %+v, want match 3", msg)
+	}
+
+	// A new subscription replaces the filter.
+	sendWS(t, ws, `{"type":"subscribe"}`)
+	if msg := receiveWS(t, ws); msg.Type != "subscribed" {
+		t.Fatalf("resubscribe reply = %+v", msg)
+	}
+	b.Publish(model.MatchedCertificate{ID: 4, KeywordID: 8})
+	if msg := receiveWS(t, ws); msg.Type != "match" || msg.Match.ID != 4 {
+		t.Errorf("after resubscribe = %+v, want match 4", msg)
+	}
+}
+
+func TestWS_RejectsBadSubscriptions(t *testing.T) {
+	b := broadcast.NewBroadcaster(8)
+	srv := httptest.NewServer(http.HandlerFunc(NewWSHandler(b, 4).Serve))
+	defer srv.Close()
+	ws := dialWS(t, srv)
+
+	for _, body := range []string{
+		`not json`,
+		`{"type":"unsubscribe"}`,
+		`{"type":"subscribe","filter":{"min_risk_score":101}}`,
+		`{"type":"subscribe","filter":{"keyword_ids":"7"}}`,
+	} {
+		sendWS(t, ws, body)
+		if msg := receiveWS(t, ws); msg.Type != "error" || msg.Error == "" {
+			t.Errorf("%s: reply = %+v, want an error", body, msg)
+		}
+	}
+
+	// The connection survives bad messages.
+	sendWS(t, ws, `{"type":"subscribe"}`)
+	if msg := receiveWS(t, ws); msg.Type != "subscribed" {
+		t.Errorf("reply = %+v, want subscribed", msg)
+	}
+}
+
+func TestWS_ConnectionCap(t *testing.T) {
+	b := broadcast.NewBroadcaster(8)
+	h := NewWSHandler(b, 1)
+	srv := httptest.NewServer(http.HandlerFunc(h.Serve))
+	defer srv.Close()
+
+	first := dialWS(t, srv)
+	waitForSubscribers(t, b, 1)
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("over the cap: status = %d, want %d", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+
+	first.Close()
+	waitForSubscribers(t, b, 0)
+	deadline := time.Now().Add(2 * time.Second)
+	for h.conns.Load() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("conns = %d after disconnect, want 0", h.conns.Load())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	dialWS(t, srv)
+}
+
+func TestWS_PingKeepsClientAlive(t *testing.T) {
+	b := broadcast.NewBroadcaster(8)
+	h := NewWSHandler(b, 4)
+	h.pingInterval, h.idleTimeout = 10*time.Millisecond, 100*time.Millisecond
+	srv := httptest.NewServer(http.HandlerFunc(h.Serve))
+	defer srv.Close()
+	ws := dialWS(t, srv)
+
+	// The client answers pings while it reads, so it outlives idleTimeout
+	// without sending anything itself.
+	received := make(chan wsMessage, 1)
+	go func() {
+		var msg wsMessage
+		if err := websocket.JSON.Receive(ws, &msg); err == nil {
+			received <- msg
+		}
+		close(received)
+	}()
+	time.Sleep(3 * h.idleTimeout)
+	waitForSubscribers(t, b, 1)
+
+	sendWS(t, ws, `{"type":"subscribe"}`)
+	select {
+	case msg, ok := <-received:
+		if !ok || msg.Type != "subscribed" {
+			t.Errorf("reply = %+v (ok %v), want subscribed", msg, ok)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no reply")
+	}
+}
+
+func TestWS_DisconnectsIdleClient(t *testing.T) {
+	b := broadcast.NewBroadcaster(8)
+	h := NewWSHandler(b, 4)
+	h.pingInterval, h.idleTimeout = time.Hour, 200*time.Millisecond
+	srv := httptest.NewServer(http.HandlerFunc(h.Serve))
+	defer srv.Close()
+
+	dialWS(t, srv)
+	waitForSubscribers(t, b, 1)
+	waitForSubscribers(t, b, 0)
+}
+
+func TestWS_ClosesWithHub(t *testing.T) {
+	b := broadcast.NewBroadcaster(8)
+	srv := httptest.NewServer(http.HandlerFunc(NewWSHandler(b, 4).Serve))
+	defer srv.Close()
+	ws := dialWS(t, srv)
+	waitForSubscribers(t, b, 1)
+
+	b.Close()
+	ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg wsMessage
+	if err := websocket.JSON.Receive(ws, &msg); err == nil {
+		t.Errorf("received %+v after shutdown, want the connection closed", msg)
+	}
+}
@@ -0,0 +1,47 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestHandler_AddsIDsFromContext(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(slog.NewJSONHandler(&buf, nil))).With("component", "test")
+
+	ctx := WithCycleID(WithRequestID(context.Background(), "req-1"), "cycle-1")
+	logger.InfoContext(ctx, "hello")
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if line["request_id"] != "req-1" || line["cycle_id"] != "cycle-1" || line["component"] != "test" {
+		t.Errorf("line = %v, want request_id, cycle_id and component", line)
+	}
+}
+
+func TestHandler_NoIDs(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(slog.NewJSONHandler(&buf, nil)))
+
+	logger.Info("hello")
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if _, ok := line["request_id"]; ok {
+		t.Error("request_id present without one in context")
+	}
+}
+
+func TestNewID(t *testing.T) {
+	a, b := NewID(), NewID()
+	if len(a) != 16 || a == b {
+		t.Errorf("NewID() = %q, %q; want distinct 16-char IDs", a, b)
+	}
+}
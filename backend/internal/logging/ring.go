@@ -0,0 +1,61 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+)
+
+// DefaultRingLines is how many log lines the server keeps for
+// GET /monitor/logs.
+const DefaultRingLines = 1000
+
+// Ring is an io.Writer that keeps the last lines written to it. Put it
+// behind slog's JSON handler (which writes each record in one call) to
+// retain the latest records in memory.
+type Ring struct {
+	mu    sync.Mutex
+	lines [][]byte
+	next  int
+	full  bool
+}
+
+// NewRing returns a Ring holding at most size lines.
+func NewRing(size int) *Ring {
+	return &Ring{lines: make([][]byte, max(size, 1))}
+}
+
+// Write stores p, without its trailing newline, as one line, overwriting
+// the oldest once the ring is full.
+func (r *Ring) Write(p []byte) (int, error) {
+	line := bytes.Clone(bytes.TrimRight(p, "\n"))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lines[r.next] = line
+	r.next = (r.next + 1) % len(r.lines)
+	if r.next == 0 {
+		r.full = true
+	}
+	return len(p), nil
+}
+
+// Size returns how many lines the ring can hold.
+func (r *Ring) Size() int {
+	return len(r.lines)
+}
+
+// Last returns up to n of the most recent lines, oldest first.
+func (r *Ring) Last(n int) []json.RawMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := r.next
+	if r.full {
+		stored = len(r.lines)
+	}
+	n = min(max(n, 0), stored)
+	out := make([]json.RawMessage, n)
+	for i := range n {
+		out[i] = r.lines[(r.next-n+i+len(r.lines))%len(r.lines)]
+	}
+	return out
+}
@@ -0,0 +1,52 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestRing_RetainsRecordsAndForwards(t *testing.T) {
+	var stdout bytes.Buffer
+	ring := NewRing(3)
+	logger := slog.New(NewHandler(slog.NewJSONHandler(io.MultiWriter(&stdout, ring), nil)))
+
+	for _, msg := range []string{"one", "two", "three", "four"} {
+		logger.Info(msg, "n", len(msg))
+	}
+
+	if got := bytes.Count(stdout.Bytes(), []byte("\n")); got != 4 {
+		t.Errorf("stdout has %d lines, want all 4 records", got)
+	}
+	lines := ring.Last(10)
+	if len(lines) != 3 {
+		t.Fatalf("Last(10) returned %d lines, want the 3 the ring holds", len(lines))
+	}
+	for i, want := range []string{"two", "three", "four"} {
+		var rec map[string]any
+		if err := json.Unmarshal(lines[i], &rec); err != nil {
+			t.Fatalf("line %d is not JSON: %v", i, err)
+		}
+		if rec["msg"] != want {
+			t.Errorf("line %d msg = %v, want %s", i, rec["msg"], want)
+		}
+	}
+}
+
+func TestRing_Last(t *testing.T) {
+	ring := NewRing(4)
+	if got := ring.Last(5); len(got) != 0 {
+		t.Errorf("empty ring returned %q", got)
+	}
+	for _, l := range []string{"a", "b", "c"} {
+		ring.Write([]byte(l + "\n"))
+	}
+	if got := ring.Last(2); len(got) != 2 || string(got[0]) != "b" || string(got[1]) != "c" {
+		t.Errorf("Last(2) = %q, want [b c]", got)
+	}
+	if got := ring.Last(-1); len(got) != 0 {
+		t.Errorf("Last(-1) = %q, want none", got)
+	}
+}
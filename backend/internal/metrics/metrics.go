@@ -0,0 +1,232 @@
+// Package metrics exposes Prometheus instrumentation for the HTTP server,
+// the monitor loop, the database pool and the notification queue.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chiMiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/monitor"
+)
+
+const namespace = "sisap"
+
+type Metrics struct {
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+	httpInFlight prometheus.Gauge
+	httpPanics   prometheus.Counter
+
+	cycles        *prometheus.CounterVec
+	entries       prometheus.Counter
+	matches       prometheus.Counter
+	parseErrors   prometheus.Counter
+	dropped       prometheus.Counter
+	tooOld        prometheus.Counter
+	cnLess        prometheus.Counter
+	stateErrors   prometheus.Counter
+	disabledKws   prometheus.Counter
+	backlog       prometheus.Gauge
+	cycleDuration prometheus.Histogram
+}
+
+// New registers all HTTP and monitor metrics with reg. Tests pass a fresh
+// prometheus.NewRegistry() to stay isolated from the global registry.
+func New(reg prometheus.Registerer) *Metrics {
+	f := promauto.With(reg)
+	return &Metrics{
+		httpRequests: f.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "http", Name: "requests_total",
+			Help: "HTTP requests by route pattern, method and status class.",
+		}, []string{"route", "method", "status"}),
+		httpDuration: f.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: "http", Name: "request_duration_seconds",
+			Help:    "HTTP request latency by route pattern and method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		httpInFlight: f.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: "http", Name: "requests_in_flight",
+			Help: "HTTP requests currently being served.",
+		}),
+		httpPanics: f.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "http", Name: "panics_total",
+			Help: "Handler panics recovered by the Recovery middleware.",
+		}),
+
+		cycles: f.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "monitor", Name: "cycles_total",
+			Help: "Monitor processing cycles by result (ok, error).",
+		}, []string{"result"}),
+		entries: f.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "monitor", Name: "entries_processed_total",
+			Help: "CT log entries fetched and matched.",
+		}),
+		matches: f.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "monitor", Name: "matches_total",
+			Help: "Keyword matches stored.",
+		}),
+		parseErrors: f.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "monitor", Name: "parse_errors_total",
+			Help: "CT log entries that could not be parsed.",
+		}),
+		dropped: f.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "monitor", Name: "dropped_matches_total",
+			Help: "Matches discarded by the per-certificate match cap.",
+		}),
+		tooOld: f.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "monitor", Name: "skipped_not_before_total",
+			Help: "Certificates skipped for a NotBefore earlier than MONITOR_MIN_NOT_BEFORE.",
+		}),
+		cnLess: f.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "monitor", Name: "cn_less_matches_total",
+			Help: "Matches from certificates without a CommonName (SAN-only).",
+		}),
+		stateErrors: f.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "monitor", Name: "state_error_failures_total",
+			Help: "Failed attempts to record the monitor's last error; a steady rate means the database is unreachable.",
+		}),
+		disabledKws: f.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "monitor", Name: "keywords_disabled_total",
+			Help: "Keywords disabled for matching more than KEYWORD_MAX_MATCH_PERCENT of the log.",
+		}),
+		backlog: f.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: "monitor", Name: "backlog_entries",
+			Help: "CT log entries not yet processed after the last cycle.",
+		}),
+		cycleDuration: f.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: "monitor", Name: "cycle_duration_seconds",
+			Help:    "Duration of monitor processing cycles.",
+			Buckets: prometheus.ExponentialBuckets(0.05, 2, 10),
+		}),
+	}
+}
+
+// Middleware records request count, latency and in-flight requests. Routes
+// are labeled by their chi pattern (e.g. /api/v1/keywords/{id}) to keep
+// cardinality bounded; unmatched paths share one label.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.httpInFlight.Inc()
+		defer m.httpInFlight.Dec()
+
+		start := time.Now()
+		ww := chiMiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		m.httpRequests.WithLabelValues(route, r.Method, strconv.Itoa(status/100)+"xx").Inc()
+		m.httpDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
+
+// IncPanics counts a recovered handler panic; pass it to
+// middleware.RecoveryWithHook.
+func (m *Metrics) IncPanics() {
+	m.httpPanics.Inc()
+}
+
+// ObserveCycle implements monitor.MetricsHook.
+func (m *Metrics) ObserveCycle(s monitor.CycleStats) {
+	result := "ok"
+	if s.Failed {
+		result = "error"
+	}
+	m.cycles.WithLabelValues(result).Inc()
+	m.cycleDuration.Observe(s.Duration.Seconds())
+	// Counted for failed cycles too: that is when the error is recorded.
+	m.stateErrors.Add(float64(s.StateErrors))
+	if s.Failed {
+		return
+	}
+	m.entries.Add(float64(s.Entries))
+	m.matches.Add(float64(s.Matches))
+	m.parseErrors.Add(float64(s.ParseErrors))
+	m.dropped.Add(float64(s.DroppedMatches))
+	m.tooOld.Add(float64(s.SkippedNotBefore))
+	m.cnLess.Add(float64(s.CNLessMatches))
+	m.disabledKws.Add(float64(s.DisabledKeywords))
+	m.backlog.Set(float64(s.Backlog))
+}
+
+// RegisterPool exposes connection pool statistics, read from stat at
+// scrape time.
+func RegisterPool(reg prometheus.Registerer, stat func() *pgxpool.Stat) {
+	f := promauto.With(reg)
+	gauge := func(name, help string, v func(*pgxpool.Stat) float64) {
+		f.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: "db_pool", Name: name, Help: help,
+		}, func() float64 { return v(stat()) })
+	}
+	counter := func(name, help string, v func(*pgxpool.Stat) float64) {
+		f.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "db_pool", Name: name, Help: help,
+		}, func() float64 { return v(stat()) })
+	}
+
+	gauge("acquired_conns", "Connections currently in use.",
+		func(s *pgxpool.Stat) float64 { return float64(s.AcquiredConns()) })
+	gauge("idle_conns", "Idle connections.",
+		func(s *pgxpool.Stat) float64 { return float64(s.IdleConns()) })
+	gauge("total_conns", "Total open connections.",
+		func(s *pgxpool.Stat) float64 { return float64(s.TotalConns()) })
+	gauge("max_conns", "Maximum pool size.",
+		func(s *pgxpool.Stat) float64 { return float64(s.MaxConns()) })
+	counter("acquires_total", "Successful connection acquires.",
+		func(s *pgxpool.Stat) float64 { return float64(s.AcquireCount()) })
+	counter("empty_acquires_total", "Acquires that had to wait for a connection.",
+		func(s *pgxpool.Stat) float64 { return float64(s.EmptyAcquireCount()) })
+	counter("canceled_acquires_total", "Acquires canceled by their context.",
+		func(s *pgxpool.Stat) float64 { return float64(s.CanceledAcquireCount()) })
+	counter("acquire_duration_seconds_total", "Total time spent acquiring connections.",
+		func(s *pgxpool.Stat) float64 { return s.AcquireDuration().Seconds() })
+}
+
+// RegisterNotifyQueue exposes the notification dispatcher's queue depth,
+// drop count and digest count, read from depth, dropped and digested at
+// scrape time.
+func RegisterNotifyQueue(reg prometheus.Registerer, depth, dropped, digested func() int64) {
+	f := promauto.With(reg)
+	f.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace, Subsystem: "notify", Name: "queue_depth",
+		Help: "Claimed notifications waiting for a delivery worker.",
+	}, func() float64 { return float64(depth()) })
+	f.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "notify", Name: "dropped_total",
+		Help: "Notifications turned away by a full queue under the drop policies.",
+	}, func() float64 { return float64(dropped()) })
+	f.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "notify", Name: "digested_total",
+		Help: "Notifications delivered in a digest by a full queue under the digest policy.",
+	}, func() float64 { return float64(digested()) })
+}
+
+// RegisterEnrichment exposes how many matches the enricher for source
+// skipped because its queue was full, read from dropped at scrape time.
+func RegisterEnrichment(reg prometheus.Registerer, source string, dropped func() int64) {
+	promauto.With(reg).NewCounterFunc(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "enrich", Name: "dropped_total",
+		Help:        "New matches left unenriched because the enrichment queue was full.",
+		ConstLabels: prometheus.Labels{"source": source},
+	}, func() float64 { return float64(dropped()) })
+}
+
+// Handler serves the metrics gathered by g in the Prometheus text format.
+func Handler(g prometheus.Gatherer) http.Handler {
+	return promhttp.HandlerFor(g, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,203 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/monitor"
+)
+
+func TestMiddleware_LabelsByRoutePattern(t *testing.T) {
+	m := New(prometheus.NewRegistry())
+
+	r := chi.NewRouter()
+	r.Use(m.Middleware)
+	r.Get("/keywords/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	for _, path := range []string{"/keywords/1", "/keywords/2", "/nope"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := testutil.ToFloat64(m.httpRequests.WithLabelValues("/keywords/{id}", "GET", "4xx")); got != 2 {
+		t.Errorf("requests{route=/keywords/{id}} = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(m.httpRequests.WithLabelValues("unmatched", "GET", "4xx")); got != 1 {
+		t.Errorf("requests{route=unmatched} = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.httpInFlight); got != 0 {
+		t.Errorf("in_flight = %v, want 0 after requests finish", got)
+	}
+}
+
+func TestObserveCycle(t *testing.T) {
+	m := New(prometheus.NewRegistry())
+
+	m.ObserveCycle(monitor.CycleStats{
+		Duration: 200 * time.Millisecond, Entries: 100, Matches: 3, ParseErrors: 1, DroppedMatches: 2, SkippedNotBefore: 4, CNLessMatches: 2, DisabledKeywords: 1, Backlog: 42,
+	})
+	m.ObserveCycle(monitor.CycleStats{Failed: true, StateErrors: 1})
+
+	if got := testutil.ToFloat64(m.cycles.WithLabelValues("ok")); got != 1 {
+		t.Errorf("cycles{ok} = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.cycles.WithLabelValues("error")); got != 1 {
+		t.Errorf("cycles{error} = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.entries); got != 100 {
+		t.Errorf("entries = %v, want 100", got)
+	}
+	if got := testutil.ToFloat64(m.matches); got != 3 {
+		t.Errorf("matches = %v, want 3", got)
+	}
+	if got := testutil.ToFloat64(m.parseErrors); got != 1 {
+		t.Errorf("parse_errors = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.dropped); got != 2 {
+		t.Errorf("dropped_matches = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(m.tooOld); got != 4 {
+		t.Errorf("skipped_not_before = %v, want 4", got)
+	}
+	if got := testutil.ToFloat64(m.cnLess); got != 2 {
+		t.Errorf("cn_less_matches = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(m.disabledKws); got != 1 {
+		t.Errorf("keywords_disabled = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.stateErrors); got != 1 {
+		t.Errorf("state_error_failures = %v, want 1 (counted for failed cycles)", got)
+	}
+	if got := testutil.ToFloat64(m.backlog); got != 42 {
+		t.Errorf("backlog = %v, want 42 (failed cycle must not reset it)", got)
+	}
+}
+
+func TestHandler_ServesRegistry(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := New(reg)
+	m.ObserveCycle(monitor.CycleStats{})
+
+	rec := httptest.NewRecorder()
+	Handler(reg).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if !strings.Contains(rec.Body.String(), "sisap_monitor_cycles_total") {
+		t.Error("metrics output missing sisap_monitor_cycles_total")
+	}
+}
+
+func TestIncPanics(t *testing.T) {
+	m := New(prometheus.NewRegistry())
+	m.IncPanics()
+	m.IncPanics()
+
+	if got := testutil.ToFloat64(m.httpPanics); got != 2 {
+		t.Errorf("panics = %v, want 2", got)
+	}
+}
+
+func TestRegisterPool(t *testing.T) {
+	// pgxpool connects lazily, so an unreachable address still yields a
+	// pool whose stats can be read.
+	pool, err := pgxpool.New(context.Background(), "postgres://user@127.0.0.1:1/none?pool_max_conns=7")
+	if err != nil {
+		t.Fatalf("pgxpool.New: %v", err)
+	}
+	defer pool.Close()
+
+	reg := prometheus.NewRegistry()
+	RegisterPool(reg, pool.Stat)
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	got := make(map[string]float64)
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			switch {
+			case m.GetGauge() != nil:
+				got[mf.GetName()] = m.GetGauge().GetValue()
+			case m.GetCounter() != nil:
+				got[mf.GetName()] = m.GetCounter().GetValue()
+			}
+		}
+	}
+
+	want := map[string]float64{
+		"sisap_db_pool_acquired_conns":                 0,
+		"sisap_db_pool_idle_conns":                     0,
+		"sisap_db_pool_total_conns":                    0,
+		"sisap_db_pool_max_conns":                      7,
+		"sisap_db_pool_acquires_total":                 0,
+		"sisap_db_pool_empty_acquires_total":           0,
+		"sisap_db_pool_canceled_acquires_total":        0,
+		"sisap_db_pool_acquire_duration_seconds_total": 0,
+	}
+	for name, v := range want {
+		g, ok := got[name]
+		if !ok {
+			t.Errorf("missing metric %s", name)
+			continue
+		}
+		if g != v {
+			t.Errorf("%s = %v, want %v", name, g, v)
+		}
+	}
+}
+
+func TestRegisterNotifyQueue(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	RegisterNotifyQueue(reg, func() int64 { return 3 }, func() int64 { return 12 }, func() int64 { return 40 })
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	got := make(map[string]float64)
+	for _, mf := range families {
+		m := mf.GetMetric()[0]
+		if m.GetGauge() != nil {
+			got[mf.GetName()] = m.GetGauge().GetValue()
+		} else {
+			got[mf.GetName()] = m.GetCounter().GetValue()
+		}
+	}
+	if got["sisap_notify_queue_depth"] != 3 || got["sisap_notify_dropped_total"] != 12 || got["sisap_notify_digested_total"] != 40 {
+		t.Errorf("metrics = %v, want queue_depth 3, dropped_total 12 and digested_total 40", got)
+	}
+}
+
+func TestRegisterEnrichment(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	RegisterEnrichment(reg, "crtsh", func() int64 { return 4 })
+	RegisterEnrichment(reg, "rdap", func() int64 { return 1 })
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	if len(families) != 1 || families[0].GetName() != "sisap_enrich_dropped_total" {
+		t.Fatalf("families = %v, want sisap_enrich_dropped_total", families)
+	}
+	got := map[string]float64{}
+	for _, m := range families[0].GetMetric() {
+		got[m.GetLabel()[0].GetValue()] = m.GetCounter().GetValue()
+	}
+	if got["crtsh"] != 4 || got["rdap"] != 1 {
+		t.Errorf("dropped by source = %v, want crtsh 4 and rdap 1", got)
+	}
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net/http"
+	"net/netip"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/logging"
+)
+
+// AllowCIDRs rejects requests whose client IP is outside the allowed
+// prefixes with 403. The client IP is the one resolved by ClientIP, so a
+// forwarded address only counts when it came through a trusted proxy. An
+// empty allowlist lets every request through.
+func AllowCIDRs(allowed []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(allowed) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := logging.ClientIP(r.Context())
+			if ip == "" {
+				ip = r.RemoteAddr
+			}
+			addr, ok := parseIP(ip)
+			if !ok || !isTrusted(addr, allowed) {
+				writeError(w, http.StatusForbidden, "forbidden")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
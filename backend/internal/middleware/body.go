@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// BodyLimit caps request bodies at maxBytes, or at overrides[path] for the
+// listed paths. A declared Content-Length over the limit is rejected with
+// 413 up front; otherwise reads past the limit fail with *http.MaxBytesError.
+func BodyLimit(maxBytes int64, overrides map[string]int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limit := maxBytes
+			if n, ok := overrides[r.URL.Path]; ok {
+				limit = n
+			}
+			if r.ContentLength > limit {
+				writeError(w, http.StatusRequestEntityTooLarge, "request body too large")
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ContentType rejects POST, PUT and PATCH requests that carry a body whose
+// media type is not in allowed (or overrides[path] for the listed paths)
+// with 415. Bodiless requests such as POST /monitor/start pass through.
+func ContentType(allowed []string, overrides map[string][]string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodPost, http.MethodPut, http.MethodPatch:
+			default:
+				next.ServeHTTP(w, r)
+				return
+			}
+			if r.ContentLength == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			types := allowed
+			if o, ok := overrides[r.URL.Path]; ok {
+				types = o
+			}
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil || !containsFold(types, mediaType) {
+				writeError(w, http.StatusUnsupportedMediaType,
+					"unsupported content type; expected "+strings.Join(types, " or "))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,13 +1,43 @@
 package middleware
 
-import "net/http"
+import (
+	"net/http"
+	"strings"
+)
+
+// CORS allows cross-origin requests from a comma-separated list of origins.
+// Entries are exact origins ("https://app.example"), wildcard subdomains
+// ("https://*.corp.example") or "*" for any origin. The matched request
+// origin is echoed back, never the whole list. With allowCredentials set,
+// "*" is never sent; the request origin is echoed instead, as browsers
+// require.
+func CORS(allowOrigins string, allowCredentials bool) func(http.Handler) http.Handler {
+	var patterns []string
+	for _, o := range strings.Split(allowOrigins, ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			patterns = append(patterns, strings.TrimSuffix(o, "/"))
+		}
+	}
 
-func CORS(allowOrigin string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+			w.Header().Add("Vary", "Origin")
+
+			if origin := r.Header.Get("Origin"); origin != "" {
+				if allowed, anyOrigin := matchOrigin(patterns, origin); allowed {
+					if anyOrigin && !allowCredentials {
+						w.Header().Set("Access-Control-Allow-Origin", "*")
+					} else {
+						w.Header().Set("Access-Control-Allow-Origin", origin)
+					}
+					if allowCredentials {
+						w.Header().Set("Access-Control-Allow-Credentials", "true")
+					}
+					w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+					w.Header().Set("Access-Control-Allow-Headers", "Content-Type, If-None-Match, traceparent, tracestate")
+					w.Header().Set("Access-Control-Expose-Headers", "ETag, X-Total-Count")
+				}
+			}
 
 			if r.Method == http.MethodOptions {
 				w.WriteHeader(http.StatusNoContent)
@@ -18,3 +48,27 @@ func CORS(allowOrigin string) func(http.Handler) http.Handler {
 		})
 	}
 }
+
+// matchOrigin reports whether origin matches one of the patterns, and
+// whether the match came from the "*" entry.
+func matchOrigin(patterns []string, origin string) (allowed, anyOrigin bool) {
+	for _, p := range patterns {
+		switch {
+		case p == "*":
+			return true, true
+		case strings.EqualFold(p, origin):
+			return true, false
+		case strings.Contains(p, "://*."):
+			scheme, suffix, _ := strings.Cut(p, "*")
+			if len(origin) > len(scheme)+len(suffix) &&
+				strings.EqualFold(origin[:len(scheme)], scheme) &&
+				strings.EqualFold(origin[len(origin)-len(suffix):], suffix) {
+				sub := origin[len(scheme) : len(origin)-len(suffix)]
+				if !strings.ContainsAny(sub, "/:@") {
+					return true, false
+				}
+			}
+		}
+	}
+	return false, false
+}
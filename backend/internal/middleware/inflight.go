@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// InFlight counts requests currently being served, so shutdown can report
+// how many were still running when it gave up.
+type InFlight struct {
+	n atomic.Int64
+}
+
+func (f *InFlight) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.n.Add(1)
+		defer f.n.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Count returns the number of requests currently in flight.
+func (f *InFlight) Count() int64 {
+	return f.n.Load()
+}
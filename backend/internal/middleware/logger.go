@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chiMiddleware "github.com/go-chi/chi/v5/middleware"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/logging"
+)
+
+// SlogLogger logs one structured line per request. Successful (< 400)
+// responses are logged at successLevel so they can be demoted to debug;
+// 4xx responses log at warn and 5xx at error. Requests to /healthz, /readyz
+// and the /debug/ endpoints (pprof, runtime stats) are not logged. Place it
+// after RequestID and ClientIP to include request_id and the resolved
+// client address.
+func SlogLogger(logger *slog.Logger, successLevel slog.Level) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/healthz" || r.URL.Path == "/readyz" || strings.HasPrefix(r.URL.Path, "/debug/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			ww := chiMiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			level := successLevel
+			switch {
+			case status >= 500:
+				level = slog.LevelError
+			case status >= 400:
+				level = slog.LevelWarn
+			}
+
+			route := ""
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				route = rctx.RoutePattern()
+			}
+			remoteIP := logging.ClientIP(r.Context())
+			if remoteIP == "" {
+				var err error
+				if remoteIP, _, err = net.SplitHostPort(r.RemoteAddr); err != nil {
+					remoteIP = r.RemoteAddr
+				}
+			}
+
+			logger.LogAttrs(context.Background(), level, "http request",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.String("route", route),
+				slog.Int("status", status),
+				slog.Int("bytes", ww.BytesWritten()),
+				slog.Duration("duration", time.Since(start)),
+				slog.String("remote_ip", remoteIP),
+				slog.String("request_id", logging.RequestID(r.Context())),
+			)
+		})
+	}
+}
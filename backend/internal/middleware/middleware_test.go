@@ -1,19 +1,48 @@
 package middleware
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/handler"
+	"github.com/andres10976/SISAP-PoC/backend/internal/logging"
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
 )
 
-func TestCORS_SetsHeaders(t *testing.T) {
-	handler := CORS("https://example.com")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+func corsRequest(t *testing.T, allow string, credentials bool, method, origin string) (*httptest.ResponseRecorder, bool) {
+	t.Helper()
+	called := false
+	handler := CORS(allow, credentials)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
 		w.WriteHeader(http.StatusOK)
 	}))
 
-	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req := httptest.NewRequest(method, "/", nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
 	rec := httptest.NewRecorder()
 	handler.ServeHTTP(rec, req)
+	return rec, called
+}
+
+func TestCORS_SetsHeaders(t *testing.T) {
+	rec, _ := corsRequest(t, "https://example.com", false, http.MethodGet, "https://example.com")
 
 	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://example.com" {
 		t.Errorf("Allow-Origin = %q, want %q", got, "https://example.com")
@@ -24,20 +53,19 @@ func TestCORS_SetsHeaders(t *testing.T) {
 	if got := rec.Header().Get("Access-Control-Allow-Headers"); got == "" {
 		t.Error("Allow-Headers header not set")
 	}
+	if got := rec.Header().Get("Access-Control-Expose-Headers"); got != "ETag, X-Total-Count" {
+		t.Errorf("Expose-Headers = %q, want ETag, X-Total-Count", got)
+	}
+	if got := rec.Header().Get("Vary"); got != "Origin" {
+		t.Errorf("Vary = %q, want Origin", got)
+	}
 	if rec.Code != http.StatusOK {
 		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
 	}
 }
 
 func TestCORS_OptionsShortCircuit(t *testing.T) {
-	called := false
-	handler := CORS("*")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		called = true
-	}))
-
-	req := httptest.NewRequest(http.MethodOptions, "/", nil)
-	rec := httptest.NewRecorder()
-	handler.ServeHTTP(rec, req)
+	rec, called := corsRequest(t, "*", false, http.MethodOptions, "https://any.example")
 
 	if called {
 		t.Error("next handler should not be called for OPTIONS")
@@ -45,6 +73,118 @@ func TestCORS_OptionsShortCircuit(t *testing.T) {
 	if rec.Code != http.StatusNoContent {
 		t.Errorf("status = %d, want %d", rec.Code, http.StatusNoContent)
 	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Allow-Origin = %q, want *", got)
+	}
+}
+
+func TestCORS_Preflight(t *testing.T) {
+	const allow = "http://localhost:3000, https://monitor.internal, https://*.corp.example"
+	tests := []struct {
+		name   string
+		origin string
+		want   string
+	}{
+		{"first of list", "http://localhost:3000", "http://localhost:3000"},
+		{"second of list", "https://monitor.internal", "https://monitor.internal"},
+		{"wildcard subdomain", "https://app.corp.example", "https://app.corp.example"},
+		{"nested wildcard subdomain", "https://a.b.corp.example", "https://a.b.corp.example"},
+		{"wildcard needs a subdomain", "https://corp.example", ""},
+		{"wildcard scheme mismatch", "http://app.corp.example", ""},
+		{"wildcard suffix lookalike", "https://app.corp.example.evil.com", ""},
+		{"wildcard with port", "https://app.corp.example:8443", ""},
+		{"disallowed", "https://evil.example", ""},
+		{"missing origin", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, called := corsRequest(t, allow, false, http.MethodOptions, tt.origin)
+
+			if called {
+				t.Error("next handler should not be called for OPTIONS")
+			}
+			if rec.Code != http.StatusNoContent {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusNoContent)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.want {
+				t.Errorf("Allow-Origin = %q, want %q", got, tt.want)
+			}
+			if tt.want == "" && rec.Header().Get("Access-Control-Allow-Methods") != "" {
+				t.Error("Allow-Methods set for a disallowed origin")
+			}
+			if got := rec.Header().Get("Vary"); got != "Origin" {
+				t.Errorf("Vary = %q, want Origin", got)
+			}
+		})
+	}
+}
+
+func TestCORS_AllowsEveryAPIMethod(t *testing.T) {
+	r := chi.NewRouter()
+	for _, h := range []interface{ RegisterRoutes(chi.Router) }{
+		handler.NewKeywordHandler(nil, nil),
+		handler.NewKeywordGroupHandler(nil, nil),
+		handler.NewCertificateHandler(nil, nil),
+		handler.NewMonitorHandler(nil, nil, nil),
+		handler.NewAuditHandler(nil),
+		handler.NewStatsHandler(nil, model.StatsLimits{}),
+		handler.NewStreamHandler(nil),
+		handler.NewWSHandler(nil, 1),
+		handler.NewAnalyzeHandler(nil, nil, 1),
+		handler.NewPoolHandler(nil),
+		handler.NewVersionHandler(nil),
+		handler.NewReportHandler(nil, nil, nil),
+	} {
+		h.RegisterRoutes(r)
+	}
+	for _, h := range []interface{ RegisterAdminRoutes(chi.Router) }{
+		handler.NewKeywordHandler(nil, nil),
+		handler.NewKeywordGroupHandler(nil, nil),
+		handler.NewCertificateHandler(nil, nil),
+		handler.NewMonitorHandler(nil, nil, nil),
+		handler.NewLogsHandler(nil),
+		handler.NewConfigHandler(nil),
+		handler.NewCTLogHandler("", nil, time.Second),
+		handler.NewWebhookHandler(nil, nil),
+		handler.NewReportHandler(nil, nil, nil),
+		handler.NewHistoryHandler(nil, nil),
+	} {
+		h.RegisterAdminRoutes(r)
+	}
+
+	rec, _ := corsRequest(t, "https://app.example", false, http.MethodOptions, "https://app.example")
+	allowed := strings.Split(rec.Header().Get("Access-Control-Allow-Methods"), ", ")
+	chi.Walk(r, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if !slices.Contains(allowed, method) {
+			t.Errorf("%s %s: method not in Allow-Methods %v", method, route, allowed)
+		}
+		return nil
+	})
+}
+
+func TestCORS_CredentialsNeverWildcard(t *testing.T) {
+	rec, _ := corsRequest(t, "*", true, http.MethodGet, "https://app.example")
+
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example" {
+		t.Errorf("Allow-Origin = %q, want echoed origin", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Errorf("Allow-Credentials = %q, want true", got)
+	}
+}
+
+func TestCORS_DisallowedPassesThroughWithoutHeaders(t *testing.T) {
+	rec, called := corsRequest(t, "https://example.com", true, http.MethodGet, "https://evil.example")
+
+	if !called {
+		t.Error("next handler should still run; the browser enforces CORS")
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Allow-Origin = %q, want empty", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Allow-Credentials = %q, want empty", got)
+	}
 }
 
 func TestRecovery_NoPanic(t *testing.T) {
@@ -62,11 +202,18 @@ func TestRecovery_NoPanic(t *testing.T) {
 }
 
 func TestRecovery_Panic(t *testing.T) {
-	handler := Recovery(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		panic("test panic")
-	}))
+	var logs bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
 
-	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	panics := 0
+	handler := RequestID(RecoveryWithHook(func() { panics++ })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("secret detail")
+	})))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/keywords", nil)
+	req.Header.Set(logging.RequestIDHeader, "req-7")
 	rec := httptest.NewRecorder()
 	handler.ServeHTTP(rec, req)
 
@@ -76,4 +223,651 @@ func TestRecovery_Panic(t *testing.T) {
 	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
 		t.Errorf("Content-Type = %q, want %q", ct, "application/json")
 	}
+	var body map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if body["error"] != "internal server error" || body["request_id"] != "req-7" {
+		t.Errorf("body = %v, want generic error with request_id", body)
+	}
+	if panics != 1 {
+		t.Errorf("onPanic called %d times, want 1", panics)
+	}
+
+	var line map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &line); err != nil {
+		t.Fatalf("decode log: %v (%s)", err, logs.String())
+	}
+	want := map[string]any{
+		"level":      "ERROR",
+		"msg":        "panic recovered",
+		"panic":      "secret detail",
+		"method":     "POST",
+		"path":       "/api/v1/keywords",
+		"request_id": "req-7",
+	}
+	for k, v := range want {
+		if line[k] != v {
+			t.Errorf("log %s = %v, want %v", k, line[k], v)
+		}
+	}
+	if stack, _ := line["stack"].(string); !strings.Contains(stack, "goroutine") {
+		t.Errorf("log stack = %q, want a stack trace", stack)
+	}
+}
+
+func TestRecovery_AbortHandlerRepanics(t *testing.T) {
+	handler := Recovery(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		if r := recover(); r != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler", r)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func TestInFlight_Count(t *testing.T) {
+	var f InFlight
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	handler := f.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		close(done)
+	}()
+
+	<-entered
+	if got := f.Count(); got != 1 {
+		t.Errorf("Count() during request = %d, want 1", got)
+	}
+	close(release)
+	<-done
+	if got := f.Count(); got != 0 {
+		t.Errorf("Count() after request = %d, want 0", got)
+	}
+}
+
+func logRequest(t *testing.T, successLevel slog.Level, path string, status int) []map[string]any {
+	t.Helper()
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	r := chi.NewRouter()
+	r.Use(RequestID)
+	r.Use(SlogLogger(logger, successLevel))
+	r.Get("/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte("hello"))
+	})
+	r.Get("/healthz", func(w http.ResponseWriter, r *http.Request) {})
+	r.Get("/readyz", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(status) })
+
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = "203.0.113.7:51234"
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	var lines []map[string]any
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var line map[string]any
+		if err := dec.Decode(&line); err != nil {
+			t.Fatalf("decode log line: %v", err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestSlogLogger_Fields(t *testing.T) {
+	lines := logRequest(t, slog.LevelInfo, "/items/42", http.StatusCreated)
+	if len(lines) != 1 {
+		t.Fatalf("got %d log lines, want 1", len(lines))
+	}
+	line := lines[0]
+
+	want := map[string]any{
+		"level":     "INFO",
+		"method":    "GET",
+		"path":      "/items/42",
+		"route":     "/items/{id}",
+		"status":    float64(http.StatusCreated),
+		"bytes":     float64(5),
+		"remote_ip": "203.0.113.7",
+	}
+	for k, v := range want {
+		if line[k] != v {
+			t.Errorf("%s = %v, want %v", k, line[k], v)
+		}
+	}
+	if id, _ := line["request_id"].(string); id == "" {
+		t.Error("request_id is empty")
+	}
+	if _, ok := line["duration"]; !ok {
+		t.Error("duration missing")
+	}
+}
+
+func TestSlogLogger_Levels(t *testing.T) {
+	tests := []struct {
+		name         string
+		successLevel slog.Level
+		status       int
+		wantLevel    string // "" means filtered out at info
+	}{
+		{"2xx at debug is filtered", slog.LevelDebug, http.StatusOK, ""},
+		{"2xx at info", slog.LevelInfo, http.StatusOK, "INFO"},
+		{"4xx warns", slog.LevelDebug, http.StatusNotFound, "WARN"},
+		{"5xx errors", slog.LevelDebug, http.StatusBadGateway, "ERROR"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := logRequest(t, tt.successLevel, "/items/1", tt.status)
+			if tt.wantLevel == "" {
+				if len(lines) != 0 {
+					t.Errorf("got %d log lines, want 0", len(lines))
+				}
+				return
+			}
+			if len(lines) != 1 || lines[0]["level"] != tt.wantLevel {
+				t.Errorf("lines = %v, want one %s line", lines, tt.wantLevel)
+			}
+		})
+	}
+}
+
+func TestSlogLogger_SkipsHealthz(t *testing.T) {
+	if lines := logRequest(t, slog.LevelInfo, "/healthz", http.StatusOK); len(lines) != 0 {
+		t.Errorf("got %d log lines for /healthz, want 0", len(lines))
+	}
+	// An unready service answers every probe with 503; none is logged.
+	if lines := logRequest(t, slog.LevelInfo, "/readyz", http.StatusServiceUnavailable); len(lines) != 0 {
+		t.Errorf("got %d log lines for /readyz, want 0", len(lines))
+	}
+}
+
+func TestSlogLogger_SkipsDebugEndpoints(t *testing.T) {
+	if lines := logRequest(t, slog.LevelInfo, "/debug/pprof/heap", http.StatusOK); len(lines) != 0 {
+		t.Errorf("got %d log lines for /debug/pprof/heap, want 0", len(lines))
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{"generated when missing", "", false},
+		{"client id reused", "abc-123_x.y:z", true},
+		{"invalid characters replaced", "bad id\n", false},
+		{"too long replaced", strings.Repeat("a", 65), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fromCtx string
+			handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fromCtx = logging.RequestID(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set(logging.RequestIDHeader, tt.incoming)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			got := rec.Header().Get(logging.RequestIDHeader)
+			if got == "" || got != fromCtx {
+				t.Fatalf("header = %q, context = %q; want equal and non-empty", got, fromCtx)
+			}
+			if (got == tt.incoming) != tt.keep {
+				t.Errorf("id = %q, incoming %q, keep = %v", got, tt.incoming, tt.keep)
+			}
+		})
+	}
+}
+
+func TestTimeout_SlowHandlerGets504(t *testing.T) {
+	handlerDone := make(chan struct{})
+	handler := Timeout(20 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(handlerDone)
+		<-r.Context().Done()
+		w.Header().Set("X-Late", "1")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("too late"))
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	<-handlerDone
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusGatewayTimeout)
+	}
+	if got := strings.TrimSpace(rec.Body.String()); got != `{"error":"request timed out"}` {
+		t.Errorf("body = %q", got)
+	}
+	if rec.Header().Get("X-Late") != "" {
+		t.Error("headers set after the timeout leaked into the response")
+	}
+}
+
+func TestTimeout_FastHandlerUnaffected(t *testing.T) {
+	handler := Timeout(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); !ok {
+			t.Error("request context has no deadline")
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("ok"))
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusCreated || rec.Body.String() != "ok" {
+		t.Errorf("got %d %q, want 201 ok", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Type"); got != "text/plain" {
+		t.Errorf("Content-Type = %q, want text/plain", got)
+	}
+}
+
+func TestTimeout_StartedResponseIsLeftAlone(t *testing.T) {
+	handler := Timeout(20 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		<-r.Context().Done()
+		w.Write([]byte(" rest"))
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if got := rec.Body.String(); got != "partial rest" {
+		t.Errorf("body = %q, want %q", got, "partial rest")
+	}
+}
+
+func TestTimeout_ExemptPathHasNoDeadline(t *testing.T) {
+	handler := Timeout(time.Millisecond, "/export")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); ok {
+			t.Error("exempt request has a deadline")
+		}
+		time.Sleep(10 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/export", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestTimeout_PanicReachesRecovery(t *testing.T) {
+	handler := Recovery(Timeout(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+}
+
+// logLines passes each log line to a channel, for logs written by another
+// goroutine.
+type logLines chan []byte
+
+func (l logLines) Write(p []byte) (int, error) {
+	l <- bytes.Clone(p)
+	return len(p), nil
+}
+
+func TestTimeout_LatePanicIsLogged(t *testing.T) {
+	lines := make(logLines, 1)
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(lines, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	release := make(chan struct{})
+	handler := RequestID(Recovery(Timeout(20 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		panic("late boom")
+	}))))
+
+	req := httptest.NewRequest(http.MethodGet, "/slow", nil)
+	req.Header.Set(logging.RequestIDHeader, "req-9")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	close(release)
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusGatewayTimeout)
+	}
+	var body map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body["request_id"] != "req-9" {
+		t.Errorf("body = %v (%v), want the 504 with request_id", body, err)
+	}
+	select {
+	case line := <-lines:
+		var got map[string]any
+		if err := json.Unmarshal(line, &got); err != nil {
+			t.Fatalf("decode log: %v (%s)", err, line)
+		}
+		if got["msg"] != "panic after request timeout" || got["panic"] != "late boom" || got["request_id"] != "req-9" {
+			t.Errorf("log = %v, want the late panic with its request_id", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("late panic was not logged")
+	}
+}
+
+func TestParseCIDRs(t *testing.T) {
+	got, err := ParseCIDRs(" 10.0.0.0/8, 192.168.1.7 ,2001:db8::/32,")
+	if err != nil {
+		t.Fatalf("ParseCIDRs: %v", err)
+	}
+	want := []string{"10.0.0.0/8", "192.168.1.7/32", "2001:db8::/32"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i].String() != want[i] {
+			t.Errorf("prefix %d = %s, want %s", i, got[i], want[i])
+		}
+	}
+
+	if _, err := ParseCIDRs("10.0.0.0/33"); err == nil {
+		t.Error("expected error for invalid CIDR")
+	}
+	if p, err := ParseCIDRs(""); err != nil || len(p) != 0 {
+		t.Errorf("ParseCIDRs(\"\") = %v, %v; want none", p, err)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParseCIDRs("10.0.0.0/8, 2001:db8::/32")
+	if err != nil {
+		t.Fatalf("ParseCIDRs: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		remote string
+		xff    []string
+		realIP string
+		want   string
+	}{
+		{"untrusted peer ignores headers", "203.0.113.9:4000", []string{"1.2.3.4"}, "5.6.7.8", "203.0.113.9"},
+		{"trusted peer without headers", "10.0.0.5:4000", nil, "", "10.0.0.5"},
+		{"trusted peer single hop", "10.0.0.5:4000", []string{"198.51.100.2"}, "", "198.51.100.2"},
+		{"chain skips trusted hops", "10.0.0.5:4000", []string{"198.51.100.2, 10.1.1.1, 10.2.2.2"}, "", "198.51.100.2"},
+		{"spoofed leftmost entry ignored", "10.0.0.5:4000", []string{"6.6.6.6, 198.51.100.2, 10.1.1.1"}, "", "198.51.100.2"},
+		{"multiple header lines", "10.0.0.5:4000", []string{"6.6.6.6", "198.51.100.2, 10.1.1.1"}, "", "198.51.100.2"},
+		{"all hops trusted uses leftmost", "10.0.0.5:4000", []string{"10.9.9.9, 10.1.1.1"}, "", "10.9.9.9"},
+		{"malformed hop stops the walk", "10.0.0.5:4000", []string{"198.51.100.2, garbage, 10.1.1.1"}, "", "10.1.1.1"},
+		{"hop with port", "10.0.0.5:4000", []string{"198.51.100.2:5555"}, "", "198.51.100.2"},
+		{"x-real-ip from trusted peer", "10.0.0.5:4000", nil, "198.51.100.3", "198.51.100.3"},
+		{"invalid x-real-ip", "10.0.0.5:4000", nil, "nope", "10.0.0.5"},
+		{"ipv6 trusted peer", "[2001:db8::1]:4000", []string{"2001:db8:ffff::1, 2606:4700::1"}, "", "2606:4700::1"},
+		{"ipv4-mapped peer", "[::ffff:10.0.0.5]:4000", []string{"198.51.100.2"}, "", "198.51.100.2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := ClientIP(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = logging.ClientIP(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remote
+			for _, v := range tt.xff {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Errorf("client IP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIP_NoTrustedProxies(t *testing.T) {
+	var got string
+	handler := ClientIP(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = logging.ClientIP(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.5:4000"
+	req.Header.Set("X-Forwarded-For", "1.2.3.4")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got != "10.0.0.5" {
+		t.Errorf("client IP = %q, want the peer address", got)
+	}
+}
+
+func TestAllowCIDRs(t *testing.T) {
+	allowed, err := ParseCIDRs("192.168.10.0/24")
+	if err != nil {
+		t.Fatalf("ParseCIDRs: %v", err)
+	}
+	trusted, err := ParseCIDRs("10.0.0.1")
+	if err != nil {
+		t.Fatalf("ParseCIDRs: %v", err)
+	}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := ClientIP(trusted)(AllowCIDRs(allowed)(ok))
+
+	tests := []struct {
+		name   string
+		remote string
+		xff    string
+		want   int
+	}{
+		{"direct client inside allowlist", "192.168.10.4:5000", "", http.StatusOK},
+		{"direct client outside allowlist", "203.0.113.9:5000", "", http.StatusForbidden},
+		{"spoofed header from untrusted peer", "203.0.113.9:5000", "192.168.10.4", http.StatusForbidden},
+		{"allowed client behind trusted proxy", "10.0.0.1:5000", "192.168.10.4", http.StatusOK},
+		{"outside client behind trusted proxy", "10.0.0.1:5000", "203.0.113.9", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.RemoteAddr = tt.remote
+			if tt.xff != "" {
+				req.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusForbidden && !strings.Contains(rec.Body.String(), `"error"`) {
+				t.Errorf("body = %q, want a JSON error", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestAllowCIDRs_EmptyAllowsAll(t *testing.T) {
+	handler := AllowCIDRs(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.RemoteAddr = "203.0.113.9:5000"
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestBodyLimit(t *testing.T) {
+	handler := BodyLimit(10, map[string]int64{"/import": 100})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			var tooLarge *http.MaxBytesError
+			if !errors.As(err, &tooLarge) {
+				t.Errorf("read error = %v, want *http.MaxBytesError", err)
+			}
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name    string
+		path    string
+		size    int
+		chunked bool
+		want    int
+	}{
+		{"within limit", "/", 10, false, http.StatusOK},
+		{"declared length over limit", "/", 11, false, http.StatusRequestEntityTooLarge},
+		{"chunked body over limit", "/", 11, true, http.StatusRequestEntityTooLarge},
+		{"override allows larger body", "/import", 100, false, http.StatusOK},
+		{"override still bounded", "/import", 101, false, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(strings.Repeat("x", tt.size)))
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestContentType(t *testing.T) {
+	handler := ContentType([]string{"application/json"}, map[string][]string{
+		"/import": {"application/json", "text/csv", "multipart/form-data"},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"json accepted", http.MethodPost, "/", "application/json; charset=utf-8", "{}", http.StatusOK},
+		{"form rejected", http.MethodPost, "/", "application/x-www-form-urlencoded", "a=b", http.StatusUnsupportedMediaType},
+		{"missing type rejected", http.MethodPut, "/", "", "{}", http.StatusUnsupportedMediaType},
+		{"csv rejected outside import", http.MethodPatch, "/", "text/csv", "value", http.StatusUnsupportedMediaType},
+		{"bodiless post passes", http.MethodPost, "/", "", "", http.StatusOK},
+		{"get ignored", http.MethodGet, "/", "text/plain", "x", http.StatusOK},
+		{"csv accepted on import", http.MethodPost, "/import", "text/csv", "value", http.StatusOK},
+		{"multipart accepted on import", http.MethodPost, "/import", "multipart/form-data; boundary=x", "--x--", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusUnsupportedMediaType && !strings.Contains(rec.Body.String(), `"error"`) {
+				t.Errorf("body = %q, want a JSON error", rec.Body.String())
+			}
+		})
+	}
+}
+
+// withSpanRecorder installs a recording tracer provider and the W3C
+// propagator globally for the duration of the test.
+func withSpanRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+	})
+	spans := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return spans
+}
+
+func TestTracing_NamesSpanByRouteAndContinuesTrace(t *testing.T) {
+	spans := withSpanRecorder(t)
+	r := chi.NewRouter()
+	r.Use(Tracing)
+	r.Get("/api/v1/keywords/{id}", func(w http.ResponseWriter, r *http.Request) {})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/keywords/42", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	ended := spans.Ended()
+	if len(ended) != 1 {
+		t.Fatalf("ended spans = %d, want 1", len(ended))
+	}
+	if got := ended[0].Name(); got != "GET /api/v1/keywords/{id}" {
+		t.Errorf("span name = %q, want the route pattern", got)
+	}
+	if got := ended[0].SpanContext().TraceID().String(); got != traceID {
+		t.Errorf("trace ID = %s, want %s from traceparent", got, traceID)
+	}
+	if !ended[0].Parent().IsRemote() {
+		t.Error("parent is not the remote caller's span")
+	}
+}
+
+func TestTracing_SkipsProbesAndMetrics(t *testing.T) {
+	spans := withSpanRecorder(t)
+	r := chi.NewRouter()
+	r.Use(Tracing)
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	r.Get("/healthz", ok)
+	r.Get("/readyz", ok)
+	r.Get("/metrics", ok)
+	r.Get("/debug/stats", ok)
+
+	for _, path := range []string{"/healthz", "/readyz", "/metrics", "/debug/stats"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	if n := len(spans.Ended()); n != 0 {
+		t.Errorf("ended spans = %d, want 0", n)
+	}
 }
@@ -1,24 +1,56 @@
 package middleware
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"runtime/debug"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/logging"
 )
 
+// Recovery turns a handler panic into a 500 JSON error and logs the panic
+// value, stack trace, method, path and request ID. The panic text is never
+// sent to the client.
 func Recovery(next http.Handler) http.Handler {
+	return recoverer(next, nil)
+}
+
+// RecoveryWithHook is Recovery that also calls onPanic for every recovered
+// panic, e.g. to count them.
+func RecoveryWithHook(onPanic func()) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return recoverer(next, onPanic)
+	}
+}
+
+func recoverer(next http.Handler, onPanic func()) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
-			if err := recover(); err != nil {
-				slog.Error("panic recovered",
-					"error", err,
-					"stack", string(debug.Stack()),
-					"path", r.URL.Path,
-				)
-				w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte(`{"error":"internal server error"}`))
+			err := recover()
+			if err == nil {
+				return
 			}
+			if e, ok := err.(error); ok && errors.Is(e, http.ErrAbortHandler) {
+				// Deliberate abort; let net/http close the connection quietly.
+				panic(err)
+			}
+
+			requestID := logging.RequestID(r.Context())
+			slog.Default().LogAttrs(context.Background(), slog.LevelError, "panic recovered",
+				slog.String("panic", fmt.Sprint(err)),
+				slog.String("stack", string(debug.Stack())),
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.String("request_id", requestID),
+			)
+			if onPanic != nil {
+				onPanic()
+			}
+
+			writeError(w, http.StatusInternalServerError, "internal server error")
 		}()
 		next.ServeHTTP(w, r)
 	})
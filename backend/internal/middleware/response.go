@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/logging"
+)
+
+// writeError sends the API's {"error": message} envelope, adding the
+// request ID when RequestID has set it, like handler.writeError.
+func writeError(w http.ResponseWriter, status int, message string) {
+	body := map[string]string{"error": message}
+	if id := w.Header().Get(logging.RequestIDHeader); id != "" {
+		body["request_id"] = id
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,182 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/logging"
+)
+
+// Timeout gives each request a deadline of d through its context. If the
+// deadline passes before the handler has written anything, the client gets a
+// 504 JSON error and later writes from the handler are discarded. If the
+// handler has already started writing, the response is left to the handler,
+// which should stop once its context is done. A panic after the 504 is
+// logged, as Recovery no longer can. Requests whose path is in
+// exempt (e.g. streaming exports) run without a deadline, as do all
+// requests when d <= 0.
+func Timeout(d time.Duration, exempt ...string) func(http.Handler) http.Handler {
+	skip := make(map[string]bool, len(exempt))
+	for _, p := range exempt {
+		skip[p] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if d <= 0 || skip[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+
+			// Headers set upstream, such as the request ID, stay visible to
+			// the handler.
+			tw := &timeoutWriter{w: w, ctx: ctx, header: w.Header().Clone()}
+			done := make(chan struct{})
+			panicked := make(chan handlerPanic, 1)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicked <- handlerPanic{value: p, stack: debug.Stack()}
+					}
+					close(done)
+				}()
+				next.ServeHTTP(tw, r.WithContext(ctx))
+			}()
+
+			select {
+			case <-done:
+			case <-ctx.Done():
+				if tw.timeout() {
+					go logLatePanic(r, done, panicked)
+					return
+				}
+				// The response is already under way; let the handler finish it.
+				<-done
+			}
+			select {
+			case p := <-panicked:
+				panic(p.value) // re-raise on the serving goroutine for Recovery
+			default:
+			}
+		})
+	}
+}
+
+type handlerPanic struct {
+	value any
+	stack []byte
+}
+
+// logLatePanic waits for a handler that was answered with a 504 and logs
+// the panic it raises, if any.
+func logLatePanic(r *http.Request, done <-chan struct{}, panicked <-chan handlerPanic) {
+	<-done
+	select {
+	case p := <-panicked:
+		slog.Default().LogAttrs(context.Background(), slog.LevelError, "panic after request timeout",
+			slog.String("panic", fmt.Sprint(p.value)),
+			slog.String("stack", string(p.stack)),
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("request_id", logging.RequestID(r.Context())),
+		)
+	default:
+	}
+}
+
+// timeoutWriter buffers headers until the handler commits to a response so
+// that a timeout can still replace it, then passes writes straight through
+// so long responses are not held in memory.
+type timeoutWriter struct {
+	w      http.ResponseWriter
+	ctx    context.Context
+	header http.Header
+
+	mu          sync.Mutex
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.writeHeaderLocked(status)
+}
+
+// writeHeaderLocked commits the handler's response, or the 504 if the
+// deadline has passed first: a handler woken by its context may get here
+// before Timeout does.
+func (tw *timeoutWriter) writeHeaderLocked(status int) {
+	if tw.wroteHeader || tw.timedOut {
+		return
+	}
+	if tw.ctx.Err() != nil {
+		tw.timeoutLocked()
+		return
+	}
+	tw.wroteHeader = true
+	dst := tw.w.Header()
+	for k := range dst {
+		if _, ok := tw.header[k]; !ok {
+			delete(dst, k)
+		}
+	}
+	for k, v := range tw.header {
+		dst[k] = v
+	}
+	tw.w.WriteHeader(status)
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.writeHeaderLocked(http.StatusOK)
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	return tw.w.Write(p)
+}
+
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.writeHeaderLocked(http.StatusOK)
+	if tw.timedOut {
+		return
+	}
+	if f, ok := tw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// timeout writes the 504 response unless the handler has already started
+// its own, reporting whether the response is the 504.
+func (tw *timeoutWriter) timeout() bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.wroteHeader {
+		return false
+	}
+	tw.timeoutLocked()
+	return true
+}
+
+func (tw *timeoutWriter) timeoutLocked() {
+	if tw.timedOut {
+		return
+	}
+	tw.timedOut = true
+	writeError(tw.w, http.StatusGatewayTimeout, "request timed out")
+}
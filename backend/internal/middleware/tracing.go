@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/logging"
+)
+
+// Tracing starts a server span per request, continuing the trace from an
+// incoming traceparent header. Once chi has routed the request the span is
+// renamed to "METHOD /route/{pattern}" so spans group by endpoint rather
+// than by raw path. Probes, metrics scrapes and debug endpoints are not
+// traced. It uses the global tracer provider and propagator, which are
+// no-ops until tracing.Setup installs an exporter.
+func Tracing(next http.Handler) http.Handler {
+	named := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+
+		span := trace.SpanFromContext(r.Context())
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			span.SetName(spanName("", r))
+			span.SetAttributes(attribute.String("http.route", rctx.RoutePattern()))
+		}
+		if id := logging.RequestID(r.Context()); id != "" {
+			span.SetAttributes(attribute.String("http.request_id", id))
+		}
+	})
+	return otelhttp.NewHandler(named, "http.request",
+		otelhttp.WithFilter(traced),
+		otelhttp.WithSpanNameFormatter(spanName),
+	)
+}
+
+// spanName is "METHOD /route/{pattern}" once chi has routed r, and just the
+// method before that.
+func spanName(_ string, r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+		return r.Method + " " + rctx.RoutePattern()
+	}
+	return r.Method
+}
+
+// traced reports whether a request gets a span.
+func traced(r *http.Request) bool {
+	switch {
+	case r.URL.Path == "/healthz", r.URL.Path == "/readyz", r.URL.Path == "/metrics",
+		strings.HasPrefix(r.URL.Path, "/debug/"):
+		return false
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	AuditActionCreate    = "create"
+	AuditActionUpdate    = "update"
+	AuditActionDelete    = "delete"
+	AuditActionStart     = "start"
+	AuditActionStop      = "stop"
+	AuditActionPause     = "pause"
+	AuditActionResume    = "resume"
+	AuditActionImport    = "import"
+	AuditActionReset     = "reset"
+	AuditActionMute      = "mute"
+	AuditActionUnmute    = "unmute"
+	AuditActionEnable    = "enable"
+	AuditActionDisable   = "disable"
+	AuditActionReprocess = "reprocess"
+	AuditActionSwitchLog = "switch_log"
+)
+
+const (
+	AuditEntityKeyword      = "keyword"
+	AuditEntityMonitor      = "monitor"
+	AuditEntityWebhook      = "webhook"
+	AuditEntityCertificate  = "certificate"
+	AuditEntityReport       = "report"
+	AuditEntityKeywordGroup = "keyword_group"
+)
+
+// AuditChange records the before/after value of a single field.
+type AuditChange struct {
+	Old string `json:"old,omitempty"`
+	New string `json:"new,omitempty"`
+}
+
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	CreatedAt  time.Time       `json:"created_at"`
+	Actor      string          `json:"actor"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   string          `json:"entity_id"`
+	Changes    json.RawMessage `json:"changes"`
+	// RequestID and ClientIP identify the API request that made the
+	// change; both are empty for system actions.
+	RequestID string `json:"request_id"`
+	ClientIP  string `json:"client_ip"`
+}
+
+// AuditFilter narrows an audit log listing. Zero values mean "any".
+type AuditFilter struct {
+	Actor      string
+	Action     string
+	EntityType string
+	RequestID  string
+	Since      *time.Time
+	Until      *time.Time
+}
@@ -0,0 +1,10 @@
+package model
+
+// BuildInfo identifies the running server build.
+type BuildInfo struct {
+	Version       string `json:"version"`
+	Commit        string `json:"commit"`
+	BuildDate     string `json:"build_date"`
+	GoVersion     string `json:"go_version"`
+	UptimeSeconds int64  `json:"uptime_seconds"`
+}
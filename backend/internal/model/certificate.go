@@ -1,18 +1,218 @@
 package model
 
-import "time"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// Triage statuses for a matched certificate.
+const (
+	CertStatusNew           = "new"
+	CertStatusAcknowledged  = "acknowledged"
+	CertStatusFalsePositive = "false_positive"
+	CertStatusResolved      = "resolved"
+)
+
+// Certificate fields a keyword match can come from.
+const (
+	MatchFieldCN  = "cn"
+	MatchFieldSAN = "san"
+)
+
+// Domain age lookup outcomes; empty until the match has been looked up.
+const (
+	DomainAgeKnown   = "known"
+	DomainAgeUnknown = "unknown"
+)
+
+// Chain validation outcomes (see ctlog.Verifier).
+const (
+	ChainValid         = "valid"
+	ChainUnknownIssuer = "unknown_issuer"
+	ChainExpired       = "expired_chain"
+	ChainNotChecked    = "not_checked"
+)
+
+// Where a match came from.
+const (
+	SourceCTLog = "ctlog"
+	SourceCrtSh = "crtsh"
+)
+
+// Issuer classes (see service/issuer).
+const (
+	IssuerFreeAutomated = "free_automated"
+	IssuerPaid          = "paid"
+	IssuerEnterprise    = "enterprise"
+	IssuerUnknown       = "unknown"
+)
+
+// ValidIssuerClass reports whether s is a known issuer class.
+func ValidIssuerClass(s string) bool {
+	switch s {
+	case IssuerFreeAutomated, IssuerPaid, IssuerEnterprise, IssuerUnknown:
+		return true
+	}
+	return false
+}
+
+// ValidChainStatus reports whether s is a known chain validation status.
+func ValidChainStatus(s string) bool {
+	switch s {
+	case ChainValid, ChainUnknownIssuer, ChainExpired, ChainNotChecked:
+		return true
+	}
+	return false
+}
+
+// ValidCertStatus reports whether s is a known triage status.
+func ValidCertStatus(s string) bool {
+	switch s {
+	case CertStatusNew, CertStatusAcknowledged, CertStatusFalsePositive, CertStatusResolved:
+		return true
+	}
+	return false
+}
 
 type MatchedCertificate struct {
-	ID            int       `json:"id"`
-	SerialNumber  string    `json:"serial_number"`
-	CommonName    string    `json:"common_name"`
-	SANs          []string  `json:"sans"`
-	Issuer        string    `json:"issuer"`
-	NotBefore     time.Time `json:"not_before"`
-	NotAfter      time.Time `json:"not_after"`
-	KeywordID     int       `json:"keyword_id"`
-	KeywordValue  string    `json:"keyword_value,omitempty"`
-	MatchedDomain string    `json:"matched_domain"`
-	CTLogIndex    int64     `json:"ct_log_index"`
-	DiscoveredAt  time.Time `json:"discovered_at"`
+	ID             int        `json:"id"`
+	SerialNumber   string     `json:"serial_number"`
+	CommonName     string     `json:"common_name"`
+	SANs           []string   `json:"sans"`
+	Issuer         string     `json:"issuer"`
+	NotBefore      time.Time  `json:"not_before"`
+	NotAfter       time.Time  `json:"not_after"`
+	KeywordID      int        `json:"keyword_id"`
+	KeywordValue   string     `json:"keyword_value,omitempty"`
+	MatchedDomain  string     `json:"matched_domain"`
+	MatchedField   string     `json:"matched_field"`
+	IsPrecert      bool       `json:"is_precert"`
+	CTLogIndex     int64      `json:"ct_log_index"`
+	DiscoveredAt   time.Time  `json:"discovered_at"`
+	Status         string     `json:"status"`
+	AcknowledgedBy string     `json:"acknowledged_by,omitempty"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+	StatusNote     string     `json:"status_note,omitempty"`
+
+	// MatchedValue is the keyword value, or alternative, found in
+	// MatchedDomain; empty for matches stored before alternatives existed.
+	MatchedValue string `json:"matched_value,omitempty"`
+
+	// RegistrableDomain is the eTLD+1 of MatchedDomain. When the host has
+	// none (IP literal, single label) it holds the raw host and
+	// RegistrableDomainRaw is set.
+	RegistrableDomain    string `json:"registrable_domain"`
+	RegistrableDomainRaw bool   `json:"registrable_domain_raw,omitempty"`
+
+	// SANCount is len(SANs), computed when the row is read so clients can
+	// flag certificates with unusually many names without counting.
+	SANCount int `json:"san_count"`
+
+	// ExtKeyUsages and IsServerAuth come from the certificate's extended
+	// key usage extension (see ctlog.ParsedCertificate).
+	ExtKeyUsages []string `json:"ext_key_usages"`
+	IsServerAuth bool     `json:"is_server_auth"`
+
+	// ChainStatus says whether the certificate chains to a trusted root
+	// through the chain logged with it (one of the Chain* constants).
+	ChainStatus string `json:"chain_status"`
+
+	// IssuerClass says what kind of CA issued the certificate (one of the
+	// Issuer* constants), from its issuer name.
+	IssuerClass string `json:"issuer_class"`
+
+	// Source says where the match came from: SourceCTLog, or SourceCrtSh
+	// for history imported from crt.sh. Imported matches have no log
+	// entry; their CTLogIndex and *SeenIndex fields are 0.
+	Source string `json:"source"`
+
+	// FirstSeen* record the log entry and time the match was first
+	// stored; LastSeen* the latest re-observation, which only the update
+	// conflict strategy records.
+	FirstSeenIndex int64     `json:"first_seen_index"`
+	FirstSeenAt    time.Time `json:"first_seen_at"`
+	LastSeenIndex  int64     `json:"last_seen_index"`
+	LastSeenAt     time.Time `json:"last_seen_at"`
+
+	// CTLogURL is the log CTLogIndex and LastSeenIndex point into, and
+	// FirstSeenLogURL the one FirstSeenIndex does. Both are empty for
+	// imported matches and for those stored before they were recorded.
+	CTLogURL        string `json:"ct_log_url,omitempty"`
+	FirstSeenLogURL string `json:"first_seen_log_url,omitempty"`
+
+	// HistoricalCertCount and HistoricalFirstSeen describe the registrable
+	// domain's certificate history on crt.sh: how many certificates were
+	// ever logged for it and when the first was. Nil until enriched (see
+	// service/enrich).
+	HistoricalCertCount *int       `json:"historical_cert_count,omitempty"`
+	HistoricalFirstSeen *time.Time `json:"historical_first_seen,omitempty"`
+
+	// DomainRegisteredAt is the registrable domain's RDAP registration
+	// date and DomainAgeDays its age in days when the match was first
+	// seen. Both are nil unless DomainAgeStatus is DomainAgeKnown.
+	DomainRegisteredAt *time.Time `json:"domain_registered_at,omitempty"`
+	DomainAgeDays      *int       `json:"domain_age_days,omitempty"`
+	DomainAgeStatus    string     `json:"domain_age_status,omitempty"`
+
+	// RiskScore (0-100) sums the points of the factors in RiskBreakdown. It
+	// is computed when the match is stored and again when enrichment
+	// updates it (see service/risk).
+	RiskScore     int          `json:"risk_score"`
+	RiskBreakdown []RiskFactor `json:"risk_breakdown"`
+
+	// Keywords is set only in a coalesced notification's payload
+	// (CERT_CONSOLIDATE): every keyword that matched the certificate
+	// before the notification went out, this match's first.
+	Keywords []CertificateKeyword `json:"keywords,omitempty"`
+}
+
+// Fingerprint identifies the certificate behind a match. The DER is not
+// stored, so it is the SHA-256 of the issuer and serial number, which
+// together name exactly one certificate.
+func (c MatchedCertificate) Fingerprint() string {
+	sum := sha256.Sum256([]byte(c.Issuer + "\x00" + c.SerialNumber))
+	return hex.EncodeToString(sum[:])
+}
+
+// Certificate is one certificate with every keyword that matched it: the
+// consolidated view of its per-keyword MatchedCertificate rows.
+type Certificate struct {
+	ID           int                  `json:"id"`
+	Fingerprint  string               `json:"fingerprint"`
+	SerialNumber string               `json:"serial_number"`
+	CommonName   string               `json:"common_name"`
+	SANs         []string             `json:"sans"`
+	Issuer       string               `json:"issuer"`
+	NotBefore    time.Time            `json:"not_before"`
+	NotAfter     time.Time            `json:"not_after"`
+	FirstSeenAt  time.Time            `json:"first_seen_at"`
+	Keywords     []CertificateKeyword `json:"keywords"`
+}
+
+// CertificateKeyword is one keyword's match on a Certificate; MatchID is
+// its MatchedCertificate.
+type CertificateKeyword struct {
+	MatchID       int    `json:"match_id"`
+	KeywordID     int    `json:"keyword_id"`
+	Keyword       string `json:"keyword"`
+	MatchedDomain string `json:"matched_domain"`
+	Reason        string `json:"reason"`
+}
+
+// MatchReason describes why a keyword matched: the field (a MatchField
+// constant) and, when known, the keyword value found in it.
+func MatchReason(field, value string) string {
+	if value == "" {
+		return field
+	}
+	return field + " contains " + value
+}
+
+// RiskFactor is one line of a risk score breakdown: a factor that applied
+// to the match, the points it added and why it applied.
+type RiskFactor struct {
+	Factor string `json:"factor"`
+	Points int    `json:"points"`
+	Detail string `json:"detail,omitempty"`
 }
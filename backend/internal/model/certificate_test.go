@@ -0,0 +1,28 @@
+package model
+
+import "testing"
+
+func TestMatchedCertificateFingerprint(t *testing.T) {
+	a := MatchedCertificate{SerialNumber: "01ab", Issuer: "Test CA", KeywordID: 1}
+	b := MatchedCertificate{SerialNumber: "01ab", Issuer: "Test CA", KeywordID: 2, CommonName: "other"}
+	if a.Fingerprint() != b.Fingerprint() {
+		t.Error("matches of one certificate have different fingerprints")
+	}
+	if len(a.Fingerprint()) != 64 {
+		t.Errorf("Fingerprint() = %q, want 64 hex digits", a.Fingerprint())
+	}
+	// The separator keeps issuer and serial from running together.
+	c := MatchedCertificate{SerialNumber: "b", Issuer: "Test CA01a"}
+	if a.Fingerprint() == c.Fingerprint() {
+		t.Error("different certificates share a fingerprint")
+	}
+}
+
+func TestMatchReason(t *testing.T) {
+	if got := MatchReason("san", "paypal"); got != "san contains paypal" {
+		t.Errorf("MatchReason(san, paypal) = %q", got)
+	}
+	if got := MatchReason("cn", ""); got != "cn" {
+		t.Errorf("MatchReason(cn, \"\") = %q, want cn", got)
+	}
+}
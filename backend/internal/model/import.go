@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+// Import job states.
+const (
+	ImportRunning   = "running"
+	ImportCompleted = "completed"
+	ImportFailed    = "failed"
+	ImportCanceled  = "canceled"
+)
+
+// ImportJob is a background import of a keyword's historical matches from
+// crt.sh, with its progress so far.
+type ImportJob struct {
+	ID        int       `json:"id"`
+	KeywordID int       `json:"keyword_id"`
+	Keyword   string    `json:"keyword"`
+	Since     time.Time `json:"since"`
+	MaxRows   int       `json:"max_rows"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+
+	// Fetched counts the crt.sh rows read, Matched those logged since
+	// Since whose names match the keyword, and Imported and Duplicates how
+	// many of these were stored or already known. The import stops once
+	// Matched reaches MaxRows.
+	Fetched    int `json:"fetched"`
+	Matched    int `json:"matched"`
+	Imported   int `json:"imported"`
+	Duplicates int `json:"duplicates"`
+
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
@@ -2,8 +2,135 @@ package model
 
 import "time"
 
+// How a keyword is compared against certificate domains.
+const (
+	// MatchModeSubstring matches the keyword anywhere in the domain.
+	MatchModeSubstring = "substring"
+	// MatchModeBoundary requires the keyword to start or end at a '.' or
+	// '-' or at the start or end of the domain.
+	MatchModeBoundary = "boundary"
+)
+
+// ValidMatchMode reports whether s is a known keyword match mode.
+func ValidMatchMode(s string) bool {
+	switch s {
+	case MatchModeSubstring, MatchModeBoundary:
+		return true
+	}
+	return false
+}
+
+// What a keyword mute silences.
+const (
+	// MuteScopeNotifications keeps matching and storing the keyword's
+	// certificates but sends no notifications for them.
+	MuteScopeNotifications = "notifications"
+	// MuteScopeMatching stops matching the keyword altogether.
+	MuteScopeMatching = "matching"
+)
+
+// ValidMuteScope reports whether s is a known mute scope.
+func ValidMuteScope(s string) bool {
+	switch s {
+	case MuteScopeNotifications, MuteScopeMatching:
+		return true
+	}
+	return false
+}
+
 type Keyword struct {
 	ID        int       `json:"id"`
 	Value     string    `json:"value"`
+	MatchMode string    `json:"match_mode"`
 	CreatedAt time.Time `json:"created_at"`
+	// Alternatives are further values matched as this same keyword (an OR
+	// group): a certificate containing any of them is one match, recording
+	// the value that hit.
+	Alternatives []string `json:"alternatives,omitempty"`
+	// MutedUntil is set while a mute is in force and nil once it has
+	// expired or been lifted; MuteScope says what it silences.
+	MutedUntil *time.Time `json:"muted_until"`
+	MuteScope  string     `json:"mute_scope,omitempty"`
+	// DisabledAt is set while the keyword is disabled, which keeps it from
+	// being matched until it is enabled again; DisabledReason says why.
+	DisabledAt     *time.Time `json:"disabled_at"`
+	DisabledReason string     `json:"disabled_reason,omitempty"`
+	// GroupID is the keyword's group, if any. Inherited lists the settings
+	// ("match_mode", "mute", "disabled") the keyword takes from its group
+	// rather than holding itself; see Inherit.
+	GroupID   *int     `json:"group_id"`
+	Inherited []string `json:"inherited,omitempty"`
+
+	// ActiveFrom and ActiveUntil bound the period the keyword is matched
+	// in, e.g. a campaign; nil leaves that side open. Outside it the
+	// keyword is kept, with its matches, but not matched. See ActiveAt.
+	ActiveFrom  *time.Time `json:"active_from"`
+	ActiveUntil *time.Time `json:"active_until"`
+}
+
+// KeywordGroup bundles keywords, such as the variants of one brand, under
+// shared settings. Its match mode, mute and disable are defaults for its
+// keywords, which can override each with their own.
+type KeywordGroup struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	MatchMode string `json:"match_mode,omitempty"`
+	// MutedUntil, MuteScope, DisabledAt and DisabledReason mean what they
+	// do on a Keyword.
+	MutedUntil     *time.Time `json:"muted_until"`
+	MuteScope      string     `json:"mute_scope,omitempty"`
+	DisabledAt     *time.Time `json:"disabled_at"`
+	DisabledReason string     `json:"disabled_reason,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	KeywordCount   int        `json:"keyword_count"`
+}
+
+// Inherit fills in the settings k leaves unset from its group g: an empty
+// match mode takes g's, and with no mute (or disable) of its own k takes
+// g's, recording each in Inherited. A keyword's own setting always wins,
+// so a notifications mute on k narrows a matching mute on g, and enabling
+// k cannot lift g's disable.
+func (k *Keyword) Inherit(g KeywordGroup) {
+	if k.MatchMode == "" && g.MatchMode != "" {
+		k.MatchMode = g.MatchMode
+		k.Inherited = append(k.Inherited, "match_mode")
+	}
+	if k.MutedUntil == nil && g.MutedUntil != nil {
+		k.MutedUntil, k.MuteScope = g.MutedUntil, g.MuteScope
+		k.Inherited = append(k.Inherited, "mute")
+	}
+	if k.DisabledAt == nil && g.DisabledAt != nil {
+		k.DisabledAt, k.DisabledReason = g.DisabledAt, g.DisabledReason
+		k.Inherited = append(k.Inherited, "disabled")
+	}
+}
+
+// Values returns the values the keyword matches: Value, then its
+// Alternatives.
+func (k Keyword) Values() []string {
+	return append([]string{k.Value}, k.Alternatives...)
+}
+
+// Enabled reports whether the keyword is matched at all.
+func (k Keyword) Enabled() bool {
+	return k.DisabledAt == nil
+}
+
+// ActiveAt reports whether t falls in the keyword's active window, which
+// includes ActiveFrom and ends at ActiveUntil.
+func (k Keyword) ActiveAt(t time.Time) bool {
+	if k.ActiveFrom != nil && t.Before(*k.ActiveFrom) {
+		return false
+	}
+	return k.ActiveUntil == nil || t.Before(*k.ActiveUntil)
+}
+
+// MutedAt reports whether the keyword is muted at t under scope. A mute
+// ends at MutedUntil: t equal to it is no longer muted. A matching mute
+// also silences notifications, since nothing is matched to notify about.
+func (k Keyword) MutedAt(t time.Time, scope string) bool {
+	if k.MutedUntil == nil || !t.Before(*k.MutedUntil) {
+		return false
+	}
+	return k.MuteScope == MuteScopeMatching || k.MuteScope == scope
 }
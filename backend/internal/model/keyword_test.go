@@ -0,0 +1,132 @@
+package model
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestKeywordInherit(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	groupMute := now.Add(24 * time.Hour)
+	ownMute := now.Add(time.Hour)
+	groupDisabled := now.Add(-time.Hour)
+	ownDisabled := now.Add(-48 * time.Hour)
+
+	group := KeywordGroup{
+		MatchMode:      MatchModeBoundary,
+		MutedUntil:     &groupMute,
+		MuteScope:      MuteScopeMatching,
+		DisabledAt:     &groupDisabled,
+		DisabledReason: "brand retired",
+	}
+
+	tests := []struct {
+		name          string
+		kw            Keyword
+		group         KeywordGroup
+		wantMode      string
+		wantMute      *time.Time
+		wantScope     string
+		wantReason    string
+		wantInherited []string
+	}{
+		{
+			name:          "unset settings come from the group",
+			kw:            Keyword{},
+			group:         group,
+			wantMode:      MatchModeBoundary,
+			wantMute:      &groupMute,
+			wantScope:     MuteScopeMatching,
+			wantReason:    "brand retired",
+			wantInherited: []string{"match_mode", "mute", "disabled"},
+		},
+		{
+			name: "own settings override the group",
+			kw: Keyword{
+				MatchMode:      MatchModeSubstring,
+				MutedUntil:     &ownMute,
+				MuteScope:      MuteScopeNotifications,
+				DisabledAt:     &ownDisabled,
+				DisabledReason: "too many matches",
+			},
+			group:      group,
+			wantMode:   MatchModeSubstring,
+			wantMute:   &ownMute,
+			wantScope:  MuteScopeNotifications,
+			wantReason: "too many matches",
+		},
+		{
+			name:      "a group without defaults leaves the keyword alone",
+			kw:        Keyword{MutedUntil: &ownMute, MuteScope: MuteScopeNotifications},
+			group:     KeywordGroup{},
+			wantMute:  &ownMute,
+			wantScope: MuteScopeNotifications,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kw := tt.kw
+			kw.Inherit(tt.group)
+
+			if kw.MatchMode != tt.wantMode {
+				t.Errorf("MatchMode = %q, want %q", kw.MatchMode, tt.wantMode)
+			}
+			if kw.MutedUntil != tt.wantMute || kw.MuteScope != tt.wantScope {
+				t.Errorf("mute = %v/%q, want %v/%q", kw.MutedUntil, kw.MuteScope, tt.wantMute, tt.wantScope)
+			}
+			if kw.DisabledReason != tt.wantReason {
+				t.Errorf("DisabledReason = %q, want %q", kw.DisabledReason, tt.wantReason)
+			}
+			if !slices.Equal(kw.Inherited, tt.wantInherited) {
+				t.Errorf("Inherited = %v, want %v", kw.Inherited, tt.wantInherited)
+			}
+		})
+	}
+}
+
+func TestKeywordInherit_ResolvedMuteAndDisable(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	until := now.Add(time.Hour)
+	disabled := now.Add(-time.Hour)
+
+	// A notifications mute on the keyword narrows the group's matching
+	// mute: the keyword is still matched, just not notified.
+	kw := Keyword{MutedUntil: &until, MuteScope: MuteScopeNotifications}
+	kw.Inherit(KeywordGroup{MutedUntil: &until, MuteScope: MuteScopeMatching})
+	if kw.MutedAt(now, MuteScopeMatching) || !kw.MutedAt(now, MuteScopeNotifications) {
+		t.Errorf("own notifications mute: matching/notifications muted = %v/%v, want false/true",
+			kw.MutedAt(now, MuteScopeMatching), kw.MutedAt(now, MuteScopeNotifications))
+	}
+
+	// An enabled keyword in a disabled group is not matched.
+	kw = Keyword{}
+	kw.Inherit(KeywordGroup{DisabledAt: &disabled})
+	if kw.Enabled() {
+		t.Error("keyword in a disabled group is enabled")
+	}
+}
+
+func TestKeywordActiveAt(t *testing.T) {
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		kw   Keyword
+		at   time.Time
+		want bool
+	}{
+		{"no window", Keyword{}, from, true},
+		{"before from", Keyword{ActiveFrom: &from}, from.Add(-time.Nanosecond), false},
+		{"at from", Keyword{ActiveFrom: &from}, from, true},
+		{"before until", Keyword{ActiveUntil: &until}, until.Add(-time.Nanosecond), true},
+		{"at until", Keyword{ActiveUntil: &until}, until, false},
+		{"inside both", Keyword{ActiveFrom: &from, ActiveUntil: &until}, from.Add(time.Hour), true},
+		{"after both", Keyword{ActiveFrom: &from, ActiveUntil: &until}, until.Add(time.Hour), false},
+	}
+	for _, tt := range tests {
+		if got := tt.kw.ActiveAt(tt.at); got != tt.want {
+			t.Errorf("%s: ActiveAt() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -12,5 +12,21 @@ type MonitorState struct {
 	ParseErrorsInLastCycle int        `json:"parse_errors_in_last_cycle"`
 	IsRunning              bool       `json:"is_running"`
 	LastError              string     `json:"last_error"`
+	LastErrorFirstSeen     *time.Time `json:"last_error_first_seen"`
+	LastErrorCount         int        `json:"last_error_count"`
 	UpdatedAt              time.Time  `json:"updated_at"`
+	// Paused is process-local and not stored; the handler fills it in.
+	Paused bool `json:"paused"`
+
+	// CTLogURL is the log the cursor follows once the monitor has switched
+	// logs, and CTLogConfiguredURL the CT_LOG_URL in force at the switch;
+	// both are empty until the first switch. LogFrozenSince is set while
+	// the log is considered frozen (see monitor.WithRollover).
+	CTLogURL           string     `json:"ct_log_url"`
+	CTLogConfiguredURL string     `json:"ct_log_configured_url"`
+	LogFrozenSince     *time.Time `json:"log_frozen_since"`
+
+	// NotifyQueue is process-local like Paused; it is omitted when the
+	// handler has no dispatcher to ask.
+	NotifyQueue *NotifyQueueStats `json:"notify_queue,omitempty"`
 }
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Outbox delivery states. Failed rows exhausted their retries and dropped
+// rows were turned away by a full delivery queue; both are kept for
+// inspection until pruned.
+const (
+	OutboxStatusPending = "pending"
+	OutboxStatusSent    = "sent"
+	OutboxStatusFailed  = "failed"
+	OutboxStatusDropped = "dropped"
+)
+
+// OutboxMessage is a pending notification written in the same transaction
+// as the match it describes.
+type OutboxMessage struct {
+	ID            int64           `json:"id"`
+	CertificateID int             `json:"certificate_id"`
+	Payload       json.RawMessage `json:"payload"`
+	Attempts      int             `json:"attempts"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// NotifyQueueStats describes the notification dispatcher's delivery queue,
+// served in GET /monitor/status. The counters are process-local and start
+// at zero on every start.
+type NotifyQueueStats struct {
+	Size     int    `json:"size"`
+	Policy   string `json:"policy"`
+	Depth    int64  `json:"depth"`
+	Dropped  int64  `json:"dropped"`
+	Digested int64  `json:"digested"`
+}
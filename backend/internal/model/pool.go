@@ -0,0 +1,15 @@
+package model
+
+// PoolStats is a snapshot of the database connection pool, served by
+// GET /debug/pool.
+type PoolStats struct {
+	AcquiredConns        int32 `json:"acquired_conns"`
+	IdleConns            int32 `json:"idle_conns"`
+	TotalConns           int32 `json:"total_conns"`
+	MaxConns             int32 `json:"max_conns"`
+	AcquireCount         int64 `json:"acquire_count"`
+	EmptyAcquireCount    int64 `json:"empty_acquire_count"`
+	CanceledAcquireCount int64 `json:"canceled_acquire_count"`
+	// AcquireDurationMS is the total time spent acquiring connections.
+	AcquireDurationMS int64 `json:"acquire_duration_ms"`
+}
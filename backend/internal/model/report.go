@@ -0,0 +1,48 @@
+package model
+
+import "time"
+
+// DailyReport summarizes one calendar day, in the report's timezone, of
+// matches and monitor health.
+type DailyReport struct {
+	// Date is the reported day (YYYY-MM-DD) in Timezone; From and To bound
+	// it as the half-open interval [From, To), which is 23 or 25 hours
+	// long on DST changes.
+	Date        string    `json:"date"`
+	Timezone    string    `json:"timezone"`
+	From        time.Time `json:"from"`
+	To          time.Time `json:"to"`
+	GeneratedAt time.Time `json:"generated_at"`
+	MatchSummary
+	Monitor ReportHealth `json:"monitor"`
+}
+
+// MatchSummary aggregates the matches first seen in a time window.
+type MatchSummary struct {
+	NewMatches int           `json:"new_matches"`
+	ByKeyword  []StatsBucket `json:"by_keyword"`
+	TopDomains []DomainCount `json:"top_domains"`
+	// HighRisk lists the riskiest matches at or above the report's
+	// threshold, highest score first.
+	HighRisk []ReportMatch `json:"high_risk"`
+}
+
+// ReportMatch is a match as listed in a report.
+type ReportMatch struct {
+	ID            int       `json:"id"`
+	MatchedDomain string    `json:"matched_domain"`
+	Keyword       string    `json:"keyword"`
+	RiskScore     int       `json:"risk_score"`
+	DiscoveredAt  time.Time `json:"discovered_at"`
+}
+
+// ReportHealth is the monitor's state when a report was generated.
+type ReportHealth struct {
+	IsRunning bool       `json:"is_running"`
+	LastRunAt *time.Time `json:"last_run_at"`
+	// Backlog is how many log entries the monitor has yet to process.
+	Backlog        int64  `json:"backlog"`
+	TotalProcessed int64  `json:"total_processed"`
+	LastError      string `json:"last_error"`
+	LastErrorCount int    `json:"last_error_count"`
+}
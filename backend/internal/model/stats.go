@@ -0,0 +1,43 @@
+package model
+
+// StatsBucket is one group in a GROUP BY breakdown.
+type StatsBucket struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+// DomainCount is the number of matches sharing a registrable domain.
+type DomainCount struct {
+	Domain string `json:"domain"`
+	Count  int    `json:"count"`
+	Raw    bool   `json:"raw,omitempty"`
+}
+
+// SimilarDomain is a stored matched domain close to a searched one, with
+// its pg_trgm similarity (0..1) and how many matches share it.
+type SimilarDomain struct {
+	Domain     string  `json:"domain"`
+	Similarity float64 `json:"similarity"`
+	Count      int     `json:"count"`
+}
+
+// StatsLimits caps the unbounded groupings in CertificateStats.
+type StatsLimits struct {
+	// TopN is the number of issuers returned in TopIssuers.
+	TopN int `json:"top_n"`
+	// Days is how many days back PerDay reaches.
+	Days int `json:"days"`
+}
+
+// CertificateStats summarizes stored matches for signal-quality dashboards.
+// The caps that bounded TopIssuers and PerDay are echoed in StatsLimits.
+type CertificateStats struct {
+	Total          int           `json:"total"`
+	ByMatchedField []StatsBucket `json:"by_matched_field"`
+	ByPrecert      []StatsBucket `json:"by_precert"`
+	ByIssuerClass  []StatsBucket `json:"by_issuer_class"`
+	TopIssuers     []StatsBucket `json:"top_issuers"`
+	// PerDay is keyed by UTC date (YYYY-MM-DD), newest first.
+	PerDay []StatsBucket `json:"per_day"`
+	StatsLimits
+}
@@ -0,0 +1,60 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook delivery states. Dead deliveries exhausted the webhook's
+// max_attempts and dropped ones were turned away by a full delivery queue;
+// both are kept for inspection until pruned.
+const (
+	WebhookDeliveryPending = "pending"
+	WebhookDeliverySent    = "sent"
+	WebhookDeliveryDead    = "dead"
+	WebhookDeliveryDropped = "dropped"
+)
+
+// ValidWebhookDeliveryStatus reports whether s is a delivery state.
+func ValidWebhookDeliveryStatus(s string) bool {
+	switch s {
+	case WebhookDeliveryPending, WebhookDeliverySent, WebhookDeliveryDead, WebhookDeliveryDropped:
+		return true
+	}
+	return false
+}
+
+// Webhook is a signed webhook destination. The secret is never listed; it
+// is only returned when the webhook is created.
+type Webhook struct {
+	ID          int       `json:"id"`
+	URL         string    `json:"url"`
+	Secret      string    `json:"-"`
+	MaxAttempts int       `json:"max_attempts"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+	// Pending and Dead count the webhook's deliveries in those states.
+	Pending int `json:"pending"`
+	Dead    int `json:"dead"`
+}
+
+// WebhookDelivery is one event queued for one webhook. EventID is the
+// idempotency key sent with every attempt.
+type WebhookDelivery struct {
+	ID            int64           `json:"id"`
+	WebhookID     int             `json:"webhook_id"`
+	EventID       string          `json:"event_id"`
+	Event         string          `json:"event"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	LastError     string          `json:"last_error"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	SentAt        *time.Time      `json:"sent_at"`
+	CreatedAt     time.Time       `json:"created_at"`
+
+	// Destination of a claimed delivery, filled in by Claim.
+	URL         string `json:"-"`
+	Secret      string `json:"-"`
+	MaxAttempts int    `json:"-"`
+}
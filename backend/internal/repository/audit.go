@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+type AuditRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewAuditRepository(pool *pgxpool.Pool) *AuditRepository {
+	return &AuditRepository{pool: pool}
+}
+
+func (r *AuditRepository) Create(ctx context.Context, entry *model.AuditEntry) error {
+	changes := entry.Changes
+	if len(changes) == 0 {
+		changes = []byte("{}")
+	}
+	return r.pool.QueryRow(ctx,
+		`INSERT INTO audit_log (actor, action, entity_type, entity_id, changes, request_id, client_ip)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)
+		 RETURNING id, created_at`,
+		entry.Actor, entry.Action, entry.EntityType, entry.EntityID, changes, entry.RequestID, entry.ClientIP,
+	).Scan(&entry.ID, &entry.CreatedAt)
+}
+
+func (r *AuditRepository) List(ctx context.Context, page, perPage int, filter model.AuditFilter) ([]model.AuditEntry, int, error) {
+	var conds []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if filter.Actor != "" {
+		add("actor = $%d", filter.Actor)
+	}
+	if filter.Action != "" {
+		add("action = $%d", filter.Action)
+	}
+	if filter.EntityType != "" {
+		add("entity_type = $%d", filter.EntityType)
+	}
+	if filter.RequestID != "" {
+		add("request_id = $%d", filter.RequestID)
+	}
+	if filter.Since != nil {
+		add("created_at >= $%d", *filter.Since)
+	}
+	if filter.Until != nil {
+		add("created_at < $%d", *filter.Until)
+	}
+
+	where := ""
+	if len(conds) > 0 {
+		where = "WHERE " + strings.Join(conds, " AND ")
+	}
+
+	var total int
+	if err := r.pool.QueryRow(ctx,
+		`SELECT COUNT(*) FROM audit_log `+where, args...,
+	).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * perPage
+	dataArgs := append(args, perPage, offset)
+	rows, err := r.pool.Query(ctx,
+		fmt.Sprintf(`SELECT id, created_at, actor, action, entity_type, entity_id, changes, request_id, client_ip
+		FROM audit_log %s
+		ORDER BY created_at DESC, id DESC
+		LIMIT $%d OFFSET $%d`, where, len(args)+1, len(args)+2),
+		dataArgs...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var entries []model.AuditEntry
+	for rows.Next() {
+		var e model.AuditEntry
+		if err := rows.Scan(
+			&e.ID, &e.CreatedAt, &e.Actor, &e.Action,
+			&e.EntityType, &e.EntityID, &e.Changes, &e.RequestID, &e.ClientIP,
+		); err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, total, rows.Err()
+}
@@ -2,82 +2,510 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/andres10976/SISAP-PoC/backend/internal/model"
 )
 
+// certColumns is the select list shared by every query returning a
+// model.MatchedCertificate; keep it in sync with scanCertificate.
+const certColumns = `mc.id, mc.serial_number, mc.common_name, mc.sans, mc.issuer,
+			mc.not_before, mc.not_after, mc.keyword_id, k.value, mc.matched_domain,
+			COALESCE(mc.ct_log_index, 0), mc.discovered_at, mc.status, mc.acknowledged_by,
+			mc.acknowledged_at, mc.status_note, mc.matched_field, mc.is_precert,
+			COALESCE(mc.registrable_domain, ''), mc.registrable_domain_raw,
+			cardinality(mc.sans), mc.ext_key_usages, mc.is_server_auth,
+			COALESCE(mc.first_seen_index, mc.ct_log_index, 0), COALESCE(mc.first_seen_at, mc.discovered_at),
+			COALESCE(mc.last_seen_index, mc.ct_log_index, 0), COALESCE(mc.last_seen_at, mc.discovered_at),
+			mc.historical_cert_count, mc.historical_first_seen,
+			mc.domain_registered_at, mc.domain_age_days, mc.domain_age_status,
+			mc.chain_status, mc.risk_score, mc.risk_breakdown, mc.issuer_class, mc.source,
+			mc.matched_value, mc.ct_log_url, mc.first_seen_log_url`
+
+func scanCertificate(row pgx.Row) (model.MatchedCertificate, error) {
+	var c model.MatchedCertificate
+	err := row.Scan(
+		&c.ID, &c.SerialNumber, &c.CommonName, &c.SANs, &c.Issuer,
+		&c.NotBefore, &c.NotAfter, &c.KeywordID, &c.KeywordValue,
+		&c.MatchedDomain, &c.CTLogIndex, &c.DiscoveredAt, &c.Status,
+		&c.AcknowledgedBy, &c.AcknowledgedAt, &c.StatusNote,
+		&c.MatchedField, &c.IsPrecert,
+		&c.RegistrableDomain, &c.RegistrableDomainRaw,
+		&c.SANCount, &c.ExtKeyUsages, &c.IsServerAuth,
+		&c.FirstSeenIndex, &c.FirstSeenAt, &c.LastSeenIndex, &c.LastSeenAt,
+		&c.HistoricalCertCount, &c.HistoricalFirstSeen,
+		&c.DomainRegisteredAt, &c.DomainAgeDays, &c.DomainAgeStatus,
+		&c.ChainStatus, &c.RiskScore, &c.RiskBreakdown, &c.IssuerClass, &c.Source,
+		&c.MatchedValue, &c.CTLogURL, &c.FirstSeenLogURL,
+	)
+	return c, err
+}
+
+// CertificateFilter narrows a certificate listing. Zero values mean "any".
+type CertificateFilter struct {
+	KeywordID int
+	// GroupID keeps certificates matched by a keyword in this group.
+	GroupID int
+	Status  string
+	// CNNotInSANs keeps certificates whose non-empty CN is absent from
+	// their SANs (compared case-insensitively).
+	CNNotInSANs bool
+	// SinceID keeps certificates with an id greater than it.
+	SinceID int
+	// MinSANs keeps certificates with at least this many SANs.
+	MinSANs int
+	// MaxSANs, when positive, keeps certificates with at most this many
+	// SANs.
+	MaxSANs int
+	// SAN keeps certificates listing exactly this SAN, compared
+	// case-insensitively.
+	SAN string
+	// ServerAuth, when set, keeps certificates whose IsServerAuth matches.
+	ServerAuth *bool
+	// Issuer keeps certificates with exactly this issuer DN (see
+	// DistinctIssuers).
+	Issuer string
+	// RegistrableDomain keeps certificates whose matched domain has this
+	// registrable domain (or, for hosts without one, this normalized host).
+	RegistrableDomain string
+	// MaxDomainAgeDays, when set, keeps certificates whose registrable
+	// domain was at most this many days old when first seen; matches with
+	// an unknown or not yet looked up age are excluded.
+	MaxDomainAgeDays *int
+	// ChainStatus keeps certificates with this chain validation status.
+	ChainStatus string
+	// IssuerClass keeps certificates whose issuer has this class.
+	IssuerClass string
+	// DiscoveredBefore, when non-zero, keeps certificates discovered
+	// strictly before it.
+	DiscoveredBefore time.Time
+	// Sort orders ListPaginated (one of the Sort* constants; empty means
+	// SortRisk). It does not narrow the listing.
+	Sort string
+}
+
+// Orders for CertificateFilter.Sort.
+const (
+	// SortRisk lists the highest risk score first, newest first among
+	// equal scores.
+	SortRisk = "risk"
+	// SortNewest lists the most recently discovered first.
+	SortNewest = "newest"
+)
+
+// orderBy returns the ORDER BY clause for f.Sort.
+func (f CertificateFilter) orderBy() string {
+	if f.Sort == SortNewest {
+		return "ORDER BY mc.discovered_at DESC"
+	}
+	return "ORDER BY mc.risk_score DESC, mc.discovered_at DESC"
+}
+
+// IsZero reports whether the filter keeps every certificate.
+func (f CertificateFilter) IsZero() bool {
+	where, _ := f.where(nil)
+	return where == ""
+}
+
+// where renders the filter as a SQL WHERE clause over the "mc" alias,
+// appending its arguments to args so callers can add their own after it.
+func (f CertificateFilter) where(args []any) (string, []any) {
+	var conds []string
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if f.KeywordID > 0 {
+		add("mc.keyword_id = $%d", f.KeywordID)
+	}
+	if f.GroupID > 0 {
+		add("mc.keyword_id IN (SELECT id FROM keywords WHERE group_id = $%d)", f.GroupID)
+	}
+	if f.Status != "" {
+		add("mc.status = $%d", f.Status)
+	}
+	if f.SinceID > 0 {
+		add("mc.id > $%d", f.SinceID)
+	}
+	if f.MinSANs > 0 {
+		add("cardinality(mc.sans) >= $%d", f.MinSANs)
+	}
+	if f.MaxSANs > 0 {
+		add("cardinality(mc.sans) <= $%d", f.MaxSANs)
+	}
+	if f.SAN != "" {
+		// Containment rather than = ANY so idx_matched_certificates_sans_lower
+		// can serve it.
+		add("lower_sans(mc.sans) @> ARRAY[lower($%d::text)]", f.SAN)
+	}
+	if f.ServerAuth != nil {
+		add("mc.is_server_auth = $%d", *f.ServerAuth)
+	}
+	if f.Issuer != "" {
+		add("mc.issuer = $%d", f.Issuer)
+	}
+	if f.ChainStatus != "" {
+		add("mc.chain_status = $%d", f.ChainStatus)
+	}
+	if f.IssuerClass != "" {
+		add("mc.issuer_class = $%d", f.IssuerClass)
+	}
+	if f.RegistrableDomain != "" {
+		add("mc.registrable_domain = $%d", f.RegistrableDomain)
+	}
+	if f.MaxDomainAgeDays != nil {
+		add("mc.domain_age_days <= $%d", *f.MaxDomainAgeDays)
+	}
+	if !f.DiscoveredBefore.IsZero() {
+		add("mc.discovered_at < $%d", f.DiscoveredBefore)
+	}
+	if f.CNNotInSANs {
+		conds = append(conds, `mc.common_name <> ''
+			AND NOT (lower(mc.common_name) = ANY(
+				SELECT lower(s) FROM unnest(mc.sans) AS s))`)
+	}
+	if len(conds) == 0 {
+		return "", args
+	}
+	return "WHERE " + strings.Join(conds, " AND "), args
+}
+
+// ConflictStrategy selects what Create does with a certificate that is
+// already stored for the same keyword.
+type ConflictStrategy string
+
+const (
+	// ConflictIgnore keeps the stored row untouched.
+	ConflictIgnore ConflictStrategy = "ignore"
+	// ConflictUpdate refreshes the stored row's discovered_at and
+	// ct_log_index, e.g. when a renewal reuses the serial.
+	ConflictUpdate ConflictStrategy = "update"
+)
+
+// Scorer computes a match's risk score and its breakdown; risk.Scorer.Score
+// is one.
+type Scorer func(c model.MatchedCertificate) (int, []model.RiskFactor)
+
 type CertificateRepository struct {
-	pool *pgxpool.Pool
+	pool        *pgxpool.Pool
+	conflict    ConflictStrategy
+	scorer      Scorer
+	consolidate bool
+}
+
+// CertificateOption configures optional CertificateRepository behavior.
+type CertificateOption func(*CertificateRepository)
+
+// WithConflictStrategy sets how Create handles duplicates. The default is
+// ConflictIgnore.
+func WithConflictStrategy(s ConflictStrategy) CertificateOption {
+	return func(r *CertificateRepository) {
+		r.conflict = s
+	}
 }
 
-func NewCertificateRepository(pool *pgxpool.Pool) *CertificateRepository {
-	return &CertificateRepository{pool: pool}
+// WithScorer scores matches when they are inserted and rescores them when
+// SetDomainHistory or SetDomainAge updates them. Without it every match
+// scores 0.
+func WithScorer(s Scorer) CertificateOption {
+	return func(r *CertificateRepository) {
+		r.scorer = s
+	}
 }
 
+// WithConsolidation coalesces notifications per certificate: a match for
+// a certificate whose notification is still waiting to be sent is added to
+// that notification's keywords instead of getting its own.
+func WithConsolidation(on bool) CertificateOption {
+	return func(r *CertificateRepository) {
+		r.consolidate = on
+	}
+}
+
+func NewCertificateRepository(pool *pgxpool.Pool, opts ...CertificateOption) *CertificateRepository {
+	r := &CertificateRepository{pool: pool, conflict: ConflictIgnore}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Create stores cert and, if it was not already stored for that keyword,
+// enqueues a notification for it in the same transaction. On insert, cert.ID
+// and cert.DiscoveredAt are filled in. A duplicate is skipped or, with
+// ConflictUpdate, refreshed in place; either way cert is left unchanged and
+// nothing is enqueued, since it was notified the first time.
 func (r *CertificateRepository) Create(ctx context.Context, cert *model.MatchedCertificate) error {
-	_, err := r.pool.Exec(ctx,
+	return pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+		return r.CreateTx(ctx, tx, cert)
+	})
+}
+
+// CreateTx is Create within a caller-managed transaction.
+func (r *CertificateRepository) CreateTx(ctx context.Context, tx pgx.Tx, cert *model.MatchedCertificate) error {
+	onConflict := `DO NOTHING`
+	if r.conflict == ConflictUpdate {
+		// first_seen_* are left alone: they mark the match's debut.
+		onConflict = `DO UPDATE SET discovered_at = NOW(), ct_log_index = EXCLUDED.ct_log_index,
+			ct_log_url = EXCLUDED.ct_log_url,
+			last_seen_at = NOW(), last_seen_index = EXCLUDED.ct_log_index,
+			updated_at = clock_timestamp()`
+	}
+	inserted, muted, certificateID, err := r.insert(ctx, tx, cert, onConflict)
+	if err != nil || !inserted {
+		// Refreshed by ConflictUpdate, or already stored for this keyword;
+		// either way it was notified the first time.
+		return err
+	}
+	if muted {
+		// The keyword is muted: keep the match, skip its notification.
+		return nil
+	}
+
+	notification := *cert
+	if r.consolidate {
+		merged, err := coalesce(ctx, tx, certificateID, cert)
+		if err != nil || merged {
+			return err
+		}
+		notification.Keywords = []model.CertificateKeyword{certificateKeyword(cert)}
+	}
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	return enqueueOutbox(ctx, tx, cert.ID, payload)
+}
+
+// Import stores a match found in crt.sh's history (cert.Source
+// model.SourceCrtSh, cert.FirstSeenAt when crt.sh saw it logged) unless the
+// keyword already has that serial, and reports whether it was added.
+// Imported matches are scored but never notified: they are history, not
+// new sightings.
+func (r *CertificateRepository) Import(ctx context.Context, cert *model.MatchedCertificate) (bool, error) {
+	var inserted bool
+	err := pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+		var err error
+		inserted, _, _, err = r.insert(ctx, tx, cert, `DO NOTHING`)
+		return err
+	})
+	return inserted, err
+}
+
+// queryRower is satisfied by both pgx.Tx and *pgxpool.Pool.
+type queryRower interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// insert adds cert, resolving a conflict on (serial, keyword) with
+// onConflict, and on insert fills in the columns the database sets and
+// links it to its consolidated certificate, whose id it returns. muted
+// reports whether the keyword, or its group, has a mute in force.
+func (r *CertificateRepository) insert(ctx context.Context, db queryRower, cert *model.MatchedCertificate, onConflict string) (inserted, muted bool, certificateID int, err error) {
+	score, breakdown := r.score(*cert)
+
+	// Matches from crt.sh have no log entry and bring their own first
+	// sighting.
+	var (
+		logIndex  any = cert.CTLogIndex
+		firstSeen *time.Time
+	)
+	if cert.Source == model.SourceCrtSh {
+		logIndex = nil
+		if !cert.FirstSeenAt.IsZero() {
+			firstSeen = &cert.FirstSeenAt
+		}
+	}
+
+	var (
+		id           int
+		discoveredAt time.Time
+		firstSeenAt  time.Time
+		status       string
+	)
+	err = db.QueryRow(ctx,
 		`INSERT INTO matched_certificates
 			(serial_number, common_name, sans, issuer, not_before, not_after,
-			 keyword_id, matched_domain, ct_log_index)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-		 ON CONFLICT (serial_number, keyword_id) DO NOTHING`,
+			 keyword_id, matched_domain, ct_log_index, matched_field, is_precert,
+			 registrable_domain, registrable_domain_raw, ext_key_usages, is_server_auth,
+			 first_seen_index, first_seen_at, last_seen_index, last_seen_at, chain_status,
+			 risk_score, risk_breakdown, issuer_class, source, matched_value,
+			 ct_log_url, first_seen_log_url)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NULLIF($12, ''), $13,
+			 COALESCE($14::text[], '{}'), $15, $9, COALESCE($20, NOW()), $9, COALESCE($20, NOW()),
+			 COALESCE(NULLIF($16, ''), 'not_checked'),
+			 $17, $18, COALESCE(NULLIF($19, ''), 'unknown'), COALESCE(NULLIF($21, ''), 'ctlog'), $22,
+			 $23, $23)
+		 ON CONFLICT (serial_number, keyword_id) `+onConflict+`
+		 RETURNING id, discovered_at, first_seen_at, status, xmax = 0,
+			 EXISTS (SELECT 1 FROM keywords k LEFT JOIN keyword_groups g ON g.id = k.group_id
+				 WHERE k.id = $7 AND (k.muted_until > NOW() OR g.muted_until > NOW()))`,
 		cert.SerialNumber, cert.CommonName, cert.SANs, cert.Issuer,
 		cert.NotBefore, cert.NotAfter, cert.KeywordID, cert.MatchedDomain,
-		cert.CTLogIndex,
-	)
+		logIndex, cert.MatchedField, cert.IsPrecert,
+		cert.RegistrableDomain, cert.RegistrableDomainRaw,
+		cert.ExtKeyUsages, cert.IsServerAuth, cert.ChainStatus,
+		score, breakdown, cert.IssuerClass, firstSeen, cert.Source, cert.MatchedValue,
+		cert.CTLogURL,
+	).Scan(&id, &discoveredAt, &firstSeenAt, &status, &inserted, &muted)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Already stored for this keyword.
+		return false, false, 0, nil
+	}
+	if err != nil || !inserted {
+		// Refreshed by ConflictUpdate (xmax is set on updated rows).
+		return false, false, 0, err
+	}
+	cert.ID, cert.DiscoveredAt, cert.Status = id, discoveredAt, status
+	cert.RiskScore, cert.RiskBreakdown = score, breakdown
+	cert.FirstSeenIndex, cert.FirstSeenAt = cert.CTLogIndex, firstSeenAt
+	cert.LastSeenIndex, cert.LastSeenAt = cert.CTLogIndex, firstSeenAt
+	cert.FirstSeenLogURL = cert.CTLogURL
+	if cert.Source == "" {
+		cert.Source = model.SourceCTLog
+	}
+	certificateID, err = link(ctx, db, cert)
+	if err != nil {
+		return false, false, 0, err
+	}
+	return true, muted, certificateID, nil
+}
+
+// score runs the scorer, if any, over c; the breakdown is never nil so it
+// is stored and sent as an empty array.
+func (r *CertificateRepository) score(c model.MatchedCertificate) (int, []model.RiskFactor) {
+	if r.scorer == nil {
+		return 0, []model.RiskFactor{}
+	}
+	score, breakdown := r.scorer(c)
+	if breakdown == nil {
+		breakdown = []model.RiskFactor{}
+	}
+	return score, breakdown
+}
+
+// rescore recomputes the stored risk score of match id within tx, after an
+// update to one of the fields it depends on.
+func (r *CertificateRepository) rescore(ctx context.Context, tx pgx.Tx, id int) error {
+	if r.scorer == nil {
+		return nil
+	}
+	c, err := scanCertificate(tx.QueryRow(ctx,
+		`SELECT `+certColumns+`
+		FROM matched_certificates mc
+		JOIN keywords k ON k.id = mc.keyword_id
+		WHERE mc.id = $1`, id))
+	if err != nil {
+		return err
+	}
+	score, breakdown := r.score(c)
+	_, err = tx.Exec(ctx,
+		`UPDATE matched_certificates SET risk_score = $1, risk_breakdown = $2, updated_at = clock_timestamp()
+		WHERE id = $3`,
+		score, breakdown, id)
 	return err
 }
 
-func (r *CertificateRepository) ListPaginated(ctx context.Context, page, perPage, keywordID int) ([]model.MatchedCertificate, int, error) {
+func (r *CertificateRepository) GetByID(ctx context.Context, id int) (*model.MatchedCertificate, error) {
+	c, err := scanCertificate(r.pool.QueryRow(ctx,
+		`SELECT `+certColumns+`
+		FROM matched_certificates mc
+		JOIN keywords k ON k.id = mc.keyword_id
+		WHERE mc.id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *CertificateRepository) ListPaginated(ctx context.Context, page, perPage int, filter CertificateFilter) ([]model.MatchedCertificate, int, error) {
 	offset := (page - 1) * perPage
+	where, args := filter.where(nil)
 
-	// Count total
 	var total int
-	if keywordID > 0 {
-		err := r.pool.QueryRow(ctx,
-			`SELECT COUNT(*) FROM matched_certificates WHERE keyword_id = $1`,
-			keywordID,
-		).Scan(&total)
-		if err != nil {
-			return nil, 0, err
-		}
-	} else {
-		err := r.pool.QueryRow(ctx,
-			`SELECT COUNT(*) FROM matched_certificates`,
-		).Scan(&total)
+	if err := r.pool.QueryRow(ctx,
+		`SELECT COUNT(*) FROM matched_certificates mc `+where, args...,
+	).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	dataQuery := fmt.Sprintf(`SELECT `+certColumns+`
+		FROM matched_certificates mc
+		JOIN keywords k ON k.id = mc.keyword_id
+		%s
+		%s
+		LIMIT $%d OFFSET $%d`, where, filter.orderBy(), len(args)+1, len(args)+2)
+
+	rows, err := r.pool.Query(ctx, dataQuery, append(args, perPage, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var certs []model.MatchedCertificate
+	for rows.Next() {
+		c, err := scanCertificate(rows)
 		if err != nil {
 			return nil, 0, err
 		}
+		certs = append(certs, c)
 	}
+	return certs, total, rows.Err()
+}
 
-	// Fetch page
-	var dataQuery string
-	var dataArgs []any
+// Version returns an opaque token for the rows matching filter that changes
+// whenever a row is added, removed or updated in place (every write path
+// bumps updated_at). It reads only aggregates, so handlers can use it for
+// ETags without loading rows.
+func (r *CertificateRepository) Version(ctx context.Context, filter CertificateFilter) (string, error) {
+	where, args := filter.where(nil)
+	var maxID, count int
+	var lastUpdated *time.Time
+	if err := r.pool.QueryRow(ctx,
+		`SELECT COALESCE(MAX(mc.id), 0), COUNT(*), MAX(mc.updated_at)
+		FROM matched_certificates mc `+where, args...,
+	).Scan(&maxID, &count, &lastUpdated); err != nil {
+		return "", err
+	}
+	v := fmt.Sprintf("%d-%d", maxID, count)
+	if lastUpdated != nil {
+		v += fmt.Sprintf("-%d", lastUpdated.UnixMicro())
+	}
+	return v, nil
+}
 
-	if keywordID > 0 {
-		dataQuery = `SELECT mc.id, mc.serial_number, mc.common_name, mc.sans, mc.issuer,
-			mc.not_before, mc.not_after, mc.keyword_id, k.value, mc.matched_domain,
-			mc.ct_log_index, mc.discovered_at
-		FROM matched_certificates mc
-		JOIN keywords k ON k.id = mc.keyword_id
-		WHERE mc.keyword_id = $1
-		ORDER BY mc.discovered_at DESC
-		LIMIT $2 OFFSET $3`
-		dataArgs = []any{keywordID, perPage, offset}
-	} else {
-		dataQuery = `SELECT mc.id, mc.serial_number, mc.common_name, mc.sans, mc.issuer,
-			mc.not_before, mc.not_after, mc.keyword_id, k.value, mc.matched_domain,
-			mc.ct_log_index, mc.discovered_at
+// ListSince returns up to limit certificates matching filter in ascending id
+// order, together with the total number that match. It is meant for pollers
+// that pass the last id they have seen as filter.SinceID.
+func (r *CertificateRepository) ListSince(ctx context.Context, limit int, filter CertificateFilter) ([]model.MatchedCertificate, int, error) {
+	where, args := filter.where(nil)
+
+	var total int
+	if err := r.pool.QueryRow(ctx,
+		`SELECT COUNT(*) FROM matched_certificates mc `+where, args...,
+	).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	dataQuery := fmt.Sprintf(`SELECT `+certColumns+`
 		FROM matched_certificates mc
 		JOIN keywords k ON k.id = mc.keyword_id
-		ORDER BY mc.discovered_at DESC
-		LIMIT $1 OFFSET $2`
-		dataArgs = []any{perPage, offset}
-	}
+		%s
+		ORDER BY mc.id ASC
+		LIMIT $%d`, where, len(args)+1)
 
-	rows, err := r.pool.Query(ctx, dataQuery, dataArgs...)
+	rows, err := r.pool.Query(ctx, dataQuery, append(args, limit)...)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -85,12 +513,8 @@ func (r *CertificateRepository) ListPaginated(ctx context.Context, page, perPage
 
 	var certs []model.MatchedCertificate
 	for rows.Next() {
-		var c model.MatchedCertificate
-		if err := rows.Scan(
-			&c.ID, &c.SerialNumber, &c.CommonName, &c.SANs, &c.Issuer,
-			&c.NotBefore, &c.NotAfter, &c.KeywordID, &c.KeywordValue,
-			&c.MatchedDomain, &c.CTLogIndex, &c.DiscoveredAt,
-		); err != nil {
+		c, err := scanCertificate(rows)
+		if err != nil {
 			return nil, 0, err
 		}
 		certs = append(certs, c)
@@ -98,31 +522,271 @@ func (r *CertificateRepository) ListPaginated(ctx context.Context, page, perPage
 	return certs, total, rows.Err()
 }
 
-func (r *CertificateRepository) ExportAll(ctx context.Context) ([]model.MatchedCertificate, error) {
+// UpdateStatus sets the triage status of a match. Moving back to "new"
+// clears the acknowledgement; re-applying the current status keeps the
+// original acknowledged_at so repeated writes are idempotent.
+func (r *CertificateRepository) UpdateStatus(ctx context.Context, id int, status, actor, note string) error {
+	tag, err := r.pool.Exec(ctx,
+		`UPDATE matched_certificates SET
+			acknowledged_by = CASE WHEN $2 = 'new' THEN '' ELSE $3 END,
+			acknowledged_at = CASE
+				WHEN $2 = 'new' THEN NULL
+				WHEN status = $2 AND acknowledged_at IS NOT NULL THEN acknowledged_at
+				ELSE NOW()
+			END,
+			status_note = $4,
+			status = $2,
+			updated_at = clock_timestamp()
+		WHERE id = $1`,
+		id, status, actor, note,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteWhere deletes the matches the filter keeps, in one transaction, and
+// returns how many were removed. Their pending notifications go with them
+// (ON DELETE CASCADE). An empty filter deletes every match; callers guard
+// against that.
+func (r *CertificateRepository) DeleteWhere(ctx context.Context, filter CertificateFilter) (int64, error) {
+	where, args := filter.where(nil)
+	var deleted int64
+	err := pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `DELETE FROM matched_certificates mc `+where, args...)
+		if err != nil {
+			return err
+		}
+		deleted = tag.RowsAffected()
+		// Drop the certificates the purge left without a match.
+		_, err = tx.Exec(ctx,
+			`DELETE FROM certificates c
+			WHERE NOT EXISTS (SELECT 1 FROM certificate_matches cm WHERE cm.certificate_id = c.id)`)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+// ExportEach calls fn for each of the 10000 most recent matches filter
+// keeps, newest first, while the rows are read, so an export never holds
+// them all in memory. An error from fn stops the iteration and is returned.
+func (r *CertificateRepository) ExportEach(ctx context.Context, filter CertificateFilter, fn func(model.MatchedCertificate) error) error {
+	where, args := filter.where(nil)
 	rows, err := r.pool.Query(ctx,
-		`SELECT mc.id, mc.serial_number, mc.common_name, mc.sans, mc.issuer,
-			mc.not_before, mc.not_after, mc.keyword_id, k.value, mc.matched_domain,
-			mc.ct_log_index, mc.discovered_at
+		`SELECT `+certColumns+`
 		FROM matched_certificates mc
 		JOIN keywords k ON k.id = mc.keyword_id
+		`+where+`
 		ORDER BY mc.discovered_at DESC
-		LIMIT 10000`)
+		LIMIT 10000`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		c, err := scanCertificate(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ListForRange returns the matches stored at indices start..end of the CT
+// log at logURL (or of an unrecorded log), plus any whose serial number is
+// in serials, ordered by index. verify compares them with a rescan of the
+// range.
+func (r *CertificateRepository) ListForRange(ctx context.Context, logURL string, start, end int64, serials []string) ([]model.MatchedCertificate, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT `+certColumns+`
+		FROM matched_certificates mc
+		JOIN keywords k ON k.id = mc.keyword_id
+		WHERE (mc.ct_log_url IN ($1, '') AND mc.ct_log_index BETWEEN $2 AND $3)
+			OR mc.serial_number = ANY($4)
+		ORDER BY mc.ct_log_index, mc.id`, logURL, start, end, serials)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var certs []model.MatchedCertificate
+	certs := []model.MatchedCertificate{}
 	for rows.Next() {
-		var c model.MatchedCertificate
-		if err := rows.Scan(
-			&c.ID, &c.SerialNumber, &c.CommonName, &c.SANs, &c.Issuer,
-			&c.NotBefore, &c.NotAfter, &c.KeywordID, &c.KeywordValue,
-			&c.MatchedDomain, &c.CTLogIndex, &c.DiscoveredAt,
-		); err != nil {
+		c, err := scanCertificate(rows)
+		if err != nil {
 			return nil, err
 		}
 		certs = append(certs, c)
 	}
 	return certs, rows.Err()
 }
+
+// DistinctIssuers returns up to limit issuer DNs present in the matches,
+// the most frequent first, for populating an issuer filter.
+func (r *CertificateRepository) DistinctIssuers(ctx context.Context, limit int) ([]string, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT issuer
+		FROM matched_certificates
+		WHERE issuer <> ''
+		GROUP BY issuer
+		ORDER BY COUNT(*) DESC, issuer
+		LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	issuers := []string{}
+	for rows.Next() {
+		var issuer string
+		if err := rows.Scan(&issuer); err != nil {
+			return nil, err
+		}
+		issuers = append(issuers, issuer)
+	}
+	return issuers, rows.Err()
+}
+
+// maxSimilarDomains caps SimilarDomains; near-duplicates past it are noise.
+const maxSimilarDomains = 100
+
+// SimilarDomains returns the distinct matched domains whose pg_trgm
+// similarity to domain exceeds threshold, closest first. The threshold is
+// also set as pg_trgm.similarity_threshold for the transaction so the %
+// operator can use the trigram index.
+func (r *CertificateRepository) SimilarDomains(ctx context.Context, domain string, threshold float64) ([]model.SimilarDomain, error) {
+	similar := []model.SimilarDomain{}
+	err := pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `SELECT set_config('pg_trgm.similarity_threshold', $1, true)`,
+			strconv.FormatFloat(threshold, 'f', -1, 64)); err != nil {
+			return err
+		}
+		rows, err := tx.Query(ctx,
+			`SELECT matched_domain, similarity(matched_domain, $1) AS sim, COUNT(*)
+			FROM matched_certificates
+			WHERE matched_domain % $1 AND similarity(matched_domain, $1) > $2
+			GROUP BY matched_domain
+			ORDER BY sim DESC, matched_domain
+			LIMIT $3`, domain, threshold, maxSimilarDomains)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var d model.SimilarDomain
+			if err := rows.Scan(&d.Domain, &d.Similarity, &d.Count); err != nil {
+				return err
+			}
+			similar = append(similar, d)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return similar, nil
+}
+
+// PendingRegistrableDomains returns up to limit matched domains, keyed by
+// row ID, whose registrable domain has not been computed yet.
+func (r *CertificateRepository) PendingRegistrableDomains(ctx context.Context, limit int) (map[int]string, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT id, matched_domain FROM matched_certificates
+		WHERE registrable_domain IS NULL
+		ORDER BY id
+		LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pending := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var matched string
+		if err := rows.Scan(&id, &matched); err != nil {
+			return nil, err
+		}
+		pending[id] = matched
+	}
+	return pending, rows.Err()
+}
+
+func (r *CertificateRepository) SetRegistrableDomain(ctx context.Context, id int, domain string, raw bool) error {
+	_, err := r.pool.Exec(ctx,
+		`UPDATE matched_certificates
+		SET registrable_domain = $1, registrable_domain_raw = $2, updated_at = clock_timestamp()
+		WHERE id = $3`,
+		domain, raw, id,
+	)
+	return err
+}
+
+// SetDomainHistory records the crt.sh history of a match's registrable
+// domain and rescores it. A zero firstSeen is stored as NULL.
+func (r *CertificateRepository) SetDomainHistory(ctx context.Context, id, count int, firstSeen time.Time) error {
+	var first *time.Time
+	if !firstSeen.IsZero() {
+		first = &firstSeen
+	}
+	return pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx,
+			`UPDATE matched_certificates
+			SET historical_cert_count = $1, historical_first_seen = $2, updated_at = clock_timestamp()
+			WHERE id = $3`,
+			count, first, id,
+		)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrNotFound
+		}
+		return r.rescore(ctx, tx, id)
+	})
+}
+
+// SetDomainAge records the RDAP registration date of a match's registrable
+// domain and its age in days when the match was first seen (never below
+// zero), and rescores it. A zero registeredAt marks the age unknown.
+func (r *CertificateRepository) SetDomainAge(ctx context.Context, id int, registeredAt time.Time) error {
+	var registered *time.Time
+	status := model.DomainAgeUnknown
+	if !registeredAt.IsZero() {
+		registered, status = &registeredAt, model.DomainAgeKnown
+	}
+	return pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx,
+			`UPDATE matched_certificates
+			SET domain_registered_at = $1::timestamptz,
+				domain_age_days = CASE WHEN $1::timestamptz IS NULL THEN NULL
+					ELSE GREATEST(0, floor(extract(epoch FROM
+						COALESCE(first_seen_at, discovered_at) - $1::timestamptz) / 86400))::int
+					END,
+				domain_age_status = $2,
+				updated_at = clock_timestamp()
+			WHERE id = $3`,
+			registered, status, id,
+		)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrNotFound
+		}
+		return r.rescore(ctx, tx, id)
+	})
+}
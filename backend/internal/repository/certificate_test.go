@@ -0,0 +1,872 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+func TestCertificateUpdateStatus(t *testing.T) {
+	pool := testPool(t)
+	repo := NewCertificateRepository(pool)
+	ctx := context.Background()
+
+	kwID := seedKeyword(t, pool, "example")
+	id := seedCert(t, pool, kwID, "aa01", nil)
+
+	if err := repo.UpdateStatus(ctx, id, model.CertStatusAcknowledged, "alice", "looking"); err != nil {
+		t.Fatalf("UpdateStatus() error = %v", err)
+	}
+	got, err := repo.GetByID(ctx, id)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if got.Status != model.CertStatusAcknowledged || got.AcknowledgedBy != "alice" || got.StatusNote != "looking" {
+		t.Errorf("got status=%q by=%q note=%q", got.Status, got.AcknowledgedBy, got.StatusNote)
+	}
+	if got.AcknowledgedAt == nil {
+		t.Fatal("AcknowledgedAt not set")
+	}
+	firstAck := *got.AcknowledgedAt
+
+	// Same status again is a no-op for the timestamp.
+	if err := repo.UpdateStatus(ctx, id, model.CertStatusAcknowledged, "alice", "looking"); err != nil {
+		t.Fatalf("repeat UpdateStatus() error = %v", err)
+	}
+	got, _ = repo.GetByID(ctx, id)
+	if got.AcknowledgedAt == nil || !got.AcknowledgedAt.Equal(firstAck) {
+		t.Errorf("AcknowledgedAt = %v, want unchanged %v", got.AcknowledgedAt, firstAck)
+	}
+
+	// Back to new clears the acknowledgement.
+	if err := repo.UpdateStatus(ctx, id, model.CertStatusNew, "bob", ""); err != nil {
+		t.Fatalf("reset UpdateStatus() error = %v", err)
+	}
+	got, _ = repo.GetByID(ctx, id)
+	if got.AcknowledgedAt != nil || got.AcknowledgedBy != "" {
+		t.Errorf("after reset: by=%q at=%v, want cleared", got.AcknowledgedBy, got.AcknowledgedAt)
+	}
+}
+
+func TestCertificateCreate_ConflictStrategy(t *testing.T) {
+	for _, tc := range []struct {
+		strategy  ConflictStrategy
+		wantIndex int64
+	}{
+		{ConflictIgnore, 1},
+		{ConflictUpdate, 2},
+	} {
+		t.Run(string(tc.strategy), func(t *testing.T) {
+			pool := testPool(t)
+			repo := NewCertificateRepository(pool, WithConflictStrategy(tc.strategy))
+			ctx := context.Background()
+
+			kwID := seedKeyword(t, pool, "example")
+			id := seedCert(t, pool, kwID, "cf01", nil)
+			first, err := repo.GetByID(ctx, id)
+			if err != nil {
+				t.Fatalf("GetByID() error = %v", err)
+			}
+
+			dup := *first
+			dup.ID = 0
+			dup.CTLogIndex = 2
+			if err := repo.Create(ctx, &dup); err != nil {
+				t.Fatalf("duplicate Create() error = %v", err)
+			}
+			if dup.ID != 0 {
+				t.Errorf("duplicate Create() set ID = %d, want 0", dup.ID)
+			}
+
+			got, err := repo.GetByID(ctx, id)
+			if err != nil {
+				t.Fatalf("GetByID() error = %v", err)
+			}
+			if got.CTLogIndex != tc.wantIndex {
+				t.Errorf("ct_log_index = %d, want %d", got.CTLogIndex, tc.wantIndex)
+			}
+			if refreshed := got.DiscoveredAt.After(first.DiscoveredAt); refreshed != (tc.strategy == ConflictUpdate) {
+				t.Errorf("discovered_at %v -> %v, refreshed = %v", first.DiscoveredAt, got.DiscoveredAt, refreshed)
+			}
+
+			var rows, outbox int
+			if err := pool.QueryRow(ctx,
+				`SELECT (SELECT COUNT(*) FROM matched_certificates), (SELECT COUNT(*) FROM notification_outbox)`,
+			).Scan(&rows, &outbox); err != nil {
+				t.Fatalf("count rows: %v", err)
+			}
+			if rows != 1 || outbox != 1 {
+				t.Errorf("rows = %d, outbox = %d; want 1 and 1", rows, outbox)
+			}
+		})
+	}
+}
+
+func TestCertificateCreate_FirstAndLastSeen(t *testing.T) {
+	pool := testPool(t)
+	repo := NewCertificateRepository(pool, WithConflictStrategy(ConflictUpdate))
+	ctx := context.Background()
+
+	kwID := seedKeyword(t, pool, "example")
+	id := seedCert(t, pool, kwID, "seen01", func(c *model.MatchedCertificate) { c.CTLogIndex = 10 })
+	first, err := repo.GetByID(ctx, id)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if first.FirstSeenIndex != 10 || first.LastSeenIndex != 10 || !first.FirstSeenAt.Equal(first.LastSeenAt) {
+		t.Errorf("new match seen %d@%v .. %d@%v, want first = last = 10",
+			first.FirstSeenIndex, first.FirstSeenAt, first.LastSeenIndex, first.LastSeenAt)
+	}
+
+	again := *first
+	again.ID = 0
+	again.CTLogIndex = 25
+	if err := repo.Create(ctx, &again); err != nil {
+		t.Fatalf("second Create() error = %v", err)
+	}
+
+	got, err := repo.GetByID(ctx, id)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if got.FirstSeenIndex != 10 || !got.FirstSeenAt.Equal(first.FirstSeenAt) {
+		t.Errorf("first seen = %d@%v, want unchanged 10@%v", got.FirstSeenIndex, got.FirstSeenAt, first.FirstSeenAt)
+	}
+	if got.LastSeenIndex != 25 || !got.LastSeenAt.After(first.LastSeenAt) {
+		t.Errorf("last seen = %d@%v, want 25 after %v", got.LastSeenIndex, got.LastSeenAt, first.LastSeenAt)
+	}
+}
+
+func TestCertificateImport(t *testing.T) {
+	pool := testPool(t)
+	repo := NewCertificateRepository(pool)
+	ctx := context.Background()
+
+	kwID := seedKeyword(t, pool, "example")
+	seedCert(t, pool, kwID, "live01", nil)
+	logged := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	cert := &model.MatchedCertificate{
+		SerialNumber:  "old01",
+		CommonName:    "old01.example.com",
+		SANs:          []string{"old01.example.com"},
+		Issuer:        "R3",
+		NotBefore:     logged,
+		NotAfter:      logged.Add(90 * 24 * time.Hour),
+		KeywordID:     kwID,
+		MatchedDomain: "old01.example.com",
+		MatchedField:  model.MatchFieldCN,
+		IsServerAuth:  true,
+		FirstSeenAt:   logged,
+		Source:        model.SourceCrtSh,
+	}
+	inserted, err := repo.Import(ctx, cert)
+	if err != nil || !inserted {
+		t.Fatalf("Import() = %v, %v, want inserted", inserted, err)
+	}
+
+	got, err := repo.GetByID(ctx, cert.ID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if got.Source != model.SourceCrtSh || got.CTLogIndex != 0 || got.FirstSeenIndex != 0 {
+		t.Errorf("imported = %q at %d/%d, want crtsh with no log index", got.Source, got.CTLogIndex, got.FirstSeenIndex)
+	}
+	if !got.FirstSeenAt.Equal(logged) || !got.LastSeenAt.Equal(logged) {
+		t.Errorf("seen %v .. %v, want the crt.sh log time %v", got.FirstSeenAt, got.LastSeenAt, logged)
+	}
+
+	// A serial the monitor already stored is a duplicate, and stays as it was.
+	dup := *cert
+	dup.ID = 0
+	dup.SerialNumber = "live01"
+	if inserted, err := repo.Import(ctx, &dup); err != nil || inserted {
+		t.Errorf("Import(duplicate) = %v, %v, want not inserted", inserted, err)
+	}
+
+	var sources []string
+	rows, err := pool.Query(ctx, `SELECT source FROM matched_certificates ORDER BY serial_number`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			t.Fatal(err)
+		}
+		sources = append(sources, s)
+	}
+	if len(sources) != 2 || sources[0] != model.SourceCTLog || sources[1] != model.SourceCrtSh {
+		t.Errorf("sources = %v, want [ctlog crtsh]", sources)
+	}
+
+	// Imports are history, not news: nothing is queued for notification.
+	var queued int
+	if err := pool.QueryRow(ctx,
+		`SELECT COUNT(*) FROM notification_outbox o JOIN matched_certificates mc ON mc.id = o.certificate_id
+		 WHERE mc.source = 'crtsh'`).Scan(&queued); err != nil {
+		t.Fatal(err)
+	}
+	if queued != 0 {
+		t.Errorf("outbox rows for imports = %d, want 0", queued)
+	}
+}
+
+func TestCertificateUpdateStatus_NotFound(t *testing.T) {
+	pool := testPool(t)
+	repo := NewCertificateRepository(pool)
+
+	err := repo.UpdateStatus(context.Background(), 9999, model.CertStatusResolved, "alice", "")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateStatus() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestCertificateUpdateStatus_Concurrent(t *testing.T) {
+	pool := testPool(t)
+	repo := NewCertificateRepository(pool)
+	ctx := context.Background()
+
+	kwID := seedKeyword(t, pool, "example")
+	id := seedCert(t, pool, kwID, "aa02", nil)
+
+	statuses := []string{
+		model.CertStatusAcknowledged, model.CertStatusFalsePositive, model.CertStatusResolved,
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 30)
+	for i := range 30 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status := statuses[i%len(statuses)]
+			errs <- repo.UpdateStatus(ctx, id, status, "actor-"+status, fmt.Sprintf("note %d", i))
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("concurrent UpdateStatus() error = %v", err)
+		}
+	}
+
+	// Each UPDATE is atomic, so the row must reflect exactly one writer.
+	got, err := repo.GetByID(ctx, id)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if got.AcknowledgedBy != "actor-"+got.Status {
+		t.Errorf("row mixes writers: status=%q acknowledged_by=%q", got.Status, got.AcknowledgedBy)
+	}
+}
+
+func TestCertificateListPaginated_StatusFilter(t *testing.T) {
+	pool := testPool(t)
+	repo := NewCertificateRepository(pool)
+	ctx := context.Background()
+
+	kwID := seedKeyword(t, pool, "example")
+	ackID := seedCert(t, pool, kwID, "aa03", nil)
+	seedCert(t, pool, kwID, "aa04", nil)
+	if err := repo.UpdateStatus(ctx, ackID, model.CertStatusAcknowledged, "alice", ""); err != nil {
+		t.Fatalf("UpdateStatus() error = %v", err)
+	}
+
+	certs, total, err := repo.ListPaginated(ctx, 1, 20, CertificateFilter{Status: model.CertStatusNew})
+	if err != nil {
+		t.Fatalf("ListPaginated() error = %v", err)
+	}
+	if total != 1 || len(certs) != 1 || certs[0].SerialNumber != "aa04" {
+		t.Errorf("got total=%d certs=%v, want only aa04", total, certs)
+	}
+}
+
+func TestCertificateListPaginated_CNNotInSANs(t *testing.T) {
+	pool := testPool(t)
+	repo := NewCertificateRepository(pool)
+	ctx := context.Background()
+
+	kwID := seedKeyword(t, pool, "example")
+	seedCert(t, pool, kwID, "in-sans", func(c *model.MatchedCertificate) {
+		c.CommonName = "Login.Example.com"
+		c.SANs = []string{"login.example.com", "www.example.com"}
+	})
+	seedCert(t, pool, kwID, "not-in-sans", func(c *model.MatchedCertificate) {
+		c.CommonName = "paypal.example.com"
+		c.SANs = []string{"www.example.com"}
+	})
+	seedCert(t, pool, kwID, "no-sans", func(c *model.MatchedCertificate) {
+		c.CommonName = "odd.example.com"
+		c.SANs = []string{}
+	})
+	seedCert(t, pool, kwID, "empty-cn", func(c *model.MatchedCertificate) {
+		c.CommonName = ""
+		c.SANs = []string{"www.example.com"}
+	})
+
+	certs, total, err := repo.ListPaginated(ctx, 1, 20, CertificateFilter{CNNotInSANs: true})
+	if err != nil {
+		t.Fatalf("ListPaginated() error = %v", err)
+	}
+	got := map[string]bool{}
+	for _, c := range certs {
+		got[c.SerialNumber] = true
+	}
+	if total != 2 || !got["not-in-sans"] || !got["no-sans"] {
+		t.Errorf("got total=%d serials=%v, want not-in-sans and no-sans", total, got)
+	}
+}
+
+func TestCertificateListPaginated_MinSANs(t *testing.T) {
+	pool := testPool(t)
+	repo := NewCertificateRepository(pool)
+	ctx := context.Background()
+
+	kwID := seedKeyword(t, pool, "example")
+	for _, n := range []int{0, 1, 49, 50, 120} {
+		seedCert(t, pool, kwID, fmt.Sprintf("sans-%d", n), func(c *model.MatchedCertificate) {
+			c.SANs = make([]string, n)
+			for i := range c.SANs {
+				c.SANs[i] = fmt.Sprintf("h%d.example.com", i)
+			}
+		})
+	}
+
+	certs, total, err := repo.ListPaginated(ctx, 1, 20, CertificateFilter{MinSANs: 50})
+	if err != nil {
+		t.Fatalf("ListPaginated() error = %v", err)
+	}
+	got := map[string]int{}
+	for _, c := range certs {
+		got[c.SerialNumber] = c.SANCount
+	}
+	if total != 2 || got["sans-50"] != 50 || got["sans-120"] != 120 {
+		t.Errorf("got total=%d san counts=%v, want sans-50 and sans-120", total, got)
+	}
+}
+
+func TestCertificateListPaginated_SANFilters(t *testing.T) {
+	pool := testPool(t)
+	repo := NewCertificateRepository(pool)
+	ctx := context.Background()
+
+	kwID := seedKeyword(t, pool, "example")
+	for serial, sans := range map[string][]string{
+		"single":  {"Login.Example.com"},
+		"pair":    {"login.example.com", "www.example.com"},
+		"other":   {"shop.example.com"},
+		"no-sans": {},
+		"many":    {"a.example.com", "b.example.com", "c.example.com", "login.example.com"},
+	} {
+		seedCert(t, pool, kwID, serial, func(c *model.MatchedCertificate) { c.SANs = sans })
+	}
+
+	tests := []struct {
+		filter CertificateFilter
+		want   []string
+	}{
+		{CertificateFilter{SAN: "LOGIN.example.com"}, []string{"many", "pair", "single"}},
+		{CertificateFilter{MaxSANs: 1}, []string{"no-sans", "other", "single"}},
+		{CertificateFilter{MinSANs: 2, MaxSANs: 3}, []string{"pair"}},
+		{CertificateFilter{SAN: "login.example.com", MaxSANs: 1}, []string{"single"}},
+		{CertificateFilter{SAN: "example.com"}, nil},
+	}
+	for _, tt := range tests {
+		certs, total, err := repo.ListPaginated(ctx, 1, 20, tt.filter)
+		if err != nil {
+			t.Fatalf("ListPaginated(%+v) error = %v", tt.filter, err)
+		}
+		var got []string
+		for _, c := range certs {
+			got = append(got, c.SerialNumber)
+		}
+		slices.Sort(got)
+		if total != len(tt.want) || !slices.Equal(got, tt.want) {
+			t.Errorf("%+v: got total=%d serials=%v, want %v", tt.filter, total, got, tt.want)
+		}
+	}
+}
+
+func TestCertificateListPaginated_ServerAuth(t *testing.T) {
+	pool := testPool(t)
+	repo := NewCertificateRepository(pool)
+	ctx := context.Background()
+
+	kwID := seedKeyword(t, pool, "example")
+	seedCert(t, pool, kwID, "server", func(c *model.MatchedCertificate) {
+		c.ExtKeyUsages = []string{"server_auth", "client_auth"}
+	})
+	seedCert(t, pool, kwID, "client", func(c *model.MatchedCertificate) {
+		c.ExtKeyUsages = []string{"client_auth"}
+		c.IsServerAuth = false
+	})
+
+	serverAuth := false
+	certs, total, err := repo.ListPaginated(ctx, 1, 20, CertificateFilter{ServerAuth: &serverAuth})
+	if err != nil {
+		t.Fatalf("ListPaginated() error = %v", err)
+	}
+	if total != 1 || len(certs) != 1 || certs[0].SerialNumber != "client" {
+		t.Fatalf("got total=%d certs=%v, want only the client cert", total, certs)
+	}
+	if got := certs[0].ExtKeyUsages; len(got) != 1 || got[0] != "client_auth" {
+		t.Errorf("ExtKeyUsages = %v, want [client_auth]", got)
+	}
+}
+
+func TestCertificateListSince(t *testing.T) {
+	pool := testPool(t)
+	repo := NewCertificateRepository(pool)
+	ctx := context.Background()
+
+	kwA := seedKeyword(t, pool, "example")
+	kwB := seedKeyword(t, pool, "paypal")
+	first := seedCert(t, pool, kwA, "s1", nil)
+	second := seedCert(t, pool, kwB, "s2", nil)
+	third := seedCert(t, pool, kwA, "s3", nil)
+	fourth := seedCert(t, pool, kwA, "s4", nil)
+
+	certs, total, err := repo.ListSince(ctx, 2, CertificateFilter{SinceID: first})
+	if err != nil {
+		t.Fatalf("ListSince() error = %v", err)
+	}
+	if total != 3 {
+		t.Errorf("total = %d, want 3", total)
+	}
+	if len(certs) != 2 || certs[0].ID != second || certs[1].ID != third {
+		t.Errorf("got ids %v, want [%d %d] in ascending order", certIDs(certs), second, third)
+	}
+
+	certs, total, err = repo.ListSince(ctx, 20, CertificateFilter{SinceID: first, KeywordID: kwA})
+	if err != nil {
+		t.Fatalf("ListSince() with keyword error = %v", err)
+	}
+	if total != 2 || len(certs) != 2 || certs[0].ID != third || certs[1].ID != fourth {
+		t.Errorf("got total=%d ids %v, want [%d %d]", total, certIDs(certs), third, fourth)
+	}
+
+	certs, total, err = repo.ListSince(ctx, 20, CertificateFilter{SinceID: fourth})
+	if err != nil {
+		t.Fatalf("ListSince() past newest error = %v", err)
+	}
+	if total != 0 || len(certs) != 0 {
+		t.Errorf("got total=%d ids %v, want none", total, certIDs(certs))
+	}
+}
+
+func TestCertificateListForRange(t *testing.T) {
+	pool := testPool(t)
+	repo := NewCertificateRepository(pool)
+	kw := seedKeyword(t, pool, "example")
+	const logURL = "https://log.example/2026h2"
+	at := func(i int64) func(*model.MatchedCertificate) {
+		return func(c *model.MatchedCertificate) { c.CTLogIndex, c.CTLogURL = i, logURL }
+	}
+	seedCert(t, pool, kw, "before", at(5))
+	first := seedCert(t, pool, kw, "first", at(10))
+	last := seedCert(t, pool, kw, "last", at(19))
+	seedCert(t, pool, kw, "after", at(20))
+	precert := seedCert(t, pool, kw, "precert", at(3))
+	// The same index in another log is another entry.
+	seedCert(t, pool, kw, "elsewhere", func(c *model.MatchedCertificate) {
+		c.CTLogIndex, c.CTLogURL = 15, "https://log.example/2026h1"
+	})
+
+	certs, err := repo.ListForRange(context.Background(), logURL, 10, 19, []string{"precert", "unknown"})
+	if err != nil {
+		t.Fatalf("ListForRange() error = %v", err)
+	}
+	if got := certIDs(certs); !slices.Equal(got, []int{precert, first, last}) {
+		t.Errorf("ids = %v, want [%d %d %d] (the serial match, then the range)", got, precert, first, last)
+	}
+}
+
+func TestCertificateListPaginated_ChainStatus(t *testing.T) {
+	pool := testPool(t)
+	repo := NewCertificateRepository(pool)
+	ctx := context.Background()
+	kw := seedKeyword(t, pool, "example")
+	seedCert(t, pool, kw, "unchecked", nil)
+	orphan := seedCert(t, pool, kw, "orphan", func(c *model.MatchedCertificate) {
+		c.ChainStatus = model.ChainUnknownIssuer
+	})
+
+	certs, total, err := repo.ListPaginated(ctx, 1, 20, CertificateFilter{ChainStatus: model.ChainUnknownIssuer})
+	if err != nil {
+		t.Fatalf("ListPaginated() error = %v", err)
+	}
+	if total != 1 || len(certs) != 1 || certs[0].ID != orphan {
+		t.Errorf("unknown_issuer listed %d (total %d), want only the orphan", len(certs), total)
+	}
+	certs, _, err = repo.ListPaginated(ctx, 1, 20, CertificateFilter{ChainStatus: model.ChainNotChecked})
+	if err != nil {
+		t.Fatalf("ListPaginated() error = %v", err)
+	}
+	if len(certs) != 1 || certs[0].SerialNumber != "unchecked" {
+		t.Errorf("not_checked = %+v, want the match stored without a status", certs)
+	}
+}
+
+func TestCertificateListPaginated_RegistrableDomain(t *testing.T) {
+	pool := testPool(t)
+	repo := NewCertificateRepository(pool)
+	ctx := context.Background()
+	kw := seedKeyword(t, pool, "example")
+	for _, m := range []struct{ serial, matched, registrable string }{
+		{"wild", "*.example.co.uk", "example.co.uk"},
+		{"sub", "login.example.co.uk", "example.co.uk"},
+		{"peer", "example-login.co.uk", "example-login.co.uk"},
+	} {
+		seedCert(t, pool, kw, m.serial, func(c *model.MatchedCertificate) {
+			c.MatchedDomain, c.RegistrableDomain = m.matched, m.registrable
+		})
+	}
+
+	certs, total, err := repo.ListPaginated(ctx, 1, 20, CertificateFilter{RegistrableDomain: "example.co.uk"})
+	if err != nil {
+		t.Fatalf("ListPaginated() error = %v", err)
+	}
+	if total != 2 || len(certs) != 2 {
+		t.Errorf("example.co.uk listed %d (total %d), want the wildcard and the subdomain", len(certs), total)
+	}
+}
+
+func TestCertificateDeleteWhere(t *testing.T) {
+	pool := testPool(t)
+	repo := NewCertificateRepository(pool)
+	ctx := context.Background()
+	kw := seedKeyword(t, pool, "example")
+	other := seedKeyword(t, pool, "other")
+	stale := seedCert(t, pool, kw, "stale", nil)
+	seedCert(t, pool, kw, "recent", nil)
+	seedCert(t, pool, other, "elsewhere", nil)
+	cutoff := time.Now().Add(-time.Hour)
+	if _, err := pool.Exec(ctx, `UPDATE matched_certificates SET discovered_at = $2 WHERE id = $1`,
+		stale, cutoff.Add(-24*time.Hour)); err != nil {
+		t.Fatalf("backdate: %v", err)
+	}
+
+	deleted, err := repo.DeleteWhere(ctx, CertificateFilter{KeywordID: kw, DiscoveredBefore: cutoff})
+	if err != nil {
+		t.Fatalf("DeleteWhere() error = %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("deleted = %d, want 1", deleted)
+	}
+	if _, err := repo.GetByID(ctx, stale); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetByID(stale) error = %v, want ErrNotFound", err)
+	}
+
+	deleted, err = repo.DeleteWhere(ctx, CertificateFilter{KeywordID: kw})
+	if err != nil {
+		t.Fatalf("DeleteWhere() error = %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("deleted = %d, want only the recent match of the keyword", deleted)
+	}
+	if _, total, _ := repo.ListPaginated(ctx, 1, 20, CertificateFilter{}); total != 1 {
+		t.Errorf("remaining = %d, want the other keyword's match", total)
+	}
+}
+
+func TestCertificateRiskScore(t *testing.T) {
+	pool := testPool(t)
+	// Scores 10 per day the domain is younger than 10 days, so the insert
+	// (no age yet) scores 0 and SetDomainAge rescores.
+	repo := NewCertificateRepository(pool, WithScorer(func(c model.MatchedCertificate) (int, []model.RiskFactor) {
+		if c.DomainAgeDays == nil || *c.DomainAgeDays >= 10 {
+			return 0, nil
+		}
+		points := 10 * (10 - *c.DomainAgeDays)
+		return points, []model.RiskFactor{{Factor: "young", Points: points}}
+	}))
+	ctx := context.Background()
+	kw := seedKeyword(t, pool, "example")
+	older := &model.MatchedCertificate{SerialNumber: "older", KeywordID: kw, MatchedDomain: "a.example.com"}
+	if err := repo.Create(ctx, older); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	newer := &model.MatchedCertificate{SerialNumber: "newer", KeywordID: kw, MatchedDomain: "b.example.com"}
+	if err := repo.Create(ctx, newer); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if older.RiskScore != 0 || older.RiskBreakdown == nil {
+		t.Errorf("inserted score = %d %v, want 0 and an empty breakdown", older.RiskScore, older.RiskBreakdown)
+	}
+
+	if err := repo.SetDomainAge(ctx, older.ID, time.Now().AddDate(0, 0, -2)); err != nil {
+		t.Fatalf("SetDomainAge() error = %v", err)
+	}
+	got, err := repo.GetByID(ctx, older.ID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if got.RiskScore != 80 || len(got.RiskBreakdown) != 1 || got.RiskBreakdown[0].Points != 80 {
+		t.Errorf("rescored = %d %+v, want 80 from one factor", got.RiskScore, got.RiskBreakdown)
+	}
+
+	certs, _, err := repo.ListPaginated(ctx, 1, 20, CertificateFilter{})
+	if err != nil {
+		t.Fatalf("ListPaginated() error = %v", err)
+	}
+	if len(certs) != 2 || certs[0].ID != older.ID {
+		t.Errorf("default order = %v, want the riskier, older match first", certs)
+	}
+	certs, _, err = repo.ListPaginated(ctx, 1, 20, CertificateFilter{Sort: SortNewest})
+	if err != nil {
+		t.Fatalf("ListPaginated() error = %v", err)
+	}
+	if len(certs) != 2 || certs[0].ID != newer.ID {
+		t.Errorf("newest order = %v, want the newer match first", certs)
+	}
+}
+
+func TestCertificateSetDomainHistory(t *testing.T) {
+	pool := testPool(t)
+	repo := NewCertificateRepository(pool)
+	ctx := context.Background()
+	kw := seedKeyword(t, pool, "example")
+	old := seedCert(t, pool, kw, "old", nil)
+	fresh := seedCert(t, pool, kw, "fresh", nil)
+	pending := seedCert(t, pool, kw, "pending", nil)
+
+	first := time.Date(2016, 4, 1, 12, 0, 0, 0, time.UTC)
+	if err := repo.SetDomainHistory(ctx, old, 1500, first); err != nil {
+		t.Fatalf("SetDomainHistory() error = %v", err)
+	}
+	if err := repo.SetDomainHistory(ctx, fresh, 0, time.Time{}); err != nil {
+		t.Fatalf("SetDomainHistory() error = %v", err)
+	}
+	if err := repo.SetDomainHistory(ctx, 999999, 1, first); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetDomainHistory(missing) error = %v, want ErrNotFound", err)
+	}
+
+	got := func(id int) *model.MatchedCertificate {
+		t.Helper()
+		c, err := repo.GetByID(ctx, id)
+		if err != nil {
+			t.Fatalf("GetByID(%d) error = %v", id, err)
+		}
+		return c
+	}
+	if c := got(old); c.HistoricalCertCount == nil || *c.HistoricalCertCount != 1500 ||
+		c.HistoricalFirstSeen == nil || !c.HistoricalFirstSeen.Equal(first) {
+		t.Errorf("old = %v/%v, want 1500/%v", c.HistoricalCertCount, c.HistoricalFirstSeen, first)
+	}
+	if c := got(fresh); c.HistoricalCertCount == nil || *c.HistoricalCertCount != 0 || c.HistoricalFirstSeen != nil {
+		t.Errorf("fresh = %v/%v, want 0 and no first-seen date", c.HistoricalCertCount, c.HistoricalFirstSeen)
+	}
+	if c := got(pending); c.HistoricalCertCount != nil || c.HistoricalFirstSeen != nil {
+		t.Errorf("pending = %v/%v, want nil until enriched", c.HistoricalCertCount, c.HistoricalFirstSeen)
+	}
+}
+
+func TestCertificateSetDomainAge(t *testing.T) {
+	pool := testPool(t)
+	repo := NewCertificateRepository(pool)
+	ctx := context.Background()
+	kw := seedKeyword(t, pool, "example")
+	young := seedCert(t, pool, kw, "young", nil)
+	old := seedCert(t, pool, kw, "old", nil)
+	unknown := seedCert(t, pool, kw, "unknown", nil)
+	pending := seedCert(t, pool, kw, "pending", nil)
+
+	young3 := time.Now().Add(-3*24*time.Hour - time.Hour)
+	for id, registered := range map[int]time.Time{
+		young:   young3,
+		old:     time.Date(1997, 9, 15, 4, 0, 0, 0, time.UTC),
+		unknown: {},
+	} {
+		if err := repo.SetDomainAge(ctx, id, registered); err != nil {
+			t.Fatalf("SetDomainAge(%d) error = %v", id, err)
+		}
+	}
+	if err := repo.SetDomainAge(ctx, 999999, young3); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetDomainAge(missing) error = %v, want ErrNotFound", err)
+	}
+
+	c, err := repo.GetByID(ctx, young)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if c.DomainAgeStatus != model.DomainAgeKnown || c.DomainAgeDays == nil || *c.DomainAgeDays != 3 ||
+		c.DomainRegisteredAt == nil || !c.DomainRegisteredAt.Equal(young3.Truncate(time.Microsecond)) {
+		t.Errorf("young = %q/%v/%v, want known, 3 days", c.DomainAgeStatus, c.DomainAgeDays, c.DomainRegisteredAt)
+	}
+	if c, _ := repo.GetByID(ctx, unknown); c.DomainAgeStatus != model.DomainAgeUnknown || c.DomainAgeDays != nil {
+		t.Errorf("unknown = %q/%v, want unknown and no age", c.DomainAgeStatus, c.DomainAgeDays)
+	}
+	if c, _ := repo.GetByID(ctx, pending); c.DomainAgeStatus != "" || c.DomainRegisteredAt != nil {
+		t.Errorf("pending = %q/%v, want nothing until enriched", c.DomainAgeStatus, c.DomainRegisteredAt)
+	}
+
+	week := 7
+	certs, total, err := repo.ListPaginated(ctx, 1, 20, CertificateFilter{MaxDomainAgeDays: &week})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if total != 1 || len(certs) != 1 || certs[0].ID != young {
+		t.Errorf("max_domain_age_days=7 listed %d (total %d), want only the young domain", len(certs), total)
+	}
+}
+
+func TestCertificateVersion(t *testing.T) {
+	pool := testPool(t)
+	repo := NewCertificateRepository(pool)
+	ctx := context.Background()
+
+	version := func() string {
+		t.Helper()
+		v, err := repo.Version(ctx, CertificateFilter{})
+		if err != nil {
+			t.Fatalf("Version() error = %v", err)
+		}
+		return v
+	}
+
+	empty := version()
+	kwID := seedKeyword(t, pool, "example")
+	id := seedCert(t, pool, kwID, "aa20", nil)
+	inserted := version()
+	if inserted == empty {
+		t.Errorf("version unchanged after insert: %q", inserted)
+	}
+	if again := version(); again != inserted {
+		t.Errorf("version = %q on unchanged data, want %q", again, inserted)
+	}
+
+	if err := repo.UpdateStatus(ctx, id, model.CertStatusAcknowledged, "alice", ""); err != nil {
+		t.Fatalf("UpdateStatus() error = %v", err)
+	}
+	triaged := version()
+	if triaged == inserted {
+		t.Errorf("version unchanged after status change: %q", triaged)
+	}
+
+	// Writes that leave every count alone still move the version.
+	if err := repo.UpdateStatus(ctx, id, model.CertStatusAcknowledged, "alice", "checked with the owner"); err != nil {
+		t.Fatalf("UpdateStatus(note) error = %v", err)
+	}
+	noted := version()
+	if noted == triaged {
+		t.Errorf("version unchanged after a note-only update: %q", noted)
+	}
+	if err := repo.SetDomainHistory(ctx, id, 12, time.Now().AddDate(-1, 0, 0)); err != nil {
+		t.Fatalf("SetDomainHistory() error = %v", err)
+	}
+	enriched := version()
+	if enriched == noted {
+		t.Errorf("version unchanged after enrichment: %q", enriched)
+	}
+
+	dup, err := repo.GetByID(ctx, id)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	dup.ID = 0
+	if err := NewCertificateRepository(pool, WithConflictStrategy(ConflictUpdate)).Create(ctx, dup); err != nil {
+		t.Fatalf("Create(duplicate) error = %v", err)
+	}
+	if refreshed := version(); refreshed == enriched {
+		t.Errorf("version unchanged after a conflict refresh: %q", refreshed)
+	}
+}
+
+func certIDs(certs []model.MatchedCertificate) []int {
+	ids := make([]int, len(certs))
+	for i, c := range certs {
+		ids[i] = c.ID
+	}
+	return ids
+}
+
+func TestCertificateDistinctIssuers(t *testing.T) {
+	pool := testPool(t)
+	repo := NewCertificateRepository(pool)
+	ctx := context.Background()
+	kwID := seedKeyword(t, pool, "example")
+
+	counts := map[string]int{"CN=Rare CA": 1, "CN=Common CA": 3, "CN=Middle CA": 2, "": 4}
+	n := 0
+	for issuer, count := range counts {
+		for range count {
+			n++
+			seedCert(t, pool, kwID, fmt.Sprintf("is%02d", n), func(c *model.MatchedCertificate) { c.Issuer = issuer })
+		}
+	}
+
+	issuers, err := repo.DistinctIssuers(ctx, 10)
+	if err != nil {
+		t.Fatalf("DistinctIssuers() error = %v", err)
+	}
+	want := []string{"CN=Common CA", "CN=Middle CA", "CN=Rare CA"}
+	if !slices.Equal(issuers, want) {
+		t.Errorf("DistinctIssuers() = %q, want %q (most frequent first, blank skipped)", issuers, want)
+	}
+
+	capped, err := repo.DistinctIssuers(ctx, 2)
+	if err != nil {
+		t.Fatalf("DistinctIssuers(limit 2) error = %v", err)
+	}
+	if !slices.Equal(capped, want[:2]) {
+		t.Errorf("DistinctIssuers(limit 2) = %q, want %q", capped, want[:2])
+	}
+
+	certs, total, err := repo.ListPaginated(ctx, 1, 20, CertificateFilter{Issuer: "CN=Middle CA"})
+	if err != nil {
+		t.Fatalf("ListPaginated(issuer) error = %v", err)
+	}
+	if total != 2 || len(certs) != 2 || certs[0].Issuer != "CN=Middle CA" {
+		t.Errorf("issuer filter returned %d of %d certificates", len(certs), total)
+	}
+}
+
+func TestCertificateSimilarDomains(t *testing.T) {
+	pool := testPool(t)
+	repo := NewCertificateRepository(pool)
+	ctx := context.Background()
+	kwID := seedKeyword(t, pool, "paypal")
+
+	domains := []string{
+		"paypal-login.com", "paypal-login.com", // same domain, two matches
+		"paypa1-login.com",
+		"paypal-logins.com",
+		"weather-forecast.org",
+	}
+	for i, d := range domains {
+		seedCert(t, pool, kwID, fmt.Sprintf("sim%02d", i), func(c *model.MatchedCertificate) { c.MatchedDomain = d })
+	}
+
+	similar, err := repo.SimilarDomains(ctx, "paypal-login.com", 0.4)
+	if err != nil {
+		t.Fatalf("SimilarDomains() error = %v", err)
+	}
+	var got []string
+	for _, d := range similar {
+		got = append(got, d.Domain)
+	}
+	want := []string{"paypal-login.com", "paypal-logins.com", "paypa1-login.com"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("SimilarDomains() = %q, want %q (closest first, distant domain excluded)", got, want)
+	}
+	if similar[0].Similarity != 1 || similar[0].Count != 2 {
+		t.Errorf("exact domain similarity/count = %v/%d, want 1/2", similar[0].Similarity, similar[0].Count)
+	}
+	if similar[1].Similarity <= similar[2].Similarity || similar[2].Similarity <= 0.4 {
+		t.Errorf("similarities = %v, want descending and above the threshold", similar)
+	}
+
+	strict, err := repo.SimilarDomains(ctx, "paypal-login.com", 0.99)
+	if err != nil {
+		t.Fatalf("SimilarDomains(0.99) error = %v", err)
+	}
+	if len(strict) != 1 || strict[0].Domain != "paypal-login.com" {
+		t.Errorf("SimilarDomains(0.99) = %v, want only the exact domain", strict)
+	}
+}
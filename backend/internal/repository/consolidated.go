@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+// ConsolidatedFilter narrows a consolidated certificate listing. Zero
+// values mean "any".
+type ConsolidatedFilter struct {
+	// KeywordID keeps certificates this keyword matched; they still list
+	// all their keywords.
+	KeywordID int
+	// MinKeywords keeps certificates matched by at least this many
+	// keywords.
+	MinKeywords int
+}
+
+// ListConsolidated returns a page of certificates, newest first, each with
+// every keyword that matched it, and how many certificates the filter
+// keeps. Certificates whose matches were all deleted are left out.
+func (r *CertificateRepository) ListConsolidated(ctx context.Context, page, perPage int, filter ConsolidatedFilter) ([]model.Certificate, int, error) {
+	var (
+		args   []any
+		where  string
+		having string
+	)
+	if filter.KeywordID > 0 {
+		args = append(args, filter.KeywordID)
+		where = fmt.Sprintf(`WHERE EXISTS (SELECT 1 FROM certificate_matches f
+			WHERE f.certificate_id = c.id AND f.keyword_id = $%d)`, len(args))
+	}
+	if filter.MinKeywords > 1 {
+		args = append(args, filter.MinKeywords)
+		having = fmt.Sprintf(`HAVING COUNT(*) >= $%d`, len(args))
+	}
+	from := `FROM certificates c
+		JOIN certificate_matches cm ON cm.certificate_id = c.id
+		JOIN keywords k ON k.id = cm.keyword_id
+		` + where + ` GROUP BY c.id ` + having
+
+	var total int
+	if err := r.pool.QueryRow(ctx,
+		`SELECT COUNT(*) FROM (SELECT c.id `+from+`) t`, args...,
+	).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.pool.Query(ctx, fmt.Sprintf(
+		`SELECT c.id, c.fingerprint, c.serial_number, c.common_name, c.sans, c.issuer,
+			c.not_before, c.not_after, c.first_seen_at,
+			jsonb_agg(jsonb_build_object('match_id', cm.match_id, 'keyword_id', cm.keyword_id,
+				'keyword', k.value, 'matched_domain', cm.matched_domain, 'reason', cm.reason)
+				ORDER BY cm.match_id)
+		%s
+		ORDER BY c.id DESC
+		LIMIT $%d OFFSET $%d`, from, len(args)+1, len(args)+2),
+		append(args, perPage, (page-1)*perPage)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var certs []model.Certificate
+	for rows.Next() {
+		var c model.Certificate
+		if err := rows.Scan(&c.ID, &c.Fingerprint, &c.SerialNumber, &c.CommonName, &c.SANs, &c.Issuer,
+			&c.NotBefore, &c.NotAfter, &c.FirstSeenAt, &c.Keywords); err != nil {
+			return nil, 0, err
+		}
+		certs = append(certs, c)
+	}
+	return certs, total, rows.Err()
+}
+
+// link files the just-inserted match cert under its certificate, adding
+// the certificate on its first match, and returns the certificate's id.
+func link(ctx context.Context, db queryRower, cert *model.MatchedCertificate) (int, error) {
+	var id int
+	err := db.QueryRow(ctx,
+		`WITH c AS (
+			INSERT INTO certificates (fingerprint, serial_number, common_name, sans, issuer, not_before, not_after, first_seen_at)
+			VALUES ($1, $2, $3, COALESCE($4::text[], '{}'), $5, $6, $7, $8)
+			ON CONFLICT (fingerprint) DO UPDATE SET fingerprint = EXCLUDED.fingerprint
+			RETURNING id
+		)
+		INSERT INTO certificate_matches (certificate_id, keyword_id, match_id, matched_domain, reason)
+		SELECT id, $9, $10, $11, $12 FROM c
+		RETURNING certificate_id`,
+		cert.Fingerprint(), cert.SerialNumber, cert.CommonName, cert.SANs, cert.Issuer,
+		cert.NotBefore, cert.NotAfter, cert.FirstSeenAt,
+		cert.KeywordID, cert.ID, cert.MatchedDomain, model.MatchReason(cert.MatchedField, cert.MatchedValue),
+	).Scan(&id)
+	return id, err
+}
+
+// coalesce adds cert's keyword to the notification already waiting for
+// certificateID, if there is one no dispatcher has claimed yet, and reports
+// whether it did. A claimed, failed or sent notification is left alone, so
+// the caller enqueues a new one.
+func coalesce(ctx context.Context, tx pgx.Tx, certificateID int, cert *model.MatchedCertificate) (bool, error) {
+	kw, err := json.Marshal([]model.CertificateKeyword{certificateKeyword(cert)})
+	if err != nil {
+		return false, err
+	}
+	// The outer conditions repeat the inner ones so a row claimed while
+	// this waited on its lock is re-checked and skipped.
+	tag, err := tx.Exec(ctx,
+		`UPDATE notification_outbox
+		SET payload = jsonb_set(payload, '{keywords}', COALESCE(payload->'keywords', '[]'::jsonb) || $2::jsonb)
+		WHERE id = (
+			SELECT o.id FROM notification_outbox o
+			JOIN certificate_matches cm ON cm.match_id = o.certificate_id
+			WHERE cm.certificate_id = $1 AND o.status = 'pending' AND o.attempts = 0 AND o.next_attempt_at <= NOW()
+			ORDER BY o.id
+			LIMIT 1
+		) AND status = 'pending' AND attempts = 0 AND next_attempt_at <= NOW()`,
+		certificateID, kw)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func certificateKeyword(cert *model.MatchedCertificate) model.CertificateKeyword {
+	return model.CertificateKeyword{
+		MatchID:       cert.ID,
+		KeywordID:     cert.KeywordID,
+		Keyword:       cert.KeywordValue,
+		MatchedDomain: cert.MatchedDomain,
+		Reason:        model.MatchReason(cert.MatchedField, cert.MatchedValue),
+	}
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+func TestListConsolidated(t *testing.T) {
+	pool := testPool(t)
+	repo := NewCertificateRepository(pool)
+	ctx := context.Background()
+	paypal := seedKeyword(t, pool, "paypal")
+	login := seedKeyword(t, pool, "login")
+	sameCert := func(c *model.MatchedCertificate) {
+		c.SerialNumber, c.CommonName, c.SANs = "shared", "paypal-login.com", []string{"paypal-login.com"}
+		c.MatchedDomain, c.MatchedField = "paypal-login.com", "cn"
+	}
+	seedCert(t, pool, paypal, "shared", sameCert)
+	seedCert(t, pool, login, "shared", sameCert)
+	seedCert(t, pool, login, "alone", nil)
+
+	certs, total, err := repo.ListConsolidated(ctx, 1, 20, ConsolidatedFilter{})
+	if err != nil {
+		t.Fatalf("ListConsolidated() error = %v", err)
+	}
+	if total != 2 || len(certs) != 2 {
+		t.Fatalf("got %d certificates (total %d), want 2", len(certs), total)
+	}
+	if certs[0].SerialNumber != "alone" || len(certs[0].Keywords) != 1 {
+		t.Errorf("newest = %+v, want alone with one keyword", certs[0])
+	}
+	shared := certs[1]
+	if len(shared.Keywords) != 2 || shared.Keywords[0].Keyword != "paypal" || shared.Keywords[1].Keyword != "login" {
+		t.Errorf("shared keywords = %+v, want paypal then login", shared.Keywords)
+	}
+	if shared.Keywords[0].Reason != "cn" {
+		t.Errorf("reason = %q, want cn", shared.Keywords[0].Reason)
+	}
+
+	certs, total, err = repo.ListConsolidated(ctx, 1, 20, ConsolidatedFilter{MinKeywords: 2})
+	if err != nil || total != 1 || certs[0].SerialNumber != "shared" {
+		t.Errorf("min_keywords 2 = %+v (total %d, err %v), want shared", certs, total, err)
+	}
+	certs, total, err = repo.ListConsolidated(ctx, 1, 20, ConsolidatedFilter{KeywordID: paypal})
+	if err != nil || total != 1 || len(certs[0].Keywords) != 2 {
+		t.Errorf("keyword filter = %+v (total %d, err %v), want shared with both keywords", certs, total, err)
+	}
+
+	// A purge leaves no certificate without matches behind.
+	if _, err := repo.DeleteWhere(ctx, CertificateFilter{KeywordID: login}); err != nil {
+		t.Fatalf("DeleteWhere() error = %v", err)
+	}
+	var left int
+	if err := pool.QueryRow(ctx, `SELECT COUNT(*) FROM certificates`).Scan(&left); err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if left != 1 {
+		t.Errorf("certificates left = %d, want the one paypal still matches", left)
+	}
+}
+
+func TestCertificateCreate_Consolidation(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	paypal := seedKeyword(t, pool, "paypal")
+	login := seedKeyword(t, pool, "login")
+
+	create := func(repo *CertificateRepository, kw int, serial string) {
+		t.Helper()
+		cert := &model.MatchedCertificate{
+			SerialNumber: serial, CommonName: "paypal-login.com", SANs: []string{"paypal-login.com"},
+			Issuer: "Test CA", KeywordID: kw, MatchedDomain: "paypal-login.com", MatchedField: "cn",
+		}
+		if err := repo.Create(ctx, cert); err != nil {
+			t.Fatalf("Create(%s, %d) error = %v", serial, kw, err)
+		}
+		if cert.Keywords != nil {
+			t.Errorf("Create set Keywords = %+v on the stored match", cert.Keywords)
+		}
+	}
+	outbox := func(serial string) []json.RawMessage {
+		t.Helper()
+		rows, err := pool.Query(ctx,
+			`SELECT COALESCE(o.payload->'keywords', 'null') FROM notification_outbox o
+			JOIN matched_certificates mc ON mc.id = o.certificate_id
+			WHERE mc.serial_number = $1 ORDER BY o.id`, serial)
+		if err != nil {
+			t.Fatalf("outbox: %v", err)
+		}
+		defer rows.Close()
+		var payloads []json.RawMessage
+		for rows.Next() {
+			var p json.RawMessage
+			if err := rows.Scan(&p); err != nil {
+				t.Fatalf("scan: %v", err)
+			}
+			payloads = append(payloads, p)
+		}
+		return payloads
+	}
+
+	// Without consolidation each keyword notifies on its own.
+	plain := NewCertificateRepository(pool)
+	create(plain, paypal, "plain")
+	create(plain, login, "plain")
+	if got := outbox("plain"); len(got) != 2 || string(got[0]) != "null" {
+		t.Errorf("plain outbox = %s, want two rows without keywords", got)
+	}
+
+	consolidated := NewCertificateRepository(pool, WithConsolidation(true))
+	create(consolidated, paypal, "merged")
+	create(consolidated, login, "merged")
+	got := outbox("merged")
+	if len(got) != 1 {
+		t.Fatalf("merged outbox rows = %d, want 1", len(got))
+	}
+	var keywords []model.CertificateKeyword
+	if err := json.Unmarshal(got[0], &keywords); err != nil {
+		t.Fatalf("keywords %s: %v", got[0], err)
+	}
+	if len(keywords) != 2 || keywords[0].KeywordID != paypal || keywords[1].KeywordID != login {
+		t.Errorf("keywords = %+v, want paypal and login", keywords)
+	}
+
+	// A notification a dispatcher already claimed is left alone.
+	if _, err := pool.Exec(ctx, `UPDATE notification_outbox SET attempts = 1`); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	third := seedKeyword(t, pool, "paypal-login")
+	create(consolidated, third, "merged")
+	if got := outbox("merged"); len(got) != 2 {
+		t.Errorf("merged outbox rows = %d after a claimed row, want 2", len(got))
+	}
+}
@@ -3,3 +3,6 @@ package repository
 import "errors"
 
 var ErrNotFound = errors.New("not found")
+
+// ErrGroupNotFound reports a keyword group id that does not exist.
+var ErrGroupNotFound = errors.New("keyword group not found")
@@ -2,7 +2,11 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/andres10976/SISAP-PoC/backend/internal/model"
@@ -16,9 +20,45 @@ func NewKeywordRepository(pool *pgxpool.Pool) *KeywordRepository {
 	return &KeywordRepository{pool: pool}
 }
 
+// keywordColumns selects a keyword and its group's settings, over
+// keywordFrom, in scanKeyword's order. A mute that has run out reads as
+// none, so mutes expire without being cleared.
+const keywordColumns = `k.id, k.value, k.match_mode, k.created_at,
+	CASE WHEN k.muted_until > NOW() THEN k.muted_until END,
+	CASE WHEN k.muted_until > NOW() THEN k.mute_scope ELSE '' END,
+	k.disabled_at, k.disabled_reason, k.group_id,
+	COALESCE(g.match_mode, ''),
+	CASE WHEN g.muted_until > NOW() THEN g.muted_until END,
+	CASE WHEN g.muted_until > NOW() THEN g.mute_scope ELSE '' END,
+	g.disabled_at, COALESCE(g.disabled_reason, ''), k.alternatives,
+	k.active_from, k.active_until`
+
+const keywordFrom = `keywords k LEFT JOIN keyword_groups g ON g.id = k.group_id`
+
+// scanKeyword reads a keywordColumns row and resolves the keyword's
+// settings against its group (model.Keyword.Inherit). A keyword left with
+// no match mode is a substring one.
+func scanKeyword(row pgx.Row, kw *model.Keyword) error {
+	var g model.KeywordGroup
+	err := row.Scan(&kw.ID, &kw.Value, &kw.MatchMode, &kw.CreatedAt, &kw.MutedUntil, &kw.MuteScope,
+		&kw.DisabledAt, &kw.DisabledReason, &kw.GroupID,
+		&g.MatchMode, &g.MutedUntil, &g.MuteScope, &g.DisabledAt, &g.DisabledReason,
+		&kw.Alternatives, &kw.ActiveFrom, &kw.ActiveUntil)
+	if err != nil {
+		return err
+	}
+	if kw.GroupID != nil {
+		kw.Inherit(g)
+	}
+	if kw.MatchMode == "" {
+		kw.MatchMode = model.MatchModeSubstring
+	}
+	return nil
+}
+
 func (r *KeywordRepository) List(ctx context.Context) ([]model.Keyword, error) {
 	rows, err := r.pool.Query(ctx,
-		`SELECT id, value, created_at FROM keywords ORDER BY created_at DESC`)
+		`SELECT `+keywordColumns+` FROM `+keywordFrom+` ORDER BY k.created_at DESC`)
 	if err != nil {
 		return nil, err
 	}
@@ -27,7 +67,7 @@ func (r *KeywordRepository) List(ctx context.Context) ([]model.Keyword, error) {
 	var keywords []model.Keyword
 	for rows.Next() {
 		var kw model.Keyword
-		if err := rows.Scan(&kw.ID, &kw.Value, &kw.CreatedAt); err != nil {
+		if err := scanKeyword(rows, &kw); err != nil {
 			return nil, err
 		}
 		keywords = append(keywords, kw)
@@ -35,22 +75,122 @@ func (r *KeywordRepository) List(ctx context.Context) ([]model.Keyword, error) {
 	return keywords, rows.Err()
 }
 
-func (r *KeywordRepository) Create(ctx context.Context, value string) (*model.Keyword, error) {
+// Get returns the keyword with id, or ErrNotFound.
+func (r *KeywordRepository) Get(ctx context.Context, id int) (*model.Keyword, error) {
 	var kw model.Keyword
-	err := r.pool.QueryRow(ctx,
-		`INSERT INTO keywords (value) VALUES ($1)
-		 RETURNING id, value, created_at`, value,
-	).Scan(&kw.ID, &kw.Value, &kw.CreatedAt)
-	return &kw, err
+	err := scanKeyword(r.pool.QueryRow(ctx,
+		`SELECT `+keywordColumns+` FROM `+keywordFrom+` WHERE k.id = $1`, id), &kw)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &kw, nil
 }
 
-func (r *KeywordRepository) Delete(ctx context.Context, id int) error {
-	tag, err := r.pool.Exec(ctx, `DELETE FROM keywords WHERE id = $1`, id)
+// Create stores a keyword matched under mode (a model.MatchMode value, or
+// "" to take its group's) in group groupID, if not nil, that also matches
+// on alternatives; one equal to value, ignoring case, is dropped. It is
+// matched from activeFrom until activeUntil, either nil for no bound. A
+// group that does not exist is ErrGroupNotFound.
+func (r *KeywordRepository) Create(ctx context.Context, value, mode string, groupID *int, alternatives []string, activeFrom, activeUntil *time.Time) (*model.Keyword, error) {
+	kw, err := r.update(ctx,
+		`INSERT INTO keywords (value, match_mode, group_id, alternatives, active_from, active_until)
+		 VALUES ($1, $2, $3, ARRAY(SELECT a FROM unnest($4::text[]) a WHERE lower(a) <> lower($1)), $5, $6)
+		 RETURNING *`,
+		value, mode, groupID, alternatives, activeFrom, activeUntil)
+	return kw, groupError(err)
+}
+
+// SetAlternatives replaces the keyword's alternative values, dropping one
+// equal to its value ignoring case, and returns it.
+func (r *KeywordRepository) SetAlternatives(ctx context.Context, id int, alternatives []string) (*model.Keyword, error) {
+	return r.update(ctx,
+		`UPDATE keywords SET alternatives = ARRAY(SELECT a FROM unnest($2::text[]) a WHERE lower(a) <> lower(value))
+		 WHERE id = $1 RETURNING *`,
+		id, alternatives)
+}
+
+// SetGroup moves the keyword into group groupID, or out of any group when
+// it is nil, and returns it. A group that does not exist is
+// ErrGroupNotFound.
+func (r *KeywordRepository) SetGroup(ctx context.Context, id int, groupID *int) (*model.Keyword, error) {
+	kw, err := r.update(ctx,
+		`UPDATE keywords SET group_id = $2 WHERE id = $1 RETURNING *`, id, groupID)
+	return kw, groupError(err)
+}
+
+// groupError turns the foreign key violation of an unknown group id into
+// ErrGroupNotFound.
+func groupError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" && pgErr.ConstraintName == "keywords_group_id_fkey" {
+		return ErrGroupNotFound
+	}
+	return err
+}
+
+// Mute silences the keyword under scope (a model.MuteScope value) until
+// until, replacing any mute already in force, and returns the keyword.
+func (r *KeywordRepository) Mute(ctx context.Context, id int, until time.Time, scope string) (*model.Keyword, error) {
+	return r.setMute(ctx, id, &until, scope)
+}
+
+// Unmute lifts the keyword's mute, if any, and returns the keyword.
+func (r *KeywordRepository) Unmute(ctx context.Context, id int) (*model.Keyword, error) {
+	return r.setMute(ctx, id, nil, "")
+}
+
+func (r *KeywordRepository) setMute(ctx context.Context, id int, until *time.Time, scope string) (*model.Keyword, error) {
+	return r.update(ctx,
+		`UPDATE keywords SET muted_until = $2, mute_scope = $3 WHERE id = $1 RETURNING *`,
+		id, until, scope)
+}
+
+// Disable stops the keyword from being matched, recording reason, and
+// returns it. Disabling a disabled keyword keeps its original time.
+func (r *KeywordRepository) Disable(ctx context.Context, id int, reason string) (*model.Keyword, error) {
+	return r.update(ctx,
+		`UPDATE keywords SET disabled_at = COALESCE(disabled_at, NOW()), disabled_reason = $2 WHERE id = $1
+		 RETURNING *`, id, reason)
+}
+
+// Enable lets a disabled keyword be matched again and returns it.
+func (r *KeywordRepository) Enable(ctx context.Context, id int) (*model.Keyword, error) {
+	return r.update(ctx,
+		`UPDATE keywords SET disabled_at = NULL, disabled_reason = '' WHERE id = $1 RETURNING *`, id)
+}
+
+// update runs a single-row INSERT or UPDATE ... RETURNING * on keywords
+// and reads the row back with its group's settings.
+func (r *KeywordRepository) update(ctx context.Context, query string, args ...any) (*model.Keyword, error) {
+	var kw model.Keyword
+	err := scanKeyword(r.pool.QueryRow(ctx,
+		`WITH k AS (`+query+`)
+		 SELECT `+keywordColumns+` FROM k LEFT JOIN keyword_groups g ON g.id = k.group_id`, args...), &kw)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if tag.RowsAffected() == 0 {
-		return ErrNotFound
+	return &kw, nil
+}
+
+// Delete removes a keyword and returns it as it was, for the audit log.
+func (r *KeywordRepository) Delete(ctx context.Context, id int) (*model.Keyword, error) {
+	return r.update(ctx, `DELETE FROM keywords WHERE id = $1 RETURNING *`, id)
+}
+
+// CreateMany inserts every value that is not stored yet and returns how many
+// rows were added. Existing values are left untouched.
+func (r *KeywordRepository) CreateMany(ctx context.Context, values []string) (int, error) {
+	tag, err := r.pool.Exec(ctx,
+		`INSERT INTO keywords (value) SELECT unnest($1::text[])
+		 ON CONFLICT (value) DO NOTHING`, values)
+	if err != nil {
+		return 0, err
 	}
-	return nil
+	return int(tag.RowsAffected()), nil
 }
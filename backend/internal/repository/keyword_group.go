@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+type KeywordGroupRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewKeywordGroupRepository(pool *pgxpool.Pool) *KeywordGroupRepository {
+	return &KeywordGroupRepository{pool: pool}
+}
+
+// keywordGroupColumns selects a group over the "g" alias in
+// scanKeywordGroup's order. As for keywords, a mute that has run out reads
+// as none.
+const keywordGroupColumns = `g.id, g.name, g.match_mode,
+	CASE WHEN g.muted_until > NOW() THEN g.muted_until END,
+	CASE WHEN g.muted_until > NOW() THEN g.mute_scope ELSE '' END,
+	g.disabled_at, g.disabled_reason, g.created_at,
+	(SELECT COUNT(*) FROM keywords k WHERE k.group_id = g.id)`
+
+func scanKeywordGroup(row pgx.Row, g *model.KeywordGroup) error {
+	return row.Scan(&g.ID, &g.Name, &g.MatchMode, &g.MutedUntil, &g.MuteScope,
+		&g.DisabledAt, &g.DisabledReason, &g.CreatedAt, &g.KeywordCount)
+}
+
+// List returns every group with its keyword count, by name.
+func (r *KeywordGroupRepository) List(ctx context.Context) ([]model.KeywordGroup, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT `+keywordGroupColumns+` FROM keyword_groups g ORDER BY g.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []model.KeywordGroup
+	for rows.Next() {
+		var g model.KeywordGroup
+		if err := scanKeywordGroup(rows, &g); err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+// Get returns the group with id, or ErrNotFound.
+func (r *KeywordGroupRepository) Get(ctx context.Context, id int) (*model.KeywordGroup, error) {
+	return r.update(ctx, `SELECT * FROM keyword_groups WHERE id = $1`, id)
+}
+
+// Create stores a group whose keywords default to mode (a model.MatchMode
+// value, or "" for no default).
+func (r *KeywordGroupRepository) Create(ctx context.Context, name, mode string) (*model.KeywordGroup, error) {
+	return r.update(ctx,
+		`INSERT INTO keyword_groups (name, match_mode) VALUES ($1, $2) RETURNING *`, name, mode)
+}
+
+// Update renames the group and replaces its default match mode.
+func (r *KeywordGroupRepository) Update(ctx context.Context, id int, name, mode string) (*model.KeywordGroup, error) {
+	return r.update(ctx,
+		`UPDATE keyword_groups SET name = $2, match_mode = $3 WHERE id = $1 RETURNING *`, id, name, mode)
+}
+
+// Mute silences the group's keywords that have no mute of their own under
+// scope until until, replacing any group mute in force.
+func (r *KeywordGroupRepository) Mute(ctx context.Context, id int, until time.Time, scope string) (*model.KeywordGroup, error) {
+	return r.update(ctx,
+		`UPDATE keyword_groups SET muted_until = $2, mute_scope = $3 WHERE id = $1 RETURNING *`, id, until, scope)
+}
+
+// Unmute lifts the group's mute; its keywords' own mutes stay.
+func (r *KeywordGroupRepository) Unmute(ctx context.Context, id int) (*model.KeywordGroup, error) {
+	return r.update(ctx,
+		`UPDATE keyword_groups SET muted_until = NULL, mute_scope = '' WHERE id = $1 RETURNING *`, id)
+}
+
+// Disable stops every keyword in the group from being matched, recording
+// reason. Disabling a disabled group keeps its original time.
+func (r *KeywordGroupRepository) Disable(ctx context.Context, id int, reason string) (*model.KeywordGroup, error) {
+	return r.update(ctx,
+		`UPDATE keyword_groups SET disabled_at = COALESCE(disabled_at, NOW()), disabled_reason = $2 WHERE id = $1
+		 RETURNING *`, id, reason)
+}
+
+// Enable lifts the group's disable; keywords disabled on their own stay
+// disabled.
+func (r *KeywordGroupRepository) Enable(ctx context.Context, id int) (*model.KeywordGroup, error) {
+	return r.update(ctx,
+		`UPDATE keyword_groups SET disabled_at = NULL, disabled_reason = '' WHERE id = $1 RETURNING *`, id)
+}
+
+// update runs a single-row statement over keyword_groups returning * and
+// reads the row back with its keyword count, or ErrNotFound.
+func (r *KeywordGroupRepository) update(ctx context.Context, query string, args ...any) (*model.KeywordGroup, error) {
+	var g model.KeywordGroup
+	err := scanKeywordGroup(r.pool.QueryRow(ctx,
+		`WITH g AS (`+query+`) SELECT `+keywordGroupColumns+` FROM g`, args...), &g)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// Delete removes the group. Its keywords are ungrouped, or, with
+// withKeywords, deleted in the same transaction along with their matches;
+// it returns how many keywords were deleted.
+func (r *KeywordGroupRepository) Delete(ctx context.Context, id int, withKeywords bool) (int64, error) {
+	var deleted int64
+	err := pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+		if withKeywords {
+			tag, err := tx.Exec(ctx, `DELETE FROM keywords WHERE group_id = $1`, id)
+			if err != nil {
+				return err
+			}
+			deleted = tag.RowsAffected()
+		}
+		tag, err := tx.Exec(ctx, `DELETE FROM keyword_groups WHERE id = $1`, id)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
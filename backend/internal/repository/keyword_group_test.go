@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+func TestKeywordGroup_SettingsResolve(t *testing.T) {
+	pool := testPool(t)
+	groups := NewKeywordGroupRepository(pool)
+	keywords := NewKeywordRepository(pool)
+	ctx := context.Background()
+
+	g, err := groups.Create(ctx, "Brand X", model.MatchModeBoundary)
+	if err != nil {
+		t.Fatalf("Create group: %v", err)
+	}
+	inherits, err := keywords.Create(ctx, "brandx", "", &g.ID, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Create keyword: %v", err)
+	}
+	overrides, err := keywords.Create(ctx, "brand-x", model.MatchModeSubstring, &g.ID, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Create keyword: %v", err)
+	}
+	if inherits.MatchMode != model.MatchModeBoundary || !slices.Equal(inherits.Inherited, []string{"match_mode"}) {
+		t.Errorf("inheriting keyword = %+v, want boundary from the group", inherits)
+	}
+	if overrides.MatchMode != model.MatchModeSubstring || len(overrides.Inherited) != 0 {
+		t.Errorf("overriding keyword = %+v, want its own substring", overrides)
+	}
+
+	until := time.Now().Add(time.Hour)
+	if _, err := groups.Mute(ctx, g.ID, until, model.MuteScopeMatching); err != nil {
+		t.Fatalf("Mute group: %v", err)
+	}
+	if _, err := keywords.Mute(ctx, overrides.ID, until, model.MuteScopeNotifications); err != nil {
+		t.Fatalf("Mute keyword: %v", err)
+	}
+	if _, err := groups.Disable(ctx, g.ID, "brand retired"); err != nil {
+		t.Fatalf("Disable group: %v", err)
+	}
+
+	got, err := keywords.Get(ctx, inherits.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.MuteScope != model.MuteScopeMatching || got.Enabled() || got.DisabledReason != "brand retired" {
+		t.Errorf("inheriting keyword = %+v, want the group's matching mute and disable", got)
+	}
+	got, err = keywords.Get(ctx, overrides.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.MuteScope != model.MuteScopeNotifications {
+		t.Errorf("overriding keyword mute scope = %q, want its own notifications", got.MuteScope)
+	}
+
+	// Leaving the group drops everything that came from it.
+	got, err = keywords.SetGroup(ctx, inherits.ID, nil)
+	if err != nil {
+		t.Fatalf("SetGroup: %v", err)
+	}
+	if got.MatchMode != model.MatchModeSubstring || got.MutedUntil != nil || !got.Enabled() || got.GroupID != nil {
+		t.Errorf("ungrouped keyword = %+v, want substring, unmuted and enabled", got)
+	}
+}
+
+func TestKeywordGroup_UnknownGroup(t *testing.T) {
+	pool := testPool(t)
+	keywords := NewKeywordRepository(pool)
+	ctx := context.Background()
+
+	missing := 99
+	if _, err := keywords.Create(ctx, "paypal", "", &missing, nil, nil, nil); !errors.Is(err, ErrGroupNotFound) {
+		t.Errorf("Create in missing group error = %v, want ErrGroupNotFound", err)
+	}
+	id := seedKeyword(t, pool, "amazon")
+	if _, err := keywords.SetGroup(ctx, id, &missing); !errors.Is(err, ErrGroupNotFound) {
+		t.Errorf("SetGroup to missing group error = %v, want ErrGroupNotFound", err)
+	}
+	if _, err := NewKeywordGroupRepository(pool).Get(ctx, missing); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get missing group error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestKeywordGroup_Delete(t *testing.T) {
+	pool := testPool(t)
+	groups := NewKeywordGroupRepository(pool)
+	keywords := NewKeywordRepository(pool)
+	ctx := context.Background()
+
+	keep, _ := groups.Create(ctx, "Keep", "")
+	drop, _ := groups.Create(ctx, "Drop", "")
+	kept, _ := keywords.Create(ctx, "keepme", "", &keep.ID, nil, nil, nil)
+	for _, v := range []string{"dropme", "dropme2"} {
+		kw, err := keywords.Create(ctx, v, "", &drop.ID, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("Create keyword: %v", err)
+		}
+		seedCert(t, pool, kw.ID, v, nil)
+	}
+
+	deleted, err := groups.Delete(ctx, keep.ID, false)
+	if err != nil || deleted != 0 {
+		t.Fatalf("Delete without keywords = %d, %v; want 0, nil", deleted, err)
+	}
+	got, err := keywords.Get(ctx, kept.ID)
+	if err != nil || got.GroupID != nil {
+		t.Errorf("keyword of deleted group = %+v, %v; want it kept and ungrouped", got, err)
+	}
+
+	deleted, err = groups.Delete(ctx, drop.ID, true)
+	if err != nil || deleted != 2 {
+		t.Fatalf("Delete with keywords = %d, %v; want 2, nil", deleted, err)
+	}
+	all, _ := keywords.List(ctx)
+	if len(all) != 1 {
+		t.Errorf("keywords left = %d, want 1", len(all))
+	}
+	if _, err := groups.Delete(ctx, drop.ID, true); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Delete error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestKeywordGroup_CertificateFilterAndMute(t *testing.T) {
+	pool := testPool(t)
+	groups := NewKeywordGroupRepository(pool)
+	keywords := NewKeywordRepository(pool)
+	certs := NewCertificateRepository(pool)
+	ctx := context.Background()
+
+	g, _ := groups.Create(ctx, "Brand X", "")
+	grouped, _ := keywords.Create(ctx, "brandx", "", &g.ID, nil, nil, nil)
+	other := seedKeyword(t, pool, "other")
+	seedCert(t, pool, grouped.ID, "in-group", nil)
+	seedCert(t, pool, other, "outside", nil)
+
+	list, total, err := certs.ListPaginated(ctx, 1, 20, CertificateFilter{GroupID: g.ID})
+	if err != nil {
+		t.Fatalf("ListPaginated: %v", err)
+	}
+	if total != 1 || list[0].SerialNumber != "in-group" {
+		t.Errorf("group filter: total = %d, certs = %+v; want in-group only", total, list)
+	}
+
+	// A group mute silences notifications for its keywords' new matches.
+	if _, err := groups.Mute(ctx, g.ID, time.Now().Add(time.Hour), model.MuteScopeNotifications); err != nil {
+		t.Fatalf("Mute group: %v", err)
+	}
+	seedCert(t, pool, grouped.ID, "muted", nil)
+	var pending int
+	if err := pool.QueryRow(ctx,
+		`SELECT COUNT(*) FROM notification_outbox o JOIN matched_certificates mc ON mc.id = o.certificate_id
+		 WHERE mc.serial_number = 'muted'`).Scan(&pending); err != nil {
+		t.Fatal(err)
+	}
+	if pending != 0 {
+		t.Errorf("outbox rows for a match of a muted group = %d, want 0", pending)
+	}
+}
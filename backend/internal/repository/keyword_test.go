@@ -0,0 +1,304 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+func TestKeywordCreateMany_SkipsExisting(t *testing.T) {
+	pool := testPool(t)
+	repo := NewKeywordRepository(pool)
+	ctx := context.Background()
+
+	seedKeyword(t, pool, "apple")
+
+	n, err := repo.CreateMany(ctx, []string{"apple", "amazon", "paypal"})
+	if err != nil {
+		t.Fatalf("CreateMany: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("inserted = %d, want 2", n)
+	}
+
+	n, err = repo.CreateMany(ctx, []string{"amazon", "paypal"})
+	if err != nil {
+		t.Fatalf("CreateMany again: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("second insert = %d, want 0", n)
+	}
+
+	keywords, err := repo.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(keywords) != 3 {
+		t.Errorf("len(keywords) = %d, want 3", len(keywords))
+	}
+}
+
+func TestKeywordCreate_MatchMode(t *testing.T) {
+	pool := testPool(t)
+	repo := NewKeywordRepository(pool)
+	ctx := context.Background()
+
+	kw, err := repo.Create(ctx, "paypal", model.MatchModeBoundary, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if kw.MatchMode != model.MatchModeBoundary {
+		t.Errorf("Create MatchMode = %q, want boundary", kw.MatchMode)
+	}
+	if _, err := repo.CreateMany(ctx, []string{"amazon"}); err != nil {
+		t.Fatalf("CreateMany: %v", err)
+	}
+
+	keywords, err := repo.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	modes := make(map[string]string)
+	for _, k := range keywords {
+		modes[k.Value] = k.MatchMode
+	}
+	if modes["paypal"] != model.MatchModeBoundary || modes["amazon"] != model.MatchModeSubstring {
+		t.Errorf("stored modes = %v, want paypal=boundary amazon=substring", modes)
+	}
+}
+
+func TestKeywordAlternatives(t *testing.T) {
+	pool := testPool(t)
+	repo := NewKeywordRepository(pool)
+	ctx := context.Background()
+
+	kw, err := repo.Create(ctx, "paypal", "", nil, []string{"PayPal", "paypa1"}, nil, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if !slices.Equal(kw.Alternatives, []string{"paypa1"}) {
+		t.Errorf("Create Alternatives = %v, want [paypa1] (the value itself dropped)", kw.Alternatives)
+	}
+
+	kw, err = repo.SetAlternatives(ctx, kw.ID, []string{"pypl", "PAYPAL"})
+	if err != nil {
+		t.Fatalf("SetAlternatives: %v", err)
+	}
+	if !slices.Equal(kw.Alternatives, []string{"pypl"}) {
+		t.Errorf("SetAlternatives = %v, want [pypl]", kw.Alternatives)
+	}
+	got, err := repo.Get(ctx, kw.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !slices.Equal(got.Values(), []string{"paypal", "pypl"}) {
+		t.Errorf("stored Values() = %v, want [paypal pypl]", got.Values())
+	}
+
+	kw, err = repo.SetAlternatives(ctx, kw.ID, nil)
+	if err != nil {
+		t.Fatalf("SetAlternatives(nil): %v", err)
+	}
+	if len(kw.Alternatives) != 0 {
+		t.Errorf("cleared Alternatives = %v, want none", kw.Alternatives)
+	}
+
+	if _, err := repo.SetAlternatives(ctx, 99999, []string{"pypl"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetAlternatives(unknown) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestKeywordGet(t *testing.T) {
+	pool := testPool(t)
+	repo := NewKeywordRepository(pool)
+	ctx := context.Background()
+
+	id := seedKeyword(t, pool, "example")
+	kw, err := repo.Get(ctx, id)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if kw.ID != id || kw.Value != "example" {
+		t.Errorf("Get = %+v, want id %d example", kw, id)
+	}
+	if _, err := repo.Get(ctx, id+1000); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestKeywordDelete_ReturnsDeleted(t *testing.T) {
+	pool := testPool(t)
+	repo := NewKeywordRepository(pool)
+	ctx := context.Background()
+
+	kw, err := repo.Create(ctx, "example", "", nil, []string{"exmpl"}, nil, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	deleted, err := repo.Delete(ctx, kw.ID)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if deleted.ID != kw.ID || deleted.Value != "example" || !slices.Equal(deleted.Alternatives, []string{"exmpl"}) {
+		t.Errorf("Delete = %+v, want the deleted keyword", deleted)
+	}
+	if _, err := repo.Get(ctx, kw.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete error = %v, want ErrNotFound", err)
+	}
+	if _, err := repo.Delete(ctx, kw.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestKeywordDisableEnable(t *testing.T) {
+	pool := testPool(t)
+	repo := NewKeywordRepository(pool)
+	ctx := context.Background()
+
+	id := seedKeyword(t, pool, "com")
+	kw, err := repo.Disable(ctx, id, "matched too much")
+	if err != nil {
+		t.Fatalf("Disable: %v", err)
+	}
+	if kw.Enabled() || kw.DisabledReason != "matched too much" {
+		t.Errorf("Disable = %+v, want disabled with the reason", kw)
+	}
+	first := *kw.DisabledAt
+
+	kw, err = repo.Disable(ctx, id, "again")
+	if err != nil {
+		t.Fatalf("second Disable: %v", err)
+	}
+	if !kw.DisabledAt.Equal(first) || kw.DisabledReason != "again" {
+		t.Errorf("second Disable = %v %q, want the first time kept and the new reason", kw.DisabledAt, kw.DisabledReason)
+	}
+
+	kw, err = repo.Enable(ctx, id)
+	if err != nil {
+		t.Fatalf("Enable: %v", err)
+	}
+	if !kw.Enabled() || kw.DisabledReason != "" {
+		t.Errorf("Enable = %+v, want enabled without a reason", kw)
+	}
+
+	if _, err := repo.Disable(ctx, id+1000, "x"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Disable(missing) error = %v, want ErrNotFound", err)
+	}
+	if _, err := repo.Enable(ctx, id+1000); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Enable(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestKeywordMute(t *testing.T) {
+	pool := testPool(t)
+	repo := NewKeywordRepository(pool)
+	ctx := context.Background()
+
+	id := seedKeyword(t, pool, "example")
+	until := time.Now().Add(time.Hour).Truncate(time.Microsecond)
+	kw, err := repo.Mute(ctx, id, until, model.MuteScopeMatching)
+	if err != nil {
+		t.Fatalf("Mute: %v", err)
+	}
+	if kw.MutedUntil == nil || !kw.MutedUntil.Equal(until) || kw.MuteScope != model.MuteScopeMatching {
+		t.Errorf("Mute = %+v, want muted until %v for matching", kw, until)
+	}
+
+	kw, err = repo.Unmute(ctx, id)
+	if err != nil {
+		t.Fatalf("Unmute: %v", err)
+	}
+	if kw.MutedUntil != nil || kw.MuteScope != "" {
+		t.Errorf("Unmute = %+v, want no mute", kw)
+	}
+
+	if _, err := repo.Mute(ctx, id+1000, until, model.MuteScopeMatching); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Mute(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestKeywordMute_Expires(t *testing.T) {
+	pool := testPool(t)
+	repo := NewKeywordRepository(pool)
+	ctx := context.Background()
+
+	id := seedKeyword(t, pool, "example")
+	// Muting into the past is refused by the handler; here it stands in
+	// for a mute that has run out.
+	if _, err := repo.Mute(ctx, id, time.Now().Add(-time.Second), model.MuteScopeNotifications); err != nil {
+		t.Fatalf("Mute: %v", err)
+	}
+	keywords, err := repo.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(keywords) != 1 || keywords[0].MutedUntil != nil || keywords[0].MuteScope != "" {
+		t.Errorf("List = %+v, want the expired mute hidden", keywords)
+	}
+}
+
+func TestKeywordMute_SkipsNotifications(t *testing.T) {
+	pool := testPool(t)
+	repo := NewKeywordRepository(pool)
+	ctx := context.Background()
+
+	muted := seedKeyword(t, pool, "muted")
+	expired := seedKeyword(t, pool, "expired")
+	if _, err := repo.Mute(ctx, muted, time.Now().Add(time.Hour), model.MuteScopeNotifications); err != nil {
+		t.Fatalf("Mute: %v", err)
+	}
+	if _, err := repo.Mute(ctx, expired, time.Now().Add(-time.Second), model.MuteScopeNotifications); err != nil {
+		t.Fatalf("Mute: %v", err)
+	}
+	seedCert(t, pool, muted, "m01", nil)
+	expiredCert := seedCert(t, pool, expired, "e01", nil)
+
+	var ids []int
+	rows, err := pool.Query(ctx, `SELECT certificate_id FROM notification_outbox`)
+	if err != nil {
+		t.Fatalf("query outbox: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) != 1 || ids[0] != expiredCert {
+		t.Errorf("outbox certificates = %v, want only %d", ids, expiredCert)
+	}
+}
+
+func TestKeywordActiveWindow(t *testing.T) {
+	pool := testPool(t)
+	repo := NewKeywordRepository(pool)
+	ctx := context.Background()
+
+	from := time.Now().Add(-time.Hour).UTC().Truncate(time.Microsecond)
+	until := from.Add(14 * 24 * time.Hour)
+	kw, err := repo.Create(ctx, "campaign", "", nil, nil, &from, &until)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	got, err := repo.Get(ctx, kw.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.ActiveFrom == nil || !got.ActiveFrom.Equal(from) || got.ActiveUntil == nil || !got.ActiveUntil.Equal(until) {
+		t.Errorf("stored window = %v..%v, want %v..%v", got.ActiveFrom, got.ActiveUntil, from, until)
+	}
+
+	open, err := repo.Create(ctx, "forever", "", nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if open.ActiveFrom != nil || open.ActiveUntil != nil {
+		t.Errorf("window = %v..%v, want none", open.ActiveFrom, open.ActiveUntil)
+	}
+}
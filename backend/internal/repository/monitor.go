@@ -22,12 +22,16 @@ func (r *MonitorRepository) Get(ctx context.Context) (*model.MonitorState, error
 	err := r.pool.QueryRow(ctx,
 		`SELECT last_processed_index, last_tree_size, last_run_at,
 			total_processed, certs_in_last_cycle, matches_in_last_cycle,
-			parse_errors_in_last_cycle, is_running, last_error, updated_at
+			parse_errors_in_last_cycle, is_running, last_error,
+			last_error_first_seen, last_error_count, updated_at,
+			ct_log_url, ct_log_configured_url, log_frozen_since
 		FROM monitor_state WHERE id = 1`,
 	).Scan(
 		&s.LastProcessedIndex, &s.LastTreeSize, &s.LastRunAt,
 		&s.TotalProcessed, &s.CertsInLastCycle, &s.MatchesInLastCycle,
-		&s.ParseErrorsInLastCycle, &s.IsRunning, &s.LastError, &s.UpdatedAt,
+		&s.ParseErrorsInLastCycle, &s.IsRunning, &s.LastError,
+		&s.LastErrorFirstSeen, &s.LastErrorCount, &s.UpdatedAt,
+		&s.CTLogURL, &s.CTLogConfiguredURL, &s.LogFrozenSince,
 	)
 	if err != nil {
 		return nil, err
@@ -35,10 +39,21 @@ func (r *MonitorRepository) Get(ctx context.Context) (*model.MonitorState, error
 	return &s, nil
 }
 
+// Update overwrites the cycle counters. An unchanged last_error keeps its
+// occurrence count; a different one starts a new run at 1, and an empty one
+// clears it.
 func (r *MonitorRepository) Update(ctx context.Context, state *model.MonitorState) error {
 	now := time.Now()
 	_, err := r.pool.Exec(ctx,
 		`UPDATE monitor_state SET
+			last_error_count = CASE
+				WHEN $9 = '' THEN 0
+				WHEN $9 = last_error THEN last_error_count
+				ELSE 1 END,
+			last_error_first_seen = CASE
+				WHEN $9 = '' THEN NULL
+				WHEN $9 = last_error THEN last_error_first_seen
+				ELSE $10 END,
 			last_processed_index = $1,
 			last_tree_size = $2,
 			last_run_at = $3,
@@ -57,6 +72,22 @@ func (r *MonitorRepository) Update(ctx context.Context, state *model.MonitorStat
 	return err
 }
 
+// ResetCycleStats zeroes the last-cycle counters, e.g. after a backfill
+// whose figures would otherwise show until the next cycle. The position in
+// the log, the totals and the error state are left alone.
+func (r *MonitorRepository) ResetCycleStats(ctx context.Context) error {
+	_, err := r.pool.Exec(ctx,
+		`UPDATE monitor_state SET
+			certs_in_last_cycle = 0,
+			matches_in_last_cycle = 0,
+			parse_errors_in_last_cycle = 0,
+			updated_at = $1
+		WHERE id = 1`,
+		time.Now(),
+	)
+	return err
+}
+
 func (r *MonitorRepository) SetRunning(ctx context.Context, running bool) error {
 	_, err := r.pool.Exec(ctx,
 		`UPDATE monitor_state SET is_running = $1, updated_at = $2 WHERE id = 1`,
@@ -65,10 +96,52 @@ func (r *MonitorRepository) SetRunning(ctx context.Context, running bool) error
 	return err
 }
 
+// SetError records the outcome of a cycle. Repeating the current message
+// increments last_error_count and keeps last_error_first_seen; a new message
+// restarts the count at 1 and an empty one clears both.
 func (r *MonitorRepository) SetError(ctx context.Context, errMsg string) error {
 	_, err := r.pool.Exec(ctx,
-		`UPDATE monitor_state SET last_error = $1, updated_at = $2 WHERE id = 1`,
+		`UPDATE monitor_state SET
+			last_error_count = CASE
+				WHEN $1 = '' THEN 0
+				WHEN $1 = last_error THEN last_error_count + 1
+				ELSE 1 END,
+			last_error_first_seen = CASE
+				WHEN $1 = '' THEN NULL
+				WHEN $1 = last_error THEN COALESCE(last_error_first_seen, $2)
+				ELSE $2 END,
+			last_error = $1,
+			updated_at = $2
+		WHERE id = 1`,
 		errMsg, time.Now(),
 	)
 	return err
 }
+
+// SetLogFrozen records since when the log has been frozen; nil clears it.
+func (r *MonitorRepository) SetLogFrozen(ctx context.Context, since *time.Time) error {
+	_, err := r.pool.Exec(ctx,
+		`UPDATE monitor_state SET log_frozen_since = $1, updated_at = $2 WHERE id = 1`,
+		since, time.Now(),
+	)
+	return err
+}
+
+// SwitchLog points the cursor at index of the log at url, whose tree has
+// treeSize entries, noting the CT_LOG_URL it was switched under so a
+// restart with the same configuration resumes on url. The frozen mark is
+// cleared; totals and the error state are left alone.
+func (r *MonitorRepository) SwitchLog(ctx context.Context, url, configuredURL string, index, treeSize int64) error {
+	_, err := r.pool.Exec(ctx,
+		`UPDATE monitor_state SET
+			ct_log_url = $1,
+			ct_log_configured_url = $2,
+			last_processed_index = $3,
+			last_tree_size = $4,
+			log_frozen_since = NULL,
+			updated_at = $5
+		WHERE id = 1`,
+		url, configuredURL, index, treeSize, time.Now(),
+	)
+	return err
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+func TestMonitorSetError_CountsRepeats(t *testing.T) {
+	pool := testPool(t)
+	repo := NewMonitorRepository(pool)
+	ctx := context.Background()
+
+	for range 3 {
+		if err := repo.SetError(ctx, "failed to get STH: timeout"); err != nil {
+			t.Fatalf("SetError() error = %v", err)
+		}
+	}
+	s, err := repo.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if s.LastErrorCount != 3 {
+		t.Errorf("LastErrorCount = %d, want 3", s.LastErrorCount)
+	}
+	if s.LastErrorFirstSeen == nil {
+		t.Fatal("LastErrorFirstSeen = nil, want set")
+	}
+	firstSeen := *s.LastErrorFirstSeen
+
+	if err := repo.SetError(ctx, "failed to fetch entries: 429"); err != nil {
+		t.Fatalf("SetError() error = %v", err)
+	}
+	s, _ = repo.Get(ctx)
+	if s.LastErrorCount != 1 {
+		t.Errorf("LastErrorCount after change = %d, want 1", s.LastErrorCount)
+	}
+	if s.LastErrorFirstSeen == nil || s.LastErrorFirstSeen.Before(firstSeen) {
+		t.Errorf("LastErrorFirstSeen = %v, want reset to a later time", s.LastErrorFirstSeen)
+	}
+
+	if err := repo.SetError(ctx, ""); err != nil {
+		t.Fatalf("SetError() error = %v", err)
+	}
+	s, _ = repo.Get(ctx)
+	if s.LastError != "" || s.LastErrorCount != 0 || s.LastErrorFirstSeen != nil {
+		t.Errorf("after clear: error=%q count=%d first_seen=%v, want all cleared",
+			s.LastError, s.LastErrorCount, s.LastErrorFirstSeen)
+	}
+}
+
+func TestMonitorResetCycleStats(t *testing.T) {
+	pool := testPool(t)
+	repo := NewMonitorRepository(pool)
+	ctx := context.Background()
+
+	if err := repo.Update(ctx, &model.MonitorState{
+		LastProcessedIndex:     5000,
+		LastTreeSize:           6000,
+		TotalProcessed:         12000,
+		CertsInLastCycle:       900,
+		MatchesInLastCycle:     40,
+		ParseErrorsInLastCycle: 3,
+		IsRunning:              true,
+		LastError:              "failed to get STH: timeout",
+	}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	if err := repo.ResetCycleStats(ctx); err != nil {
+		t.Fatalf("ResetCycleStats() error = %v", err)
+	}
+	s, err := repo.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if s.CertsInLastCycle != 0 || s.MatchesInLastCycle != 0 || s.ParseErrorsInLastCycle != 0 {
+		t.Errorf("cycle counters = %d/%d/%d, want all zero",
+			s.CertsInLastCycle, s.MatchesInLastCycle, s.ParseErrorsInLastCycle)
+	}
+	if s.LastProcessedIndex != 5000 || s.LastTreeSize != 6000 || s.TotalProcessed != 12000 ||
+		!s.IsRunning || s.LastError != "failed to get STH: timeout" || s.LastErrorCount != 1 {
+		t.Errorf("state = %+v, want everything but the cycle counters unchanged", s)
+	}
+}
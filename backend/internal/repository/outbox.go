@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+type OutboxRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewOutboxRepository(pool *pgxpool.Pool) *OutboxRepository {
+	return &OutboxRepository{pool: pool}
+}
+
+// enqueueOutbox adds a pending notification inside tx, so it commits or
+// rolls back together with the match it describes.
+func enqueueOutbox(ctx context.Context, tx pgx.Tx, certificateID int, payload []byte) error {
+	_, err := tx.Exec(ctx,
+		`INSERT INTO notification_outbox (certificate_id, payload) VALUES ($1, $2)`,
+		certificateID, payload,
+	)
+	return err
+}
+
+// Claim leases up to limit due messages until leaseUntil. A dispatcher that
+// crashes mid-delivery leaves the rows pending, and they become due again
+// once the lease expires. SKIP LOCKED keeps concurrent dispatchers from
+// claiming the same rows.
+func (r *OutboxRepository) Claim(ctx context.Context, limit int, leaseUntil time.Time) ([]model.OutboxMessage, error) {
+	rows, err := r.pool.Query(ctx,
+		`UPDATE notification_outbox o SET next_attempt_at = $2
+		FROM (
+			SELECT id FROM notification_outbox
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		) due
+		WHERE o.id = due.id
+		RETURNING o.id, o.certificate_id, o.payload, o.attempts, o.created_at`,
+		limit, leaseUntil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var msgs []model.OutboxMessage
+	for rows.Next() {
+		var m model.OutboxMessage
+		if err := rows.Scan(&m.ID, &m.CertificateID, &m.Payload, &m.Attempts, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs, rows.Err()
+}
+
+func (r *OutboxRepository) MarkSent(ctx context.Context, id int64) error {
+	_, err := r.pool.Exec(ctx,
+		`UPDATE notification_outbox
+		SET status = 'sent', attempts = attempts + 1, last_error = '', sent_at = NOW()
+		WHERE id = $1`,
+		id,
+	)
+	return err
+}
+
+// MarkFailed records a failed attempt. The message is retried at retryAt
+// unless dead is set, which moves it to the failed state for good.
+func (r *OutboxRepository) MarkFailed(ctx context.Context, id int64, errMsg string, retryAt time.Time, dead bool) error {
+	status := model.OutboxStatusPending
+	if dead {
+		status = model.OutboxStatusFailed
+	}
+	_, err := r.pool.Exec(ctx,
+		`UPDATE notification_outbox
+		SET status = $2, attempts = attempts + 1, last_error = $3, next_attempt_at = $4
+		WHERE id = $1`,
+		id, status, errMsg, retryAt,
+	)
+	return err
+}
+
+// MarkDropped moves claimed messages that a full delivery queue turned
+// away to the dropped state, so they are not redelivered when the lease
+// expires. Messages no longer pending are left alone.
+func (r *OutboxRepository) MarkDropped(ctx context.Context, ids []int64) error {
+	_, err := r.pool.Exec(ctx,
+		`UPDATE notification_outbox
+		SET status = 'dropped', last_error = 'delivery queue full'
+		WHERE id = ANY($1) AND status = 'pending'`,
+		ids,
+	)
+	return err
+}
+
+// Prune deletes delivered, dead and dropped messages created before the
+// cutoff.
+func (r *OutboxRepository) Prune(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := r.pool.Exec(ctx,
+		`DELETE FROM notification_outbox
+		WHERE status IN ('sent', 'failed', 'dropped') AND created_at < $1`,
+		before,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+func TestCertificateCreate_EnqueuesOutboxOnce(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	kwID := seedKeyword(t, pool, "example")
+	certID := seedCert(t, pool, kwID, "ob01", nil)
+	// Re-storing the same serial/keyword must not notify again.
+	seedCert(t, pool, kwID, "ob01", nil)
+
+	var n, gotCertID int
+	if err := pool.QueryRow(ctx,
+		`SELECT COUNT(*), MAX(certificate_id) FROM notification_outbox`,
+	).Scan(&n, &gotCertID); err != nil {
+		t.Fatalf("count outbox: %v", err)
+	}
+	if n != 1 || gotCertID != certID {
+		t.Errorf("outbox rows = %d for cert %d, want 1 for cert %d", n, gotCertID, certID)
+	}
+}
+
+func TestOutbox_ClaimAndMark(t *testing.T) {
+	pool := testPool(t)
+	repo := NewOutboxRepository(pool)
+	ctx := context.Background()
+
+	kwID := seedKeyword(t, pool, "example")
+	seedCert(t, pool, kwID, "ob02", nil)
+	seedCert(t, pool, kwID, "ob03", nil)
+
+	lease := time.Now().Add(time.Minute)
+	msgs, err := repo.Claim(ctx, 10, lease)
+	if err != nil {
+		t.Fatalf("Claim() error = %v", err)
+	}
+	if len(msgs) != 2 {
+		t.Fatalf("claimed %d, want 2", len(msgs))
+	}
+	var cert model.MatchedCertificate
+	if err := json.Unmarshal(msgs[0].Payload, &cert); err != nil || cert.SerialNumber != "ob02" {
+		t.Errorf("payload = %s (err %v), want serial ob02", msgs[0].Payload, err)
+	}
+
+	// Leased rows are not claimable again until the lease expires.
+	again, err := repo.Claim(ctx, 10, lease)
+	if err != nil {
+		t.Fatalf("Claim() error = %v", err)
+	}
+	if len(again) != 0 {
+		t.Errorf("re-claimed %d leased rows, want 0", len(again))
+	}
+
+	if err := repo.MarkSent(ctx, msgs[0].ID); err != nil {
+		t.Fatalf("MarkSent() error = %v", err)
+	}
+	if err := repo.MarkFailed(ctx, msgs[1].ID, "boom", time.Now().Add(-time.Second), false); err != nil {
+		t.Fatalf("MarkFailed() error = %v", err)
+	}
+
+	retry, err := repo.Claim(ctx, 10, lease)
+	if err != nil {
+		t.Fatalf("Claim() error = %v", err)
+	}
+	if len(retry) != 1 || retry[0].ID != msgs[1].ID || retry[0].Attempts != 1 {
+		t.Errorf("retry claim = %+v, want only the failed message with 1 attempt", retry)
+	}
+
+	if err := repo.MarkFailed(ctx, msgs[1].ID, "boom", time.Now().Add(-time.Second), true); err != nil {
+		t.Fatalf("MarkFailed() error = %v", err)
+	}
+	pruned, err := repo.Prune(ctx, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if pruned != 2 {
+		t.Errorf("pruned %d, want 2 (sent + dead)", pruned)
+	}
+}
+
+func TestOutbox_MarkDropped(t *testing.T) {
+	pool := testPool(t)
+	repo := NewOutboxRepository(pool)
+	ctx := context.Background()
+
+	kwID := seedKeyword(t, pool, "example")
+	seedCert(t, pool, kwID, "ob04", nil)
+
+	// An expired lease, so the row would be claimable again if it were
+	// still pending.
+	msgs, err := repo.Claim(ctx, 10, time.Now().Add(-time.Second))
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("Claim() = %d messages, %v; want 1", len(msgs), err)
+	}
+	if err := repo.MarkDropped(ctx, []int64{msgs[0].ID}); err != nil {
+		t.Fatalf("MarkDropped() error = %v", err)
+	}
+
+	again, err := repo.Claim(ctx, 10, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("Claim() error = %v", err)
+	}
+	if len(again) != 0 {
+		t.Errorf("re-claimed %d dropped rows, want 0", len(again))
+	}
+	pruned, err := repo.Prune(ctx, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if pruned != 1 {
+		t.Errorf("pruned %d, want the dropped row", pruned)
+	}
+}
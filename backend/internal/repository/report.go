@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+type ReportRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewReportRepository(pool *pgxpool.Pool) *ReportRepository {
+	return &ReportRepository{pool: pool}
+}
+
+// Save stores report under its date, replacing any report already stored
+// for that day.
+func (r *ReportRepository) Save(ctx context.Context, report *model.DailyReport) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	_, err = r.pool.Exec(ctx,
+		`INSERT INTO daily_reports (report_date, timezone, report, generated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (report_date) DO UPDATE SET
+			timezone = EXCLUDED.timezone, report = EXCLUDED.report, generated_at = EXCLUDED.generated_at`,
+		report.Date, report.Timezone, data, report.GeneratedAt)
+	return err
+}
+
+// Get returns the report stored for date (YYYY-MM-DD), or ErrNotFound.
+func (r *ReportRepository) Get(ctx context.Context, date string) (*model.DailyReport, error) {
+	return r.scan(r.pool.QueryRow(ctx,
+		`SELECT report FROM daily_reports WHERE report_date = $1`, date))
+}
+
+// Latest returns the report for the most recent day, or ErrNotFound when
+// none has been stored.
+func (r *ReportRepository) Latest(ctx context.Context) (*model.DailyReport, error) {
+	return r.scan(r.pool.QueryRow(ctx,
+		`SELECT report FROM daily_reports ORDER BY report_date DESC LIMIT 1`))
+}
+
+func (r *ReportRepository) scan(row pgx.Row) (*model.DailyReport, error) {
+	var data []byte
+	if err := row.Scan(&data); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	var report model.DailyReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+func TestReportSaveGetLatest(t *testing.T) {
+	pool := testPool(t)
+	repo := NewReportRepository(pool)
+	ctx := context.Background()
+
+	if _, err := repo.Latest(ctx); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Latest() on empty table error = %v, want ErrNotFound", err)
+	}
+
+	for _, date := range []string{"2026-03-09", "2026-03-10"} {
+		from, _ := time.Parse(time.DateOnly, date)
+		if err := repo.Save(ctx, &model.DailyReport{
+			Date: date, Timezone: "UTC", From: from, To: from.AddDate(0, 0, 1), GeneratedAt: time.Now(),
+			MatchSummary: model.MatchSummary{NewMatches: 1},
+		}); err != nil {
+			t.Fatalf("Save(%s) error = %v", date, err)
+		}
+	}
+	// Regenerating a day replaces its report.
+	if err := repo.Save(ctx, &model.DailyReport{
+		Date: "2026-03-09", Timezone: "UTC", GeneratedAt: time.Now(),
+		MatchSummary: model.MatchSummary{NewMatches: 7},
+	}); err != nil {
+		t.Fatalf("Save() again error = %v", err)
+	}
+
+	got, err := repo.Get(ctx, "2026-03-09")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.NewMatches != 7 {
+		t.Errorf("NewMatches = %d, want 7 from the replacement", got.NewMatches)
+	}
+	latest, err := repo.Latest(ctx)
+	if err != nil {
+		t.Fatalf("Latest() error = %v", err)
+	}
+	if latest.Date != "2026-03-10" {
+		t.Errorf("Latest().Date = %s, want 2026-03-10", latest.Date)
+	}
+	if _, err := repo.Get(ctx, "2026-01-01"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of a missing day error = %v, want ErrNotFound", err)
+	}
+}
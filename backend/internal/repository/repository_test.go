@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/database"
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+// testPool connects to the database named by TEST_DATABASE_URL, applies the
+// migrations and empties every table. Tests are skipped when it is unset so
+// `go test ./...` keeps working without a database.
+//
+// Repository tests share one schema, so run them with -p 1 when other
+// packages also use TEST_DATABASE_URL.
+func testPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set; skipping repository integration test")
+	}
+
+	pool, err := database.Connect(url, 0)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	if err := database.Migrate(pool); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	ctx := context.Background()
+	if _, err := pool.Exec(ctx,
+		`TRUNCATE keywords, keyword_groups, matched_certificates, certificates, audit_log, notification_outbox, webhooks, webhook_deliveries, daily_reports RESTART IDENTITY CASCADE`); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+	if _, err := pool.Exec(ctx, `DELETE FROM monitor_state`); err != nil {
+		t.Fatalf("reset monitor_state: %v", err)
+	}
+	if _, err := pool.Exec(ctx, `INSERT INTO monitor_state (id) VALUES (1)`); err != nil {
+		t.Fatalf("seed monitor_state: %v", err)
+	}
+	return pool
+}
+
+func seedKeyword(t *testing.T, pool *pgxpool.Pool, value string) int {
+	t.Helper()
+	kw, err := NewKeywordRepository(pool).Create(context.Background(), value, model.MatchModeSubstring, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("seed keyword %q: %v", value, err)
+	}
+	return kw.ID
+}
+
+// seedCert inserts a match for keywordID and returns its ID. The
+// certificate fields can be adjusted via mutate before insert.
+func seedCert(t *testing.T, pool *pgxpool.Pool, keywordID int, serial string, mutate func(*model.MatchedCertificate)) int {
+	t.Helper()
+	cert := &model.MatchedCertificate{
+		SerialNumber:  serial,
+		CommonName:    serial + ".example.com",
+		SANs:          []string{serial + ".example.com"},
+		Issuer:        "Test CA",
+		NotBefore:     time.Now().Add(-time.Hour),
+		NotAfter:      time.Now().Add(24 * time.Hour),
+		KeywordID:     keywordID,
+		MatchedDomain: serial + ".example.com",
+		CTLogIndex:    1,
+		IsServerAuth:  true,
+	}
+	if mutate != nil {
+		mutate(cert)
+	}
+	if err := NewCertificateRepository(pool).Create(context.Background(), cert); err != nil {
+		t.Fatalf("seed cert %q: %v", serial, err)
+	}
+
+	var id int
+	if err := pool.QueryRow(context.Background(),
+		`SELECT id FROM matched_certificates WHERE serial_number = $1 AND keyword_id = $2`,
+		cert.SerialNumber, keywordID,
+	).Scan(&id); err != nil {
+		t.Fatalf("lookup seeded cert %q: %v", serial, err)
+	}
+	return id
+}
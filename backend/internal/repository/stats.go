@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+// Stats returns aggregate counts over all stored matches. The issuer and
+// per-day groupings are capped by limits to bound query cost and payload.
+func (r *CertificateRepository) Stats(ctx context.Context, limits model.StatsLimits) (*model.CertificateStats, error) {
+	stats := model.CertificateStats{StatsLimits: limits}
+	if err := r.pool.QueryRow(ctx,
+		`SELECT COUNT(*) FROM matched_certificates`,
+	).Scan(&stats.Total); err != nil {
+		return nil, err
+	}
+
+	var err error
+	stats.ByMatchedField, err = r.groupCounts(ctx,
+		`SELECT CASE WHEN matched_field = '' THEN 'unknown' ELSE matched_field END AS key, COUNT(*)
+		FROM matched_certificates
+		GROUP BY key
+		ORDER BY COUNT(*) DESC, key`)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.ByPrecert, err = r.groupCounts(ctx,
+		`SELECT CASE WHEN is_precert THEN 'precert' ELSE 'final' END AS key, COUNT(*)
+		FROM matched_certificates
+		GROUP BY key
+		ORDER BY COUNT(*) DESC, key`)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.ByIssuerClass, err = r.groupCounts(ctx,
+		`SELECT issuer_class, COUNT(*)
+		FROM matched_certificates
+		GROUP BY issuer_class
+		ORDER BY COUNT(*) DESC, issuer_class`)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.TopIssuers, err = r.groupCounts(ctx,
+		`SELECT issuer, COUNT(*)
+		FROM matched_certificates
+		GROUP BY issuer
+		ORDER BY COUNT(*) DESC, issuer
+		LIMIT $1`, limits.TopN)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.PerDay, err = r.groupCounts(ctx,
+		`SELECT to_char(discovered_at AT TIME ZONE 'UTC', 'YYYY-MM-DD') AS key, COUNT(*)
+		FROM matched_certificates
+		WHERE discovered_at >= date_trunc('day', NOW() AT TIME ZONE 'UTC') AT TIME ZONE 'UTC'
+			- make_interval(days => $1::int - 1)
+		GROUP BY key
+		ORDER BY key DESC
+		LIMIT $1::int`, limits.Days)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
+
+// TopRegistrableDomains returns the registrable domains with the most
+// matches, largest first.
+func (r *CertificateRepository) TopRegistrableDomains(ctx context.Context, limit int) ([]model.DomainCount, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT registrable_domain, COUNT(*), bool_or(registrable_domain_raw)
+		FROM matched_certificates
+		WHERE registrable_domain IS NOT NULL
+		GROUP BY registrable_domain
+		ORDER BY COUNT(*) DESC, registrable_domain
+		LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	domains := []model.DomainCount{}
+	for rows.Next() {
+		var d model.DomainCount
+		if err := rows.Scan(&d.Domain, &d.Count, &d.Raw); err != nil {
+			return nil, err
+		}
+		domains = append(domains, d)
+	}
+	return domains, rows.Err()
+}
+
+// firstSeen is when a match was first observed; rows stored before
+// first_seen_at existed fall back to discovered_at, which ConflictUpdate
+// would otherwise move forward on every re-observation.
+const firstSeen = `COALESCE(mc.first_seen_at, mc.discovered_at)`
+
+// Summary aggregates the matches first seen in [from, to): their count,
+// counts per keyword, the topN registrable domains and up to topN matches
+// scoring at least minRisk.
+func (r *CertificateRepository) Summary(ctx context.Context, from, to time.Time, topN, minRisk int) (*model.MatchSummary, error) {
+	window := ` WHERE ` + firstSeen + ` >= $1 AND ` + firstSeen + ` < $2`
+	var s model.MatchSummary
+	if err := r.pool.QueryRow(ctx,
+		`SELECT COUNT(*) FROM matched_certificates mc`+window, from, to,
+	).Scan(&s.NewMatches); err != nil {
+		return nil, err
+	}
+
+	var err error
+	s.ByKeyword, err = r.groupCounts(ctx,
+		`SELECT k.value, COUNT(*)
+		FROM matched_certificates mc JOIN keywords k ON k.id = mc.keyword_id`+window+`
+		GROUP BY k.value
+		ORDER BY COUNT(*) DESC, k.value`, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.pool.Query(ctx,
+		`SELECT mc.registrable_domain, COUNT(*), bool_or(mc.registrable_domain_raw)
+		FROM matched_certificates mc`+window+` AND mc.registrable_domain IS NOT NULL
+		GROUP BY mc.registrable_domain
+		ORDER BY COUNT(*) DESC, mc.registrable_domain
+		LIMIT $3`, from, to, topN)
+	if err != nil {
+		return nil, err
+	}
+	s.TopDomains = []model.DomainCount{}
+	for rows.Next() {
+		var d model.DomainCount
+		if err := rows.Scan(&d.Domain, &d.Count, &d.Raw); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		s.TopDomains = append(s.TopDomains, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = r.pool.Query(ctx,
+		`SELECT mc.id, mc.matched_domain, k.value, mc.risk_score, mc.discovered_at
+		FROM matched_certificates mc JOIN keywords k ON k.id = mc.keyword_id`+window+` AND mc.risk_score >= $3
+		ORDER BY mc.risk_score DESC, mc.discovered_at DESC
+		LIMIT $4`, from, to, minRisk, topN)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	s.HighRisk = []model.ReportMatch{}
+	for rows.Next() {
+		var m model.ReportMatch
+		if err := rows.Scan(&m.ID, &m.MatchedDomain, &m.Keyword, &m.RiskScore, &m.DiscoveredAt); err != nil {
+			return nil, err
+		}
+		s.HighRisk = append(s.HighRisk, m)
+	}
+	return &s, rows.Err()
+}
+
+// groupCounts runs a query returning (key, count) rows.
+func (r *CertificateRepository) groupCounts(ctx context.Context, query string, args ...any) ([]model.StatsBucket, error) {
+	rows, err := r.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buckets := []model.StatsBucket{}
+	for rows.Next() {
+		var b model.StatsBucket
+		if err := rows.Scan(&b.Key, &b.Count); err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, b)
+	}
+	return buckets, rows.Err()
+}
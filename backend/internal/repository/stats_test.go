@@ -0,0 +1,239 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+func TestCertificateStats_GroupsByFieldAndPrecert(t *testing.T) {
+	pool := testPool(t)
+	repo := NewCertificateRepository(pool)
+
+	kwID := seedKeyword(t, pool, "example")
+	mix := []struct {
+		serial  string
+		field   string
+		precert bool
+	}{
+		{"s1", model.MatchFieldSAN, true},
+		{"s2", model.MatchFieldSAN, true},
+		{"s3", model.MatchFieldSAN, false},
+		{"s4", model.MatchFieldCN, true},
+	}
+	for _, m := range mix {
+		seedCert(t, pool, kwID, m.serial, func(c *model.MatchedCertificate) {
+			c.MatchedField = m.field
+			c.IsPrecert = m.precert
+		})
+	}
+
+	stats, err := repo.Stats(context.Background(), model.StatsLimits{TopN: 10, Days: 30})
+	if err != nil {
+		t.Fatalf("Stats() error = %v", err)
+	}
+	if stats.Total != 4 {
+		t.Errorf("Total = %d, want 4", stats.Total)
+	}
+
+	wantField := map[string]int{model.MatchFieldSAN: 3, model.MatchFieldCN: 1}
+	for _, b := range stats.ByMatchedField {
+		if wantField[b.Key] != b.Count {
+			t.Errorf("ByMatchedField[%s] = %d, want %d", b.Key, b.Count, wantField[b.Key])
+		}
+	}
+	if len(stats.ByMatchedField) != 2 || stats.ByMatchedField[0].Key != model.MatchFieldSAN {
+		t.Errorf("ByMatchedField = %v, want san first", stats.ByMatchedField)
+	}
+
+	wantPrecert := map[string]int{"precert": 3, "final": 1}
+	for _, b := range stats.ByPrecert {
+		if wantPrecert[b.Key] != b.Count {
+			t.Errorf("ByPrecert[%s] = %d, want %d", b.Key, b.Count, wantPrecert[b.Key])
+		}
+	}
+}
+
+func TestCertificateStats_GroupsByIssuerClass(t *testing.T) {
+	pool := testPool(t)
+	repo := NewCertificateRepository(pool)
+
+	kwID := seedKeyword(t, pool, "example")
+	classes := []string{model.IssuerFreeAutomated, model.IssuerFreeAutomated, model.IssuerPaid, ""}
+	for i, class := range classes {
+		seedCert(t, pool, kwID, fmt.Sprintf("s%d", i), func(c *model.MatchedCertificate) {
+			c.IssuerClass = class
+		})
+	}
+
+	stats, err := repo.Stats(context.Background(), model.StatsLimits{TopN: 10, Days: 30})
+	if err != nil {
+		t.Fatalf("Stats() error = %v", err)
+	}
+	want := []model.StatsBucket{
+		{Key: model.IssuerFreeAutomated, Count: 2},
+		{Key: model.IssuerPaid, Count: 1},
+		{Key: model.IssuerUnknown, Count: 1},
+	}
+	if len(stats.ByIssuerClass) != len(want) {
+		t.Fatalf("ByIssuerClass = %v, want %v", stats.ByIssuerClass, want)
+	}
+	for i, b := range want {
+		if stats.ByIssuerClass[i] != b {
+			t.Errorf("ByIssuerClass = %v, want %v", stats.ByIssuerClass, want)
+			break
+		}
+	}
+}
+
+func TestCertificateStats_CapsTopIssuers(t *testing.T) {
+	pool := testPool(t)
+	repo := NewCertificateRepository(pool)
+
+	kwID := seedKeyword(t, pool, "example")
+	issuers := []string{"CA A", "CA A", "CA A", "CA B", "CA B", "CA C", "CA D"}
+	for i, issuer := range issuers {
+		seedCert(t, pool, kwID, fmt.Sprintf("s%d", i), func(c *model.MatchedCertificate) {
+			c.Issuer = issuer
+		})
+	}
+
+	stats, err := repo.Stats(context.Background(), model.StatsLimits{TopN: 2, Days: 7})
+	if err != nil {
+		t.Fatalf("Stats() error = %v", err)
+	}
+	if len(stats.TopIssuers) != 2 {
+		t.Fatalf("TopIssuers = %v, want 2 entries", stats.TopIssuers)
+	}
+	if stats.TopIssuers[0] != (model.StatsBucket{Key: "CA A", Count: 3}) ||
+		stats.TopIssuers[1] != (model.StatsBucket{Key: "CA B", Count: 2}) {
+		t.Errorf("TopIssuers = %v, want CA A x3, CA B x2", stats.TopIssuers)
+	}
+	if stats.TopN != 2 || stats.Days != 7 {
+		t.Errorf("limits = %+v, want top_n 2, days 7", stats.StatsLimits)
+	}
+	if len(stats.PerDay) != 1 || stats.PerDay[0].Count != len(issuers) {
+		t.Errorf("PerDay = %v, want one day with %d matches", stats.PerDay, len(issuers))
+	}
+}
+
+func TestTopRegistrableDomains(t *testing.T) {
+	pool := testPool(t)
+	repo := NewCertificateRepository(pool)
+
+	kwID := seedKeyword(t, pool, "example")
+	for i, d := range []string{"example.com", "example.com", "example.co.uk", "10.0.0.1"} {
+		seedCert(t, pool, kwID, fmt.Sprintf("s%d", i), func(c *model.MatchedCertificate) {
+			c.RegistrableDomain = d
+			c.RegistrableDomainRaw = d == "10.0.0.1"
+		})
+	}
+
+	domains, err := repo.TopRegistrableDomains(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("TopRegistrableDomains() error = %v", err)
+	}
+	if len(domains) != 2 {
+		t.Fatalf("got %d domains, want 2 (limit)", len(domains))
+	}
+	if domains[0].Domain != "example.com" || domains[0].Count != 2 {
+		t.Errorf("domains[0] = %+v, want example.com x2", domains[0])
+	}
+}
+
+func TestPendingRegistrableDomains(t *testing.T) {
+	pool := testPool(t)
+	repo := NewCertificateRepository(pool)
+	ctx := context.Background()
+
+	kwID := seedKeyword(t, pool, "example")
+	pendingID := seedCert(t, pool, kwID, "legacy", func(c *model.MatchedCertificate) {
+		c.MatchedDomain = "www.example.org"
+	})
+	seedCert(t, pool, kwID, "current", func(c *model.MatchedCertificate) {
+		c.RegistrableDomain = "example.com"
+	})
+
+	pending, err := repo.PendingRegistrableDomains(ctx, 10)
+	if err != nil {
+		t.Fatalf("PendingRegistrableDomains() error = %v", err)
+	}
+	if len(pending) != 1 || pending[pendingID] != "www.example.org" {
+		t.Fatalf("pending = %v, want only row %d", pending, pendingID)
+	}
+
+	if err := repo.SetRegistrableDomain(ctx, pendingID, "example.org", false); err != nil {
+		t.Fatalf("SetRegistrableDomain() error = %v", err)
+	}
+	pending, _ = repo.PendingRegistrableDomains(ctx, 10)
+	if len(pending) != 0 {
+		t.Errorf("pending after set = %v, want none", pending)
+	}
+}
+
+func TestCertificateSummary(t *testing.T) {
+	pool := testPool(t)
+	repo := NewCertificateRepository(pool)
+	ctx := context.Background()
+
+	from := time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 1)
+	paypal := seedKeyword(t, pool, "paypal")
+	bank := seedKeyword(t, pool, "bank")
+	for _, c := range []struct {
+		serial    string
+		keywordID int
+		domain    string
+		firstSeen time.Time
+		risk      int
+	}{
+		{"in1", paypal, "paypal-login.com", from, 90},
+		{"in2", paypal, "paypal-login.com", from.Add(5 * time.Hour), 40},
+		{"in3", bank, "bank-secure.net", to.Add(-time.Second), 60},
+		{"before", paypal, "old.com", from.Add(-time.Second), 100},
+		{"at-end", bank, "next.com", to, 100},
+	} {
+		id := seedCert(t, pool, c.keywordID, c.serial, func(m *model.MatchedCertificate) {
+			m.RegistrableDomain = c.domain
+		})
+		if _, err := pool.Exec(ctx,
+			`UPDATE matched_certificates SET first_seen_at = $2, risk_score = $3 WHERE id = $1`,
+			id, c.firstSeen, c.risk); err != nil {
+			t.Fatalf("backdate %s: %v", c.serial, err)
+		}
+	}
+
+	s, err := repo.Summary(ctx, from, to, 10, 50)
+	if err != nil {
+		t.Fatalf("Summary() error = %v", err)
+	}
+	if s.NewMatches != 3 {
+		t.Errorf("NewMatches = %d, want 3 (window is [from, to))", s.NewMatches)
+	}
+	if len(s.ByKeyword) != 2 || s.ByKeyword[0] != (model.StatsBucket{Key: "paypal", Count: 2}) {
+		t.Errorf("ByKeyword = %+v, want paypal x2 first", s.ByKeyword)
+	}
+	if len(s.TopDomains) != 2 || s.TopDomains[0].Domain != "paypal-login.com" || s.TopDomains[0].Count != 2 {
+		t.Errorf("TopDomains = %+v, want paypal-login.com x2 first", s.TopDomains)
+	}
+	if len(s.HighRisk) != 2 || s.HighRisk[0].RiskScore != 90 || s.HighRisk[1].Keyword != "bank" {
+		t.Errorf("HighRisk = %+v, want scores 90 and 60", s.HighRisk)
+	}
+}
+
+func TestCertificateSummary_EmptyWindow(t *testing.T) {
+	pool := testPool(t)
+	repo := NewCertificateRepository(pool)
+
+	from := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	s, err := repo.Summary(context.Background(), from, from.AddDate(0, 0, 1), 10, 50)
+	if err != nil {
+		t.Fatalf("Summary() error = %v", err)
+	}
+	if s.NewMatches != 0 || s.ByKeyword == nil || s.TopDomains == nil || s.HighRisk == nil {
+		t.Errorf("Summary = %+v, want zero counts and empty, non-nil lists", s)
+	}
+}
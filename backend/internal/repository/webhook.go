@@ -0,0 +1,212 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+type WebhookRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewWebhookRepository(pool *pgxpool.Pool) *WebhookRepository {
+	return &WebhookRepository{pool: pool}
+}
+
+// List returns every webhook with its pending and dead delivery counts.
+func (r *WebhookRepository) List(ctx context.Context) ([]model.Webhook, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT w.id, w.url, w.max_attempts, w.active, w.created_at,
+			COUNT(d.id) FILTER (WHERE d.status = 'pending'),
+			COUNT(d.id) FILTER (WHERE d.status = 'dead')
+		FROM webhooks w
+		LEFT JOIN webhook_deliveries d ON d.webhook_id = w.id
+		GROUP BY w.id
+		ORDER BY w.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hooks []model.Webhook
+	for rows.Next() {
+		var h model.Webhook
+		if err := rows.Scan(&h.ID, &h.URL, &h.MaxAttempts, &h.Active, &h.CreatedAt, &h.Pending, &h.Dead); err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, h)
+	}
+	return hooks, rows.Err()
+}
+
+func (r *WebhookRepository) Create(ctx context.Context, url, secret string, maxAttempts int) (*model.Webhook, error) {
+	h := model.Webhook{Secret: secret}
+	err := r.pool.QueryRow(ctx,
+		`INSERT INTO webhooks (url, secret, max_attempts) VALUES ($1, $2, $3)
+		 RETURNING id, url, max_attempts, active, created_at`, url, secret, maxAttempts,
+	).Scan(&h.ID, &h.URL, &h.MaxAttempts, &h.Active, &h.CreatedAt)
+	return &h, err
+}
+
+// Delete removes a webhook together with its deliveries.
+func (r *WebhookRepository) Delete(ctx context.Context, id int) error {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ListDeliveries returns up to limit of a webhook's deliveries, newest
+// first, optionally only those in status. It returns ErrNotFound when the
+// webhook does not exist.
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, webhookID int, status string, limit int) ([]model.WebhookDelivery, error) {
+	var exists bool
+	if err := r.pool.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM webhooks WHERE id = $1)`, webhookID,
+	).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	rows, err := r.pool.Query(ctx,
+		`SELECT id, webhook_id, event_id, event, payload, status, attempts, last_error,
+			next_attempt_at, sent_at, created_at
+		FROM webhook_deliveries
+		WHERE webhook_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY id DESC
+		LIMIT $3`,
+		webhookID, status, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []model.WebhookDelivery
+	for rows.Next() {
+		var d model.WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.Event, &d.Payload, &d.Status, &d.Attempts,
+			&d.LastError, &d.NextAttemptAt, &d.SentAt, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// Enqueue adds a delivery of the event for every active webhook and
+// returns how many were added. A webhook that already has eventID is
+// skipped, so enqueueing the same event twice is a no-op.
+func (r *WebhookRepository) Enqueue(ctx context.Context, eventID, event string, payload []byte) (int, error) {
+	tag, err := r.pool.Exec(ctx,
+		`INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload)
+		SELECT id, $1, $2, $3 FROM webhooks WHERE active
+		ON CONFLICT (webhook_id, event_id) DO NOTHING`,
+		eventID, event, payload,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// Claim leases up to limit due deliveries until leaseUntil, as
+// OutboxRepository.Claim does, and fills in each one's destination.
+func (r *WebhookRepository) Claim(ctx context.Context, limit int, leaseUntil time.Time) ([]model.WebhookDelivery, error) {
+	rows, err := r.pool.Query(ctx,
+		`WITH claimed AS (
+			UPDATE webhook_deliveries d SET next_attempt_at = $2
+			FROM (
+				SELECT id FROM webhook_deliveries
+				WHERE status = 'pending' AND next_attempt_at <= NOW()
+				ORDER BY id
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			) due
+			WHERE d.id = due.id
+			RETURNING d.*
+		)
+		SELECT c.id, c.webhook_id, c.event_id, c.event, c.payload, c.status, c.attempts, c.created_at,
+			w.url, w.secret, w.max_attempts
+		FROM claimed c
+		JOIN webhooks w ON w.id = c.webhook_id
+		ORDER BY c.id`,
+		limit, leaseUntil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []model.WebhookDelivery
+	for rows.Next() {
+		var d model.WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.Event, &d.Payload, &d.Status, &d.Attempts,
+			&d.CreatedAt, &d.URL, &d.Secret, &d.MaxAttempts); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (r *WebhookRepository) MarkSent(ctx context.Context, id int64) error {
+	_, err := r.pool.Exec(ctx,
+		`UPDATE webhook_deliveries
+		SET status = 'sent', attempts = attempts + 1, last_error = '', sent_at = NOW()
+		WHERE id = $1`,
+		id,
+	)
+	return err
+}
+
+// MarkFailed records a failed attempt. The delivery is retried at retryAt
+// unless dead is set, which moves it to the dead state for good.
+func (r *WebhookRepository) MarkFailed(ctx context.Context, id int64, errMsg string, retryAt time.Time, dead bool) error {
+	status := model.WebhookDeliveryPending
+	if dead {
+		status = model.WebhookDeliveryDead
+	}
+	_, err := r.pool.Exec(ctx,
+		`UPDATE webhook_deliveries
+		SET status = $2, attempts = attempts + 1, last_error = $3, next_attempt_at = $4
+		WHERE id = $1`,
+		id, status, errMsg, retryAt,
+	)
+	return err
+}
+
+// MarkDropped moves claimed deliveries that a full delivery queue turned
+// away to the dropped state, as OutboxRepository.MarkDropped does.
+func (r *WebhookRepository) MarkDropped(ctx context.Context, ids []int64) error {
+	_, err := r.pool.Exec(ctx,
+		`UPDATE webhook_deliveries
+		SET status = 'dropped', last_error = 'delivery queue full'
+		WHERE id = ANY($1) AND status = 'pending'`,
+		ids,
+	)
+	return err
+}
+
+// Prune deletes sent, dead and dropped deliveries created before the cutoff.
+func (r *WebhookRepository) Prune(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := r.pool.Exec(ctx,
+		`DELETE FROM webhook_deliveries
+		WHERE status IN ('sent', 'dead', 'dropped') AND created_at < $1`,
+		before,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
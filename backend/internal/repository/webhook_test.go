@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+func TestWebhook_EnqueueClaimAndDeadLetter(t *testing.T) {
+	pool := testPool(t)
+	repo := NewWebhookRepository(pool)
+	ctx := context.Background()
+
+	hook, err := repo.Create(ctx, "https://hooks.example.com/in", "s3cret-s3cret-s3cret", 2)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	inactive, err := repo.Create(ctx, "https://other.example.com/in", "s3cret-s3cret-s3cret", 5)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, err := pool.Exec(ctx, `UPDATE webhooks SET active = FALSE WHERE id = $1`, inactive.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the active webhook gets a delivery, and only once per event.
+	for i, want := range []int{1, 0} {
+		n, err := repo.Enqueue(ctx, "match-1", "match.created", []byte(`{"id":1}`))
+		if err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+		if n != want {
+			t.Fatalf("Enqueue() #%d added %d deliveries, want %d", i+1, n, want)
+		}
+	}
+	var count int
+
+	lease := time.Now().Add(time.Minute)
+	claimed, err := repo.Claim(ctx, 10, lease)
+	if err != nil {
+		t.Fatalf("Claim() error = %v", err)
+	}
+	if len(claimed) != 1 {
+		t.Fatalf("claimed %d, want 1", len(claimed))
+	}
+	d := claimed[0]
+	if d.EventID != "match-1" || d.URL != hook.URL || d.Secret != hook.Secret || d.MaxAttempts != 2 {
+		t.Errorf("claimed %+v, want match-1 for webhook %d", d, hook.ID)
+	}
+	if again, _ := repo.Claim(ctx, 10, lease); len(again) != 0 {
+		t.Errorf("re-claimed %d leased rows, want 0", len(again))
+	}
+
+	if err := repo.MarkFailed(ctx, d.ID, "webhook returned status 503", time.Now().Add(-time.Second), true); err != nil {
+		t.Fatalf("MarkFailed() error = %v", err)
+	}
+	if due, _ := repo.Claim(ctx, 10, lease); len(due) != 0 {
+		t.Errorf("claimed %d dead deliveries, want 0", len(due))
+	}
+
+	dead, err := repo.ListDeliveries(ctx, hook.ID, model.WebhookDeliveryDead, 10)
+	if err != nil {
+		t.Fatalf("ListDeliveries() error = %v", err)
+	}
+	if len(dead) != 1 || dead[0].Attempts != 1 || dead[0].LastError != "webhook returned status 503" {
+		t.Errorf("dead deliveries = %+v", dead)
+	}
+	hooks, err := repo.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(hooks) != 2 || hooks[0].Dead != 1 || hooks[0].Pending != 0 {
+		t.Errorf("webhooks = %+v, want the first with 1 dead delivery", hooks)
+	}
+
+	if _, err := repo.ListDeliveries(ctx, 999, "", 10); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ListDeliveries(missing) error = %v, want ErrNotFound", err)
+	}
+	if err := repo.Delete(ctx, hook.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	pool.QueryRow(ctx, `SELECT COUNT(*) FROM webhook_deliveries`).Scan(&count)
+	if count != 0 {
+		t.Errorf("%d deliveries left after deleting the webhook, want 0", count)
+	}
+}
@@ -0,0 +1,152 @@
+// Package analyze runs a dry-run match of the newest CT log entries
+// against a keyword set, without storing anything, to help tune keywords.
+package analyze
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/ctlog"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/matcher"
+)
+
+// SampleSize is the number of matched domains reported per keyword.
+const SampleSize = 5
+
+type Client interface {
+	GetSTH(ctx context.Context) (*ctlog.STH, error)
+	GetEntries(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error)
+}
+
+// KeywordResult is the outcome for one keyword; keywords without hits are
+// reported with zero matches.
+type KeywordResult struct {
+	Keyword       string   `json:"keyword"`
+	Matches       int      `json:"matches"`
+	SampleDomains []string `json:"sample_domains"`
+}
+
+type Result struct {
+	TreeSize    int64           `json:"tree_size"`
+	Start       int64           `json:"start"`
+	End         int64           `json:"end"`
+	Entries     int             `json:"entries"`
+	ParseErrors int             `json:"parse_errors"`
+	Keywords    []KeywordResult `json:"keywords"`
+}
+
+// Option configures Run.
+type Option func(*options)
+
+type options struct {
+	start       int64 // -1: the newest count entries
+	concurrency int
+}
+
+// WithStart analyzes count entries from index start instead of the newest
+// count; the range is cut at the tree head.
+func WithStart(start int64) Option {
+	return func(o *options) { o.start = start }
+}
+
+// WithConcurrency parses each fetched page with up to n goroutines
+// (default 1). Matching stays sequential, so results do not depend on n.
+func WithConcurrency(n int) Option {
+	return func(o *options) { o.concurrency = max(1, n) }
+}
+
+// Run fetches the newest count entries from client and matches them
+// against keywords. Logs cap how many entries one request returns, so the
+// range is fetched in as many requests as the log needs.
+func Run(ctx context.Context, client Client, keywords []model.Keyword, count int, opts ...Option) (*Result, error) {
+	o := options{start: -1, concurrency: 1}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	sth, err := client.GetSTH(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get STH: %w", err)
+	}
+
+	res := &Result{
+		TreeSize: sth.TreeSize,
+		Start:    max(0, sth.TreeSize-int64(count)),
+		End:      sth.TreeSize - 1,
+		Keywords: make([]KeywordResult, len(keywords)),
+	}
+	if o.start >= 0 {
+		if o.start >= sth.TreeSize {
+			return nil, fmt.Errorf("start %d is beyond the tree size %d", o.start, sth.TreeSize)
+		}
+		res.Start = o.start
+		res.End = min(o.start+int64(count)-1, sth.TreeSize-1)
+	}
+	byID := make(map[int]*KeywordResult, len(keywords))
+	for i, kw := range keywords {
+		res.Keywords[i] = KeywordResult{Keyword: kw.Value, SampleDomains: []string{}}
+		byID[kw.ID] = &res.Keywords[i]
+	}
+
+	for next := res.Start; next <= res.End; {
+		entries, err := client.GetEntries(ctx, next, res.End)
+		if err != nil {
+			return nil, fmt.Errorf("get entries %d-%d: %w", next, res.End, err)
+		}
+		if len(entries) == 0 {
+			break
+		}
+		next += int64(len(entries))
+		res.Entries += len(entries)
+
+		for _, cert := range parseAll(entries, o.concurrency) {
+			if cert == nil {
+				res.ParseErrors++
+				continue
+			}
+			for _, m := range matcher.Match(cert, keywords) {
+				kr := byID[m.KeywordID]
+				kr.Matches++
+				if len(kr.SampleDomains) < SampleSize {
+					kr.SampleDomains = append(kr.SampleDomains, m.MatchedDomain)
+				}
+			}
+		}
+	}
+	return res, nil
+}
+
+// parseAll parses entries with up to n goroutines. The result is in entry
+// order, with nil for entries that fail to parse.
+func parseAll(entries []ctlog.RawEntry, n int) []*ctlog.ParsedCertificate {
+	certs := make([]*ctlog.ParsedCertificate, len(entries))
+	parse := func(i int) {
+		if cert, err := ctlog.ParseLeafInput(entries[i].LeafInput, entries[i].ExtraData); err == nil {
+			certs[i] = cert
+		}
+	}
+	if n <= 1 {
+		for i := range entries {
+			parse(i)
+		}
+		return certs
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(n, len(entries)) {
+		wg.Go(func() {
+			for i := range next {
+				parse(i)
+			}
+		})
+	}
+	for i := range entries {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return certs
+}
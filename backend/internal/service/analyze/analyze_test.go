@@ -0,0 +1,134 @@
+package analyze
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/ctlog"
+)
+
+type fakeLog struct {
+	entries []ctlog.RawEntry
+	// pageSize caps entries per GetEntries call, like a real log.
+	pageSize int
+	calls    int
+}
+
+func (f *fakeLog) GetSTH(ctx context.Context) (*ctlog.STH, error) {
+	return &ctlog.STH{TreeSize: int64(len(f.entries))}, nil
+}
+
+func (f *fakeLog) GetEntries(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
+	f.calls++
+	end = min(end, start+int64(f.pageSize)-1)
+	return f.entries[start : end+1], nil
+}
+
+func leaf(t *testing.T, cn string) ctlog.RawEntry {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	buf := make([]byte, 12, 15+len(der))
+	binary.BigEndian.PutUint64(buf[2:10], uint64(time.Now().UnixMilli()))
+	buf = append(buf, byte(len(der)>>16), byte(len(der)>>8), byte(len(der)))
+	return ctlog.RawEntry{LeafInput: append(buf, der...)}
+}
+
+func TestRun(t *testing.T) {
+	log := &fakeLog{pageSize: 2, entries: []ctlog.RawEntry{
+		leaf(t, "old.paypal.com"), // outside the requested window
+		leaf(t, "login.paypal.com"),
+		{LeafInput: []byte("garbage")},
+		leaf(t, "shop.example.com"),
+		leaf(t, "paypal-secure.net"),
+	}}
+	keywords := []model.Keyword{{ID: 1, Value: "paypal"}, {ID: 2, Value: "amazon"}}
+
+	res, err := Run(context.Background(), log, keywords, 4)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if res.Start != 1 || res.End != 4 || res.Entries != 4 || res.ParseErrors != 1 {
+		t.Errorf("start/end/entries/parse_errors = %d/%d/%d/%d, want 1/4/4/1",
+			res.Start, res.End, res.Entries, res.ParseErrors)
+	}
+	if log.calls != 2 {
+		t.Errorf("GetEntries called %d times, want 2 pages", log.calls)
+	}
+	if len(res.Keywords) != 2 {
+		t.Fatalf("got %d keyword results, want 2", len(res.Keywords))
+	}
+	if got := res.Keywords[0]; got.Matches != 2 || len(got.SampleDomains) != 2 || got.SampleDomains[0] != "login.paypal.com" {
+		t.Errorf("paypal = %+v, want 2 matches starting with login.paypal.com", got)
+	}
+	if got := res.Keywords[1]; got.Matches != 0 || got.SampleDomains == nil {
+		t.Errorf("amazon = %+v, want 0 matches and an empty sample list", got)
+	}
+}
+
+type failingLog struct{ fakeLog }
+
+func (f *failingLog) GetEntries(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
+	return nil, errors.New("log unavailable")
+}
+
+func TestRun_FetchError(t *testing.T) {
+	log := &failingLog{fakeLog{entries: make([]ctlog.RawEntry, 10)}}
+
+	if _, err := Run(context.Background(), log, nil, 5); err == nil {
+		t.Error("Run() error = nil, want the fetch error")
+	}
+}
+
+func TestRun_StartAndConcurrency(t *testing.T) {
+	log := &fakeLog{pageSize: 3, entries: []ctlog.RawEntry{
+		leaf(t, "a.paypal.com"),
+		leaf(t, "b.paypal.com"),
+		{LeafInput: []byte("garbage")},
+		leaf(t, "c.paypal.com"),
+		leaf(t, "d.paypal.com"),
+		leaf(t, "e.paypal.com"),
+	}}
+	keywords := []model.Keyword{{ID: 1, Value: "paypal"}}
+
+	res, err := Run(context.Background(), log, keywords, 10, WithStart(1), WithConcurrency(4))
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if res.Start != 1 || res.End != 5 || res.Entries != 5 || res.ParseErrors != 1 {
+		t.Errorf("start/end/entries/parse_errors = %d/%d/%d/%d, want 1/5/5/1 (cut at the tree head)",
+			res.Start, res.End, res.Entries, res.ParseErrors)
+	}
+	want := []string{"b.paypal.com", "c.paypal.com", "d.paypal.com", "e.paypal.com"}
+	if got := res.Keywords[0].SampleDomains; !slices.Equal(got, want) {
+		t.Errorf("samples = %q, want %q in log order", got, want)
+	}
+
+	if _, err := Run(context.Background(), log, keywords, 10, WithStart(6)); err == nil {
+		t.Error("Run() starting past the tree succeeded, want an error")
+	}
+}
@@ -0,0 +1,84 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/logging"
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+// Anonymous is the actor recorded when a request carries no identity.
+const Anonymous = "anonymous"
+
+// System is the actor for actions the server takes on its own, such as
+// stopping the monitor at shutdown.
+const System = "system"
+
+type entryStore interface {
+	Create(ctx context.Context, entry *model.AuditEntry) error
+}
+
+type actorKey struct{}
+
+// WithActor returns a context carrying the name of the caller (e.g. the
+// API key name) for attribution in audit entries.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the caller stored by WithActor, or Anonymous.
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return Anonymous
+}
+
+// Recorder writes audit entries on a best-effort basis: a failure to audit
+// is logged but never surfaced to the caller, so it can't fail the request
+// that triggered it.
+type Recorder struct {
+	store entryStore
+}
+
+func NewRecorder(store entryStore) *Recorder {
+	return &Recorder{store: store}
+}
+
+// Record persists an audit entry for an action on an entity, with the
+// request ID and client IP the request middleware stored in ctx. The write
+// outlives the request context so a client disconnect doesn't drop it.
+func (r *Recorder) Record(ctx context.Context, action, entityType, entityID string, changes map[string]model.AuditChange) {
+	entry := &model.AuditEntry{
+		Actor:      ActorFromContext(ctx),
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		RequestID:  logging.RequestID(ctx),
+		ClientIP:   logging.ClientIP(ctx),
+	}
+
+	if len(changes) > 0 {
+		diff, err := json.Marshal(changes)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to encode audit changes", "error", err, "action", action, "entity_type", entityType)
+		} else {
+			entry.Changes = diff
+		}
+	}
+
+	writeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := r.store.Create(writeCtx, entry); err != nil {
+		slog.ErrorContext(ctx, "failed to write audit entry",
+			"error", err,
+			"actor", entry.Actor,
+			"action", action,
+			"entity_type", entityType,
+			"entity_id", entityID,
+		)
+	}
+}
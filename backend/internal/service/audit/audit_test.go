@@ -0,0 +1,108 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/logging"
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+type mockEntryStore struct {
+	createFn func(ctx context.Context, entry *model.AuditEntry) error
+}
+
+func (m *mockEntryStore) Create(ctx context.Context, entry *model.AuditEntry) error {
+	return m.createFn(ctx, entry)
+}
+
+func TestRecord_DefaultsToAnonymous(t *testing.T) {
+	var got *model.AuditEntry
+	r := NewRecorder(&mockEntryStore{
+		createFn: func(ctx context.Context, entry *model.AuditEntry) error {
+			got = entry
+			return nil
+		},
+	})
+
+	r.Record(context.Background(), model.AuditActionCreate, model.AuditEntityKeyword, "1",
+		map[string]model.AuditChange{"value": {New: "paypal"}})
+
+	if got == nil {
+		t.Fatal("expected entry to be stored")
+	}
+	if got.Actor != Anonymous {
+		t.Errorf("Actor = %q, want %q", got.Actor, Anonymous)
+	}
+	var changes map[string]model.AuditChange
+	if err := json.Unmarshal(got.Changes, &changes); err != nil {
+		t.Fatalf("decode changes: %v", err)
+	}
+	if changes["value"].New != "paypal" {
+		t.Errorf("changes = %v, want value.new = paypal", changes)
+	}
+}
+
+func TestRecord_UsesContextActor(t *testing.T) {
+	var actor string
+	r := NewRecorder(&mockEntryStore{
+		createFn: func(ctx context.Context, entry *model.AuditEntry) error {
+			actor = entry.Actor
+			return nil
+		},
+	})
+
+	r.Record(WithActor(context.Background(), "ci-key"), model.AuditActionStop, model.AuditEntityMonitor, "", nil)
+
+	if actor != "ci-key" {
+		t.Errorf("Actor = %q, want %q", actor, "ci-key")
+	}
+}
+
+func TestRecord_RequestIDAndClientIP(t *testing.T) {
+	var got *model.AuditEntry
+	r := NewRecorder(&mockEntryStore{
+		createFn: func(ctx context.Context, entry *model.AuditEntry) error {
+			got = entry
+			return nil
+		},
+	})
+
+	ctx := logging.WithClientIP(logging.WithRequestID(context.Background(), "req-1"), "203.0.113.7")
+	r.Record(ctx, model.AuditActionDelete, model.AuditEntityKeyword, "3", nil)
+
+	if got.RequestID != "req-1" || got.ClientIP != "203.0.113.7" {
+		t.Errorf("request_id/client_ip = %q/%q, want req-1/203.0.113.7", got.RequestID, got.ClientIP)
+	}
+}
+
+func TestRecord_StoreErrorIsSwallowed(t *testing.T) {
+	r := NewRecorder(&mockEntryStore{
+		createFn: func(ctx context.Context, entry *model.AuditEntry) error {
+			return errors.New("db down")
+		},
+	})
+
+	// Must not panic or block; the failure is only logged.
+	r.Record(context.Background(), model.AuditActionDelete, model.AuditEntityKeyword, "1", nil)
+}
+
+func TestRecord_OutlivesCanceledRequest(t *testing.T) {
+	var ctxErr error
+	r := NewRecorder(&mockEntryStore{
+		createFn: func(ctx context.Context, entry *model.AuditEntry) error {
+			ctxErr = ctx.Err()
+			return nil
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r.Record(ctx, model.AuditActionDelete, model.AuditEntityKeyword, "1", nil)
+
+	if ctxErr != nil {
+		t.Errorf("store saw ctx error %v, want live context", ctxErr)
+	}
+}
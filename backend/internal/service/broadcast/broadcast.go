@@ -0,0 +1,123 @@
+// Package broadcast fans new matches out to live subscribers (the SSE
+// stream). Publishing never blocks: a subscriber that cannot keep up is
+// dropped so one slow client cannot stall delivery to the others.
+package broadcast
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+// Subscription receives published matches on C until it is unsubscribed or
+// dropped, at which point C is closed.
+type Subscription struct {
+	C <-chan model.MatchedCertificate
+
+	ch      chan model.MatchedCertificate
+	dropped bool
+}
+
+// Dropped reports whether the subscription was evicted for falling behind.
+// It is only meaningful after C has been closed.
+func (s *Subscription) Dropped() bool {
+	return s.dropped
+}
+
+type Broadcaster struct {
+	buffer int
+
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+// NewBroadcaster returns a Broadcaster whose subscribers each buffer up to
+// buffer matches before they are dropped.
+func NewBroadcaster(buffer int) *Broadcaster {
+	if buffer < 1 {
+		buffer = 1
+	}
+	return &Broadcaster{buffer: buffer, subs: make(map[*Subscription]struct{})}
+}
+
+func (b *Broadcaster) Subscribe() *Subscription {
+	ch := make(chan model.MatchedCertificate, b.buffer)
+	s := &Subscription{C: ch, ch: ch}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return s
+	}
+	b.subs[s] = struct{}{}
+	return s
+}
+
+// Close ends every subscription and makes later ones start closed, so
+// streaming handlers return and let the server shut down.
+func (b *Broadcaster) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for s := range b.subs {
+		b.removeLocked(s)
+	}
+}
+
+// Unsubscribe removes s and closes its channel. It is safe to call more
+// than once and after s was dropped.
+func (b *Broadcaster) Unsubscribe(s *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.removeLocked(s)
+}
+
+// Subscribers returns the number of live subscriptions.
+func (b *Broadcaster) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+// Publish delivers cert to every subscriber without blocking. Subscribers
+// whose buffer is full are dropped.
+func (b *Broadcaster) Publish(cert model.MatchedCertificate) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subs {
+		if !b.deliver(s, cert) {
+			s.dropped = true
+			b.removeLocked(s)
+			slog.Warn("dropped slow stream subscriber", "subscribers", len(b.subs))
+		}
+	}
+}
+
+// deliver attempts a non-blocking send, treating a panic (e.g. a send on a
+// channel closed behind our back) as a failed delivery so it stays
+// contained to that subscriber.
+func (b *Broadcaster) deliver(s *Subscription, cert model.MatchedCertificate) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("stream subscriber delivery panicked", "error", r)
+			ok = false
+		}
+	}()
+	select {
+	case s.ch <- cert:
+		return true
+	default:
+		return false
+	}
+}
+
+func (b *Broadcaster) removeLocked(s *Subscription) {
+	if _, ok := b.subs[s]; !ok {
+		return
+	}
+	delete(b.subs, s)
+	close(s.ch)
+}
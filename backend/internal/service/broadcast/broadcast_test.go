@@ -0,0 +1,114 @@
+package broadcast
+
+import (
+	"testing"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+func TestPublish_DeliversToAllSubscribers(t *testing.T) {
+	b := NewBroadcaster(4)
+	a, c := b.Subscribe(), b.Subscribe()
+
+	b.Publish(model.MatchedCertificate{ID: 1})
+
+	for _, s := range []*Subscription{a, c} {
+		select {
+		case got := <-s.C:
+			if got.ID != 1 {
+				t.Errorf("got ID %d, want 1", got.ID)
+			}
+		default:
+			t.Error("subscriber did not receive the match")
+		}
+	}
+}
+
+func TestPublish_SlowSubscriberDroppedWithoutBlockingOthers(t *testing.T) {
+	b := NewBroadcaster(2)
+	slow := b.Subscribe() // never reads
+	fast := b.Subscribe()
+
+	received := make(chan int, 10)
+	go func() {
+		for cert := range fast.C {
+			received <- cert.ID
+		}
+		close(received)
+	}()
+
+	published := make(chan struct{})
+	go func() {
+		for i := 1; i <= 5; i++ {
+			b.Publish(model.MatchedCertificate{ID: i})
+			// Give the fast consumer a chance to drain so only the slow
+			// one overflows.
+			time.Sleep(5 * time.Millisecond)
+		}
+		close(published)
+	}()
+
+	select {
+	case <-published:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Publish blocked on a slow subscriber")
+	}
+
+	// The slow subscriber got its buffer's worth and was then closed.
+	var got []int
+	for cert := range slow.C {
+		got = append(got, cert.ID)
+	}
+	if len(got) != 2 || !slow.Dropped() {
+		t.Errorf("slow subscriber got %v dropped=%v, want 2 matches and dropped", got, slow.Dropped())
+	}
+
+	b.Unsubscribe(fast)
+	var fastGot []int
+	for id := range received {
+		fastGot = append(fastGot, id)
+	}
+	if len(fastGot) != 5 {
+		t.Errorf("fast subscriber got %v, want all 5 matches", fastGot)
+	}
+	if fast.Dropped() {
+		t.Error("fast subscriber marked dropped")
+	}
+	if n := b.Subscribers(); n != 0 {
+		t.Errorf("Subscribers() = %d, want 0", n)
+	}
+}
+
+func TestUnsubscribe_Idempotent(t *testing.T) {
+	b := NewBroadcaster(1)
+	s := b.Subscribe()
+
+	b.Unsubscribe(s)
+	b.Unsubscribe(s)
+	b.Publish(model.MatchedCertificate{ID: 1})
+
+	if _, ok := <-s.C; ok {
+		t.Error("channel still open after Unsubscribe")
+	}
+}
+
+func TestClose_EndsSubscriptions(t *testing.T) {
+	b := NewBroadcaster(1)
+	before := b.Subscribe()
+
+	b.Close()
+	after := b.Subscribe()
+
+	for _, s := range []*Subscription{before, after} {
+		if _, ok := <-s.C; ok {
+			t.Error("subscription still open after Close")
+		}
+		if s.Dropped() {
+			t.Error("closed subscription reported as dropped")
+		}
+	}
+	if n := b.Subscribers(); n != 0 {
+		t.Errorf("Subscribers() = %d, want 0", n)
+	}
+}
@@ -0,0 +1,158 @@
+package ctlog
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+// oidPrecertPoison marks a precertificate (RFC 6962 §3.1). It is critical,
+// so x509 verification rejects any certificate carrying it.
+var oidPrecertPoison = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 3}
+
+// GetRoots retrieves the root certificates the log accepts (RFC 6962
+// §4.7). Roots that fail to parse are skipped.
+func (c *Client) GetRoots(ctx context.Context) ([]*x509.Certificate, error) {
+	url := fmt.Sprintf("%s/ct/v1/get-roots", c.baseURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create roots request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", c.userAgent)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch roots: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get-roots returned status %d", resp.StatusCode)
+	}
+
+	result, err := decode[getRootsResponse](resp.Body, c.maxResponseBytes)
+	if err != nil {
+		return nil, fmt.Errorf("decode roots: %w", err)
+	}
+	roots := make([]*x509.Certificate, 0, len(result.Certificates))
+	for _, der := range result.Certificates {
+		if cert, err := x509.ParseCertificate(der); err == nil {
+			roots = append(roots, cert)
+		}
+	}
+	return roots, nil
+}
+
+// RootPool returns the system trust store extended with extra (e.g. the
+// log's roots from GetRoots). Without a system store it holds only extra.
+func RootPool(extra []*x509.Certificate) *x509.CertPool {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	for _, c := range extra {
+		pool.AddCert(c)
+	}
+	return pool
+}
+
+// Verifier checks whether a logged certificate chains to a trusted root,
+// using the chain the log stored with the entry as intermediates.
+type Verifier struct {
+	roots *x509.CertPool
+	now   func() time.Time
+}
+
+// NewVerifier returns a Verifier trusting roots (see RootPool).
+func NewVerifier(roots *x509.CertPool) *Verifier {
+	return &Verifier{roots: roots, now: time.Now}
+}
+
+// Verify returns the model.Chain* status of cert. It never fails: a
+// certificate not decoded by ParseLeafInput is model.ChainNotChecked, and
+// a malformed or untrusted chain is model.ChainUnknownIssuer. A chain that
+// was valid when the certificate was issued but is not now (an expired
+// leaf or intermediate) is model.ChainExpired.
+func (v *Verifier) Verify(cert *ParsedCertificate) (status string) {
+	if cert == nil || cert.leaf == nil {
+		return model.ChainNotChecked
+	}
+	// Logs accept whatever chains submitters send; a pathological one must
+	// cost a status, not the monitor.
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Warn("certificate chain verification panicked", "serial", cert.Serial, "panic", r)
+			status = model.ChainUnknownIssuer
+		}
+	}()
+
+	intermediates, err := parseChain(cert.chain)
+	if err != nil {
+		return model.ChainUnknownIssuer
+	}
+	leaf := withoutPoison(cert.leaf)
+	opts := x509.VerifyOptions{
+		Roots:         v.roots,
+		Intermediates: intermediates,
+		CurrentTime:   v.now(),
+		// Precertificates are issued by precertificate signing certificates
+		// whose only EKU is CT's own; the chain matters here, not usages.
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	if _, err := leaf.Verify(opts); err == nil {
+		return model.ChainValid
+	}
+	opts.CurrentTime = leaf.NotBefore
+	if _, err := leaf.Verify(opts); err == nil {
+		return model.ChainExpired
+	}
+	return model.ChainUnknownIssuer
+}
+
+// withoutPoison returns cert, or for a precertificate a copy with the
+// poison extension no longer listed as unhandled.
+func withoutPoison(cert *x509.Certificate) *x509.Certificate {
+	i := slices.IndexFunc(cert.UnhandledCriticalExtensions, func(oid asn1.ObjectIdentifier) bool {
+		return oid.Equal(oidPrecertPoison)
+	})
+	if i < 0 {
+		return cert
+	}
+	stripped := *cert
+	stripped.UnhandledCriticalExtensions = slices.Delete(slices.Clone(cert.UnhandledCriticalExtensions), i, i+1)
+	return &stripped
+}
+
+// parseChain decodes a TLS-encoded ASN.1Cert list (a 3-byte length, then
+// certificates each prefixed with a 3-byte length) into a pool. An empty
+// input is an empty chain.
+func parseChain(b []byte) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	if len(b) == 0 {
+		return pool, nil
+	}
+	if len(b) < 3 || len(b) != 3+readUint24(b) {
+		return nil, errors.New("malformed certificate chain")
+	}
+	for b = b[3:]; len(b) > 0; {
+		if len(b) < 3 || len(b) < 3+readUint24(b) {
+			return nil, errors.New("truncated certificate in chain")
+		}
+		n := readUint24(b)
+		cert, err := x509.ParseCertificate(b[3 : 3+n])
+		if err != nil {
+			return nil, fmt.Errorf("chain certificate: %w", err)
+		}
+		pool.AddCert(cert)
+		b = b[3+n:]
+	}
+	return pool, nil
+}
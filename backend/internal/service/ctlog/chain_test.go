@@ -0,0 +1,196 @@
+package ctlog
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// issue signs tmpl with parent (self-signed when parent is nil).
+func issue(t *testing.T, tmpl *x509.Certificate, parent *testCA) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	signer, signerKey := tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("create certificate %q: %v", tmpl.Subject.CommonName, err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+	return &testCA{cert: cert, key: key}
+}
+
+func caTemplate(cn string, notAfter time.Time) *x509.Certificate {
+	return &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-48 * time.Hour),
+		NotAfter:              notAfter,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+}
+
+func leafTemplate(precert bool) *x509.Certificate {
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "login.example.com"},
+		DNSNames:     []string{"login.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if precert {
+		tmpl.ExtraExtensions = append(tmpl.ExtraExtensions, pkix.Extension{
+			Id: oidPrecertPoison, Critical: true, Value: []byte{0x05, 0x00},
+		})
+	}
+	return tmpl
+}
+
+// encodeChain builds a TLS ASN.1Cert list: 3-byte total length, then each
+// certificate with its own 3-byte length.
+func encodeChain(certs ...*x509.Certificate) []byte {
+	var list []byte
+	for _, c := range certs {
+		list = append(list, byte(len(c.Raw)>>16), byte(len(c.Raw)>>8), byte(len(c.Raw)))
+		list = append(list, c.Raw...)
+	}
+	return append([]byte{byte(len(list) >> 16), byte(len(list) >> 8), byte(len(list))}, list...)
+}
+
+func parseEntry(t *testing.T, leaf *x509.Certificate, precert bool, chain []byte) *ParsedCertificate {
+	t.Helper()
+	var p *ParsedCertificate
+	var err error
+	if precert {
+		p, err = ParseLeafInput(buildLeaf(t, 1, nil, 0), append(buildExtraData(t, leaf.Raw), chain...))
+	} else {
+		p, err = ParseLeafInput(buildLeaf(t, 0, leaf.Raw, 0), chain)
+	}
+	if err != nil {
+		t.Fatalf("ParseLeafInput: %v", err)
+	}
+	return p
+}
+
+func rootsOf(cas ...*testCA) *x509.CertPool {
+	pool := x509.NewCertPool()
+	for _, ca := range cas {
+		pool.AddCert(ca.cert)
+	}
+	return pool
+}
+
+func TestVerify(t *testing.T) {
+	root := issue(t, caTemplate("Test Root", time.Now().Add(24*365*time.Hour)), nil)
+	inter := issue(t, caTemplate("Test Intermediate", time.Now().Add(24*365*time.Hour)), root)
+	cert := issue(t, leafTemplate(false), inter).cert
+	precert := issue(t, leafTemplate(true), inter).cert
+
+	tests := []struct {
+		name  string
+		entry *ParsedCertificate
+		roots *x509.CertPool
+		at    time.Time
+		want  string
+	}{
+		{name: "valid", entry: parseEntry(t, cert, false, encodeChain(inter.cert, root.cert)),
+			roots: rootsOf(root), want: model.ChainValid},
+		{name: "precert poison stripped", entry: parseEntry(t, precert, true, encodeChain(inter.cert, root.cert)),
+			roots: rootsOf(root), want: model.ChainValid},
+		{name: "untrusted root", entry: parseEntry(t, cert, false, encodeChain(inter.cert, root.cert)),
+			roots: x509.NewCertPool(), want: model.ChainUnknownIssuer},
+		{name: "missing intermediate", entry: parseEntry(t, cert, false, encodeChain()),
+			roots: rootsOf(root), want: model.ChainUnknownIssuer},
+		{name: "expired since issuance", entry: parseEntry(t, cert, false, encodeChain(inter.cert, root.cert)),
+			roots: rootsOf(root), at: time.Now().Add(48 * time.Hour), want: model.ChainExpired},
+		{name: "malformed chain", entry: parseEntry(t, cert, false, []byte{0, 0, 9, 1, 2}),
+			roots: rootsOf(root), want: model.ChainUnknownIssuer},
+		{name: "garbage certificate in chain", entry: parseEntry(t, cert, false, []byte{0, 0, 5, 0, 0, 2, 0x30, 0x00}),
+			roots: rootsOf(root), want: model.ChainUnknownIssuer},
+		{name: "not parsed from a log entry", entry: &ParsedCertificate{Serial: "01"},
+			roots: rootsOf(root), want: model.ChainNotChecked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewVerifier(tt.roots)
+			if !tt.at.IsZero() {
+				v.now = func() time.Time { return tt.at }
+			}
+			if got := v.Verify(tt.entry); got != tt.want {
+				t.Errorf("Verify() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerify_PrecertLeafUnchanged(t *testing.T) {
+	root := issue(t, caTemplate("Test Root", time.Now().Add(time.Hour)), nil)
+	precert := issue(t, leafTemplate(true), root).cert
+	p := parseEntry(t, precert, true, encodeChain(root.cert))
+
+	NewVerifier(rootsOf(root)).Verify(p)
+
+	if len(p.leaf.UnhandledCriticalExtensions) != 1 {
+		t.Errorf("UnhandledCriticalExtensions = %v, want the poison left on the parsed certificate", p.leaf.UnhandledCriticalExtensions)
+	}
+}
+
+func TestGetRoots(t *testing.T) {
+	root := issue(t, caTemplate("Log Root", time.Now().Add(time.Hour)), nil)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ct/v1/get-roots" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+		fmt.Fprintf(w, `{"certificates":[%q,%q]}`,
+			base64.StdEncoding.EncodeToString(root.cert.Raw),
+			base64.StdEncoding.EncodeToString([]byte("not a certificate")))
+	}))
+	defer srv.Close()
+
+	roots, err := NewClient(srv.URL).GetRoots(context.Background())
+	if err != nil {
+		t.Fatalf("GetRoots: %v", err)
+	}
+	if len(roots) != 1 || !roots[0].Equal(root.cert) {
+		t.Errorf("roots = %d certificates, want the one that parses", len(roots))
+	}
+}
+
+func TestGetRoots_ServerError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	if _, err := NewClient(srv.URL).GetRoots(context.Background()); err == nil {
+		t.Error("GetRoots succeeded, want an error")
+	}
+}
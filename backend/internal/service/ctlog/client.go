@@ -3,9 +3,15 @@ package ctlog
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/version"
 )
 
 // STH represents a Signed Tree Head response (RFC 6962 §4.3).
@@ -21,29 +27,147 @@ type RawEntry struct {
 	ExtraData []byte `json:"extra_data"`
 }
 
+// DefaultMaxResponseBytes bounds a get-sth or get-entries body. Real logs
+// serve a few MB per get-entries batch; the limit only stops a broken or
+// hostile log from exhausting memory.
+const DefaultMaxResponseBytes = 64 << 20
+
+// ErrResponseTooLarge is returned when a log response exceeds the client's
+// maximum response size.
+var ErrResponseTooLarge = errors.New("CT log response too large")
+
+// ErrRedirectNotAllowed is returned when a log redirects to a host the
+// client was not allowed to follow (WithAllowedRedirects).
+var ErrRedirectNotAllowed = errors.New("CT log redirect not allowed")
+
 // Client talks to a Certificate Transparency log over HTTP.
 type Client struct {
-	baseURL    string
-	httpClient *http.Client
+	baseURL          string
+	userAgent        string
+	httpClient       *http.Client
+	maxResponseBytes int64
+
+	// sthTTL > 0 enables the STH cache. sthLock (a one-slot semaphore, so
+	// waiting respects the context) serializes fetches so callers arriving
+	// during one share its result; sth and sthAt are guarded by sthMu.
+	sthTTL  time.Duration
+	sthLock chan struct{}
+	sthMu   sync.Mutex
+	sth     STH
+	sthAt   time.Time
+	now     func() time.Time
+}
+
+// ClientOption configures optional Client behavior.
+type ClientOption func(*Client)
+
+// WithMaxResponseBytes sets the largest response body the client decodes;
+// n <= 0 keeps DefaultMaxResponseBytes.
+func WithMaxResponseBytes(n int64) ClientOption {
+	return func(c *Client) {
+		if n > 0 {
+			c.maxResponseBytes = n
+		}
+	}
+}
+
+// WithSTHCacheTTL makes GetSTH reuse a fetched tree head for ttl, so code
+// paths asking for it close together cost the log one request. Zero (the
+// default) fetches every time.
+func WithSTHCacheTTL(ttl time.Duration) ClientOption {
+	return func(c *Client) {
+		c.sthTTL = ttl
+	}
+}
+
+// WithAllowedRedirects follows a redirect only to a host (host[:port],
+// lower-cased, as Host returns it) for which allowed reports true, so a
+// log on an allowlisted host cannot bounce the client to an internal
+// service. By default redirects are followed wherever they lead.
+func WithAllowedRedirects(allowed func(host string) bool) ClientOption {
+	return func(c *Client) {
+		c.httpClient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			if host := strings.ToLower(req.URL.Host); !allowed(host) {
+				return fmt.Errorf("%w: %s", ErrRedirectNotAllowed, host)
+			}
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return nil
+		}
+	}
 }
 
-func NewClient(baseURL string) *Client {
-	return &Client{
-		baseURL: baseURL,
+func NewClient(baseURL string, opts ...ClientOption) *Client {
+	c := &Client{
+		baseURL:   baseURL,
+		userAgent: version.UserAgent(),
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		maxResponseBytes: DefaultMaxResponseBytes,
+		sthLock:          make(chan struct{}, 1),
+		now:              time.Now,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
-// GetSTH retrieves the latest Signed Tree Head.
+// GetSTH retrieves the latest Signed Tree Head. With WithSTHCacheTTL a
+// head fetched less than the TTL ago is returned instead, and concurrent
+// callers wait for (or give up on, when ctx ends) a fetch in progress
+// rather than starting their own. Failures are not cached.
 func (c *Client) GetSTH(ctx context.Context) (*STH, error) {
+	if c.sthTTL <= 0 {
+		return c.fetchSTH(ctx)
+	}
+	if sth, ok := c.cachedSTH(); ok {
+		return sth, nil
+	}
+
+	select {
+	case c.sthLock <- struct{}{}:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("fetch STH: %w", ctx.Err())
+	}
+	defer func() { <-c.sthLock }()
+
+	// A fetch that finished while this call waited is fresh enough.
+	if sth, ok := c.cachedSTH(); ok {
+		return sth, nil
+	}
+	sth, err := c.fetchSTH(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.sthMu.Lock()
+	c.sth, c.sthAt = *sth, c.now()
+	c.sthMu.Unlock()
+	return sth, nil
+}
+
+// cachedSTH returns a copy of the cached tree head if it is younger than
+// the TTL.
+func (c *Client) cachedSTH() (*STH, bool) {
+	c.sthMu.Lock()
+	defer c.sthMu.Unlock()
+	if c.sthAt.IsZero() || c.now().Sub(c.sthAt) >= c.sthTTL {
+		return nil, false
+	}
+	sth := c.sth
+	return &sth, true
+}
+
+func (c *Client) fetchSTH(ctx context.Context) (*STH, error) {
 	url := fmt.Sprintf("%s/ct/v1/get-sth", c.baseURL)
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("create STH request: %w", err)
 	}
 
+	req.Header.Set("User-Agent", c.userAgent)
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("fetch STH: %w", err)
@@ -54,8 +178,8 @@ func (c *Client) GetSTH(ctx context.Context) (*STH, error) {
 		return nil, fmt.Errorf("STH returned status %d", resp.StatusCode)
 	}
 
-	var sth STH
-	if err := json.NewDecoder(resp.Body).Decode(&sth); err != nil {
+	sth, err := decode[STH](resp.Body, c.maxResponseBytes)
+	if err != nil {
 		return nil, fmt.Errorf("decode STH: %w", err)
 	}
 	return &sth, nil
@@ -69,6 +193,7 @@ func (c *Client) GetEntries(ctx context.Context, start, end int64) ([]RawEntry,
 		return nil, fmt.Errorf("create entries request: %w", err)
 	}
 
+	req.Header.Set("User-Agent", c.userAgent)
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("fetch entries: %w", err)
@@ -79,11 +204,31 @@ func (c *Client) GetEntries(ctx context.Context, start, end int64) ([]RawEntry,
 		return nil, fmt.Errorf("get-entries returned status %d", resp.StatusCode)
 	}
 
-	var result struct {
-		Entries []RawEntry `json:"entries"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+	result, err := decode[getEntriesResponse](resp.Body, c.maxResponseBytes)
+	if err != nil {
 		return nil, fmt.Errorf("decode entries: %w", err)
 	}
 	return result.Entries, nil
 }
+
+// getEntriesResponse is the body of get-entries.
+type getEntriesResponse struct {
+	Entries []RawEntry `json:"entries"`
+}
+
+// getRootsResponse is the body of get-roots.
+type getRootsResponse struct {
+	Certificates [][]byte `json:"certificates"`
+}
+
+// decode reads a JSON value from body, giving up with ErrResponseTooLarge
+// once more than limit bytes would have to be read.
+func decode[T any](body io.Reader, limit int64) (T, error) {
+	var v T
+	lr := &io.LimitedReader{R: body, N: limit + 1}
+	err := json.NewDecoder(lr).Decode(&v)
+	if err != nil && lr.N <= 0 {
+		return v, fmt.Errorf("%w: more than %d bytes", ErrResponseTooLarge, limit)
+	}
+	return v, err
+}
@@ -3,10 +3,16 @@ package ctlog
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/version"
 )
 
 func TestGetSTH_Success(t *testing.T) {
@@ -31,6 +37,28 @@ func TestGetSTH_Success(t *testing.T) {
 	}
 }
 
+func TestClient_SendsUserAgent(t *testing.T) {
+	var agents []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agents = append(agents, r.UserAgent())
+		if r.URL.Path == "/ct/v1/get-sth" {
+			json.NewEncoder(w).Encode(STH{TreeSize: 1})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string][]RawEntry{"entries": {}})
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL)
+	client.GetSTH(context.Background())
+	client.GetEntries(context.Background(), 0, 0)
+
+	want := "sisap-ct-monitor/" + version.Version
+	if len(agents) != 2 || agents[0] != want || agents[1] != want {
+		t.Errorf("User-Agents = %q, want %q on both requests", agents, want)
+	}
+}
+
 func TestGetSTH_ServerError(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -150,3 +178,209 @@ func TestGetEntries_ServerError(t *testing.T) {
 		t.Fatal("expected error for 502 response")
 	}
 }
+
+// streamEntries serves a get-entries body of up to total bytes, flushing
+// as it goes, like a log that never stops sending.
+func streamEntries(total int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		entry := `{"leaf_input":"` + strings.Repeat("QUFB", 256) + `","extra_data":""},`
+		written, _ := w.Write([]byte(`{"entries":[`))
+		for written < total {
+			n, err := w.Write([]byte(entry))
+			if err != nil {
+				return // the client hung up
+			}
+			written += n
+			w.(http.Flusher).Flush()
+		}
+		w.Write([]byte(`{"leaf_input":"","extra_data":""}]}`))
+	}
+}
+
+func TestGetEntries_ResponseTooLarge(t *testing.T) {
+	srv := httptest.NewServer(streamEntries(1 << 30))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, WithMaxResponseBytes(64<<10))
+	_, err := client.GetEntries(context.Background(), 0, 10)
+	if !errors.Is(err, ErrResponseTooLarge) {
+		t.Fatalf("error = %v, want ErrResponseTooLarge", err)
+	}
+	if !strings.Contains(err.Error(), "more than 65536 bytes") {
+		t.Errorf("error = %q, want the limit in the message", err)
+	}
+}
+
+func TestGetEntries_UnderLimit(t *testing.T) {
+	srv := httptest.NewServer(streamEntries(32 << 10))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, WithMaxResponseBytes(64<<10))
+	entries, err := client.GetEntries(context.Background(), 0, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) < 32 {
+		t.Errorf("got %d entries, want the whole 32 KiB body", len(entries))
+	}
+}
+
+func TestGetSTH_ResponseTooLarge(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"tree_size":1,"sha256_root_hash":"` + strings.Repeat("A", 4096) + `"}`))
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, WithMaxResponseBytes(1024))
+	if _, err := client.GetSTH(context.Background()); !errors.Is(err, ErrResponseTooLarge) {
+		t.Fatalf("error = %v, want ErrResponseTooLarge", err)
+	}
+}
+
+func TestNewClient_DefaultMaxResponseBytes(t *testing.T) {
+	if c := NewClient("https://ct.example.com", WithMaxResponseBytes(0)); c.maxResponseBytes != DefaultMaxResponseBytes {
+		t.Errorf("maxResponseBytes = %d, want %d", c.maxResponseBytes, DefaultMaxResponseBytes)
+	}
+}
+
+// countingSTHServer serves an STH whose tree size is the number of requests
+// seen so far.
+func countingSTHServer(t *testing.T) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	var hits atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(STH{TreeSize: hits.Add(1)})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+func TestGetSTH_CachedWithinTTL(t *testing.T) {
+	srv, hits := countingSTHServer(t)
+	client := NewClient(srv.URL, WithSTHCacheTTL(time.Minute))
+
+	for range 2 {
+		sth, err := client.GetSTH(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if sth.TreeSize != 1 {
+			t.Errorf("TreeSize = %d, want 1 (the cached head)", sth.TreeSize)
+		}
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("server hit %d times, want 1", n)
+	}
+}
+
+func TestGetSTH_CacheExpires(t *testing.T) {
+	srv, hits := countingSTHServer(t)
+	client := NewClient(srv.URL, WithSTHCacheTTL(time.Second))
+	now := time.Now()
+	client.now = func() time.Time { return now }
+
+	client.GetSTH(context.Background())
+	now = now.Add(time.Second)
+	sth, err := client.GetSTH(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sth.TreeSize != 2 || hits.Load() != 2 {
+		t.Errorf("TreeSize = %d after %d hits, want a fresh fetch", sth.TreeSize, hits.Load())
+	}
+}
+
+func TestGetSTH_CacheReturnsCopies(t *testing.T) {
+	srv, _ := countingSTHServer(t)
+	client := NewClient(srv.URL, WithSTHCacheTTL(time.Minute))
+
+	first, _ := client.GetSTH(context.Background())
+	first.TreeSize = 99
+	second, _ := client.GetSTH(context.Background())
+	if second.TreeSize != 1 {
+		t.Errorf("TreeSize = %d, want 1: callers share the cached value", second.TreeSize)
+	}
+}
+
+func TestGetSTH_NoCacheByDefault(t *testing.T) {
+	srv, hits := countingSTHServer(t)
+	client := NewClient(srv.URL)
+
+	client.GetSTH(context.Background())
+	client.GetSTH(context.Background())
+	if n := hits.Load(); n != 2 {
+		t.Errorf("server hit %d times, want 2", n)
+	}
+}
+
+func TestGetSTH_ErrorsNotCached(t *testing.T) {
+	var hits atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(STH{TreeSize: 5})
+	}))
+	defer srv.Close()
+	client := NewClient(srv.URL, WithSTHCacheTTL(time.Minute))
+
+	if _, err := client.GetSTH(context.Background()); err == nil {
+		t.Fatal("expected error from the first fetch")
+	}
+	sth, err := client.GetSTH(context.Background())
+	if err != nil || sth.TreeSize != 5 {
+		t.Errorf("GetSTH() = %+v, %v; want the retried head", sth, err)
+	}
+}
+
+func TestGetSTH_ConcurrentCallsShareFetch(t *testing.T) {
+	release := make(chan struct{})
+	var hits atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		<-release
+		json.NewEncoder(w).Encode(STH{TreeSize: 7})
+	}))
+	defer srv.Close()
+	client := NewClient(srv.URL, WithSTHCacheTTL(time.Minute))
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Go(func() {
+			if sth, err := client.GetSTH(context.Background()); err != nil || sth.TreeSize != 7 {
+				t.Errorf("GetSTH() = %+v, %v", sth, err)
+			}
+		})
+	}
+	for hits.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	if n := hits.Load(); n != 1 {
+		t.Errorf("server hit %d times, want 1", n)
+	}
+}
+
+func TestGetSTH_WaitRespectsContext(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		json.NewEncoder(w).Encode(STH{TreeSize: 1})
+	}))
+	defer srv.Close()
+	defer close(release)
+	client := NewClient(srv.URL, WithSTHCacheTTL(time.Minute))
+
+	go client.GetSTH(context.Background())
+	for len(client.sthLock) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.GetSTH(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want context.DeadlineExceeded", err)
+	}
+}
@@ -24,6 +24,21 @@ type ParsedCertificate struct {
 	Issuer     string
 	NotBefore  time.Time
 	NotAfter   time.Time
+	// IsPrecert is true for precert_entry leaves, whose final certificate
+	// may never be issued.
+	IsPrecert bool
+	// ExtKeyUsages names the certificate's extended key usages
+	// ("server_auth", "client_auth", ...); unknown ones are "unknown".
+	ExtKeyUsages []string
+	// IsServerAuth reports whether the certificate may be used by a TLS
+	// server: it lists serverAuth or anyExtendedKeyUsage, or has no EKU
+	// extension at all (RFC 5280 leaves it unrestricted then).
+	IsServerAuth bool
+
+	// leaf and chain are kept for Verifier: the decoded certificate and
+	// the entry's certificate_chain / precertificate_chain, still encoded.
+	leaf  *x509.Certificate
+	chain []byte
 }
 
 // ParseLeafInput decodes a MerkleTreeLeaf binary blob into a ParsedCertificate.
@@ -42,7 +57,7 @@ func ParseLeafInput(data []byte, extraData []byte) (*ParsedCertificate, error) {
 	// Bytes 10-11: entry type
 	entryType := binary.BigEndian.Uint16(data[10:12])
 
-	var certDER []byte
+	var certDER, chain []byte
 
 	switch entryType {
 	case 0: // x509_entry
@@ -52,6 +67,7 @@ func ParseLeafInput(data []byte, extraData []byte) (*ParsedCertificate, error) {
 			return nil, ErrTooShort
 		}
 		certDER = data[15:end]
+		chain = extraData
 
 	case 1: // precert_entry — extract certificate from extra_data
 		if len(extraData) < 3 {
@@ -63,6 +79,7 @@ func ParseLeafInput(data []byte, extraData []byte) (*ParsedCertificate, error) {
 			return nil, fmt.Errorf("%w: precert extra_data truncated", ErrTooShort)
 		}
 		certDER = extraData[3:end]
+		chain = extraData[end:]
 
 	default:
 		return nil, fmt.Errorf("%w: %d", ErrUnknownType, entryType)
@@ -86,9 +103,53 @@ func ParseLeafInput(data []byte, extraData []byte) (*ParsedCertificate, error) {
 		Issuer:     issuer,
 		NotBefore:  cert.NotBefore,
 		NotAfter:   cert.NotAfter,
+		IsPrecert:  entryType == 1,
+
+		ExtKeyUsages: extKeyUsageNames(cert),
+		IsServerAuth: isServerAuth(cert),
+
+		leaf:  cert,
+		chain: chain,
 	}, nil
 }
 
+var extKeyUsageNamesByID = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageAny:             "any",
+	x509.ExtKeyUsageServerAuth:      "server_auth",
+	x509.ExtKeyUsageClientAuth:      "client_auth",
+	x509.ExtKeyUsageCodeSigning:     "code_signing",
+	x509.ExtKeyUsageEmailProtection: "email_protection",
+	x509.ExtKeyUsageTimeStamping:    "time_stamping",
+	x509.ExtKeyUsageOCSPSigning:     "ocsp_signing",
+}
+
+func extKeyUsageNames(cert *x509.Certificate) []string {
+	names := make([]string, 0, len(cert.ExtKeyUsage)+len(cert.UnknownExtKeyUsage))
+	for _, u := range cert.ExtKeyUsage {
+		name, ok := extKeyUsageNamesByID[u]
+		if !ok {
+			name = "unknown"
+		}
+		names = append(names, name)
+	}
+	for range cert.UnknownExtKeyUsage {
+		names = append(names, "unknown")
+	}
+	return names
+}
+
+func isServerAuth(cert *x509.Certificate) bool {
+	if len(cert.ExtKeyUsage) == 0 && len(cert.UnknownExtKeyUsage) == 0 {
+		return true
+	}
+	for _, u := range cert.ExtKeyUsage {
+		if u == x509.ExtKeyUsageServerAuth || u == x509.ExtKeyUsageAny {
+			return true
+		}
+	}
+	return false
+}
+
 // readUint24 reads a 3-byte big-endian unsigned integer.
 func readUint24(b []byte) int {
 	return int(b[0])<<16 | int(b[1])<<8 | int(b[2])
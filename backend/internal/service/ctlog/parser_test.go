@@ -11,6 +11,8 @@ import (
 	"math/big"
 	"testing"
 	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/benchcorpus"
 )
 
 // buildLeaf constructs a minimal MerkleTreeLeaf blob for testing.
@@ -145,6 +147,9 @@ func TestParseLeafInput_X509Entry(t *testing.T) {
 	if pc.Timestamp != time.UnixMilli(int64(ts)) {
 		t.Errorf("Timestamp = %v, want %v", pc.Timestamp, time.UnixMilli(int64(ts)))
 	}
+	if pc.IsPrecert {
+		t.Error("IsPrecert = true for x509_entry")
+	}
 }
 
 func TestParseLeafInput_PrecertEntry(t *testing.T) {
@@ -159,6 +164,9 @@ func TestParseLeafInput_PrecertEntry(t *testing.T) {
 	if pc.CommonName != "precert.example.com" {
 		t.Errorf("CommonName = %q, want %q", pc.CommonName, "precert.example.com")
 	}
+	if !pc.IsPrecert {
+		t.Error("IsPrecert = false for precert_entry")
+	}
 }
 
 func TestParseLeafInput_TooShort(t *testing.T) {
@@ -222,3 +230,77 @@ func TestParseLeafInput_IssuerOrgFallback(t *testing.T) {
 		t.Errorf("Issuer = %q, want %q", pc.Issuer, "My Org")
 	}
 }
+
+// ekuCert returns a self-signed certificate with the given extended key
+// usages.
+func ekuCert(t *testing.T, usages []x509.ExtKeyUsage) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "client.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  usages,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	return der
+}
+
+func TestParseLeafInput_ExtKeyUsage(t *testing.T) {
+	tests := []struct {
+		name       string
+		usages     []x509.ExtKeyUsage
+		wantNames  []string
+		wantServer bool
+	}{
+		{"client auth only", []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}, []string{"client_auth"}, false},
+		{"server and client auth", []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}, []string{"server_auth", "client_auth"}, true},
+		{"code signing", []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning}, []string{"code_signing"}, false},
+		{"any usage", []x509.ExtKeyUsage{x509.ExtKeyUsageAny}, []string{"any"}, true},
+		{"no extension", nil, []string{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pc, err := ParseLeafInput(buildLeaf(t, 0, ekuCert(t, tt.usages), 0), nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if pc.IsServerAuth != tt.wantServer {
+				t.Errorf("IsServerAuth = %v, want %v", pc.IsServerAuth, tt.wantServer)
+			}
+			if len(pc.ExtKeyUsages) != len(tt.wantNames) {
+				t.Fatalf("ExtKeyUsages = %v, want %v", pc.ExtKeyUsages, tt.wantNames)
+			}
+			for i, name := range tt.wantNames {
+				if pc.ExtKeyUsages[i] != name {
+					t.Errorf("ExtKeyUsages[%d] = %q, want %q", i, pc.ExtKeyUsages[i], name)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkParseLeafInput parses one x509_entry leaf per op from a
+// deterministic corpus of 1000 certificates with 1 to 8 names each:
+//
+//	go test -run '^$' -bench ParseLeafInput -benchmem -count 10 ./internal/service/ctlog
+func BenchmarkParseLeafInput(b *testing.B) {
+	leaves := benchcorpus.Leaves(1000, benchcorpus.Keywords(10))
+	b.ReportAllocs()
+	i := 0
+	for b.Loop() {
+		if _, err := ParseLeafInput(leaves[i%len(leaves)], nil); err != nil {
+			b.Fatal(err)
+		}
+		i++
+	}
+	b.ReportMetric(float64(i)/b.Elapsed().Seconds(), "certs/s")
+}
@@ -0,0 +1,80 @@
+package ctlog
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ErrInvalidURL is returned by NormalizeURL for URLs that cannot name a log.
+var ErrInvalidURL = errors.New("invalid CT log URL")
+
+// NormalizeURL turns common spellings of a log URL into the base URL the
+// Client expects: surrounding spaces and trailing slashes are dropped, a
+// missing scheme becomes https, and a pasted endpoint path ("/ct/v1" or
+// "/ct/v1/get-sth") is cut off. changed reports whether any of that was
+// needed. Only http and https URLs with a host and no credentials, query
+// or fragment are accepted.
+func NormalizeURL(raw string) (normalized string, changed bool, err error) {
+	s := strings.TrimSpace(raw)
+	if s == "" {
+		return "", false, fmt.Errorf("%w: empty", ErrInvalidURL)
+	}
+	if !strings.Contains(s, "://") {
+		s = "https://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", false, fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	switch {
+	case u.Scheme != "http" && u.Scheme != "https":
+		return "", false, fmt.Errorf("%w: scheme must be http or https", ErrInvalidURL)
+	case u.Hostname() == "":
+		return "", false, fmt.Errorf("%w: missing host", ErrInvalidURL)
+	case u.User != nil:
+		return "", false, fmt.Errorf("%w: credentials are not allowed", ErrInvalidURL)
+	case u.RawQuery != "" || u.Fragment != "" || strings.HasSuffix(s, "?") || strings.HasSuffix(s, "#"):
+		return "", false, fmt.Errorf("%w: query and fragment are not allowed", ErrInvalidURL)
+	}
+
+	path := strings.TrimRight(u.Path, "/")
+	for _, suffix := range []string{"/ct/v1/get-sth", "/ct/v1/get-entries", "/ct/v1"} {
+		if strings.HasSuffix(path, suffix) {
+			path = strings.TrimSuffix(path, suffix)
+			break
+		}
+	}
+	u.Path, u.RawPath = path, ""
+	normalized = u.String()
+	return normalized, normalized != raw, nil
+}
+
+// ParseAllowedHosts turns a comma-separated list of log URLs into the
+// hosts (host[:port], lowercased) they name, normalizing each entry as
+// NormalizeURL does. Empty entries are skipped.
+func ParseAllowedHosts(list string) ([]string, error) {
+	var hosts []string
+	for _, entry := range strings.Split(list, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		normalized, _, err := NormalizeURL(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", strings.TrimSpace(entry), err)
+		}
+		hosts = append(hosts, Host(normalized))
+	}
+	return hosts, nil
+}
+
+// Host returns the lowercased host[:port] of a URL NormalizeURL accepted,
+// as ParseAllowedHosts reports it.
+func Host(normalized string) string {
+	u, err := url.Parse(normalized)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
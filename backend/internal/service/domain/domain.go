@@ -0,0 +1,63 @@
+// Package domain derives registrable domains (eTLD+1) from certificate
+// hostnames using the public suffix list.
+package domain
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Registrable returns the registrable domain (eTLD+1) of host, e.g.
+// "login.example.co.uk" -> "example.co.uk". A leading wildcard label is
+// ignored. ok is false when host has no registrable domain (IP literals,
+// single labels, bare public suffixes); the normalized host is returned
+// unchanged in that case.
+func Registrable(host string) (domain string, ok bool) {
+	h := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(host), "."))
+	h = strings.TrimPrefix(h, "*.")
+	if h == "" || net.ParseIP(h) != nil || !strings.Contains(h, ".") {
+		return h, false
+	}
+	d, err := publicsuffix.EffectiveTLDPlusOne(h)
+	if err != nil {
+		return h, false
+	}
+	return d, true
+}
+
+type backfillStore interface {
+	PendingRegistrableDomains(ctx context.Context, limit int) (map[int]string, error)
+	SetRegistrableDomain(ctx context.Context, id int, domain string, raw bool) error
+}
+
+const backfillBatchSize = 500
+
+// Backfill computes the registrable domain for rows stored before the
+// column existed. It runs until no rows are pending or ctx is canceled.
+func Backfill(ctx context.Context, store backfillStore) error {
+	total := 0
+	for {
+		pending, err := store.PendingRegistrableDomains(ctx, backfillBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			break
+		}
+		for id, matched := range pending {
+			d, ok := Registrable(matched)
+			if err := store.SetRegistrableDomain(ctx, id, d, !ok); err != nil {
+				return err
+			}
+		}
+		total += len(pending)
+	}
+	if total > 0 {
+		slog.Info("registrable domain backfill complete", "rows", total)
+	}
+	return nil
+}
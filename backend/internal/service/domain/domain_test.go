@@ -0,0 +1,98 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRegistrable(t *testing.T) {
+	tests := []struct {
+		host   string
+		want   string
+		wantOK bool
+	}{
+		{"example.com", "example.com", true},
+		{"login.secure.example.com", "example.com", true},
+		{"WWW.Example.COM.", "example.com", true},
+		{"*.example.com", "example.com", true},
+		{"shop.example.co.uk", "example.co.uk", true},
+		{"*.example.co.uk", "example.co.uk", true},
+		{"user.github.io", "user.github.io", true},
+		{"co.uk", "co.uk", false},
+		{"localhost", "localhost", false},
+		{"192.0.2.1", "192.0.2.1", false},
+		{"2001:db8::1", "2001:db8::1", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			got, ok := Registrable(tt.host)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Registrable(%q) = (%q, %v), want (%q, %v)", tt.host, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+type mockBackfillStore struct {
+	pending map[int]string
+	set     map[int]string
+	raw     map[int]bool
+	setErr  error
+}
+
+func (m *mockBackfillStore) PendingRegistrableDomains(ctx context.Context, limit int) (map[int]string, error) {
+	out := map[int]string{}
+	for id, d := range m.pending {
+		if len(out) == limit {
+			break
+		}
+		out[id] = d
+	}
+	return out, nil
+}
+
+func (m *mockBackfillStore) SetRegistrableDomain(ctx context.Context, id int, domain string, raw bool) error {
+	if m.setErr != nil {
+		return m.setErr
+	}
+	delete(m.pending, id)
+	m.set[id] = domain
+	m.raw[id] = raw
+	return nil
+}
+
+func TestBackfill(t *testing.T) {
+	store := &mockBackfillStore{
+		pending: map[int]string{1: "a.example.com", 2: "10.0.0.1"},
+		set:     map[int]string{},
+		raw:     map[int]bool{},
+	}
+	for i := 3; i < 3+backfillBatchSize; i++ {
+		store.pending[i] = "x.example.org"
+	}
+
+	if err := Backfill(context.Background(), store); err != nil {
+		t.Fatalf("Backfill() error = %v", err)
+	}
+	if len(store.pending) != 0 {
+		t.Errorf("%d rows still pending", len(store.pending))
+	}
+	if store.set[1] != "example.com" || store.raw[1] {
+		t.Errorf("row 1 = (%q, raw=%v), want (example.com, false)", store.set[1], store.raw[1])
+	}
+	if store.set[2] != "10.0.0.1" || !store.raw[2] {
+		t.Errorf("row 2 = (%q, raw=%v), want (10.0.0.1, true)", store.set[2], store.raw[2])
+	}
+}
+
+func TestBackfill_SetError(t *testing.T) {
+	store := &mockBackfillStore{
+		pending: map[int]string{1: "a.example.com"},
+		setErr:  errors.New("db error"),
+	}
+	if err := Backfill(context.Background(), store); err == nil {
+		t.Error("Backfill() error = nil, want error")
+	}
+}
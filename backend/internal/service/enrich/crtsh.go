@@ -0,0 +1,262 @@
+package enrich
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultCrtShURL is crt.sh's public search endpoint.
+const DefaultCrtShURL = "https://crt.sh"
+
+// maxCrtShResponseBytes bounds a crt.sh answer; popular domains can have
+// hundreds of thousands of entries, and a count that large is as useful as
+// an error here.
+const maxCrtShResponseBytes = 32 << 20
+
+// crtShTimeLayout is how crt.sh formats timestamps: UTC without a zone.
+const crtShTimeLayout = "2006-01-02T15:04:05.999999999"
+
+// History is a domain's certificate history.
+type History struct {
+	// CertCount is how many certificates were logged for the domain.
+	CertCount int
+	// FirstSeen is when the earliest of them was logged; zero when there
+	// are none.
+	FirstSeen time.Time
+}
+
+// HistoryLooker finds a domain's certificate history; *CrtSh implements it.
+type HistoryLooker interface {
+	Lookup(ctx context.Context, domain string) (History, error)
+}
+
+// HistoryStore records a match's domain history.
+type HistoryStore interface {
+	SetDomainHistory(ctx context.Context, id, count int, firstSeen time.Time) error
+}
+
+// NewHistory returns an enricher that stores the crt.sh history of each
+// match's registrable domain. Failed lookups leave the match as it is.
+func NewHistory(lookup HistoryLooker, store HistoryStore, opts ...Option) *Enricher[History] {
+	return newEnricher("crtsh", lookup.Lookup,
+		func(ctx context.Context, id int, h History) error {
+			return store.SetDomainHistory(ctx, id, h.CertCount, h.FirstSeen)
+		},
+		nil, opts)
+}
+
+// CrtSh looks up domains on crt.sh's JSON endpoint.
+type CrtSh struct {
+	baseURL string
+	client  *http.Client
+	// maxBytes bounds a response, maxCrtShResponseBytes unless a test
+	// lowers it.
+	maxBytes int64
+}
+
+// NewCrtSh returns a client for baseURL (DefaultCrtShURL in production)
+// whose lookups give up after timeout.
+func NewCrtSh(baseURL string, timeout time.Duration) *CrtSh {
+	return &CrtSh{
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		client:   &http.Client{Timeout: timeout},
+		maxBytes: maxCrtShResponseBytes,
+	}
+}
+
+// crtShEntry holds the fields of a crt.sh result row that History needs.
+type crtShEntry struct {
+	EntryTimestamp string `json:"entry_timestamp"`
+	NotBefore      string `json:"not_before"`
+}
+
+// Lookup counts the certificates crt.sh has for domain (precertificates and
+// their final certificates count once) and finds the earliest log entry.
+func (c *CrtSh) Lookup(ctx context.Context, domain string) (History, error) {
+	q := url.Values{"q": {domain}, "output": {"json"}, "deduplicate": {"Y"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/?"+q.Encode(), nil)
+	if err != nil {
+		return History{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return History{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return History{}, fmt.Errorf("crt.sh returned status %d", resp.StatusCode)
+	}
+
+	body := &io.LimitedReader{R: resp.Body, N: c.maxBytes + 1}
+	h, err := decodeHistory(json.NewDecoder(body))
+	if body.N <= 0 {
+		return History{}, fmt.Errorf("crt.sh response is larger than %d bytes", c.maxBytes)
+	}
+	if err != nil {
+		return History{}, fmt.Errorf("decode crt.sh response: %w", err)
+	}
+	return h, nil
+}
+
+// decodeHistory reads a JSON array of entries one at a time, so only the
+// running count and minimum are kept in memory.
+func decodeHistory(dec *json.Decoder) (History, error) {
+	var h History
+	err := eachEntry(dec, func(e crtShEntry) error {
+		h.CertCount++
+		seen := e.EntryTimestamp
+		if seen == "" {
+			seen = e.NotBefore
+		}
+		if t, err := time.Parse(crtShTimeLayout, seen); err == nil && (h.FirstSeen.IsZero() || t.Before(h.FirstSeen)) {
+			h.FirstSeen = t
+		}
+		return nil
+	})
+	return h, err
+}
+
+// eachEntry decodes a JSON array one element at a time, calling fn for
+// each until it returns an error.
+func eachEntry[T any](dec *json.Decoder, fn func(T) error) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return errors.New("expected a JSON array")
+	}
+	for dec.More() {
+		var e T
+		if err := dec.Decode(&e); err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	_, err = dec.Token()
+	return err
+}
+
+// ErrStopSearch, returned by a Search visitor, ends the search early
+// without an error.
+var ErrStopSearch = errors.New("stop search")
+
+// CrtShCert is a certificate found by Search.
+type CrtShCert struct {
+	// Issuer is the issuer's distinguished name ("C=US, O=Let's Encrypt,
+	// CN=R3"); Names are the certificate's DNS names, SANs and CN alike.
+	Issuer     string
+	CommonName string
+	Names      []string
+	// Serial is the serial number in lowercase hex without leading zeros,
+	// as the log parser formats it.
+	Serial    string
+	NotBefore time.Time
+	NotAfter  time.Time
+	// LoggedAt is when the certificate was first logged; NotBefore when
+	// crt.sh does not say.
+	LoggedAt time.Time
+}
+
+// crtShCertEntry is a crt.sh search result row.
+type crtShCertEntry struct {
+	IssuerName     string `json:"issuer_name"`
+	CommonName     string `json:"common_name"`
+	NameValue      string `json:"name_value"`
+	SerialNumber   string `json:"serial_number"`
+	NotBefore      string `json:"not_before"`
+	NotAfter       string `json:"not_after"`
+	EntryTimestamp string `json:"entry_timestamp"`
+}
+
+// Search streams the certificates crt.sh finds for query, an identity
+// search where % is a wildcard, to visit (precertificates and their final
+// certificates once). Rows without a valid serial or validity are skipped.
+// It stops at the first error visit returns; ErrStopSearch stops it
+// cleanly. A 429 is returned as a *RateLimitError.
+func (c *CrtSh) Search(ctx context.Context, query string, visit func(CrtShCert) error) error {
+	q := url.Values{"q": {query}, "output": {"json"}, "deduplicate": {"Y"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusTooManyRequests:
+		return &RateLimitError{RetryAfter: retryAfter(resp.Header.Get("Retry-After"))}
+	default:
+		return fmt.Errorf("crt.sh returned status %d", resp.StatusCode)
+	}
+
+	body := &io.LimitedReader{R: resp.Body, N: c.maxBytes + 1}
+	var visitErr error
+	err = eachEntry(json.NewDecoder(body), func(e crtShCertEntry) error {
+		cert, ok := e.cert()
+		if !ok {
+			return nil
+		}
+		visitErr = visit(cert)
+		return visitErr
+	})
+	switch {
+	case errors.Is(visitErr, ErrStopSearch):
+		return nil
+	case visitErr != nil:
+		return visitErr
+	case body.N <= 0:
+		return fmt.Errorf("crt.sh response is larger than %d bytes", c.maxBytes)
+	case err != nil:
+		return fmt.Errorf("decode crt.sh response: %w", err)
+	}
+	return nil
+}
+
+func (e crtShCertEntry) cert() (CrtShCert, bool) {
+	serial, ok := new(big.Int).SetString(e.SerialNumber, 16)
+	if !ok {
+		return CrtShCert{}, false
+	}
+	notBefore, err1 := time.Parse(crtShTimeLayout, e.NotBefore)
+	notAfter, err2 := time.Parse(crtShTimeLayout, e.NotAfter)
+	if err1 != nil || err2 != nil {
+		return CrtShCert{}, false
+	}
+	logged, err := time.Parse(crtShTimeLayout, e.EntryTimestamp)
+	if err != nil {
+		logged = notBefore
+	}
+	var names []string
+	for n := range strings.SplitSeq(e.NameValue, "\n") {
+		if n = strings.TrimSpace(n); n != "" {
+			names = append(names, n)
+		}
+	}
+	return CrtShCert{
+		Issuer:     e.IssuerName,
+		CommonName: e.CommonName,
+		Names:      names,
+		Serial:     serial.Text(16),
+		NotBefore:  notBefore.UTC(),
+		NotAfter:   notAfter.UTC(),
+		LoggedAt:   logged.UTC(),
+	}, true
+}
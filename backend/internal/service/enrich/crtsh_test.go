@@ -0,0 +1,183 @@
+package enrich
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCrtShLookup(t *testing.T) {
+	var gotQuery string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.RawQuery
+		fmt.Fprint(w, `[
+			{"id": 3, "entry_timestamp": "2024-05-01T10:00:00.123", "not_before": "2024-05-01T09:00:00"},
+			{"id": 2, "entry_timestamp": "2018-02-03T04:05:06.789", "not_before": "2018-02-03T00:00:00"},
+			{"id": 1, "entry_timestamp": null, "not_before": "2021-01-01T00:00:00"}
+		]`)
+	}))
+	defer srv.Close()
+
+	h, err := NewCrtSh(srv.URL+"/", time.Second).Lookup(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+	if h.CertCount != 3 {
+		t.Errorf("CertCount = %d, want 3", h.CertCount)
+	}
+	if want := time.Date(2018, 2, 3, 4, 5, 6, 789000000, time.UTC); !h.FirstSeen.Equal(want) {
+		t.Errorf("FirstSeen = %v, want %v", h.FirstSeen, want)
+	}
+	if gotQuery != "deduplicate=Y&output=json&q=example.com" {
+		t.Errorf("query = %q", gotQuery)
+	}
+}
+
+func TestCrtShLookup_NoCertificates(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[]`)
+	}))
+	defer srv.Close()
+
+	h, err := NewCrtSh(srv.URL, time.Second).Lookup(context.Background(), "fresh-phish.example")
+	if err != nil || h.CertCount != 0 || !h.FirstSeen.IsZero() {
+		t.Errorf("Lookup = %+v, %v; want an empty history", h, err)
+	}
+}
+
+func TestCrtShLookup_Errors(t *testing.T) {
+	for name, handler := range map[string]http.HandlerFunc{
+		"status": func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "busy", http.StatusBadGateway)
+		},
+		"not an array": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"error":"x"}`)
+		},
+		"truncated": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `[{"id":1},`)
+		},
+		"timeout": func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(handler)
+			defer srv.Close()
+			if _, err := NewCrtSh(srv.URL, 100*time.Millisecond).Lookup(context.Background(), "example.com"); err == nil {
+				t.Error("Lookup succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestCrtShLookup_TooLarge(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entry := `{"entry_timestamp":"2020-01-01T00:00:00"},`
+		w.Write([]byte("["))
+		for written := 0; written <= 4<<10; written += len(entry) {
+			w.Write([]byte(entry))
+		}
+		w.Write([]byte(`{}]`))
+	}))
+	defer srv.Close()
+
+	c := NewCrtSh(srv.URL, 10*time.Second)
+	c.maxBytes = 4 << 10
+	if _, err := c.Lookup(context.Background(), "example.com"); err == nil || !strings.Contains(err.Error(), "larger than 4096") {
+		t.Errorf("Lookup err = %v, want a size error", err)
+	}
+	err := c.Search(context.Background(), "example.com", func(CrtShCert) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "larger than 4096") {
+		t.Errorf("Search err = %v, want a size error", err)
+	}
+}
+
+const crtShSearchBody = `[
+	{"issuer_name": "C=US, O=Let's Encrypt, CN=R3", "common_name": "paypal-login.com",
+	 "name_value": "paypal-login.com\nwww.paypal-login.com", "serial_number": "04a1b2",
+	 "not_before": "2024-05-01T00:00:00", "not_after": "2024-07-30T00:00:00",
+	 "entry_timestamp": "2024-05-01T01:02:03.456"},
+	{"issuer_name": "CN=Bad", "common_name": "x", "name_value": "x", "serial_number": "zz",
+	 "not_before": "2024-05-01T00:00:00", "not_after": "2024-07-30T00:00:00"},
+	{"issuer_name": "CN=E5", "common_name": "", "name_value": "mypaypal.net", "serial_number": "ff",
+	 "not_before": "2023-01-01T00:00:00", "not_after": "2023-04-01T00:00:00", "entry_timestamp": null}
+]`
+
+func TestCrtShSearch(t *testing.T) {
+	var gotQuery string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.RawQuery
+		fmt.Fprint(w, crtShSearchBody)
+	}))
+	defer srv.Close()
+
+	var got []CrtShCert
+	err := NewCrtSh(srv.URL, time.Second).Search(context.Background(), "%paypal%", func(c CrtShCert) error {
+		got = append(got, c)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if gotQuery != "deduplicate=Y&output=json&q=%25paypal%25" {
+		t.Errorf("query = %q", gotQuery)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d certificates, want 2 (invalid serial skipped): %+v", len(got), got)
+	}
+	first := got[0]
+	if first.Serial != "4a1b2" || first.CommonName != "paypal-login.com" || first.Issuer != "C=US, O=Let's Encrypt, CN=R3" {
+		t.Errorf("first = %+v", first)
+	}
+	if len(first.Names) != 2 || first.Names[1] != "www.paypal-login.com" {
+		t.Errorf("Names = %q", first.Names)
+	}
+	if want := time.Date(2024, 5, 1, 1, 2, 3, 456000000, time.UTC); !first.LoggedAt.Equal(want) {
+		t.Errorf("LoggedAt = %v, want %v", first.LoggedAt, want)
+	}
+	if !got[1].LoggedAt.Equal(got[1].NotBefore) {
+		t.Errorf("LoggedAt = %v, want NotBefore when entry_timestamp is null", got[1].LoggedAt)
+	}
+}
+
+func TestCrtShSearch_Stop(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, crtShSearchBody)
+	}))
+	defer srv.Close()
+
+	calls := 0
+	err := NewCrtSh(srv.URL, time.Second).Search(context.Background(), "paypal", func(c CrtShCert) error {
+		calls++
+		return ErrStopSearch
+	})
+	if err != nil || calls != 1 {
+		t.Errorf("Search = %v after %d calls, want nil after 1", err, calls)
+	}
+
+	boom := fmt.Errorf("store failed")
+	err = NewCrtSh(srv.URL, time.Second).Search(context.Background(), "paypal", func(c CrtShCert) error {
+		return boom
+	})
+	if err != boom {
+		t.Errorf("Search = %v, want the visitor's error", err)
+	}
+}
+
+func TestCrtShSearch_RateLimited(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	err := NewCrtSh(srv.URL, time.Second).Search(context.Background(), "paypal", func(CrtShCert) error { return nil })
+	limited, ok := err.(*RateLimitError)
+	if !ok || limited.RetryAfter != 30*time.Second {
+		t.Errorf("err = %v, want a 30s *RateLimitError", err)
+	}
+}
@@ -0,0 +1,252 @@
+// Package enrich adds external context to newly stored matches: the
+// registrable domain's crt.sh history and its RDAP registration date. Each
+// source runs after the insert and off the monitor's path, so a slow,
+// failing or disabled service only leaves its fields empty.
+package enrich
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+const (
+	defaultMinInterval = 5 * time.Second
+	defaultCacheTTL    = 24 * time.Hour
+	defaultQueueSize   = 100
+	// defaultRetryAfter is how long to pause after a rate limit that did
+	// not say how long to wait.
+	defaultRetryAfter = time.Minute
+	// maxCacheEntries bounds the cache; expired entries are dropped once it
+	// is reached.
+	maxCacheEntries = 10000
+)
+
+// RateLimitError reports that a service asked for fewer requests. The
+// enricher makes no lookups to it until RetryAfter has passed.
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited, retry after %v", e.RetryAfter)
+}
+
+type settings struct {
+	minInterval time.Duration
+	cacheTTL    time.Duration
+	queueSize   int
+}
+
+type Option func(*settings)
+
+// WithMinInterval spaces external lookups at least d apart.
+func WithMinInterval(d time.Duration) Option {
+	return func(s *settings) {
+		s.minInterval = d
+	}
+}
+
+// WithCacheTTL reuses a domain's result for d before looking it up again.
+func WithCacheTTL(d time.Duration) Option {
+	return func(s *settings) {
+		s.cacheTTL = d
+	}
+}
+
+// WithQueueSize sets how many matches can wait for enrichment; more are
+// dropped (and left unenriched) rather than slowing down the monitor.
+func WithQueueSize(n int) Option {
+	return func(s *settings) {
+		s.queueSize = n
+	}
+}
+
+type cacheEntry[T any] struct {
+	value   T
+	expires time.Time
+}
+
+// Enricher queues new matches (it is a monitor.MatchPublisher) and looks
+// up their registrable domains one at a time, at most one external call
+// per minInterval. Results are cached per domain for cacheTTL, so a burst
+// of matches for one domain costs a single lookup.
+type Enricher[T any] struct {
+	name   string
+	lookup func(ctx context.Context, domain string) (T, error)
+	store  func(ctx context.Context, id int, value T) error
+	// fallback, when set, turns a failed lookup into a value to store
+	// (uncached) instead of leaving the match as it is.
+	fallback func(err error) (T, bool)
+
+	settings
+	queue chan model.MatchedCertificate
+	now   func() time.Time
+
+	// cache, lastCall and pausedUntil are only touched by Run.
+	cache       map[string]cacheEntry[T]
+	lastCall    time.Time
+	pausedUntil time.Time
+
+	dropped atomic.Int64
+}
+
+func newEnricher[T any](
+	name string,
+	lookup func(ctx context.Context, domain string) (T, error),
+	store func(ctx context.Context, id int, value T) error,
+	fallback func(err error) (T, bool),
+	opts []Option,
+) *Enricher[T] {
+	e := &Enricher[T]{
+		name:     name,
+		lookup:   lookup,
+		store:    store,
+		fallback: fallback,
+		settings: settings{
+			minInterval: defaultMinInterval,
+			cacheTTL:    defaultCacheTTL,
+			queueSize:   defaultQueueSize,
+		},
+		now:   time.Now,
+		cache: make(map[string]cacheEntry[T]),
+	}
+	for _, opt := range opts {
+		opt(&e.settings)
+	}
+	e.queue = make(chan model.MatchedCertificate, e.queueSize)
+	return e
+}
+
+// Name identifies the source in logs and metrics.
+func (e *Enricher[T]) Name() string {
+	return e.name
+}
+
+// Publish queues cert for enrichment without blocking. Matches without a
+// registrable domain (IP literals, single labels) are skipped.
+func (e *Enricher[T]) Publish(cert model.MatchedCertificate) {
+	if cert.ID == 0 || cert.RegistrableDomain == "" || cert.RegistrableDomainRaw {
+		return
+	}
+	select {
+	case e.queue <- cert:
+	default:
+		e.dropped.Add(1)
+		slog.Debug("enrichment queue full, skipping match",
+			"source", e.name, "id", cert.ID, "domain", cert.RegistrableDomain)
+	}
+}
+
+// Dropped returns how many matches were skipped because the queue was full.
+func (e *Enricher[T]) Dropped() int64 {
+	return e.dropped.Load()
+}
+
+// Run enriches queued matches until ctx is canceled.
+func (e *Enricher[T]) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case cert := <-e.queue:
+			e.enrich(ctx, cert)
+		}
+	}
+}
+
+func (e *Enricher[T]) enrich(ctx context.Context, cert model.MatchedCertificate) {
+	domain := cert.RegistrableDomain
+	v, ok := e.cached(domain)
+	if !ok {
+		var err error
+		v, err = e.fetch(ctx, domain)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.WarnContext(ctx, "enrichment lookup failed", "source", e.name, "domain", domain, "error", err)
+			if e.fallback == nil {
+				return
+			}
+			if v, ok = e.fallback(err); !ok {
+				return
+			}
+		} else {
+			e.remember(domain, v)
+		}
+	}
+
+	if err := e.store(ctx, cert.ID, v); err != nil && ctx.Err() == nil {
+		slog.WarnContext(ctx, "failed to store enrichment",
+			"source", e.name, "id", cert.ID, "domain", domain, "error", err)
+	}
+}
+
+// fetch looks domain up, unless the service recently rate limited us: then
+// it fails at once so the queue keeps moving.
+func (e *Enricher[T]) fetch(ctx context.Context, domain string) (T, error) {
+	var zero T
+	if wait := e.pausedUntil.Sub(e.now()); wait > 0 {
+		return zero, &RateLimitError{RetryAfter: wait}
+	}
+	if !e.wait(ctx) {
+		return zero, ctx.Err()
+	}
+	v, err := e.lookup(ctx, domain)
+	var limited *RateLimitError
+	if errors.As(err, &limited) {
+		retry := limited.RetryAfter
+		if retry <= 0 {
+			retry = defaultRetryAfter
+		}
+		e.pausedUntil = e.now().Add(retry)
+	}
+	return v, err
+}
+
+func (e *Enricher[T]) cached(domain string) (T, bool) {
+	entry, ok := e.cache[domain]
+	if !ok || !e.now().Before(entry.expires) {
+		var zero T
+		return zero, false
+	}
+	return entry.value, true
+}
+
+func (e *Enricher[T]) remember(domain string, v T) {
+	now := e.now()
+	if len(e.cache) >= maxCacheEntries {
+		for d, entry := range e.cache {
+			if !now.Before(entry.expires) {
+				delete(e.cache, d)
+			}
+		}
+		// Still full of live entries: start over rather than grow.
+		if len(e.cache) >= maxCacheEntries {
+			clear(e.cache)
+		}
+	}
+	e.cache[domain] = cacheEntry[T]{value: v, expires: now.Add(e.cacheTTL)}
+}
+
+// wait blocks until minInterval has passed since the previous lookup and
+// reports false if ctx ended first.
+func (e *Enricher[T]) wait(ctx context.Context) bool {
+	if delay := e.lastCall.Add(e.minInterval).Sub(e.now()); delay > 0 {
+		t := time.NewTimer(delay)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+			return false
+		case <-t.C:
+		}
+	}
+	e.lastCall = e.now()
+	return true
+}
@@ -0,0 +1,246 @@
+package enrich
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+type fakeLooker struct {
+	mu    sync.Mutex
+	calls []string
+	at    []time.Time
+	fn    func(domain string) (History, error)
+}
+
+func (f *fakeLooker) Lookup(ctx context.Context, domain string) (History, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, domain)
+	f.at = append(f.at, time.Now())
+	f.mu.Unlock()
+	return f.fn(domain)
+}
+
+type storedHistory struct {
+	id, count int
+	firstSeen time.Time
+}
+
+type fakeStore struct {
+	mu     sync.Mutex
+	stored []storedHistory
+}
+
+func (f *fakeStore) SetDomainHistory(ctx context.Context, id, count int, firstSeen time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stored = append(f.stored, storedHistory{id, count, firstSeen})
+	return nil
+}
+
+func newMatch(id int, domain string) model.MatchedCertificate {
+	return model.MatchedCertificate{ID: id, MatchedDomain: "login." + domain, RegistrableDomain: domain}
+}
+
+func TestEnrich_StoresHistoryAndCachesPerDomain(t *testing.T) {
+	first := time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)
+	looker := &fakeLooker{fn: func(domain string) (History, error) {
+		return History{CertCount: 12, FirstSeen: first}, nil
+	}}
+	store := &fakeStore{}
+	e := NewHistory(looker, store, WithMinInterval(0))
+
+	ctx := context.Background()
+	e.enrich(ctx, newMatch(1, "example.com"))
+	e.enrich(ctx, newMatch(2, "example.com"))
+
+	if len(looker.calls) != 1 {
+		t.Errorf("lookups = %v, want one for the cached domain", looker.calls)
+	}
+	want := []storedHistory{{1, 12, first}, {2, 12, first}}
+	if len(store.stored) != 2 || store.stored[0] != want[0] || store.stored[1] != want[1] {
+		t.Errorf("stored = %+v, want %+v", store.stored, want)
+	}
+}
+
+func TestEnrich_CacheExpires(t *testing.T) {
+	looker := &fakeLooker{fn: func(string) (History, error) { return History{CertCount: 1}, nil }}
+	e := NewHistory(looker, &fakeStore{}, WithMinInterval(0), WithCacheTTL(time.Hour))
+	now := time.Now()
+	e.now = func() time.Time { return now }
+
+	e.enrich(context.Background(), newMatch(1, "example.com"))
+	now = now.Add(2 * time.Hour)
+	e.enrich(context.Background(), newMatch(2, "example.com"))
+
+	if len(looker.calls) != 2 {
+		t.Errorf("lookups = %d, want a fresh one after the TTL", len(looker.calls))
+	}
+}
+
+func TestEnrich_FailureStoresNothing(t *testing.T) {
+	looker := &fakeLooker{fn: func(string) (History, error) { return History{}, errors.New("crt.sh returned status 502") }}
+	store := &fakeStore{}
+	e := NewHistory(looker, store, WithMinInterval(0))
+
+	e.enrich(context.Background(), newMatch(1, "example.com"))
+	e.enrich(context.Background(), newMatch(2, "example.com"))
+
+	if len(store.stored) != 0 {
+		t.Errorf("stored = %+v, want nothing after failed lookups", store.stored)
+	}
+	if len(looker.calls) != 2 {
+		t.Errorf("lookups = %d, want failures not to be cached", len(looker.calls))
+	}
+}
+
+func TestEnrich_RateLimitsLookups(t *testing.T) {
+	looker := &fakeLooker{fn: func(string) (History, error) { return History{}, nil }}
+	e := NewHistory(looker, &fakeStore{}, WithMinInterval(50*time.Millisecond))
+
+	for i, domain := range []string{"a.com", "b.com", "c.com"} {
+		e.enrich(context.Background(), newMatch(i+1, domain))
+	}
+
+	for i := 1; i < len(looker.at); i++ {
+		if gap := looker.at[i].Sub(looker.at[i-1]); gap < 50*time.Millisecond {
+			t.Errorf("lookup %d came %v after the previous one, want at least 50ms", i, gap)
+		}
+	}
+}
+
+func TestEnrich_WaitStopsOnCancel(t *testing.T) {
+	looker := &fakeLooker{fn: func(string) (History, error) { return History{}, nil }}
+	e := NewHistory(looker, &fakeStore{}, WithMinInterval(time.Hour))
+	e.lastCall = time.Now()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	e.enrich(ctx, newMatch(1, "example.com"))
+
+	if len(looker.calls) != 0 {
+		t.Errorf("lookups = %v, want none once canceled", looker.calls)
+	}
+}
+
+func TestPublish_SkipsAndDrops(t *testing.T) {
+	e := NewHistory(&fakeLooker{}, &fakeStore{}, WithQueueSize(1))
+
+	e.Publish(model.MatchedCertificate{RegistrableDomain: "example.com"})                                 // already stored
+	e.Publish(model.MatchedCertificate{ID: 1, RegistrableDomain: "10.0.0.1", RegistrableDomainRaw: true}) // no eTLD+1
+	e.Publish(newMatch(2, "example.com"))
+	e.Publish(newMatch(3, "example.com"))
+
+	if got := len(e.queue); got != 1 {
+		t.Errorf("queued = %d, want 1", got)
+	}
+	if got := e.Dropped(); got != 1 {
+		t.Errorf("Dropped() = %d, want 1", got)
+	}
+}
+
+func TestRun_ProcessesQueueUntilCanceled(t *testing.T) {
+	looker := &fakeLooker{fn: func(string) (History, error) { return History{CertCount: 3}, nil }}
+	store := &fakeStore{}
+	e := NewHistory(looker, store, WithMinInterval(0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		e.Run(ctx)
+		close(done)
+	}()
+
+	e.Publish(newMatch(7, "example.com"))
+	deadline := time.After(time.Second)
+	for {
+		store.mu.Lock()
+		n := len(store.stored)
+		store.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatal("match was not enriched")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+}
+
+type fakeRegistrations struct {
+	calls int
+	fn    func(domain string) (time.Time, error)
+}
+
+func (f *fakeRegistrations) Registered(ctx context.Context, domain string) (time.Time, error) {
+	f.calls++
+	return f.fn(domain)
+}
+
+type fakeAgeStore struct {
+	stored map[int]time.Time
+}
+
+func (f *fakeAgeStore) SetDomainAge(ctx context.Context, id int, registeredAt time.Time) error {
+	f.stored[id] = registeredAt
+	return nil
+}
+
+func TestDomainAge_FailureMarksUnknown(t *testing.T) {
+	lookup := &fakeRegistrations{fn: func(string) (time.Time, error) { return time.Time{}, errors.New("rdap returned status 503") }}
+	store := &fakeAgeStore{stored: map[int]time.Time{}}
+	e := NewDomainAge(lookup, store, WithMinInterval(0))
+
+	e.enrich(context.Background(), newMatch(1, "example.com"))
+	e.enrich(context.Background(), newMatch(2, "example.com"))
+
+	if registered, ok := store.stored[1]; !ok || !registered.IsZero() {
+		t.Errorf("stored = %v, want match 1 marked unknown", store.stored)
+	}
+	if lookup.calls != 2 {
+		t.Errorf("lookups = %d, want failures not to be cached", lookup.calls)
+	}
+}
+
+func TestDomainAge_RateLimitPausesLookups(t *testing.T) {
+	registered := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	limited := true
+	lookup := &fakeRegistrations{fn: func(string) (time.Time, error) {
+		if limited {
+			return time.Time{}, &RateLimitError{RetryAfter: time.Minute}
+		}
+		return registered, nil
+	}}
+	store := &fakeAgeStore{stored: map[int]time.Time{}}
+	e := NewDomainAge(lookup, store, WithMinInterval(0))
+	now := time.Now()
+	e.now = func() time.Time { return now }
+
+	e.enrich(context.Background(), newMatch(1, "a.com"))
+	limited = false
+	e.enrich(context.Background(), newMatch(2, "b.com"))
+	if lookup.calls != 1 {
+		t.Errorf("lookups = %d, want none while paused", lookup.calls)
+	}
+	if got, ok := store.stored[2]; !ok || !got.IsZero() {
+		t.Errorf("match 2 = %v, %v; want it marked unknown without waiting", got, ok)
+	}
+
+	now = now.Add(time.Minute)
+	e.enrich(context.Background(), newMatch(3, "b.com"))
+	if got := store.stored[3]; !got.Equal(registered) {
+		t.Errorf("match 3 = %v, want %v once the pause is over", got, registered)
+	}
+}
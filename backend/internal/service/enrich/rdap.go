@@ -0,0 +1,118 @@
+package enrich
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultRDAPURL is rdap.org, which redirects each query to the RDAP server
+// the IANA bootstrap registry lists for the domain's TLD.
+const DefaultRDAPURL = "https://rdap.org"
+
+// maxRDAPResponseBytes bounds an RDAP answer; domain objects are a few KiB.
+const maxRDAPResponseBytes = 1 << 20
+
+// RegistrationLooker finds when a domain was registered; *RDAP implements
+// it.
+type RegistrationLooker interface {
+	Registered(ctx context.Context, domain string) (time.Time, error)
+}
+
+// DomainAgeStore records a match's domain registration date; a zero time
+// marks it unknown.
+type DomainAgeStore interface {
+	SetDomainAge(ctx context.Context, id int, registeredAt time.Time) error
+}
+
+// NewDomainAge returns an enricher that stores the RDAP registration date
+// of each match's registrable domain. A failed or rate-limited lookup marks
+// the age unknown instead of holding up the queue; only answers are cached.
+func NewDomainAge(lookup RegistrationLooker, store DomainAgeStore, opts ...Option) *Enricher[time.Time] {
+	return newEnricher("rdap", lookup.Registered, store.SetDomainAge,
+		func(error) (time.Time, bool) { return time.Time{}, true },
+		opts)
+}
+
+// RDAP looks up domain registration dates over RDAP (RFC 9082/9083).
+type RDAP struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewRDAP returns a client for baseURL (DefaultRDAPURL in production) whose
+// lookups, redirects included, give up after timeout.
+func NewRDAP(baseURL string, timeout time.Duration) *RDAP {
+	return &RDAP{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+type rdapDomain struct {
+	Events []struct {
+		Action string `json:"eventAction"`
+		Date   string `json:"eventDate"`
+	} `json:"events"`
+}
+
+// Registered returns the date of domain's "registration" event. The zero
+// time means RDAP has no answer: no server for the TLD (common for ccTLDs),
+// an unknown domain, or a record without that event. A 429 is returned as
+// a *RateLimitError.
+func (c *RDAP) Registered(ctx context.Context, domain string) (time.Time, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/domain/"+url.PathEscape(domain), nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	req.Header.Set("Accept", "application/rdap+json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return time.Time{}, nil
+	case http.StatusTooManyRequests:
+		return time.Time{}, &RateLimitError{RetryAfter: retryAfter(resp.Header.Get("Retry-After"))}
+	default:
+		return time.Time{}, fmt.Errorf("rdap returned status %d", resp.StatusCode)
+	}
+
+	var d rdapDomain
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxRDAPResponseBytes)).Decode(&d); err != nil {
+		return time.Time{}, fmt.Errorf("decode rdap response: %w", err)
+	}
+	for _, e := range d.Events {
+		if e.Action != "registration" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, e.Date)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("rdap registration date %q: %w", e.Date, err)
+		}
+		return t, nil
+	}
+	return time.Time{}, nil
+}
+
+// retryAfter parses a Retry-After header (seconds or an HTTP date); zero
+// means it was absent or unparseable.
+func retryAfter(v string) time.Duration {
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
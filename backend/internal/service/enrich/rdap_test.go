@@ -0,0 +1,97 @@
+package enrich
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRDAPRegistered(t *testing.T) {
+	mux := http.NewServeMux()
+	// rdap.org answers with a redirect to the TLD's server.
+	mux.HandleFunc("/domain/example.com", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/registry/domain/example.com", http.StatusFound)
+	})
+	mux.HandleFunc("/registry/domain/example.com", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Accept"); got != "application/rdap+json" {
+			t.Errorf("Accept = %q", got)
+		}
+		fmt.Fprint(w, `{"objectClassName":"domain","ldhName":"EXAMPLE.COM","events":[
+			{"eventAction":"expiration","eventDate":"2026-08-13T04:00:00Z"},
+			{"eventAction":"registration","eventDate":"1995-08-14T04:00:00Z"}
+		]}`)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	got, err := NewRDAP(srv.URL+"/", time.Second).Registered(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("Registered: %v", err)
+	}
+	if want := time.Date(1995, 8, 14, 4, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Registered = %v, want %v", got, want)
+	}
+}
+
+func TestRDAPRegistered_Unknown(t *testing.T) {
+	for name, handler := range map[string]http.HandlerFunc{
+		"no server for the TLD": func(w http.ResponseWriter, r *http.Request) {
+			http.NotFound(w, r)
+		},
+		"no registration event": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"objectClassName":"domain","events":[{"eventAction":"last changed","eventDate":"2024-01-01T00:00:00Z"}]}`)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(handler)
+			defer srv.Close()
+			got, err := NewRDAP(srv.URL, time.Second).Registered(context.Background(), "example.ch")
+			if err != nil || !got.IsZero() {
+				t.Errorf("Registered = %v, %v; want zero time and no error", got, err)
+			}
+		})
+	}
+}
+
+func TestRDAPRegistered_RateLimited(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	_, err := NewRDAP(srv.URL, time.Second).Registered(context.Background(), "example.com")
+	var limited *RateLimitError
+	if !errors.As(err, &limited) || limited.RetryAfter != 30*time.Second {
+		t.Errorf("err = %v, want a RateLimitError with RetryAfter 30s", err)
+	}
+}
+
+func TestRDAPRegistered_Errors(t *testing.T) {
+	for name, handler := range map[string]http.HandlerFunc{
+		"status": func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+		},
+		"not json": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `<html>`)
+		},
+		"bad date": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"events":[{"eventAction":"registration","eventDate":"14 Aug 1995"}]}`)
+		},
+		"timeout": func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(handler)
+			defer srv.Close()
+			if _, err := NewRDAP(srv.URL, 100*time.Millisecond).Registered(context.Background(), "example.com"); err == nil {
+				t.Error("Registered succeeded, want an error")
+			}
+		})
+	}
+}
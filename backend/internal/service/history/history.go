@@ -0,0 +1,422 @@
+// Package history imports a keyword's past matches from crt.sh, for
+// keywords added after the monitor passed the certificates that would have
+// matched them. Imports run as background jobs, one crt.sh request at a
+// time, and store what they find as matches with source crtsh.
+package history
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/ctlog"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/domain"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/enrich"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/issuer"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/matcher"
+)
+
+const (
+	defaultMaxRows     = 1000
+	defaultMinInterval = 5 * time.Second
+	// maxFinishedJobs bounds the finished jobs kept for GET; the oldest are
+	// forgotten first.
+	maxFinishedJobs = 50
+)
+
+var (
+	ErrJobRunning  = errors.New("an import is already running for this keyword")
+	ErrJobNotFound = errors.New("import job not found")
+	ErrJobFinished = errors.New("import job already finished")
+	ErrClosed      = errors.New("importer is shut down")
+)
+
+type searcher interface {
+	Search(ctx context.Context, query string, visit func(enrich.CrtShCert) error) error
+}
+
+type keywordGetter interface {
+	Get(ctx context.Context, id int) (*model.Keyword, error)
+}
+
+type certImporter interface {
+	Import(ctx context.Context, cert *model.MatchedCertificate) (bool, error)
+}
+
+// Importer runs import jobs. Jobs live in memory only: a restart forgets
+// them, but not the matches they stored.
+type Importer struct {
+	search      searcher
+	keywords    keywordGetter
+	certs       certImporter
+	maxRows     int
+	minInterval time.Duration
+	ignoreCN    bool
+	now         func() time.Time
+
+	// ctx is canceled by Close, stopping every job.
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// gate admits one crt.sh request at a time; notBefore is when the next
+	// may start. Both are guarded by holding gate.
+	gate      chan struct{}
+	notBefore time.Time
+
+	mu     sync.Mutex
+	nextID int
+	jobs   map[int]*job
+}
+
+type job struct {
+	state  model.ImportJob
+	cancel context.CancelFunc
+	// canceled is set by Cancel, to tell it from a shutdown.
+	canceled bool
+}
+
+type Option func(*Importer)
+
+// WithMaxRows caps the matches one import stores or finds already stored,
+// and the max_rows a request may ask for. The default is 1000.
+func WithMaxRows(n int) Option {
+	return func(im *Importer) {
+		if n > 0 {
+			im.maxRows = n
+		}
+	}
+}
+
+// WithMinInterval spaces crt.sh requests at least d apart. The default is
+// 5s.
+func WithMinInterval(d time.Duration) Option {
+	return func(im *Importer) {
+		im.minInterval = d
+	}
+}
+
+// WithIgnoreCN matches keywords against SANs only, as the monitor does
+// with MATCH_IGNORE_CN.
+func WithIgnoreCN(ignore bool) Option {
+	return func(im *Importer) {
+		im.ignoreCN = ignore
+	}
+}
+
+// New returns an Importer that searches crt.sh with search and stores
+// matches with certs. Call Close to stop its jobs.
+func New(search searcher, keywords keywordGetter, certs certImporter, opts ...Option) *Importer {
+	ctx, cancel := context.WithCancel(context.Background())
+	im := &Importer{
+		search:      search,
+		keywords:    keywords,
+		certs:       certs,
+		maxRows:     defaultMaxRows,
+		minInterval: defaultMinInterval,
+		now:         time.Now,
+		ctx:         ctx,
+		cancel:      cancel,
+		gate:        make(chan struct{}, 1),
+		jobs:        make(map[int]*job),
+	}
+	for _, opt := range opts {
+		opt(im)
+	}
+	return im
+}
+
+// MaxRows is the largest max_rows a job may use.
+func (im *Importer) MaxRows() int {
+	return im.maxRows
+}
+
+// Start launches an import of the matches for keyword id first logged at or
+// after since, stopping after maxRows of them (0 or more than MaxRows means
+// MaxRows). It returns the job as started; a keyword that does not exist
+// is repository.ErrNotFound, and one already being imported ErrJobRunning.
+func (im *Importer) Start(ctx context.Context, keywordID int, since time.Time, maxRows int) (model.ImportJob, error) {
+	if maxRows <= 0 || maxRows > im.maxRows {
+		maxRows = im.maxRows
+	}
+	kw, err := im.keywords.Get(ctx, keywordID)
+	if err != nil {
+		return model.ImportJob{}, err
+	}
+
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	if im.ctx.Err() != nil {
+		return model.ImportJob{}, ErrClosed
+	}
+	for _, j := range im.jobs {
+		if j.state.KeywordID == keywordID && j.state.Status == model.ImportRunning {
+			return model.ImportJob{}, ErrJobRunning
+		}
+	}
+	im.nextID++
+	jobCtx, cancel := context.WithCancel(im.ctx)
+	j := &job{
+		state: model.ImportJob{
+			ID:        im.nextID,
+			KeywordID: kw.ID,
+			Keyword:   kw.Value,
+			Since:     since,
+			MaxRows:   maxRows,
+			Status:    model.ImportRunning,
+			StartedAt: im.now(),
+		},
+		cancel: cancel,
+	}
+	im.jobs[j.state.ID] = j
+	im.pruneLocked()
+	im.wg.Go(func() { im.run(jobCtx, j, *kw) })
+	return j.state, nil
+}
+
+// Get returns job id as it stands.
+func (im *Importer) Get(id int) (model.ImportJob, error) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	j, ok := im.jobs[id]
+	if !ok {
+		return model.ImportJob{}, ErrJobNotFound
+	}
+	return j.state, nil
+}
+
+// List returns the known jobs, newest first.
+func (im *Importer) List() []model.ImportJob {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	jobs := make([]model.ImportJob, 0, len(im.jobs))
+	for _, j := range im.jobs {
+		jobs = append(jobs, j.state)
+	}
+	slices.SortFunc(jobs, func(a, b model.ImportJob) int { return b.ID - a.ID })
+	return jobs
+}
+
+// Cancel stops a running job. Matches it already stored are kept.
+func (im *Importer) Cancel(id int) (model.ImportJob, error) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	j, ok := im.jobs[id]
+	if !ok {
+		return model.ImportJob{}, ErrJobNotFound
+	}
+	if j.state.Status != model.ImportRunning {
+		return j.state, ErrJobFinished
+	}
+	j.canceled = true
+	j.cancel()
+	return j.state, nil
+}
+
+// Close cancels every running job and waits for them to stop.
+func (im *Importer) Close() {
+	im.cancel()
+	im.wg.Wait()
+}
+
+// pruneLocked forgets the oldest finished jobs beyond maxFinishedJobs.
+func (im *Importer) pruneLocked() {
+	var finished []int
+	for id, j := range im.jobs {
+		if j.state.Status != model.ImportRunning {
+			finished = append(finished, id)
+		}
+	}
+	if len(finished) <= maxFinishedJobs {
+		return
+	}
+	slices.Sort(finished)
+	for _, id := range finished[:len(finished)-maxFinishedJobs] {
+		delete(im.jobs, id)
+	}
+}
+
+func (im *Importer) update(j *job, fn func(s *model.ImportJob)) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	fn(&j.state)
+}
+
+func (im *Importer) run(ctx context.Context, j *job, kw model.Keyword) {
+	defer j.cancel()
+	err := im.searchGated(ctx, "%"+strings.ToLower(kw.Value)+"%", func(c enrich.CrtShCert) error {
+		return im.visit(ctx, j, kw, c)
+	})
+
+	im.mu.Lock()
+	finished := im.now()
+	j.state.FinishedAt = &finished
+	switch {
+	case err == nil:
+		j.state.Status = model.ImportCompleted
+	case ctx.Err() != nil:
+		j.state.Status = model.ImportCanceled
+		if !j.canceled {
+			j.state.Error = "server shut down"
+		}
+	default:
+		j.state.Status = model.ImportFailed
+		j.state.Error = err.Error()
+	}
+	state := j.state
+	im.mu.Unlock()
+
+	slog.Info("crt.sh import finished",
+		"job_id", state.ID,
+		"keyword", state.Keyword,
+		"status", state.Status,
+		"fetched", state.Fetched,
+		"matched", state.Matched,
+		"imported", state.Imported,
+		"duplicates", state.Duplicates,
+		"error", state.Error,
+	)
+}
+
+// searchGated runs one crt.sh search once no other is in flight and
+// minInterval has passed since the last one started (longer after a 429).
+func (im *Importer) searchGated(ctx context.Context, query string, visit func(enrich.CrtShCert) error) error {
+	select {
+	case im.gate <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-im.gate }()
+
+	if wait := im.notBefore.Sub(im.now()); wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+	im.notBefore = im.now().Add(im.minInterval)
+
+	err := im.search.Search(ctx, query, visit)
+	var limited *enrich.RateLimitError
+	if errors.As(err, &limited) {
+		im.notBefore = im.now().Add(max(limited.RetryAfter, im.minInterval))
+	}
+	return err
+}
+
+// visit matches one crt.sh certificate against kw and stores the match,
+// ending the search once the job has MaxRows matches.
+func (im *Importer) visit(ctx context.Context, j *job, kw model.Keyword, c enrich.CrtShCert) error {
+	im.update(j, func(s *model.ImportJob) { s.Fetched++ })
+	if c.LoggedAt.Before(j.state.Since) {
+		return nil
+	}
+
+	issuerName := issuerCommonName(c.Issuer)
+	parsed := &ctlog.ParsedCertificate{
+		Serial:     c.Serial,
+		CommonName: c.CommonName,
+		SANs:       c.Names,
+		Issuer:     issuerName,
+		NotBefore:  c.NotBefore,
+		NotAfter:   c.NotAfter,
+	}
+	matches := matcher.MatchWith(parsed, []model.Keyword{kw}, matcher.Options{IgnoreCN: im.ignoreCN})
+	if len(matches) == 0 {
+		return nil
+	}
+	match := matches[0]
+	registrable, ok := domain.Registrable(match.MatchedDomain)
+	inserted, err := im.certs.Import(ctx, &model.MatchedCertificate{
+		SerialNumber:         c.Serial,
+		CommonName:           c.CommonName,
+		SANs:                 c.Names,
+		Issuer:               issuerName,
+		NotBefore:            c.NotBefore,
+		NotAfter:             c.NotAfter,
+		KeywordID:            kw.ID,
+		KeywordValue:         kw.Value,
+		MatchedDomain:        match.MatchedDomain,
+		MatchedField:         match.MatchedField,
+		MatchedValue:         match.MatchedValue,
+		RegistrableDomain:    registrable,
+		RegistrableDomainRaw: !ok,
+		// crt.sh's JSON has no key usages; count the match as a server
+		// certificate, as rows stored before they were parsed are.
+		IsServerAuth: true,
+		ChainStatus:  model.ChainNotChecked,
+		IssuerClass:  issuer.Classify(issuerName),
+		Source:       model.SourceCrtSh,
+		FirstSeenAt:  c.LoggedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	var done bool
+	im.update(j, func(s *model.ImportJob) {
+		s.Matched++
+		if inserted {
+			s.Imported++
+		} else {
+			s.Duplicates++
+		}
+		done = s.Matched >= s.MaxRows
+	})
+	if done {
+		return enrich.ErrStopSearch
+	}
+	return nil
+}
+
+// issuerCommonName picks the issuer name the log parser would store from a
+// crt.sh distinguished name ("C=US, O=Let's Encrypt, CN=R3"): the CN, else
+// the first O, else the whole name.
+func issuerCommonName(dn string) string {
+	var org string
+	for _, rdn := range splitDN(dn) {
+		key, value, ok := strings.Cut(rdn, "=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+		switch strings.TrimSpace(key) {
+		case "CN":
+			return value
+		case "O":
+			if org == "" {
+				org = value
+			}
+		}
+	}
+	if org != "" {
+		return org
+	}
+	return dn
+}
+
+// splitDN splits a distinguished name on the commas outside quotes.
+func splitDN(dn string) []string {
+	var parts []string
+	quoted, start := false, 0
+	for i, r := range dn {
+		switch r {
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				parts = append(parts, dn[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, dn[start:])
+}
@@ -0,0 +1,375 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/repository"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/enrich"
+)
+
+// --- mocks ---
+
+type mockSearcher struct {
+	mu      sync.Mutex
+	queries []string
+	certs   []enrich.CrtShCert
+	err     error
+	// block, when set, makes Search wait for the context to end.
+	block bool
+}
+
+func (m *mockSearcher) Search(ctx context.Context, query string, visit func(enrich.CrtShCert) error) error {
+	m.mu.Lock()
+	m.queries = append(m.queries, query)
+	m.mu.Unlock()
+	if m.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	for _, c := range m.certs {
+		if err := visit(c); err != nil {
+			if errors.Is(err, enrich.ErrStopSearch) {
+				return nil
+			}
+			return err
+		}
+	}
+	return m.err
+}
+
+type mockKeywords struct {
+	keywords map[int]model.Keyword
+}
+
+func (m *mockKeywords) Get(ctx context.Context, id int) (*model.Keyword, error) {
+	kw, ok := m.keywords[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &kw, nil
+}
+
+type mockCerts struct {
+	mu     sync.Mutex
+	stored map[string]model.MatchedCertificate
+	err    error
+}
+
+func (m *mockCerts) Import(ctx context.Context, cert *model.MatchedCertificate) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return false, m.err
+	}
+	if m.stored == nil {
+		m.stored = make(map[string]model.MatchedCertificate)
+	}
+	if _, ok := m.stored[cert.SerialNumber]; ok {
+		return false, nil
+	}
+	m.stored[cert.SerialNumber] = *cert
+	return true, nil
+}
+
+func keywords() *mockKeywords {
+	return &mockKeywords{keywords: map[int]model.Keyword{
+		1: {ID: 1, Value: "PayPal", MatchMode: model.MatchModeSubstring},
+	}}
+}
+
+func crtCert(serial, name string, logged time.Time) enrich.CrtShCert {
+	return enrich.CrtShCert{
+		Issuer:     "C=US, O=Let's Encrypt, CN=R3",
+		CommonName: name,
+		Names:      []string{name},
+		Serial:     serial,
+		NotBefore:  logged,
+		NotAfter:   logged.Add(90 * 24 * time.Hour),
+		LoggedAt:   logged,
+	}
+}
+
+// wait polls job id until it is no longer running.
+func wait(t *testing.T, im *Importer, id int) model.ImportJob {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		j, err := im.Get(id)
+		if err != nil {
+			t.Fatalf("Get(%d): %v", id, err)
+		}
+		if j.Status != model.ImportRunning {
+			return j
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %d still running", id)
+	return model.ImportJob{}
+}
+
+// --- tests ---
+
+func TestImport_StoresMatchesSince(t *testing.T) {
+	since := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	search := &mockSearcher{certs: []enrich.CrtShCert{
+		crtCert("a1", "paypal-login.com", since.Add(time.Hour)),
+		crtCert("a2", "unrelated.com", since.Add(time.Hour)),   // name does not match
+		crtCert("a3", "paypal-old.com", since.Add(-time.Hour)), // before since
+		crtCert("a4", "secure-paypal.co.uk", since.Add(48*time.Hour)),
+	}}
+	certs := &mockCerts{}
+	im := New(search, keywords(), certs, WithMinInterval(0))
+	defer im.Close()
+
+	started, err := im.Start(context.Background(), 1, since, 0)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if started.Status != model.ImportRunning || started.Keyword != "PayPal" || started.MaxRows != defaultMaxRows {
+		t.Errorf("started = %+v", started)
+	}
+	j := wait(t, im, started.ID)
+
+	if j.Status != model.ImportCompleted || j.Error != "" {
+		t.Fatalf("job = %+v, want completed", j)
+	}
+	if j.Fetched != 4 || j.Matched != 2 || j.Imported != 2 || j.Duplicates != 0 {
+		t.Errorf("progress = %d/%d/%d/%d, want 4/2/2/0", j.Fetched, j.Matched, j.Imported, j.Duplicates)
+	}
+	if j.FinishedAt == nil {
+		t.Error("FinishedAt not set")
+	}
+	if len(search.queries) != 1 || search.queries[0] != "%paypal%" {
+		t.Errorf("queries = %q, want [%%paypal%%]", search.queries)
+	}
+
+	got := certs.stored["a4"]
+	if got.Source != model.SourceCrtSh || got.KeywordID != 1 || got.MatchedField != model.MatchFieldCN {
+		t.Errorf("stored = %+v", got)
+	}
+	if got.Issuer != "R3" || got.IssuerClass != model.IssuerFreeAutomated {
+		t.Errorf("issuer = %q/%q, want R3/free_automated", got.Issuer, got.IssuerClass)
+	}
+	if got.RegistrableDomain != "secure-paypal.co.uk" || !got.FirstSeenAt.Equal(since.Add(48*time.Hour)) {
+		t.Errorf("registrable/first seen = %q/%v", got.RegistrableDomain, got.FirstSeenAt)
+	}
+	if got.CTLogIndex != 0 || !got.IsServerAuth || got.ChainStatus != model.ChainNotChecked {
+		t.Errorf("log index/server auth/chain = %d/%v/%q", got.CTLogIndex, got.IsServerAuth, got.ChainStatus)
+	}
+}
+
+func TestImport_CountsDuplicates(t *testing.T) {
+	logged := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	search := &mockSearcher{certs: []enrich.CrtShCert{crtCert("b1", "paypal.example", logged)}}
+	certs := &mockCerts{stored: map[string]model.MatchedCertificate{"b1": {}}}
+	im := New(search, keywords(), certs, WithMinInterval(0))
+	defer im.Close()
+
+	started, err := im.Start(context.Background(), 1, time.Time{}, 0)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	j := wait(t, im, started.ID)
+	if j.Matched != 1 || j.Imported != 0 || j.Duplicates != 1 {
+		t.Errorf("progress = %d/%d/%d, want 1 matched, 1 duplicate", j.Matched, j.Imported, j.Duplicates)
+	}
+}
+
+func TestImport_StopsAtMaxRows(t *testing.T) {
+	logged := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	var list []enrich.CrtShCert
+	for _, s := range []string{"c1", "c2", "c3", "c4", "c5"} {
+		list = append(list, crtCert(s, s+".paypal.example", logged))
+	}
+	certs := &mockCerts{}
+	im := New(&mockSearcher{certs: list}, keywords(), certs, WithMinInterval(0), WithMaxRows(3))
+	defer im.Close()
+
+	// Asking for more than the configured cap gets the cap.
+	started, err := im.Start(context.Background(), 1, time.Time{}, 50)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if started.MaxRows != 3 {
+		t.Errorf("MaxRows = %d, want 3", started.MaxRows)
+	}
+	j := wait(t, im, started.ID)
+	if j.Status != model.ImportCompleted || j.Matched != 3 || len(certs.stored) != 3 {
+		t.Errorf("job = %+v, stored %d; want completed after 3", j, len(certs.stored))
+	}
+
+	started, err = im.Start(context.Background(), 1, time.Time{}, 1)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if j := wait(t, im, started.ID); j.Matched != 1 {
+		t.Errorf("Matched = %d, want 1", j.Matched)
+	}
+}
+
+func TestImport_Failures(t *testing.T) {
+	logged := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		search *mockSearcher
+		certs  *mockCerts
+		want   string
+	}{
+		{"search", &mockSearcher{err: errors.New("crt.sh returned status 502")}, &mockCerts{}, "crt.sh returned status 502"},
+		{"store", &mockSearcher{certs: []enrich.CrtShCert{crtCert("d1", "paypal.example", logged)}},
+			&mockCerts{err: errors.New("db down")}, "db down"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			im := New(tt.search, keywords(), tt.certs, WithMinInterval(0))
+			defer im.Close()
+			started, err := im.Start(context.Background(), 1, time.Time{}, 0)
+			if err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+			j := wait(t, im, started.ID)
+			if j.Status != model.ImportFailed || j.Error != tt.want {
+				t.Errorf("job = %s %q, want failed %q", j.Status, j.Error, tt.want)
+			}
+		})
+	}
+}
+
+func TestStart_Errors(t *testing.T) {
+	im := New(&mockSearcher{block: true}, keywords(), &mockCerts{}, WithMinInterval(0))
+	defer im.Close()
+
+	if _, err := im.Start(context.Background(), 99, time.Time{}, 0); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("unknown keyword: err = %v, want ErrNotFound", err)
+	}
+	if _, err := im.Start(context.Background(), 1, time.Time{}, 0); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if _, err := im.Start(context.Background(), 1, time.Time{}, 0); !errors.Is(err, ErrJobRunning) {
+		t.Errorf("second import: err = %v, want ErrJobRunning", err)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	logged := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	im := New(&mockSearcher{block: true}, keywords(), &mockCerts{}, WithMinInterval(0))
+	defer im.Close()
+
+	started, err := im.Start(context.Background(), 1, logged, 0)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if _, err := im.Cancel(started.ID); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	j := wait(t, im, started.ID)
+	if j.Status != model.ImportCanceled || j.Error != "" {
+		t.Errorf("job = %s %q, want canceled without error", j.Status, j.Error)
+	}
+
+	if _, err := im.Cancel(started.ID); !errors.Is(err, ErrJobFinished) {
+		t.Errorf("second Cancel: err = %v, want ErrJobFinished", err)
+	}
+	if _, err := im.Cancel(12345); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("unknown job: err = %v, want ErrJobNotFound", err)
+	}
+	// The keyword can be imported again once the job is over.
+	if _, err := im.Start(context.Background(), 1, logged, 0); err != nil {
+		t.Errorf("Start after cancel: %v", err)
+	}
+}
+
+func TestClose_CancelsJobs(t *testing.T) {
+	im := New(&mockSearcher{block: true}, keywords(), &mockCerts{}, WithMinInterval(0))
+	started, err := im.Start(context.Background(), 1, time.Time{}, 0)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	im.Close()
+
+	j, err := im.Get(started.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.Status != model.ImportCanceled || j.Error != "server shut down" {
+		t.Errorf("job = %s %q, want canceled by shutdown", j.Status, j.Error)
+	}
+	if _, err := im.Start(context.Background(), 1, time.Time{}, 0); !errors.Is(err, ErrClosed) {
+		t.Errorf("Start after Close: err = %v, want ErrClosed", err)
+	}
+}
+
+func TestSearchGated_SpacesRequests(t *testing.T) {
+	search := &mockSearcher{}
+	im := New(search, keywords(), &mockCerts{}, WithMinInterval(50*time.Millisecond))
+	defer im.Close()
+
+	start := time.Now()
+	for range 3 {
+		if err := im.searchGated(context.Background(), "%x%", func(enrich.CrtShCert) error { return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("3 searches took %v, want at least 2 intervals", elapsed)
+	}
+}
+
+func TestSearchGated_RateLimitDelaysNext(t *testing.T) {
+	search := &mockSearcher{err: &enrich.RateLimitError{RetryAfter: time.Hour}}
+	im := New(search, keywords(), &mockCerts{}, WithMinInterval(0))
+	defer im.Close()
+
+	if err := im.searchGated(context.Background(), "%x%", func(enrich.CrtShCert) error { return nil }); err == nil {
+		t.Fatal("want the rate limit error")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := im.searchGated(ctx, "%x%", func(enrich.CrtShCert) error { return nil }); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want to wait out Retry-After until the deadline", err)
+	}
+	if len(search.queries) != 1 {
+		t.Errorf("queries = %d, want 1", len(search.queries))
+	}
+}
+
+func TestList_NewestFirstAndPruned(t *testing.T) {
+	im := New(&mockSearcher{}, keywords(), &mockCerts{}, WithMinInterval(0))
+	defer im.Close()
+
+	var last int
+	for range maxFinishedJobs + 5 {
+		j, err := im.Start(context.Background(), 1, time.Time{}, 0)
+		if err != nil {
+			t.Fatalf("Start: %v", err)
+		}
+		wait(t, im, j.ID)
+		last = j.ID
+	}
+	jobs := im.List()
+	if len(jobs) > maxFinishedJobs+1 {
+		t.Errorf("kept %d jobs, want at most %d", len(jobs), maxFinishedJobs+1)
+	}
+	if jobs[0].ID != last {
+		t.Errorf("first job = %d, want the newest (%d)", jobs[0].ID, last)
+	}
+}
+
+func TestIssuerCommonName(t *testing.T) {
+	tests := []struct{ dn, want string }{
+		{"C=US, O=Let's Encrypt, CN=R3", "R3"},
+		{`C=US, O="DigiCert, Inc.", CN=DigiCert TLS RSA SHA256 2020 CA1`, "DigiCert TLS RSA SHA256 2020 CA1"},
+		{`C=US, O="DigiCert, Inc."`, "DigiCert, Inc."},
+		{"weird", "weird"},
+	}
+	for _, tt := range tests {
+		if got := issuerCommonName(tt.dn); got != tt.want {
+			t.Errorf("issuerCommonName(%q) = %q, want %q", tt.dn, got, tt.want)
+		}
+	}
+}
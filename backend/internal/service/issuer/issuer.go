@@ -0,0 +1,135 @@
+// Package issuer classifies certificate issuers by how their certificates
+// are obtained: free automated issuance (ACME and hosting-bundled DV), paid
+// commercial CAs, and CAs that only issue for their operator's own
+// platform. Phishing overwhelmingly uses free issuance, so analysts weigh
+// matches by it.
+package issuer
+
+import (
+	"strings"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+// rule classifies the issuers of one CA family. Issuer names are the
+// intermediate's CN (or O when there is no CN), as ctlog.ParsedCertificate
+// reports them; names match exactly and prefixes match the start of the
+// name, both ignoring case.
+type rule struct {
+	ca       string
+	class    string
+	names    []string
+	prefixes []string
+}
+
+// rules is the classification table; the first rule that matches wins.
+// Add a CA family by appending a rule.
+var rules = []rule{
+	{
+		ca:    "Let's Encrypt",
+		class: model.IssuerFreeAutomated,
+		names: []string{
+			"R3", "R4", "R10", "R11", "R12", "R13", "R14",
+			"E1", "E2", "E5", "E6", "E7", "E8", "E9",
+		},
+		prefixes: []string{"Let's Encrypt"},
+	},
+	{
+		ca:       "ZeroSSL",
+		class:    model.IssuerFreeAutomated,
+		prefixes: []string{"ZeroSSL"},
+	},
+	{
+		ca:    "Google Trust Services",
+		class: model.IssuerFreeAutomated,
+		names: []string{
+			"WR1", "WR2", "WR3", "WR4", "WR5",
+			"WE1", "WE2", "WE3", "WE4", "WE5",
+		},
+		prefixes: []string{"GTS CA ", "Google Trust Services"},
+	},
+	{
+		ca:       "cPanel AutoSSL",
+		class:    model.IssuerFreeAutomated,
+		prefixes: []string{"cPanel"},
+	},
+	{
+		// DigiCert's DV intermediate bundled free by hosting providers.
+		ca:       "Encryption Everywhere",
+		class:    model.IssuerFreeAutomated,
+		prefixes: []string{"Encryption Everywhere"},
+	},
+	{
+		ca:       "Cloudflare",
+		class:    model.IssuerFreeAutomated,
+		prefixes: []string{"Cloudflare"},
+	},
+	{
+		ca:       "DigiCert",
+		class:    model.IssuerPaid,
+		prefixes: []string{"DigiCert", "GeoTrust", "Thawte", "RapidSSL"},
+	},
+	{
+		ca:       "Sectigo",
+		class:    model.IssuerPaid,
+		prefixes: []string{"Sectigo", "COMODO", "USERTrust"},
+	},
+	{
+		ca:       "GoDaddy",
+		class:    model.IssuerPaid,
+		prefixes: []string{"Go Daddy", "Starfield"},
+	},
+	{
+		ca:       "GlobalSign",
+		class:    model.IssuerPaid,
+		prefixes: []string{"GlobalSign", "AlphaSSL"},
+	},
+	{
+		ca:       "Entrust",
+		class:    model.IssuerPaid,
+		prefixes: []string{"Entrust"},
+	},
+	{
+		ca:       "Other commercial CAs",
+		class:    model.IssuerPaid,
+		prefixes: []string{"Certum", "SSL.com", "GoGetSSL", "TrustAsia", "HARICA", "Actalis", "Buypass"},
+	},
+	{
+		ca:       "Amazon",
+		class:    model.IssuerEnterprise,
+		prefixes: []string{"Amazon"},
+	},
+	{
+		ca:       "Microsoft",
+		class:    model.IssuerEnterprise,
+		prefixes: []string{"Microsoft"},
+	},
+	{
+		ca:       "Apple",
+		class:    model.IssuerEnterprise,
+		prefixes: []string{"Apple"},
+	},
+}
+
+// Classify returns the issuer class of the named issuer, or
+// model.IssuerUnknown when no rule covers it.
+func Classify(name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return model.IssuerUnknown
+	}
+	lower := strings.ToLower(name)
+	for _, r := range rules {
+		for _, n := range r.names {
+			if strings.EqualFold(name, n) {
+				return r.class
+			}
+		}
+		for _, p := range r.prefixes {
+			if strings.HasPrefix(lower, strings.ToLower(p)) {
+				return r.class
+			}
+		}
+	}
+	return model.IssuerUnknown
+}
@@ -0,0 +1,68 @@
+package issuer
+
+import (
+	"testing"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		// Let's Encrypt intermediates, current and retired.
+		{"R3", model.IssuerFreeAutomated},
+		{"R10", model.IssuerFreeAutomated},
+		{"R11", model.IssuerFreeAutomated},
+		{"E5", model.IssuerFreeAutomated},
+		{"e6", model.IssuerFreeAutomated},
+		{"Let's Encrypt", model.IssuerFreeAutomated},
+		{"ZeroSSL RSA Domain Secure Site CA", model.IssuerFreeAutomated},
+		{"ZeroSSL ECC Domain Secure Site CA", model.IssuerFreeAutomated},
+		{"WR1", model.IssuerFreeAutomated},
+		{"WE1", model.IssuerFreeAutomated},
+		{"GTS CA 1P5", model.IssuerFreeAutomated},
+		{"cPanel, Inc. Certification Authority", model.IssuerFreeAutomated},
+		{"Encryption Everywhere DV TLS CA - G2", model.IssuerFreeAutomated},
+		{"Cloudflare Inc ECC CA-3", model.IssuerFreeAutomated},
+
+		{"DigiCert Global G2 TLS RSA SHA256 2020 CA1", model.IssuerPaid},
+		{"GeoTrust TLS RSA CA G1", model.IssuerPaid},
+		{"RapidSSL TLS RSA CA G1", model.IssuerPaid},
+		{"Thawte TLS RSA CA G1", model.IssuerPaid},
+		{"Sectigo RSA Domain Validation Secure Server CA", model.IssuerPaid},
+		{"COMODO RSA Domain Validation Secure Server CA", model.IssuerPaid},
+		{"Go Daddy Secure Certificate Authority - G2", model.IssuerPaid},
+		{"Starfield Secure Certificate Authority - G2", model.IssuerPaid},
+		{"GlobalSign GCC R3 DV TLS CA 2020", model.IssuerPaid},
+		{"AlphaSSL CA - SHA256 - G4", model.IssuerPaid},
+		{"Entrust Certification Authority - L1K", model.IssuerPaid},
+		{"Certum Domain Validation CA SHA2", model.IssuerPaid},
+
+		{"Amazon RSA 2048 M01", model.IssuerEnterprise},
+		{"Microsoft Azure RSA TLS Issuing CA 03", model.IssuerEnterprise},
+		{"Apple Public Server RSA CA 12 - G1", model.IssuerEnterprise},
+
+		{"", model.IssuerUnknown},
+		{"R", model.IssuerUnknown},
+		{"R100", model.IssuerUnknown},
+		{"Some Private Root CA", model.IssuerUnknown},
+	}
+	for _, tt := range tests {
+		if got := Classify(tt.name); got != tt.want {
+			t.Errorf("Classify(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRules_UseKnownClasses(t *testing.T) {
+	for _, r := range rules {
+		if !model.ValidIssuerClass(r.class) || r.class == model.IssuerUnknown {
+			t.Errorf("rule %q has class %q", r.ca, r.class)
+		}
+		if len(r.names) == 0 && len(r.prefixes) == 0 {
+			t.Errorf("rule %q matches nothing", r.ca)
+		}
+	}
+}
@@ -7,40 +7,122 @@ import (
 	"github.com/andres10976/SISAP-PoC/backend/internal/service/ctlog"
 )
 
+// Contains reports whether domain contains keyword under mode (one of
+// the model.MatchMode values; empty means substring). Both are compared
+// case-insensitively.
+func Contains(domain, keyword, mode string) bool {
+	domain, keyword = strings.ToLower(domain), strings.ToLower(keyword)
+	if mode == model.MatchModeBoundary {
+		return containsAtBoundary(domain, keyword)
+	}
+	return strings.Contains(domain, keyword)
+}
+
+// containsAtBoundary reports whether some occurrence of sub in s starts or
+// ends at a domain boundary: the start or end of s, a '.' or a '-'. With
+// "paypal", "paypal-login.com" and "secure-paypal.com" match but
+// "oldpaypalx.net" does not.
+func containsAtBoundary(s, sub string) bool {
+	if sub == "" {
+		return false
+	}
+	for from := 0; ; {
+		i := strings.Index(s[from:], sub)
+		if i < 0 {
+			return false
+		}
+		start, end := from+i, from+i+len(sub)
+		if isBoundary(s, start-1) || isBoundary(s, end) {
+			return true
+		}
+		from = start + 1
+	}
+}
+
+// isBoundary reports whether position i of s is outside s or a separator.
+func isBoundary(s string, i int) bool {
+	return i < 0 || i >= len(s) || s[i] == '.' || s[i] == '-'
+}
+
 // MatchResult pairs a keyword ID with the domain that triggered the match.
+// MatchedField records where that domain came from (model.MatchFieldCN or
+// model.MatchFieldSAN) and MatchedValue which of the keyword's values
+// (model.Keyword.Values) it contains, the first in order when several do.
+// MatchedDomains lists every CN/SAN containing any of the values, CN
+// first, with case-insensitive duplicates removed; its first element is
+// MatchedDomain.
 type MatchResult struct {
-	KeywordID     int
-	MatchedDomain string
+	KeywordID      int
+	MatchedDomain  string
+	MatchedField   string
+	MatchedValue   string
+	MatchedDomains []string
 }
 
-// Match checks a parsed certificate against all keywords.
-// Returns one match per keyword (first matching domain wins).
+// Options adjusts MatchWith.
+type Options struct {
+	// IgnoreCN matches against SANs only, so a keyword found only in the
+	// CommonName does not match.
+	IgnoreCN bool
+}
+
+// Match checks a parsed certificate against all keywords, each under its
+// own MatchMode. A keyword with alternatives matches when any of its
+// values does. Returns one match per keyword; the CN wins over SANs, then
+// the first SAN.
 func Match(cert *ctlog.ParsedCertificate, keywords []model.Keyword) []MatchResult {
+	return MatchWith(cert, keywords, Options{})
+}
+
+// MatchWith is Match adjusted by opts.
+func MatchWith(cert *ctlog.ParsedCertificate, keywords []model.Keyword, opts Options) []MatchResult {
 	var results []MatchResult
 
 	for _, kw := range keywords {
-		lower := strings.ToLower(kw.Value)
-
-		// Check Common Name first
-		if cert.CommonName != "" && strings.Contains(strings.ToLower(cert.CommonName), lower) {
-			results = append(results, MatchResult{
-				KeywordID:     kw.ID,
-				MatchedDomain: cert.CommonName,
-			})
-			continue
+		var result MatchResult
+		seen := make(map[string]bool)
+		add := func(domain, field string) {
+			key := strings.ToLower(domain)
+			if seen[key] {
+				return
+			}
+			value := firstContained(key, kw)
+			if value == "" {
+				return
+			}
+			seen[key] = true
+			if result.MatchedDomain == "" {
+				result = MatchResult{KeywordID: kw.ID, MatchedDomain: domain, MatchedField: field, MatchedValue: value}
+			}
+			result.MatchedDomains = append(result.MatchedDomains, domain)
 		}
 
-		// Check each SAN
+		if cert.CommonName != "" && !opts.IgnoreCN {
+			add(cert.CommonName, model.MatchFieldCN)
+		}
 		for _, san := range cert.SANs {
-			if strings.Contains(strings.ToLower(san), lower) {
-				results = append(results, MatchResult{
-					KeywordID:     kw.ID,
-					MatchedDomain: san,
-				})
-				break
-			}
+			add(san, model.MatchFieldSAN)
+		}
+
+		if result.MatchedDomain != "" {
+			results = append(results, result)
 		}
 	}
 
 	return results
 }
+
+// firstContained returns the first of kw.Values() that domain contains
+// under kw.MatchMode, or "" when it contains none. It walks Value and
+// Alternatives in place so the hot path does not allocate.
+func firstContained(domain string, kw model.Keyword) string {
+	if Contains(domain, kw.Value, kw.MatchMode) {
+		return kw.Value
+	}
+	for _, v := range kw.Alternatives {
+		if Contains(domain, v, kw.MatchMode) {
+			return v
+		}
+	}
+	return ""
+}
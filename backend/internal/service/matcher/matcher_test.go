@@ -1,8 +1,11 @@
 package matcher
 
 import (
+	"fmt"
+	"slices"
 	"testing"
 
+	"github.com/andres10976/SISAP-PoC/backend/internal/benchcorpus"
 	"github.com/andres10976/SISAP-PoC/backend/internal/model"
 	"github.com/andres10976/SISAP-PoC/backend/internal/service/ctlog"
 )
@@ -43,6 +46,9 @@ func TestMatch_CNMatch(t *testing.T) {
 	if results[0].MatchedDomain != "example.com" {
 		t.Errorf("MatchedDomain = %q, want %q", results[0].MatchedDomain, "example.com")
 	}
+	if results[0].MatchedField != model.MatchFieldCN {
+		t.Errorf("MatchedField = %q, want %q", results[0].MatchedField, model.MatchFieldCN)
+	}
 }
 
 func TestMatch_SANMatch(t *testing.T) {
@@ -53,6 +59,9 @@ func TestMatch_SANMatch(t *testing.T) {
 	if results[0].MatchedDomain != "www.example.com" {
 		t.Errorf("MatchedDomain = %q, want %q", results[0].MatchedDomain, "www.example.com")
 	}
+	if results[0].MatchedField != model.MatchFieldSAN {
+		t.Errorf("MatchedField = %q, want %q", results[0].MatchedField, model.MatchFieldSAN)
+	}
 }
 
 func TestMatch_CaseInsensitive(t *testing.T) {
@@ -121,3 +130,214 @@ func TestMatch_EmptySANs(t *testing.T) {
 		t.Errorf("got %d results, want 0", len(results))
 	}
 }
+
+func TestMatch_AllMatchedDomains(t *testing.T) {
+	results := Match(cert("other.com", "www.example.com", "static.other.com", "api.example.com"),
+		[]model.Keyword{kw(1, "example")})
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+	want := []string{"www.example.com", "api.example.com"}
+	if !slices.Equal(results[0].MatchedDomains, want) {
+		t.Errorf("MatchedDomains = %q, want %q", results[0].MatchedDomains, want)
+	}
+}
+
+func TestMatch_CNDuplicatedInSANsCountsOnce(t *testing.T) {
+	results := Match(cert("Example.com", "example.com", "EXAMPLE.COM"), []model.Keyword{kw(1, "example")})
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+	if results[0].MatchedField != model.MatchFieldCN {
+		t.Errorf("MatchedField = %q, want %q", results[0].MatchedField, model.MatchFieldCN)
+	}
+	if want := []string{"Example.com"}; !slices.Equal(results[0].MatchedDomains, want) {
+		t.Errorf("MatchedDomains = %q, want %q", results[0].MatchedDomains, want)
+	}
+}
+
+func TestMatchWith_IgnoreCN(t *testing.T) {
+	opts := Options{IgnoreCN: true}
+
+	if results := MatchWith(cert("login.example.com", "cdn.other.net"), []model.Keyword{kw(1, "example")}, opts); len(results) != 0 {
+		t.Errorf("CN-only match: got %+v, want it suppressed", results)
+	}
+
+	results := MatchWith(cert("example.com", "example.com", "www.example.com"), []model.Keyword{kw(1, "example")}, opts)
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+	if results[0].MatchedField != model.MatchFieldSAN {
+		t.Errorf("MatchedField = %q, want %q", results[0].MatchedField, model.MatchFieldSAN)
+	}
+	if want := []string{"example.com", "www.example.com"}; !slices.Equal(results[0].MatchedDomains, want) {
+		t.Errorf("MatchedDomains = %q, want %q", results[0].MatchedDomains, want)
+	}
+}
+
+func TestContains_Boundary(t *testing.T) {
+	tests := []struct {
+		domain string
+		want   bool
+	}{
+		{"paypal-login.com", true},
+		{"secure-paypal.com", true},
+		{"paypal.com", true},
+		{"www.paypal.com", true},
+		{"login.secure-paypal", true},
+		{"PayPal-Login.com", true},
+		{"oldpaypalx.net", false},
+		{"mypaypalsite.com", false},
+		// A mid-token first occurrence must not hide a later anchored one.
+		{"xpaypalx.paypal-help.org", true},
+	}
+	for _, tt := range tests {
+		if got := Contains(tt.domain, "paypal", model.MatchModeBoundary); got != tt.want {
+			t.Errorf("Contains(%q, boundary) = %v, want %v", tt.domain, got, tt.want)
+		}
+	}
+}
+
+func TestContains_SubstringIsDefault(t *testing.T) {
+	for _, mode := range []string{"", model.MatchModeSubstring} {
+		if !Contains("oldpaypalx.net", "paypal", mode) {
+			t.Errorf("Contains(oldpaypalx.net, %q) = false, want true", mode)
+		}
+	}
+}
+
+func TestMatch_BoundaryMode(t *testing.T) {
+	results := Match(
+		cert("oldpaypalx.net", "mypaypalsite.com", "secure-paypal.com"),
+		[]model.Keyword{{ID: 1, Value: "paypal", MatchMode: model.MatchModeBoundary}},
+	)
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+	if results[0].MatchedDomain != "secure-paypal.com" || results[0].MatchedField != model.MatchFieldSAN {
+		t.Errorf("matched %q (%s), want SAN secure-paypal.com", results[0].MatchedDomain, results[0].MatchedField)
+	}
+	if !slices.Equal(results[0].MatchedDomains, []string{"secure-paypal.com"}) {
+		t.Errorf("MatchedDomains = %v, want only secure-paypal.com", results[0].MatchedDomains)
+	}
+}
+
+func TestMatch_Alternatives(t *testing.T) {
+	paypal := model.Keyword{ID: 1, Value: "paypal", Alternatives: []string{"paypa1", "pypl"}}
+
+	tests := []struct {
+		name        string
+		cert        *ctlog.ParsedCertificate
+		wantDomain  string
+		wantValue   string
+		wantDomains []string
+	}{
+		{
+			name:        "value",
+			cert:        cert("paypal-login.com"),
+			wantDomain:  "paypal-login.com",
+			wantValue:   "paypal",
+			wantDomains: []string{"paypal-login.com"},
+		},
+		{
+			name:        "alternative",
+			cert:        cert("other.com", "secure-paypa1.com"),
+			wantDomain:  "secure-paypa1.com",
+			wantValue:   "paypa1",
+			wantDomains: []string{"secure-paypa1.com"},
+		},
+		{
+			name:        "several alternatives are one match",
+			cert:        cert("pypl-help.net", "paypa1.com", "www.paypal.com"),
+			wantDomain:  "pypl-help.net",
+			wantValue:   "pypl",
+			wantDomains: []string{"pypl-help.net", "paypa1.com", "www.paypal.com"},
+		},
+		{
+			name:        "value wins over an alternative in the same domain",
+			cert:        cert("paypal-pypl.com"),
+			wantDomain:  "paypal-pypl.com",
+			wantValue:   "paypal",
+			wantDomains: []string{"paypal-pypl.com"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := Match(tt.cert, []model.Keyword{paypal})
+			if len(results) != 1 {
+				t.Fatalf("got %d results, want 1", len(results))
+			}
+			r := results[0]
+			if r.KeywordID != 1 || r.MatchedDomain != tt.wantDomain || r.MatchedValue != tt.wantValue {
+				t.Errorf("got keyword %d, %q via %q; want 1, %q via %q",
+					r.KeywordID, r.MatchedDomain, r.MatchedValue, tt.wantDomain, tt.wantValue)
+			}
+			if !slices.Equal(r.MatchedDomains, tt.wantDomains) {
+				t.Errorf("MatchedDomains = %q, want %q", r.MatchedDomains, tt.wantDomains)
+			}
+		})
+	}
+
+	if results := Match(cert("example.com", "www.example.com"), []model.Keyword{paypal}); len(results) != 0 {
+		t.Errorf("no value present: got %+v, want no match", results)
+	}
+}
+
+func TestMatch_AlternativesUseMatchMode(t *testing.T) {
+	kw := model.Keyword{ID: 1, Value: "paypal", Alternatives: []string{"pypl"}, MatchMode: model.MatchModeBoundary}
+
+	if results := Match(cert("oldpypl1.net"), []model.Keyword{kw}); len(results) != 0 {
+		t.Errorf("mid-token alternative: got %+v, want no match under boundary", results)
+	}
+	results := Match(cert("oldpypl1.net", "pypl-login.com"), []model.Keyword{kw})
+	if len(results) != 1 || results[0].MatchedDomain != "pypl-login.com" || results[0].MatchedValue != "pypl" {
+		t.Errorf("got %+v, want pypl-login.com via pypl", results)
+	}
+}
+
+// benchCerts is the size of the certificate corpus the benchmarks cycle
+// through.
+const benchCerts = 1000
+
+// BenchmarkMatch matches one certificate per op against k keywords of the
+// given mode (mixed: k of each). Compare runs with benchstat:
+//
+//	go test -run '^$' -bench Match -benchmem -count 10 ./internal/service/matcher
+func BenchmarkMatch(b *testing.B) {
+	for _, k := range []int{10, 100} {
+		all := benchcorpus.Keywords(k)
+		certs := make([]*ctlog.ParsedCertificate, benchCerts)
+		for i, names := range benchcorpus.Names(benchCerts, all) {
+			certs[i] = &ctlog.ParsedCertificate{CommonName: names[0], SANs: names}
+		}
+		for _, mode := range []string{model.MatchModeSubstring, model.MatchModeBoundary, "mixed"} {
+			keywords := all
+			switch mode {
+			case model.MatchModeSubstring:
+				keywords = all[:k]
+			case model.MatchModeBoundary:
+				keywords = all[k:]
+			}
+			b.Run(fmt.Sprintf("mode=%s/keywords=%d", mode, len(keywords)), func(b *testing.B) {
+				b.ReportAllocs()
+				i := 0
+				for b.Loop() {
+					Match(certs[i%len(certs)], keywords)
+					i++
+				}
+				b.ReportMetric(float64(i)/b.Elapsed().Seconds(), "certs/s")
+			})
+		}
+	}
+}
+
+func BenchmarkContains(b *testing.B) {
+	for _, mode := range []string{model.MatchModeSubstring, model.MatchModeBoundary} {
+		b.Run("mode="+mode, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				Contains("Secure-PayPal-Login.Example.com", "paypal", mode)
+			}
+		})
+	}
+}
@@ -5,21 +5,43 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"math/rand/v2"
 	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/logging"
 	"github.com/andres10976/SISAP-PoC/backend/internal/model"
 	"github.com/andres10976/SISAP-PoC/backend/internal/service/ctlog"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/domain"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/issuer"
 	"github.com/andres10976/SISAP-PoC/backend/internal/service/matcher"
 )
 
+// tracerName identifies the monitor's spans.
+const tracerName = "github.com/andres10976/SISAP-PoC/backend/internal/service/monitor"
+
 var (
-	ErrAlreadyRunning = errors.New("monitor already running")
-	ErrNotRunning     = errors.New("monitor not running")
+	ErrAlreadyRunning   = errors.New("monitor already running")
+	ErrNotRunning       = errors.New("monitor not running")
+	ErrNoKeywords       = errors.New("no keywords to match (none configured or all muted, disabled or outside their window)")
+	ErrBeyondTree       = errors.New("range extends beyond the log's tree size")
+	ErrReprocessRunning = errors.New("a reprocess is already running")
+	// ErrLogSwitchDisabled is returned by SwitchLog without WithLogs;
+	// dialers report ErrInvalidLogURL and ErrLogNotAllowed.
+	ErrLogSwitchDisabled = errors.New("switching CT logs is not configured")
+	ErrInvalidLogURL     = errors.New("invalid CT log URL")
+	ErrLogNotAllowed     = errors.New("CT log host is not allowed")
 )
 
-type ctClient interface {
+// LogClient reads a CT log; *ctlog.Client is one.
+type LogClient interface {
 	GetSTH(ctx context.Context) (*ctlog.STH, error)
 	GetEntries(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error)
 }
@@ -37,10 +59,12 @@ type stateStore interface {
 	Update(ctx context.Context, state *model.MonitorState) error
 	SetRunning(ctx context.Context, running bool) error
 	SetError(ctx context.Context, errMsg string) error
+	SetLogFrozen(ctx context.Context, since *time.Time) error
+	SwitchLog(ctx context.Context, url, configuredURL string, index, treeSize int64) error
 }
 
 type Monitor struct {
-	ctClient  ctClient
+	ctClient  LogClient
 	keywords  keywordLister
 	certs     certCreator
 	state     stateStore
@@ -52,28 +76,304 @@ type Monitor struct {
 	// true: re-fetch and re-process the last batch (useful for testing/demo)
 	reprocessOnIdle bool
 
+	// startJitter is the upper bound of a random delay applied before the
+	// first batch, so instances started together don't hit the log at once.
+	startJitter time.Duration
+	jitterFn    func(max time.Duration) time.Duration
+
 	mu     sync.Mutex
 	cancel context.CancelFunc
+	// quit asks the loop to exit after the current batch; exited is closed
+	// once it has. stopping is set while Stop waits for that.
+	quit     chan struct{}
+	exited   chan struct{}
+	stopping bool
+	// paused keeps the loop alive but skips batch work; it is cleared by
+	// Start and Stop.
+	paused bool
+
+	metrics    MetricsHook
+	publishers []MatchPublisher
+
+	// effectiveBatch is the batch size actually requested; it starts at
+	// batchSize and is lowered when the log keeps serving fewer entries per
+	// get-entries call. shortReads counts consecutive reads of shortLen
+	// entries. Only the processing loop touches these.
+	effectiveBatch int
+	shortReads     int
+	shortLen       int
+
+	// setErrorFailures counts consecutive failed SetError calls; only the
+	// processing loop touches it.
+	setErrorFailures int
+
+	// maxMatchesPerCert caps the matches stored for one certificate; zero
+	// means unlimited.
+	maxMatchesPerCert int
+
+	// headLag keeps processing that many entries behind the tree head.
+	headLag int64
+
+	// serverAuthOnly skips certificates that cannot be used by a TLS
+	// server (client-auth, code-signing, ...).
+	serverAuthOnly bool
+
+	// minNotBefore skips certificates issued before it; zero disables the
+	// filter.
+	minNotBefore time.Time
+
+	// chains sets ChainStatus on matches; nil leaves them not_checked.
+	chains ChainVerifier
+
+	// ignoreCN matches keywords against SANs only.
+	ignoreCN bool
+
+	// logMatches logs every newly stored match.
+	logMatches bool
+
+	// maxCycles stops the loop after that many batches; zero means run
+	// until stopped. done is closed when the limit is reached.
+	maxCycles int
+	done      chan struct{}
+	doneOnce  sync.Once
+
+	// tracer records a span per cycle with children for the CT log calls,
+	// parsing and matching.
+	tracer trace.Tracer
+
+	// reprocessing lets one Reprocess run at a time.
+	reprocessing sync.Mutex
+
+	// disabler, when set, disables keywords that matched more than
+	// maxMatchPercent of a cycle's entries for runawayCycles cycles in a
+	// row. runaway counts each keyword's current streak; only the
+	// processing loop touches it.
+	disabler        KeywordDisabler
+	maxMatchPercent int
+	runawayCycles   int
+	runaway         map[int]int
+
+	// cycled is set once a batch has completed without failing or losing a
+	// state write.
+	cycled atomic.Bool
+
+	// logMu guards ctClient and logURL, which a switch replaces while
+	// Reprocess may be reading the log. configuredURL is CT_LOG_URL and
+	// dial opens another log (WithLogs).
+	logMu         sync.RWMutex
+	logURL        string
+	configuredURL string
+	dial          func(url string) (LogClient, error)
+
+	// cursorMu keeps SwitchLog from moving the cursor while a batch
+	// advances it, and guards the rollover state below.
+	cursorMu sync.Mutex
+
+	// intervalEnd, frozenAfter, successorURL and switchRecorder configure
+	// rollover (WithRollover). treeSize and treeChangedAt are the last tree
+	// size seen and when it changed; frozenSince is set while the log is
+	// considered frozen.
+	intervalEnd    time.Time
+	frozenAfter    time.Duration
+	successorURL   string
+	switchRecorder LogSwitchRecorder
+	treeSize       int64
+	treeChangedAt  time.Time
+	frozenSince    *time.Time
+}
+
+// CycleStats summarizes one processing cycle for a MetricsHook.
+type CycleStats struct {
+	Duration    time.Duration
+	Entries     int
+	Matches     int
+	ParseErrors int
+	// DroppedMatches counts matches discarded by the per-certificate cap.
+	DroppedMatches int
+	// SkippedNotBefore counts certificates skipped because their NotBefore
+	// is earlier than the WithMinNotBefore cutoff.
+	SkippedNotBefore int
+	// CNLessMatches counts the Matches whose certificate has no CommonName.
+	CNLessMatches int
+	// Backlog is the number of log entries still unprocessed after the
+	// cycle, including those held back by WithHeadLag.
+	Backlog int64
+	// StateErrors counts failed attempts to save the monitor's state or to
+	// record or clear its last error; nonzero usually means the database is
+	// unreachable.
+	StateErrors int
+	// DisabledKeywords counts keywords disabled after the cycle for
+	// matching too much of the log (WithRunawayKeywords).
+	DisabledKeywords int
+	Failed           bool
+}
+
+// MetricsHook receives a summary after every processing cycle.
+type MetricsHook interface {
+	ObserveCycle(stats CycleStats)
+}
+
+// MatchPublisher is told about every newly stored match. Publish must not
+// block the processing loop.
+type MatchPublisher interface {
+	Publish(cert model.MatchedCertificate)
+}
+
+// KeywordDisabler stops a keyword from being matched, recording why.
+type KeywordDisabler interface {
+	Disable(ctx context.Context, id int, reason string) (*model.Keyword, error)
+}
+
+// Option configures optional Monitor behavior.
+type Option func(*Monitor)
+
+// WithStartJitter delays the first batch by a random duration in [0, max).
+// Zero (the default) starts processing immediately.
+func WithStartJitter(max time.Duration) Option {
+	return func(m *Monitor) {
+		m.startJitter = max
+	}
+}
+
+// WithMetrics reports every processing cycle to h.
+func WithMetrics(h MetricsHook) Option {
+	return func(m *Monitor) {
+		m.metrics = h
+	}
+}
+
+// WithPublisher sends every newly stored match to p (e.g. the live stream).
+// Matches already stored for the keyword are not republished. Repeat it to
+// add more publishers.
+func WithPublisher(p MatchPublisher) Option {
+	return func(m *Monitor) {
+		m.publishers = append(m.publishers, p)
+	}
+}
+
+// WithMaxMatchesPerCert stores at most n matches for a single certificate
+// so one certificate with many SANs and broad keywords cannot flood the
+// database. Zero (the default) stores every match.
+func WithMaxMatchesPerCert(n int) Option {
+	return func(m *Monitor) {
+		m.maxMatchesPerCert = n
+	}
+}
+
+// WithHeadLag keeps processing n entries behind the tree head, leaving the
+// newest entries, which may not have propagated yet, for a later cycle.
+// Zero (the default) processes up to the head.
+func WithHeadLag(n int64) Option {
+	return func(m *Monitor) {
+		m.headLag = n
+	}
+}
+
+// WithServerAuthOnly skips certificates whose extended key usage rules out
+// TLS server use, so client-auth and code-signing certificates never match.
+func WithServerAuthOnly(on bool) Option {
+	return func(m *Monitor) {
+		m.serverAuthOnly = on
+	}
+}
+
+// WithIgnoreCN matches keywords against SANs only, since the CN is
+// deprecated for naming hosts (matcher.Options.IgnoreCN).
+func WithIgnoreCN(on bool) Option {
+	return func(m *Monitor) {
+		m.ignoreCN = on
+	}
+}
+
+// ChainVerifier reports whether a certificate chains to a trusted root as
+// one of the model.Chain* statuses; *ctlog.Verifier implements it.
+type ChainVerifier interface {
+	Verify(cert *ctlog.ParsedCertificate) string
+}
+
+// WithChainVerifier checks the chain of every matched certificate with v
+// before storing it. Only matched certificates are verified.
+func WithChainVerifier(v ChainVerifier) Option {
+	return func(m *Monitor) {
+		m.chains = v
+	}
+}
+
+// WithMinNotBefore skips certificates whose NotBefore is earlier than t,
+// e.g. backdated or long-lived certificates re-logged to a new shard. They
+// are counted in CycleStats.SkippedNotBefore. The zero time (the default)
+// matches every certificate.
+func WithMinNotBefore(t time.Time) Option {
+	return func(m *Monitor) {
+		m.minNotBefore = t
+	}
+}
+
+// WithMaxCycles stops the monitor after n processing cycles and closes
+// Done, for deployments that run a fixed amount of work per invocation
+// (e.g. from cron). Paused ticks do not count. Zero (the default) runs
+// until Stop.
+func WithMaxCycles(n int) Option {
+	return func(m *Monitor) {
+		m.maxCycles = n
+	}
+}
+
+// WithLogMatches emits an Info "certificate matched" line for every newly
+// stored match, so log pipelines can alert without webhooks or the stream.
+func WithLogMatches(on bool) Option {
+	return func(m *Monitor) {
+		m.logMatches = on
+	}
+}
+
+// WithTracerProvider records cycle spans with tp instead of the global
+// provider.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(m *Monitor) {
+		m.tracer = tp.Tracer(tracerName)
+	}
+}
+
+// WithRunawayKeywords disables, through d, any keyword whose matches exceed
+// percent of the entries processed in each of cycles consecutive cycles,
+// so a keyword like "com" cannot fill the database. A percent of zero (the
+// default) turns the check off; backfills are not counted.
+func WithRunawayKeywords(d KeywordDisabler, percent, cycles int) Option {
+	return func(m *Monitor) {
+		m.disabler = d
+		m.maxMatchPercent = percent
+		m.runawayCycles = max(cycles, 1)
+	}
 }
 
 func New(
-	ct ctClient,
+	ct LogClient,
 	kw keywordLister,
 	cert certCreator,
 	st stateStore,
 	batchSize int,
 	interval time.Duration,
 	reprocessOnIdle bool,
+	opts ...Option,
 ) *Monitor {
-	return &Monitor{
+	m := &Monitor{
 		ctClient:        ct,
 		keywords:        kw,
 		certs:           cert,
 		state:           st,
 		batchSize:       batchSize,
+		effectiveBatch:  batchSize,
 		interval:        interval,
 		reprocessOnIdle: reprocessOnIdle,
+		jitterFn:        rand.N[time.Duration],
+		tracer:          otel.Tracer(tracerName),
+		done:            make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(m)
 	}
+	return m
 }
 
 // Start launches the background monitoring loop.
@@ -89,6 +389,7 @@ func (m *Monitor) Start(ctx context.Context) error {
 
 	monCtx, cancel := context.WithCancel(context.Background())
 	m.cancel = cancel
+	m.paused = false
 
 	if err := m.state.SetRunning(ctx, true); err != nil {
 		cancel()
@@ -96,27 +397,55 @@ func (m *Monitor) Start(ctx context.Context) error {
 		return err
 	}
 
-	go m.run(monCtx)
+	quit, exited := make(chan struct{}), make(chan struct{})
+	m.quit, m.exited = quit, exited
+	go func() {
+		defer close(exited)
+		m.run(monCtx, quit)
+	}()
 	return nil
 }
 
-// Stop halts the monitoring loop.
-// Uses a background context for the DB update so it succeeds even if
-// the HTTP request context is already canceled.
-func (m *Monitor) Stop(_ context.Context) error {
+// Stop halts the monitoring loop. A batch already in progress is allowed to
+// finish and persist its progress; if ctx ends first the batch is canceled
+// and Stop reports it. The running flag is cleared with a background
+// context so it is recorded even if ctx is already canceled.
+func (m *Monitor) Stop(ctx context.Context) error {
 	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	if m.cancel == nil {
+	if m.cancel == nil || m.stopping {
+		m.mu.Unlock()
 		return ErrNotRunning
 	}
+	m.stopping = true
+	close(m.quit)
+	cancel, exited := m.cancel, m.exited
+	m.mu.Unlock()
 
-	m.cancel()
+	var err error
+	select {
+	case <-exited:
+	case <-ctx.Done():
+		err = fmt.Errorf("batch canceled before it finished: %w", ctx.Err())
+		cancel()
+		<-exited
+	}
+	cancel()
+
+	m.mu.Lock()
 	m.cancel = nil
+	m.stopping = false
+	m.paused = false
+	m.mu.Unlock()
 
 	dbCtx, dbCancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer dbCancel()
-	return m.state.SetRunning(dbCtx, false)
+	return errors.Join(err, m.state.SetRunning(dbCtx, false))
+}
+
+// Done is closed once the monitor has stopped itself after its
+// WithMaxCycles limit. It is never closed when there is no limit.
+func (m *Monitor) Done() <-chan struct{} {
+	return m.done
 }
 
 // IsRunning returns whether the monitor loop is active.
@@ -126,7 +455,48 @@ func (m *Monitor) IsRunning() bool {
 	return m.cancel != nil
 }
 
-func (m *Monitor) run(ctx context.Context) {
+// Pause keeps the loop running (and is_running true) but skips batch work
+// on each tick until Resume. A batch already in progress completes.
+func (m *Monitor) Pause() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.cancel == nil {
+		return ErrNotRunning
+	}
+	m.paused = true
+	return nil
+}
+
+// Resume undoes Pause; processing picks up again on the next tick.
+func (m *Monitor) Resume() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.cancel == nil {
+		return ErrNotRunning
+	}
+	m.paused = false
+	return nil
+}
+
+// IsPaused returns whether batch processing is paused.
+func (m *Monitor) IsPaused() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.paused
+}
+
+// HasCompletedCycle reports whether a batch has ever completed without
+// failing or losing a state write, which proves the CT log and the database
+// both answered. It stays true through later failures and Stop.
+func (m *Monitor) HasCompletedCycle() bool {
+	return m.cycled.Load()
+}
+
+// run processes batches until ctx is canceled (abandoning a batch in
+// progress) or quit is closed (after the current batch).
+func (m *Monitor) run(ctx context.Context, quit <-chan struct{}) {
 	slog.Info("monitor goroutine started", "batch_size", m.batchSize, "interval", m.interval)
 
 	defer func() {
@@ -138,11 +508,38 @@ func (m *Monitor) run(ctx context.Context) {
 			cleanupCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 			defer cancel()
 			m.state.SetRunning(cleanupCtx, false)
-			m.state.SetError(cleanupCtx, fmt.Sprintf("panic: %v", r))
+			m.setError(cleanupCtx, nil, fmt.Sprintf("panic: %v", r))
 		}
 	}()
 
-	m.processBatch(ctx)
+	if m.startJitter > 0 {
+		delay := m.jitterFn(m.startJitter)
+		slog.Info("delaying first batch", "delay", delay)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-quit:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+
+	cycles := 0
+	// step runs one tick and reports whether the cycle limit was reached.
+	step := func() bool {
+		if m.tick(ctx) {
+			cycles++
+		}
+		return m.maxCycles > 0 && cycles >= m.maxCycles
+	}
+
+	if step() {
+		m.finish(cycles)
+		return
+	}
 
 	ticker := time.NewTicker(m.interval)
 	defer ticker.Stop()
@@ -151,65 +548,173 @@ func (m *Monitor) run(ctx context.Context) {
 		select {
 		case <-ctx.Done():
 			return
+		case <-quit:
+			return
 		case <-ticker.C:
-			m.processBatch(ctx)
+			if step() {
+				m.finish(cycles)
+				return
+			}
 		}
 	}
 }
 
-func (m *Monitor) processBatch(ctx context.Context) {
+// finish stops the monitor after its cycle limit and closes done. If the
+// loop was already stopped (Stop or shutdown raced the last cycle) there is
+// nothing to stop, but done is still closed.
+func (m *Monitor) finish(cycles int) {
+	slog.Info("monitor reached its cycle limit, stopping", "cycles", cycles)
+	// Stop would wait for this goroutine to exit, so release the loop here.
+	m.mu.Lock()
+	owned := m.cancel != nil && !m.stopping
+	if owned {
+		m.cancel()
+		m.cancel = nil
+		m.paused = false
+	}
+	m.mu.Unlock()
+	if owned {
+		dbCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := m.state.SetRunning(dbCtx, false); err != nil {
+			slog.Error("failed to record monitor stop", "error", err)
+		}
+	}
+	m.doneOnce.Do(func() { close(m.done) })
+}
+
+// tick runs one batch unless the monitor is paused, and reports whether it
+// did.
+func (m *Monitor) tick(ctx context.Context) bool {
+	if m.IsPaused() {
+		slog.Debug("monitor paused, skipping batch")
+		return false
+	}
+	// Every log line for this batch carries the same cycle_id.
+	cycleID := logging.NewID()
+	ctx = logging.WithCycleID(ctx, cycleID)
+	ctx, span := m.tracer.Start(ctx, "monitor.cycle", trace.WithAttributes(attribute.String("cycle_id", cycleID)))
+	started := time.Now()
+	stats := m.processBatch(ctx)
+	stats.Duration = time.Since(started)
+	span.SetAttributes(
+		attribute.Int("entries", stats.Entries),
+		attribute.Int("matches", stats.Matches),
+		attribute.Int("parse_errors", stats.ParseErrors),
+		attribute.Int64("backlog", stats.Backlog),
+	)
+	if stats.Failed {
+		span.SetStatus(codes.Error, "cycle failed")
+	}
+	span.End()
+	if m.metrics != nil {
+		m.metrics.ObserveCycle(stats)
+	}
+	return true
+}
+
+func (m *Monitor) processBatch(ctx context.Context) (stats CycleStats) {
+	m.cursorMu.Lock()
+	defer m.cursorMu.Unlock()
 	logger := slog.Default()
+	defer func() {
+		if !stats.Failed && stats.StateErrors == 0 {
+			m.cycled.Store(true)
+		}
+	}()
 
 	// 1. Get current Signed Tree Head
-	sth, err := m.ctClient.GetSTH(ctx)
+	sth, err := m.getSTH(ctx)
 	if err != nil {
-		logger.Error("failed to get STH", "error", err)
-		m.state.SetError(ctx, fmt.Sprintf("failed to get STH: %v", err))
+		logger.ErrorContext(ctx, "failed to get STH", "error", err)
+		stats.Failed = true
+		m.setError(ctx, &stats, fmt.Sprintf("failed to get STH: %v", err))
 		return
 	}
 
 	// 2. Load current monitor state
 	state, err := m.state.Get(ctx)
 	if err != nil {
-		logger.Error("failed to get monitor state", "error", err)
-		m.state.SetError(ctx, fmt.Sprintf("failed to get monitor state: %v", err))
+		logger.ErrorContext(ctx, "failed to get monitor state", "error", err)
+		stats.Failed = true
+		m.setError(ctx, &stats, fmt.Sprintf("failed to get monitor state: %v", err))
 		return
 	}
 
-	// 3. Calculate batch range
+	// A frozen shard is drained and then, with a successor, left for it;
+	// the next cycle reads the successor.
+	if m.watchFrozen(ctx, &stats, sth.TreeSize, state.LastProcessedIndex) {
+		return
+	}
+
+	// An empty log (brand new, or a test log) has nothing to fetch yet.
+	// Refresh last_run_at so the monitor still reports as alive.
+	if sth.TreeSize <= 0 {
+		logger.InfoContext(ctx, "CT log is empty, nothing to fetch", "tree_size", sth.TreeSize)
+		m.saveState(ctx, &stats, &model.MonitorState{
+			LastProcessedIndex:     state.LastProcessedIndex,
+			LastTreeSize:           0,
+			TotalProcessed:         state.TotalProcessed,
+			CertsInLastCycle:       0,
+			MatchesInLastCycle:     0,
+			ParseErrorsInLastCycle: 0,
+			IsRunning:              true,
+		})
+		m.setError(ctx, &stats, "")
+		return
+	}
+
+	// 3. Calculate batch range, staying headLag entries behind the tree
+	// head; the newest entries are picked up by a later cycle. A frozen
+	// log gets no new entries, so it is read to the end.
+	head := sth.TreeSize
+	if m.frozenSince == nil {
+		head -= m.headLag
+	}
 	start := state.LastProcessedIndex
 	if start == 0 {
-		start = max(0, sth.TreeSize-int64(m.batchSize))
+		start = max(0, head-int64(m.effectiveBatch))
 	}
-	end := min(start+int64(m.batchSize)-1, sth.TreeSize-1)
+	end := min(start+int64(m.effectiveBatch)-1, head-1)
+	stats.Backlog = max(0, sth.TreeSize-start)
 
 	// 4. Get entries — either new from CT log or re-fetch for reprocessing
 	var entries []ctlog.RawEntry
+	var logURL string
 	var batchStart int64
-	hasNewEntries := start <= end
+	hasNewEntries := end >= 0 && start <= end
 
 	if hasNewEntries {
 		// Fetch fresh entries from CT log
-		logger.Info("fetching CT log entries",
+		logger.InfoContext(ctx, "fetching CT log entries",
 			"start", start, "end", end, "tree_size", sth.TreeSize)
 
-		entries, err = m.ctClient.GetEntries(ctx, start, end)
+		entries, logURL, err = m.getEntries(ctx, start, end)
 		if err != nil {
-			logger.Error("failed to fetch entries", "error", err)
-			m.state.SetError(ctx, fmt.Sprintf("failed to fetch entries: %v", err))
+			logger.ErrorContext(ctx, "failed to fetch entries", "error", err)
+			stats.Failed = true
+			m.setError(ctx, &stats, fmt.Sprintf("failed to fetch entries: %v", err))
 			return
 		}
 		batchStart = start
+		// Logs may cap get-entries below the requested range; only advance
+		// past what was actually returned so nothing is skipped.
+		if requested := int(end - start + 1); len(entries) < requested {
+			end = start + int64(len(entries)) - 1
+			m.observeShortRead(ctx, requested, len(entries))
+		} else {
+			m.shortReads = 0
+		}
 
 	} else if m.reprocessOnIdle {
 		// No new entries, but reprocess mode enabled — re-fetch last batch
-		reprocessStart := max(0, state.LastProcessedIndex-int64(m.batchSize))
+		reprocessStart := max(0, state.LastProcessedIndex-int64(m.effectiveBatch))
 		reprocessEnd := state.LastProcessedIndex - 1
 
 		if reprocessStart > reprocessEnd {
 			// No previous batch to reprocess (first run)
-			logger.Info("no entries to reprocess yet")
-			m.state.Update(ctx, &model.MonitorState{
+			logger.InfoContext(ctx, "no entries to reprocess yet")
+			m.saveState(ctx, &stats, &model.MonitorState{
 				LastProcessedIndex:     state.LastProcessedIndex,
 				LastTreeSize:           sth.TreeSize,
 				TotalProcessed:         state.TotalProcessed,
@@ -221,24 +726,25 @@ func (m *Monitor) processBatch(ctx context.Context) {
 			return
 		}
 
-		logger.Info("reprocessing previous batch (re-fetching from CT log)",
+		logger.InfoContext(ctx, "reprocessing previous batch (re-fetching from CT log)",
 			"start", reprocessStart, "end", reprocessEnd, "tree_size", sth.TreeSize)
 
-		entries, err = m.ctClient.GetEntries(ctx, reprocessStart, reprocessEnd)
+		entries, logURL, err = m.getEntries(ctx, reprocessStart, reprocessEnd)
 		if err != nil {
-			logger.Error("failed to re-fetch entries for reprocessing", "error", err)
-			m.state.SetError(ctx, fmt.Sprintf("failed to re-fetch entries: %v", err))
+			logger.ErrorContext(ctx, "failed to re-fetch entries for reprocessing", "error", err)
+			stats.Failed = true
+			m.setError(ctx, &stats, fmt.Sprintf("failed to re-fetch entries: %v", err))
 			return
 		}
 		batchStart = reprocessStart
 
 	} else {
 		// No new entries and reprocess disabled — skip
-		logger.Info("no new entries, skipping",
+		logger.InfoContext(ctx, "no new entries, skipping",
 			"last_processed", start, "tree_size", sth.TreeSize)
 
 		// Update last_run_at to show monitor is still alive
-		m.state.Update(ctx, &model.MonitorState{
+		m.saveState(ctx, &stats, &model.MonitorState{
 			LastProcessedIndex:     state.LastProcessedIndex,
 			LastTreeSize:           sth.TreeSize,
 			TotalProcessed:         state.TotalProcessed,
@@ -253,37 +759,53 @@ func (m *Monitor) processBatch(ctx context.Context) {
 	// 5. Load keywords
 	keywords, err := m.keywords.List(ctx)
 	if err != nil {
-		logger.Error("failed to load keywords", "error", err)
-		m.state.SetError(ctx, fmt.Sprintf("failed to load keywords: %v", err))
+		logger.ErrorContext(ctx, "failed to load keywords", "error", err)
+		stats.Failed = true
+		m.setError(ctx, &stats, fmt.Sprintf("failed to load keywords: %v", err))
 		return
 	}
+	keywords = matchableKeywords(keywords, time.Now())
 
 	if len(keywords) == 0 {
-		logger.Info("no keywords configured, skipping matching")
+		logger.InfoContext(ctx, "no keywords to match (none configured or all muted, disabled or outside their window), skipping matching")
 		if hasNewEntries {
-			m.updateState(ctx, state, end, sth.TreeSize, len(entries), 0, 0)
+			m.updateState(ctx, &stats, state, end, sth.TreeSize, len(entries), 0, 0)
+			stats.Entries = len(entries)
+			stats.Backlog = sth.TreeSize - (end + 1)
 		}
-		m.state.SetError(ctx, "")
+		m.setError(ctx, &stats, "")
 		return
 	}
 
 	// 6. Parse and match
-	matchCount, parseErrors := m.matchEntries(ctx, entries, batchStart, keywords)
+	perKeyword := make(map[int]int, len(keywords))
+	batch := m.matchEntries(ctx, entries, logURL, batchStart, keywords, perKeyword)
+	stats.Entries, stats.Matches, stats.ParseErrors = batch.Entries, batch.Matches, batch.ParseErrors
+	stats.DroppedMatches, stats.SkippedNotBefore = batch.DroppedMatches, batch.SkippedNotBefore
+	stats.CNLessMatches = batch.CNLessMatches
+	matchCount, parseErrors := batch.Matches, batch.ParseErrors
 
-	logger.Info("batch processed",
+	logger.InfoContext(ctx, "batch processed",
 		"entries", len(entries),
 		"parse_errors", parseErrors,
 		"matches", matchCount,
+		"cn_less_matches", batch.CNLessMatches,
+		"dropped_matches", batch.DroppedMatches,
+		"skipped_not_before", batch.SkippedNotBefore,
 		"reprocessed", !hasNewEntries,
 	)
 
-	// 7. Update state and clear any previous error
+	// 7. Disable keywords that keep matching most of the log
+	stats.DisabledKeywords = m.disableRunaways(ctx, keywords, perKeyword, batch.Entries)
+
+	// 8. Update state and clear any previous error
 	if hasNewEntries {
 		// New entries processed - advance processing index
-		m.updateState(ctx, state, end, sth.TreeSize, len(entries), matchCount, parseErrors)
+		m.updateState(ctx, &stats, state, end, sth.TreeSize, len(entries), matchCount, parseErrors)
+		stats.Backlog = sth.TreeSize - (end + 1)
 	} else {
 		// Reprocessed - just update match count and last_run_at
-		m.state.Update(ctx, &model.MonitorState{
+		m.saveState(ctx, &stats, &model.MonitorState{
 			LastProcessedIndex:     state.LastProcessedIndex,
 			LastTreeSize:           sth.TreeSize,
 			TotalProcessed:         state.TotalProcessed,
@@ -293,15 +815,308 @@ func (m *Monitor) processBatch(ctx context.Context) {
 			IsRunning:              true,
 		})
 	}
-	m.state.SetError(ctx, "")
+	m.setError(ctx, &stats, "")
+	return
+}
+
+// Reprocess re-fetches log entries [start, end] and matches them as
+// Backfill does, for a server that may be running its loop at the same
+// time: the monitor's position is left alone. A range reaching past the
+// current tree size is ErrBeyondTree, and a call while another is in
+// progress ErrReprocessRunning.
+func (m *Monitor) Reprocess(ctx context.Context, start, end int64) (CycleStats, error) {
+	if !m.reprocessing.TryLock() {
+		return CycleStats{}, ErrReprocessRunning
+	}
+	defer m.reprocessing.Unlock()
+
+	if start < 0 || end < start {
+		return CycleStats{}, fmt.Errorf("invalid range %d-%d", start, end)
+	}
+	sth, err := m.getSTH(ctx)
+	if err != nil {
+		return CycleStats{}, fmt.Errorf("get STH: %w", err)
+	}
+	if end >= sth.TreeSize {
+		return CycleStats{}, fmt.Errorf("%w: last index is %d", ErrBeyondTree, sth.TreeSize-1)
+	}
+	slog.InfoContext(ctx, "reprocessing range", "start", start, "end", end, "tree_size", sth.TreeSize)
+	return m.Backfill(ctx, start, end)
+}
+
+// Backfill fetches log entries [start, end] in batches and matches them
+// against the current keywords, storing (and publishing) matches as a
+// regular cycle would. The monitor's position and state are left alone, so
+// a range missed earlier can be rescanned while the loop keeps running.
+func (m *Monitor) Backfill(ctx context.Context, start, end int64) (stats CycleStats, err error) {
+	ctx, span := m.tracer.Start(ctx, "monitor.backfill", trace.WithAttributes(
+		attribute.Int64("start", start), attribute.Int64("end", end)))
+	defer span.End()
+	started := time.Now()
+	defer func() { stats.Duration = time.Since(started) }()
+
+	if start < 0 || end < start {
+		return stats, fmt.Errorf("invalid range %d-%d", start, end)
+	}
+	keywords, err := m.keywords.List(ctx)
+	if err != nil {
+		return stats, fmt.Errorf("load keywords: %w", err)
+	}
+	keywords = matchableKeywords(keywords, time.Now())
+	if len(keywords) == 0 {
+		return stats, ErrNoKeywords
+	}
+
+	// batchSize rather than effectiveBatch, which belongs to the loop;
+	// short reads are handled by advancing past what the log returned.
+	for next := start; next <= end; {
+		batchEnd := min(next+int64(m.batchSize)-1, end)
+		entries, logURL, err := m.getEntries(ctx, next, batchEnd)
+		if err != nil {
+			return stats, fmt.Errorf("get entries %d-%d: %w", next, batchEnd, err)
+		}
+		if len(entries) == 0 {
+			return stats, fmt.Errorf("log returned no entries at index %d", next)
+		}
+		batch := m.matchEntries(ctx, entries, logURL, next, keywords, nil)
+		stats.Entries += batch.Entries
+		stats.Matches += batch.Matches
+		stats.ParseErrors += batch.ParseErrors
+		stats.DroppedMatches += batch.DroppedMatches
+		stats.SkippedNotBefore += batch.SkippedNotBefore
+		stats.CNLessMatches += batch.CNLessMatches
+		// Advance past what the log returned; it may cap the page size.
+		next += int64(len(entries))
+		slog.InfoContext(ctx, "backfill progress",
+			"next", next, "end", end, "entries", stats.Entries, "matches", stats.Matches)
+	}
+	return stats, nil
+}
+
+// disableRunaways updates each keyword's streak of cycles over
+// maxMatchPercent of entries from its matches in this cycle and disables
+// those whose streak reached runawayCycles. It returns how many it
+// disabled. A keyword that fails to be disabled keeps its streak and is
+// tried again next cycle.
+func (m *Monitor) disableRunaways(ctx context.Context, keywords []model.Keyword, matches map[int]int, entries int) (disabled int) {
+	if m.disabler == nil || m.maxMatchPercent <= 0 || entries == 0 {
+		return 0
+	}
+	// Rebuilt every cycle so deleted or muted keywords drop out.
+	streaks := make(map[int]int)
+	for _, kw := range keywords {
+		n := matches[kw.ID]
+		if n*100 <= m.maxMatchPercent*entries {
+			continue
+		}
+		streak := m.runaway[kw.ID] + 1
+		if streak < m.runawayCycles {
+			streaks[kw.ID] = streak
+			continue
+		}
+		reason := fmt.Sprintf("matched %d of %d entries (over %d%%) for %d consecutive cycles",
+			n, entries, m.maxMatchPercent, streak)
+		if _, err := m.disabler.Disable(ctx, kw.ID, reason); err != nil {
+			slog.ErrorContext(ctx, "failed to disable runaway keyword", "error", err, "keyword", kw.Value)
+			streaks[kw.ID] = streak
+			continue
+		}
+		slog.WarnContext(ctx, "keyword disabled for matching too much of the log",
+			"keyword", kw.Value, "keyword_id", kw.ID, "reason", reason)
+		disabled++
+	}
+	m.runaway = streaks
+	return disabled
+}
+
+// matchableKeywords returns the keywords enabled, in their active window
+// and not muted from matching at now.
+func matchableKeywords(keywords []model.Keyword, now time.Time) []model.Keyword {
+	matchable := make([]model.Keyword, 0, len(keywords))
+	for _, kw := range keywords {
+		if kw.Enabled() && kw.ActiveAt(now) && !kw.MutedAt(now, model.MuteScopeMatching) {
+			matchable = append(matchable, kw)
+		}
+	}
+	return matchable
 }
 
+// shortReadsToTune is how many consecutive reads of the same short length
+// it takes to lower the effective batch size to that length.
+const shortReadsToTune = 3
+
+// observeShortRead records that a get-entries call for requested entries
+// returned only got. Once the log has served the same smaller count
+// shortReadsToTune times in a row, future batches request that many.
+func (m *Monitor) observeShortRead(ctx context.Context, requested, got int) {
+	if got <= 0 || got >= m.effectiveBatch {
+		// Empty reads say nothing about the log's page size, and a short
+		// read near the tree head is not a cap.
+		return
+	}
+	if got != m.shortLen {
+		m.shortLen, m.shortReads = got, 0
+	}
+	m.shortReads++
+	if m.shortReads < shortReadsToTune {
+		return
+	}
+	slog.WarnContext(ctx, "CT log serves fewer entries per request than the batch size; lowering the batch size",
+		"batch_size", m.batchSize, "requested", requested, "effective_batch_size", got)
+	m.effectiveBatch = got
+	m.shortReads, m.shortLen = 0, 0
+}
+
+func (m *Monitor) getSTH(ctx context.Context) (*ctlog.STH, error) {
+	ctx, span := m.tracer.Start(ctx, "ctlog.get-sth")
+	defer span.End()
+	sth, err := m.client().GetSTH(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "get-sth failed")
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int64("tree_size", sth.TreeSize))
+	return sth, nil
+}
+
+// getEntries fetches entries start..end of the current log and returns
+// its URL with them.
+func (m *Monitor) getEntries(ctx context.Context, start, end int64) ([]ctlog.RawEntry, string, error) {
+	ctx, span := m.tracer.Start(ctx, "ctlog.get-entries", trace.WithAttributes(
+		attribute.Int64("start", start), attribute.Int64("end", end)))
+	defer span.End()
+	client, url := m.log()
+	entries, err := client.GetEntries(ctx, start, end)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "get-entries failed")
+		return nil, url, err
+	}
+	span.SetAttributes(attribute.Int("entries", len(entries)))
+	return entries, url, nil
+}
+
+// parsedEntry is a certificate decoded from the log entry at index.
+type parsedEntry struct {
+	cert  *ctlog.ParsedCertificate
+	index int64
+}
+
+// matchEntries parses entries, read from the log at logURL, stores their
+// matches and returns the batch's counts (every CycleStats field but
+// Duration, Backlog, DisabledKeywords and Failed). A non-nil perKeyword is
+// filled with each keyword's matches, counted before the per-certificate
+// cap.
 func (m *Monitor) matchEntries(
 	ctx context.Context,
 	entries []ctlog.RawEntry,
+	logURL string,
 	batchStart int64,
 	keywords []model.Keyword,
-) (matchCount, parseErrors int) {
+	perKeyword map[int]int,
+) (stats CycleStats) {
+	parsed, parseErrors, tooOld := m.parseEntries(ctx, entries, batchStart)
+	stats.Entries, stats.ParseErrors, stats.SkippedNotBefore = len(entries), parseErrors, tooOld
+
+	ctx, span := m.tracer.Start(ctx, "monitor.match", trace.WithAttributes(
+		attribute.Int("certificates", len(parsed)), attribute.Int("keywords", len(keywords))))
+	defer func() {
+		span.SetAttributes(attribute.Int("matches", stats.Matches), attribute.Int("dropped_matches", stats.DroppedMatches))
+		span.End()
+	}()
+
+	keywordValues := make(map[int]string, len(keywords))
+	for _, kw := range keywords {
+		keywordValues[kw.ID] = kw.Value
+	}
+
+	for _, p := range parsed {
+		cert := p.cert
+		matches := matcher.MatchWith(cert, keywords, matcher.Options{IgnoreCN: m.ignoreCN})
+		if perKeyword != nil {
+			for _, match := range matches {
+				perKeyword[match.KeywordID]++
+			}
+		}
+		if m.maxMatchesPerCert > 0 && len(matches) > m.maxMatchesPerCert {
+			slog.WarnContext(ctx, "per-certificate match cap reached",
+				"serial", cert.Serial,
+				"common_name", cert.CommonName,
+				"matches", len(matches),
+				"cap", m.maxMatchesPerCert,
+			)
+			stats.DroppedMatches += len(matches) - m.maxMatchesPerCert
+			matches = matches[:m.maxMatchesPerCert]
+		}
+		chainStatus := model.ChainNotChecked
+		if m.chains != nil && len(matches) > 0 {
+			chainStatus = m.chains.Verify(cert)
+		}
+		for _, match := range matches {
+			registrable, ok := domain.Registrable(match.MatchedDomain)
+			stored := &model.MatchedCertificate{
+				SerialNumber:         cert.Serial,
+				CommonName:           cert.CommonName,
+				SANs:                 cert.SANs,
+				Issuer:               cert.Issuer,
+				NotBefore:            cert.NotBefore,
+				NotAfter:             cert.NotAfter,
+				KeywordID:            match.KeywordID,
+				KeywordValue:         keywordValues[match.KeywordID],
+				MatchedDomain:        match.MatchedDomain,
+				MatchedField:         match.MatchedField,
+				MatchedValue:         match.MatchedValue,
+				IsPrecert:            cert.IsPrecert,
+				CTLogIndex:           p.index,
+				CTLogURL:             logURL,
+				RegistrableDomain:    registrable,
+				RegistrableDomainRaw: !ok,
+				ExtKeyUsages:         cert.ExtKeyUsages,
+				IsServerAuth:         cert.IsServerAuth,
+				ChainStatus:          chainStatus,
+				IssuerClass:          issuer.Classify(cert.Issuer),
+			}
+			if err := m.certs.Create(ctx, stored); err != nil {
+				slog.ErrorContext(ctx, "failed to store match", "error", err, "domain", match.MatchedDomain)
+				continue
+			}
+			stats.Matches++
+			if cert.CommonName == "" {
+				stats.CNLessMatches++
+			}
+			// Create leaves ID zero when the match was already stored.
+			if stored.ID == 0 {
+				continue
+			}
+			for _, p := range m.publishers {
+				p.Publish(*stored)
+			}
+			if m.logMatches {
+				slog.InfoContext(ctx, "certificate matched",
+					"keyword", stored.KeywordValue,
+					"matched_domain", stored.MatchedDomain,
+					"serial", stored.SerialNumber,
+					"issuer", stored.Issuer,
+					"ct_log_index", stored.CTLogIndex,
+				)
+			}
+		}
+	}
+	return
+}
+
+// parseEntries decodes entries, dropping those that fail to parse, those
+// issued before minNotBefore (counted in tooOld) and, with serverAuthOnly,
+// certificates not valid for TLS server authentication.
+func (m *Monitor) parseEntries(ctx context.Context, entries []ctlog.RawEntry, batchStart int64) (parsed []parsedEntry, parseErrors, tooOld int) {
+	ctx, span := m.tracer.Start(ctx, "monitor.parse", trace.WithAttributes(attribute.Int("entries", len(entries))))
+	defer func() {
+		span.SetAttributes(attribute.Int("parse_errors", parseErrors), attribute.Int("skipped_not_before", tooOld))
+		span.End()
+	}()
+
+	parsed = make([]parsedEntry, 0, len(entries))
 	for i, entry := range entries {
 		cert, err := ctlog.ParseLeafInput(entry.LeafInput, entry.ExtraData)
 		if err != nil {
@@ -309,36 +1124,45 @@ func (m *Monitor) matchEntries(
 			continue
 		}
 
-		matches := matcher.Match(cert, keywords)
-		for _, match := range matches {
-			err := m.certs.Create(ctx, &model.MatchedCertificate{
-				SerialNumber:  cert.Serial,
-				CommonName:    cert.CommonName,
-				SANs:          cert.SANs,
-				Issuer:        cert.Issuer,
-				NotBefore:     cert.NotBefore,
-				NotAfter:      cert.NotAfter,
-				KeywordID:     match.KeywordID,
-				MatchedDomain: match.MatchedDomain,
-				CTLogIndex:    batchStart + int64(i),
-			})
-			if err != nil {
-				slog.Error("failed to store match", "error", err, "domain", match.MatchedDomain)
-				continue
-			}
-			matchCount++
+		if !m.minNotBefore.IsZero() && cert.NotBefore.Before(m.minNotBefore) {
+			tooOld++
+			continue
 		}
+		if m.serverAuthOnly && !cert.IsServerAuth {
+			slog.DebugContext(ctx, "skipping non-server certificate",
+				"serial", cert.Serial, "ext_key_usages", cert.ExtKeyUsages)
+			continue
+		}
+		parsed = append(parsed, parsedEntry{cert: cert, index: batchStart + int64(i)})
 	}
-	return
+	return parsed, parseErrors, tooOld
+}
+
+// setError records msg as the monitor's last error (empty clears it). A
+// failure is logged and counted in stats, when given: with the database
+// down the cycle's real error would otherwise be lost and the monitor
+// would look healthy but idle.
+func (m *Monitor) setError(ctx context.Context, stats *CycleStats, msg string) {
+	if err := m.state.SetError(ctx, msg); err != nil {
+		m.setErrorFailures++
+		slog.ErrorContext(ctx, "failed to record monitor error",
+			"error", err, "monitor_error", msg, "consecutive_failures", m.setErrorFailures)
+		if stats != nil {
+			stats.StateErrors++
+		}
+		return
+	}
+	m.setErrorFailures = 0
 }
 
 func (m *Monitor) updateState(
 	ctx context.Context,
+	stats *CycleStats,
 	prev *model.MonitorState,
 	endIndex, treeSize int64,
 	processed, matches, parseErrors int,
 ) {
-	err := m.state.Update(ctx, &model.MonitorState{
+	m.saveState(ctx, stats, &model.MonitorState{
 		LastProcessedIndex:     endIndex + 1,
 		LastTreeSize:           treeSize,
 		TotalProcessed:         prev.TotalProcessed + int64(processed),
@@ -347,7 +1171,13 @@ func (m *Monitor) updateState(
 		ParseErrorsInLastCycle: parseErrors,
 		IsRunning:              true,
 	})
-	if err != nil {
-		slog.Error("failed to update monitor state", "error", err)
+}
+
+// saveState writes st as the monitor's state. A failure is logged and
+// counted in stats so the cycle does not count as completed.
+func (m *Monitor) saveState(ctx context.Context, stats *CycleStats, st *model.MonitorState) {
+	if err := m.state.Update(ctx, st); err != nil {
+		stats.StateErrors++
+		slog.ErrorContext(ctx, "failed to update monitor state", "error", err)
 	}
 }
@@ -1,6 +1,7 @@
 package monitor
 
 import (
+	"bytes"
 	"context"
 	"crypto/ecdsa"
 	"crypto/elliptic"
@@ -8,11 +9,23 @@ import (
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/binary"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
 	"math/big"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/fakectlog"
 	"github.com/andres10976/SISAP-PoC/backend/internal/model"
 	"github.com/andres10976/SISAP-PoC/backend/internal/service/ctlog"
 )
@@ -52,6 +65,8 @@ type mockStateStore struct {
 	updateFn     func(ctx context.Context, state *model.MonitorState) error
 	setRunningFn func(ctx context.Context, running bool) error
 	setErrorFn   func(ctx context.Context, errMsg string) error
+	setFrozenFn  func(ctx context.Context, since *time.Time) error
+	switchLogFn  func(ctx context.Context, url, configuredURL string, index, treeSize int64) error
 }
 
 func (m *mockStateStore) Get(ctx context.Context) (*model.MonitorState, error) {
@@ -69,6 +84,18 @@ func (m *mockStateStore) SetError(ctx context.Context, errMsg string) error {
 	}
 	return nil
 }
+func (m *mockStateStore) SetLogFrozen(ctx context.Context, since *time.Time) error {
+	if m.setFrozenFn != nil {
+		return m.setFrozenFn(ctx, since)
+	}
+	return nil
+}
+func (m *mockStateStore) SwitchLog(ctx context.Context, url, configuredURL string, index, treeSize int64) error {
+	if m.switchLogFn != nil {
+		return m.switchLogFn(ctx, url, configuredURL, index, treeSize)
+	}
+	return nil
+}
 
 // --- helpers ---
 
@@ -91,7 +118,13 @@ func buildLeaf(t *testing.T, certDER []byte) []byte {
 	return buf
 }
 
-func selfSignedDER(t *testing.T, cn string, sans []string) []byte {
+func selfSignedDER(t *testing.T, cn string, sans []string, usages ...x509.ExtKeyUsage) []byte {
+	t.Helper()
+	return selfSignedDERFrom(t, time.Now().Add(-time.Hour), cn, sans, usages...)
+}
+
+// selfSignedDERFrom is selfSignedDER with an explicit NotBefore.
+func selfSignedDERFrom(t *testing.T, notBefore time.Time, cn string, sans []string, usages ...x509.ExtKeyUsage) []byte {
 	t.Helper()
 	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	if err != nil {
@@ -101,8 +134,9 @@ func selfSignedDER(t *testing.T, cn string, sans []string) []byte {
 		SerialNumber: big.NewInt(1),
 		Subject:      pkix.Name{CommonName: cn},
 		DNSNames:     sans,
-		NotBefore:    time.Now().Add(-time.Hour),
+		NotBefore:    notBefore,
 		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  usages,
 	}
 	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
 	if err != nil {
@@ -257,6 +291,79 @@ func TestStop_NotRunning(t *testing.T) {
 	}
 }
 
+// blockingSTHClient blocks GetSTH until release is closed, then fails the
+// batch with the context's error (nil if it was never canceled).
+func blockingSTHClient(entered chan<- struct{}, release <-chan struct{}, batchErr chan<- error) *mockCTClient {
+	return &mockCTClient{
+		getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+			entered <- struct{}{}
+			select {
+			case <-release:
+			case <-ctx.Done():
+			}
+			batchErr <- ctx.Err()
+			return nil, errors.New("stub")
+		},
+	}
+}
+
+func TestStop_WaitsForBatchInProgress(t *testing.T) {
+	entered, release, batchErr := make(chan struct{}, 1), make(chan struct{}), make(chan error, 1)
+	ss := &mockStateStore{setRunningFn: func(ctx context.Context, running bool) error { return nil }}
+	m := New(blockingSTHClient(entered, release, batchErr), &mockKeywordLister{}, &mockCertCreator{}, ss, 10, time.Hour, false)
+
+	m.Start(context.Background())
+	<-entered
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- m.Stop(context.Background()) }()
+	select {
+	case err := <-stopped:
+		t.Fatalf("Stop() returned %v while the batch was in progress", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	if !m.IsRunning() {
+		t.Error("IsRunning() = false while the batch is finishing")
+	}
+
+	close(release)
+	if err := <-stopped; err != nil {
+		t.Errorf("Stop() = %v, want nil", err)
+	}
+	if err := <-batchErr; err != nil {
+		t.Errorf("batch context error = %v, want the batch to run to completion", err)
+	}
+	if m.IsRunning() {
+		t.Error("IsRunning() = true after Stop")
+	}
+}
+
+func TestStop_CancelsBatchAtDeadline(t *testing.T) {
+	entered, batchErr := make(chan struct{}, 1), make(chan error, 1)
+	var running []bool
+	ss := &mockStateStore{setRunningFn: func(ctx context.Context, r bool) error {
+		running = append(running, r)
+		return nil
+	}}
+	m := New(blockingSTHClient(entered, nil, batchErr), &mockKeywordLister{}, &mockCertCreator{}, ss, 10, time.Hour, false)
+
+	m.Start(context.Background())
+	<-entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := m.Stop(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Stop() = %v, want DeadlineExceeded", err)
+	}
+	if err := <-batchErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("batch context error = %v, want Canceled", err)
+	}
+	if m.IsRunning() || len(running) != 2 || running[1] {
+		t.Errorf("IsRunning() = %v, SetRunning calls = %v; want stopped and recorded", m.IsRunning(), running)
+	}
+}
+
 func TestIsRunning_DefaultFalse(t *testing.T) {
 	m := New(&mockCTClient{}, &mockKeywordLister{}, &mockCertCreator{}, &mockStateStore{}, 10, time.Hour, false)
 	if m.IsRunning() {
@@ -264,6 +371,148 @@ func TestIsRunning_DefaultFalse(t *testing.T) {
 	}
 }
 
+func TestStart_JitterDelaysFirstBatch(t *testing.T) {
+	ticks := make(chan time.Time, 1)
+	ss := &mockStateStore{
+		setRunningFn: func(ctx context.Context, running bool) error { return nil },
+	}
+	ct := &mockCTClient{
+		getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+			ticks <- time.Now()
+			return nil, errors.New("stub")
+		},
+	}
+	m := New(ct, &mockKeywordLister{}, &mockCertCreator{}, ss, 10, time.Hour, false,
+		WithStartJitter(time.Second))
+	m.jitterFn = func(max time.Duration) time.Duration { return 50 * time.Millisecond }
+
+	started := time.Now()
+	if err := m.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer m.Stop(context.Background())
+
+	select {
+	case first := <-ticks:
+		if elapsed := first.Sub(started); elapsed < 50*time.Millisecond {
+			t.Errorf("first batch after %v, want >= 50ms jitter", elapsed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for first batch")
+	}
+}
+
+func TestStop_DuringJitter(t *testing.T) {
+	called := make(chan struct{}, 1)
+	ss := &mockStateStore{
+		setRunningFn: func(ctx context.Context, running bool) error { return nil },
+	}
+	ct := &mockCTClient{
+		getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+			called <- struct{}{}
+			return nil, errors.New("stub")
+		},
+	}
+	m := New(ct, &mockKeywordLister{}, &mockCertCreator{}, ss, 10, time.Hour, false,
+		WithStartJitter(time.Hour))
+
+	if err := m.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if err := m.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	select {
+	case <-called:
+		t.Error("batch ran after Stop during jitter delay")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestPause_TicksWithoutProcessingThenResumes(t *testing.T) {
+	calls := make(chan struct{}, 100)
+	ss := &mockStateStore{
+		setRunningFn: func(ctx context.Context, running bool) error { return nil },
+	}
+	ct := &mockCTClient{
+		getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+			calls <- struct{}{}
+			return nil, errors.New("stub")
+		},
+	}
+	m := New(ct, &mockKeywordLister{}, &mockCertCreator{}, ss, 10, 10*time.Millisecond, false)
+
+	if err := m.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer m.Stop(context.Background())
+
+	select {
+	case <-calls:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for first batch")
+	}
+
+	if err := m.Pause(); err != nil {
+		t.Fatalf("Pause() error = %v", err)
+	}
+	if !m.IsPaused() || !m.IsRunning() {
+		t.Fatalf("IsPaused=%v IsRunning=%v, want both true", m.IsPaused(), m.IsRunning())
+	}
+
+	// Let a batch that raced with Pause finish, then expect silence.
+	time.Sleep(20 * time.Millisecond)
+	for len(calls) > 0 {
+		<-calls
+	}
+	select {
+	case <-calls:
+		t.Fatal("batch processed while paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := m.Resume(); err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+	select {
+	case <-calls:
+	case <-time.After(time.Second):
+		t.Fatal("no batch processed after Resume")
+	}
+}
+
+func TestPause_NotRunning(t *testing.T) {
+	m := New(nil, nil, nil, nil, 10, time.Hour, false)
+	if err := m.Pause(); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("Pause() error = %v, want ErrNotRunning", err)
+	}
+	if err := m.Resume(); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("Resume() error = %v, want ErrNotRunning", err)
+	}
+}
+
+func TestStop_ClearsPause(t *testing.T) {
+	ss := &mockStateStore{
+		setRunningFn: func(ctx context.Context, running bool) error { return nil },
+	}
+	ct := &mockCTClient{
+		getSTHFn: func(ctx context.Context) (*ctlog.STH, error) { return nil, errors.New("stub") },
+	}
+	m := New(ct, &mockKeywordLister{}, &mockCertCreator{}, ss, 10, time.Hour, false)
+
+	if err := m.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	m.Pause()
+	if err := m.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if m.IsPaused() {
+		t.Error("IsPaused() = true after Stop, want false")
+	}
+}
+
 // --- processBatch tests ---
 
 func TestProcessBatch_Success(t *testing.T) {
@@ -319,6 +568,16 @@ func TestProcessBatch_Success(t *testing.T) {
 	if storedCert.MatchedDomain != "example.com" {
 		t.Errorf("storedCert.MatchedDomain = %q, want %q", storedCert.MatchedDomain, "example.com")
 	}
+	if storedCert.KeywordValue != "example" {
+		t.Errorf("storedCert.KeywordValue = %q, want %q", storedCert.KeywordValue, "example")
+	}
+	if storedCert.MatchedField != model.MatchFieldCN {
+		t.Errorf("storedCert.MatchedField = %q, want %q", storedCert.MatchedField, model.MatchFieldCN)
+	}
+	if storedCert.RegistrableDomain != "example.com" || storedCert.RegistrableDomainRaw {
+		t.Errorf("storedCert registrable domain = (%q, raw=%v), want (example.com, false)",
+			storedCert.RegistrableDomain, storedCert.RegistrableDomainRaw)
+	}
 
 	if updatedState == nil {
 		t.Fatal("expected state to be updated")
@@ -331,107 +590,160 @@ func TestProcessBatch_Success(t *testing.T) {
 	}
 }
 
-func TestProcessBatch_STHError(t *testing.T) {
-	stateCalled := false
+type recordingMetrics struct {
+	cycles []CycleStats
+}
+
+func (r *recordingMetrics) ObserveCycle(stats CycleStats) {
+	r.cycles = append(r.cycles, stats)
+}
+
+func TestTick_ReportsCycleStats(t *testing.T) {
+	der := selfSignedDER(t, "example.com", nil)
+	leaf := buildLeaf(t, der)
+	rec := &recordingMetrics{}
+
 	m := New(
 		&mockCTClient{
 			getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
-				return nil, errors.New("network error")
+				return &ctlog.STH{TreeSize: 200}, nil
+			},
+			getEntriesFn: func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
+				return []ctlog.RawEntry{{LeafInput: leaf}, {LeafInput: []byte("garbage")}}, nil
 			},
 		},
-		&mockKeywordLister{},
-		&mockCertCreator{},
+		&mockKeywordLister{
+			listFn: func(ctx context.Context) ([]model.Keyword, error) {
+				return []model.Keyword{{ID: 1, Value: "example"}}, nil
+			},
+		},
+		&mockCertCreator{
+			createFn: func(ctx context.Context, cert *model.MatchedCertificate) error { return nil },
+		},
 		&mockStateStore{
 			getFn: func(ctx context.Context) (*model.MonitorState, error) {
-				stateCalled = true
-				return nil, nil
+				return &model.MonitorState{LastProcessedIndex: 100}, nil
 			},
+			updateFn: func(ctx context.Context, state *model.MonitorState) error { return nil },
 		},
 		10, time.Hour, false,
+		WithMetrics(rec),
 	)
 
-	m.processBatch(context.Background())
+	m.tick(context.Background())
 
-	if stateCalled {
-		t.Error("state.Get should not be called when STH fails")
+	if len(rec.cycles) != 1 {
+		t.Fatalf("ObserveCycle called %d times, want 1", len(rec.cycles))
+	}
+	got := rec.cycles[0]
+	if got.Failed {
+		t.Error("Failed = true, want false")
+	}
+	if got.Entries != 2 || got.Matches != 1 || got.ParseErrors != 1 {
+		t.Errorf("Entries/Matches/ParseErrors = %d/%d/%d, want 2/1/1", got.Entries, got.Matches, got.ParseErrors)
+	}
+	// The log returned 2 of the 10 requested entries; only those count as
+	// processed.
+	if got.Backlog != 98 {
+		t.Errorf("Backlog = %d, want 98", got.Backlog)
 	}
 }
 
-func TestProcessBatch_StateGetError(t *testing.T) {
-	entriesCalled := false
+func TestTick_RecordsCycleSpans(t *testing.T) {
+	der := selfSignedDER(t, "example.com", nil)
+	leaf := buildLeaf(t, der)
+	spans := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+
 	m := New(
 		&mockCTClient{
 			getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
 				return &ctlog.STH{TreeSize: 200}, nil
 			},
 			getEntriesFn: func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
-				entriesCalled = true
-				return nil, nil
+				return []ctlog.RawEntry{{LeafInput: leaf}, {LeafInput: []byte("garbage")}}, nil
 			},
 		},
-		&mockKeywordLister{},
-		&mockCertCreator{},
+		&mockKeywordLister{
+			listFn: func(ctx context.Context) ([]model.Keyword, error) {
+				return []model.Keyword{{ID: 1, Value: "example"}}, nil
+			},
+		},
+		&mockCertCreator{
+			createFn: func(ctx context.Context, cert *model.MatchedCertificate) error { return nil },
+		},
 		&mockStateStore{
 			getFn: func(ctx context.Context) (*model.MonitorState, error) {
-				return nil, errors.New("db error")
+				return &model.MonitorState{LastProcessedIndex: 100}, nil
 			},
+			updateFn: func(ctx context.Context, state *model.MonitorState) error { return nil },
 		},
 		10, time.Hour, false,
+		WithTracerProvider(tp),
 	)
 
-	m.processBatch(context.Background())
+	m.tick(context.Background())
 
-	if entriesCalled {
-		t.Error("GetEntries should not be called when state.Get fails")
+	ended := spans.Ended()
+	byName := make(map[string]sdktrace.ReadOnlySpan, len(ended))
+	for _, s := range ended {
+		byName[s.Name()] = s
+	}
+	cycle, ok := byName["monitor.cycle"]
+	if !ok {
+		t.Fatalf("spans = %v, want a monitor.cycle span", byName)
+	}
+	for _, name := range []string{"ctlog.get-sth", "ctlog.get-entries", "monitor.parse", "monitor.match"} {
+		s, ok := byName[name]
+		if !ok {
+			t.Errorf("missing %s span", name)
+			continue
+		}
+		if s.Parent().SpanID() != cycle.SpanContext().SpanID() {
+			t.Errorf("%s is not a child of monitor.cycle", name)
+		}
+	}
+	attrs := make(map[string]int64)
+	for _, kv := range byName["monitor.parse"].Attributes() {
+		attrs[string(kv.Key)] = kv.Value.AsInt64()
+	}
+	if attrs["entries"] != 2 || attrs["parse_errors"] != 1 {
+		t.Errorf("monitor.parse attributes = %v, want entries=2 parse_errors=1", attrs)
 	}
 }
 
-func TestProcessBatch_NoNewEntries(t *testing.T) {
-	entriesCalled := false
-	var updatedState *model.MonitorState
+func TestTick_FailedCycleSpanStatus(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
 	m := New(
 		&mockCTClient{
 			getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
-				return &ctlog.STH{TreeSize: 100}, nil
-			},
-			getEntriesFn: func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
-				entriesCalled = true
-				return nil, nil
+				return nil, errors.New("network error")
 			},
 		},
 		&mockKeywordLister{},
 		&mockCertCreator{},
-		&mockStateStore{
-			getFn: func(ctx context.Context) (*model.MonitorState, error) {
-				// Already processed up to tree size
-				return &model.MonitorState{LastProcessedIndex: 100}, nil
-			},
-			updateFn: func(ctx context.Context, state *model.MonitorState) error {
-				updatedState = state
-				return nil
-			},
-		},
-		10, time.Hour, false, // reprocessOnIdle=false
+		&mockStateStore{},
+		10, time.Hour, false,
+		WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))),
 	)
 
-	m.processBatch(context.Background())
-
-	if entriesCalled {
-		t.Error("GetEntries should not be called when start > end")
-	}
+	m.tick(context.Background())
 
-	// State SHOULD be updated to refresh last_run_at
-	if updatedState == nil {
-		t.Fatal("state should be updated even when no new entries (to update last_run_at)")
+	for _, s := range spans.Ended() {
+		if s.Status().Code != codes.Error {
+			t.Errorf("%s status = %v, want Error", s.Name(), s.Status().Code)
+		}
 	}
-	if updatedState.LastProcessedIndex != 100 {
-		t.Errorf("LastProcessedIndex = %d, want 100 (unchanged)", updatedState.LastProcessedIndex)
+	if n := len(spans.Ended()); n != 2 {
+		t.Errorf("ended spans = %d, want monitor.cycle and ctlog.get-sth", n)
 	}
 }
 
-func TestProcessBatch_NoKeywords(t *testing.T) {
-	var updatedState *model.MonitorState
-	certCreated := false
+func TestTick_CapsMatchesPerCert(t *testing.T) {
+	der := selfSignedDER(t, "example.com", []string{"login.example.com", "shop.example.com", "mail.example.com"})
+	leaf := buildLeaf(t, der)
+	rec := &recordingMetrics{}
+	var stored []string
 
 	m := New(
 		&mockCTClient{
@@ -439,17 +751,23 @@ func TestProcessBatch_NoKeywords(t *testing.T) {
 				return &ctlog.STH{TreeSize: 200}, nil
 			},
 			getEntriesFn: func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
-				return []ctlog.RawEntry{{LeafInput: []byte("dummy")}}, nil
+				return []ctlog.RawEntry{{LeafInput: leaf}}, nil
 			},
 		},
 		&mockKeywordLister{
 			listFn: func(ctx context.Context) ([]model.Keyword, error) {
-				return nil, nil // no keywords
+				return []model.Keyword{
+					{ID: 1, Value: "example"},
+					{ID: 2, Value: "login"},
+					{ID: 3, Value: "shop"},
+					{ID: 4, Value: "mail"},
+					{ID: 5, Value: "exam"},
+				}, nil
 			},
 		},
 		&mockCertCreator{
 			createFn: func(ctx context.Context, cert *model.MatchedCertificate) error {
-				certCreated = true
+				stored = append(stored, cert.KeywordValue)
 				return nil
 			},
 		},
@@ -457,43 +775,38 @@ func TestProcessBatch_NoKeywords(t *testing.T) {
 			getFn: func(ctx context.Context) (*model.MonitorState, error) {
 				return &model.MonitorState{LastProcessedIndex: 100}, nil
 			},
-			updateFn: func(ctx context.Context, state *model.MonitorState) error {
-				updatedState = state
-				return nil
-			},
+			updateFn: func(ctx context.Context, state *model.MonitorState) error { return nil },
 		},
 		10, time.Hour, false,
+		WithMetrics(rec),
+		WithMaxMatchesPerCert(2),
 	)
 
-	m.processBatch(context.Background())
+	m.tick(context.Background())
 
-	if certCreated {
-		t.Error("no certs should be stored when there are no keywords")
+	if len(stored) != 2 {
+		t.Errorf("stored %d matches (%v), want 2", len(stored), stored)
 	}
-	if updatedState == nil {
-		t.Fatal("state should still be updated when no keywords")
+	if len(rec.cycles) != 1 {
+		t.Fatalf("ObserveCycle called %d times, want 1", len(rec.cycles))
 	}
-	if updatedState.MatchesInLastCycle != 0 {
-		t.Errorf("MatchesInLastCycle = %d, want 0", updatedState.MatchesInLastCycle)
+	if got := rec.cycles[0]; got.Matches != 2 || got.DroppedMatches != 3 {
+		t.Errorf("Matches/DroppedMatches = %d/%d, want 2/3", got.Matches, got.DroppedMatches)
 	}
 }
 
-func TestProcessBatch_ParseErrorSkipped(t *testing.T) {
-	der := selfSignedDER(t, "example.com", nil)
-	goodLeaf := buildLeaf(t, der)
-	badLeaf := buildLeaf(t, []byte{0xDE, 0xAD}) // invalid DER
+func TestTick_ServerAuthOnlySkipsClientCerts(t *testing.T) {
+	server := buildLeaf(t, selfSignedDER(t, "server.example.com", nil, x509.ExtKeyUsageServerAuth))
+	client := buildLeaf(t, selfSignedDER(t, "client.example.com", nil, x509.ExtKeyUsageClientAuth))
+	var stored []*model.MatchedCertificate
 
-	createCount := 0
 	m := New(
 		&mockCTClient{
 			getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
 				return &ctlog.STH{TreeSize: 200}, nil
 			},
 			getEntriesFn: func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
-				return []ctlog.RawEntry{
-					{LeafInput: badLeaf},
-					{LeafInput: goodLeaf},
-				}, nil
+				return []ctlog.RawEntry{{LeafInput: server}, {LeafInput: client}}, nil
 			},
 		},
 		&mockKeywordLister{
@@ -503,7 +816,7 @@ func TestProcessBatch_ParseErrorSkipped(t *testing.T) {
 		},
 		&mockCertCreator{
 			createFn: func(ctx context.Context, cert *model.MatchedCertificate) error {
-				createCount++
+				stored = append(stored, cert)
 				return nil
 			},
 		},
@@ -514,27 +827,33 @@ func TestProcessBatch_ParseErrorSkipped(t *testing.T) {
 			updateFn: func(ctx context.Context, state *model.MonitorState) error { return nil },
 		},
 		10, time.Hour, false,
+		WithServerAuthOnly(true),
 	)
 
-	m.processBatch(context.Background())
+	m.tick(context.Background())
 
-	if createCount != 1 {
-		t.Errorf("createCount = %d, want 1 (bad entry should be skipped)", createCount)
+	if len(stored) != 1 || stored[0].CommonName != "server.example.com" {
+		t.Fatalf("stored %d matches, want only the server certificate", len(stored))
+	}
+	if !stored[0].IsServerAuth || len(stored[0].ExtKeyUsages) != 1 || stored[0].ExtKeyUsages[0] != "server_auth" {
+		t.Errorf("IsServerAuth/ExtKeyUsages = %v/%v, want true/[server_auth]", stored[0].IsServerAuth, stored[0].ExtKeyUsages)
 	}
 }
 
-func TestProcessBatch_CertStoreError(t *testing.T) {
-	der := selfSignedDER(t, "example.com", nil)
-	leaf := buildLeaf(t, der)
+func TestTick_SkipsCertificatesBeforeMinNotBefore(t *testing.T) {
+	cutoff := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	old := buildLeaf(t, selfSignedDERFrom(t, cutoff.AddDate(-2, 0, 0), "old.example.com", nil))
+	recent := buildLeaf(t, selfSignedDER(t, "recent.example.com", nil))
+	rec := &recordingMetrics{}
+	var stored []string
 
-	var updatedState *model.MonitorState
 	m := New(
 		&mockCTClient{
 			getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
 				return &ctlog.STH{TreeSize: 200}, nil
 			},
 			getEntriesFn: func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
-				return []ctlog.RawEntry{{LeafInput: leaf}}, nil
+				return []ctlog.RawEntry{{LeafInput: old}, {LeafInput: recent}}, nil
 			},
 		},
 		&mockKeywordLister{
@@ -544,85 +863,134 @@ func TestProcessBatch_CertStoreError(t *testing.T) {
 		},
 		&mockCertCreator{
 			createFn: func(ctx context.Context, cert *model.MatchedCertificate) error {
-				return errors.New("insert failed")
+				stored = append(stored, cert.CommonName)
+				return nil
 			},
 		},
 		&mockStateStore{
 			getFn: func(ctx context.Context) (*model.MonitorState, error) {
 				return &model.MonitorState{LastProcessedIndex: 100}, nil
 			},
-			updateFn: func(ctx context.Context, state *model.MonitorState) error {
-				updatedState = state
-				return nil
-			},
+			updateFn: func(ctx context.Context, state *model.MonitorState) error { return nil },
 		},
 		10, time.Hour, false,
+		WithMetrics(rec),
+		WithMinNotBefore(cutoff),
 	)
 
-	m.processBatch(context.Background())
+	m.tick(context.Background())
 
-	if updatedState == nil {
-		t.Fatal("state should still be updated even when cert store fails")
+	if len(stored) != 1 || stored[0] != "recent.example.com" {
+		t.Fatalf("stored %v, want only recent.example.com", stored)
 	}
-	if updatedState.MatchesInLastCycle != 0 {
-		t.Errorf("MatchesInLastCycle = %d, want 0 (store failed)", updatedState.MatchesInLastCycle)
+	if len(rec.cycles) != 1 {
+		t.Fatalf("ObserveCycle called %d times, want 1", len(rec.cycles))
+	}
+	if got := rec.cycles[0]; got.Entries != 2 || got.SkippedNotBefore != 1 || got.ParseErrors != 0 {
+		t.Errorf("Entries/SkippedNotBefore/ParseErrors = %d/%d/%d, want 2/1/0",
+			got.Entries, got.SkippedNotBefore, got.ParseErrors)
 	}
 }
 
-func TestProcessBatch_FirstBatch_StartsNearTreeSize(t *testing.T) {
-	var requestedStart int64
+func TestProcessBatch_HeadLag(t *testing.T) {
+	tests := []struct {
+		name      string
+		lastIndex int64
+		wantStart int64
+		wantEnd   int64
+	}{
+		{"first batch ends before the lag", 0, 85, 94},
+		{"catching up stops at the lag", 90, 90, 94},
+		{"far behind is unaffected", 10, 10, 19},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotStart, gotEnd int64 = -1, -1
+			m := New(
+				&mockCTClient{
+					getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+						return &ctlog.STH{TreeSize: 100}, nil
+					},
+					getEntriesFn: func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
+						gotStart, gotEnd = start, end
+						return nil, nil
+					},
+				},
+				&mockKeywordLister{
+					listFn: func(ctx context.Context) ([]model.Keyword, error) { return nil, nil },
+				},
+				&mockCertCreator{},
+				&mockStateStore{
+					getFn: func(ctx context.Context) (*model.MonitorState, error) {
+						return &model.MonitorState{LastProcessedIndex: tt.lastIndex}, nil
+					},
+					updateFn: func(ctx context.Context, state *model.MonitorState) error { return nil },
+				},
+				10, time.Hour, false,
+				WithHeadLag(5),
+			)
+
+			m.processBatch(context.Background())
+
+			if gotStart != tt.wantStart || gotEnd != tt.wantEnd {
+				t.Errorf("GetEntries(%d, %d), want (%d, %d)", gotStart, gotEnd, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
 
+func TestProcessBatch_HeadLagHoldsBackNewestEntries(t *testing.T) {
+	fetched := false
 	m := New(
 		&mockCTClient{
 			getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
-				return &ctlog.STH{TreeSize: 1000}, nil
+				return &ctlog.STH{TreeSize: 100}, nil
 			},
 			getEntriesFn: func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
-				requestedStart = start
-				return nil, nil
-			},
-		},
-		&mockKeywordLister{
-			listFn: func(ctx context.Context) ([]model.Keyword, error) {
+				fetched = true
 				return nil, nil
 			},
 		},
+		&mockKeywordLister{},
 		&mockCertCreator{},
 		&mockStateStore{
 			getFn: func(ctx context.Context) (*model.MonitorState, error) {
-				return &model.MonitorState{LastProcessedIndex: 0}, nil // fresh start
+				return &model.MonitorState{LastProcessedIndex: 95}, nil
 			},
 			updateFn: func(ctx context.Context, state *model.MonitorState) error { return nil },
 		},
-		50, time.Hour, false,
+		10, time.Hour, false,
+		WithHeadLag(5),
 	)
 
 	m.processBatch(context.Background())
 
-	// When LastProcessedIndex is 0, start = max(0, TreeSize - batchSize) = 950
-	if requestedStart != 950 {
-		t.Errorf("start = %d, want 950 (TreeSize 1000 - batchSize 50)", requestedStart)
+	if fetched {
+		t.Error("fetched entries inside the head lag")
 	}
 }
 
-// --- error persistence tests ---
-
-func TestProcessBatch_STHError_PersistsError(t *testing.T) {
-	var lastError string
+func TestProcessBatch_ShortReadsAdvanceByReturnedEntries(t *testing.T) {
+	var updated *model.MonitorState
 	m := New(
 		&mockCTClient{
 			getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
-				return nil, errors.New("network error")
+				return &ctlog.STH{TreeSize: 1000}, nil
+			},
+			getEntriesFn: func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
+				return make([]ctlog.RawEntry, 4), nil
 			},
 		},
-		&mockKeywordLister{},
+		&mockKeywordLister{
+			listFn: func(ctx context.Context) ([]model.Keyword, error) { return nil, nil },
+		},
 		&mockCertCreator{},
 		&mockStateStore{
 			getFn: func(ctx context.Context) (*model.MonitorState, error) {
-				return nil, nil
+				return &model.MonitorState{LastProcessedIndex: 100}, nil
 			},
-			setErrorFn: func(ctx context.Context, errMsg string) error {
-				lastError = errMsg
+			updateFn: func(ctx context.Context, state *model.MonitorState) error {
+				updated = state
 				return nil
 			},
 		},
@@ -631,27 +999,114 @@ func TestProcessBatch_STHError_PersistsError(t *testing.T) {
 
 	m.processBatch(context.Background())
 
-	if lastError == "" {
-		t.Error("expected SetError to be called with non-empty error")
-	}
-	if lastError != "failed to get STH: network error" {
-		t.Errorf("lastError = %q, want %q", lastError, "failed to get STH: network error")
+	if updated == nil || updated.LastProcessedIndex != 104 {
+		t.Errorf("state = %+v, want LastProcessedIndex 104 after a 4-entry read", updated)
 	}
 }
 
-func TestProcessBatch_Success_ClearsError(t *testing.T) {
-	der := selfSignedDER(t, "example.com", []string{"www.example.com"})
-	leaf := buildLeaf(t, der)
+func TestProcessBatch_AutoTunesBatchSize(t *testing.T) {
+	const served = 32
+	next := int64(100)
+	var requests [][2]int64
+	m := New(
+		&mockCTClient{
+			getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+				return &ctlog.STH{TreeSize: 10000}, nil
+			},
+			getEntriesFn: func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
+				requests = append(requests, [2]int64{start, end})
+				return make([]ctlog.RawEntry, min(end-start+1, served)), nil
+			},
+		},
+		&mockKeywordLister{
+			listFn: func(ctx context.Context) ([]model.Keyword, error) { return nil, nil },
+		},
+		&mockCertCreator{},
+		&mockStateStore{
+			getFn: func(ctx context.Context) (*model.MonitorState, error) {
+				return &model.MonitorState{LastProcessedIndex: next}, nil
+			},
+			updateFn: func(ctx context.Context, state *model.MonitorState) error {
+				next = state.LastProcessedIndex
+				return nil
+			},
+		},
+		100, time.Hour, false,
+	)
 
-	var lastError string
-	setErrorCalled := false
+	for range shortReadsToTune + 1 {
+		m.processBatch(context.Background())
+	}
+
+	if m.effectiveBatch != served {
+		t.Errorf("effectiveBatch = %d, want %d", m.effectiveBatch, served)
+	}
+	for i, r := range requests[:shortReadsToTune] {
+		if got := r[1] - r[0] + 1; got != 100 {
+			t.Errorf("request %d asked for %d entries, want 100 before tuning", i, got)
+		}
+	}
+	last := requests[len(requests)-1]
+	if got := last[1] - last[0] + 1; got != served {
+		t.Errorf("request after tuning asked for %d entries, want %d", got, served)
+	}
+	if want := int64(100 + (shortReadsToTune+1)*served); next != want {
+		t.Errorf("LastProcessedIndex = %d, want %d (no entries skipped)", next, want)
+	}
+}
+
+func TestProcessBatch_VaryingShortReadsDoNotTune(t *testing.T) {
+	sizes := []int{32, 20, 32, 20}
+	call := 0
 	m := New(
 		&mockCTClient{
 			getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
-				return &ctlog.STH{TreeSize: 200}, nil
+				return &ctlog.STH{TreeSize: 10000}, nil
 			},
 			getEntriesFn: func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
-				return []ctlog.RawEntry{{LeafInput: leaf}}, nil
+				n := sizes[call%len(sizes)]
+				call++
+				return make([]ctlog.RawEntry, n), nil
+			},
+		},
+		&mockKeywordLister{
+			listFn: func(ctx context.Context) ([]model.Keyword, error) { return nil, nil },
+		},
+		&mockCertCreator{},
+		&mockStateStore{
+			getFn: func(ctx context.Context) (*model.MonitorState, error) {
+				return &model.MonitorState{LastProcessedIndex: 100}, nil
+			},
+			updateFn: func(ctx context.Context, state *model.MonitorState) error { return nil },
+		},
+		100, time.Hour, false,
+	)
+
+	for range sizes {
+		m.processBatch(context.Background())
+	}
+
+	if m.effectiveBatch != 100 {
+		t.Errorf("effectiveBatch = %d, want 100 for inconsistent reads", m.effectiveBatch)
+	}
+}
+
+func TestBackfill_ScansRangeWithoutTouchingState(t *testing.T) {
+	leaf := buildLeaf(t, selfSignedDER(t, "example.com", nil))
+	var requested [][2]int64
+	var stored []int64
+
+	m := New(
+		&mockCTClient{
+			getEntriesFn: func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
+				requested = append(requested, [2]int64{start, end})
+				// The log serves at most 3 entries per request.
+				n := min(end-start+1, 3)
+				entries := make([]ctlog.RawEntry, n)
+				for i := range entries {
+					entries[i] = ctlog.RawEntry{LeafInput: leaf}
+				}
+				return entries, nil
 			},
 		},
 		&mockKeywordLister{
@@ -661,90 +1116,1753 @@ func TestProcessBatch_Success_ClearsError(t *testing.T) {
 		},
 		&mockCertCreator{
 			createFn: func(ctx context.Context, cert *model.MatchedCertificate) error {
+				stored = append(stored, cert.CTLogIndex)
 				return nil
 			},
 		},
 		&mockStateStore{
-			getFn: func(ctx context.Context) (*model.MonitorState, error) {
-				return &model.MonitorState{LastProcessedIndex: 100}, nil
-			},
 			updateFn: func(ctx context.Context, state *model.MonitorState) error {
-				return nil
-			},
-			setErrorFn: func(ctx context.Context, errMsg string) error {
-				setErrorCalled = true
-				lastError = errMsg
+				t.Error("Backfill updated the monitor state")
 				return nil
 			},
 		},
-		10, time.Hour, false,
+		5, time.Hour, false,
 	)
 
-	m.processBatch(context.Background())
-
-	if !setErrorCalled {
-		t.Error("expected SetError to be called to clear error")
+	stats, err := m.Backfill(context.Background(), 10, 17)
+	if err != nil {
+		t.Fatalf("Backfill() error = %v", err)
 	}
-	if lastError != "" {
-		t.Errorf("lastError = %q, want empty string (error should be cleared)", lastError)
+	if stats.Entries != 8 || stats.Matches != 8 {
+		t.Errorf("Entries/Matches = %d/%d, want 8/8", stats.Entries, stats.Matches)
+	}
+	want := [][2]int64{{10, 14}, {13, 17}, {16, 17}}
+	if len(requested) != len(want) {
+		t.Fatalf("requested %v, want %v", requested, want)
+	}
+	for i := range want {
+		if requested[i] != want[i] {
+			t.Errorf("request %d = %v, want %v", i, requested[i], want[i])
+		}
+	}
+	for i, idx := range stored {
+		if idx != int64(10+i) {
+			t.Errorf("stored[%d] ct_log_index = %d, want %d", i, idx, 10+i)
+		}
 	}
 }
 
-// --- panic recovery tests ---
-
-func TestRun_PanicRecovery(t *testing.T) {
-	setRunningCalled := make(chan bool, 1)
-	var panicError string
+func TestBackfill_AgainstFakeLog(t *testing.T) {
+	log := fakectlog.New(fakectlog.WithTreeSize(40), fakectlog.WithPageSize(16),
+		fakectlog.WithKeywords("paypal"), fakectlog.WithMatchEvery(7), fakectlog.WithMalformedEvery(10))
+	srv := httptest.NewServer(log)
+	defer srv.Close()
 
-	ss := &mockStateStore{
-		setRunningFn: func(ctx context.Context, running bool) error {
-			if !running {
-				setRunningCalled <- running
-			}
-			return nil
+	var stored []int64
+	m := New(
+		ctlog.NewClient(srv.URL),
+		&mockKeywordLister{
+			listFn: func(ctx context.Context) ([]model.Keyword, error) {
+				return []model.Keyword{{ID: 1, Value: "paypal"}}, nil
+			},
 		},
-		setErrorFn: func(ctx context.Context, errMsg string) error {
-			panicError = errMsg
-			return nil
+		&mockCertCreator{
+			createFn: func(ctx context.Context, cert *model.MatchedCertificate) error {
+				cert.ID = len(stored) + 1
+				stored = append(stored, cert.CTLogIndex)
+				return nil
+			},
 		},
+		&mockStateStore{},
+		50, time.Hour, false,
+	)
+
+	stats, err := m.Backfill(context.Background(), 0, 39)
+	if err != nil {
+		t.Fatalf("Backfill() error = %v", err)
+	}
+	var want []int64
+	for i := range int64(40) {
+		if log.Matching(i) {
+			want = append(want, i)
+		}
+	}
+	if stats.Entries != 40 || stats.ParseErrors != 4 {
+		t.Errorf("Entries/ParseErrors = %d/%d, want 40/4", stats.Entries, stats.ParseErrors)
+	}
+	if !slices.Equal(stored, want) {
+		t.Errorf("stored indices = %v, want %v", stored, want)
 	}
+}
 
-	ct := &mockCTClient{
-		getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
-			panic("test panic in processBatch")
+func TestBackfill_Errors(t *testing.T) {
+	kw := &mockKeywordLister{listFn: func(ctx context.Context) ([]model.Keyword, error) {
+		return []model.Keyword{{ID: 1, Value: "example"}}, nil
+	}}
+	tests := []struct {
+		name       string
+		start, end int64
+		keywords   *mockKeywordLister
+		entries    func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error)
+		want       string
+	}{
+		{"inverted range", 10, 5, kw, nil, "invalid range"},
+		{"no keywords", 0, 5, &mockKeywordLister{listFn: func(ctx context.Context) ([]model.Keyword, error) { return nil, nil }}, nil, "no keywords"},
+		{"fetch error", 0, 5, kw, func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
+			return nil, errors.New("HTTP 400")
+		}, "get entries 0-5: HTTP 400"},
+		{"empty page", 0, 5, kw, func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
+			return nil, nil
+		}, "no entries at index 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New(&mockCTClient{getEntriesFn: tt.entries}, tt.keywords, &mockCertCreator{}, &mockStateStore{}, 10, time.Hour, false)
+			_, err := m.Backfill(context.Background(), tt.start, tt.end)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Backfill() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTick_ReportsFailedCycle(t *testing.T) {
+	rec := &recordingMetrics{}
+	m := New(
+		&mockCTClient{
+			getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+				return nil, errors.New("network error")
+			},
 		},
+		&mockKeywordLister{},
+		&mockCertCreator{},
+		&mockStateStore{},
+		10, time.Hour, false,
+		WithMetrics(rec),
+	)
+
+	m.tick(context.Background())
+
+	if len(rec.cycles) != 1 || !rec.cycles[0].Failed {
+		t.Errorf("cycles = %+v, want one failed cycle", rec.cycles)
 	}
+}
 
-	m := New(ct, &mockKeywordLister{}, &mockCertCreator{}, ss, 10, time.Hour, false)
-	// Manually set cancel so we can verify it gets cleared
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	m.cancel = cancel
+func TestTick_SetErrorFailureLoggedAndCounted(t *testing.T) {
+	var logs bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	rec := &recordingMetrics{}
+	m := New(
+		&mockCTClient{
+			getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+				return nil, errors.New("network error")
+			},
+		},
+		&mockKeywordLister{},
+		&mockCertCreator{},
+		&mockStateStore{
+			setErrorFn: func(ctx context.Context, errMsg string) error {
+				return errors.New("connection refused")
+			},
+		},
+		10, time.Hour, false,
+		WithMetrics(rec),
+	)
 
-	go m.run(ctx)
+	m.tick(context.Background())
+	m.tick(context.Background())
 
-	select {
-	case running := <-setRunningCalled:
-		if running {
-			t.Error("expected SetRunning(false) after panic")
+	if len(rec.cycles) != 2 || rec.cycles[0].StateErrors != 1 || rec.cycles[1].StateErrors != 1 {
+		t.Errorf("cycles = %+v, want one state error per cycle", rec.cycles)
+	}
+	var failures []map[string]any
+	for _, line := range bytes.Split(bytes.TrimSpace(logs.Bytes()), []byte("\n")) {
+		var entry map[string]any
+		if err := json.Unmarshal(line, &entry); err != nil {
+			t.Fatalf("log line %q: %v", line, err)
+		}
+		if entry["msg"] == "failed to record monitor error" {
+			failures = append(failures, entry)
 		}
-	case <-time.After(2 * time.Second):
-		t.Fatal("timed out waiting for panic recovery to call SetRunning(false)")
 	}
-
-	// Verify cancel was cleared
-	m.mu.Lock()
-	cancelNil := m.cancel == nil
-	m.mu.Unlock()
-	if !cancelNil {
-		t.Error("expected m.cancel to be nil after panic recovery")
+	if len(failures) != 2 {
+		t.Fatalf("logged %d SetError failures, want 2: %s", len(failures), logs.String())
 	}
+	got := failures[1]
+	if got["level"] != "ERROR" || got["error"] != "connection refused" ||
+		got["monitor_error"] != "failed to get STH: network error" || got["consecutive_failures"] != float64(2) {
+		t.Errorf("failure log = %v", got)
+	}
+}
 
-	if panicError == "" {
-		t.Error("expected SetError to be called with panic message")
+func TestSetError_SuccessResetsFailureCount(t *testing.T) {
+	fail := true
+	m := New(&mockCTClient{}, &mockKeywordLister{}, &mockCertCreator{}, &mockStateStore{
+		setErrorFn: func(ctx context.Context, errMsg string) error {
+			if fail {
+				return errors.New("connection refused")
+			}
+			return nil
+		},
+	}, 10, time.Hour, false)
+
+	var stats CycleStats
+	m.setError(context.Background(), &stats, "boom")
+	m.setError(context.Background(), nil, "boom")
+	if m.setErrorFailures != 2 || stats.StateErrors != 1 {
+		t.Errorf("failures/StateErrors = %d/%d, want 2/1", m.setErrorFailures, stats.StateErrors)
 	}
-	if panicError != "panic: test panic in processBatch" {
-		t.Errorf("panicError = %q, want %q", panicError, "panic: test panic in processBatch")
+	fail = false
+	m.setError(context.Background(), &stats, "")
+	if m.setErrorFailures != 0 || stats.StateErrors != 1 {
+		t.Errorf("after success failures/StateErrors = %d/%d, want 0/1", m.setErrorFailures, stats.StateErrors)
+	}
+}
+
+func TestProcessBatch_STHError(t *testing.T) {
+	stateCalled := false
+	m := New(
+		&mockCTClient{
+			getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+				return nil, errors.New("network error")
+			},
+		},
+		&mockKeywordLister{},
+		&mockCertCreator{},
+		&mockStateStore{
+			getFn: func(ctx context.Context) (*model.MonitorState, error) {
+				stateCalled = true
+				return nil, nil
+			},
+		},
+		10, time.Hour, false,
+	)
+
+	m.processBatch(context.Background())
+
+	if stateCalled {
+		t.Error("state.Get should not be called when STH fails")
 	}
 }
 
+func TestProcessBatch_StateGetError(t *testing.T) {
+	entriesCalled := false
+	m := New(
+		&mockCTClient{
+			getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+				return &ctlog.STH{TreeSize: 200}, nil
+			},
+			getEntriesFn: func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
+				entriesCalled = true
+				return nil, nil
+			},
+		},
+		&mockKeywordLister{},
+		&mockCertCreator{},
+		&mockStateStore{
+			getFn: func(ctx context.Context) (*model.MonitorState, error) {
+				return nil, errors.New("db error")
+			},
+		},
+		10, time.Hour, false,
+	)
+
+	m.processBatch(context.Background())
+
+	if entriesCalled {
+		t.Error("GetEntries should not be called when state.Get fails")
+	}
+}
+
+func TestProcessBatch_NoNewEntries(t *testing.T) {
+	entriesCalled := false
+	var updatedState *model.MonitorState
+	m := New(
+		&mockCTClient{
+			getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+				return &ctlog.STH{TreeSize: 100}, nil
+			},
+			getEntriesFn: func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
+				entriesCalled = true
+				return nil, nil
+			},
+		},
+		&mockKeywordLister{},
+		&mockCertCreator{},
+		&mockStateStore{
+			getFn: func(ctx context.Context) (*model.MonitorState, error) {
+				// Already processed up to tree size
+				return &model.MonitorState{LastProcessedIndex: 100}, nil
+			},
+			updateFn: func(ctx context.Context, state *model.MonitorState) error {
+				updatedState = state
+				return nil
+			},
+		},
+		10, time.Hour, false, // reprocessOnIdle=false
+	)
+
+	m.processBatch(context.Background())
+
+	if entriesCalled {
+		t.Error("GetEntries should not be called when start > end")
+	}
+
+	// State SHOULD be updated to refresh last_run_at
+	if updatedState == nil {
+		t.Fatal("state should be updated even when no new entries (to update last_run_at)")
+	}
+	if updatedState.LastProcessedIndex != 100 {
+		t.Errorf("LastProcessedIndex = %d, want 100 (unchanged)", updatedState.LastProcessedIndex)
+	}
+}
+
+func TestProcessBatch_EmptyLog(t *testing.T) {
+	entriesCalled := false
+	var updatedState *model.MonitorState
+	var lastErr *string
+	m := New(
+		&mockCTClient{
+			getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+				return &ctlog.STH{TreeSize: 0}, nil
+			},
+			getEntriesFn: func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
+				entriesCalled = true
+				return nil, nil
+			},
+		},
+		&mockKeywordLister{},
+		&mockCertCreator{},
+		&mockStateStore{
+			getFn: func(ctx context.Context) (*model.MonitorState, error) {
+				return &model.MonitorState{}, nil
+			},
+			updateFn: func(ctx context.Context, state *model.MonitorState) error {
+				updatedState = state
+				return nil
+			},
+			setErrorFn: func(ctx context.Context, errMsg string) error {
+				lastErr = &errMsg
+				return nil
+			},
+		},
+		10, time.Hour, true, // reprocessOnIdle must not trigger a fetch either
+	)
+
+	m.processBatch(context.Background())
+
+	if entriesCalled {
+		t.Error("GetEntries should not be called for an empty log")
+	}
+	if updatedState == nil {
+		t.Fatal("state should be updated for an empty log (to update last_run_at)")
+	}
+	if updatedState.LastProcessedIndex != 0 || updatedState.LastTreeSize != 0 {
+		t.Errorf("state = %+v, want zero index and tree size", updatedState)
+	}
+	if !updatedState.IsRunning {
+		t.Error("IsRunning = false, want true")
+	}
+	if lastErr == nil || *lastErr != "" {
+		t.Errorf("SetError = %v, want cleared error", lastErr)
+	}
+}
+
+func TestProcessBatch_NoKeywords(t *testing.T) {
+	var updatedState *model.MonitorState
+	certCreated := false
+
+	m := New(
+		&mockCTClient{
+			getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+				return &ctlog.STH{TreeSize: 200}, nil
+			},
+			getEntriesFn: func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
+				return []ctlog.RawEntry{{LeafInput: []byte("dummy")}}, nil
+			},
+		},
+		&mockKeywordLister{
+			listFn: func(ctx context.Context) ([]model.Keyword, error) {
+				return nil, nil // no keywords
+			},
+		},
+		&mockCertCreator{
+			createFn: func(ctx context.Context, cert *model.MatchedCertificate) error {
+				certCreated = true
+				return nil
+			},
+		},
+		&mockStateStore{
+			getFn: func(ctx context.Context) (*model.MonitorState, error) {
+				return &model.MonitorState{LastProcessedIndex: 100}, nil
+			},
+			updateFn: func(ctx context.Context, state *model.MonitorState) error {
+				updatedState = state
+				return nil
+			},
+		},
+		10, time.Hour, false,
+	)
+
+	m.processBatch(context.Background())
+
+	if certCreated {
+		t.Error("no certs should be stored when there are no keywords")
+	}
+	if updatedState == nil {
+		t.Fatal("state should still be updated when no keywords")
+	}
+	if updatedState.MatchesInLastCycle != 0 {
+		t.Errorf("MatchesInLastCycle = %d, want 0", updatedState.MatchesInLastCycle)
+	}
+}
+
+func TestProcessBatch_ParseErrorSkipped(t *testing.T) {
+	der := selfSignedDER(t, "example.com", nil)
+	goodLeaf := buildLeaf(t, der)
+	badLeaf := buildLeaf(t, []byte{0xDE, 0xAD}) // invalid DER
+
+	createCount := 0
+	m := New(
+		&mockCTClient{
+			getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+				return &ctlog.STH{TreeSize: 200}, nil
+			},
+			getEntriesFn: func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
+				return []ctlog.RawEntry{
+					{LeafInput: badLeaf},
+					{LeafInput: goodLeaf},
+				}, nil
+			},
+		},
+		&mockKeywordLister{
+			listFn: func(ctx context.Context) ([]model.Keyword, error) {
+				return []model.Keyword{{ID: 1, Value: "example"}}, nil
+			},
+		},
+		&mockCertCreator{
+			createFn: func(ctx context.Context, cert *model.MatchedCertificate) error {
+				createCount++
+				return nil
+			},
+		},
+		&mockStateStore{
+			getFn: func(ctx context.Context) (*model.MonitorState, error) {
+				return &model.MonitorState{LastProcessedIndex: 100}, nil
+			},
+			updateFn: func(ctx context.Context, state *model.MonitorState) error { return nil },
+		},
+		10, time.Hour, false,
+	)
+
+	m.processBatch(context.Background())
+
+	if createCount != 1 {
+		t.Errorf("createCount = %d, want 1 (bad entry should be skipped)", createCount)
+	}
+}
+
+func TestProcessBatch_CertStoreError(t *testing.T) {
+	der := selfSignedDER(t, "example.com", nil)
+	leaf := buildLeaf(t, der)
+
+	var updatedState *model.MonitorState
+	m := New(
+		&mockCTClient{
+			getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+				return &ctlog.STH{TreeSize: 200}, nil
+			},
+			getEntriesFn: func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
+				return []ctlog.RawEntry{{LeafInput: leaf}}, nil
+			},
+		},
+		&mockKeywordLister{
+			listFn: func(ctx context.Context) ([]model.Keyword, error) {
+				return []model.Keyword{{ID: 1, Value: "example"}}, nil
+			},
+		},
+		&mockCertCreator{
+			createFn: func(ctx context.Context, cert *model.MatchedCertificate) error {
+				return errors.New("insert failed")
+			},
+		},
+		&mockStateStore{
+			getFn: func(ctx context.Context) (*model.MonitorState, error) {
+				return &model.MonitorState{LastProcessedIndex: 100}, nil
+			},
+			updateFn: func(ctx context.Context, state *model.MonitorState) error {
+				updatedState = state
+				return nil
+			},
+		},
+		10, time.Hour, false,
+	)
+
+	m.processBatch(context.Background())
+
+	if updatedState == nil {
+		t.Fatal("state should still be updated even when cert store fails")
+	}
+	if updatedState.MatchesInLastCycle != 0 {
+		t.Errorf("MatchesInLastCycle = %d, want 0 (store failed)", updatedState.MatchesInLastCycle)
+	}
+}
+
+func TestProcessBatch_FirstBatch_StartsNearTreeSize(t *testing.T) {
+	var requestedStart int64
+
+	m := New(
+		&mockCTClient{
+			getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+				return &ctlog.STH{TreeSize: 1000}, nil
+			},
+			getEntriesFn: func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
+				requestedStart = start
+				return nil, nil
+			},
+		},
+		&mockKeywordLister{
+			listFn: func(ctx context.Context) ([]model.Keyword, error) {
+				return nil, nil
+			},
+		},
+		&mockCertCreator{},
+		&mockStateStore{
+			getFn: func(ctx context.Context) (*model.MonitorState, error) {
+				return &model.MonitorState{LastProcessedIndex: 0}, nil // fresh start
+			},
+			updateFn: func(ctx context.Context, state *model.MonitorState) error { return nil },
+		},
+		50, time.Hour, false,
+	)
+
+	m.processBatch(context.Background())
+
+	// When LastProcessedIndex is 0, start = max(0, TreeSize - batchSize) = 950
+	if requestedStart != 950 {
+		t.Errorf("start = %d, want 950 (TreeSize 1000 - batchSize 50)", requestedStart)
+	}
+}
+
+// --- error persistence tests ---
+
+func TestProcessBatch_STHError_PersistsError(t *testing.T) {
+	var lastError string
+	m := New(
+		&mockCTClient{
+			getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+				return nil, errors.New("network error")
+			},
+		},
+		&mockKeywordLister{},
+		&mockCertCreator{},
+		&mockStateStore{
+			getFn: func(ctx context.Context) (*model.MonitorState, error) {
+				return nil, nil
+			},
+			setErrorFn: func(ctx context.Context, errMsg string) error {
+				lastError = errMsg
+				return nil
+			},
+		},
+		10, time.Hour, false,
+	)
+
+	m.processBatch(context.Background())
+
+	if lastError == "" {
+		t.Error("expected SetError to be called with non-empty error")
+	}
+	if lastError != "failed to get STH: network error" {
+		t.Errorf("lastError = %q, want %q", lastError, "failed to get STH: network error")
+	}
+}
+
+type recordingPublisher struct {
+	published []model.MatchedCertificate
+}
+
+func (p *recordingPublisher) Publish(cert model.MatchedCertificate) {
+	p.published = append(p.published, cert)
+}
+
+func TestProcessBatch_PublishesOnlyNewMatches(t *testing.T) {
+	newLeaf := buildLeaf(t, selfSignedDER(t, "new.example.com", nil))
+	dupLeaf := buildLeaf(t, selfSignedDER(t, "dup.example.com", nil))
+	pub := &recordingPublisher{}
+
+	m := New(
+		&mockCTClient{
+			getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+				return &ctlog.STH{TreeSize: 200}, nil
+			},
+			getEntriesFn: func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
+				return []ctlog.RawEntry{{LeafInput: newLeaf}, {LeafInput: dupLeaf}}, nil
+			},
+		},
+		&mockKeywordLister{
+			listFn: func(ctx context.Context) ([]model.Keyword, error) {
+				return []model.Keyword{{ID: 1, Value: "example"}}, nil
+			},
+		},
+		&mockCertCreator{
+			createFn: func(ctx context.Context, cert *model.MatchedCertificate) error {
+				// Mirror the repository: only a fresh insert gets an ID.
+				if cert.CommonName == "new.example.com" {
+					cert.ID = 7
+				}
+				return nil
+			},
+		},
+		&mockStateStore{
+			getFn: func(ctx context.Context) (*model.MonitorState, error) {
+				return &model.MonitorState{LastProcessedIndex: 100}, nil
+			},
+			updateFn: func(ctx context.Context, state *model.MonitorState) error { return nil },
+		},
+		10, time.Hour, false,
+		WithPublisher(pub),
+	)
+
+	m.processBatch(context.Background())
+
+	if len(pub.published) != 1 || pub.published[0].ID != 7 {
+		t.Errorf("published = %+v, want only the new match (ID 7)", pub.published)
+	}
+}
+
+func TestProcessBatch_LogMatches(t *testing.T) {
+	newLeaf := buildLeaf(t, selfSignedDER(t, "new.example.com", nil))
+	dupLeaf := buildLeaf(t, selfSignedDER(t, "dup.example.com", nil))
+	var logs bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	m := New(
+		&mockCTClient{
+			getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+				return &ctlog.STH{TreeSize: 200}, nil
+			},
+			getEntriesFn: func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
+				return []ctlog.RawEntry{{LeafInput: newLeaf}, {LeafInput: dupLeaf}}, nil
+			},
+		},
+		&mockKeywordLister{
+			listFn: func(ctx context.Context) ([]model.Keyword, error) {
+				return []model.Keyword{{ID: 1, Value: "example"}}, nil
+			},
+		},
+		&mockCertCreator{
+			createFn: func(ctx context.Context, cert *model.MatchedCertificate) error {
+				if cert.CommonName == "new.example.com" {
+					cert.ID = 7
+				}
+				return nil
+			},
+		},
+		&mockStateStore{
+			getFn: func(ctx context.Context) (*model.MonitorState, error) {
+				return &model.MonitorState{LastProcessedIndex: 100}, nil
+			},
+			updateFn: func(ctx context.Context, state *model.MonitorState) error { return nil },
+		},
+		10, time.Hour, false,
+		WithLogMatches(true),
+	)
+
+	m.processBatch(context.Background())
+
+	var matched []map[string]any
+	for _, line := range bytes.Split(bytes.TrimSpace(logs.Bytes()), []byte("\n")) {
+		var rec map[string]any
+		if err := json.Unmarshal(line, &rec); err != nil {
+			t.Fatalf("log line %q: %v", line, err)
+		}
+		if rec["msg"] == "certificate matched" {
+			matched = append(matched, rec)
+		}
+	}
+	if len(matched) != 1 {
+		t.Fatalf("logged %d matches, want only the new one: %s", len(matched), logs.String())
+	}
+	got := matched[0]
+	if got["level"] != "INFO" || got["keyword"] != "example" || got["matched_domain"] != "new.example.com" ||
+		got["ct_log_index"] != float64(100) {
+		t.Errorf("match log = %v", got)
+	}
+	for _, key := range []string{"serial", "issuer"} {
+		if v, _ := got[key].(string); v == "" {
+			t.Errorf("match log is missing %s: %v", key, got)
+		}
+	}
+}
+
+func TestProcessBatch_LogMatchesOffByDefault(t *testing.T) {
+	leaf := buildLeaf(t, selfSignedDER(t, "new.example.com", nil))
+	var logs bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	m := New(
+		&mockCTClient{
+			getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+				return &ctlog.STH{TreeSize: 200}, nil
+			},
+			getEntriesFn: func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
+				return []ctlog.RawEntry{{LeafInput: leaf}}, nil
+			},
+		},
+		&mockKeywordLister{
+			listFn: func(ctx context.Context) ([]model.Keyword, error) {
+				return []model.Keyword{{ID: 1, Value: "example"}}, nil
+			},
+		},
+		&mockCertCreator{
+			createFn: func(ctx context.Context, cert *model.MatchedCertificate) error {
+				cert.ID = 1
+				return nil
+			},
+		},
+		&mockStateStore{
+			getFn: func(ctx context.Context) (*model.MonitorState, error) {
+				return &model.MonitorState{LastProcessedIndex: 100}, nil
+			},
+			updateFn: func(ctx context.Context, state *model.MonitorState) error { return nil },
+		},
+		10, time.Hour, false,
+	)
+
+	m.processBatch(context.Background())
+
+	if bytes.Contains(logs.Bytes(), []byte("certificate matched")) {
+		t.Errorf("match logged without WithLogMatches: %s", logs.String())
+	}
+}
+
+func TestProcessBatch_Success_ClearsError(t *testing.T) {
+	der := selfSignedDER(t, "example.com", []string{"www.example.com"})
+	leaf := buildLeaf(t, der)
+
+	var lastError string
+	setErrorCalled := false
+	m := New(
+		&mockCTClient{
+			getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+				return &ctlog.STH{TreeSize: 200}, nil
+			},
+			getEntriesFn: func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
+				return []ctlog.RawEntry{{LeafInput: leaf}}, nil
+			},
+		},
+		&mockKeywordLister{
+			listFn: func(ctx context.Context) ([]model.Keyword, error) {
+				return []model.Keyword{{ID: 1, Value: "example"}}, nil
+			},
+		},
+		&mockCertCreator{
+			createFn: func(ctx context.Context, cert *model.MatchedCertificate) error {
+				return nil
+			},
+		},
+		&mockStateStore{
+			getFn: func(ctx context.Context) (*model.MonitorState, error) {
+				return &model.MonitorState{LastProcessedIndex: 100}, nil
+			},
+			updateFn: func(ctx context.Context, state *model.MonitorState) error {
+				return nil
+			},
+			setErrorFn: func(ctx context.Context, errMsg string) error {
+				setErrorCalled = true
+				lastError = errMsg
+				return nil
+			},
+		},
+		10, time.Hour, false,
+	)
+
+	m.processBatch(context.Background())
+
+	if !setErrorCalled {
+		t.Error("expected SetError to be called to clear error")
+	}
+	if lastError != "" {
+		t.Errorf("lastError = %q, want empty string (error should be cleared)", lastError)
+	}
+}
+
+// --- panic recovery tests ---
+
+func TestRun_StopsAfterMaxCycles(t *testing.T) {
+	var mu sync.Mutex
+	var cycles int
+	var running []bool
+	ss := &mockStateStore{
+		setRunningFn: func(ctx context.Context, r bool) error {
+			mu.Lock()
+			defer mu.Unlock()
+			running = append(running, r)
+			return nil
+		},
+		getFn: func(ctx context.Context) (*model.MonitorState, error) {
+			return nil, errors.New("stub")
+		},
+	}
+	ct := &mockCTClient{
+		getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			cycles++
+			return nil, errors.New("stub")
+		},
+	}
+	m := New(ct, &mockKeywordLister{}, &mockCertCreator{}, ss, 10, time.Millisecond, false,
+		WithMaxCycles(3),
+	)
+
+	if err := m.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	select {
+	case <-m.Done():
+	case <-time.After(2 * time.Second):
+		m.Stop(context.Background())
+		t.Fatal("monitor did not stop after 3 cycles")
+	}
+
+	if m.IsRunning() {
+		t.Error("IsRunning() = true after the cycle limit")
+	}
+	// Give a stray tick a chance to show up before counting.
+	time.Sleep(10 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if cycles != 3 {
+		t.Errorf("ran %d cycles, want 3", cycles)
+	}
+	if len(running) != 2 || !running[0] || running[1] {
+		t.Errorf("SetRunning calls = %v, want [true false]", running)
+	}
+}
+
+func TestDone_OpenWithoutLimit(t *testing.T) {
+	m := New(&mockCTClient{}, &mockKeywordLister{}, &mockCertCreator{}, &mockStateStore{}, 10, time.Hour, false)
+
+	select {
+	case <-m.Done():
+		t.Error("Done() closed on a monitor without a cycle limit")
+	default:
+	}
+}
+
+func TestRun_PanicRecovery(t *testing.T) {
+	setRunningCalled := make(chan bool, 1)
+	var panicError string
+
+	ss := &mockStateStore{
+		setRunningFn: func(ctx context.Context, running bool) error {
+			if !running {
+				setRunningCalled <- running
+			}
+			return nil
+		},
+		setErrorFn: func(ctx context.Context, errMsg string) error {
+			panicError = errMsg
+			return nil
+		},
+	}
+
+	ct := &mockCTClient{
+		getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+			panic("test panic in processBatch")
+		},
+	}
+
+	m := New(ct, &mockKeywordLister{}, &mockCertCreator{}, ss, 10, time.Hour, false)
+	// Manually set cancel so we can verify it gets cleared
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m.cancel = cancel
+
+	go m.run(ctx, nil)
+
+	select {
+	case running := <-setRunningCalled:
+		if running {
+			t.Error("expected SetRunning(false) after panic")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for panic recovery to call SetRunning(false)")
+	}
+
+	// Verify cancel was cleared
+	m.mu.Lock()
+	cancelNil := m.cancel == nil
+	m.mu.Unlock()
+	if !cancelNil {
+		t.Error("expected m.cancel to be nil after panic recovery")
+	}
+
+	if panicError == "" {
+		t.Error("expected SetError to be called with panic message")
+	}
+	if panicError != "panic: test panic in processBatch" {
+		t.Errorf("panicError = %q, want %q", panicError, "panic: test panic in processBatch")
+	}
+}
+
+
+type stubVerifier struct {
+	verified []string
+}
+
+func (v *stubVerifier) Verify(cert *ctlog.ParsedCertificate) string {
+	v.verified = append(v.verified, cert.CommonName)
+	return model.ChainUnknownIssuer
+}
+
+func TestTick_SetsChainStatusOnMatches(t *testing.T) {
+	matched := buildLeaf(t, selfSignedDER(t, "login.example.com", nil))
+	unmatched := buildLeaf(t, selfSignedDER(t, "other.test", nil))
+	verifier := &stubVerifier{}
+	var stored []model.MatchedCertificate
+
+	newMonitor := func(opts ...Option) *Monitor {
+		return New(
+			&mockCTClient{
+				getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+					return &ctlog.STH{TreeSize: 200}, nil
+				},
+				getEntriesFn: func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
+					return []ctlog.RawEntry{{LeafInput: matched}, {LeafInput: unmatched}}, nil
+				},
+			},
+			&mockKeywordLister{
+				listFn: func(ctx context.Context) ([]model.Keyword, error) {
+					return []model.Keyword{{ID: 1, Value: "example"}}, nil
+				},
+			},
+			&mockCertCreator{
+				createFn: func(ctx context.Context, cert *model.MatchedCertificate) error {
+					stored = append(stored, *cert)
+					return nil
+				},
+			},
+			&mockStateStore{
+				getFn: func(ctx context.Context) (*model.MonitorState, error) {
+					return &model.MonitorState{LastProcessedIndex: 100}, nil
+				},
+				updateFn: func(ctx context.Context, state *model.MonitorState) error { return nil },
+			},
+			10, time.Hour, false, opts...)
+	}
+
+	newMonitor(WithChainVerifier(verifier)).tick(context.Background())
+	if len(verifier.verified) != 1 || verifier.verified[0] != "login.example.com" {
+		t.Errorf("verified %v, want only the matched certificate", verifier.verified)
+	}
+	if len(stored) != 1 || stored[0].ChainStatus != model.ChainUnknownIssuer {
+		t.Fatalf("stored %+v, want one match with the verifier's status", stored)
+	}
+	// Self-signed, so issued by "login.example.com", which no rule covers.
+	if stored[0].IssuerClass != model.IssuerUnknown {
+		t.Errorf("IssuerClass = %q, want unknown", stored[0].IssuerClass)
+	}
+
+	stored = nil
+	newMonitor().tick(context.Background())
+	if len(stored) != 1 || stored[0].ChainStatus != model.ChainNotChecked {
+		t.Errorf("without a verifier stored %+v, want one not_checked match", stored)
+	}
+}
+
+func TestTick_IgnoreCNAndCNLessMatches(t *testing.T) {
+	cnOnly := buildLeaf(t, selfSignedDER(t, "login.example.com", []string{"cdn.other.net"}))
+	sanOnly := buildLeaf(t, selfSignedDER(t, "", []string{"shop.example.com"}))
+
+	run := func(opts ...Option) (stored []model.MatchedCertificate, stats CycleStats) {
+		rec := &recordingMetrics{}
+		New(
+			&mockCTClient{
+				getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+					return &ctlog.STH{TreeSize: 200}, nil
+				},
+				getEntriesFn: func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
+					return []ctlog.RawEntry{{LeafInput: cnOnly}, {LeafInput: sanOnly}}, nil
+				},
+			},
+			&mockKeywordLister{
+				listFn: func(ctx context.Context) ([]model.Keyword, error) {
+					return []model.Keyword{{ID: 1, Value: "example"}}, nil
+				},
+			},
+			&mockCertCreator{
+				createFn: func(ctx context.Context, cert *model.MatchedCertificate) error {
+					stored = append(stored, *cert)
+					return nil
+				},
+			},
+			&mockStateStore{
+				getFn: func(ctx context.Context) (*model.MonitorState, error) {
+					return &model.MonitorState{LastProcessedIndex: 100}, nil
+				},
+				updateFn: func(ctx context.Context, state *model.MonitorState) error { return nil },
+			},
+			10, time.Hour, false, append(opts, WithMetrics(rec))...,
+		).tick(context.Background())
+		if len(rec.cycles) != 1 {
+			t.Fatalf("ObserveCycle called %d times, want 1", len(rec.cycles))
+		}
+		return stored, rec.cycles[0]
+	}
+
+	stored, stats := run()
+	if len(stored) != 2 || stats.Matches != 2 || stats.CNLessMatches != 1 {
+		t.Errorf("default: stored %d, Matches/CNLessMatches = %d/%d, want 2, 2/1",
+			len(stored), stats.Matches, stats.CNLessMatches)
+	}
+
+	stored, stats = run(WithIgnoreCN(true))
+	if len(stored) != 1 || stored[0].MatchedDomain != "shop.example.com" || stored[0].MatchedField != model.MatchFieldSAN {
+		t.Errorf("IgnoreCN: stored %+v, want only the SAN match", stored)
+	}
+	if stats.Matches != 1 || stats.CNLessMatches != 1 {
+		t.Errorf("IgnoreCN: Matches/CNLessMatches = %d/%d, want 1/1", stats.Matches, stats.CNLessMatches)
+	}
+}
+
+func TestMatchableKeywords_MuteBoundaries(t *testing.T) {
+	until := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	keywords := []model.Keyword{
+		{ID: 1, Value: "matching", MutedUntil: &until, MuteScope: model.MuteScopeMatching},
+		{ID: 2, Value: "notifications", MutedUntil: &until, MuteScope: model.MuteScopeNotifications},
+		{ID: 3, Value: "unmuted"},
+	}
+	ids := func(kws []model.Keyword) []int {
+		var out []int
+		for _, kw := range kws {
+			out = append(out, kw.ID)
+		}
+		return out
+	}
+	tests := []struct {
+		name string
+		now  time.Time
+		want []int
+	}{
+		{"well before", until.Add(-time.Hour), []int{2, 3}},
+		{"just before", until.Add(-time.Nanosecond), []int{2, 3}},
+		{"at until", until, []int{1, 2, 3}},
+		{"after", until.Add(time.Hour), []int{1, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ids(matchableKeywords(keywords, tt.now)); !slices.Equal(got, tt.want) {
+				t.Errorf("matchable = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if len(keywords) != 3 {
+		t.Error("matchableKeywords modified its input")
+	}
+}
+
+func TestBackfill_SkipsKeywordsMutedFromMatching(t *testing.T) {
+	leaf := buildLeaf(t, selfSignedDER(t, "example.com", nil))
+	until := time.Now().Add(time.Hour)
+	var matched []int
+
+	m := New(
+		&mockCTClient{
+			getEntriesFn: func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
+				return []ctlog.RawEntry{{LeafInput: leaf}}, nil
+			},
+		},
+		&mockKeywordLister{
+			listFn: func(ctx context.Context) ([]model.Keyword, error) {
+				return []model.Keyword{
+					{ID: 1, Value: "example", MutedUntil: &until, MuteScope: model.MuteScopeMatching},
+					{ID: 2, Value: "exam", MutedUntil: &until, MuteScope: model.MuteScopeNotifications},
+				}, nil
+			},
+		},
+		&mockCertCreator{
+			createFn: func(ctx context.Context, cert *model.MatchedCertificate) error {
+				matched = append(matched, cert.KeywordID)
+				return nil
+			},
+		},
+		&mockStateStore{},
+		10, time.Hour, false,
+	)
+
+	if _, err := m.Backfill(context.Background(), 0, 0); err != nil {
+		t.Fatalf("Backfill() error = %v", err)
+	}
+	if !slices.Equal(matched, []int{2}) {
+		t.Errorf("matched keywords %v, want only the notification-muted one", matched)
+	}
+}
+
+type mockKeywordDisabler struct {
+	disabled map[int]string
+	err      error
+}
+
+func (m *mockKeywordDisabler) Disable(ctx context.Context, id int, reason string) (*model.Keyword, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	if m.disabled == nil {
+		m.disabled = make(map[int]string)
+	}
+	m.disabled[id] = reason
+	now := time.Now()
+	return &model.Keyword{ID: id, DisabledAt: &now, DisabledReason: reason}, nil
+}
+
+// runawayMonitor returns a monitor over a log whose every batch holds four
+// certificates for example.com, one of which also names rare.net, and a
+// keyword list that reflects what d has disabled.
+func runawayMonitor(t *testing.T, d *mockKeywordDisabler, rec *recordingMetrics, opts ...Option) *Monitor {
+	t.Helper()
+	common := buildLeaf(t, selfSignedDER(t, "example.com", nil))
+	rare := buildLeaf(t, selfSignedDER(t, "example.com", []string{"rare.net"}))
+	var next int64 = 100
+	return New(
+		&mockCTClient{
+			getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+				return &ctlog.STH{TreeSize: 1000}, nil
+			},
+			getEntriesFn: func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
+				return []ctlog.RawEntry{{LeafInput: common}, {LeafInput: common}, {LeafInput: common}, {LeafInput: rare}}, nil
+			},
+		},
+		&mockKeywordLister{
+			listFn: func(ctx context.Context) ([]model.Keyword, error) {
+				keywords := []model.Keyword{{ID: 1, Value: "example"}, {ID: 2, Value: "rare"}}
+				for i, kw := range keywords {
+					if reason, ok := d.disabled[kw.ID]; ok {
+						now := time.Now()
+						keywords[i].DisabledAt, keywords[i].DisabledReason = &now, reason
+					}
+				}
+				return keywords, nil
+			},
+		},
+		&mockCertCreator{
+			createFn: func(ctx context.Context, cert *model.MatchedCertificate) error { return nil },
+		},
+		&mockStateStore{
+			getFn: func(ctx context.Context) (*model.MonitorState, error) {
+				return &model.MonitorState{LastProcessedIndex: next}, nil
+			},
+			updateFn: func(ctx context.Context, state *model.MonitorState) error {
+				next = state.LastProcessedIndex
+				return nil
+			},
+		},
+		4, time.Hour, false,
+		append([]Option{WithMetrics(rec)}, opts...)...,
+	)
+}
+
+func TestTick_DisablesRunawayKeyword(t *testing.T) {
+	d := &mockKeywordDisabler{}
+	rec := &recordingMetrics{}
+	m := runawayMonitor(t, d, rec, WithRunawayKeywords(d, 50, 3))
+
+	for range 2 {
+		m.tick(context.Background())
+	}
+	if len(d.disabled) != 0 {
+		t.Fatalf("disabled %v after 2 cycles, want none before 3", d.disabled)
+	}
+
+	m.tick(context.Background())
+	want := "matched 4 of 4 entries (over 50%) for 3 consecutive cycles"
+	if len(d.disabled) != 1 || d.disabled[1] != want {
+		t.Fatalf("disabled = %v, want keyword 1 with %q", d.disabled, want)
+	}
+	if got := rec.cycles[2].DisabledKeywords; got != 1 {
+		t.Errorf("DisabledKeywords = %d, want 1", got)
+	}
+
+	// The disabled keyword is no longer matched; the rare one still is.
+	m.tick(context.Background())
+	if got := rec.cycles[3].Matches; got != 1 {
+		t.Errorf("matches after disabling = %d, want 1 (rare only)", got)
+	}
+}
+
+func TestTick_RunawayStreakResets(t *testing.T) {
+	d := &mockKeywordDisabler{}
+	rec := &recordingMetrics{}
+	// Keyword 1 matches 100% of entries, keyword 2 25%; at a 25% limit
+	// only keyword 1 is over it.
+	m := runawayMonitor(t, d, rec, WithRunawayKeywords(d, 25, 2))
+
+	m.tick(context.Background())
+	m.runaway[1] = 0 // as if the last cycle had been under the limit
+	m.tick(context.Background())
+	if len(d.disabled) != 0 {
+		t.Fatalf("disabled %v, want none after an interrupted streak", d.disabled)
+	}
+	m.tick(context.Background())
+	if _, ok := d.disabled[1]; !ok || len(d.disabled) != 1 {
+		t.Errorf("disabled = %v, want only keyword 1", d.disabled)
+	}
+}
+
+func TestTick_RunawayDisableFailureRetries(t *testing.T) {
+	d := &mockKeywordDisabler{err: errors.New("db down")}
+	rec := &recordingMetrics{}
+	m := runawayMonitor(t, d, rec, WithRunawayKeywords(d, 50, 1))
+
+	m.tick(context.Background())
+	if rec.cycles[0].DisabledKeywords != 0 || m.runaway[1] != 1 {
+		t.Fatalf("DisabledKeywords = %d, streak = %d; want 0 and the streak kept", rec.cycles[0].DisabledKeywords, m.runaway[1])
+	}
+	d.err = nil
+	m.tick(context.Background())
+	if _, ok := d.disabled[1]; !ok {
+		t.Errorf("disabled = %v, want keyword 1 on retry", d.disabled)
+	}
+}
+
+func TestTick_RunawayCheckOffByDefault(t *testing.T) {
+	d := &mockKeywordDisabler{}
+	m := runawayMonitor(t, d, &recordingMetrics{})
+
+	for range 5 {
+		m.tick(context.Background())
+	}
+	if len(d.disabled) != 0 {
+		t.Errorf("disabled = %v, want none without WithRunawayKeywords", d.disabled)
+	}
+}
+
+func TestMatchableKeywords_SkipsDisabled(t *testing.T) {
+	disabledAt := time.Now()
+	keywords := []model.Keyword{
+		{ID: 1, Value: "disabled", DisabledAt: &disabledAt},
+		{ID: 2, Value: "enabled"},
+	}
+	got := matchableKeywords(keywords, time.Now())
+	if len(got) != 1 || got[0].ID != 2 {
+		t.Errorf("matchable = %+v, want only keyword 2", got)
+	}
+}
+
+func TestReprocess_RefusesRangeBeyondTree(t *testing.T) {
+	fetched := false
+	m := New(
+		&mockCTClient{
+			getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+				return &ctlog.STH{TreeSize: 100}, nil
+			},
+			getEntriesFn: func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
+				fetched = true
+				return nil, nil
+			},
+		},
+		&mockKeywordLister{}, &mockCertCreator{}, &mockStateStore{}, 10, time.Hour, false,
+	)
+
+	_, err := m.Reprocess(context.Background(), 90, 100)
+	if !errors.Is(err, ErrBeyondTree) || !strings.Contains(err.Error(), "last index is 99") {
+		t.Errorf("Reprocess() error = %v, want ErrBeyondTree naming index 99", err)
+	}
+	if _, err := m.Reprocess(context.Background(), 10, 5); err == nil || !strings.Contains(err.Error(), "invalid range") {
+		t.Errorf("Reprocess(inverted) error = %v, want invalid range", err)
+	}
+	if fetched {
+		t.Error("entries fetched for a refused range")
+	}
+}
+
+func TestReprocess_OneAtATime(t *testing.T) {
+	leaf := buildLeaf(t, selfSignedDER(t, "example.com", nil))
+	release := make(chan struct{})
+	fetching := make(chan struct{})
+	m := New(
+		&mockCTClient{
+			getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+				return &ctlog.STH{TreeSize: 100}, nil
+			},
+			getEntriesFn: func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
+				close(fetching)
+				<-release
+				return []ctlog.RawEntry{{LeafInput: leaf}}, nil
+			},
+		},
+		&mockKeywordLister{listFn: func(ctx context.Context) ([]model.Keyword, error) {
+			return []model.Keyword{{ID: 1, Value: "example"}}, nil
+		}},
+		&mockCertCreator{createFn: func(ctx context.Context, cert *model.MatchedCertificate) error { return nil }},
+		&mockStateStore{}, 10, time.Hour, false,
+	)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := m.Reprocess(context.Background(), 5, 5)
+		done <- err
+	}()
+	<-fetching
+	if _, err := m.Reprocess(context.Background(), 5, 5); !errors.Is(err, ErrReprocessRunning) {
+		t.Errorf("concurrent Reprocess() error = %v, want ErrReprocessRunning", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("first Reprocess() error = %v", err)
+	}
+}
+
+func TestProcessBatch_HasCompletedCycle(t *testing.T) {
+	var sthErr, stateErr error
+	m := New(
+		&mockCTClient{
+			getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+				return &ctlog.STH{TreeSize: 0}, sthErr
+			},
+		},
+		&mockKeywordLister{},
+		&mockCertCreator{},
+		&mockStateStore{
+			getFn: func(ctx context.Context) (*model.MonitorState, error) {
+				return &model.MonitorState{}, nil
+			},
+			updateFn:   func(ctx context.Context, state *model.MonitorState) error { return nil },
+			setErrorFn: func(ctx context.Context, errMsg string) error { return stateErr },
+		},
+		10, time.Hour, false,
+	)
+	if m.HasCompletedCycle() {
+		t.Fatal("HasCompletedCycle() = true before any batch")
+	}
+
+	sthErr = errors.New("log down")
+	m.processBatch(context.Background())
+	if m.HasCompletedCycle() {
+		t.Error("HasCompletedCycle() = true after a failed batch")
+	}
+
+	sthErr, stateErr = nil, errors.New("db down")
+	m.processBatch(context.Background())
+	if m.HasCompletedCycle() {
+		t.Error("HasCompletedCycle() = true after a batch that could not write its state")
+	}
+
+	stateErr = nil
+	m.processBatch(context.Background())
+	if !m.HasCompletedCycle() {
+		t.Fatal("HasCompletedCycle() = false after a successful batch")
+	}
+
+	sthErr = errors.New("log down")
+	m.processBatch(context.Background())
+	if !m.HasCompletedCycle() {
+		t.Error("HasCompletedCycle() = false after a later failure, want it to stay true")
+	}
+}
+
+type recordedSwitches []LogSwitch
+
+func (r *recordedSwitches) RecordLogSwitch(ctx context.Context, sw LogSwitch) { *r = append(*r, sw) }
+
+func sthClient(treeSize int64) *mockCTClient {
+	return &mockCTClient{
+		getSTHFn: func(ctx context.Context) (*ctlog.STH, error) { return &ctlog.STH{TreeSize: treeSize}, nil },
+	}
+}
+
+func TestProcessBatch_FrozenLogSwitchesToSuccessor(t *testing.T) {
+	successor := sthClient(1000)
+	var dialed []string
+	dial := func(url string) (LogClient, error) {
+		dialed = append(dialed, url)
+		return successor, nil
+	}
+	var frozen []*time.Time
+	var switched string
+	state := &mockStateStore{
+		getFn: func(ctx context.Context) (*model.MonitorState, error) {
+			return &model.MonitorState{LastProcessedIndex: 10}, nil
+		},
+		updateFn:    func(ctx context.Context, state *model.MonitorState) error { return nil },
+		setFrozenFn: func(ctx context.Context, since *time.Time) error { frozen = append(frozen, since); return nil },
+		switchLogFn: func(ctx context.Context, url, configuredURL string, index, treeSize int64) error {
+			switched = fmt.Sprintf("%s %s %d %d", url, configuredURL, index, treeSize)
+			return nil
+		},
+	}
+	var recorded recordedSwitches
+	m := New(sthClient(10), &mockKeywordLister{}, &mockCertCreator{}, state, 10, time.Hour, false,
+		WithLogs("https://old.example", "https://old.example", dial),
+		WithRollover(time.Now().Add(-time.Hour), time.Millisecond, "https://new.example", &recorded),
+	)
+
+	m.processBatch(context.Background())
+	if len(frozen) != 0 || len(dialed) != 0 {
+		t.Fatalf("first sighting: frozen = %v, dialed = %v; want nothing yet", frozen, dialed)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if stats := m.processBatch(context.Background()); stats.Failed {
+		t.Fatal("switching batch failed")
+	}
+	if len(frozen) != 1 || frozen[0] == nil {
+		t.Errorf("frozen = %v, want the log marked frozen", frozen)
+	}
+	if switched != "https://new.example https://old.example 1000 1000" {
+		t.Errorf("SwitchLog = %q, want the successor's tail under the configured URL", switched)
+	}
+	want := LogSwitch{From: "https://old.example", To: "https://new.example", StartIndex: 1000, Reason: LogSwitchFrozen}
+	if len(recorded) != 1 || recorded[0] != want {
+		t.Errorf("recorded = %+v, want %+v", recorded, want)
+	}
+	if m.client() != successor || m.url() != "https://new.example" || m.frozenSince != nil {
+		t.Errorf("after switch: url = %s, frozenSince = %v; want the successor, live", m.url(), m.frozenSince)
+	}
+}
+
+func TestWatchFrozen(t *testing.T) {
+	ctx := context.Background()
+	var frozen []*time.Time
+	var dialed int
+	m := New(sthClient(10), &mockKeywordLister{}, &mockCertCreator{},
+		&mockStateStore{setFrozenFn: func(ctx context.Context, since *time.Time) error {
+			frozen = append(frozen, since)
+			return nil
+		}},
+		10, time.Hour, false,
+		WithLogs("https://old.example", "https://old.example", func(url string) (LogClient, error) {
+			dialed++
+			return sthClient(5), nil
+		}),
+		WithRollover(time.Now().Add(-time.Hour), time.Millisecond, "https://new.example", nil),
+	)
+	var stats CycleStats
+
+	m.watchFrozen(ctx, &stats, 10, 4)
+	time.Sleep(5 * time.Millisecond)
+	if m.watchFrozen(ctx, &stats, 10, 4) {
+		t.Error("watchFrozen() = true with entries left to read")
+	}
+	if len(frozen) != 1 || frozen[0] == nil || dialed != 0 {
+		t.Fatalf("frozen = %v, dialed = %d; want marked frozen, no switch while behind", frozen, dialed)
+	}
+
+	// A log that grows again is live, and no longer drained past headLag.
+	if m.watchFrozen(ctx, &stats, 11, 4) || m.frozenSince != nil {
+		t.Errorf("after growth: frozenSince = %v, want cleared", m.frozenSince)
+	}
+	if len(frozen) != 2 || frozen[1] != nil {
+		t.Errorf("frozen = %v, want the mark cleared in the store", frozen)
+	}
+
+	// Before the shard's interval ends an idle log is just quiet.
+	m.intervalEnd = time.Now().Add(time.Hour)
+	m.watchFrozen(ctx, &stats, 11, 11)
+	time.Sleep(5 * time.Millisecond)
+	if m.watchFrozen(ctx, &stats, 11, 11) || m.frozenSince != nil || dialed != 0 {
+		t.Errorf("before interval end: frozenSince = %v, dialed = %d; want live", m.frozenSince, dialed)
+	}
+}
+
+func TestWatchFrozen_SwitchFailure(t *testing.T) {
+	var lastErr string
+	m := New(sthClient(10), &mockKeywordLister{}, &mockCertCreator{},
+		&mockStateStore{setErrorFn: func(ctx context.Context, errMsg string) error { lastErr = errMsg; return nil }},
+		10, time.Hour, false,
+		WithLogs("https://old.example", "https://old.example", func(url string) (LogClient, error) {
+			return nil, ErrLogNotAllowed
+		}),
+		WithRollover(time.Now().Add(-time.Hour), time.Millisecond, "https://new.example", nil),
+	)
+	var stats CycleStats
+	m.watchFrozen(context.Background(), &stats, 10, 10)
+	time.Sleep(5 * time.Millisecond)
+	if !m.watchFrozen(context.Background(), &stats, 10, 10) || !stats.Failed {
+		t.Errorf("watchFrozen() failed = %v, want the cycle ended as failed", stats.Failed)
+	}
+	if !strings.Contains(lastErr, "successor") || m.url() != "https://old.example" {
+		t.Errorf("error = %q, url = %s; want the failure reported on the old log", lastErr, m.url())
+	}
+}
+
+func TestSwitchLog(t *testing.T) {
+	ctx := context.Background()
+	m := New(sthClient(10), &mockKeywordLister{}, &mockCertCreator{}, &mockStateStore{}, 10, time.Hour, false)
+	if _, err := m.SwitchLog(ctx, "https://new.example", nil); !errors.Is(err, ErrLogSwitchDisabled) {
+		t.Errorf("SwitchLog() without WithLogs error = %v, want ErrLogSwitchDisabled", err)
+	}
+
+	var index int64 = -1
+	var recorded recordedSwitches
+	m = New(sthClient(10), &mockKeywordLister{}, &mockCertCreator{},
+		&mockStateStore{switchLogFn: func(ctx context.Context, url, configuredURL string, i, treeSize int64) error {
+			index = i
+			return nil
+		}},
+		10, time.Hour, false,
+		WithLogs("https://old.example", "https://old.example", func(url string) (LogClient, error) {
+			if url == "ftp://bad" {
+				return nil, ErrInvalidLogURL
+			}
+			return sthClient(50), nil
+		}),
+		WithRollover(time.Time{}, time.Hour, "", &recorded),
+	)
+
+	if _, err := m.SwitchLog(ctx, "ftp://bad", nil); !errors.Is(err, ErrInvalidLogURL) {
+		t.Errorf("SwitchLog(bad) error = %v, want the dialer's", err)
+	}
+	beyond := int64(51)
+	if _, err := m.SwitchLog(ctx, "https://new.example", &beyond); !errors.Is(err, ErrBeyondTree) || index != -1 {
+		t.Errorf("SwitchLog(51) error = %v, index = %d; want ErrBeyondTree and no switch", err, index)
+	}
+
+	start := int64(20)
+	sw, err := m.SwitchLog(ctx, "https://new.example", &start)
+	if err != nil {
+		t.Fatalf("SwitchLog() error = %v", err)
+	}
+	if sw.StartIndex != 20 || index != 20 || sw.Reason != LogSwitchManual || sw.From != "https://old.example" {
+		t.Errorf("switch = %+v, stored index %d; want a manual switch at 20", sw, index)
+	}
+	if sw, _ := m.SwitchLog(ctx, "https://other.example", nil); sw.StartIndex != 50 || sw.From != "https://new.example" {
+		t.Errorf("switch without start = %+v, want the tail of the new log", sw)
+	}
+	if len(recorded) != 0 {
+		t.Errorf("recorded = %+v, want manual switches left to the caller", recorded)
+	}
+}
+
+func TestTick_SkipsKeywordsOutsideTheirWindow(t *testing.T) {
+	login := buildLeaf(t, selfSignedDER(t, "login.example.com", nil))
+	shop := buildLeaf(t, selfSignedDER(t, "shop.example.com", nil))
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	var stored []model.MatchedCertificate
+	New(
+		&mockCTClient{
+			getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+				return &ctlog.STH{TreeSize: 200}, nil
+			},
+			getEntriesFn: func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
+				return []ctlog.RawEntry{{LeafInput: login}, {LeafInput: shop}}, nil
+			},
+		},
+		&mockKeywordLister{
+			listFn: func(ctx context.Context) ([]model.Keyword, error) {
+				return []model.Keyword{
+					{ID: 1, Value: "login", ActiveUntil: &past},
+					{ID: 2, Value: "shop", ActiveFrom: &future},
+					{ID: 3, Value: "example", ActiveFrom: &past, ActiveUntil: &future},
+				}, nil
+			},
+		},
+		&mockCertCreator{
+			createFn: func(ctx context.Context, cert *model.MatchedCertificate) error {
+				stored = append(stored, *cert)
+				return nil
+			},
+		},
+		&mockStateStore{
+			getFn: func(ctx context.Context) (*model.MonitorState, error) {
+				return &model.MonitorState{LastProcessedIndex: 100}, nil
+			},
+			updateFn: func(ctx context.Context, state *model.MonitorState) error { return nil },
+		},
+		10, time.Hour, false,
+	).tick(context.Background())
+
+	if len(stored) != 2 {
+		t.Fatalf("stored %d matches, want the 2 of the keyword in its window", len(stored))
+	}
+	for _, cert := range stored {
+		if cert.KeywordID != 3 {
+			t.Errorf("stored a match for keyword %d, which is outside its window", cert.KeywordID)
+		}
+	}
+}
+
+func TestWatchFrozen_OffAfterSwitch(t *testing.T) {
+	ctx := context.Background()
+	var frozen []*time.Time
+	m := New(sthClient(10), &mockKeywordLister{}, &mockCertCreator{},
+		&mockStateStore{setFrozenFn: func(ctx context.Context, since *time.Time) error {
+			frozen = append(frozen, since)
+			return nil
+		}},
+		10, time.Hour, false,
+		WithLogs("https://old.example", "https://new.example", func(url string) (LogClient, error) {
+			return sthClient(5), nil
+		}),
+		WithRollover(time.Now().Add(-time.Hour), time.Millisecond, "https://new.example", nil),
+	)
+	var stats CycleStats
+
+	// The predecessor's interval has ended, but a stall on the successor is
+	// an outage, not a freeze: headLag keeps applying.
+	m.watchFrozen(ctx, &stats, 10, 10)
+	time.Sleep(5 * time.Millisecond)
+	if m.watchFrozen(ctx, &stats, 10, 10) || m.frozenSince != nil || len(frozen) != 0 {
+		t.Errorf("on the successor: frozenSince = %v, frozen = %v; want live", m.frozenSince, frozen)
+	}
+}
+
+func TestProcessBatch_EmptyLogUpdateFailureLogged(t *testing.T) {
+	var logs bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	var lastErr *string
+	m := New(
+		&mockCTClient{
+			getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+				return &ctlog.STH{TreeSize: 0}, nil
+			},
+		},
+		&mockKeywordLister{},
+		&mockCertCreator{},
+		&mockStateStore{
+			getFn: func(ctx context.Context) (*model.MonitorState, error) {
+				return &model.MonitorState{}, nil
+			},
+			updateFn: func(ctx context.Context, state *model.MonitorState) error {
+				return errors.New("connection refused")
+			},
+			setErrorFn: func(ctx context.Context, errMsg string) error {
+				lastErr = &errMsg
+				return nil
+			},
+		},
+		10, time.Hour, false,
+	)
+
+	m.processBatch(context.Background())
+
+	if !strings.Contains(logs.String(), `"msg":"failed to update monitor state"`) ||
+		!strings.Contains(logs.String(), "connection refused") {
+		t.Errorf("logs = %s, want the failed state update", logs.String())
+	}
+	if lastErr == nil || *lastErr != "" {
+		t.Errorf("SetError = %v, want the cycle to finish and clear the error", lastErr)
+	}
+}
+
+func TestBackfill_TagsMatchesWithTheirLog(t *testing.T) {
+	leaf := buildLeaf(t, selfSignedDER(t, "example.com", nil))
+	logClient := func() *mockCTClient {
+		c := sthClient(100)
+		c.getEntriesFn = func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
+			return []ctlog.RawEntry{{LeafInput: leaf}}, nil
+		}
+		return c
+	}
+	var stored []string
+	m := New(logClient(),
+		&mockKeywordLister{
+			listFn: func(ctx context.Context) ([]model.Keyword, error) {
+				return []model.Keyword{{ID: 1, Value: "example"}}, nil
+			},
+		},
+		&mockCertCreator{
+			createFn: func(ctx context.Context, cert *model.MatchedCertificate) error {
+				stored = append(stored, fmt.Sprintf("%s#%d", cert.CTLogURL, cert.CTLogIndex))
+				return nil
+			},
+		},
+		&mockStateStore{
+			switchLogFn: func(ctx context.Context, url, configuredURL string, index, treeSize int64) error { return nil },
+		},
+		10, time.Hour, false,
+		WithLogs("https://old.example", "https://old.example", func(url string) (LogClient, error) {
+			return logClient(), nil
+		}),
+	)
+
+	if _, err := m.Backfill(context.Background(), 5, 5); err != nil {
+		t.Fatalf("Backfill() error = %v", err)
+	}
+	if _, err := m.SwitchLog(context.Background(), "https://new.example", nil); err != nil {
+		t.Fatalf("SwitchLog() error = %v", err)
+	}
+	if _, err := m.Backfill(context.Background(), 5, 5); err != nil {
+		t.Fatalf("Backfill() error = %v", err)
+	}
+
+	// The same index in two logs is two entries.
+	if want := []string{"https://old.example#5", "https://new.example#5"}; !slices.Equal(stored, want) {
+		t.Errorf("stored = %v, want %v", stored, want)
+	}
+}
+
+func TestProcessBatch_StateUpdateFailureKeepsUnready(t *testing.T) {
+	leaf := buildLeaf(t, selfSignedDER(t, "example.com", nil))
+	tests := []struct {
+		name          string
+		treeSize      int64
+		lastProcessed int64
+		reprocess     bool
+		headLag       int64
+	}{
+		{"empty log", 0, 0, false, 0},
+		{"new entries", 100, 50, false, 0},
+		{"no new entries", 100, 100, false, 0},
+		{"reprocessed", 100, 100, true, 0},
+		{"nothing to reprocess", 100, 0, true, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var updates int
+			m := New(
+				&mockCTClient{
+					getSTHFn: func(ctx context.Context) (*ctlog.STH, error) {
+						return &ctlog.STH{TreeSize: tt.treeSize}, nil
+					},
+					getEntriesFn: func(ctx context.Context, start, end int64) ([]ctlog.RawEntry, error) {
+						entries := make([]ctlog.RawEntry, end-start+1)
+						for i := range entries {
+							entries[i] = ctlog.RawEntry{LeafInput: leaf}
+						}
+						return entries, nil
+					},
+				},
+				&mockKeywordLister{listFn: func(ctx context.Context) ([]model.Keyword, error) {
+					return []model.Keyword{{ID: 1, Value: "example"}}, nil
+				}},
+				&mockCertCreator{createFn: func(ctx context.Context, cert *model.MatchedCertificate) error { return nil }},
+				&mockStateStore{
+					getFn: func(ctx context.Context) (*model.MonitorState, error) {
+						return &model.MonitorState{LastProcessedIndex: tt.lastProcessed}, nil
+					},
+					updateFn: func(ctx context.Context, state *model.MonitorState) error {
+						updates++
+						return errors.New("db down")
+					},
+				},
+				10, time.Hour, tt.reprocess,
+				WithHeadLag(tt.headLag),
+			)
+
+			stats := m.processBatch(context.Background())
+			if updates != 1 {
+				t.Fatalf("state updates = %d, want 1", updates)
+			}
+			if stats.StateErrors != 1 {
+				t.Errorf("StateErrors = %d, want 1", stats.StateErrors)
+			}
+			if m.HasCompletedCycle() {
+				t.Error("HasCompletedCycle() = true after a batch that could not save its state")
+			}
+		})
+	}
+}
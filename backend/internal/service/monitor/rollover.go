@@ -0,0 +1,164 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Reasons for a LogSwitch.
+const (
+	LogSwitchFrozen = "frozen"
+	LogSwitchManual = "manual"
+)
+
+// LogSwitch describes the monitor moving its cursor to another CT log.
+type LogSwitch struct {
+	From       string
+	To         string
+	StartIndex int64
+	Reason     string
+}
+
+// LogSwitchRecorder is told about each automatic switch to a successor
+// log; manual switches are recorded by their caller.
+type LogSwitchRecorder interface {
+	RecordLogSwitch(ctx context.Context, sw LogSwitch)
+}
+
+// WithLogs names the logs the monitor reads. configured is CT_LOG_URL and
+// current the log its stored cursor follows, which differs once it has
+// switched. dial opens another log for SwitchLog and rollover, and rejects
+// URLs that may not be read with ErrInvalidLogURL or ErrLogNotAllowed; a
+// nil dial leaves switching off.
+func WithLogs(configured, current string, dial func(url string) (LogClient, error)) Option {
+	return func(m *Monitor) {
+		m.configuredURL, m.logURL, m.dial = configured, current, dial
+	}
+}
+
+// WithRollover treats the log as frozen once intervalEnd, the end of the
+// shard's temporal interval, has passed and its tree size has not changed
+// for frozenAfter. A frozen log is read to its end; then, if successor is
+// set, the monitor switches to it at its current tree size and tells
+// recorder. A zero intervalEnd (the default) turns detection off.
+func WithRollover(intervalEnd time.Time, frozenAfter time.Duration, successor string, recorder LogSwitchRecorder) Option {
+	return func(m *Monitor) {
+		m.intervalEnd, m.frozenAfter = intervalEnd, frozenAfter
+		m.successorURL, m.switchRecorder = successor, recorder
+	}
+}
+
+// SwitchLog moves the cursor to the log at url, at start or, when start is
+// nil, at its current tree size so only entries logged from now on are
+// processed. It waits for a batch in progress to finish.
+func (m *Monitor) SwitchLog(ctx context.Context, url string, start *int64) (LogSwitch, error) {
+	m.cursorMu.Lock()
+	defer m.cursorMu.Unlock()
+	return m.switchLog(ctx, url, start, LogSwitchManual)
+}
+
+func (m *Monitor) client() LogClient {
+	m.logMu.RLock()
+	defer m.logMu.RUnlock()
+	return m.ctClient
+}
+
+func (m *Monitor) url() string {
+	m.logMu.RLock()
+	defer m.logMu.RUnlock()
+	return m.logURL
+}
+
+// log returns the client and URL of the current log together, so entries
+// are never attributed to a log a switch put in place after their fetch.
+func (m *Monitor) log() (LogClient, string) {
+	m.logMu.RLock()
+	defer m.logMu.RUnlock()
+	return m.ctClient, m.logURL
+}
+
+func (m *Monitor) switchLog(ctx context.Context, url string, start *int64, reason string) (LogSwitch, error) {
+	if m.dial == nil {
+		return LogSwitch{}, ErrLogSwitchDisabled
+	}
+	client, err := m.dial(url)
+	if err != nil {
+		return LogSwitch{}, err
+	}
+	sth, err := client.GetSTH(ctx)
+	if err != nil {
+		return LogSwitch{}, fmt.Errorf("get STH of %s: %w", url, err)
+	}
+	index := sth.TreeSize
+	if start != nil {
+		if *start < 0 || *start > sth.TreeSize {
+			return LogSwitch{}, fmt.Errorf("%w: start must be between 0 and %d", ErrBeyondTree, sth.TreeSize)
+		}
+		index = *start
+	}
+	if err := m.state.SwitchLog(ctx, url, m.configuredURL, index, sth.TreeSize); err != nil {
+		return LogSwitch{}, fmt.Errorf("record switch: %w", err)
+	}
+
+	m.logMu.Lock()
+	sw := LogSwitch{From: m.logURL, To: url, StartIndex: index, Reason: reason}
+	m.ctClient, m.logURL = client, url
+	m.logMu.Unlock()
+	m.treeSize, m.treeChangedAt, m.frozenSince = 0, time.Time{}, nil
+
+	slog.WarnContext(ctx, "monitor switched CT log",
+		"from", sw.From, "to", sw.To, "start_index", sw.StartIndex, "reason", reason)
+	if reason == LogSwitchFrozen && m.switchRecorder != nil {
+		m.switchRecorder.RecordLogSwitch(ctx, sw)
+	}
+	return sw, nil
+}
+
+// watchFrozen tracks the tree size for WithRollover, marking the log
+// frozen or live again as needed, and switches to the successor once a
+// frozen log has been read to its end. It reports whether the cycle should
+// end here. intervalEnd belongs to the configured log, so once the monitor
+// has moved to another one a stall there is just an outage; setting
+// CT_LOG_URL and CT_LOG_INTERVAL_END to the successor watches it again.
+func (m *Monitor) watchFrozen(ctx context.Context, stats *CycleStats, treeSize int64, cursor int64) bool {
+	if m.intervalEnd.IsZero() || m.url() != m.configuredURL {
+		return false
+	}
+	now := time.Now()
+	if treeSize != m.treeSize || m.treeChangedAt.IsZero() {
+		m.treeSize, m.treeChangedAt = treeSize, now
+		if m.frozenSince != nil {
+			slog.InfoContext(ctx, "CT log is growing again", "ct_log_url", m.url(), "tree_size", treeSize)
+			m.setFrozen(ctx, stats, nil)
+		}
+		return false
+	}
+	if now.Before(m.intervalEnd) || now.Sub(m.treeChangedAt) < m.frozenAfter {
+		return false
+	}
+	if m.frozenSince == nil {
+		since := m.treeChangedAt
+		slog.WarnContext(ctx, "CT log appears frozen: its interval has ended and its tree size stopped changing",
+			"ct_log_url", m.url(), "tree_size", treeSize, "unchanged_since", since, "successor", m.successorURL)
+		m.setFrozen(ctx, stats, &since)
+	}
+	if m.successorURL == "" || m.successorURL == m.url() || cursor < treeSize {
+		return false
+	}
+	if _, err := m.switchLog(ctx, m.successorURL, nil, LogSwitchFrozen); err != nil {
+		slog.ErrorContext(ctx, "failed to switch to the successor log", "successor", m.successorURL, "error", err)
+		stats.Failed = true
+		m.setError(ctx, stats, fmt.Sprintf("failed to switch to successor log: %v", err))
+	}
+	return true
+}
+
+func (m *Monitor) setFrozen(ctx context.Context, stats *CycleStats, since *time.Time) {
+	m.frozenSince = since
+	if err := m.state.SetLogFrozen(ctx, since); err != nil {
+		stats.StateErrors++
+		slog.ErrorContext(ctx, "failed to record frozen log state", "error", err)
+	}
+}
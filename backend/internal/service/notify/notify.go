@@ -0,0 +1,283 @@
+// Package notify delivers match notifications from the transactional outbox
+// to external notifiers such as webhooks.
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/outbox"
+)
+
+// Notifier delivers a single match notification. Delivery is at least
+// once: a message is retried when any notifier fails, so implementations
+// may see the same match more than once.
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, cert model.MatchedCertificate) error
+}
+
+// DigestNotifier is a Notifier that can also deliver many matches as one
+// message. Under QueueDigest it receives the overflow of a full queue in a
+// single call; notifiers without it get those matches one by one.
+type DigestNotifier interface {
+	Notifier
+	NotifyDigest(ctx context.Context, certs []model.MatchedCertificate) error
+}
+
+type outboxStore interface {
+	Claim(ctx context.Context, limit int, leaseUntil time.Time) ([]model.OutboxMessage, error)
+	MarkSent(ctx context.Context, id int64) error
+	MarkFailed(ctx context.Context, id int64, errMsg string, retryAt time.Time, dead bool) error
+	MarkDropped(ctx context.Context, ids []int64) error
+	Prune(ctx context.Context, before time.Time) (int64, error)
+}
+
+const (
+	defaultBatchSize   = 200
+	defaultMaxAttempts = 5
+	defaultRetention   = 7 * 24 * time.Hour
+	defaultWorkers     = 4
+	defaultQueueSize   = 100
+	deliveryTimeout    = 10 * time.Second
+)
+
+// Queue policies: what a poll does with a claimed message when the
+// delivery queue is full; see the outbox package.
+const (
+	QueueBlock      = outbox.QueueBlock
+	QueueDrop       = outbox.QueueDrop
+	QueueDropOldest = outbox.QueueDropOldest
+	// QueueDigest delivers the messages that do not fit together once the
+	// queue has drained (see DigestNotifier).
+	QueueDigest = outbox.QueueDigest
+)
+
+// Dispatcher polls the outbox and hands due messages to every notifier.
+// Each batch is delivered by a bounded pool of workers fed from a queue, so
+// a burst of matches never opens more than workers concurrent deliveries.
+type Dispatcher struct {
+	store       outboxStore
+	notifiers   []Notifier
+	maxAttempts int
+	now         func() time.Time
+	poller      outbox.Poller[model.OutboxMessage]
+}
+
+type Option func(*Dispatcher)
+
+// WithMaxAttempts sets how many failed deliveries move a message to the
+// failed (dead-letter) state.
+func WithMaxAttempts(n int) Option {
+	return func(d *Dispatcher) {
+		if n > 0 {
+			d.maxAttempts = n
+		}
+	}
+}
+
+// WithRetention sets how long sent and failed messages are kept.
+func WithRetention(r time.Duration) Option {
+	return func(d *Dispatcher) {
+		if r > 0 {
+			d.poller.Retention = r
+		}
+	}
+}
+
+// WithWorkers sets how many messages are delivered concurrently.
+func WithWorkers(n int) Option {
+	return func(d *Dispatcher) {
+		if n > 0 {
+			d.poller.Workers = n
+		}
+	}
+}
+
+// WithBatchSize sets how many due messages a poll claims. The queue
+// policies only apply when it exceeds the queue size.
+func WithBatchSize(n int) Option {
+	return func(d *Dispatcher) {
+		if n > 0 {
+			d.poller.BatchSize = n
+		}
+	}
+}
+
+// WithQueue sets how many messages may wait for a worker and what happens
+// when that many are already waiting: QueueBlock, QueueDrop,
+// QueueDropOldest or QueueDigest. Dropped messages are never delivered.
+func WithQueue(size int, policy string) Option {
+	return func(d *Dispatcher) {
+		if size > 0 {
+			d.poller.QueueSize = size
+		}
+		switch policy {
+		case QueueBlock, QueueDrop, QueueDropOldest, QueueDigest:
+			d.poller.Policy = policy
+		}
+	}
+}
+
+func NewDispatcher(store outboxStore, notifiers []Notifier, interval time.Duration, opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		store:       store,
+		notifiers:   notifiers,
+		maxAttempts: defaultMaxAttempts,
+		now:         time.Now,
+	}
+	d.poller = outbox.Poller[model.OutboxMessage]{
+		Name:    "outbox",
+		Claim:   store.Claim,
+		Prune:   store.Prune,
+		Drop:    store.MarkDropped,
+		Deliver: d.deliver,
+		Digest:  d.deliverDigest,
+		ID:      func(msg model.OutboxMessage) int64 { return msg.ID },
+
+		Interval:  interval,
+		BatchSize: defaultBatchSize,
+		Workers:   defaultWorkers,
+		QueueSize: defaultQueueSize,
+		Policy:    QueueBlock,
+		Retention: defaultRetention,
+		// Every notifier may time out on every message.
+		Timeout: deliveryTimeout * time.Duration(max(1, len(notifiers))),
+		Now:     func() time.Time { return d.now() },
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Run polls until ctx is canceled. A batch already claimed is delivered to
+// completion before Run returns, so callers should wait for it on shutdown.
+func (d *Dispatcher) Run(ctx context.Context) {
+	d.poller.Run(ctx)
+}
+
+// QueueDepth returns how many claimed messages are waiting for a worker.
+func (d *Dispatcher) QueueDepth() int64 {
+	return d.poller.QueueDepth()
+}
+
+// Dropped returns how many messages the drop policies have turned away.
+func (d *Dispatcher) Dropped() int64 {
+	return d.poller.Dropped()
+}
+
+// Digested returns how many messages the digest policy has delivered as
+// part of a digest.
+func (d *Dispatcher) Digested() int64 {
+	return d.poller.Digested()
+}
+
+// Stats reports the queue's settings and counters.
+func (d *Dispatcher) Stats() model.NotifyQueueStats {
+	return model.NotifyQueueStats{
+		Size:     d.poller.QueueSize,
+		Policy:   d.poller.Policy,
+		Depth:    d.QueueDepth(),
+		Dropped:  d.Dropped(),
+		Digested: d.Digested(),
+	}
+}
+
+// deliverDigest delivers the overflow of a full queue at once: one digest
+// to each DigestNotifier and every match to the other notifiers. The
+// messages succeed or fail together.
+func (d *Dispatcher) deliverDigest(ctx context.Context, msgs []model.OutboxMessage) {
+	certs := make([]model.MatchedCertificate, 0, len(msgs))
+	decoded := make([]model.OutboxMessage, 0, len(msgs))
+	for _, msg := range msgs {
+		var cert model.MatchedCertificate
+		if err := json.Unmarshal(msg.Payload, &cert); err != nil {
+			d.fail(ctx, msg, fmt.Errorf("decode payload: %w", err), true)
+			continue
+		}
+		certs = append(certs, cert)
+		decoded = append(decoded, msg)
+	}
+	if len(certs) == 0 {
+		return
+	}
+	slog.Warn("notification queue full, delivering the overflow as a digest",
+		"matches", len(certs), "queue_size", d.poller.QueueSize)
+
+	var errs []error
+	for _, n := range d.notifiers {
+		if dn, ok := n.(DigestNotifier); ok {
+			nctx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+			if err := dn.NotifyDigest(nctx, certs); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", n.Name(), err))
+			}
+			cancel()
+			continue
+		}
+		for _, cert := range certs {
+			nctx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+			if err := n.Notify(nctx, cert); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", n.Name(), err))
+			}
+			cancel()
+		}
+	}
+
+	err := errors.Join(errs...)
+	for _, msg := range decoded {
+		if err != nil {
+			d.fail(ctx, msg, err, msg.Attempts+1 >= d.maxAttempts)
+			continue
+		}
+		if err := d.store.MarkSent(ctx, msg.ID); err != nil {
+			slog.Error("failed to mark outbox message sent", "error", err, "id", msg.ID)
+		}
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, msg model.OutboxMessage) {
+	var cert model.MatchedCertificate
+	err := json.Unmarshal(msg.Payload, &cert)
+	if err != nil {
+		// A payload that cannot be decoded will never succeed.
+		d.fail(ctx, msg, fmt.Errorf("decode payload: %w", err), true)
+		return
+	}
+
+	var errs []error
+	for _, n := range d.notifiers {
+		nctx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+		if err := n.Notify(nctx, cert); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", n.Name(), err))
+		}
+		cancel()
+	}
+	if err := errors.Join(errs...); err != nil {
+		d.fail(ctx, msg, err, msg.Attempts+1 >= d.maxAttempts)
+		return
+	}
+
+	if err := d.store.MarkSent(ctx, msg.ID); err != nil {
+		slog.Error("failed to mark outbox message sent", "error", err, "id", msg.ID)
+	}
+}
+
+func (d *Dispatcher) fail(ctx context.Context, msg model.OutboxMessage, cause error, dead bool) {
+	retryAt := d.now().Add(outbox.Backoff(msg.Attempts))
+	if dead {
+		slog.Error("notification failed permanently",
+			"error", cause, "id", msg.ID, "attempts", msg.Attempts+1)
+	} else {
+		slog.Warn("notification failed, will retry",
+			"error", cause, "id", msg.ID, "attempts", msg.Attempts+1, "retry_at", retryAt)
+	}
+	if err := d.store.MarkFailed(ctx, msg.ID, cause.Error(), retryAt, dead); err != nil {
+		slog.Error("failed to record notification failure", "error", err, "id", msg.ID)
+	}
+}
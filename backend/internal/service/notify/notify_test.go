@@ -0,0 +1,526 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/outbox"
+)
+
+// --- mocks ---
+
+type failCall struct {
+	id      int64
+	errMsg  string
+	retryAt time.Time
+	dead    bool
+}
+
+type mockOutboxStore struct {
+	mu      sync.Mutex
+	msgs    []model.OutboxMessage
+	sent    []int64
+	failed  []failCall
+	dropped []int64
+	pruned  int
+	claimFn func(limit int) ([]model.OutboxMessage, error)
+}
+
+func (m *mockOutboxStore) Claim(ctx context.Context, limit int, leaseUntil time.Time) ([]model.OutboxMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.claimFn != nil {
+		return m.claimFn(limit)
+	}
+	msgs := m.msgs
+	m.msgs = nil
+	return msgs, nil
+}
+func (m *mockOutboxStore) MarkSent(ctx context.Context, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, id)
+	return nil
+}
+func (m *mockOutboxStore) MarkFailed(ctx context.Context, id int64, errMsg string, retryAt time.Time, dead bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failed = append(m.failed, failCall{id, errMsg, retryAt, dead})
+	return nil
+}
+func (m *mockOutboxStore) MarkDropped(ctx context.Context, ids []int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dropped = append(m.dropped, ids...)
+	return nil
+}
+func (m *mockOutboxStore) Prune(ctx context.Context, before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pruned++
+	return 0, nil
+}
+
+type mockNotifier struct {
+	notifyFn func(ctx context.Context, cert model.MatchedCertificate) error
+}
+
+func (m *mockNotifier) Name() string { return "mock" }
+func (m *mockNotifier) Notify(ctx context.Context, cert model.MatchedCertificate) error {
+	return m.notifyFn(ctx, cert)
+}
+
+// --- helpers ---
+
+func message(t *testing.T, id int64, attempts int) model.OutboxMessage {
+	t.Helper()
+	payload, err := json.Marshal(model.MatchedCertificate{ID: int(id), MatchedDomain: "login.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return model.OutboxMessage{ID: id, CertificateID: int(id), Payload: payload, Attempts: attempts}
+}
+
+var fixedNow = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func newTestDispatcher(store outboxStore, notifiers ...Notifier) *Dispatcher {
+	d := NewDispatcher(store, notifiers, time.Hour, WithMaxAttempts(3))
+	d.now = func() time.Time { return fixedNow }
+	return d
+}
+
+// --- poll tests ---
+
+func TestDispatch_DeliversAndMarksSent(t *testing.T) {
+	store := &mockOutboxStore{msgs: []model.OutboxMessage{message(t, 1, 0), message(t, 2, 0)}}
+	var notified atomic.Int32
+	d := newTestDispatcher(store, &mockNotifier{
+		notifyFn: func(ctx context.Context, cert model.MatchedCertificate) error {
+			notified.Add(1)
+			return nil
+		},
+	})
+
+	d.poller.Poll(context.Background())
+
+	if n := notified.Load(); n != 2 {
+		t.Errorf("notified %d times, want 2", n)
+	}
+	if len(store.sent) != 2 || len(store.failed) != 0 {
+		t.Errorf("sent=%v failed=%v, want both sent", store.sent, store.failed)
+	}
+}
+
+func TestDispatch_NoNotifiersMarksSent(t *testing.T) {
+	store := &mockOutboxStore{msgs: []model.OutboxMessage{message(t, 1, 0)}}
+	d := newTestDispatcher(store)
+
+	d.poller.Poll(context.Background())
+
+	if len(store.sent) != 1 {
+		t.Errorf("sent = %v, want [1]", store.sent)
+	}
+}
+
+func TestDispatch_FailureSchedulesRetry(t *testing.T) {
+	store := &mockOutboxStore{msgs: []model.OutboxMessage{message(t, 1, 1)}}
+	d := newTestDispatcher(store, &mockNotifier{
+		notifyFn: func(ctx context.Context, cert model.MatchedCertificate) error {
+			return errors.New("connection refused")
+		},
+	})
+
+	d.poller.Poll(context.Background())
+
+	if len(store.sent) != 0 {
+		t.Errorf("sent = %v, want none", store.sent)
+	}
+	if len(store.failed) != 1 {
+		t.Fatalf("failed = %v, want one call", store.failed)
+	}
+	f := store.failed[0]
+	if f.dead {
+		t.Error("dead = true, want retry")
+	}
+	if want := fixedNow.Add(time.Minute); !f.retryAt.Equal(want) {
+		t.Errorf("retryAt = %v, want %v", f.retryAt, want)
+	}
+}
+
+func TestDispatch_MaxAttemptsDeadLetters(t *testing.T) {
+	store := &mockOutboxStore{msgs: []model.OutboxMessage{message(t, 1, 2)}}
+	d := newTestDispatcher(store, &mockNotifier{
+		notifyFn: func(ctx context.Context, cert model.MatchedCertificate) error {
+			return errors.New("500")
+		},
+	})
+
+	d.poller.Poll(context.Background())
+
+	if len(store.failed) != 1 || !store.failed[0].dead {
+		t.Errorf("failed = %v, want one dead-lettered call", store.failed)
+	}
+}
+
+func TestDispatch_BadPayloadDeadLetters(t *testing.T) {
+	store := &mockOutboxStore{msgs: []model.OutboxMessage{{ID: 1, Payload: json.RawMessage(`"nope"`)}}}
+	called := false
+	d := newTestDispatcher(store, &mockNotifier{
+		notifyFn: func(ctx context.Context, cert model.MatchedCertificate) error {
+			called = true
+			return nil
+		},
+	})
+
+	d.poller.Poll(context.Background())
+
+	if called {
+		t.Error("notifier called for undecodable payload")
+	}
+	if len(store.failed) != 1 || !store.failed[0].dead {
+		t.Errorf("failed = %v, want one dead-lettered call", store.failed)
+	}
+}
+
+func TestDispatch_ClaimErrorSkipsBatch(t *testing.T) {
+	store := &mockOutboxStore{
+		claimFn: func(limit int) ([]model.OutboxMessage, error) {
+			return nil, errors.New("db error")
+		},
+	}
+	d := newTestDispatcher(store)
+
+	d.poller.Poll(context.Background())
+
+	if len(store.sent) != 0 || len(store.failed) != 0 || store.pruned != 0 {
+		t.Errorf("unexpected store calls: sent=%v failed=%v pruned=%d", store.sent, store.failed, store.pruned)
+	}
+}
+
+func TestDispatch_PrunesHourly(t *testing.T) {
+	store := &mockOutboxStore{}
+	d := newTestDispatcher(store)
+
+	d.poller.Poll(context.Background())
+	d.poller.Poll(context.Background())
+	if store.pruned != 1 {
+		t.Errorf("pruned %d times, want 1 within the hour", store.pruned)
+	}
+
+	d.now = func() time.Time { return fixedNow.Add(outbox.PruneEvery) }
+	d.poller.Poll(context.Background())
+	if store.pruned != 2 {
+		t.Errorf("pruned %d times, want 2 after an hour", store.pruned)
+	}
+}
+
+// --- worker pool tests ---
+
+// messages returns n messages with IDs 1..n.
+func messages(t *testing.T, n int) []model.OutboxMessage {
+	t.Helper()
+	msgs := make([]model.OutboxMessage, n)
+	for i := range msgs {
+		msgs[i] = message(t, int64(i+1), 0)
+	}
+	return msgs
+}
+
+func TestDispatch_BoundsConcurrentDeliveries(t *testing.T) {
+	const workers = 3
+	store := &mockOutboxStore{msgs: messages(t, 20)}
+	var inFlight, peak atomic.Int32
+	d := newTestDispatcher(store, &mockNotifier{
+		notifyFn: func(ctx context.Context, cert model.MatchedCertificate) error {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+			}
+			time.Sleep(5 * time.Millisecond)
+			return nil
+		},
+	})
+	WithWorkers(workers)(d)
+	WithQueue(2, QueueBlock)(d)
+
+	d.poller.Poll(context.Background())
+
+	if p := peak.Load(); p != workers {
+		t.Errorf("peak concurrent deliveries = %d, want %d", p, workers)
+	}
+	if len(store.sent) != 20 {
+		t.Errorf("sent %d messages, want all 20", len(store.sent))
+	}
+	if d.QueueDepth() != 0 || d.Dropped() != 0 {
+		t.Errorf("queue depth/dropped = %d/%d, want 0/0", d.QueueDepth(), d.Dropped())
+	}
+}
+
+func TestDispatch_DropPolicyMarksMessagesDropped(t *testing.T) {
+	store := &mockOutboxStore{msgs: messages(t, 5)}
+	release := make(chan struct{})
+	entered := make(chan struct{}, 5)
+	d := newTestDispatcher(store, &mockNotifier{
+		notifyFn: func(ctx context.Context, cert model.MatchedCertificate) error {
+			entered <- struct{}{}
+			<-release
+			return nil
+		},
+	})
+	WithWorkers(1)(d)
+	WithQueue(1, QueueDrop)(d)
+
+	done := make(chan struct{})
+	go func() {
+		d.poller.Poll(context.Background())
+		close(done)
+	}()
+	// The worker blocks on its first message, so at most one more fits in
+	// the queue; whether the worker took the first before the rest were
+	// queued is up to the scheduler.
+	<-entered
+	close(release)
+	<-done
+
+	sent, dropped := len(store.sent), d.Dropped()
+	if sent < 1 || sent > 2 || int64(sent)+dropped != 5 {
+		t.Errorf("sent %d, dropped %d; want 1 or 2 sent and the rest dropped", sent, dropped)
+	}
+	if int64(len(store.dropped)) != dropped || slices.ContainsFunc(store.dropped, func(id int64) bool {
+		return slices.Contains(store.sent, id)
+	}) {
+		t.Errorf("marked dropped %v, sent %v; want every unsent message marked", store.dropped, store.sent)
+	}
+	if len(store.failed) != 0 {
+		t.Errorf("failed = %v, want dropped messages marked dropped, not failed", store.failed)
+	}
+}
+
+func TestDispatch_DropOldestKeepsNewest(t *testing.T) {
+	store := &mockOutboxStore{msgs: messages(t, 20)}
+	release := make(chan struct{})
+	d := newTestDispatcher(store, &mockNotifier{
+		notifyFn: func(ctx context.Context, cert model.MatchedCertificate) error {
+			<-release
+			return nil
+		},
+	})
+	WithWorkers(1)(d)
+	WithQueue(2, QueueDropOldest)(d)
+
+	done := make(chan struct{})
+	go func() {
+		d.poller.Poll(context.Background())
+		close(done)
+	}()
+	for d.Dropped() < 17 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	<-done
+
+	// The blocked worker holds at most one message and the queue two, so
+	// the newest two are always among those sent.
+	sent, dropped := store.sent, d.Dropped()
+	if len(sent) < 2 || len(sent) > 3 || int64(len(sent))+dropped != 20 {
+		t.Errorf("sent %v, dropped %d; want 2 or 3 sent and the rest dropped", sent, dropped)
+	}
+	if !slices.Contains(sent, 19) || !slices.Contains(sent, 20) {
+		t.Errorf("sent %v, want the newest messages 19 and 20", sent)
+	}
+	if int64(len(store.dropped)) != dropped || slices.Contains(store.dropped, 20) {
+		t.Errorf("marked dropped %v, want the %d oldest", store.dropped, dropped)
+	}
+	if len(store.failed) != 0 {
+		t.Errorf("failed = %v, want dropped messages marked dropped, not failed", store.failed)
+	}
+}
+
+// digestNotifier records what it is sent, one match or one digest at a
+// time, after delay.
+type digestNotifier struct {
+	delay   time.Duration
+	err     error
+	mu      sync.Mutex
+	single  int
+	digests []int
+}
+
+func (n *digestNotifier) Name() string { return "digest" }
+func (n *digestNotifier) Notify(ctx context.Context, cert model.MatchedCertificate) error {
+	time.Sleep(n.delay)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.single++
+	return n.err
+}
+func (n *digestNotifier) NotifyDigest(ctx context.Context, certs []model.MatchedCertificate) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.digests = append(n.digests, len(certs))
+	return n.err
+}
+
+func TestDispatch_DigestCollapsesOverflow(t *testing.T) {
+	store := &mockOutboxStore{msgs: messages(t, 30)}
+	digests := &digestNotifier{delay: time.Millisecond}
+	var plain atomic.Int32
+	d := newTestDispatcher(store, digests, &mockNotifier{
+		notifyFn: func(ctx context.Context, cert model.MatchedCertificate) error {
+			plain.Add(1)
+			return nil
+		},
+	})
+	WithWorkers(1)(d)
+	WithQueue(2, QueueDigest)(d)
+
+	d.poller.Poll(context.Background())
+
+	if len(digests.digests) != 1 || digests.single+digests.digests[0] != 30 {
+		t.Fatalf("digest notifier got %d single and digests %v, want one digest with the rest of 30", digests.single, digests.digests)
+	}
+	if int64(digests.digests[0]) != d.Digested() {
+		t.Errorf("Digested() = %d, want %d", d.Digested(), digests.digests[0])
+	}
+	if plain.Load() != 30 {
+		t.Errorf("notifier without digests got %d matches, want all 30", plain.Load())
+	}
+	if len(store.sent) != 30 || d.Dropped() != 0 {
+		t.Errorf("sent %d, dropped %d; want all 30 sent", len(store.sent), d.Dropped())
+	}
+}
+
+func TestDispatch_DigestFailureRetriesItsMessages(t *testing.T) {
+	store := &mockOutboxStore{msgs: messages(t, 10)}
+	digests := &digestNotifier{err: errors.New("slack down")}
+	d := newTestDispatcher(store, digests)
+	WithWorkers(1)(d)
+	WithQueue(1, QueueDigest)(d)
+
+	d.poller.Poll(context.Background())
+
+	if len(store.sent) != 0 || len(store.failed) != 10 {
+		t.Fatalf("sent %v, failed %d; want every message failed", store.sent, len(store.failed))
+	}
+	for _, f := range store.failed {
+		if f.dead || f.errMsg != "digest: slack down" {
+			t.Errorf("failure = %+v, want a retry naming the notifier", f)
+		}
+	}
+}
+
+// TestDispatch_BurstStaysBounded pushes a burst through a slow notifier
+// under each policy and checks that no more than the queue size ever
+// waits for a worker.
+func TestDispatch_BurstStaysBounded(t *testing.T) {
+	const burst, queueSize = 200, 5
+	tests := []struct {
+		policy string
+		check  func(t *testing.T, d *Dispatcher, sent int, n *digestNotifier)
+	}{
+		{QueueBlock, func(t *testing.T, d *Dispatcher, sent int, n *digestNotifier) {
+			if sent != burst || d.Dropped() != 0 || len(n.digests) != 0 {
+				t.Errorf("sent %d, dropped %d, digests %v; want every message sent singly", sent, d.Dropped(), n.digests)
+			}
+		}},
+		{QueueDrop, func(t *testing.T, d *Dispatcher, sent int, n *digestNotifier) {
+			if d.Dropped() == 0 || int64(sent)+d.Dropped() != burst {
+				t.Errorf("sent %d, dropped %d; want drops accounting for the rest", sent, d.Dropped())
+			}
+		}},
+		{QueueDropOldest, func(t *testing.T, d *Dispatcher, sent int, n *digestNotifier) {
+			if d.Dropped() == 0 || int64(sent)+d.Dropped() != burst {
+				t.Errorf("sent %d, dropped %d; want drops accounting for the rest", sent, d.Dropped())
+			}
+		}},
+		{QueueDigest, func(t *testing.T, d *Dispatcher, sent int, n *digestNotifier) {
+			if sent != burst || d.Dropped() != 0 || len(n.digests) != 1 || n.single+n.digests[0] != burst {
+				t.Errorf("sent %d, single %d, digests %v; want the overflow in one digest", sent, n.single, n.digests)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			store := &mockOutboxStore{msgs: messages(t, burst)}
+			n := &digestNotifier{delay: 200 * time.Microsecond}
+			d := newTestDispatcher(store, n)
+			WithWorkers(2)(d)
+			WithQueue(queueSize, tt.policy)(d)
+
+			var peak atomic.Int64
+			stop := make(chan struct{})
+			sampled := make(chan struct{})
+			go func() {
+				defer close(sampled)
+				for {
+					if depth := d.QueueDepth(); depth > peak.Load() {
+						peak.Store(depth)
+					}
+					select {
+					case <-stop:
+						return
+					default:
+						runtime.Gosched()
+					}
+				}
+			}()
+			d.poller.Poll(context.Background())
+			close(stop)
+			<-sampled
+
+			// The message being handed to the queue counts as waiting too.
+			if p := peak.Load(); p > queueSize+1 {
+				t.Errorf("peak queue depth = %d, want at most %d", p, queueSize+1)
+			}
+			if d.QueueDepth() != 0 {
+				t.Errorf("queue depth = %d after the batch, want 0", d.QueueDepth())
+			}
+			tt.check(t, d, len(store.sent), n)
+		})
+	}
+}
+
+// --- Run tests ---
+
+func TestRun_FinishesClaimedBatchOnCancel(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	store := &mockOutboxStore{msgs: []model.OutboxMessage{message(t, 1, 0)}}
+	d := newTestDispatcher(store, &mockNotifier{
+		notifyFn: func(ctx context.Context, cert model.MatchedCertificate) error {
+			close(entered)
+			<-release
+			return ctx.Err()
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+
+	<-entered
+	cancel()
+	close(release)
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if len(store.sent) != 1 {
+		t.Errorf("sent = %v, want in-flight delivery to complete", store.sent)
+	}
+}
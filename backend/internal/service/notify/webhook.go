@@ -0,0 +1,78 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+// WebhookNotifier POSTs each match as JSON to a fixed URL.
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:    url,
+		client: &http.Client{Timeout: deliveryTimeout},
+	}
+}
+
+func (w *WebhookNotifier) Name() string { return "webhook" }
+
+type webhookPayload struct {
+	Event string                   `json:"event"`
+	Data  model.MatchedCertificate `json:"data"`
+}
+
+type webhookDigestPayload struct {
+	Event string `json:"event"`
+	Data  struct {
+		Count   int                        `json:"count"`
+		Matches []model.MatchedCertificate `json:"matches"`
+	} `json:"data"`
+}
+
+func (w *WebhookNotifier) Notify(ctx context.Context, cert model.MatchedCertificate) error {
+	body, err := json.Marshal(webhookPayload{Event: "match.created", Data: cert})
+	if err != nil {
+		return err
+	}
+	return w.post(ctx, body)
+}
+
+// NotifyDigest POSTs certs as a single match.digest event.
+func (w *WebhookNotifier) NotifyDigest(ctx context.Context, certs []model.MatchedCertificate) error {
+	payload := webhookDigestPayload{Event: "match.digest"}
+	payload.Data.Count, payload.Data.Matches = len(certs), certs
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return w.post(ctx, body)
+}
+
+// post sends body, an encoded event, to the webhook URL.
+func (w *WebhookNotifier) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+func TestWebhookNotifier_Success(t *testing.T) {
+	var got webhookPayload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("method = %s, want POST", r.Method)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", ct)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	n := NewWebhookNotifier(srv.URL)
+	err := n.Notify(context.Background(), model.MatchedCertificate{ID: 7, MatchedDomain: "login.example.com"})
+	if err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if got.Event != "match.created" || got.Data.ID != 7 || got.Data.MatchedDomain != "login.example.com" {
+		t.Errorf("payload = %+v", got)
+	}
+}
+
+func TestWebhookNotifier_Non2xx(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	n := NewWebhookNotifier(srv.URL)
+	if err := n.Notify(context.Background(), model.MatchedCertificate{}); err == nil {
+		t.Error("Notify() error = nil, want error for 502")
+	}
+}
+
+func TestWebhookNotifier_Digest(t *testing.T) {
+	var got webhookDigestPayload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	n := NewWebhookNotifier(srv.URL)
+	err := n.NotifyDigest(context.Background(), []model.MatchedCertificate{{ID: 7}, {ID: 8}})
+	if err != nil {
+		t.Fatalf("NotifyDigest() error = %v", err)
+	}
+	if got.Event != "match.digest" || got.Data.Count != 2 || len(got.Data.Matches) != 2 || got.Data.Matches[1].ID != 8 {
+		t.Errorf("payload = %+v", got)
+	}
+}
@@ -0,0 +1,222 @@
+// Package outbox runs the poll loop shared by the notification dispatcher
+// and the webhook worker: claim a batch of due rows under a lease, deliver
+// it through a bounded queue feeding a fixed pool of workers, and prune old
+// rows once an hour. Failed rows are retried after Backoff; rows a full
+// queue turns away are marked dropped and never retried.
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	retryBase = 30 * time.Second
+	retryMax  = time.Hour
+	// PruneEvery is how often a Poller prunes.
+	PruneEvery = time.Hour
+)
+
+// Queue policies: what a Poller does with a claimed item when the delivery
+// queue is full.
+const (
+	// QueueBlock waits for a worker to free a slot.
+	QueueBlock = "block"
+	// QueueDrop hands the item to Drop, which records it as dropped for
+	// good.
+	QueueDrop = "drop"
+	// QueueDropOldest makes room by dropping the longest-waiting item
+	// instead.
+	QueueDropOldest = "drop_oldest"
+	// QueueDigest holds the items that do not fit and hands them to Digest
+	// once the queue has drained.
+	QueueDigest = "digest"
+)
+
+// Poller claims and delivers batches of T. Its fields are set by the
+// owning dispatcher before the first Poll and not changed afterwards.
+type Poller[T any] struct {
+	// Name identifies the queue in logs, e.g. "outbox".
+	Name  string
+	Claim func(ctx context.Context, limit int, leaseUntil time.Time) ([]T, error)
+	Prune func(ctx context.Context, before time.Time) (int64, error)
+	// Deliver sends one item and records the outcome.
+	Deliver func(ctx context.Context, item T)
+	// Digest receives the overflow under QueueDigest; when nil, those
+	// items are delivered one at a time after the queue has drained.
+	Digest func(ctx context.Context, items []T)
+	// Drop moves the claimed rows with these IDs, which the drop policies
+	// turned away, to a terminal state while the claim's lease still holds.
+	Drop func(ctx context.Context, ids []int64) error
+	// ID names an item in logs and to Drop.
+	ID func(item T) int64
+
+	Interval  time.Duration
+	BatchSize int
+	Workers   int
+	QueueSize int
+	Policy    string
+	Retention time.Duration
+	// Timeout bounds the delivery of one item; the claim's lease covers
+	// every item a worker takes hitting it.
+	Timeout time.Duration
+	Now     func() time.Time
+
+	lastPrune time.Time
+	queued    atomic.Int64
+	dropped   atomic.Int64
+	digested  atomic.Int64
+}
+
+// Run polls until ctx is canceled. A batch already claimed is delivered to
+// completion before Run returns, so callers should wait for it on shutdown.
+func (p *Poller[T]) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		// Deliveries outlive ctx so shutdown never abandons a claimed batch.
+		p.Poll(context.WithoutCancel(ctx))
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// QueueDepth returns how many claimed items are waiting for a worker.
+func (p *Poller[T]) QueueDepth() int64 {
+	return p.queued.Load()
+}
+
+// Dropped returns how many items the drop policies have turned away.
+func (p *Poller[T]) Dropped() int64 {
+	return p.dropped.Load()
+}
+
+// Digested returns how many items the digest policy has held for Digest.
+func (p *Poller[T]) Digested() int64 {
+	return p.digested.Load()
+}
+
+// Poll claims and delivers one batch, then prunes old rows if due.
+func (p *Poller[T]) Poll(ctx context.Context) {
+	now := p.Now()
+	rounds := (p.BatchSize + p.Workers - 1) / p.Workers
+	if p.Policy == QueueDigest {
+		// The overflow may then go out one item at a time.
+		rounds += p.BatchSize
+	}
+	items, err := p.Claim(ctx, p.BatchSize, now.Add(p.Timeout*time.Duration(rounds)))
+	if err != nil {
+		slog.Error("failed to claim due rows", "queue", p.Name, "error", err)
+		return
+	}
+
+	p.deliverAll(ctx, items)
+
+	if now.Sub(p.lastPrune) >= PruneEvery {
+		p.lastPrune = now
+		n, err := p.Prune(ctx, now.Add(-p.Retention))
+		if err != nil {
+			slog.Error("failed to prune old rows", "queue", p.Name, "error", err)
+		} else if n > 0 {
+			slog.Info("pruned old rows", "queue", p.Name, "rows", n)
+		}
+	}
+}
+
+// deliverAll queues items for at most p.Workers goroutines and waits for
+// them to drain the queue. Only this goroutine sends on the queue, so a
+// slot it frees stays free until it sends again.
+func (p *Poller[T]) deliverAll(ctx context.Context, items []T) {
+	if len(items) == 0 {
+		return
+	}
+	queue := make(chan T, p.QueueSize)
+	var wg sync.WaitGroup
+	for range min(p.Workers, len(items)) {
+		wg.Go(func() {
+			for item := range queue {
+				p.queued.Add(-1)
+				p.Deliver(ctx, item)
+			}
+		})
+	}
+
+	var overflow []T
+	var dropped []int64
+	for _, item := range items {
+		p.queued.Add(1)
+		if p.Policy == QueueBlock {
+			queue <- item
+			continue
+		}
+		select {
+		case queue <- item:
+			continue
+		default:
+			p.queued.Add(-1)
+		}
+		switch p.Policy {
+		case QueueDrop:
+			dropped = append(dropped, p.drop(item))
+		case QueueDropOldest:
+			// A worker may take the oldest first, freeing the slot anyway.
+			select {
+			case oldest := <-queue:
+				p.queued.Add(-1)
+				dropped = append(dropped, p.drop(oldest))
+			default:
+			}
+			p.queued.Add(1)
+			queue <- item
+		case QueueDigest:
+			overflow = append(overflow, item)
+		}
+	}
+	close(queue)
+	if len(dropped) > 0 {
+		if err := p.Drop(ctx, dropped); err != nil {
+			// They stay claimed and are retried once the lease expires.
+			slog.Error("failed to mark dropped rows", "queue", p.Name, "rows", len(dropped), "error", err)
+		}
+	}
+	wg.Wait()
+
+	if len(overflow) == 0 {
+		return
+	}
+	p.digested.Add(int64(len(overflow)))
+	if p.Digest != nil {
+		p.Digest(ctx, overflow)
+		return
+	}
+	for _, item := range overflow {
+		p.Deliver(ctx, item)
+	}
+}
+
+func (p *Poller[T]) drop(item T) int64 {
+	id := p.ID(item)
+	p.dropped.Add(1)
+	slog.Warn("delivery queue full, dropping row",
+		"queue", p.Name, "id", id, "queue_size", p.QueueSize, "policy", p.Policy)
+	return id
+}
+
+// Backoff doubles the retry delay per previous attempt, capped at an hour.
+func Backoff(attempts int) time.Duration {
+	delay := retryBase
+	for range attempts {
+		delay *= 2
+		if delay >= retryMax {
+			return retryMax
+		}
+	}
+	return delay
+}
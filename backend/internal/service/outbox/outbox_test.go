@@ -0,0 +1,102 @@
+package outbox
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestPoller returns a Poller over ids 1..n that records deliveries.
+func newTestPoller(n, workers, queueSize int, policy string) (*Poller[int64], func() []int64) {
+	var (
+		mu        sync.Mutex
+		delivered []int64
+	)
+	items := make([]int64, n)
+	for i := range items {
+		items[i] = int64(i + 1)
+	}
+	release := make(chan struct{})
+	p := &Poller[int64]{
+		Name: "test",
+		Claim: func(ctx context.Context, limit int, leaseUntil time.Time) ([]int64, error) {
+			// Hold the workers until every item has been queued or turned away.
+			time.AfterFunc(20*time.Millisecond, func() { close(release) })
+			return items, nil
+		},
+		Prune: func(ctx context.Context, before time.Time) (int64, error) { return 0, nil },
+		Deliver: func(ctx context.Context, id int64) {
+			<-release
+			mu.Lock()
+			defer mu.Unlock()
+			delivered = append(delivered, id)
+		},
+		ID:        func(id int64) int64 { return id },
+		BatchSize: n,
+		Workers:   workers,
+		QueueSize: queueSize,
+		Policy:    policy,
+		Timeout:   time.Second,
+		Now:       time.Now,
+	}
+	return p, func() []int64 {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Sorted(slices.Values(delivered))
+	}
+}
+
+func TestPoll_DigestWithoutDigestDeliversOverflowOneByOne(t *testing.T) {
+	p, delivered := newTestPoller(6, 1, 1, QueueDigest)
+
+	p.Poll(context.Background())
+
+	if got := delivered(); !slices.Equal(got, []int64{1, 2, 3, 4, 5, 6}) {
+		t.Errorf("delivered = %v, want every item", got)
+	}
+	if p.Digested() == 0 || p.Dropped() != 0 || p.QueueDepth() != 0 {
+		t.Errorf("digested/dropped/depth = %d/%d/%d, want overflow held, none dropped",
+			p.Digested(), p.Dropped(), p.QueueDepth())
+	}
+}
+
+func TestPoll_DropMarksOverflowDropped(t *testing.T) {
+	for _, policy := range []string{QueueDrop, QueueDropOldest} {
+		p, delivered := newTestPoller(6, 1, 1, policy)
+		var marked []int64
+		p.Drop = func(ctx context.Context, ids []int64) error {
+			marked = append(marked, ids...)
+			return nil
+		}
+
+		p.Poll(context.Background())
+
+		got := delivered()
+		if int64(len(got))+p.Dropped() != 6 || p.Dropped() == 0 {
+			t.Errorf("%s: delivered %d, dropped %d; want the overflow dropped", policy, len(got), p.Dropped())
+		}
+		// Every item is either delivered or marked dropped, never both.
+		if all := slices.Sorted(slices.Values(append(marked, got...))); !slices.Equal(all, []int64{1, 2, 3, 4, 5, 6}) {
+			t.Errorf("%s: delivered %v, marked dropped %v; want each item exactly once", policy, got, marked)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, retryBase},
+		{1, 2 * retryBase},
+		{3, 8 * retryBase},
+		{20, retryMax},
+	}
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
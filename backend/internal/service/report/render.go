@@ -0,0 +1,204 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"when": formatTime,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>CT monitor daily report {{.Date}}</title></head>
+<body style="font-family: sans-serif">
+<h1>CT monitor daily report: {{.Date}}</h1>
+<p>{{.NewMatches}} new matches between {{when .From}} and {{when .To}} ({{.Timezone}}).</p>
+
+<h2>New matches by keyword</h2>
+{{if .ByKeyword}}<table>
+<tr><th align="left">Keyword</th><th align="right">Matches</th></tr>
+{{range .ByKeyword}}<tr><td>{{.Key}}</td><td align="right">{{.Count}}</td></tr>
+{{end}}</table>{{else}}<p>None.</p>{{end}}
+
+<h2>Top domains</h2>
+{{if .TopDomains}}<table>
+<tr><th align="left">Registrable domain</th><th align="right">Matches</th></tr>
+{{range .TopDomains}}<tr><td>{{.Domain}}</td><td align="right">{{.Count}}</td></tr>
+{{end}}</table>{{else}}<p>None.</p>{{end}}
+
+<h2>High-risk matches</h2>
+{{if .HighRisk}}<table>
+<tr><th align="right">Risk</th><th align="left">Domain</th><th align="left">Keyword</th><th align="left">Discovered</th></tr>
+{{range .HighRisk}}<tr><td align="right">{{.RiskScore}}</td><td>{{.MatchedDomain}}</td><td>{{.Keyword}}</td><td>{{when .DiscoveredAt}}</td></tr>
+{{end}}</table>{{else}}<p>None.</p>{{end}}
+
+<h2>Monitor health</h2>
+<ul>
+<li>Running: {{if .Monitor.IsRunning}}yes{{else}}no{{end}}</li>
+<li>Last cycle: {{with .Monitor.LastRunAt}}{{when .}}{{else}}never{{end}}</li>
+<li>Backlog: {{.Monitor.Backlog}} entries</li>
+<li>Last error: {{with .Monitor.LastError}}{{.}} ({{$.Monitor.LastErrorCount}} times){{else}}none{{end}}</li>
+</ul>
+<p style="color: #666">Generated {{when .GeneratedAt}}.</p>
+</body>
+</html>
+`))
+
+// HTML renders r as a standalone HTML document for email.
+func HTML(r *model.DailyReport) ([]byte, error) {
+	r = localize(r)
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, r); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Text renders r as compact plain text, one section per paragraph.
+func Text(r *model.DailyReport) string {
+	r = localize(r)
+	var b strings.Builder
+	fmt.Fprintf(&b, "CT monitor daily report %s (%s): %d new matches\n", r.Date, r.Timezone, r.NewMatches)
+	writeSection(&b, "By keyword", len(r.ByKeyword), func(i int) string {
+		return fmt.Sprintf("%s: %d", r.ByKeyword[i].Key, r.ByKeyword[i].Count)
+	})
+	writeSection(&b, "Top domains", len(r.TopDomains), func(i int) string {
+		return fmt.Sprintf("%s: %d", r.TopDomains[i].Domain, r.TopDomains[i].Count)
+	})
+	writeSection(&b, "High risk", len(r.HighRisk), func(i int) string {
+		m := r.HighRisk[i]
+		return fmt.Sprintf("%d %s (%s)", m.RiskScore, m.MatchedDomain, m.Keyword)
+	})
+	fmt.Fprintf(&b, "\nMonitor: %s\n", health(r.Monitor))
+	return b.String()
+}
+
+func writeSection(b *strings.Builder, title string, n int, line func(i int) string) {
+	fmt.Fprintf(b, "\n%s:\n", title)
+	if n == 0 {
+		b.WriteString("  none\n")
+		return
+	}
+	for i := range n {
+		fmt.Fprintf(b, "  %s\n", line(i))
+	}
+}
+
+// health summarizes the monitor's state in one line.
+func health(h model.ReportHealth) string {
+	status := "stopped"
+	if h.IsRunning {
+		status = "running"
+	}
+	last := "never"
+	if h.LastRunAt != nil {
+		last = formatTime(*h.LastRunAt)
+	}
+	s := fmt.Sprintf("%s, last cycle %s, backlog %d", status, last, h.Backlog)
+	if h.LastError != "" {
+		s += fmt.Sprintf(", last error %q (%d times)", h.LastError, h.LastErrorCount)
+	}
+	return s
+}
+
+// localize returns a copy of r with its times in the report's timezone; a
+// report read back from storage carries them with a bare offset.
+func localize(r *model.DailyReport) *model.DailyReport {
+	loc, err := time.LoadLocation(r.Timezone)
+	if err != nil {
+		return r
+	}
+	c := *r
+	c.From, c.To, c.GeneratedAt = r.From.In(loc), r.To.In(loc), r.GeneratedAt.In(loc)
+	if r.Monitor.LastRunAt != nil {
+		t := r.Monitor.LastRunAt.In(loc)
+		c.Monitor.LastRunAt = &t
+	}
+	c.HighRisk = make([]model.ReportMatch, len(r.HighRisk))
+	for i, m := range r.HighRisk {
+		m.DiscoveredAt = m.DiscoveredAt.In(loc)
+		c.HighRisk[i] = m
+	}
+	return &c
+}
+
+func formatTime(t time.Time) string {
+	return t.Format("2006-01-02 15:04 MST")
+}
+
+// slackMessage is an incoming-webhook payload; Text is the notification
+// fallback for clients that do not show blocks.
+type slackMessage struct {
+	Text   string       `json:"text"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+type slackBlock struct {
+	Type     string      `json:"type"`
+	Text     *slackText  `json:"text,omitempty"`
+	Fields   []slackText `json:"fields,omitempty"`
+	Elements []slackText `json:"elements,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+func mrkdwn(s string) slackText { return slackText{Type: "mrkdwn", Text: s} }
+
+// slackEscape escapes the characters Slack treats as markup in text.
+var slackEscape = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// slackMaxLines caps each list in a Slack message; Slack rejects section
+// texts over 3000 characters.
+const slackMaxLines = 10
+
+// Slack renders r as a Slack Block Kit message.
+func Slack(r *model.DailyReport) ([]byte, error) {
+	r = localize(r)
+	title := fmt.Sprintf("CT monitor daily report %s: %d new matches", r.Date, r.NewMatches)
+	list := func(n int, line func(i int) string) string {
+		if n == 0 {
+			return "_none_"
+		}
+		lines := make([]string, 0, min(n, slackMaxLines)+1)
+		for i := range min(n, slackMaxLines) {
+			lines = append(lines, "• "+line(i))
+		}
+		if n > slackMaxLines {
+			lines = append(lines, fmt.Sprintf("_and %d more_", n-slackMaxLines))
+		}
+		return strings.Join(lines, "\n")
+	}
+	msg := slackMessage{
+		Text: title,
+		Blocks: []slackBlock{
+			{Type: "header", Text: &slackText{Type: "plain_text", Text: title}},
+			{Type: "section", Fields: []slackText{
+				mrkdwn(fmt.Sprintf("*By keyword*\n%s", list(len(r.ByKeyword), func(i int) string {
+					return fmt.Sprintf("%s: %d", slackEscape.Replace(r.ByKeyword[i].Key), r.ByKeyword[i].Count)
+				}))),
+				mrkdwn(fmt.Sprintf("*Top domains*\n%s", list(len(r.TopDomains), func(i int) string {
+					return fmt.Sprintf("%s: %d", slackEscape.Replace(r.TopDomains[i].Domain), r.TopDomains[i].Count)
+				}))),
+			}},
+			{Type: "section", Text: &slackText{Type: "mrkdwn", Text: fmt.Sprintf("*High risk*\n%s",
+				list(len(r.HighRisk), func(i int) string {
+					m := r.HighRisk[i]
+					return fmt.Sprintf("`%d` %s (%s)", m.RiskScore,
+						slackEscape.Replace(m.MatchedDomain), slackEscape.Replace(m.Keyword))
+				}))}},
+			{Type: "context", Elements: []slackText{
+				mrkdwn("Monitor: " + slackEscape.Replace(health(r.Monitor)) + " · " + r.Timezone),
+			}},
+		},
+	}
+	return json.Marshal(msg)
+}
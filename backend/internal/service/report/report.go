@@ -0,0 +1,206 @@
+// Package report builds the daily digest: the matches first seen on one
+// calendar day in a configured timezone (per keyword, top registrable
+// domains, the riskiest hits) plus the monitor's health. Reports are stored
+// so they can be served again, rendered as HTML for email and as text or
+// Slack Block Kit for chat, and sent to the configured Senders each day.
+package report
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+// DateLayout is the format of a report's date.
+const DateLayout = "2006-01-02"
+
+const (
+	defaultHour    = 8
+	defaultTopN    = 10
+	defaultMinRisk = 50
+)
+
+type summarizer interface {
+	Summary(ctx context.Context, from, to time.Time, topN, minRisk int) (*model.MatchSummary, error)
+}
+
+type stateGetter interface {
+	Get(ctx context.Context) (*model.MonitorState, error)
+}
+
+type reportSaver interface {
+	Save(ctx context.Context, report *model.DailyReport) error
+}
+
+// Sender delivers a generated report, e.g. to a Slack channel.
+type Sender interface {
+	Name() string
+	Send(ctx context.Context, report *model.DailyReport) error
+}
+
+// Generator builds and stores daily reports, and with Run sends the
+// previous day's report every day at a fixed hour.
+type Generator struct {
+	certs   summarizer
+	state   stateGetter
+	store   reportSaver
+	loc     *time.Location
+	hour    int
+	topN    int
+	minRisk int
+	senders []Sender
+	now     func() time.Time
+}
+
+type Option func(*Generator)
+
+// WithHour sets the hour of the day (0-23, in the report timezone) at which
+// Run generates the previous day's report. The default is 8.
+func WithHour(h int) Option {
+	return func(g *Generator) {
+		g.hour = h
+	}
+}
+
+// WithTopN caps the top domains and high-risk matches listed. The default
+// is 10.
+func WithTopN(n int) Option {
+	return func(g *Generator) {
+		if n > 0 {
+			g.topN = n
+		}
+	}
+}
+
+// WithMinRisk sets the lowest risk score listed among the high-risk
+// matches. The default is 50.
+func WithMinRisk(score int) Option {
+	return func(g *Generator) {
+		g.minRisk = score
+	}
+}
+
+// WithSender has Run deliver each report to s. Repeat it to add more.
+func WithSender(s Sender) Option {
+	return func(g *Generator) {
+		g.senders = append(g.senders, s)
+	}
+}
+
+// New returns a Generator for days in loc.
+func New(certs summarizer, state stateGetter, store reportSaver, loc *time.Location, opts ...Option) *Generator {
+	g := &Generator{
+		certs:   certs,
+		state:   state,
+		store:   store,
+		loc:     loc,
+		hour:    defaultHour,
+		topN:    defaultTopN,
+		minRisk: defaultMinRisk,
+		now:     time.Now,
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// Generate builds the report for date (YYYY-MM-DD in the generator's
+// timezone) and stores it, replacing any earlier report for that day. A
+// day without matches still yields a report.
+func (g *Generator) Generate(ctx context.Context, date string) (*model.DailyReport, error) {
+	day, err := time.ParseInLocation(DateLayout, date, g.loc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid report date %q: %w", date, err)
+	}
+	// Midnight to midnight, so DST days are 23 or 25 hours long.
+	from := day
+	to := time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, g.loc)
+
+	summary, err := g.certs.Summary(ctx, from, to, g.topN, g.minRisk)
+	if err != nil {
+		return nil, fmt.Errorf("summarize matches: %w", err)
+	}
+	state, err := g.state.Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get monitor state: %w", err)
+	}
+
+	report := &model.DailyReport{
+		Date:         date,
+		Timezone:     g.loc.String(),
+		From:         from,
+		To:           to,
+		GeneratedAt:  g.now(),
+		MatchSummary: *summary,
+		Monitor: model.ReportHealth{
+			IsRunning:      state.IsRunning,
+			LastRunAt:      state.LastRunAt,
+			Backlog:        max(state.LastTreeSize-state.LastProcessedIndex, 0),
+			TotalProcessed: state.TotalProcessed,
+			LastError:      state.LastError,
+			LastErrorCount: state.LastErrorCount,
+		},
+	}
+	if err := g.store.Save(ctx, report); err != nil {
+		return nil, fmt.Errorf("save report: %w", err)
+	}
+	return report, nil
+}
+
+// Run generates the previous day's report at the configured hour every day
+// and hands it to each Sender, until ctx is canceled. Failures are logged;
+// a missed day can be generated later through the API.
+func (g *Generator) Run(ctx context.Context) {
+	for {
+		next := nextRun(g.now(), g.loc, g.hour)
+		timer := time.NewTimer(next.Sub(g.now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		g.runOnce(ctx, next)
+	}
+}
+
+// PreviousDay returns yesterday's date in the generator's timezone, the
+// last complete day.
+func (g *Generator) PreviousDay() string {
+	return g.dayBefore(g.now())
+}
+
+func (g *Generator) dayBefore(t time.Time) string {
+	return t.In(g.loc).AddDate(0, 0, -1).Format(DateLayout)
+}
+
+// runOnce generates and sends the report for the day before at.
+func (g *Generator) runOnce(ctx context.Context, at time.Time) {
+	date := g.dayBefore(at)
+	report, err := g.Generate(ctx, date)
+	if err != nil {
+		slog.ErrorContext(ctx, "daily report failed", "date", date, "error", err)
+		return
+	}
+	slog.InfoContext(ctx, "daily report generated", "date", date, "new_matches", report.NewMatches)
+	for _, s := range g.senders {
+		if err := s.Send(ctx, report); err != nil {
+			slog.ErrorContext(ctx, "daily report delivery failed", "date", date, "sender", s.Name(), "error", err)
+		}
+	}
+}
+
+// nextRun returns the first hour:00 in loc strictly after now. An hour
+// skipped by a DST change resolves as time.Date does.
+func nextRun(now time.Time, loc *time.Location, hour int) time.Time {
+	local := now.In(loc)
+	next := time.Date(local.Year(), local.Month(), local.Day(), hour, 0, 0, 0, loc)
+	if !next.After(now) {
+		next = time.Date(local.Year(), local.Month(), local.Day()+1, hour, 0, 0, 0, loc)
+	}
+	return next
+}
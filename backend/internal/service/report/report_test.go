@@ -0,0 +1,526 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+// --- mocks ---
+
+type summaryCall struct {
+	from, to      time.Time
+	topN, minRisk int
+}
+
+type mockSummarizer struct {
+	calls   []summaryCall
+	summary *model.MatchSummary
+	err     error
+}
+
+func (m *mockSummarizer) Summary(ctx context.Context, from, to time.Time, topN, minRisk int) (*model.MatchSummary, error) {
+	m.calls = append(m.calls, summaryCall{from, to, topN, minRisk})
+	if m.err != nil {
+		return nil, m.err
+	}
+	if m.summary != nil {
+		return m.summary, nil
+	}
+	return &model.MatchSummary{
+		ByKeyword:  []model.StatsBucket{},
+		TopDomains: []model.DomainCount{},
+		HighRisk:   []model.ReportMatch{},
+	}, nil
+}
+
+type mockState struct {
+	state model.MonitorState
+}
+
+func (m *mockState) Get(ctx context.Context) (*model.MonitorState, error) {
+	s := m.state
+	return &s, nil
+}
+
+type mockSaver struct {
+	saved []*model.DailyReport
+	err   error
+}
+
+func (m *mockSaver) Save(ctx context.Context, report *model.DailyReport) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.saved = append(m.saved, report)
+	return nil
+}
+
+type mockSender struct {
+	sent []*model.DailyReport
+	err  error
+}
+
+func (m *mockSender) Name() string { return "mock" }
+
+func (m *mockSender) Send(ctx context.Context, report *model.DailyReport) error {
+	m.sent = append(m.sent, report)
+	return m.err
+}
+
+func loadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("LoadLocation(%q): %v", name, err)
+	}
+	return loc
+}
+
+func sampleReport() *model.DailyReport {
+	runAt := time.Date(2026, 3, 10, 7, 55, 0, 0, time.UTC)
+	return &model.DailyReport{
+		Date:        "2026-03-09",
+		Timezone:    "UTC",
+		From:        time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC),
+		To:          time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC),
+		GeneratedAt: time.Date(2026, 3, 10, 8, 0, 0, 0, time.UTC),
+		MatchSummary: model.MatchSummary{
+			NewMatches: 3,
+			ByKeyword:  []model.StatsBucket{{Key: "paypal", Count: 2}, {Key: "<script>", Count: 1}},
+			TopDomains: []model.DomainCount{{Domain: "paypal-login.com", Count: 2}},
+			HighRisk: []model.ReportMatch{{
+				ID: 7, MatchedDomain: "paypal-login.com", Keyword: "paypal", RiskScore: 90,
+				DiscoveredAt: time.Date(2026, 3, 9, 13, 0, 0, 0, time.UTC),
+			}},
+		},
+		Monitor: model.ReportHealth{IsRunning: true, LastRunAt: &runAt, Backlog: 12},
+	}
+}
+
+func emptyReport() *model.DailyReport {
+	r := sampleReport()
+	r.MatchSummary = model.MatchSummary{
+		ByKeyword:  []model.StatsBucket{},
+		TopDomains: []model.DomainCount{},
+		HighRisk:   []model.ReportMatch{},
+	}
+	r.Monitor = model.ReportHealth{}
+	return r
+}
+
+// --- Generate ---
+
+func TestGenerate_WindowInTimezone(t *testing.T) {
+	ny := loadLocation(t, "America/New_York")
+	tests := []struct {
+		name     string
+		date     string
+		from, to time.Time
+		hours    float64
+	}{
+		{
+			name:  "standard day",
+			date:  "2026-01-15",
+			from:  time.Date(2026, 1, 15, 5, 0, 0, 0, time.UTC),
+			to:    time.Date(2026, 1, 16, 5, 0, 0, 0, time.UTC),
+			hours: 24,
+		},
+		{
+			name:  "spring forward",
+			date:  "2026-03-08",
+			from:  time.Date(2026, 3, 8, 5, 0, 0, 0, time.UTC),
+			to:    time.Date(2026, 3, 9, 4, 0, 0, 0, time.UTC),
+			hours: 23,
+		},
+		{
+			name:  "fall back",
+			date:  "2026-11-01",
+			from:  time.Date(2026, 11, 1, 4, 0, 0, 0, time.UTC),
+			to:    time.Date(2026, 11, 2, 5, 0, 0, 0, time.UTC),
+			hours: 25,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			certs := &mockSummarizer{}
+			g := New(certs, &mockState{}, &mockSaver{}, ny)
+
+			r, err := g.Generate(context.Background(), tt.date)
+			if err != nil {
+				t.Fatalf("Generate: %v", err)
+			}
+			if len(certs.calls) != 1 {
+				t.Fatalf("Summary calls = %d, want 1", len(certs.calls))
+			}
+			c := certs.calls[0]
+			if !c.from.Equal(tt.from) || !c.to.Equal(tt.to) {
+				t.Errorf("window = [%s, %s), want [%s, %s)", c.from.UTC(), c.to.UTC(), tt.from, tt.to)
+			}
+			if got := c.to.Sub(c.from).Hours(); got != tt.hours {
+				t.Errorf("window length = %vh, want %vh", got, tt.hours)
+			}
+			if r.Date != tt.date || r.Timezone != "America/New_York" {
+				t.Errorf("report date/timezone = %s/%s", r.Date, r.Timezone)
+			}
+		})
+	}
+}
+
+func TestGenerate_EmptyDay(t *testing.T) {
+	saver := &mockSaver{}
+	g := New(&mockSummarizer{}, &mockState{}, saver, time.UTC)
+
+	r, err := g.Generate(context.Background(), "2026-03-09")
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if len(saver.saved) != 1 || saver.saved[0] != r {
+		t.Fatalf("saved = %v, want the generated report", saver.saved)
+	}
+
+	body, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"new_matches":0`, `"by_keyword":[]`, `"top_domains":[]`, `"high_risk":[]`} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("JSON missing %s: %s", want, body)
+		}
+	}
+}
+
+func TestGenerate_OptionsAndHealth(t *testing.T) {
+	runAt := time.Date(2026, 3, 10, 7, 0, 0, 0, time.UTC)
+	certs := &mockSummarizer{}
+	state := &mockState{state: model.MonitorState{
+		IsRunning: true, LastRunAt: &runAt, LastTreeSize: 1000, LastProcessedIndex: 940,
+		TotalProcessed: 5000, LastError: "boom", LastErrorCount: 3,
+	}}
+	g := New(certs, state, &mockSaver{}, time.UTC, WithTopN(5), WithMinRisk(70))
+
+	r, err := g.Generate(context.Background(), "2026-03-09")
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if c := certs.calls[0]; c.topN != 5 || c.minRisk != 70 {
+		t.Errorf("topN/minRisk = %d/%d, want 5/70", c.topN, c.minRisk)
+	}
+	want := model.ReportHealth{
+		IsRunning: true, LastRunAt: &runAt, Backlog: 60,
+		TotalProcessed: 5000, LastError: "boom", LastErrorCount: 3,
+	}
+	if r.Monitor != want {
+		t.Errorf("Monitor = %+v, want %+v", r.Monitor, want)
+	}
+}
+
+func TestGenerate_BacklogNeverNegative(t *testing.T) {
+	state := &mockState{state: model.MonitorState{LastTreeSize: 10, LastProcessedIndex: 20}}
+	g := New(&mockSummarizer{}, state, &mockSaver{}, time.UTC)
+
+	r, err := g.Generate(context.Background(), "2026-03-09")
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if r.Monitor.Backlog != 0 {
+		t.Errorf("Backlog = %d, want 0", r.Monitor.Backlog)
+	}
+}
+
+func TestGenerate_Errors(t *testing.T) {
+	g := New(&mockSummarizer{}, &mockState{}, &mockSaver{}, time.UTC)
+	if _, err := g.Generate(context.Background(), "2026-13-01"); err == nil {
+		t.Error("invalid date: expected error")
+	}
+
+	g = New(&mockSummarizer{err: errors.New("db down")}, &mockState{}, &mockSaver{}, time.UTC)
+	if _, err := g.Generate(context.Background(), "2026-03-09"); err == nil || !strings.Contains(err.Error(), "db down") {
+		t.Errorf("summary failure: err = %v", err)
+	}
+
+	g = New(&mockSummarizer{}, &mockState{}, &mockSaver{err: errors.New("disk full")}, time.UTC)
+	if _, err := g.Generate(context.Background(), "2026-03-09"); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("save failure: err = %v", err)
+	}
+}
+
+// --- scheduling ---
+
+func TestNextRun(t *testing.T) {
+	ny := loadLocation(t, "America/New_York")
+	tokyo := loadLocation(t, "Asia/Tokyo")
+	tests := []struct {
+		name string
+		now  time.Time
+		loc  *time.Location
+		hour int
+		want time.Time
+	}{
+		{
+			name: "later today",
+			now:  time.Date(2026, 3, 10, 6, 0, 0, 0, time.UTC),
+			loc:  time.UTC,
+			hour: 8,
+			want: time.Date(2026, 3, 10, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "already passed",
+			now:  time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC),
+			loc:  time.UTC,
+			hour: 8,
+			want: time.Date(2026, 3, 11, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "exactly at the hour",
+			now:  time.Date(2026, 3, 10, 8, 0, 0, 0, time.UTC),
+			loc:  time.UTC,
+			hour: 8,
+			want: time.Date(2026, 3, 11, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "local day differs from UTC day",
+			now:  time.Date(2026, 3, 10, 23, 30, 0, 0, time.UTC), // 08:30 on the 11th in Tokyo
+			loc:  tokyo,
+			hour: 8,
+			want: time.Date(2026, 3, 11, 23, 0, 0, 0, time.UTC), // 08:00 on the 12th in Tokyo
+		},
+		{
+			name: "across spring forward",
+			now:  time.Date(2026, 3, 7, 14, 0, 0, 0, time.UTC), // 09:00 EST
+			loc:  ny,
+			hour: 8,
+			want: time.Date(2026, 3, 8, 12, 0, 0, 0, time.UTC), // 08:00 EDT
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextRun(tt.now, tt.loc, tt.hour); !got.Equal(tt.want) {
+				t.Errorf("nextRun = %s, want %s", got.UTC(), tt.want)
+			}
+		})
+	}
+}
+
+func TestRunOnce_ReportsPreviousDayAndSends(t *testing.T) {
+	tokyo := loadLocation(t, "Asia/Tokyo")
+	certs := &mockSummarizer{}
+	sender := &mockSender{}
+	g := New(certs, &mockState{}, &mockSaver{}, tokyo, WithSender(sender))
+
+	// 08:00 on March 10 in Tokyo is still March 9 in UTC.
+	g.runOnce(context.Background(), time.Date(2026, 3, 9, 23, 0, 0, 0, time.UTC))
+
+	if len(sender.sent) != 1 {
+		t.Fatalf("sent = %d, want 1", len(sender.sent))
+	}
+	if got := sender.sent[0].Date; got != "2026-03-09" {
+		t.Errorf("Date = %s, want 2026-03-09", got)
+	}
+	if from := certs.calls[0].from; !from.Equal(time.Date(2026, 3, 8, 15, 0, 0, 0, time.UTC)) {
+		t.Errorf("from = %s, want 2026-03-08 15:00 UTC", from.UTC())
+	}
+}
+
+func TestRunOnce_SenderFailureDoesNotStopOthers(t *testing.T) {
+	failing := &mockSender{err: errors.New("unreachable")}
+	ok := &mockSender{}
+	g := New(&mockSummarizer{}, &mockState{}, &mockSaver{}, time.UTC, WithSender(failing), WithSender(ok))
+
+	g.runOnce(context.Background(), time.Date(2026, 3, 10, 8, 0, 0, 0, time.UTC))
+
+	if len(failing.sent) != 1 || len(ok.sent) != 1 {
+		t.Errorf("sent = %d/%d, want 1/1", len(failing.sent), len(ok.sent))
+	}
+}
+
+func TestRunOnce_GenerateFailureSendsNothing(t *testing.T) {
+	sender := &mockSender{}
+	g := New(&mockSummarizer{err: errors.New("db down")}, &mockState{}, &mockSaver{}, time.UTC, WithSender(sender))
+
+	g.runOnce(context.Background(), time.Date(2026, 3, 10, 8, 0, 0, 0, time.UTC))
+
+	if len(sender.sent) != 0 {
+		t.Errorf("sent = %d, want 0", len(sender.sent))
+	}
+}
+
+func TestRun_StopsOnCancel(t *testing.T) {
+	g := New(&mockSummarizer{}, &mockState{}, &mockSaver{}, time.UTC)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		g.Run(ctx)
+		close(done)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+}
+
+func TestPreviousDay(t *testing.T) {
+	tokyo := loadLocation(t, "Asia/Tokyo")
+	g := New(&mockSummarizer{}, &mockState{}, &mockSaver{}, tokyo)
+	g.now = func() time.Time { return time.Date(2026, 3, 9, 16, 0, 0, 0, time.UTC) } // 01:00 on the 10th in Tokyo
+
+	if got := g.PreviousDay(); got != "2026-03-09" {
+		t.Errorf("PreviousDay = %s, want 2026-03-09", got)
+	}
+}
+
+// --- rendering ---
+
+func TestHTML(t *testing.T) {
+	out, err := HTML(sampleReport())
+	if err != nil {
+		t.Fatalf("HTML: %v", err)
+	}
+	s := string(out)
+	for _, want := range []string{"2026-03-09", "paypal-login.com", "&lt;script&gt;", "2026-03-09 13:00 UTC", "Backlog: 12 entries"} {
+		if !strings.Contains(s, want) {
+			t.Errorf("HTML missing %q", want)
+		}
+	}
+	if strings.Contains(s, "<script>") {
+		t.Error("HTML contains an unescaped keyword")
+	}
+}
+
+func TestHTML_EmptyDay(t *testing.T) {
+	out, err := HTML(emptyReport())
+	if err != nil {
+		t.Fatalf("HTML: %v", err)
+	}
+	s := string(out)
+	if n := strings.Count(s, "<p>None.</p>"); n != 3 {
+		t.Errorf("None. sections = %d, want 3", n)
+	}
+	for _, want := range []string{"0 new matches", "Last cycle: never"} {
+		if !strings.Contains(s, want) {
+			t.Errorf("HTML missing %q", want)
+		}
+	}
+}
+
+func TestHTML_TimesInReportTimezone(t *testing.T) {
+	r := sampleReport()
+	r.Timezone = "America/New_York"
+	out, err := HTML(r)
+	if err != nil {
+		t.Fatalf("HTML: %v", err)
+	}
+	// 13:00 UTC on March 9 is 09:00 EDT.
+	if !strings.Contains(string(out), "2026-03-09 09:00 EDT") {
+		t.Errorf("HTML does not show times in the report timezone:\n%s", out)
+	}
+}
+
+func TestText(t *testing.T) {
+	s := Text(sampleReport())
+	for _, want := range []string{
+		"CT monitor daily report 2026-03-09 (UTC): 3 new matches",
+		"  paypal: 2\n",
+		"  90 paypal-login.com (paypal)\n",
+		"Monitor: running, last cycle 2026-03-10 07:55 UTC, backlog 12",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("Text missing %q:\n%s", want, s)
+		}
+	}
+}
+
+func TestText_EmptyDay(t *testing.T) {
+	s := Text(emptyReport())
+	if n := strings.Count(s, "  none\n"); n != 3 {
+		t.Errorf("none sections = %d, want 3:\n%s", n, s)
+	}
+	if !strings.Contains(s, "Monitor: stopped, last cycle never, backlog 0") {
+		t.Errorf("Text health line wrong:\n%s", s)
+	}
+}
+
+func TestSlack(t *testing.T) {
+	r := sampleReport()
+	for i := range 15 {
+		r.TopDomains = append(r.TopDomains, model.DomainCount{Domain: "d" + string(rune('a'+i)) + ".com", Count: 1})
+	}
+	out, err := Slack(r)
+	if err != nil {
+		t.Fatalf("Slack: %v", err)
+	}
+	var msg slackMessage
+	if err := json.Unmarshal(out, &msg); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if msg.Text == "" || len(msg.Blocks) != 4 || msg.Blocks[0].Type != "header" {
+		t.Fatalf("unexpected message: %s", out)
+	}
+	fields := msg.Blocks[1].Fields
+	if len(fields) != 2 {
+		t.Fatalf("fields = %d, want 2", len(fields))
+	}
+	if !strings.Contains(fields[0].Text, "&lt;script&gt;: 1") {
+		t.Errorf("keyword not escaped for Slack: %q", fields[0].Text)
+	}
+	if !strings.Contains(fields[1].Text, "_and 6 more_") {
+		t.Errorf("top domains not capped: %q", fields[1].Text)
+	}
+}
+
+func TestSlack_EmptyDay(t *testing.T) {
+	out, err := Slack(emptyReport())
+	if err != nil {
+		t.Fatalf("Slack: %v", err)
+	}
+	if n := strings.Count(string(out), "_none_"); n != 3 {
+		t.Errorf("_none_ sections = %d, want 3: %s", n, out)
+	}
+}
+
+// --- SlackSender ---
+
+func TestSlackSender_Success(t *testing.T) {
+	var got slackMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("method = %s, want POST", r.Method)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	if err := NewSlackSender(srv.URL).Send(context.Background(), sampleReport()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if !strings.Contains(got.Text, "2026-03-09") {
+		t.Errorf("Text = %q", got.Text)
+	}
+}
+
+func TestSlackSender_Non2xx(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	err := NewSlackSender(srv.URL).Send(context.Background(), sampleReport())
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("err = %v, want status 403", err)
+	}
+}
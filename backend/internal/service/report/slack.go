@@ -0,0 +1,52 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+const slackTimeout = 10 * time.Second
+
+// SlackSender posts reports to a Slack incoming webhook.
+type SlackSender struct {
+	url    string
+	client *http.Client
+}
+
+func NewSlackSender(url string) *SlackSender {
+	return &SlackSender{
+		url:    url,
+		client: &http.Client{Timeout: slackTimeout},
+	}
+}
+
+func (s *SlackSender) Name() string { return "slack" }
+
+func (s *SlackSender) Send(ctx context.Context, report *model.DailyReport) error {
+	body, err := Slack(report)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("slack webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+// Package risk scores matched certificates so the likeliest phishing
+// domains can be triaged first. A score is the sum of the weights of the
+// factors that apply to a match, capped at MaxScore; each applying factor
+// is reported with its points so the number can be explained.
+package risk
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+// MaxScore caps a match's risk score.
+const MaxScore = 100
+
+// Factor names, as used in RISK_WEIGHTS and the score breakdown.
+const (
+	DomainAgeWeek  = "domain_age_week"
+	DomainAgeMonth = "domain_age_month"
+	NoHistory      = "no_history"
+	UntrustedChain = "untrusted_chain"
+	FreeIssuer     = "free_issuer"
+	MatchedCN      = "matched_cn"
+	CNNotInSANs    = "cn_not_in_sans"
+	Wildcard       = "wildcard"
+	ShortValidity  = "short_validity"
+)
+
+const (
+	// fewCertificates is the most certificates crt.sh may know for a
+	// registrable domain for it to count as having no history.
+	fewCertificates = 2
+	// shortValidity is the longest validity period that counts as short.
+	shortValidity = 90 * 24 * time.Hour
+)
+
+// factor is one row of the scoring table. applies reports whether the
+// factor applies to a match and, if so, why.
+type factor struct {
+	name    string
+	weight  int
+	applies func(c *model.MatchedCertificate) (string, bool)
+}
+
+// factors is the scoring table, with the default weight of each factor.
+var factors = []factor{
+	{DomainAgeWeek, 35, func(c *model.MatchedCertificate) (string, bool) {
+		if c.DomainAgeDays == nil || *c.DomainAgeDays > 7 {
+			return "", false
+		}
+		return fmt.Sprintf("domain registered %d days before first seen", *c.DomainAgeDays), true
+	}},
+	{DomainAgeMonth, 20, func(c *model.MatchedCertificate) (string, bool) {
+		if c.DomainAgeDays == nil || *c.DomainAgeDays <= 7 || *c.DomainAgeDays > 30 {
+			return "", false
+		}
+		return fmt.Sprintf("domain registered %d days before first seen", *c.DomainAgeDays), true
+	}},
+	{NoHistory, 15, func(c *model.MatchedCertificate) (string, bool) {
+		if c.HistoricalCertCount == nil || *c.HistoricalCertCount > fewCertificates {
+			return "", false
+		}
+		return fmt.Sprintf("%d certificates ever logged for the domain", *c.HistoricalCertCount), true
+	}},
+	{UntrustedChain, 15, func(c *model.MatchedCertificate) (string, bool) {
+		if c.ChainStatus != model.ChainUnknownIssuer && c.ChainStatus != model.ChainExpired {
+			return "", false
+		}
+		return "chain status " + c.ChainStatus, true
+	}},
+	{FreeIssuer, 10, func(c *model.MatchedCertificate) (string, bool) {
+		return "issued by a free automated CA", c.IssuerClass == model.IssuerFreeAutomated
+	}},
+	{MatchedCN, 10, func(c *model.MatchedCertificate) (string, bool) {
+		return "keyword matched the common name", c.MatchedField == model.MatchFieldCN
+	}},
+	{CNNotInSANs, 10, func(c *model.MatchedCertificate) (string, bool) {
+		if c.CommonName == "" || slices.ContainsFunc(c.SANs, func(s string) bool {
+			return strings.EqualFold(s, c.CommonName)
+		}) {
+			return "", false
+		}
+		return "common name missing from the SANs", true
+	}},
+	{Wildcard, 10, func(c *model.MatchedCertificate) (string, bool) {
+		for _, name := range append([]string{c.CommonName}, c.SANs...) {
+			if strings.HasPrefix(name, "*.") {
+				return "wildcard name " + name, true
+			}
+		}
+		return "", false
+	}},
+	{ShortValidity, 5, func(c *model.MatchedCertificate) (string, bool) {
+		validity := c.NotAfter.Sub(c.NotBefore)
+		if validity <= 0 || validity > shortValidity {
+			return "", false
+		}
+		return fmt.Sprintf("valid for %d days", int(validity.Hours()/24)), true
+	}},
+}
+
+// DefaultWeights returns the weight of every factor when RISK_WEIGHTS does
+// not override it.
+func DefaultWeights() map[string]int {
+	weights := make(map[string]int, len(factors))
+	for _, f := range factors {
+		weights[f.name] = f.weight
+	}
+	return weights
+}
+
+// Scorer computes risk scores with a fixed set of weights.
+type Scorer struct {
+	weights map[string]int
+}
+
+// New returns a Scorer using the default weights with overrides applied.
+// A weight of zero turns a factor off; unknown factor names are an error.
+func New(overrides map[string]int) (*Scorer, error) {
+	weights := DefaultWeights()
+	for name, w := range overrides {
+		if _, ok := weights[name]; !ok {
+			return nil, fmt.Errorf("unknown risk factor %q", name)
+		}
+		if w < 0 || w > MaxScore {
+			return nil, fmt.Errorf("risk factor %s: weight %d is not between 0 and %d", name, w, MaxScore)
+		}
+		weights[name] = w
+	}
+	return &Scorer{weights: weights}, nil
+}
+
+// Score returns c's risk score and the factors that make it up, in table
+// order. Factors whose weight is zero are left out of the breakdown.
+func (s *Scorer) Score(c model.MatchedCertificate) (int, []model.RiskFactor) {
+	score := 0
+	breakdown := []model.RiskFactor{}
+	for _, f := range factors {
+		points := s.weights[f.name]
+		if points == 0 {
+			continue
+		}
+		detail, ok := f.applies(&c)
+		if !ok {
+			continue
+		}
+		score += points
+		breakdown = append(breakdown, model.RiskFactor{Factor: f.name, Points: points, Detail: detail})
+	}
+	return min(score, MaxScore), breakdown
+}
@@ -0,0 +1,139 @@
+package risk
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+func intPtr(n int) *int { return &n }
+
+// baseCert applies no factor: SAN match, CN among the SANs, trusted chain,
+// a year of validity and no enrichment yet.
+func baseCert() model.MatchedCertificate {
+	notBefore := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	return model.MatchedCertificate{
+		CommonName:   "login.example.com",
+		SANs:         []string{"login.example.com", "www.example.com"},
+		MatchedField: model.MatchFieldSAN,
+		ChainStatus:  model.ChainValid,
+		NotBefore:    notBefore,
+		NotAfter:     notBefore.AddDate(1, 0, 0),
+	}
+}
+
+func factorNames(breakdown []model.RiskFactor) []string {
+	var names []string
+	for _, f := range breakdown {
+		names = append(names, f.Factor)
+	}
+	return names
+}
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(c *model.MatchedCertificate)
+		want   []string
+		score  int
+	}{
+		{"none", func(c *model.MatchedCertificate) {}, nil, 0},
+		{"week old domain", func(c *model.MatchedCertificate) { c.DomainAgeDays = intPtr(3) }, []string{DomainAgeWeek}, 35},
+		{"month old domain", func(c *model.MatchedCertificate) { c.DomainAgeDays = intPtr(20) }, []string{DomainAgeMonth}, 20},
+		{"old domain", func(c *model.MatchedCertificate) { c.DomainAgeDays = intPtr(400) }, nil, 0},
+		{"no history", func(c *model.MatchedCertificate) { c.HistoricalCertCount = intPtr(1) }, []string{NoHistory}, 15},
+		{"long history", func(c *model.MatchedCertificate) { c.HistoricalCertCount = intPtr(300) }, nil, 0},
+		{"unknown issuer", func(c *model.MatchedCertificate) { c.ChainStatus = model.ChainUnknownIssuer }, []string{UntrustedChain}, 15},
+		{"unchecked chain", func(c *model.MatchedCertificate) { c.ChainStatus = model.ChainNotChecked }, nil, 0},
+		{"free issuer", func(c *model.MatchedCertificate) { c.IssuerClass = model.IssuerFreeAutomated }, []string{FreeIssuer}, 10},
+		{"paid issuer", func(c *model.MatchedCertificate) { c.IssuerClass = model.IssuerPaid }, nil, 0},
+		{"cn match", func(c *model.MatchedCertificate) { c.MatchedField = model.MatchFieldCN }, []string{MatchedCN}, 10},
+		{"cn not in sans", func(c *model.MatchedCertificate) { c.CommonName = "other.example.net" }, []string{CNNotInSANs}, 10},
+		{"cn differs only in case", func(c *model.MatchedCertificate) { c.CommonName = "LOGIN.example.com" }, nil, 0},
+		{"wildcard", func(c *model.MatchedCertificate) { c.SANs = append(c.SANs, "*.example.com") }, []string{Wildcard}, 10},
+		{"short validity", func(c *model.MatchedCertificate) { c.NotAfter = c.NotBefore.AddDate(0, 0, 90) }, []string{ShortValidity}, 5},
+		{"capped", func(c *model.MatchedCertificate) {
+			c.DomainAgeDays = intPtr(0)
+			c.HistoricalCertCount = intPtr(0)
+			c.ChainStatus = model.ChainExpired
+			c.IssuerClass = model.IssuerFreeAutomated
+			c.MatchedField = model.MatchFieldCN
+			c.CommonName = "*.example-login.com"
+			c.NotAfter = c.NotBefore.AddDate(0, 0, 7)
+		}, []string{DomainAgeWeek, NoHistory, UntrustedChain, FreeIssuer, MatchedCN, CNNotInSANs, Wildcard, ShortValidity}, MaxScore},
+	}
+	s, err := New(nil)
+	if err != nil {
+		t.Fatalf("New(nil) error = %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := baseCert()
+			tt.mutate(&c)
+			score, breakdown := s.Score(c)
+			if score != tt.score {
+				t.Errorf("score = %d, want %d (breakdown %+v)", score, tt.score, breakdown)
+			}
+			if got := factorNames(breakdown); !slices.Equal(got, tt.want) {
+				t.Errorf("factors = %v, want %v", got, tt.want)
+			}
+			if breakdown == nil {
+				t.Error("breakdown is nil, want an empty slice")
+			}
+		})
+	}
+}
+
+func TestScore_BreakdownExplainsPoints(t *testing.T) {
+	s, _ := New(nil)
+	c := baseCert()
+	c.DomainAgeDays = intPtr(2)
+	c.MatchedField = model.MatchFieldCN
+
+	score, breakdown := s.Score(c)
+	sum := 0
+	for _, f := range breakdown {
+		sum += f.Points
+		if f.Detail == "" {
+			t.Errorf("factor %s has no detail", f.Factor)
+		}
+	}
+	if sum != score {
+		t.Errorf("breakdown sums to %d, score is %d", sum, score)
+	}
+	if breakdown[0].Detail != "domain registered 2 days before first seen" {
+		t.Errorf("detail = %q", breakdown[0].Detail)
+	}
+}
+
+func TestNew_Overrides(t *testing.T) {
+	s, err := New(map[string]int{MatchedCN: 0, DomainAgeWeek: 60})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	c := baseCert()
+	c.DomainAgeDays = intPtr(1)
+	c.MatchedField = model.MatchFieldCN
+
+	score, breakdown := s.Score(c)
+	if score != 60 || !slices.Equal(factorNames(breakdown), []string{DomainAgeWeek}) {
+		t.Errorf("score = %d %v, want 60 from domain_age_week only", score, breakdown)
+	}
+	if DefaultWeights()[DomainAgeWeek] != 35 {
+		t.Error("overrides changed the default weights")
+	}
+}
+
+func TestNew_RejectsBadWeights(t *testing.T) {
+	for _, overrides := range []map[string]int{
+		{"severity": 10},
+		{Wildcard: -1},
+		{Wildcard: MaxScore + 1},
+	} {
+		if _, err := New(overrides); err == nil {
+			t.Errorf("New(%v) = nil error, want one", overrides)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Package seed inserts a configured list of keywords at startup.
+package seed
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+// minKeywordLength mirrors the validation in the keyword handler.
+const minKeywordLength = 3
+
+// Parse splits a seed list on commas and newlines. Blank entries and lines
+// starting with # are ignored, and duplicates (case-insensitive) are dropped
+// keeping the first spelling.
+func Parse(s string) []string {
+	var values []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(s, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		for _, v := range strings.Split(line, ",") {
+			v = strings.TrimSpace(v)
+			key := strings.ToLower(v)
+			if v == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+type keywordStore interface {
+	List(ctx context.Context) ([]model.Keyword, error)
+	CreateMany(ctx context.Context, values []string) (int, error)
+}
+
+// Keywords inserts the values that are not stored yet and returns how many
+// were added. Matching is case-insensitive, so a value that differs from an
+// existing keyword only by case counts as existing. Values shorter than the
+// API minimum are skipped with a warning.
+func Keywords(ctx context.Context, store keywordStore, values []string) (int, error) {
+	if len(values) == 0 {
+		return 0, nil
+	}
+
+	existing, err := store.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	have := make(map[string]bool, len(existing))
+	for _, kw := range existing {
+		have[strings.ToLower(kw.Value)] = true
+	}
+
+	var missing []string
+	for _, v := range values {
+		if len(v) < minKeywordLength {
+			slog.Warn("skipping seed keyword shorter than minimum", "value", v, "min", minKeywordLength)
+			continue
+		}
+		if !have[strings.ToLower(v)] {
+			missing = append(missing, v)
+		}
+	}
+	if len(missing) == 0 {
+		return 0, nil
+	}
+	return store.CreateMany(ctx, missing)
+}
+
+// ParseCSV reads the "value" column of a CSV with a header row, the format
+// of the keyword export and import endpoints.
+func ParseCSV(r io.Reader) ([]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	col := -1
+	for i, name := range header {
+		if strings.EqualFold(strings.TrimSpace(name), "value") {
+			col = i
+		}
+	}
+	if col < 0 {
+		return nil, errors.New("csv has no value column")
+	}
+
+	var values []string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return values, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if col < len(record) {
+			values = append(values, record[col])
+		}
+	}
+}
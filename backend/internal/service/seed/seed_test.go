@@ -0,0 +1,96 @@
+package seed
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{"empty", "", nil},
+		{"comma separated", "apple, amazon ,paypal", []string{"apple", "amazon", "paypal"}},
+		{"file lines", "# brands\napple\n\namazon,paypal\n", []string{"apple", "amazon", "paypal"}},
+		{"duplicates keep first spelling", "Apple,apple,APPLE,bank", []string{"Apple", "bank"}},
+		{"blank entries", " , ,apple,, ", []string{"apple"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Parse(tt.in); !slices.Equal(got, tt.want) {
+				t.Errorf("Parse(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+type mockKeywordStore struct {
+	existing []model.Keyword
+	listErr  error
+	created  []string
+}
+
+func (m *mockKeywordStore) List(ctx context.Context) ([]model.Keyword, error) {
+	return m.existing, m.listErr
+}
+
+func (m *mockKeywordStore) CreateMany(ctx context.Context, values []string) (int, error) {
+	m.created = append(m.created, values...)
+	return len(values), nil
+}
+
+func TestKeywords_SkipsExisting(t *testing.T) {
+	store := &mockKeywordStore{existing: []model.Keyword{{ID: 1, Value: "apple"}, {ID: 2, Value: "PayPal"}}}
+
+	n, err := Keywords(context.Background(), store, []string{"Apple", "amazon", "paypal", "ab", "bank"})
+	if err != nil {
+		t.Fatalf("Keywords: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("seeded = %d, want 2", n)
+	}
+	if want := []string{"amazon", "bank"}; !slices.Equal(store.created, want) {
+		t.Errorf("created = %q, want %q", store.created, want)
+	}
+}
+
+func TestKeywords_AllExisting(t *testing.T) {
+	store := &mockKeywordStore{existing: []model.Keyword{{ID: 1, Value: "apple"}}}
+
+	n, err := Keywords(context.Background(), store, []string{"apple"})
+	if err != nil || n != 0 {
+		t.Errorf("Keywords = (%d, %v), want (0, nil)", n, err)
+	}
+	if store.created != nil {
+		t.Errorf("CreateMany called with %q, want no call", store.created)
+	}
+}
+
+func TestKeywords_ListError(t *testing.T) {
+	store := &mockKeywordStore{listErr: errors.New("db down")}
+
+	if _, err := Keywords(context.Background(), store, []string{"apple"}); err == nil {
+		t.Error("expected error when List fails")
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	values, err := ParseCSV(strings.NewReader("created_at,Value\n2025-01-01T00:00:00Z,paypal\n2025-01-02T00:00:00Z,\"amazon, inc\"\n"))
+	if err != nil {
+		t.Fatalf("ParseCSV() error = %v", err)
+	}
+	if !slices.Equal(values, []string{"paypal", "amazon, inc"}) {
+		t.Errorf("ParseCSV() = %q, want [paypal \"amazon, inc\"]", values)
+	}
+
+	if _, err := ParseCSV(strings.NewReader("keyword\npaypal\n")); err == nil {
+		t.Error("ParseCSV() without a value column succeeded, want an error")
+	}
+}
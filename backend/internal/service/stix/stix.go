@@ -0,0 +1,217 @@
+// Package stix renders matched certificates as a STIX 2.1 bundle for
+// threat-intel platforms. Each match becomes an indicator for the matched
+// domain, an x509-certificate observable and a relationship between them.
+package stix
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+// MediaType is the Content-Type of a STIX 2.1 bundle.
+const MediaType = "application/stix+json;version=2.1"
+
+const specVersion = "2.1"
+
+// namespace is the STIX 2.1 namespace for deterministic (UUIDv5)
+// identifiers.
+var namespace = uuid.MustParse("00abedb4-aa42-466c-9c01-fed23315a9b7")
+
+// timestampLayout is the STIX timestamp format: UTC with millisecond
+// precision.
+const timestampLayout = "2006-01-02T15:04:05.000Z"
+
+// Certificate is a STIX x509-certificate observable.
+type Certificate struct {
+	Type              string `json:"type"`
+	SpecVersion       string `json:"spec_version"`
+	ID                string `json:"id"`
+	SerialNumber      string `json:"serial_number,omitempty"`
+	Issuer            string `json:"issuer,omitempty"`
+	Subject           string `json:"subject,omitempty"`
+	ValidityNotBefore string `json:"validity_not_before,omitempty"`
+	ValidityNotAfter  string `json:"validity_not_after,omitempty"`
+}
+
+// Indicator is a STIX indicator matching the domain a keyword hit.
+type Indicator struct {
+	Type           string   `json:"type"`
+	SpecVersion    string   `json:"spec_version"`
+	ID             string   `json:"id"`
+	Created        string   `json:"created"`
+	Modified       string   `json:"modified"`
+	Name           string   `json:"name"`
+	Description    string   `json:"description,omitempty"`
+	IndicatorTypes []string `json:"indicator_types"`
+	Pattern        string   `json:"pattern"`
+	PatternType    string   `json:"pattern_type"`
+	ValidFrom      string   `json:"valid_from"`
+	ValidUntil     string   `json:"valid_until,omitempty"`
+	// XRiskScore is the match's risk score (0-100), a custom property.
+	XRiskScore int `json:"x_risk_score"`
+}
+
+// Relationship links an indicator to its certificate.
+type Relationship struct {
+	Type             string `json:"type"`
+	SpecVersion      string `json:"spec_version"`
+	ID               string `json:"id"`
+	Created          string `json:"created"`
+	Modified         string `json:"modified"`
+	RelationshipType string `json:"relationship_type"`
+	SourceRef        string `json:"source_ref"`
+	TargetRef        string `json:"target_ref"`
+}
+
+// object is a STIX object the Writer can emit.
+type object interface {
+	objectID() string
+}
+
+func (c *Certificate) objectID() string  { return c.ID }
+func (i *Indicator) objectID() string    { return i.ID }
+func (r *Relationship) objectID() string { return r.ID }
+
+// Fingerprint identifies the certificate behind a match
+// (model.MatchedCertificate.Fingerprint).
+func Fingerprint(c model.MatchedCertificate) string {
+	return c.Fingerprint()
+}
+
+// id derives a stable identifier from parts so re-exporting the same match
+// yields the same object and platforms update it instead of duplicating it.
+func id(objectType string, parts ...string) string {
+	name := objectType + ":" + strings.Join(parts, ":")
+	return objectType + "--" + uuid.NewSHA1(namespace, []byte(name)).String()
+}
+
+// Objects returns the certificate, indicator and relationship for c, in
+// that order.
+func Objects(c model.MatchedCertificate) (*Certificate, *Indicator, *Relationship) {
+	fp := Fingerprint(c)
+	created := timestamp(c.DiscoveredAt)
+
+	cert := &Certificate{
+		Type:              "x509-certificate",
+		SpecVersion:       specVersion,
+		ID:                id("x509-certificate", fp),
+		SerialNumber:      c.SerialNumber,
+		Issuer:            c.Issuer,
+		ValidityNotBefore: timestamp(c.NotBefore),
+		ValidityNotAfter:  timestamp(c.NotAfter),
+	}
+	if c.CommonName != "" {
+		cert.Subject = "CN=" + c.CommonName
+	}
+
+	ind := &Indicator{
+		Type:           "indicator",
+		SpecVersion:    specVersion,
+		ID:             id("indicator", fp, c.MatchedDomain),
+		Created:        created,
+		Modified:       created,
+		Name:           "Certificate issued for " + c.MatchedDomain,
+		IndicatorTypes: []string{"anomalous-activity"},
+		Pattern:        fmt.Sprintf("[domain-name:value = '%s']", escape(c.MatchedDomain)),
+		PatternType:    "stix",
+		ValidFrom:      timestamp(c.NotBefore),
+		XRiskScore:     c.RiskScore,
+	}
+	if c.KeywordValue != "" {
+		ind.Description = fmt.Sprintf("Matched keyword %q in CT log entry %d.", c.KeywordValue, c.CTLogIndex)
+	}
+	// valid_until must be later than valid_from.
+	if c.NotAfter.After(c.NotBefore) {
+		ind.ValidUntil = timestamp(c.NotAfter)
+	}
+
+	rel := &Relationship{
+		Type:             "relationship",
+		SpecVersion:      specVersion,
+		ID:               id("relationship", ind.ID, cert.ID),
+		Created:          created,
+		Modified:         created,
+		RelationshipType: "related-to",
+		SourceRef:        ind.ID,
+		TargetRef:        cert.ID,
+	}
+	return cert, ind, rel
+}
+
+func timestamp(t time.Time) string {
+	return t.UTC().Format(timestampLayout)
+}
+
+// escape quotes s for a STIX pattern string literal.
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s)
+}
+
+// Writer streams a bundle to w. Objects shared by several matches (a
+// certificate matching two keywords, say) are written once.
+type Writer struct {
+	w       io.Writer
+	enc     *json.Encoder
+	seen    map[string]bool
+	started bool
+}
+
+// NewWriter returns a Writer for one bundle. Nothing is written until the
+// first Add or Close.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w, enc: json.NewEncoder(w), seen: make(map[string]bool)}
+}
+
+// Add writes the objects for c.
+func (b *Writer) Add(c model.MatchedCertificate) error {
+	cert, ind, rel := Objects(c)
+	for _, obj := range []object{cert, ind, rel} {
+		if b.seen[obj.objectID()] {
+			continue
+		}
+		b.seen[obj.objectID()] = true
+		if err := b.object(obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close ends the bundle.
+func (b *Writer) Close() error {
+	if err := b.open(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(b.w, "]}\n")
+	return err
+}
+
+func (b *Writer) open() error {
+	if b.started {
+		return nil
+	}
+	b.started = true
+	_, err := fmt.Fprintf(b.w, `{"type":"bundle","id":"bundle--%s","objects":[`, uuid.New())
+	return err
+}
+
+func (b *Writer) object(obj object) error {
+	sep := ","
+	if !b.started {
+		if err := b.open(); err != nil {
+			return err
+		}
+		sep = ""
+	}
+	if _, err := io.WriteString(b.w, sep); err != nil {
+		return err
+	}
+	return b.enc.Encode(obj)
+}
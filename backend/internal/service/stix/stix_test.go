@@ -0,0 +1,200 @@
+package stix
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+func match(serial, domain, keyword string) model.MatchedCertificate {
+	return model.MatchedCertificate{
+		SerialNumber:  serial,
+		CommonName:    domain,
+		Issuer:        "CN=R11, O=Let's Encrypt, C=US",
+		NotBefore:     time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:      time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+		KeywordValue:  keyword,
+		MatchedDomain: domain,
+		CTLogIndex:    42,
+		DiscoveredAt:  time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+}
+
+func export(t *testing.T, certs ...model.MatchedCertificate) map[string]any {
+	t.Helper()
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, c := range certs {
+		if err := w.Add(c); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	var bundle map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &bundle); err != nil {
+		t.Fatalf("bundle is not JSON: %v\n%s", err, buf.String())
+	}
+	return bundle
+}
+
+// Constraints from the STIX 2.1 JSON schemas (common/identifier.json,
+// common/timestamp.json, common/core.json and the bundle, indicator,
+// relationship and x509-certificate object schemas).
+var (
+	identifierRE = regexp.MustCompile(`^[a-z][a-z0-9-]+[a-z0-9]--[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[1-5][0-9a-fA-F]{3}-[89abAB][0-9a-fA-F]{3}-[0-9a-fA-F]{12}$`)
+	timestampRE  = regexp.MustCompile(`^[0-9]{4}-(0[1-9]|1[012])-(0[1-9]|[12][0-9]|3[01])T([01][0-9]|2[0-3]):([0-5][0-9]):([0-5][0-9]|60)(\.[0-9]+)?Z$`)
+
+	required = map[string][]string{
+		"indicator":        {"type", "spec_version", "id", "created", "modified", "pattern", "pattern_type", "valid_from"},
+		"relationship":     {"type", "spec_version", "id", "created", "modified", "relationship_type", "source_ref", "target_ref"},
+		"x509-certificate": {"type", "id"},
+	}
+	timestamps = []string{"created", "modified", "valid_from", "valid_until", "validity_not_before", "validity_not_after"}
+)
+
+// validate checks bundle against the schema constraints above and returns
+// its objects keyed by ID.
+func validate(t *testing.T, bundle map[string]any) map[string]map[string]any {
+	t.Helper()
+	if bundle["type"] != "bundle" {
+		t.Errorf("bundle type = %v", bundle["type"])
+	}
+	if id, _ := bundle["id"].(string); !identifierRE.MatchString(id) || id[:8] != "bundle--" {
+		t.Errorf("bundle id = %q", id)
+	}
+	objects, ok := bundle["objects"].([]any)
+	if !ok {
+		t.Fatalf("objects = %T, want array", bundle["objects"])
+	}
+
+	byID := make(map[string]map[string]any, len(objects))
+	for _, o := range objects {
+		obj := o.(map[string]any)
+		typ, _ := obj["type"].(string)
+		props, known := required[typ]
+		if !known {
+			t.Errorf("unexpected object type %q", typ)
+			continue
+		}
+		for _, p := range props {
+			if _, ok := obj[p]; !ok {
+				t.Errorf("%s is missing required property %q", typ, p)
+			}
+		}
+		id, _ := obj["id"].(string)
+		if !identifierRE.MatchString(id) || id[:len(typ)+2] != typ+"--" {
+			t.Errorf("%s id = %q", typ, id)
+		}
+		if v, ok := obj["spec_version"]; ok && v != "2.1" {
+			t.Errorf("%s spec_version = %v", typ, v)
+		}
+		for _, p := range timestamps {
+			if v, ok := obj[p]; ok && !timestampRE.MatchString(v.(string)) {
+				t.Errorf("%s %s = %q is not a STIX timestamp", typ, p, v)
+			}
+		}
+		if _, dup := byID[id]; dup {
+			t.Errorf("duplicate object %s", id)
+		}
+		byID[id] = obj
+	}
+
+	for id, obj := range byID {
+		switch obj["type"] {
+		case "indicator":
+			if obj["pattern_type"] != "stix" {
+				t.Errorf("%s pattern_type = %v", id, obj["pattern_type"])
+			}
+			if types, _ := obj["indicator_types"].([]any); len(types) == 0 {
+				t.Errorf("%s indicator_types must be a non-empty list", id)
+			}
+		case "relationship":
+			for _, ref := range []string{"source_ref", "target_ref"} {
+				if _, ok := byID[obj[ref].(string)]; !ok {
+					t.Errorf("%s %s %v is not in the bundle", id, ref, obj[ref])
+				}
+			}
+		}
+	}
+	return byID
+}
+
+func TestWriter_ValidBundle(t *testing.T) {
+	objects := validate(t, export(t,
+		match("01", "login.example.com", "example"),
+		match("02", "shop.example.com", "shop"),
+	))
+
+	if len(objects) != 6 {
+		t.Fatalf("got %d objects, want an indicator, certificate and relationship per match", len(objects))
+	}
+	c := match("01", "login.example.com", "example")
+	cert, ind, rel := Objects(c)
+	got := objects[ind.ID]
+	if got["pattern"] != "[domain-name:value = 'login.example.com']" {
+		t.Errorf("pattern = %v", got["pattern"])
+	}
+	if got["valid_from"] != "2025-01-01T00:00:00.000Z" || got["valid_until"] != "2025-04-01T00:00:00.000Z" {
+		t.Errorf("validity = %v..%v, want the certificate's", got["valid_from"], got["valid_until"])
+	}
+	x509 := objects[cert.ID]
+	if x509["serial_number"] != "01" || x509["issuer"] != c.Issuer || x509["validity_not_after"] != "2025-04-01T00:00:00.000Z" {
+		t.Errorf("certificate = %v", x509)
+	}
+	if r := objects[rel.ID]; r["source_ref"] != ind.ID || r["target_ref"] != cert.ID {
+		t.Errorf("relationship = %v, want indicator -> certificate", r)
+	}
+}
+
+func TestWriter_EmptyBundle(t *testing.T) {
+	if objects := validate(t, export(t)); len(objects) != 0 {
+		t.Errorf("got %d objects, want none", len(objects))
+	}
+}
+
+func TestObjects_DeterministicIDs(t *testing.T) {
+	c := match("01", "login.example.com", "example")
+	cert1, ind1, rel1 := Objects(c)
+
+	// A later re-observation keeps the same identity.
+	c.CTLogIndex, c.ID = 99, 7
+	cert2, ind2, rel2 := Objects(c)
+	if cert1.ID != cert2.ID || ind1.ID != ind2.ID || rel1.ID != rel2.ID {
+		t.Error("IDs changed between exports of the same match")
+	}
+
+	other := match("01", "login.example.com", "example")
+	other.Issuer = "CN=Other CA"
+	if cert3, _, _ := Objects(other); cert3.ID == cert1.ID {
+		t.Error("same serial from another issuer shares the certificate ID")
+	}
+}
+
+func TestWriter_SharedCertificateWrittenOnce(t *testing.T) {
+	// One certificate matched by two keywords on two of its names.
+	a := match("01", "login.example.com", "example")
+	b := match("01", "login.example.com", "login")
+	c := match("01", "mail.example.com", "mail")
+
+	objects := validate(t, export(t, a, b, c))
+	counts := map[any]int{}
+	for _, o := range objects {
+		counts[o["type"]]++
+	}
+	if counts["x509-certificate"] != 1 || counts["indicator"] != 2 || counts["relationship"] != 2 {
+		t.Errorf("object counts = %v, want 1 certificate and 2 indicators/relationships", counts)
+	}
+}
+
+func TestObjects_EscapesPattern(t *testing.T) {
+	_, ind, _ := Objects(match("01", `o'brien\.example.com`, "brien"))
+	if want := `[domain-name:value = 'o\'brien\\.example.com']`; ind.Pattern != want {
+		t.Errorf("pattern = %s, want %s", ind.Pattern, want)
+	}
+}
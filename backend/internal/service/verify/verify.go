@@ -0,0 +1,219 @@
+// Package verify compares the matches a rescan of a CT log range produces
+// with the matches stored for it, to find detections a matcher bug missed.
+package verify
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+// Collector stands in for the certificate repository during a rescan: it
+// records every match instead of storing it. Create leaves the ID zero, so
+// the monitor treats each match as already stored and publishes nothing.
+type Collector struct {
+	mu    sync.Mutex
+	certs []model.MatchedCertificate
+}
+
+func (c *Collector) Create(ctx context.Context, cert *model.MatchedCertificate) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.certs = append(c.certs, *cert)
+	return nil
+}
+
+// Matches returns the recorded matches in the order they were made.
+func (c *Collector) Matches() []model.MatchedCertificate {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.certs)
+}
+
+// Report is the outcome of comparing a rescan of entries Start..End of the
+// log at CTLogURL with the stored matches.
+type Report struct {
+	CTLogURL string `json:"ct_log_url"`
+	Start    int64  `json:"start"`
+	End      int64  `json:"end"`
+	// Expected counts the distinct matches the rescan produced; Stored
+	// counts the stored rows it was compared with.
+	Expected int `json:"expected"`
+	Stored   int `json:"stored"`
+	// Missing are rescan matches with no stored row; Extra are stored rows
+	// in the range the rescan did not produce.
+	Missing    []model.MatchedCertificate `json:"missing"`
+	Extra      []model.MatchedCertificate `json:"extra"`
+	Mismatched []Mismatch                 `json:"mismatched"`
+	// Fixed counts the missing matches inserted by verify -fix.
+	Fixed int `json:"fixed"`
+}
+
+// Clean reports whether the stored matches agree with the rescan.
+func (r *Report) Clean() bool {
+	return len(r.Missing) == 0 && len(r.Extra) == 0 && len(r.Mismatched) == 0
+}
+
+// Mismatch is a match both stored and produced by the rescan whose fields
+// differ.
+type Mismatch struct {
+	ID           int         `json:"id"`
+	SerialNumber string      `json:"serial_number"`
+	KeywordID    int         `json:"keyword_id"`
+	KeywordValue string      `json:"keyword_value"`
+	Fields       []FieldDiff `json:"fields"`
+}
+
+// FieldDiff is one differing field, formatted for display.
+type FieldDiff struct {
+	Field    string `json:"field"`
+	Stored   string `json:"stored"`
+	Expected string `json:"expected"`
+}
+
+// key identifies a match as the (serial_number, keyword_id) unique
+// constraint does.
+type key struct {
+	serial    string
+	keywordID int
+}
+
+func keyOf(c model.MatchedCertificate) key {
+	return key{c.SerialNumber, c.KeywordID}
+}
+
+// Compare diffs the rescan matches of entries start..end of the log at
+// logURL against stored, which should hold the rows stored in that range
+// plus any row sharing a serial number with a rescan match. A precertificate and its final
+// certificate share a serial, so a match may repeat in expected (only the
+// first is kept, as the conflict strategy does on insert) or be stored from
+// an entry before start; ct_log_index and is_precert are only compared when
+// the stored row lies in the range.
+func Compare(logURL string, start, end int64, expected, stored []model.MatchedCertificate) *Report {
+	r := &Report{
+		CTLogURL:   logURL,
+		Start:      start,
+		End:        end,
+		Stored:     len(stored),
+		Missing:    []model.MatchedCertificate{},
+		Extra:      []model.MatchedCertificate{},
+		Mismatched: []Mismatch{},
+	}
+
+	want := make(map[key]model.MatchedCertificate, len(expected))
+	var order []key
+	for _, c := range expected {
+		k := keyOf(c)
+		if _, ok := want[k]; ok {
+			continue
+		}
+		want[k] = c
+		order = append(order, k)
+	}
+	r.Expected = len(order)
+
+	have := make(map[key]model.MatchedCertificate, len(stored))
+	for _, c := range stored {
+		have[keyOf(c)] = c
+		// Rows imported from crt.sh have no log index, so they are never
+		// known to sit in the range.
+		if _, ok := want[keyOf(c)]; !ok && inRange(c, logURL, start, end) {
+			r.Extra = append(r.Extra, c)
+		}
+	}
+
+	for _, k := range order {
+		exp := want[k]
+		got, ok := have[k]
+		if !ok {
+			r.Missing = append(r.Missing, exp)
+			continue
+		}
+		sameEntry := inRange(got, logURL, start, end)
+		if diffs := diff(got, exp, sameEntry); len(diffs) > 0 {
+			r.Mismatched = append(r.Mismatched, Mismatch{
+				ID:           got.ID,
+				SerialNumber: got.SerialNumber,
+				KeywordID:    got.KeywordID,
+				KeywordValue: exp.KeywordValue,
+				Fields:       diffs,
+			})
+		}
+	}
+
+	slices.SortFunc(r.Extra, func(a, b model.MatchedCertificate) int {
+		return cmp.Or(cmp.Compare(a.CTLogIndex, b.CTLogIndex), cmp.Compare(a.KeywordID, b.KeywordID))
+	})
+	return r
+}
+
+// inRange reports whether c was stored from an entry start..end of the log
+// at logURL. Rows whose log was not recorded may come from any log.
+func inRange(c model.MatchedCertificate, logURL string, start, end int64) bool {
+	return c.Source != model.SourceCrtSh && (c.CTLogURL == "" || c.CTLogURL == logURL) &&
+		c.CTLogIndex >= start && c.CTLogIndex <= end
+}
+
+// diff lists the fields the matcher and parser derive that differ between
+// a stored row and its rescan. Fields filled in later (status, registrable
+// domain) are not compared.
+func diff(stored, expected model.MatchedCertificate, sameEntry bool) []FieldDiff {
+	var diffs []FieldDiff
+	add := func(field string, s, e any) {
+		diffs = append(diffs, FieldDiff{Field: field, Stored: fmt.Sprint(s), Expected: fmt.Sprint(e)})
+	}
+	if stored.CommonName != expected.CommonName {
+		add("common_name", stored.CommonName, expected.CommonName)
+	}
+	if !slices.Equal(stored.SANs, expected.SANs) {
+		add("sans", strings.Join(stored.SANs, ","), strings.Join(expected.SANs, ","))
+	}
+	if stored.Issuer != expected.Issuer {
+		add("issuer", stored.Issuer, expected.Issuer)
+	}
+	if !stored.NotBefore.Equal(expected.NotBefore) {
+		add("not_before", stored.NotBefore.UTC().Format(time.RFC3339), expected.NotBefore.UTC().Format(time.RFC3339))
+	}
+	if !stored.NotAfter.Equal(expected.NotAfter) {
+		add("not_after", stored.NotAfter.UTC().Format(time.RFC3339), expected.NotAfter.UTC().Format(time.RFC3339))
+	}
+	if stored.MatchedDomain != expected.MatchedDomain {
+		add("matched_domain", stored.MatchedDomain, expected.MatchedDomain)
+	}
+	if stored.MatchedField != expected.MatchedField {
+		add("matched_field", stored.MatchedField, expected.MatchedField)
+	}
+	if stored.IsServerAuth != expected.IsServerAuth {
+		add("is_server_auth", stored.IsServerAuth, expected.IsServerAuth)
+	}
+	if !sameEntry {
+		return diffs
+	}
+	if stored.IsPrecert != expected.IsPrecert {
+		add("is_precert", stored.IsPrecert, expected.IsPrecert)
+	}
+	if stored.CTLogIndex != expected.CTLogIndex {
+		add("ct_log_index", stored.CTLogIndex, expected.CTLogIndex)
+	}
+	return diffs
+}
+
+// Serials returns the distinct serial numbers of certs, for loading the
+// stored rows Compare needs.
+func Serials(certs []model.MatchedCertificate) []string {
+	seen := make(map[string]bool, len(certs))
+	serials := []string{}
+	for _, c := range certs {
+		if !seen[c.SerialNumber] {
+			seen[c.SerialNumber] = true
+			serials = append(serials, c.SerialNumber)
+		}
+	}
+	return serials
+}
@@ -0,0 +1,167 @@
+package verify
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+var notBefore = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+const logURL = "https://log.example/2026h2"
+
+func match(id int, serial string, keywordID int, index int64) model.MatchedCertificate {
+	return model.MatchedCertificate{
+		ID:            id,
+		SerialNumber:  serial,
+		CommonName:    serial + ".example.com",
+		SANs:          []string{serial + ".example.com"},
+		Issuer:        "Test CA",
+		NotBefore:     notBefore,
+		NotAfter:      notBefore.Add(90 * 24 * time.Hour),
+		KeywordID:     keywordID,
+		KeywordValue:  "example",
+		MatchedDomain: serial + ".example.com",
+		MatchedField:  model.MatchFieldCN,
+		CTLogIndex:    index,
+		CTLogURL:      logURL,
+	}
+}
+
+func TestCompare_Clean(t *testing.T) {
+	expected := []model.MatchedCertificate{match(0, "a", 1, 10), match(0, "b", 1, 11)}
+	stored := []model.MatchedCertificate{match(7, "a", 1, 10), match(8, "b", 1, 11)}
+
+	r := Compare(logURL, 10, 19, expected, stored)
+
+	if !r.Clean() {
+		t.Errorf("report = %+v, want clean", r)
+	}
+	if r.Expected != 2 || r.Stored != 2 {
+		t.Errorf("expected/stored = %d/%d, want 2/2", r.Expected, r.Stored)
+	}
+}
+
+func TestCompare_MissingAndExtra(t *testing.T) {
+	expected := []model.MatchedCertificate{match(0, "a", 1, 10), match(0, "a", 2, 10)}
+	stored := []model.MatchedCertificate{
+		match(7, "a", 1, 10),
+		match(8, "gone", 1, 15),
+		// Outside the range and not rescanned: neither extra nor missing.
+		match(9, "old", 1, 3),
+	}
+
+	r := Compare(logURL, 10, 19, expected, stored)
+
+	if len(r.Missing) != 1 || r.Missing[0].KeywordID != 2 {
+		t.Errorf("missing = %+v, want serial a for keyword 2", r.Missing)
+	}
+	if len(r.Extra) != 1 || r.Extra[0].ID != 8 {
+		t.Errorf("extra = %+v, want id 8", r.Extra)
+	}
+	if len(r.Mismatched) != 0 {
+		t.Errorf("mismatched = %+v, want none", r.Mismatched)
+	}
+}
+
+func TestCompare_Mismatched(t *testing.T) {
+	exp := match(0, "a", 1, 10)
+	exp.MatchedDomain = "login.a.example.com"
+	exp.MatchedField = model.MatchFieldSAN
+	got := match(7, "a", 1, 12)
+
+	r := Compare(logURL, 10, 19, []model.MatchedCertificate{exp}, []model.MatchedCertificate{got})
+
+	if len(r.Mismatched) != 1 {
+		t.Fatalf("mismatched = %+v, want one", r.Mismatched)
+	}
+	m := r.Mismatched[0]
+	var fields []string
+	for _, f := range m.Fields {
+		fields = append(fields, f.Field)
+	}
+	if m.ID != 7 || !slices.Equal(fields, []string{"matched_domain", "matched_field", "ct_log_index"}) {
+		t.Errorf("mismatch = %+v, want id 7 with matched_domain, matched_field, ct_log_index", m)
+	}
+	if d := m.Fields[2]; d.Stored != "12" || d.Expected != "10" {
+		t.Errorf("ct_log_index diff = %+v, want stored 12, expected 10", d)
+	}
+}
+
+func TestCompare_PrecertSharesSerial(t *testing.T) {
+	// The precertificate at 8 was stored first; its final certificate at
+	// 12 carries the same serial, so the rescan's match is not missing and
+	// the entry-specific fields are not compared.
+	precert := match(7, "a", 1, 8)
+	precert.IsPrecert = true
+	final := match(0, "a", 1, 12)
+	dup := match(0, "a", 1, 13)
+
+	r := Compare(logURL, 10, 19, []model.MatchedCertificate{final, dup}, []model.MatchedCertificate{precert})
+
+	if !r.Clean() || r.Expected != 1 {
+		t.Errorf("report = %+v, want clean with one expected match", r)
+	}
+}
+
+func TestCompare_ImportedFromCrtSh(t *testing.T) {
+	// Rows imported from crt.sh read back with log index 0 and are neither
+	// extra in a range starting at 0 nor compared on entry fields.
+	imported := match(7, "a", 1, 0)
+	imported.Source = model.SourceCrtSh
+	gone := match(8, "b", 1, 0)
+	gone.Source = model.SourceCrtSh
+
+	r := Compare(logURL, 0, 19, []model.MatchedCertificate{match(0, "a", 1, 10)}, []model.MatchedCertificate{imported, gone})
+
+	if !r.Clean() {
+		t.Errorf("report = %+v, want clean", r)
+	}
+}
+
+func TestCompare_OtherLog(t *testing.T) {
+	// Indices restart in every log: a row stored from entry 12 of the
+	// previous log is not extra in this one's 10..19, and a serial seen
+	// there is not compared on entry fields. Rows whose log was never
+	// recorded may still be in the range.
+	previous := match(7, "a", 1, 12)
+	previous.CTLogURL = "https://log.example/2026h1"
+	elsewhere := match(8, "b", 1, 15)
+	elsewhere.CTLogURL = "https://log.example/2026h1"
+	legacy := match(9, "c", 1, 16)
+	legacy.CTLogURL = ""
+
+	r := Compare(logURL, 10, 19, []model.MatchedCertificate{match(0, "a", 1, 10)},
+		[]model.MatchedCertificate{previous, elsewhere, legacy})
+
+	if len(r.Missing) != 0 || len(r.Mismatched) != 0 {
+		t.Errorf("missing %+v, mismatched %+v; want none", r.Missing, r.Mismatched)
+	}
+	if len(r.Extra) != 1 || r.Extra[0].ID != 9 {
+		t.Errorf("extra = %+v, want only the row with no recorded log", r.Extra)
+	}
+}
+
+func TestCollector(t *testing.T) {
+	var c Collector
+	cert := match(0, "a", 1, 10)
+	if err := c.Create(context.Background(), &cert); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if cert.ID != 0 {
+		t.Errorf("ID = %d, want 0 so the monitor does not publish", cert.ID)
+	}
+	if got := c.Matches(); len(got) != 1 || got[0].SerialNumber != "a" {
+		t.Errorf("Matches() = %+v, want the one match", got)
+	}
+}
+
+func TestSerials(t *testing.T) {
+	got := Serials([]model.MatchedCertificate{match(0, "a", 1, 1), match(0, "b", 1, 2), match(0, "a", 2, 1)})
+	if !slices.Equal(got, []string{"a", "b"}) {
+		t.Errorf("Serials() = %v, want [a b]", got)
+	}
+}
@@ -0,0 +1,59 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers sent with every delivery.
+const (
+	// SignatureHeader carries "sha256=" and the hex HMAC-SHA256 of the
+	// timestamp, a '.', and the body, keyed with the webhook's secret.
+	SignatureHeader = "X-SISAP-Signature"
+	// TimestampHeader carries the Unix time, in seconds, the delivery was
+	// signed at. A new timestamp is signed on every attempt.
+	TimestampHeader = "X-SISAP-Timestamp"
+	// IdempotencyHeader carries the event ID, which is the same on every
+	// attempt, so receivers can drop replays they have already handled.
+	IdempotencyHeader = "Idempotency-Key"
+)
+
+const signaturePrefix = "sha256="
+
+var (
+	ErrBadSignature = errors.New("webhook signature does not match")
+	ErrStale        = errors.New("webhook timestamp outside tolerance")
+)
+
+// Sign returns the SignatureHeader value for body signed at timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a delivery the way a receiver should: the signature must
+// match and the timestamp be within tolerance of now, which bounds how long
+// a captured request can be replayed. Receivers dedupe replays inside the
+// window on IdempotencyHeader.
+func Verify(secret, timestampHeader, signatureHeader string, body []byte, now time.Time, tolerance time.Duration) error {
+	ts, err := strconv.ParseInt(timestampHeader, 10, 64)
+	if err != nil {
+		return ErrBadSignature
+	}
+	if !strings.HasPrefix(signatureHeader, signaturePrefix) ||
+		!hmac.Equal([]byte(signatureHeader), []byte(Sign(secret, ts, body))) {
+		return ErrBadSignature
+	}
+	if d := now.Sub(time.Unix(ts, 0)); d > tolerance || d < -tolerance {
+		return ErrStale
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package webhook
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	now := time.Unix(1_760_000_000, 0)
+	body := []byte(`{"event":"match.created"}`)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	sig := Sign("s3cret", now.Unix(), body)
+
+	for _, tc := range []struct {
+		name      string
+		secret    string
+		timestamp string
+		signature string
+		body      string
+		now       time.Time
+		want      error
+	}{
+		{"valid", "s3cret", ts, sig, string(body), now, nil},
+		{"within tolerance", "s3cret", ts, sig, string(body), now.Add(4 * time.Minute), nil},
+		{"wrong secret", "other", ts, sig, string(body), now, ErrBadSignature},
+		{"tampered body", "s3cret", ts, sig, `{"event":"match.deleted"}`, now, ErrBadSignature},
+		{"timestamp changed", "s3cret", strconv.FormatInt(now.Unix()+1, 10), sig, string(body), now, ErrBadSignature},
+		{"bad timestamp", "s3cret", "yesterday", sig, string(body), now, ErrBadSignature},
+		{"missing prefix", "s3cret", ts, sig[len("sha256="):], string(body), now, ErrBadSignature},
+		{"replayed later", "s3cret", ts, sig, string(body), now.Add(10 * time.Minute), ErrStale},
+		{"from the future", "s3cret", ts, sig, string(body), now.Add(-10 * time.Minute), ErrStale},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Verify(tc.secret, tc.timestamp, tc.signature, []byte(tc.body), tc.now, 5*time.Minute)
+			if !errors.Is(err, tc.want) {
+				t.Errorf("Verify = %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+// Package webhook delivers matches to the signed webhooks configured
+// through the API. A Fanout notifier, run by the notify dispatcher, queues
+// one delivery per active webhook, and a Worker sends them: each attempt is
+// a JSON event signed with the webhook's secret (see Sign), retried with
+// exponential backoff until the webhook's max_attempts moves it to the dead
+// state.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/outbox"
+)
+
+// EventMatchCreated is the event sent for a new match.
+const EventMatchCreated = "match.created"
+
+const (
+	defaultBatchSize = 200
+	defaultWorkers   = 4
+	defaultQueueSize = 100
+	defaultRetention = 7 * 24 * time.Hour
+	deliveryTimeout  = 10 * time.Second
+)
+
+type enqueuer interface {
+	Enqueue(ctx context.Context, eventID, event string, payload []byte) (int, error)
+}
+
+// Fanout is a notify.Notifier that queues each match for every active
+// webhook. The event ID is derived from the match, so an outbox message
+// delivered twice queues nothing new.
+type Fanout struct {
+	store enqueuer
+}
+
+func NewFanout(store enqueuer) *Fanout {
+	return &Fanout{store: store}
+}
+
+func (f *Fanout) Name() string { return "webhooks" }
+
+func (f *Fanout) Notify(ctx context.Context, cert model.MatchedCertificate) error {
+	payload, err := json.Marshal(cert)
+	if err != nil {
+		return err
+	}
+	_, err = f.store.Enqueue(ctx, "match-"+strconv.Itoa(cert.ID), EventMatchCreated, payload)
+	return err
+}
+
+// Event is the body of a delivery.
+type Event struct {
+	Event     string          `json:"event"`
+	ID        string          `json:"id"`
+	Timestamp time.Time       `json:"timestamp"`
+	Data      json.RawMessage `json:"data"`
+}
+
+type deliveryStore interface {
+	Claim(ctx context.Context, limit int, leaseUntil time.Time) ([]model.WebhookDelivery, error)
+	MarkSent(ctx context.Context, id int64) error
+	MarkFailed(ctx context.Context, id int64, errMsg string, retryAt time.Time, dead bool) error
+	MarkDropped(ctx context.Context, ids []int64) error
+	Prune(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Worker polls for due deliveries and sends them, on the same poll loop
+// and bounded queue as notify.Dispatcher.
+type Worker struct {
+	store  deliveryStore
+	client *http.Client
+	now    func() time.Time
+	poller outbox.Poller[model.WebhookDelivery]
+}
+
+type Option func(*Worker)
+
+// WithWorkers sets how many deliveries are sent concurrently.
+func WithWorkers(n int) Option {
+	return func(w *Worker) {
+		if n > 0 {
+			w.poller.Workers = n
+		}
+	}
+}
+
+// WithBatchSize sets how many due deliveries a poll claims.
+func WithBatchSize(n int) Option {
+	return func(w *Worker) {
+		if n > 0 {
+			w.poller.BatchSize = n
+		}
+	}
+}
+
+// WithQueue sets how many deliveries may wait for a worker and what
+// happens when that many are already waiting: one of the outbox queue
+// policies. Under outbox.QueueDigest the overflow is sent one delivery at
+// a time once the queue has drained, as each is signed on its own.
+func WithQueue(size int, policy string) Option {
+	return func(w *Worker) {
+		if size > 0 {
+			w.poller.QueueSize = size
+		}
+		switch policy {
+		case outbox.QueueBlock, outbox.QueueDrop, outbox.QueueDropOldest, outbox.QueueDigest:
+			w.poller.Policy = policy
+		}
+	}
+}
+
+// WithRetention sets how long sent and dead deliveries are kept.
+func WithRetention(r time.Duration) Option {
+	return func(w *Worker) {
+		if r > 0 {
+			w.poller.Retention = r
+		}
+	}
+}
+
+// WithClient sets the HTTP client deliveries are sent with.
+func WithClient(c *http.Client) Option {
+	return func(w *Worker) {
+		if c != nil {
+			w.client = c
+		}
+	}
+}
+
+func NewWorker(store deliveryStore, interval time.Duration, opts ...Option) *Worker {
+	w := &Worker{
+		store:  store,
+		client: &http.Client{Timeout: deliveryTimeout},
+		now:    time.Now,
+	}
+	w.poller = outbox.Poller[model.WebhookDelivery]{
+		Name:    "webhook_deliveries",
+		Claim:   store.Claim,
+		Prune:   store.Prune,
+		Drop:    store.MarkDropped,
+		Deliver: w.deliver,
+		ID:      func(d model.WebhookDelivery) int64 { return d.ID },
+
+		Interval:  interval,
+		BatchSize: defaultBatchSize,
+		Workers:   defaultWorkers,
+		QueueSize: defaultQueueSize,
+		Policy:    outbox.QueueBlock,
+		Retention: defaultRetention,
+		Timeout:   deliveryTimeout,
+		Now:       func() time.Time { return w.now() },
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// Run polls until ctx is canceled. A batch already claimed is sent to
+// completion before Run returns, so callers should wait for it on shutdown.
+func (w *Worker) Run(ctx context.Context) {
+	w.poller.Run(ctx)
+}
+
+func (w *Worker) deliver(ctx context.Context, d model.WebhookDelivery) {
+	err := w.send(ctx, d)
+	if err == nil {
+		if err := w.store.MarkSent(ctx, d.ID); err != nil {
+			slog.Error("failed to mark webhook delivery sent", "error", err, "id", d.ID)
+		}
+		return
+	}
+
+	dead := d.Attempts+1 >= d.MaxAttempts
+	retryAt := w.now().Add(outbox.Backoff(d.Attempts))
+	if dead {
+		slog.Error("webhook delivery failed permanently",
+			"error", err, "id", d.ID, "webhook_id", d.WebhookID, "attempts", d.Attempts+1)
+	} else {
+		slog.Warn("webhook delivery failed, will retry",
+			"error", err, "id", d.ID, "webhook_id", d.WebhookID, "attempts", d.Attempts+1, "retry_at", retryAt)
+	}
+	if err := w.store.MarkFailed(ctx, d.ID, err.Error(), retryAt, dead); err != nil {
+		slog.Error("failed to record webhook delivery failure", "error", err, "id", d.ID)
+	}
+}
+
+// send signs and POSTs one attempt of d.
+func (w *Worker) send(ctx context.Context, d model.WebhookDelivery) error {
+	signedAt := w.now().Truncate(time.Second)
+	body, err := json.Marshal(Event{Event: d.Event, ID: d.EventID, Timestamp: signedAt.UTC(), Data: d.Payload})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TimestampHeader, strconv.FormatInt(signedAt.Unix(), 10))
+	req.Header.Set(SignatureHeader, Sign(d.Secret, signedAt.Unix(), body))
+	req.Header.Set(IdempotencyHeader, d.EventID)
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,309 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+	"github.com/andres10976/SISAP-PoC/backend/internal/service/outbox"
+)
+
+// memoryStore holds deliveries the way WebhookRepository does: Claim
+// returns the due pending ones, MarkFailed moves them to pending or dead
+// and MarkDropped to dropped.
+type memoryStore struct {
+	mu         sync.Mutex
+	deliveries []model.WebhookDelivery
+	enqueued   map[string]bool
+}
+
+func (m *memoryStore) Enqueue(ctx context.Context, eventID, event string, payload []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.enqueued[eventID] {
+		return 0, nil
+	}
+	if m.enqueued == nil {
+		m.enqueued = map[string]bool{}
+	}
+	m.enqueued[eventID] = true
+	m.deliveries = append(m.deliveries, model.WebhookDelivery{
+		ID: int64(len(m.deliveries) + 1), EventID: eventID, Event: event, Payload: payload,
+		Status: model.WebhookDeliveryPending,
+	})
+	return 1, nil
+}
+
+func (m *memoryStore) Claim(ctx context.Context, limit int, leaseUntil time.Time) ([]model.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var due []model.WebhookDelivery
+	for _, d := range m.deliveries {
+		if d.Status == model.WebhookDeliveryPending && len(due) < limit {
+			due = append(due, d)
+		}
+	}
+	return due, nil
+}
+
+func (m *memoryStore) update(id int64, fn func(d *model.WebhookDelivery)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.deliveries {
+		if m.deliveries[i].ID == id {
+			fn(&m.deliveries[i])
+		}
+	}
+}
+
+func (m *memoryStore) MarkSent(ctx context.Context, id int64) error {
+	m.update(id, func(d *model.WebhookDelivery) {
+		d.Status = model.WebhookDeliverySent
+		d.Attempts++
+		d.LastError = ""
+	})
+	return nil
+}
+
+func (m *memoryStore) MarkFailed(ctx context.Context, id int64, errMsg string, retryAt time.Time, dead bool) error {
+	m.update(id, func(d *model.WebhookDelivery) {
+		d.Status = model.WebhookDeliveryPending
+		if dead {
+			d.Status = model.WebhookDeliveryDead
+		}
+		d.Attempts++
+		d.LastError = errMsg
+		d.NextAttemptAt = retryAt
+	})
+	return nil
+}
+
+func (m *memoryStore) MarkDropped(ctx context.Context, ids []int64) error {
+	for _, id := range ids {
+		m.update(id, func(d *model.WebhookDelivery) {
+			if d.Status == model.WebhookDeliveryPending {
+				d.Status = model.WebhookDeliveryDropped
+			}
+		})
+	}
+	return nil
+}
+
+func (m *memoryStore) Prune(ctx context.Context, before time.Time) (int64, error) { return 0, nil }
+
+func (m *memoryStore) get(t *testing.T, id int64) model.WebhookDelivery {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, d := range m.deliveries {
+		if d.ID == id {
+			return d
+		}
+	}
+	t.Fatalf("no delivery %d", id)
+	return model.WebhookDelivery{}
+}
+
+// enqueueFor queues cert as Fanout would and points the delivery at url.
+func enqueueFor(t *testing.T, store *memoryStore, cert model.MatchedCertificate, url string, maxAttempts int) {
+	t.Helper()
+	if err := NewFanout(store).Notify(context.Background(), cert); err != nil {
+		t.Fatal(err)
+	}
+	store.update(int64(len(store.deliveries)), func(d *model.WebhookDelivery) {
+		d.URL, d.Secret, d.MaxAttempts = url, "s3cret", maxAttempts
+	})
+}
+
+func TestFanout_IdempotentEventID(t *testing.T) {
+	store := &memoryStore{}
+	f := NewFanout(store)
+	cert := model.MatchedCertificate{ID: 42, MatchedDomain: "paypal-login.example.com"}
+	for range 2 {
+		if err := f.Notify(context.Background(), cert); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(store.deliveries) != 1 {
+		t.Fatalf("%d deliveries, want 1 for a replayed match", len(store.deliveries))
+	}
+	d := store.deliveries[0]
+	if d.EventID != "match-42" || d.Event != EventMatchCreated {
+		t.Errorf("delivery = %+v, want event %s with ID match-42", d, EventMatchCreated)
+	}
+}
+
+func TestWorker_SignedDelivery(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	type received struct {
+		header http.Header
+		body   []byte
+	}
+	got := make(chan received, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- received{r.Header, body}
+	}))
+	defer srv.Close()
+
+	store := &memoryStore{}
+	enqueueFor(t, store, model.MatchedCertificate{ID: 7, MatchedDomain: "paypal-login.example.com"}, srv.URL, 3)
+	w := NewWorker(store, time.Second)
+	w.now = func() time.Time { return now }
+	w.poller.Poll(context.Background())
+
+	r := <-got
+	if err := Verify("s3cret", r.header.Get(TimestampHeader), r.header.Get(SignatureHeader), r.body, now, 5*time.Minute); err != nil {
+		t.Errorf("receiver could not verify the delivery: %v", err)
+	}
+	if err := Verify("wrong", r.header.Get(TimestampHeader), r.header.Get(SignatureHeader), r.body, now, 5*time.Minute); err == nil {
+		t.Error("delivery verified with the wrong secret")
+	}
+	if k := r.header.Get(IdempotencyHeader); k != "match-7" {
+		t.Errorf("%s = %q, want match-7", IdempotencyHeader, k)
+	}
+	if ts := r.header.Get(TimestampHeader); ts != strconv.FormatInt(now.Unix(), 10) {
+		t.Errorf("%s = %q, want %d", TimestampHeader, ts, now.Unix())
+	}
+
+	var event Event
+	if err := json.Unmarshal(r.body, &event); err != nil {
+		t.Fatal(err)
+	}
+	var cert model.MatchedCertificate
+	json.Unmarshal(event.Data, &cert)
+	if event.Event != EventMatchCreated || event.ID != "match-7" || !event.Timestamp.Equal(now) ||
+		cert.MatchedDomain != "paypal-login.example.com" {
+		t.Errorf("event = %+v, data = %+v", event, cert)
+	}
+	if d := store.get(t, 1); d.Status != model.WebhookDeliverySent || d.Attempts != 1 {
+		t.Errorf("delivery = %s after %d attempts, want sent after 1", d.Status, d.Attempts)
+	}
+}
+
+func TestWorker_RetryThenDead(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	var keys []string
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		keys = append(keys, r.Header.Get(IdempotencyHeader))
+		mu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	store := &memoryStore{}
+	enqueueFor(t, store, model.MatchedCertificate{ID: 9}, srv.URL, 3)
+	w := NewWorker(store, time.Second)
+	w.now = func() time.Time { return now }
+
+	for attempt, want := range []struct {
+		status string
+		retry  time.Duration
+	}{
+		{model.WebhookDeliveryPending, 30 * time.Second},
+		{model.WebhookDeliveryPending, time.Minute},
+		{model.WebhookDeliveryDead, 2 * time.Minute},
+	} {
+		w.poller.Poll(context.Background())
+		d := store.get(t, 1)
+		if d.Status != want.status || d.Attempts != attempt+1 {
+			t.Fatalf("after attempt %d: %s with %d attempts, want %s", attempt+1, d.Status, d.Attempts, want.status)
+		}
+		if !d.NextAttemptAt.Equal(now.Add(want.retry)) {
+			t.Errorf("after attempt %d: retry at %v, want %v", attempt+1, d.NextAttemptAt, now.Add(want.retry))
+		}
+		if d.LastError != "webhook returned status 503" {
+			t.Errorf("last error = %q", d.LastError)
+		}
+	}
+
+	// Dead deliveries are not claimed again.
+	w.poller.Poll(context.Background())
+	if len(keys) != 3 {
+		t.Errorf("%d attempts, want 3", len(keys))
+	}
+	for _, k := range keys {
+		if k != "match-9" {
+			t.Errorf("retry sent %s = %q, want the same key match-9", IdempotencyHeader, k)
+		}
+	}
+}
+
+func TestWorker_RetrySucceeds(t *testing.T) {
+	fail := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			fail = false
+			http.Error(w, "busy", http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	store := &memoryStore{}
+	enqueueFor(t, store, model.MatchedCertificate{ID: 1}, srv.URL, 3)
+	w := NewWorker(store, time.Second)
+	w.poller.Poll(context.Background())
+	if d := store.get(t, 1); d.Status != model.WebhookDeliveryPending {
+		t.Fatalf("after a 500: %s, want pending", d.Status)
+	}
+	w.poller.Poll(context.Background())
+	if d := store.get(t, 1); d.Status != model.WebhookDeliverySent || d.Attempts != 2 || d.LastError != "" {
+		t.Errorf("after retry: %+v, want sent after 2 attempts", d)
+	}
+}
+
+func TestWorker_DropPolicyMarksDeliveriesDropped(t *testing.T) {
+	release := make(chan struct{})
+	entered := make(chan struct{}, 5)
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		entered <- struct{}{}
+		<-release
+	}))
+	defer srv.Close()
+
+	store := &memoryStore{}
+	for id := range 5 {
+		enqueueFor(t, store, model.MatchedCertificate{ID: id + 1}, srv.URL, 3)
+	}
+	w := NewWorker(store, time.Second, WithWorkers(1), WithQueue(1, outbox.QueueDrop))
+
+	done := make(chan struct{})
+	go func() {
+		w.poller.Poll(context.Background())
+		close(done)
+	}()
+	<-entered
+	close(release)
+	<-done
+
+	var sent, dropped int
+	for id := range int64(5) {
+		switch store.get(t, id+1).Status {
+		case model.WebhookDeliverySent:
+			sent++
+		case model.WebhookDeliveryDropped:
+			dropped++
+		}
+	}
+	if sent < 1 || sent > 2 || sent+dropped != 5 {
+		t.Errorf("sent %d, dropped %d; want 1 or 2 sent and the rest marked dropped", sent, dropped)
+	}
+
+	// Dropped deliveries are not claimed again.
+	w.poller.Poll(context.Background())
+	if int(hits.Load()) != sent {
+		t.Errorf("%d requests, want only the %d sent", hits.Load(), sent)
+	}
+}
@@ -0,0 +1,147 @@
+// Package tlsserver lets the API server terminate TLS itself: it serves a
+// certificate from disk, reloads it when the files change, and redirects
+// plain HTTP to HTTPS.
+package tlsserver
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Reloader holds the current certificate for a cert/key file pair. Renewals
+// written over the files are picked up by Watch without a restart.
+type Reloader struct {
+	certFile, keyFile string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	certMod time.Time
+	keyMod  time.Time
+}
+
+// NewReloader loads certFile and keyFile, failing if either is unreadable,
+// they do not form a pair, or the certificate has expired.
+func NewReloader(certFile, keyFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the files again. On error the previous certificate stays in
+// use.
+func (r *Reloader) Reload() error {
+	certMod, keyMod, err := r.modTimes()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load TLS key pair: %w", err)
+	}
+	if time.Now().After(cert.Leaf.NotAfter) {
+		return fmt.Errorf("TLS certificate %s expired at %s", r.certFile, cert.Leaf.NotAfter.Format(time.RFC3339))
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.certMod, r.keyMod = certMod, keyMod
+	r.mu.Unlock()
+	return nil
+}
+
+// NotAfter is the expiry of the certificate being served.
+func (r *Reloader) NotAfter() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert.Leaf.NotAfter
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// TLSConfig returns a server configuration serving the reloaded
+// certificate. TLS 1.0 and 1.1 are refused; cipher suites are left to Go's
+// defaults, which only offer AEAD suites with forward secrecy first.
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: r.GetCertificate,
+	}
+}
+
+// Watch reloads the certificate when either file's modification time
+// changes (checked every interval) or a value arrives on reload, e.g. a
+// SIGHUP, until ctx is canceled. Failed reloads are logged and keep the
+// current certificate.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration, reload <-chan os.Signal) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+		case <-reload:
+		}
+		if err := r.Reload(); err != nil {
+			slog.ErrorContext(ctx, "TLS certificate reload failed; keeping the current one", "error", err)
+			continue
+		}
+		slog.InfoContext(ctx, "TLS certificate reloaded", "not_after", r.NotAfter())
+	}
+}
+
+// changed reports whether either file differs from the loaded one. A file
+// that cannot be read counts as changed so the failure gets logged.
+func (r *Reloader) changed() bool {
+	certMod, keyMod, err := r.modTimes()
+	if err != nil {
+		return true
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return !certMod.Equal(r.certMod) || !keyMod.Equal(r.keyMod)
+}
+
+func (r *Reloader) modTimes() (certMod, keyMod time.Time, err error) {
+	ci, err := os.Stat(r.certFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	ki, err := os.Stat(r.keyFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return ci.ModTime(), ki.ModTime(), nil
+}
+
+// RedirectHandler answers every request with a permanent redirect to the
+// same host and path over HTTPS on httpsPort.
+func RedirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,193 @@
+package tlsserver
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeKeyPair writes a self-signed certificate for cn valid until notAfter
+// to dir and returns the cert and key paths.
+func writeKeyPair(t *testing.T, dir, cn string, notAfter time.Time) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{cn},
+		NotBefore:    notAfter.Add(-48 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func servedCN(t *testing.T, r *Reloader) string {
+	t.Helper()
+	cert, err := r.GetCertificate(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatalf("GetCertificate() error = %v", err)
+	}
+	return cert.Leaf.Subject.CommonName
+}
+
+func TestNewReloader(t *testing.T) {
+	certFile, keyFile := writeKeyPair(t, t.TempDir(), "api.example", time.Now().Add(24*time.Hour))
+
+	r, err := NewReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("NewReloader() error = %v", err)
+	}
+	if got := servedCN(t, r); got != "api.example" {
+		t.Errorf("served CN = %q, want api.example", got)
+	}
+	if cfg := r.TLSConfig(); cfg.MinVersion != tls.VersionTLS12 {
+		t.Errorf("MinVersion = %x, want TLS 1.2", cfg.MinVersion)
+	}
+}
+
+func TestNewReloader_Errors(t *testing.T) {
+	dir := t.TempDir()
+	expiredCert, expiredKey := writeKeyPair(t, mkdir(t, dir, "expired"), "old.example", time.Now().Add(-time.Hour))
+	validCert, _ := writeKeyPair(t, mkdir(t, dir, "valid"), "api.example", time.Now().Add(time.Hour))
+	_, otherKey := writeKeyPair(t, mkdir(t, dir, "other"), "other.example", time.Now().Add(time.Hour))
+
+	for _, tc := range []struct {
+		name              string
+		certFile, keyFile string
+		want              string
+	}{
+		{"missing", filepath.Join(dir, "nope.crt"), filepath.Join(dir, "nope.key"), "no such file"},
+		{"expired", expiredCert, expiredKey, "expired"},
+		{"mismatched key", validCert, otherKey, "load TLS key pair"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewReloader(tc.certFile, tc.keyFile)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("NewReloader() error = %v, want one mentioning %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func mkdir(t *testing.T, parent, name string) string {
+	t.Helper()
+	dir := filepath.Join(parent, name)
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestWatch_ReloadsChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeKeyPair(t, dir, "old.example", time.Now().Add(time.Hour))
+	r, err := NewReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("NewReloader() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Watch(ctx, 10*time.Millisecond, nil)
+
+	writeKeyPair(t, dir, "new.example", time.Now().Add(time.Hour))
+	// Make the change visible even on filesystems with coarse mtimes.
+	later := time.Now().Add(time.Second)
+	os.Chtimes(certFile, later, later)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for servedCN(t, r) != "new.example" {
+		if time.Now().After(deadline) {
+			t.Fatal("renewed certificate was not picked up")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWatch_SignalReloadKeepsCertOnError(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeKeyPair(t, dir, "api.example", time.Now().Add(time.Hour))
+	r, err := NewReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("NewReloader() error = %v", err)
+	}
+
+	if err := os.WriteFile(certFile, []byte("garbage"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err == nil {
+		t.Fatal("Reload() of a corrupt file = nil, want error")
+	}
+	if got := servedCN(t, r); got != "api.example" {
+		t.Errorf("served CN after failed reload = %q, want api.example", got)
+	}
+
+	writeKeyPair(t, dir, "renewed.example", time.Now().Add(time.Hour))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hup := make(chan os.Signal, 1)
+	go r.Watch(ctx, time.Hour, hup)
+	hup <- os.Interrupt
+
+	deadline := time.Now().Add(2 * time.Second)
+	for servedCN(t, r) != "renewed.example" {
+		if time.Now().After(deadline) {
+			t.Fatal("signal did not reload the certificate")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRedirectHandler(t *testing.T) {
+	for _, tc := range []struct {
+		port, host, target, want string
+	}{
+		{"8443", "api.example:8080", "/api/v1/stats?days=7", "https://api.example:8443/api/v1/stats?days=7"},
+		{"443", "api.example", "/", "https://api.example/"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+		req.Host = tc.host
+		rec := httptest.NewRecorder()
+		RedirectHandler(tc.port).ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusPermanentRedirect {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusPermanentRedirect)
+		}
+		if got := rec.Header().Get("Location"); got != tc.want {
+			t.Errorf("Location = %q, want %q", got, tc.want)
+		}
+	}
+}
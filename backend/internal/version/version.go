@@ -0,0 +1,38 @@
+// Package version reports which build is running. The variables are set at
+// link time, e.g.
+//
+//	go build -ldflags "-X github.com/andres10976/SISAP-PoC/backend/internal/version.Version=v1.2.0 \
+//	  -X github.com/andres10976/SISAP-PoC/backend/internal/version.Commit=$(git rev-parse HEAD) \
+//	  -X github.com/andres10976/SISAP-PoC/backend/internal/version.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+package version
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/andres10976/SISAP-PoC/backend/internal/model"
+)
+
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+var started = time.Now()
+
+// Info describes the running build and how long the process has been up.
+func Info() model.BuildInfo {
+	return model.BuildInfo{
+		Version:       Version,
+		Commit:        Commit,
+		BuildDate:     BuildDate,
+		GoVersion:     runtime.Version(),
+		UptimeSeconds: int64(time.Since(started).Seconds()),
+	}
+}
+
+// UserAgent is the User-Agent sent to CT logs.
+func UserAgent() string {
+	return "sisap-ct-monitor/" + Version
+}
@@ -1,4 +1,7 @@
-export const API_BASE = import.meta.env.VITE_API_URL ?? "/api/v1";
+// BASE_URL is "/" unless the app is built with `vite build --base`, e.g.
+// for a backend running with BASE_PATH.
+export const API_BASE =
+  import.meta.env.VITE_API_URL ?? `${import.meta.env.BASE_URL}api/v1`;
 
 export class ApiError extends Error {
   constructor(
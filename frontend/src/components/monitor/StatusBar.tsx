@@ -54,6 +54,7 @@ export function StatusBar({
       {status?.last_error ? (
         <span className="text-xs text-red-400 max-w-xs truncate" title={status.last_error}>
           Error: {status.last_error}
+          {status.last_error_count > 1 && ` (x${status.last_error_count})`}
         </span>
       ) : status?.last_run_at ? (
         <span className="text-xs text-gray-500">
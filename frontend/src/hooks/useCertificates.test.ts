@@ -14,6 +14,9 @@ const cert1 = {
   serial_number: "AA:BB",
   common_name: "example.com",
   sans: ["example.com"],
+  san_count: 1,
+  ext_key_usages: ["server_auth"],
+  is_server_auth: true,
   issuer: "Let's Encrypt",
   not_before: "2024-01-01T00:00:00Z",
   not_after: "2024-12-31T23:59:59Z",
@@ -22,6 +25,10 @@ const cert1 = {
   matched_domain: "example.com",
   ct_log_index: 100,
   discovered_at: "2024-01-01T12:00:00Z",
+  first_seen_index: 100,
+  first_seen_at: "2024-01-01T12:00:00Z",
+  last_seen_index: 100,
+  last_seen_at: "2024-01-01T12:00:00Z",
 };
 
 describe("useCertificates", () => {
@@ -14,6 +14,7 @@ const mockStop = vi.mocked(api.stopMonitor);
 
 const mockStatus = {
   is_running: true,
+  paused: false,
   last_run_at: "2024-01-01T00:00:00Z",
   last_tree_size: 1000,
   last_processed_index: 500,
@@ -22,6 +23,8 @@ const mockStatus = {
   matches_in_last_cycle: 3,
   parse_errors_in_last_cycle: 0,
   last_error: "",
+  last_error_first_seen: null,
+  last_error_count: 0,
   updated_at: "2024-01-01T12:00:00Z",
 };
 
@@ -3,6 +3,9 @@ export interface MatchedCertificate {
   serial_number: string;
   common_name: string;
   sans: string[];
+  san_count: number;
+  ext_key_usages: string[];
+  is_server_auth: boolean;
   issuer: string;
   not_before: string; // ISO 8601
   not_after: string; // ISO 8601
@@ -11,6 +14,11 @@ export interface MatchedCertificate {
   matched_domain: string;
   ct_log_index: number;
   discovered_at: string; // ISO 8601
+  // First and latest log entry/time this serial was seen for the keyword.
+  first_seen_index: number;
+  first_seen_at: string; // ISO 8601
+  last_seen_index: number;
+  last_seen_at: string; // ISO 8601
 }
 
 export interface CertificatesResponse {
@@ -19,3 +27,11 @@ export interface CertificatesResponse {
   page: number;
   per_page: number;
 }
+
+// Response to GET /certificates?since_id=N: matches newer than N in
+// ascending id order; pass last_id as the next since_id.
+export interface CertificatesSinceResponse {
+  certificates: MatchedCertificate[];
+  count: number;
+  last_id: number;
+}
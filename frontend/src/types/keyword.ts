@@ -1,6 +1,9 @@
+export type MatchMode = "substring" | "boundary";
+
 export interface Keyword {
   id: number;
   value: string;
+  match_mode: MatchMode;
   created_at: string; // ISO 8601
 }
 
@@ -10,4 +13,5 @@ export interface KeywordsResponse {
 
 export interface CreateKeywordRequest {
   value: string;
+  match_mode?: MatchMode;
 }
@@ -1,5 +1,6 @@
 export interface MonitorStatus {
   is_running: boolean;
+  paused: boolean;
   last_run_at: string | null;
   last_tree_size: number;
   last_processed_index: number;
@@ -8,5 +9,7 @@ export interface MonitorStatus {
   matches_in_last_cycle: number;
   parse_errors_in_last_cycle: number;
   last_error: string;
+  last_error_first_seen: string | null;
+  last_error_count: number;
   updated_at: string;
 }